@@ -39,6 +39,7 @@ type ChatAgentConfig struct {
 	MaxStep             int    `json:"maxStep" label:"Max Steps" desc:"Maximum number of reasoning-tool loops the agent can perform"`
 	MaxToolOutputLength int    `json:"maxToolOutputLength" label:"Max Tool Output Length" desc:"Truncate tool output beyond this length to prevent context overflow. Default 50000"`
 	StreamToolCallCheck string `json:"streamToolCallCheck" label:"Stream Tool-call Check" desc:"How to detect tool calls in streaming output (agents with tools only): empty=auto (default, suits most models), firstContent=decide on first text chunk, drain=consume whole stream first"`
+	SessionCompaction   bool   `json:"sessionCompaction" label:"Session Compaction" desc:"Summarize older session messages with this agent's model once the session manager's compaction threshold is reached (requires a session aspect whose session config enables CompactionConfig)"`
 }
 
 // Desc returns the component description
@@ -95,6 +96,7 @@ type ToolWrapOptions struct {
 	MaxToolOutputLength int // 工具输出最大长度
 	Logger              types.Logger
 	MetricsCollector    *token.MetricsCollector
+	Model               string // 计算工具输入输出 token 使用的默认模型
 }
 
 // ============================================
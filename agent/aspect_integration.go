@@ -24,9 +24,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 	"github.com/rulego/rulego-components-ai/aspect"
 	"github.com/rulego/rulego-components-ai/config"
+	"github.com/rulego/rulego-components-ai/session"
+	"github.com/rulego/rulego-components-ai/tool/common"
 	"github.com/rulego/rulego/api/types"
 )
 
@@ -57,12 +60,29 @@ func (e *AgentAspectExecutor) Manager() *aspect.AspectManager {
 	return e.manager
 }
 
+// compactionModelSetter 可设置会话压缩模型的切面（如 builtin.SessionAspect）
+type compactionModelSetter interface {
+	SetCompactionModel(chatModel model.BaseChatModel)
+}
+
+// EnableSessionCompaction 让会话切面使用 chatModel 生成会话压缩摘要
+// 切面由 New() 为每个节点单独创建，设置只影响当前节点
+func (e *AgentAspectExecutor) EnableSessionCompaction(chatModel model.BaseChatModel) {
+	for _, a := range e.manager.Aspects() {
+		if setter, ok := a.(compactionModelSetter); ok {
+			setter.SetCompactionModel(chatModel)
+		}
+	}
+}
+
 // ExecuteOptions 执行选项
 type ExecuteOptions struct {
 	ChainId    string
 	AgentName  string
 	Msg        types.RuleMsg
 	SessionKey string
+	// Model 节点配置的默认模型，写入执行点元数据 aspect.MetaLLMModel
+	Model string
 }
 
 // ExecuteSync 同步执行（带切面）
@@ -79,6 +99,11 @@ func (e *AgentAspectExecutor) ExecuteSync(
 	// 创建工具调用收集器并注入到 context
 	toolCallsCollector := aspect.NewToolCallsCollector()
 	ctx = aspect.WithToolCallsCollector(ctx, toolCallsCollector)
+	// 创建 LLM 调用用量收集器，由模型包装器按调用记录用量
+	ctx = aspect.WithLLMUsageCollector(ctx, aspect.NewLLMUsageCollector())
+	// 注入切面管理器与执行点，供每次 LLM 调用前执行 MessageBefore 切面
+	ctx = aspect.WithAspectManager(ctx, e.manager)
+	ctx = aspect.WithAgentPoint(ctx, point)
 
 	// 1. Start 切面
 	input, err := e.manager.ExecuteStart(ctx, point, input)
@@ -94,6 +119,13 @@ func (e *AgentAspectExecutor) ExecuteSync(
 		return nil, err
 	}
 
+	// 会话 key 在 Before 阶段确定，注入 context 供工具按会话隔离状态（如 edit 备份、bash 常驻 Shell）
+	if input.SessionKey != "" {
+		ctx = session.WithSessionKey(ctx, input.SessionKey)
+	}
+	// runId 注入 context，文件工具（edit/write/bash）把本次执行的改动记入同一个检查点
+	ctx = common.WithRunID(ctx, point.Metadata[aspect.MetaRunID])
+
 	// 3. 合并历史消息
 	mergedMessages := e.mergeMessages(input, messages)
 
@@ -142,6 +174,11 @@ func (e *AgentAspectExecutor) ExecuteStream(
 	// 创建工具调用收集器并注入到 context
 	toolCallsCollector := aspect.NewToolCallsCollector()
 	ctx = aspect.WithToolCallsCollector(ctx, toolCallsCollector)
+	// 创建 LLM 调用用量收集器，由模型包装器按调用记录用量
+	ctx = aspect.WithLLMUsageCollector(ctx, aspect.NewLLMUsageCollector())
+	// 注入切面管理器与执行点，供每次 LLM 调用前执行 MessageBefore 切面
+	ctx = aspect.WithAspectManager(ctx, e.manager)
+	ctx = aspect.WithAgentPoint(ctx, point)
 
 	// 1. Start 切面
 	input, err := e.manager.ExecuteStart(ctx, point, input)
@@ -157,6 +194,13 @@ func (e *AgentAspectExecutor) ExecuteStream(
 		return nil, err
 	}
 
+	// 会话 key 在 Before 阶段确定，注入 context 供工具按会话隔离状态（如 edit 备份、bash 常驻 Shell）
+	if input.SessionKey != "" {
+		ctx = session.WithSessionKey(ctx, input.SessionKey)
+	}
+	// runId 注入 context，文件工具（edit/write/bash）把本次执行的改动记入同一个检查点
+	ctx = common.WithRunID(ctx, point.Metadata[aspect.MetaRunID])
+
 	// 3. 合并历史消息
 	mergedMessages := e.mergeMessages(input, messages)
 
@@ -265,6 +309,12 @@ func (e *AgentAspectExecutor) buildPoint(opts ExecuteOptions) *aspect.AgentPoint
 	for k, v := range opts.Msg.Metadata.Values() {
 		point.Metadata[k] = v
 	}
+	if point.Metadata[aspect.MetaRunID] == "" {
+		point.Metadata[aspect.MetaRunID] = opts.Msg.Id
+	}
+	if opts.Model != "" {
+		point.Metadata[aspect.MetaLLMModel] = opts.Model
+	}
 
 	return point
 }
@@ -382,6 +432,25 @@ func InjectAspectManager(ctx context.Context, manager *aspect.AspectManager) con
 	return aspect.WithAspectManager(ctx, manager)
 }
 
+// ExecuteMessageBefore 在单次 LLM 调用前执行 MessageBefore 切面
+// 切面管理器与执行点由 ExecuteSync/ExecuteStream 注入 context，不在切面执行链内的调用直接返回原消息。
+// modelName 为本次调用实际使用的模型，经 aspect.WithLLMModel 随 ctx 传给切面（如上下文预算），
+// 不写入执行点元数据：父智能体的工具与 fork 的子智能体并发运行时会同时读取执行点。
+func ExecuteMessageBefore(ctx context.Context, modelName string, messages []*schema.Message) ([]*schema.Message, error) {
+	manager, ok := aspect.GetAspectManager(ctx)
+	point := aspect.GetAgentPoint(ctx)
+	if !ok || manager == nil || point == nil {
+		return messages, nil
+	}
+	if point.Metadata == nil {
+		point.Metadata = make(map[string]string)
+	}
+	if modelName != "" {
+		ctx = aspect.WithLLMModel(ctx, modelName)
+	}
+	return manager.ExecuteMessageBefore(ctx, point, messages)
+}
+
 // BuildTokenMetadata 构建 token 统计元数据
 func BuildTokenMetadata(msg types.RuleMsg, tokenUsage aspect.TokenUsage, modelName string) {
 	msg.Metadata.PutValue(config.KeyModel, modelName)
@@ -20,8 +20,11 @@ import (
 	"context"
 	"testing"
 
+	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 	"github.com/rulego/rulego-components-ai/aspect"
+	"github.com/rulego/rulego-components-ai/config"
+	aierrors "github.com/rulego/rulego-components-ai/errors"
 	"github.com/rulego/rulego/api/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -238,3 +241,164 @@ func TestAspectIntegration_MultipleAroundAspects_Interrupt(t *testing.T) {
 		assert.False(t, aspect3.calledBefore, "Aspect3 should NOT be reached")
 	})
 }
+
+// mockMessageBeforeAspect 记录 BeforeLLM 调用并可裁剪或拒绝消息
+type mockMessageBeforeAspect struct {
+	model string
+	calls int
+	err   error
+}
+
+func (a *mockMessageBeforeAspect) Order() int { return 1 }
+
+func (a *mockMessageBeforeAspect) New() aspect.Aspect { return a }
+
+func (a *mockMessageBeforeAspect) PointCut(ctx context.Context, point *aspect.AgentPoint) bool {
+	return true
+}
+
+func (a *mockMessageBeforeAspect) BeforeLLM(ctx context.Context, point *aspect.AgentPoint, messages []*schema.Message) ([]*schema.Message, error) {
+	a.calls++
+	a.model = aspect.GetLLMModel(ctx)
+	if a.err != nil {
+		return nil, a.err
+	}
+	return messages[len(messages)-1:], nil
+}
+
+// recordingChatModel 记录收到的消息
+type recordingChatModel struct {
+	received []*schema.Message
+}
+
+func (m *recordingChatModel) Generate(_ context.Context, input []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	m.received = input
+	return schema.AssistantMessage("ok", nil), nil
+}
+
+func (m *recordingChatModel) Stream(_ context.Context, input []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	m.received = input
+	return streamReaderFromChunks("ok"), nil
+}
+
+func (m *recordingChatModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// TestAspectIntegration_MessageBeforeOnEachLLMCall 测试模型包装器在每次 LLM 调用前执行 MessageBefore 切面
+func TestAspectIntegration_MessageBeforeOnEachLLMCall(t *testing.T) {
+	executor := NewAgentAspectExecutor(NewTestLogger(t))
+	executor.manager = aspect.NewAspectManager()
+	before := &mockMessageBeforeAspect{}
+	executor.manager.Register(before)
+
+	base := &recordingChatModel{}
+	chatModel := WrapModelWithDynamicSupport(base, config.LLMConfig{Model: "default-model"}, ModelOptions{})
+
+	opts := ExecuteOptions{
+		ChainId:   "test_chain",
+		AgentName: "test_agent",
+		Msg:       types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), ""),
+	}
+	messages := []*schema.Message{schema.SystemMessage("sys"), schema.UserMessage("Hello")}
+
+	_, err := executor.ExecuteSync(context.Background(), opts, &aspect.AgentInput{}, messages, func(ctx context.Context, msgs []*schema.Message) (*schema.Message, error) {
+		ctx = ContextWithSessionModel(ctx, "session-model")
+		return chatModel.Generate(ctx, msgs)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, before.calls)
+	assert.Equal(t, "session-model", before.model)
+	require.Len(t, base.received, 1, "model should receive messages returned by the aspect")
+
+	_, err = executor.ExecuteStream(context.Background(), opts, &aspect.AgentInput{}, messages,
+		func(ctx context.Context, msgs []*schema.Message) (*schema.StreamReader[*schema.Message], error) {
+			return chatModel.Stream(ctx, msgs)
+		}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, before.calls)
+	assert.Equal(t, "default-model", before.model)
+
+	// 切面返回错误时不调用模型
+	base.received = nil
+	before.err = aierrors.LLMContextTooLong()
+	_, err = executor.ExecuteSync(context.Background(), opts, &aspect.AgentInput{}, messages, func(ctx context.Context, msgs []*schema.Message) (*schema.Message, error) {
+		return chatModel.Generate(ctx, msgs)
+	})
+	assert.True(t, aierrors.IsCode(err, aierrors.CodeLLMContextTooLong))
+	assert.Nil(t, base.received)
+
+	// 不在切面执行链内的调用不执行切面
+	_, err = chatModel.Generate(context.Background(), messages)
+	require.NoError(t, err)
+	assert.Equal(t, 3, before.calls)
+}
+
+// usageChatModel 返回带 token 用量的响应，流式用量在最后一个分片返回
+type usageChatModel struct{}
+
+func (m *usageChatModel) Generate(_ context.Context, _ []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	msg := schema.AssistantMessage("ok", nil)
+	msg.ResponseMeta = &schema.ResponseMeta{Usage: &schema.TokenUsage{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110}}
+	return msg, nil
+}
+
+func (m *usageChatModel) Stream(_ context.Context, _ []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	last := schema.AssistantMessage("", nil)
+	last.ResponseMeta = &schema.ResponseMeta{Usage: &schema.TokenUsage{PromptTokens: 200, CompletionTokens: 20, TotalTokens: 220}}
+	return schema.StreamReaderFromArray([]*schema.Message{schema.AssistantMessage("o", nil), schema.AssistantMessage("k", nil), last}), nil
+}
+
+func (m *usageChatModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// mockUsageCompletedAspect 在 OnCompleted 时读取 LLM 调用用量
+type mockUsageCompletedAspect struct {
+	calls []aspect.LLMCallUsage
+}
+
+func (a *mockUsageCompletedAspect) Order() int         { return 100 }
+func (a *mockUsageCompletedAspect) New() aspect.Aspect { return a }
+func (a *mockUsageCompletedAspect) PointCut(_ context.Context, _ *aspect.AgentPoint) bool {
+	return true
+}
+func (a *mockUsageCompletedAspect) OnCompleted(ctx context.Context, _ *aspect.AgentPoint, _ *aspect.AgentOutput) {
+	a.calls = aspect.GetLLMUsagesFromContext(ctx)
+}
+
+func TestAspectIntegration_CollectsLLMUsagePerCall(t *testing.T) {
+	executor := NewAgentAspectExecutor(NewTestLogger(t))
+	executor.manager = aspect.NewAspectManager()
+	completed := &mockUsageCompletedAspect{}
+	executor.manager.Register(completed)
+
+	chatModel := WrapModelWithDynamicSupport(&usageChatModel{}, config.LLMConfig{Model: "default-model"}, ModelOptions{})
+	opts := ExecuteOptions{
+		ChainId: "test_chain",
+		Msg:     types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), ""),
+	}
+	messages := []*schema.Message{schema.UserMessage("Hello")}
+
+	// 一次执行内两轮 LLM 调用（如工具调用后再次生成），分别记录
+	_, err := executor.ExecuteSync(context.Background(), opts, &aspect.AgentInput{}, messages, func(ctx context.Context, msgs []*schema.Message) (*schema.Message, error) {
+		if _, err := chatModel.Generate(ctx, msgs); err != nil {
+			return nil, err
+		}
+		return chatModel.Generate(ContextWithSessionModel(ctx, "session-model"), msgs)
+	})
+	require.NoError(t, err)
+	require.Len(t, completed.calls, 2)
+	assert.Equal(t, "default-model", completed.calls[0].Model)
+	assert.Equal(t, "session-model", completed.calls[1].Model)
+	assert.Equal(t, 110, completed.calls[1].Usage.TotalTokens)
+
+	_, err = executor.ExecuteStream(context.Background(), opts, &aspect.AgentInput{}, messages,
+		func(ctx context.Context, msgs []*schema.Message) (*schema.StreamReader[*schema.Message], error) {
+			return chatModel.Stream(ctx, msgs)
+		}, nil)
+	require.NoError(t, err)
+	require.Len(t, completed.calls, 1)
+	assert.Equal(t, 200, completed.calls[0].Usage.PromptTokens)
+	assert.Equal(t, 20, completed.calls[0].Usage.CompletionTokens)
+}
@@ -80,8 +80,8 @@ const maxCachedModels = 32
 type DynamicModelWrapper struct {
 	baseModel    model.ToolCallingChatModel
 	llmConfig    config.LLMConfig
-	modelCache   *sync.Map // modelID -> model.ToolCallingChatModel（指针，WithTools 派生实例共享）
-	cacheCount   *int32    // 缓存中的模型数量（指针，WithTools 派生实例共享）
+	modelCache   *sync.Map // modelID -> model.ToolCallingChatModel（重建的模型绑定了 tools，每个 WithTools 派生实例独立缓存）
+	cacheCount   *int32    // 缓存中的模型数量
 	logger       types.Logger
 	modelOptions ModelOptions
 	tools        []*schema.ToolInfo // 绑定的工具列表（重建模型时需重新绑定，否则工具调用失效）
@@ -168,7 +168,15 @@ func (w *DynamicModelWrapper) getModelForContext(ctx context.Context) model.Tool
 	return newModel
 }
 
-// Generate 生成方法
+// effectiveModel 返回本次调用实际使用的模型名称
+func (w *DynamicModelWrapper) effectiveModel(sessionModel string) string {
+	if sessionModel != "" {
+		return sessionModel
+	}
+	return w.llmConfig.Model
+}
+
+// Generate 生成方法（调用前执行 MessageBefore 切面）
 func (w *DynamicModelWrapper) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
 	sessionModel := SessionModelFromContext(ctx)
 	// 覆盖 opts 里可能存在的 WithModel（eino compose 会注入节点默认 model），
@@ -176,32 +184,82 @@ func (w *DynamicModelWrapper) Generate(ctx context.Context, input []*schema.Mess
 	if sessionModel != "" {
 		opts = append(opts, model.WithModel(sessionModel))
 	}
+	input, err := ExecuteMessageBefore(ctx, w.effectiveModel(sessionModel), input)
+	if err != nil {
+		return nil, err
+	}
 	m := w.getModelForContext(ctx)
-	return m.Generate(ctx, input, opts...)
+	resp, err := m.Generate(ctx, input, opts...)
+	if err == nil && resp != nil {
+		if usage, ok := messageUsage(resp); ok {
+			if c := aspect.GetLLMUsageCollector(ctx); c != nil {
+				c.Add(aspect.LLMCallUsage{Model: w.effectiveModel(sessionModel), Usage: usage})
+			}
+		}
+	}
+	return resp, err
 }
 
-// Stream 流式生成方法
+// Stream 流式生成方法（调用前执行 MessageBefore 切面）
 func (w *DynamicModelWrapper) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
 	sessionModel := SessionModelFromContext(ctx)
 	if sessionModel != "" {
 		opts = append(opts, model.WithModel(sessionModel))
 	}
+	input, err := ExecuteMessageBefore(ctx, w.effectiveModel(sessionModel), input)
+	if err != nil {
+		return nil, err
+	}
 	m := w.getModelForContext(ctx)
-	return m.Stream(ctx, input, opts...)
+	sr, err := m.Stream(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c := aspect.GetLLMUsageCollector(ctx)
+	if c == nil {
+		return sr, nil
+	}
+	// 透传分片，记录最后一次返回的用量
+	modelName := w.effectiveModel(sessionModel)
+	idx := -1
+	return schema.StreamReaderWithConvert(sr, func(chunk *schema.Message) (*schema.Message, error) {
+		if usage, ok := messageUsage(chunk); ok {
+			if idx < 0 {
+				idx = c.Add(aspect.LLMCallUsage{Model: modelName, Usage: usage})
+			} else {
+				c.Update(idx, usage)
+			}
+		}
+		return chunk, nil
+	}), nil
 }
 
-// WithTools 设置工具并返回新模型，新实例共享同一个 modelCache
+// messageUsage 提取模型响应中的 token 用量
+func messageUsage(msg *schema.Message) (aspect.TokenUsage, bool) {
+	if msg == nil || msg.ResponseMeta == nil || msg.ResponseMeta.Usage == nil {
+		return aspect.TokenUsage{}, false
+	}
+	u := msg.ResponseMeta.Usage
+	return aspect.TokenUsage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		CachedTokens:     u.PromptTokenDetails.CachedTokens,
+	}, true
+}
+
+// WithTools 设置工具并返回新模型，新实例使用独立的 modelCache
 func (w *DynamicModelWrapper) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
 	newModel, err := w.baseModel.WithTools(tools)
 	if err != nil {
 		return nil, err
 	}
-	// modelCache/cacheCount 为指针，派生实例与原实例共享同一份缓存与计数
+	// 缓存的重建模型绑定了各自的工具列表，不能与其他工具集（刷新前的 agent、fork 的子智能体）共享
 	return &DynamicModelWrapper{
 		baseModel:    newModel,
 		llmConfig:    w.llmConfig,
-		modelCache:   w.modelCache,
-		cacheCount:   w.cacheCount,
+		modelCache:   &sync.Map{},
+		cacheCount:   new(int32),
 		logger:       w.logger,
 		modelOptions: w.modelOptions,
 		tools:        tools, // 记住工具，重建模型（切模型/extra 覆盖）时重新绑定
@@ -313,44 +313,97 @@ type ToolOptions struct {
 	WrapVisual     bool
 	WrapOptions    ToolWrapOptions
 	Logger         types.Logger
+	// Vision 模型是否支持视觉，为 true 时远程 MCP 工具返回的图片以多模态 part 交给模型
+	Vision bool
 }
 
 // CreateTools 批量创建工具
 func CreateTools(toolsConfig []config.Tool, opts ToolOptions) ([]tool.BaseTool, []*schema.ToolInfo, aitool.DynamicSkillLister, error) {
-	var tools []tool.BaseTool
-	var toolInfoList []*schema.ToolInfo
+	groups, skillLister, err := createToolGroups(toolsConfig, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	tools, toolInfoList := flattenToolGroups(groups)
+	return tools, toolInfoList, skillLister, nil
+}
+
+// toolGroup 一条工具配置创建出的工具及其信息。
+// set 非空表示工具来自远程 MCP 服务器，服务器的工具列表变化后需通过 refreshToolGroup 换用新工具。
+type toolGroup struct {
+	tools []tool.BaseTool
+	infos []*schema.ToolInfo
+	set   *mcpadapter.RemoteToolSet
+}
+
+// createToolGroups 按工具配置创建工具，每条配置对应一个 toolGroup
+func createToolGroups(toolsConfig []config.Tool, opts ToolOptions) ([]toolGroup, aitool.DynamicSkillLister, error) {
+	var groups []toolGroup
 	var skillLister aitool.DynamicSkillLister
 
 	for _, toolConfig := range toolsConfig {
 		if toolConfig.Type == config.ToolTypeMCP {
 			// MCP 类型：一条 config 展开为多个工具
-			mcpTools, mcpInfos, err := createMCPTools(toolConfig, opts)
+			group, err := createMCPTools(toolConfig, opts)
 			if err != nil {
-				return nil, nil, nil, err
+				closeToolGroups(groups)
+				return nil, nil, err
 			}
-			tools = append(tools, mcpTools...)
-			toolInfoList = append(toolInfoList, mcpInfos...)
+			groups = append(groups, group)
 		} else {
 			// 其他类型：单个工具
 			t, info, sl, err := CreateTool(toolConfig, opts)
 			if err != nil {
-				return nil, nil, nil, err
+				closeToolGroups(groups)
+				return nil, nil, err
 			}
 			if sl != nil && skillLister == nil {
 				skillLister = sl
 			}
-			tools = append(tools, t)
-			toolInfoList = append(toolInfoList, info)
+			groups = append(groups, toolGroup{tools: []tool.BaseTool{t}, infos: []*schema.ToolInfo{info}})
 		}
 	}
 
-	return tools, toolInfoList, skillLister, nil
+	return groups, skillLister, nil
+}
+
+// flattenToolGroups 按配置顺序合并各组的工具与工具信息
+func flattenToolGroups(groups []toolGroup) ([]tool.BaseTool, []*schema.ToolInfo) {
+	var tools []tool.BaseTool
+	var toolInfoList []*schema.ToolInfo
+	for _, group := range groups {
+		tools = append(tools, group.tools...)
+		toolInfoList = append(toolInfoList, group.infos...)
+	}
+	return tools, toolInfoList
+}
+
+// refreshToolGroup 用远程工具集的当前工具重建 group，工具的包装方式与创建时一致
+func refreshToolGroup(group toolGroup, opts ToolOptions) (toolGroup, error) {
+	if group.set == nil {
+		return group, nil
+	}
+	refreshed, err := wrapMCPTools(group.set.Tools(), opts)
+	if err != nil {
+		return group, err
+	}
+	refreshed.set = group.set
+	return refreshed, nil
+}
+
+// closeToolGroups 关闭各组持有的远程 MCP 连接
+func closeToolGroups(groups []toolGroup) {
+	for _, group := range groups {
+		if group.set != nil {
+			_ = group.set.Close()
+		}
+	}
 }
 
 // createMCPTools 从 MCP 工具配置创建工具列表。
 // 支持 self（进程内）和远程（http/stdio）两种模式。
 // tools 字段为可选过滤器：nil/空 表示自动发现全部工具。
-func createMCPTools(toolConfig config.Tool, opts ToolOptions) ([]tool.BaseTool, []*schema.ToolInfo, error) {
+// 远程模式返回的 group 持有 RemoteToolSet，订阅服务器的工具列表变化通知。
+func createMCPTools(toolConfig config.Tool, opts ToolOptions) (toolGroup, error) {
 	server := ""
 	var filterTools []string
 
@@ -367,28 +420,39 @@ func createMCPTools(toolConfig config.Tool, opts ToolOptions) ([]tool.BaseTool,
 		}
 	}
 
-	var tools []tool.BaseTool
-	var err error
-
 	switch {
 	case server == "self":
-		tools, err = createSelfMCPTools(opts.RuleConfig, filterTools)
+		tools, err := createSelfMCPTools(opts.RuleConfig, filterTools)
+		if err != nil {
+			return toolGroup{}, err
+		}
+		return wrapMCPTools(tools, opts)
 	case server != "":
-		tools, err = createRemoteMCPTools(server, filterTools)
+		// vision 为 true 时创建多模态工具，工具结果中的图片直接交给模型
+		set, err := mcpadapter.NewRemoteToolSet(server, filterTools, mcpadapter.RemoteOptions{Vision: opts.Vision})
+		if err != nil {
+			return toolGroup{}, err
+		}
+		group, err := wrapMCPTools(set.Tools(), opts)
+		if err != nil {
+			_ = set.Close()
+			return toolGroup{}, err
+		}
+		group.set = set
+		return group, nil
 	default:
-		return nil, nil, fmt.Errorf("mcp 工具配置缺少 server 字段")
-	}
-
-	if err != nil {
-		return nil, nil, err
+		return toolGroup{}, fmt.Errorf("mcp 工具配置缺少 server 字段")
 	}
+}
 
+// wrapMCPTools 获取 MCP 工具信息，并按需包装可视化
+func wrapMCPTools(tools []tool.BaseTool, opts ToolOptions) (toolGroup, error) {
 	// 获取工具信息
 	var infos []*schema.ToolInfo
 	for _, t := range tools {
 		info, e := t.Info(context.Background())
 		if e != nil {
-			return nil, nil, e
+			return toolGroup{}, e
 		}
 		infos = append(infos, info)
 	}
@@ -396,17 +460,19 @@ func createMCPTools(toolConfig config.Tool, opts ToolOptions) ([]tool.BaseTool,
 	// 可选：包装可视化
 	if opts.WrapVisual {
 		for i, t := range tools {
+			wrapOpts := opts.WrapOptions
+			wrapOpts.Name = infos[i].Name
+			wrapOpts.ToolType = aspect.ToolTypeMCP
+			wrapOpts.TargetId = infos[i].Name
 			if invokable, ok := t.(tool.InvokableTool); ok {
-				wrapOpts := opts.WrapOptions
-				wrapOpts.Name = infos[i].Name
-				wrapOpts.ToolType = aspect.ToolTypeMCP
-				wrapOpts.TargetId = infos[i].Name
 				tools[i] = NewVisualToolWrapper(invokable, wrapOpts)
+			} else if enhanced, ok := t.(tool.EnhancedInvokableTool); ok {
+				tools[i] = NewVisualEnhancedToolWrapper(enhanced, wrapOpts)
 			}
 		}
 	}
 
-	return tools, infos, nil
+	return toolGroup{tools: tools, infos: infos}, nil
 }
 
 // createSelfMCPTools 进程内模式：通过 RuleConfig UDF 获取 MCPToolProvider。
@@ -418,11 +484,6 @@ func createSelfMCPTools(ruleConfig types.Config, toolNames []string) ([]tool.Bas
 	return mcpadapter.CreateToolsFromProvider(provider, toolNames)
 }
 
-// createRemoteMCPTools 远程模式：通过 MCP 协议的 tools/list 自动发现工具。
-func createRemoteMCPTools(server string, toolNames []string) ([]tool.BaseTool, error) {
-	return mcpadapter.CreateToolsFromRemote(server, toolNames)
-}
-
 // CreateTool 创建单个工具
 func CreateTool(toolConfig config.Tool, opts ToolOptions) (tool.BaseTool, *schema.ToolInfo, aitool.DynamicSkillLister, error) {
 	var toolInstance tool.BaseTool
@@ -839,3 +839,57 @@ func TestCreateTools_MCPType_Self_ToolExecution(t *testing.T) {
 	assert.Equal(t, 1, len(provider.calls))
 	assert.Equal(t, "save_rule_chain", provider.calls[0].name)
 }
+
+// mockEnhancedTool 返回多模态结果的测试工具
+type mockEnhancedTool struct {
+	runFunc func(ctx context.Context, toolArgument *schema.ToolArgument, opts ...tool.Option) (*schema.ToolResult, error)
+}
+
+func (m *mockEnhancedTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "screenshot", Desc: "Take a screenshot"}, nil
+}
+
+func (m *mockEnhancedTool) InvokableRun(ctx context.Context, toolArgument *schema.ToolArgument, opts ...tool.Option) (*schema.ToolResult, error) {
+	return m.runFunc(ctx, toolArgument, opts...)
+}
+
+// TestVisualEnhancedToolWrapper_KeepsMediaParts 测试多模态包装器保留图片 part，文本结果带图片占位
+func TestVisualEnhancedToolWrapper_KeepsMediaParts(t *testing.T) {
+	data := "iVBORw0KGgo="
+	var gotArgs string
+	wrapper := NewVisualEnhancedToolWrapper(&mockEnhancedTool{
+		runFunc: func(ctx context.Context, toolArgument *schema.ToolArgument, opts ...tool.Option) (*schema.ToolResult, error) {
+			gotArgs = toolArgument.Text
+			return &schema.ToolResult{Parts: []schema.ToolOutputPart{
+				{Type: schema.ToolPartTypeText, Text: "page loaded"},
+				{Type: schema.ToolPartTypeImage, Image: &schema.ToolOutputImage{MessagePartCommon: schema.MessagePartCommon{Base64Data: &data, MIMEType: "image/png"}}},
+			}}, nil
+		},
+	}, ToolWrapOptions{Name: "screenshot"})
+
+	info, err := wrapper.Info(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "screenshot", info.Name)
+
+	result, err := wrapper.InvokableRun(context.Background(), &schema.ToolArgument{Text: `{"url":"https://example.com"}`})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"url":"https://example.com"}`, gotArgs)
+	assert.Equal(t, 2, len(result.Parts))
+	assert.Equal(t, "page loaded\n[image: image/png]", result.Parts[0].Text)
+	assert.Equal(t, schema.ToolPartTypeImage, result.Parts[1].Type)
+	assert.Equal(t, data, *result.Parts[1].Image.Base64Data)
+}
+
+// TestVisualEnhancedToolWrapper_Error 测试多模态工具出错时只返回错误文本
+func TestVisualEnhancedToolWrapper_Error(t *testing.T) {
+	wrapper := NewVisualEnhancedToolWrapper(&mockEnhancedTool{
+		runFunc: func(ctx context.Context, toolArgument *schema.ToolArgument, opts ...tool.Option) (*schema.ToolResult, error) {
+			return nil, context.DeadlineExceeded
+		},
+	}, ToolWrapOptions{Name: "screenshot"})
+
+	result, err := wrapper.InvokableRun(context.Background(), &schema.ToolArgument{Text: `{"url":"https://example.com"}`})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(result.Parts))
+	assert.True(t, strings.HasPrefix(result.Parts[0].Text, "Tool execution failed"))
+}
@@ -20,10 +20,12 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cloudwego/eino/adk"
 	"github.com/cloudwego/eino/components/model"
-	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/flow/agent/react"
 	"github.com/cloudwego/eino/schema"
 	"github.com/rulego/rulego"
@@ -31,6 +33,7 @@ import (
 	"github.com/rulego/rulego-components-ai/config"
 	aitool "github.com/rulego/rulego-components-ai/tool"
 	"github.com/rulego/rulego-components-ai/tool/common"
+	mcpadapter "github.com/rulego/rulego-components-ai/tool/mcp"
 	"github.com/rulego/rulego-components-ai/utils/token"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/components/base"
@@ -42,6 +45,9 @@ func init() {
 	_ = rulego.Registry.Register(&ReactAgentNode{})
 }
 
+// refreshToolsTimeout 重新获取远程 MCP 工具列表的超时时间
+const refreshToolsTimeout = 30 * time.Second
+
 // ReactAgentNodeConfig extends ChatAgentConfig
 type ReactAgentNodeConfig struct {
 	ChatAgentConfig `json:",squash"`
@@ -64,6 +70,13 @@ type ReactAgentNode struct {
 	metricsCollector     *token.MetricsCollector
 	ruleEnginePool       types.RuleEnginePool
 	chatModel            model.ToolCallingChatModel // 保存 chatModel 引用，用于动态模型切换
+	subAgents            *subAgentFactory           // 配置了技能工具时非空，供 fork 模式派生子智能体
+	toolGroups           []toolGroup                // 按工具配置分组的工具，远程 MCP 工具随服务器的工具列表更新
+	toolOptions          ToolOptions
+	skillLister          aitool.DynamicSkillLister
+	maxStep              int
+	toolsChanged         atomic.Bool  // 远程 MCP 工具列表已变化，下次执行前重建 agent
+	mu                   sync.RWMutex // 保护 agent、tools、subAgents 与 toolGroups
 }
 
 // Type 返回组件类型
@@ -153,31 +166,68 @@ func (x *ReactAgentNode) Init(ruleConfig types.Config, configuration types.Confi
 
 	// 5. 初始化切面执行器（必须在 createTools 之前）
 	x.aspectExecutor = NewAgentAspectExecutor(ruleConfig.Logger)
+	if x.Config.SessionCompaction {
+		x.aspectExecutor.EnableSessionCompaction(chatModel)
+	}
 	x.tokenTracker = token.NewTokenTracker()
 	x.metricsCollector = token.NewMetricsCollector()
 
 	// 6. 创建工具（skillLister 在包装前提取，避免 VisualToolWrapper 遮蔽接口）
-	tools, toolInfoList, skillLister, err := x.createTools(ruleConfig, chatModel)
+	x.toolOptions = x.newToolOptions(ruleConfig)
+	toolGroups, skillLister, err := createToolGroups(x.Config.Tools, x.toolOptions)
 	if err != nil {
 		return fmt.Errorf("failed to create tools: %v", err)
 	}
+	x.toolGroups = toolGroups
+	x.skillLister = skillLister
 
-	// 7. 构建技能列表的 MessageModifier
-	var messageModifier func(ctx context.Context, input []*schema.Message) []*schema.Message
-	if skillLister != nil {
-		messageModifier = BuildSkillModifier(skillLister)
+	x.maxStep = x.Config.MaxStep
+	if x.maxStep <= 0 {
+		x.maxStep = DefaultMaxStep
+	}
+
+	// 7. 创建 React Agent
+	if err := x.buildAgent(); err != nil {
+		closeToolGroups(toolGroups)
+		return err
 	}
-	// 8. 创建 React Agent
-	maxStep := x.Config.MaxStep
-	if maxStep <= 0 {
-		maxStep = DefaultMaxStep
+
+	// 8. 远程 MCP 服务器的工具列表变化时标记，在下次执行前换用新的工具
+	for _, group := range toolGroups {
+		if group.set != nil {
+			group.set.OnChange(func() {
+				x.toolsChanged.Store(true)
+			})
+		}
+	}
+
+	return nil
+}
+
+// buildAgent 用当前的工具创建 React Agent，绑定到模型的工具信息随之更新
+func (x *ReactAgentNode) buildAgent() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.buildAgentLocked()
+}
+
+// buildAgentLocked 同 buildAgent，调用方需持有 x.mu
+func (x *ReactAgentNode) buildAgentLocked() error {
+	tools, toolInfoList := flattenToolGroups(x.toolGroups)
+
+	// 构建技能列表的 MessageModifier；同时记录对话并准备子智能体，供 fork 模式的技能派生
+	var messageModifier func(ctx context.Context, input []*schema.Message) []*schema.Message
+	var subAgents *subAgentFactory
+	if x.skillLister != nil {
+		messageModifier = trackSubAgentMessages(BuildSkillModifier(x.skillLister))
+		subAgents = newSubAgentFactory(x.chatModel, tools, toolInfoList, x.maxStep, x.logger)
 	}
 
 	checkMode := resolveStreamToolCallCheck(x.Config.StreamToolCallCheck, len(tools) > 0)
-	agent, err := CreateReactAgent(context.Background(), chatModel, AgentOptions{
-		MaxStep:             maxStep,
+	agent, err := CreateReactAgent(context.Background(), x.chatModel, AgentOptions{
+		MaxStep:             x.maxStep,
 		ToolsConfig:         buildToolsConfig(tools),
-		Logger:              ruleConfig.Logger,
+		Logger:              x.logger,
 		MessageModifier:     messageModifier,
 		StreamToolCallCheck: checkMode,
 	})
@@ -187,10 +237,65 @@ func (x *ReactAgentNode) Init(ruleConfig types.Config, configuration types.Confi
 
 	x.agent = agent
 	x.tools = toolInfoList
-
+	x.subAgents = subAgents
 	return nil
 }
 
+// refreshTools 重新获取远程 MCP 工具集的工具列表并重建 React Agent。
+// 执行中的请求继续使用旧的 agent，新请求使用重建后的 agent。
+// 工具列表在锁外获取，避免网络请求阻塞其他请求获取 agent；
+// 工具替换与重建在同一把锁内完成，避免并发刷新时较旧的工具覆盖较新的 agent。
+func (x *ReactAgentNode) refreshTools() error {
+	x.mu.RLock()
+	sets := make([]*mcpadapter.RemoteToolSet, 0, len(x.toolGroups))
+	for _, group := range x.toolGroups {
+		if group.set != nil {
+			sets = append(sets, group.set)
+		}
+	}
+	x.mu.RUnlock()
+	ctx, cancel := context.WithTimeout(context.Background(), refreshToolsTimeout)
+	defer cancel()
+	for _, set := range sets {
+		if _, err := set.Refresh(ctx); err != nil {
+			return err
+		}
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	groups := make([]toolGroup, len(x.toolGroups))
+	for i, group := range x.toolGroups {
+		refreshed, err := refreshToolGroup(group, x.toolOptions)
+		if err != nil {
+			return err
+		}
+		groups[i] = refreshed
+	}
+	x.toolGroups = groups
+	return x.buildAgentLocked()
+}
+
+// refreshToolsIfChanged 远程 MCP 工具列表变化后换用新的工具，失败时继续使用当前工具并在下次执行前重试
+func (x *ReactAgentNode) refreshToolsIfChanged() {
+	if !x.toolsChanged.Swap(false) {
+		return
+	}
+	if err := x.refreshTools(); err != nil {
+		x.toolsChanged.Store(true)
+		if x.logger != nil {
+			x.logger.Warnf("ReactAgentNode: refresh MCP tools failed: %v", err)
+		}
+	}
+}
+
+// currentAgent 返回当前的 React Agent 及其子智能体工厂
+func (x *ReactAgentNode) currentAgent() (*react.Agent, *subAgentFactory) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.agent, x.subAgents
+}
+
 // initTemplates 初始化模板
 func (x *ReactAgentNode) initTemplates() error {
 	if x.Config.SystemPrompt != "" {
@@ -234,9 +339,9 @@ func (x *ReactAgentNode) initNodeInfo(configuration types.Configuration) {
 	}
 }
 
-// createTools 创建工具
-func (x *ReactAgentNode) createTools(ruleConfig types.Config, chatModel interface{}) ([]tool.BaseTool, []*schema.ToolInfo, aitool.DynamicSkillLister, error) {
-	return CreateTools(x.Config.Tools, ToolOptions{
+// newToolOptions 创建工具选项
+func (x *ReactAgentNode) newToolOptions(ruleConfig types.Config) ToolOptions {
+	return ToolOptions{
 		RuleConfig:     ruleConfig,
 		RuleEnginePool: x.ruleEnginePool,
 		WrapVisual:     true,
@@ -248,13 +353,19 @@ func (x *ReactAgentNode) createTools(ruleConfig types.Config, chatModel interfac
 			MaxToolOutputLength: x.Config.MaxToolOutputLength,
 			Logger:              x.logger,
 			MetricsCollector:    x.metricsCollector,
+			Model:               x.Config.Model,
 		},
 		Logger: x.logger,
-	})
+		Vision: config.SupportsVision(x.Config.Model),
+	}
 }
 
 // OnMsg 处理消息
 func (x *ReactAgentNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
+	// 0. 远程 MCP 工具列表已变化：换用新的工具，失败时继续使用当前工具
+	x.refreshToolsIfChanged()
+	agent, subAgents := x.currentAgent()
+
 	// 1. 转换输入
 	adkInput, err := ConvertRuleMsgToAgentInput(ctx, msg, x.systemPromptTemplate, x.hasVar, x.Config.SystemPrompt, x.presetMessagesTmpls, x.Config.Model, x.id, x.logger)
 	if err != nil {
@@ -263,7 +374,7 @@ func (x *ReactAgentNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 	}
 
 	// 2. 构建执行上下文
-	runCtx := x.buildRunContext(ctx, msg)
+	runCtx := x.buildRunContext(ctx, msg, subAgents)
 
 	// 3. 获取规则链 ID
 	chainId := ""
@@ -284,17 +395,18 @@ func (x *ReactAgentNode) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 		AgentName:  x.name,
 		Msg:        msg,
 		SessionKey: msg.Metadata.GetValue("sessionKey"),
+		Model:      x.Config.Model,
 	}
 
 	if x.isStreamMode(msg) {
-		x.executeStream(ctx, msg, runCtx, opts, agentInput, adkInput)
+		x.executeStream(ctx, msg, agent, runCtx, opts, agentInput, adkInput)
 	} else {
-		x.executeSync(ctx, msg, runCtx, opts, agentInput, adkInput)
+		x.executeSync(ctx, msg, agent, runCtx, opts, agentInput, adkInput)
 	}
 }
 
 // buildRunContext 构建执行上下文
-func (x *ReactAgentNode) buildRunContext(ctx types.RuleContext, msg types.RuleMsg) context.Context {
+func (x *ReactAgentNode) buildRunContext(ctx types.RuleContext, msg types.RuleMsg, subAgents *subAgentFactory) context.Context {
 	runCtx := context.WithValue(ctx.GetContext(), config.ShareRuleContextKey, ctx)
 	runCtx = context.WithValue(runCtx, config.KeyRuleConfig, ctx.Config())
 
@@ -318,6 +430,11 @@ func (x *ReactAgentNode) buildRunContext(ctx types.RuleContext, msg types.RuleMs
 	// 注入 doom-loop 检测器（agent 级共享，跨工具抓死循环）
 	runCtx = WithDoomLoopDetector(runCtx, NewDoomLoopDetector())
 
+	// 注入子智能体运行器（每次执行独立记录对话）
+	if subAgents != nil {
+		runCtx = aitool.WithSubAgentRunner(runCtx, subAgents.newRunner())
+	}
+
 	// 获取规则链 ID
 	chainId := ""
 	if ctx.RuleChain() != nil {
@@ -387,12 +504,12 @@ func (x *ReactAgentNode) isStreamMode(msg types.RuleMsg) bool {
 }
 
 // executeSync 同步执行
-func (x *ReactAgentNode) executeSync(ctx types.RuleContext, msg types.RuleMsg, runCtx context.Context, opts ExecuteOptions, agentInput *aspect.AgentInput, adkInput *adk.AgentInput) {
+func (x *ReactAgentNode) executeSync(ctx types.RuleContext, msg types.RuleMsg, agent *react.Agent, runCtx context.Context, opts ExecuteOptions, agentInput *aspect.AgentInput, adkInput *adk.AgentInput) {
 	output, err := x.aspectExecutor.ExecuteSync(runCtx, opts, agentInput, adkInput.Messages, func(ctx context.Context, msgs []*schema.Message) (*schema.Message, error) {
 		// 注入 session_model 到 context（用于动态模型切换）
 		ctx = InjectSessionModelToContext(ctx, agentInput.Metadata)
 		ctx = InjectSessionExtraFieldsToContext(ctx, agentInput.Metadata)
-		return x.agent.Generate(ctx, msgs)
+		return agent.Generate(ctx, msgs)
 	})
 
 	if err != nil {
@@ -407,15 +524,13 @@ func (x *ReactAgentNode) executeSync(ctx types.RuleContext, msg types.RuleMsg, r
 	// 此处（注入后）解析响应模型，确保回显会话级切换后的模型而非节点默认模型
 	responseModel := resolveResponseModel(x.Config.Model, agentInput.Metadata)
 	BuildTokenMetadata(msg, output.TokenUsage, responseModel)
-	// 如果 Around 切面拦截了请求（如 CommandAspect），传递切面输出的元数据
-	if output.SkippedAI {
-		transferOutputMetadata(msg, output)
-	}
+	// 传递切面输出的元数据（如 CommandAspect 的 _isCommandResponse、费用与审批信息）
+	transferOutputMetadata(msg, output)
 	ctx.TellSuccess(msg)
 }
 
 // executeStream 流式执行
-func (x *ReactAgentNode) executeStream(ctx types.RuleContext, msg types.RuleMsg, runCtx context.Context, opts ExecuteOptions, agentInput *aspect.AgentInput, adkInput *adk.AgentInput) {
+func (x *ReactAgentNode) executeStream(ctx types.RuleContext, msg types.RuleMsg, agent *react.Agent, runCtx context.Context, opts ExecuteOptions, agentInput *aspect.AgentInput, adkInput *adk.AgentInput) {
 	// 会话级模型需在 session_aspect 注入 session_model 后才能解析（注入发生在下方 ExecuteStream
 	// 内部的 Before 阶段）。用闭包延迟解析，每次取值读最新的 agentInput.Metadata，
 	// 确保 SSE 回显会话级切换后的模型而非节点默认模型。
@@ -446,7 +561,7 @@ func (x *ReactAgentNode) executeStream(ctx types.RuleContext, msg types.RuleMsg,
 			// 注入 session_model 到 context（用于动态模型切换）
 			ctx = InjectSessionModelToContext(ctx, agentInput.Metadata)
 			ctx = InjectSessionExtraFieldsToContext(ctx, agentInput.Metadata)
-			return x.agent.Stream(ctx, msgs)
+			return agent.Stream(ctx, msgs)
 		},
 		func(content, reasoning string, isFirst bool) {
 			chunkMsg := msg.Copy()
@@ -513,12 +628,17 @@ func (x *ReactAgentNode) executeStream(ctx types.RuleContext, msg types.RuleMsg,
 	BuildStreamEndMetadata(finalMsg)
 	finalMsg.Metadata.PutValue(config.KeyFullContent, config.ValueTrue)
 	BuildTokenMetadata(finalMsg, output.TokenUsage, getResponseModel())
+	transferOutputMetadata(finalMsg, output)
 	ctx.TellNext(finalMsg, types.Success)
 }
 
 // Destroy 销毁节点
 func (x *ReactAgentNode) Destroy() {
-	// Agent 不需要显式清理
+	// 关闭远程 MCP 连接，Agent 本身不需要显式清理
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	closeToolGroups(x.toolGroups)
+	x.toolGroups = nil
 }
 
 // skillPromptMarker 将原始 system prompt 与技能提示词分隔开。
@@ -587,7 +707,7 @@ func resolveResponseModel(defaultModel string, metadata map[string]string) strin
 }
 
 // transferOutputMetadata 将切面输出的元数据传递到消息元数据
-// 用于传递 CommandAspect 等切面设置的元数据（如 _isCommandResponse）
+// 用于传递 CommandAspect 等切面设置的元数据（如 _isCommandResponse）及费用、上下文预算、审批等信息
 func transferOutputMetadata(msg types.RuleMsg, output *aspect.AgentOutput) {
 	for k, v := range output.Metadata {
 		switch sv := v.(type) {
@@ -0,0 +1,211 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+	"github.com/rulego/rulego/test/assert"
+)
+
+// toolRecordingProvider openai 兼容的 mock provider，记录每次请求绑定的工具名，直接回复 "hi"
+type toolRecordingProvider struct {
+	*httptest.Server
+	mu    sync.Mutex
+	tools [][]string
+}
+
+func newToolRecordingProvider(t *testing.T) *toolRecordingProvider {
+	p := &toolRecordingProvider{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			Model  string `json:"model"`
+			Stream bool   `json:"stream"`
+			Tools  []struct {
+				Function struct {
+					Name string `json:"name"`
+				} `json:"function"`
+			} `json:"tools"`
+		}
+		_ = json.Unmarshal(body, &req)
+		names := []string{}
+		for _, tl := range req.Tools {
+			names = append(names, tl.Function.Name)
+		}
+		sort.Strings(names)
+		p.mu.Lock()
+		p.tools = append(p.tools, names)
+		p.mu.Unlock()
+		if req.Stream {
+			writeMockSSE(w, req.Model)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id": "1", "object": "chat.completion", "model": req.Model,
+			"choices": []map[string]any{{"index": 0, "message": map[string]any{"role": "assistant", "content": "hi"}, "finish_reason": "stop"}},
+		})
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// lastTools 返回最近一次请求绑定的工具名
+func (p *toolRecordingProvider) lastTools() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.tools) == 0 {
+		return nil
+	}
+	return p.tools[len(p.tools)-1]
+}
+
+// startRemoteToolsNode 启动提供 search 工具的 MCP 服务器，并初始化使用其工具、请求 llmURL 的节点，
+// 调用返回的 fail 函数后 MCP 服务器拒绝所有请求
+func startRemoteToolsNode(t *testing.T, llmURL string) (*server.MCPServer, *ReactAgentNode, func()) {
+	mcpServer := server.NewMCPServer("test-server", "1.0.0", server.WithToolCapabilities(true))
+	mcpServer.AddTool(mcp.NewTool("search", mcp.WithDescription("Searches")), okToolHandler)
+	httpServer := server.NewStreamableHTTPServer(mcpServer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	var failing atomic.Bool
+	go func() {
+		_ = http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if failing.Load() {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			httpServer.ServeHTTP(w, r)
+		}))
+	}()
+	t.Cleanup(func() {
+		_ = httpServer.Shutdown(context.Background())
+		_ = listener.Close()
+	})
+
+	node := &ReactAgentNode{}
+	err = node.Init(rulego.NewConfig(), types.Configuration{
+		"model": "gpt-4o",
+		"url":   llmURL,
+		"key":   "test-key",
+		"tools": []interface{}{
+			map[string]interface{}{
+				"type":   "mcp",
+				"config": map[string]interface{}{"server": "http://" + listener.Addr().String() + "/mcp"},
+			},
+		},
+	})
+	assert.Nil(t, err)
+	t.Cleanup(node.Destroy)
+	return mcpServer, node, func() { failing.Store(true) }
+}
+
+// okToolHandler 测试 MCP 工具的处理函数
+func okToolHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return mcp.NewToolResultText("ok"), nil
+}
+
+// TestReactAgentNode_RefreshRemoteMCPTools 测试远程 MCP 工具列表变化后，节点无需重新初始化即换用新的工具
+func TestReactAgentNode_RefreshRemoteMCPTools(t *testing.T) {
+	mcpServer, node, _ := startRemoteToolsNode(t, "http://127.0.0.1:1/v1")
+
+	toolNames := func() []string {
+		node.mu.RLock()
+		defer node.mu.RUnlock()
+		var names []string
+		for _, info := range node.tools {
+			names = append(names, info.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"search"}, toolNames())
+	oldAgent, _ := node.currentAgent()
+
+	mcpServer.AddTool(mcp.NewTool("fetch", mcp.WithDescription("Fetches")), okToolHandler)
+	deadline := time.Now().Add(5 * time.Second)
+	for !node.toolsChanged.Load() && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.True(t, node.toolsChanged.Load())
+
+	assert.Nil(t, node.refreshTools())
+	assert.Equal(t, []string{"fetch", "search"}, toolNames())
+	newAgent, _ := node.currentAgent()
+	assert.True(t, oldAgent != newAgent)
+
+	// 并发刷新与工具变化交错时，最终的工具与服务器一致
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = node.refreshTools()
+		}()
+	}
+	mcpServer.AddTool(mcp.NewTool("read", mcp.WithDescription("Reads")), okToolHandler)
+	wg.Wait()
+	deadline = time.Now().Add(5 * time.Second)
+	for len(toolNames()) != 3 && time.Now().Before(deadline) {
+		assert.Nil(t, node.refreshTools())
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Equal(t, []string{"fetch", "read", "search"}, toolNames())
+}
+
+// TestReactAgentNode_RefreshToolsWithSessionModel 会话切换模型后刷新工具，重建的模型绑定新的工具列表
+func TestReactAgentNode_RefreshToolsWithSessionModel(t *testing.T) {
+	provider := newToolRecordingProvider(t)
+	mcpServer, node, _ := startRemoteToolsNode(t, provider.URL)
+
+	ctx := ContextWithSessionModel(context.Background(), "gpt-4o-mini")
+	generate := func() {
+		agent, _ := node.currentAgent()
+		_, err := agent.Generate(ctx, []*schema.Message{schema.UserMessage("hi")})
+		assert.Nil(t, err)
+	}
+	generate()
+	assert.Equal(t, []string{"search"}, provider.lastTools())
+
+	mcpServer.AddTool(mcp.NewTool("fetch", mcp.WithDescription("Fetches")), okToolHandler)
+	mcpServer.DeleteTools("search")
+	onlyFetch := func() bool {
+		node.mu.RLock()
+		defer node.mu.RUnlock()
+		return len(node.tools) == 1 && node.tools[0].Name == "fetch"
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !onlyFetch() && time.Now().Before(deadline) {
+		assert.Nil(t, node.refreshTools())
+		time.Sleep(20 * time.Millisecond)
+	}
+	generate()
+	assert.Equal(t, []string{"fetch"}, provider.lastTools())
+}
+
+// TestReactAgentNode_RefreshFailureKeepsPending 重新获取工具失败时保留变化标记，下次执行前重试
+func TestReactAgentNode_RefreshFailureKeepsPending(t *testing.T) {
+	_, node, fail := startRemoteToolsNode(t, "http://127.0.0.1:1/v1")
+	fail()
+
+	node.toolsChanged.Store(true)
+	node.refreshToolsIfChanged()
+	assert.True(t, node.toolsChanged.Load())
+	node.mu.RLock()
+	defer node.mu.RUnlock()
+	assert.Equal(t, 1, len(node.tools))
+	assert.Equal(t, "search", node.tools[0].Name)
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego-components-ai/aspect"
+	"github.com/rulego/rulego-components-ai/aspect/builtin"
+	"github.com/rulego/rulego-components-ai/session"
+	"github.com/rulego/rulego/api/types"
+	rulegotest "github.com/rulego/rulego/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runCompactionNode 注册使用 mgr 的会话切面，初始化节点并执行 rounds 轮对话，返回会话
+func runCompactionNode(t *testing.T, mgr *session.Manager, sessionCompaction bool, rounds int) *session.Session {
+	provider := newToolRecordingProvider(t)
+	aspect.RegisterAspect("test-session-compaction", builtin.NewSessionAspect(mgr, session.ScopePerPeer, nil))
+	t.Cleanup(func() { aspect.UnregisterAspect("test-session-compaction") })
+
+	node := &ReactAgentNode{}
+	require.NoError(t, node.Init(rulego.NewConfig(), types.Configuration{
+		"model":             "gpt-4o",
+		"url":               provider.URL,
+		"key":               "test-key",
+		"sessionCompaction": sessionCompaction,
+	}))
+	t.Cleanup(node.Destroy)
+
+	for i := 0; i < rounds; i++ {
+		done := make(chan error, 1)
+		ctx := rulegotest.NewRuleContext(types.NewConfig(), func(msg types.RuleMsg, relationType string, err error) {
+			done <- err
+		})
+		metadata := types.NewMetadata()
+		metadata.PutValue(aspect.MetaLoadHistory, "true")
+		node.OnMsg(ctx, types.NewMsg(0, "TEST", types.TEXT, metadata, "hello"))
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("timeout waiting for agent response")
+		}
+	}
+
+	sessions, err := mgr.List(context.Background(), &session.SessionQuery{})
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	return sessions[0]
+}
+
+func newCompactionSessionManager() *session.Manager {
+	cfg := session.DefaultSessionConfig()
+	cfg.CompactionConfig = &session.CompactionConfig{
+		Enabled:              true,
+		MaxTokenCount:        100,
+		TriggerThreshold:     0.01,
+		KeepRecentCount:      2,
+		MinMessagesToCompact: 4,
+	}
+	return session.NewManager(session.NewMemoryStorage(), cfg)
+}
+
+// TestReactAgentNode_SessionCompaction 开启 sessionCompaction 后会话切面使用节点模型压缩会话
+func TestReactAgentNode_SessionCompaction(t *testing.T) {
+	sess := runCompactionNode(t, newCompactionSessionManager(), true, 3)
+	assert.Equal(t, "hi", sess.CompactedSummary)
+	compacted := 0
+	for _, msg := range sess.Messages {
+		if msg.IsCompacted {
+			compacted++
+		}
+	}
+	assert.Equal(t, 2, compacted)
+}
+
+// TestReactAgentNode_SessionCompactionDisabled 未开启时会话不压缩
+func TestReactAgentNode_SessionCompactionDisabled(t *testing.T) {
+	sess := runCompactionNode(t, newCompactionSessionManager(), false, 3)
+	assert.Empty(t, sess.CompactedSummary)
+	for _, msg := range sess.Messages {
+		assert.False(t, msg.IsCompacted)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/rulego/rulego-components-ai/aspect"
+	aitool "github.com/rulego/rulego-components-ai/tool"
+	"github.com/rulego/rulego/api/types"
+)
+
+// subAgentFactory 保存派生子智能体所需的父智能体资源（模型、已包装的工具）。
+// 父智能体每次执行通过 newRunner 创建一个 subAgentRunner 注入 ctx（见 buildRunContext），
+// skill 等工具据此在 fork 模式下派生子智能体。
+type subAgentFactory struct {
+	chatModel model.ToolCallingChatModel
+	tools     []tool.BaseTool
+	names     []string
+	maxStep   int
+	logger    types.Logger
+}
+
+// newSubAgentFactory tools 与 infos 一一对应（CreateTools 的返回值）
+func newSubAgentFactory(chatModel model.ToolCallingChatModel, tools []tool.BaseTool, infos []*schema.ToolInfo, maxStep int, logger types.Logger) *subAgentFactory {
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name
+	}
+	return &subAgentFactory{chatModel: chatModel, tools: tools, names: names, maxStep: maxStep, logger: logger}
+}
+
+func (f *subAgentFactory) newRunner() *subAgentRunner {
+	return &subAgentRunner{factory: f}
+}
+
+// selectTools 按允许/排除列表筛选工具；allowed 为 nil 表示全部，未知的工具名忽略
+func (f *subAgentFactory) selectTools(allowed, exclude []string) []tool.BaseTool {
+	excluded := make(map[string]bool, len(exclude))
+	for _, name := range exclude {
+		excluded[name] = true
+	}
+	var allow map[string]bool
+	if allowed != nil {
+		allow = make(map[string]bool, len(allowed))
+		for _, name := range allowed {
+			allow[name] = true
+		}
+	}
+	var selected []tool.BaseTool
+	for i, t := range f.tools {
+		if excluded[f.names[i]] || (allow != nil && !allow[f.names[i]]) {
+			continue
+		}
+		selected = append(selected, t)
+	}
+	return selected
+}
+
+// subAgentRunner 单次执行内的子智能体运行器，实现 aitool.SubAgentRunner。
+// 父智能体每次模型调用前由 trackSubAgentMessages 记录最新对话，供 fork_with_context 携带上下文。
+type subAgentRunner struct {
+	factory  *subAgentFactory
+	mu       sync.Mutex
+	messages []*schema.Message
+}
+
+// track 记录父智能体当前对话（浅拷贝切片）
+func (r *subAgentRunner) track(msgs []*schema.Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages[:0:0], msgs...)
+}
+
+// RecentMessages 返回最近的至多 limit 条非系统消息；开头的孤立工具结果（其 tool_call 已被截掉）一并去掉
+func (r *subAgentRunner) RecentMessages(limit int) []*schema.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var msgs []*schema.Message
+	for _, m := range r.messages {
+		if m.Role != schema.System {
+			msgs = append(msgs, m)
+		}
+	}
+	if limit > 0 && len(msgs) > limit {
+		msgs = msgs[len(msgs)-limit:]
+	}
+	for len(msgs) > 0 && msgs[0].Role == schema.Tool {
+		msgs = msgs[1:]
+	}
+	return msgs
+}
+
+// RunSubAgent 以父智能体的模型与筛选后的工具运行一个独立的 ReAct 子智能体，返回其最终回复
+func (r *subAgentRunner) RunSubAgent(ctx context.Context, req aitool.SubAgentRequest) (string, error) {
+	f := r.factory
+	tools := f.selectTools(req.AllowedTools, req.ExcludeTools)
+	agent, err := CreateReactAgent(ctx, f.chatModel, AgentOptions{
+		Name:                req.Name,
+		MaxStep:             f.maxStep,
+		ToolsConfig:         buildToolsConfig(tools),
+		Logger:              f.logger,
+		StreamToolCallCheck: resolveStreamToolCallCheck("", len(tools) > 0),
+	})
+	if err != nil {
+		return "", fmt.Errorf("create sub-agent: %w", err)
+	}
+	if f.logger != nil {
+		f.logger.Debugf("sub-agent %s started with %d tool(s), %d message(s)", req.Name, len(tools), len(req.Messages))
+	}
+
+	msgs := make([]*schema.Message, 0, len(req.Messages)+1)
+	if req.SystemPrompt != "" {
+		msgs = append(msgs, schema.SystemMessage(req.SystemPrompt))
+	}
+	msgs = append(msgs, req.Messages...)
+
+	// 子智能体不继承父智能体的运行器：其工具调用不应再读取或派生自父对话
+	ctx = aitool.WithSubAgentRunner(ctx, nil)
+	// 子智能体使用执行点的副本：其模型调用前的切面会写入执行点元数据，
+	// 而父智能体的其他工具调用（如审批切面）与其他 fork 正并发读取父执行点
+	if point := aspect.GetAgentPoint(ctx); point != nil {
+		ctx = aspect.WithAgentPoint(ctx, point.Clone())
+	}
+	out, err := agent.Generate(ctx, msgs)
+	if err != nil {
+		return "", err
+	}
+	return out.Content, nil
+}
+
+// trackSubAgentMessages 包装 MessageModifier：模型调用前把完整对话记录到 ctx 中的 subAgentRunner
+func trackSubAgentMessages(next func(ctx context.Context, input []*schema.Message) []*schema.Message) func(ctx context.Context, input []*schema.Message) []*schema.Message {
+	return func(ctx context.Context, input []*schema.Message) []*schema.Message {
+		if r, ok := aitool.SubAgentRunnerFromCtx(ctx).(*subAgentRunner); ok {
+			r.track(input)
+		}
+		if next == nil {
+			return input
+		}
+		return next(ctx, input)
+	}
+}
@@ -0,0 +1,277 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/rulego/rulego-components-ai/aspect"
+	"github.com/rulego/rulego-components-ai/config"
+	aitool "github.com/rulego/rulego-components-ai/tool"
+	"github.com/rulego/rulego/api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// namedTool 只有名称的占位工具，调用返回空结果
+type namedTool struct{ name string }
+
+func (n namedTool) Info(context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: n.name}, nil
+}
+
+func (n namedTool) InvokableRun(context.Context, string, ...tool.Option) (string, error) {
+	return "", nil
+}
+
+func newTestSubAgentFactory(t *testing.T, m *recordingModel, tools ...tool.BaseTool) *subAgentFactory {
+	t.Helper()
+	var infos []*schema.ToolInfo
+	for _, tl := range tools {
+		info, err := tl.Info(context.Background())
+		require.NoError(t, err)
+		infos = append(infos, info)
+	}
+	return newSubAgentFactory(m, tools, infos, 10, nil)
+}
+
+func TestSubAgentSelectTools(t *testing.T) {
+	f := newTestSubAgentFactory(t, &recordingModel{}, namedTool{"read"}, namedTool{"bash"}, namedTool{"skill"})
+	names := func(tools []tool.BaseTool) []string {
+		var out []string
+		for _, tl := range tools {
+			out = append(out, tl.(namedTool).name)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"read", "bash"}, names(f.selectTools(nil, []string{"skill"})))
+	assert.Equal(t, []string{"read"}, names(f.selectTools([]string{"read", "unknown"}, []string{"skill"})))
+	assert.Empty(t, f.selectTools([]string{}, nil))
+	assert.Empty(t, f.selectTools([]string{"skill"}, []string{"skill"}))
+}
+
+func TestSubAgentRunnerRecentMessages(t *testing.T) {
+	r := newTestSubAgentFactory(t, &recordingModel{}).newRunner()
+	call := &schema.Message{Role: schema.Assistant, ToolCalls: []schema.ToolCall{{ID: "1", Function: schema.FunctionCall{Name: "read"}}}}
+	r.track([]*schema.Message{
+		schema.SystemMessage("sys"),
+		schema.UserMessage("q1"),
+		call,
+		schema.ToolMessage("file", "1"),
+		schema.UserMessage("q2"),
+	})
+
+	all := r.RecentMessages(0)
+	require.Len(t, all, 4)
+	assert.Equal(t, "q1", all[0].Content)
+
+	// 截断后开头的孤立工具结果被去掉
+	recent := r.RecentMessages(2)
+	require.Len(t, recent, 1)
+	assert.Equal(t, "q2", recent[0].Content)
+}
+
+func TestTrackSubAgentMessages(t *testing.T) {
+	r := newTestSubAgentFactory(t, &recordingModel{}).newRunner()
+	modifier := trackSubAgentMessages(func(_ context.Context, input []*schema.Message) []*schema.Message {
+		return append([]*schema.Message{schema.SystemMessage("injected")}, input...)
+	})
+
+	input := []*schema.Message{schema.UserMessage("hello")}
+	out := modifier(aitool.WithSubAgentRunner(context.Background(), r), input)
+	assert.Len(t, out, 2)
+	require.Len(t, r.RecentMessages(0), 1)
+	assert.Equal(t, "hello", r.RecentMessages(0)[0].Content)
+
+	// 未注入运行器时透传
+	assert.Len(t, modifier(context.Background(), input), 2)
+}
+
+func TestSubAgentRunnerRunSubAgent(t *testing.T) {
+	m := &recordingModel{toolCallSeq: 1} // 第 1 次调用 echo，第 2 次返回 done
+	echo := &echoTool{}
+	f := newTestSubAgentFactory(t, m, echo, namedTool{"skill"})
+	parent := f.newRunner()
+	ctx := aitool.WithSubAgentRunner(context.Background(), parent)
+
+	answer, err := parent.RunSubAgent(ctx, aitool.SubAgentRequest{
+		Name:         "review",
+		SystemPrompt: "you are a sub-agent",
+		Messages:     []*schema.Message{schema.UserMessage("do it")},
+		AllowedTools: []string{"echo"},
+		ExcludeTools: []string{"skill"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "done", answer)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&echo.runs))
+
+	require.NotEmpty(t, m.received)
+	first := m.received[0]
+	require.Len(t, first, 2)
+	assert.Equal(t, schema.System, first[0].Role)
+	assert.Equal(t, "you are a sub-agent", first[0].Content)
+	assert.Equal(t, "do it", first[1].Content)
+}
+
+// forkingModel 父智能体首轮同时调用多个 fork 与 probe 工具，子智能体与之后的父智能体轮次直接回复
+type forkingModel struct {
+	forks, probes int
+}
+
+func (m *forkingModel) Generate(_ context.Context, msgs []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	if len(msgs) > 0 && msgs[0].Role == schema.System && msgs[0].Content == "sub-agent" {
+		return schema.AssistantMessage("sub done", nil), nil
+	}
+	if msgs[len(msgs)-1].Role == schema.Tool {
+		return schema.AssistantMessage("done", nil), nil
+	}
+	var calls []schema.ToolCall
+	for i := 0; i < m.forks+m.probes; i++ {
+		name := "probe"
+		if i < m.forks {
+			name = "fork"
+		}
+		calls = append(calls, schema.ToolCall{ID: fmt.Sprintf("call-%d", i), Type: "function", Function: schema.FunctionCall{Name: name, Arguments: "{}"}})
+	}
+	return schema.AssistantMessage("", calls), nil
+}
+
+func (m *forkingModel) Stream(context.Context, []*schema.Message, ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return nil, fmt.Errorf("stream not implemented in forkingModel")
+}
+
+func (m *forkingModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// forkTool 以 fork 模式运行子智能体
+type forkTool struct{}
+
+func (forkTool) Info(context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "fork", Desc: "fork a sub-agent"}, nil
+}
+
+func (forkTool) InvokableRun(ctx context.Context, _ string, _ ...tool.Option) (string, error) {
+	return aitool.SubAgentRunnerFromCtx(ctx).RunSubAgent(ctx, aitool.SubAgentRequest{
+		Name:         "fork",
+		SystemPrompt: "sub-agent",
+		Messages:     []*schema.Message{schema.UserMessage("do it")},
+		AllowedTools: []string{},
+	})
+}
+
+// probeTool 占位工具，其调用前切面读取执行点元数据
+type probeTool struct{}
+
+func (probeTool) Info(context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "probe", Desc: "probe"}, nil
+}
+
+func (probeTool) InvokableRun(context.Context, string, ...tool.Option) (string, error) {
+	return "ok", nil
+}
+
+// pointMetadataAspect 模型调用前写入执行点元数据（如上下文预算），工具调用前读取本次执行的执行点（如审批）
+type pointMetadataAspect struct {
+	mu     sync.Mutex
+	models []string
+}
+
+func (a *pointMetadataAspect) Order() int { return 1 }
+
+func (a *pointMetadataAspect) New() aspect.Aspect { return a }
+
+func (a *pointMetadataAspect) PointCut(context.Context, *aspect.AgentPoint) bool { return true }
+
+func (a *pointMetadataAspect) BeforeLLM(ctx context.Context, point *aspect.AgentPoint, messages []*schema.Message) ([]*schema.Message, error) {
+	point.Metadata[aspect.MetaContextPromptTokens] = fmt.Sprint(len(messages))
+	a.mu.Lock()
+	a.models = append(a.models, aspect.GetLLMModel(ctx))
+	a.mu.Unlock()
+	return messages, nil
+}
+
+func (a *pointMetadataAspect) BeforeToolCall(ctx context.Context, point *aspect.AgentPoint, call *aspect.ToolCallInfo) (*aspect.ToolCallInfo, error) {
+	if run := aspect.GetAgentPoint(ctx); run != nil {
+		_ = run.Metadata[aspect.MetaContextPromptTokens]
+		_ = run.Metadata[aspect.MetaSessionKey]
+	}
+	return call, nil
+}
+
+// TestSubAgentRunner_ForkAlongsideToolCalls 测试 fork 的子智能体与父智能体的其他工具调用并发执行时不共享执行点元数据（需 -race 验证）
+func TestSubAgentRunner_ForkAlongsideToolCalls(t *testing.T) {
+	executor := NewAgentAspectExecutor(NewTestLogger(t))
+	executor.manager = aspect.NewAspectManager()
+	probeAspect := &pointMetadataAspect{}
+	executor.manager.Register(probeAspect)
+
+	chatModel := WrapModelWithDynamicSupport(&forkingModel{forks: 3, probes: 3}, config.LLMConfig{Model: "default-model"}, ModelOptions{})
+	wrapOpts := ToolWrapOptions{AgentName: "parent", AspectManager: executor.manager}
+	var tools []tool.BaseTool
+	for _, tl := range []tool.InvokableTool{forkTool{}, probeTool{}} {
+		info, err := tl.Info(context.Background())
+		require.NoError(t, err)
+		opts := wrapOpts
+		opts.Name = info.Name
+		tools = append(tools, NewVisualToolWrapper(tl, opts))
+	}
+	f := newSubAgentFactory(chatModel, tools, []*schema.ToolInfo{{Name: "fork"}, {Name: "probe"}}, 10, nil)
+	agent, err := CreateReactAgent(context.Background(), chatModel, AgentOptions{MaxStep: 10, ToolsConfig: buildToolsConfig(tools)})
+	require.NoError(t, err)
+
+	opts := ExecuteOptions{
+		ChainId:   "test_chain",
+		AgentName: "parent",
+		Msg:       types.NewMsg(0, "TEST", types.JSON, types.NewMetadata(), ""),
+	}
+	ctx := aitool.WithSubAgentRunner(context.Background(), f.newRunner())
+	output, err := executor.ExecuteSync(ctx, opts, &aspect.AgentInput{}, []*schema.Message{schema.UserMessage("go")},
+		func(ctx context.Context, msgs []*schema.Message) (*schema.Message, error) {
+			return agent.Generate(ctx, msgs)
+		})
+	require.NoError(t, err)
+	assert.Equal(t, "done", output.Content)
+
+	// 父智能体两轮 + 3 个子智能体各一轮，模型经 context 传给切面
+	assert.Len(t, probeAspect.models, 5)
+	for _, m := range probeAspect.models {
+		assert.Equal(t, "default-model", m)
+	}
+}
+
+// TestSubAgentRunner_SessionModelBindsOwnTools 会话切换模型时，父智能体与 fork 的子智能体各自绑定自己的工具
+func TestSubAgentRunner_SessionModelBindsOwnTools(t *testing.T) {
+	provider := newToolRecordingProvider(t)
+	llm := config.LLMConfig{Model: "gpt-4o", Url: provider.URL, Key: "test"}
+	base, err := CreateChatModel(llm, ModelOptions{})
+	require.NoError(t, err)
+	chatModel := WrapModelWithDynamicSupport(base, llm, ModelOptions{})
+	f := newSubAgentFactory(chatModel, []tool.BaseTool{namedTool{"read"}, namedTool{"bash"}, namedTool{"skill"}},
+		[]*schema.ToolInfo{{Name: "read"}, {Name: "bash"}, {Name: "skill"}}, 10, nil)
+	parent, err := CreateReactAgent(context.Background(), chatModel, AgentOptions{MaxStep: 10, ToolsConfig: buildToolsConfig(f.tools)})
+	require.NoError(t, err)
+
+	ctx := ContextWithSessionModel(context.Background(), "gpt-4o-mini")
+	_, err = parent.Generate(ctx, []*schema.Message{schema.UserMessage("hi")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bash", "read", "skill"}, provider.lastTools())
+
+	_, err = f.newRunner().RunSubAgent(ctx, aitool.SubAgentRequest{
+		Name:         "review",
+		Messages:     []*schema.Message{schema.UserMessage("do it")},
+		AllowedTools: []string{"read"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read"}, provider.lastTools())
+
+	_, err = parent.Generate(ctx, []*schema.Message{schema.UserMessage("again")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bash", "read", "skill"}, provider.lastTools())
+}
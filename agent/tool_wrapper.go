@@ -198,6 +198,7 @@ type VisualToolWrapper struct {
 	logger              types.Logger
 	callCounter         int32
 	metricsCollector    *token.MetricsCollector
+	model               string
 }
 
 // NewVisualToolWrapper 创建可视化工具包装器
@@ -214,6 +215,7 @@ func NewVisualToolWrapper(base tool.InvokableTool, opts ToolWrapOptions) *Visual
 		maxToolOutputLength: opts.MaxToolOutputLength,
 		logger:              opts.Logger,
 		metricsCollector:    opts.MetricsCollector,
+		model:               opts.Model,
 	}
 }
 
@@ -222,8 +224,23 @@ func (w *VisualToolWrapper) Info(ctx context.Context) (*schema.ToolInfo, error)
 	return w.base.Info(ctx)
 }
 
+// tokenModel 返回计算 token 使用的模型，优先使用本次 LLM 调用实际使用的模型
+func (w *VisualToolWrapper) tokenModel(ctx context.Context) string {
+	if model := aspect.GetLLMModel(ctx); model != "" {
+		return model
+	}
+	return w.model
+}
+
 // InvokableRun 执行工具并发送 AG-UI 可视化事件和 SSE 流事件
-func (w *VisualToolWrapper) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (result string, err error) {
+func (w *VisualToolWrapper) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	return w.run(ctx, argumentsInJSON, func(ctx context.Context, arguments string) (string, error) {
+		return w.base.InvokableRun(ctx, arguments, opts...)
+	})
+}
+
+// run 执行 invoke 并完成切面、事件、doom-loop 检测与指标记录，返回交给 agent 的文本结果
+func (w *VisualToolWrapper) run(ctx context.Context, argumentsInJSON string, invoke func(ctx context.Context, arguments string) (string, error)) (result string, err error) {
 	// 工具执行 panic 不杀整个 server：捕获后作为 error result 返回给 agent（agent 可见错误，决定重试/换法）。
 	// 治 agent 并行工具偶发的 concurrent map 等致命错误导致进程崩溃（server 反复 exit 2 根因之一）。
 	defer func() {
@@ -301,6 +318,10 @@ func (w *VisualToolWrapper) InvokableRun(ctx context.Context, argumentsInJSON st
 			// 切面返回错误，阻止工具调用
 			return fmt.Sprintf("Tool call blocked by aspect: %v", err), nil
 		}
+		// 切面可修改调用参数（如审批时修改参数后批准）
+		if callInfo.Arguments != "" {
+			argumentsInJSON = callInfo.Arguments
+		}
 	}
 
 	// TOOL_CALL_START 已由 VizAspect.BeforeToolCall 统一发（同一 ctx emitter），此处不再重复 emit（修双发：原 START/RESULT 被 emit 两次）
@@ -318,7 +339,7 @@ func (w *VisualToolWrapper) InvokableRun(ctx context.Context, argumentsInJSON st
 		sendToSSE(toolCallId, w.name, string(SSEEventToolStart), string(eventJSON), toolIndex)
 	}
 
-	inputTokens := token.EstimateTokens(argumentsInJSON)
+	inputTokens := token.CountTokens(w.tokenModel(ctx), argumentsInJSON)
 
 	// doom-loop 检测（执行前：滑动窗口内同名同参重复）
 	var doomWarn string
@@ -354,7 +375,7 @@ func (w *VisualToolWrapper) InvokableRun(ctx context.Context, argumentsInJSON st
 		}
 		duration := time.Since(startTime).Milliseconds()
 		if w.metricsCollector != nil {
-			w.metricsCollector.Record(w.name, duration, inputTokens, token.EstimateTokens(blockedResult), true)
+			w.metricsCollector.Record(w.name, duration, inputTokens, token.CountTokens(w.tokenModel(ctx), blockedResult), true)
 		}
 		callResult := &aspect.ToolCallResult{
 			CallId:    toolCallId,
@@ -382,7 +403,7 @@ func (w *VisualToolWrapper) InvokableRun(ctx context.Context, argumentsInJSON st
 		return blockedResult, nil
 	}
 
-	result, err = w.base.InvokableRun(ctx, argumentsInJSON, opts...)
+	result, err = invoke(ctx, argumentsInJSON)
 
 	// doom-loop 检测（执行后：记录本次调用 + 连续失败）
 	if detector := GetDoomLoopDetector(ctx); detector != nil {
@@ -401,7 +422,7 @@ func (w *VisualToolWrapper) InvokableRun(ctx context.Context, argumentsInJSON st
 	}
 
 	duration := time.Since(startTime).Milliseconds()
-	outputTokens := token.EstimateTokens(result)
+	outputTokens := token.CountTokens(w.tokenModel(ctx), result)
 
 	if w.metricsCollector != nil {
 		w.metricsCollector.Record(w.name, duration, inputTokens, outputTokens, err != nil)
@@ -502,5 +523,80 @@ func WithStepCounter(ctx context.Context, counter *int32) context.Context {
 	return context.WithValue(ctx, stepCounterKey{}, counter)
 }
 
+// ============================================
+// 多模态工具包装器
+// ============================================
+
+// VisualEnhancedToolWrapper 多模态工具（tool.EnhancedInvokableTool）的可视化包装器。
+// 切面、SSE 事件、doom-loop 检测与指标使用结果的文本形式（图片以占位文本表示），
+// 返回给模型的结果在文本之后保留图片等多模态 part。
+type VisualEnhancedToolWrapper struct {
+	*VisualToolWrapper
+	enhanced tool.EnhancedInvokableTool
+}
+
+// NewVisualEnhancedToolWrapper 创建多模态工具的可视化包装器
+func NewVisualEnhancedToolWrapper(base tool.EnhancedInvokableTool, opts ToolWrapOptions) *VisualEnhancedToolWrapper {
+	return &VisualEnhancedToolWrapper{
+		VisualToolWrapper: NewVisualToolWrapper(nil, opts),
+		enhanced:          base,
+	}
+}
+
+// Info 返回工具信息
+func (w *VisualEnhancedToolWrapper) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return w.enhanced.Info(ctx)
+}
+
+// InvokableRun 执行多模态工具并发送 AG-UI 可视化事件和 SSE 流事件
+func (w *VisualEnhancedToolWrapper) InvokableRun(ctx context.Context, toolArgument *schema.ToolArgument, opts ...tool.Option) (*schema.ToolResult, error) {
+	var arguments string
+	if toolArgument != nil {
+		arguments = toolArgument.Text
+	}
+	var media []schema.ToolOutputPart
+	text, err := w.run(ctx, arguments, func(ctx context.Context, arguments string) (string, error) {
+		output, err := w.enhanced.InvokableRun(ctx, &schema.ToolArgument{Text: arguments}, opts...)
+		if err != nil || output == nil {
+			return "", err
+		}
+		var texts []string
+		for _, part := range output.Parts {
+			if part.Type == schema.ToolPartTypeText {
+				texts = append(texts, part.Text)
+			} else {
+				texts = append(texts, toolPartPlaceholder(part))
+				media = append(media, part)
+			}
+		}
+		return strings.Join(texts, "\n"), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 工具被拦截或执行失败时 media 为空，只返回 run 给出的提示文本
+	parts := append([]schema.ToolOutputPart{{Type: schema.ToolPartTypeText, Text: text}}, media...)
+	return &schema.ToolResult{Parts: parts}, nil
+}
+
+// toolPartPlaceholder 返回多模态 part 的占位文本
+func toolPartPlaceholder(part schema.ToolOutputPart) string {
+	var mimeType string
+	switch {
+	case part.Image != nil:
+		mimeType = part.Image.MIMEType
+	case part.Audio != nil:
+		mimeType = part.Audio.MIMEType
+	case part.Video != nil:
+		mimeType = part.Video.MIMEType
+	case part.File != nil:
+		mimeType = part.File.MIMEType
+	}
+	return fmt.Sprintf("[%s: %s]", part.Type, mimeType)
+}
+
 // Ensure VisualToolWrapper implements tool.InvokableTool
 var _ tool.InvokableTool = (*VisualToolWrapper)(nil)
+
+// Ensure VisualEnhancedToolWrapper implements tool.EnhancedInvokableTool
+var _ tool.EnhancedInvokableTool = (*VisualEnhancedToolWrapper)(nil)
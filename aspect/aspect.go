@@ -32,6 +32,37 @@ import (
 // toolCallsKey stores ToolCallsCollector in context
 var toolCallsKey = contextx.NewKey[*ToolCallsCollector]("toolCalls")
 
+// agentPointKey 当前执行点的 context key
+var agentPointKey = contextx.NewKey[*AgentPoint]("agentPoint")
+
+// WithAgentPoint 将当前执行点存入 context
+// 供执行链内部的 LLM 调用（如模型包装器）执行 MessageBeforeAspect 时使用
+func WithAgentPoint(ctx context.Context, point *AgentPoint) context.Context {
+	return agentPointKey.With(ctx, point)
+}
+
+// GetAgentPoint 从 context 获取当前执行点
+func GetAgentPoint(ctx context.Context) *AgentPoint {
+	p, _ := agentPointKey.Get(ctx)
+	return p
+}
+
+// llmModelKey 当前 LLM 调用实际使用的模型的 context key
+var llmModelKey = contextx.NewKey[string]("llmModel")
+
+// WithLLMModel 将本次 LLM 调用实际使用的模型存入 context
+// 由模型包装器在执行 MessageBeforeAspect 前设置。模型随调用的 context 传递，
+// 不写入执行点元数据，并发运行的子智能体不会互相覆盖
+func WithLLMModel(ctx context.Context, model string) context.Context {
+	return llmModelKey.With(ctx, model)
+}
+
+// GetLLMModel 从 context 获取本次 LLM 调用实际使用的模型，未设置时返回空字符串
+func GetLLMModel(ctx context.Context) string {
+	model, _ := llmModelKey.Get(ctx)
+	return model
+}
+
 // ToolCallsCollector 工具调用收集器
 // 线程安全，用于在 Agent 执行过程中收集工具调用结果
 type ToolCallsCollector struct {
@@ -93,6 +124,79 @@ func GetToolCallResultsFromContext(ctx context.Context) []ToolCallResult {
 	return collector.Get()
 }
 
+// ============================================
+// LLM Usage Collector - LLM 调用用量收集器
+// ============================================
+
+// llmUsageKey stores LLMUsageCollector in context
+var llmUsageKey = contextx.NewKey[*LLMUsageCollector]("llmUsage")
+
+// LLMCallUsage 单次 LLM 调用的用量
+type LLMCallUsage struct {
+	Model string     // 实际使用的模型
+	Usage TokenUsage // 模型返回的 token 用量
+}
+
+// LLMUsageCollector LLM 调用用量收集器
+// 线程安全，用于在 Agent 执行过程中按调用收集 token 用量（一次执行可能包含多轮 LLM 调用）
+type LLMUsageCollector struct {
+	mu    sync.Mutex
+	calls []LLMCallUsage
+}
+
+// NewLLMUsageCollector 创建新的 LLM 调用用量收集器
+func NewLLMUsageCollector() *LLMUsageCollector {
+	return &LLMUsageCollector{
+		calls: make([]LLMCallUsage, 0),
+	}
+}
+
+// Add 添加一次调用的用量，返回调用序号
+func (c *LLMUsageCollector) Add(call LLMCallUsage) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, call)
+	return len(c.calls) - 1
+}
+
+// Update 更新指定调用的用量（流式调用可能在多个分片中返回用量，以最后一次为准）
+func (c *LLMUsageCollector) Update(idx int, usage TokenUsage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if idx >= 0 && idx < len(c.calls) {
+		c.calls[idx].Usage = usage
+	}
+}
+
+// Get 获取所有调用的用量
+func (c *LLMUsageCollector) Get() []LLMCallUsage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := make([]LLMCallUsage, len(c.calls))
+	copy(result, c.calls)
+	return result
+}
+
+// WithLLMUsageCollector 将 LLM 调用用量收集器存入 context
+func WithLLMUsageCollector(ctx context.Context, collector *LLMUsageCollector) context.Context {
+	return llmUsageKey.With(ctx, collector)
+}
+
+// GetLLMUsageCollector 从 context 获取 LLM 调用用量收集器
+func GetLLMUsageCollector(ctx context.Context) *LLMUsageCollector {
+	c, _ := llmUsageKey.Get(ctx)
+	return c
+}
+
+// GetLLMUsagesFromContext 从 context 获取所有 LLM 调用的用量
+func GetLLMUsagesFromContext(ctx context.Context) []LLMCallUsage {
+	collector := GetLLMUsageCollector(ctx)
+	if collector == nil {
+		return nil
+	}
+	return collector.Get()
+}
+
 // ============================================
 // Aspect Interface - 切面接口定义
 // ============================================
@@ -207,6 +311,18 @@ type AgentPoint struct {
 	Metadata    map[string]string // Additional metadata / 额外元数据
 }
 
+// Clone returns a copy of the point with its own Metadata map.
+//
+// Clone 复制执行点，Metadata 为独立的副本。
+func (p *AgentPoint) Clone() *AgentPoint {
+	cp := *p
+	cp.Metadata = make(map[string]string, len(p.Metadata))
+	for k, v := range p.Metadata {
+		cp.Metadata[k] = v
+	}
+	return &cp
+}
+
 // AgentInput represents the input to an agent execution.
 //
 // AgentInput 表示智能体执行的输入。
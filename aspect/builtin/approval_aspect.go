@@ -0,0 +1,550 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/rulego/rulego-components-ai/aspect"
+	"github.com/rulego/rulego-components-ai/config"
+	aierrors "github.com/rulego/rulego-components-ai/errors"
+	"github.com/rulego/rulego-components-ai/session"
+	"github.com/rulego/rulego-components-ai/tool/common"
+	"github.com/rulego/rulego/api/types"
+)
+
+const (
+	// DefaultApprovalTimeout 默认审批等待时间，超时视为拒绝
+	DefaultApprovalTimeout = 30 * time.Minute
+	// ApprovalRequestEvent 审批请求事件名称（AG-UI CUSTOM 事件）
+	ApprovalRequestEvent = "tool_approval_request"
+	// ApprovalResolvedEvent 审批结果事件名称（AG-UI CUSTOM 事件）
+	ApprovalResolvedEvent = "tool_approval_resolved"
+	// ApprovalMsgType 审批请求规则链消息类型
+	ApprovalMsgType = "TOOL_APPROVAL_REQUEST"
+	// RelationApproval 审批请求规则链关系类型
+	RelationApproval = "Approval"
+	// metaApprovalAck 决定已交付给等待中的执行，Around 阶段直接应答
+	metaApprovalAck = "_approval_ack"
+)
+
+// ApprovalRule 工具调用审批规则，已配置的条件全部满足时命中
+type ApprovalRule struct {
+	// Name 规则名称，写入审批记录
+	Name string `json:"name"`
+	// Tools 工具名称通配符（如 bash、mcp_*），为空匹配所有工具
+	Tools []string `json:"tools,omitempty"`
+	// Commands 命令正则，匹配参数 command（bash 工具），为空不限制
+	Commands []string `json:"commands,omitempty"`
+	// Paths 路径通配符，匹配参数 path/file_path，支持 **；不含 / 的模式同时匹配文件名
+	Paths []string `json:"paths,omitempty"`
+
+	commands []*regexp.Regexp
+}
+
+// ApprovalConfig 审批切面配置
+type ApprovalConfig struct {
+	Rules []ApprovalRule `json:"rules"`
+	// Timeout 等待审批的最长时间，<=0 使用 DefaultApprovalTimeout
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// Broker 等待者注册表，为空使用进程内共享的 DefaultApprovalBroker
+	Broker *ApprovalBroker `json:"-"`
+}
+
+// ApprovalBroker 审批等待者注册表
+// 挂起的工具调用按审批 ID 注册等待通道，决定消息到达时通过它唤醒对应调用
+type ApprovalBroker struct {
+	mu      sync.Mutex
+	waiters map[string]chan session.ToolApproval
+}
+
+// DefaultApprovalBroker 进程内共享的审批等待者注册表
+var DefaultApprovalBroker = NewApprovalBroker()
+
+// NewApprovalBroker 创建审批等待者注册表
+func NewApprovalBroker() *ApprovalBroker {
+	return &ApprovalBroker{waiters: make(map[string]chan session.ToolApproval)}
+}
+
+// register 注册等待通道
+func (b *ApprovalBroker) register(id string) chan session.ToolApproval {
+	ch := make(chan session.ToolApproval, 1)
+	b.mu.Lock()
+	b.waiters[id] = ch
+	b.mu.Unlock()
+	return ch
+}
+
+// unregister 注销等待通道
+func (b *ApprovalBroker) unregister(id string) {
+	b.mu.Lock()
+	delete(b.waiters, id)
+	b.mu.Unlock()
+}
+
+// deliver 将决定交付给等待者，没有等待者（如进程已重启）时返回 false
+func (b *ApprovalBroker) deliver(approval session.ToolApproval) bool {
+	b.mu.Lock()
+	ch, ok := b.waiters[approval.ID]
+	delete(b.waiters, approval.ID)
+	b.mu.Unlock()
+	if !ok {
+		return false
+	}
+	ch <- approval
+	return true
+}
+
+// Waiting 返回审批 ID 是否有挂起中的工具调用
+func (b *ApprovalBroker) Waiting(id string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.waiters[id]
+	return ok
+}
+
+// ApprovalAspect 工具调用审批切面（人工介入）
+// BeforeToolCall 阶段对命中审批策略的工具调用创建待审批记录并持久化到会话，
+// 通过 AG-UI CUSTOM 事件和规则链 Approval 关系发出审批请求，然后挂起调用等待决定。
+// 携带 approvalDecision/approvalRunId 元数据的消息为决定消息，SessionAspect 不会将其保存为用户对话：
+// Before 阶段记录决定并唤醒挂起的调用，Around 阶段直接应答，不调用 LLM；
+// 若原执行已不存在（进程重启），则把决定作为上下文注入本次执行，LLM 重新发起的相同调用直接使用该决定。
+type ApprovalAspect struct {
+	order      int
+	sessionMgr session.ApprovalManager
+	rules      []ApprovalRule
+	timeout    time.Duration
+	broker     *ApprovalBroker
+	logger     types.Logger
+}
+
+// NewApprovalAspect 创建工具调用审批切面
+func NewApprovalAspect(sessionMgr session.SessionManager, cfg ApprovalConfig, logger types.Logger) (*ApprovalAspect, error) {
+	rules := make([]ApprovalRule, len(cfg.Rules))
+	for i, rule := range cfg.Rules {
+		for _, expr := range rule.Commands {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("approval rule %q: invalid command pattern %q: %w", rule.Name, expr, err)
+			}
+			rule.commands = append(rule.commands, re)
+		}
+		rules[i] = rule
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultApprovalTimeout
+	}
+	broker := cfg.Broker
+	if broker == nil {
+		broker = DefaultApprovalBroker
+	}
+	// 会话管理器不支持审批记录时不应用此切面
+	approvals, _ := sessionMgr.(session.ApprovalManager)
+	return &ApprovalAspect{
+		order:      70,
+		sessionMgr: approvals,
+		rules:      rules,
+		timeout:    timeout,
+		broker:     broker,
+		logger:     logger,
+	}, nil
+}
+
+// Order 返回执行顺序
+func (a *ApprovalAspect) Order() int {
+	return a.order
+}
+
+// New 创建切面的新实例，共享等待者注册表
+func (a *ApprovalAspect) New() aspect.Aspect {
+	return &ApprovalAspect{
+		order:      a.order,
+		sessionMgr: a.sessionMgr,
+		rules:      a.rules,
+		timeout:    a.timeout,
+		broker:     a.broker,
+		logger:     a.logger,
+	}
+}
+
+// PointCut 配置了支持审批记录的会话管理器和审批规则时应用此切面
+func (a *ApprovalAspect) PointCut(ctx context.Context, point *aspect.AgentPoint) bool {
+	return a.sessionMgr != nil && len(a.rules) > 0
+}
+
+// log 内部日志方法
+func (a *ApprovalAspect) log(format string, v ...interface{}) {
+	if a.logger != nil {
+		a.logger.Debugf(format, v...)
+	}
+}
+
+// Before 记录会话 key，并处理决定消息
+func (a *ApprovalAspect) Before(ctx context.Context, point *aspect.AgentPoint, input *aspect.AgentInput) (*aspect.AgentInput, error) {
+	if point.Metadata == nil {
+		point.Metadata = make(map[string]string)
+	}
+	if input.SessionKey != "" {
+		point.Metadata[aspect.MetaSessionKey] = input.SessionKey
+	}
+	decision := input.Metadata[aspect.MetaApprovalDecision]
+	if decision == "" {
+		return input, nil
+	}
+
+	approvals, delivered, err := a.Decide(ctx, input.SessionKey, input.Metadata[aspect.MetaApprovalRunID],
+		input.Metadata[aspect.MetaApprovalID], session.ApprovalDecision(decision),
+		input.Metadata[aspect.MetaApprovalArguments], input.Metadata[aspect.MetaApprovalReason])
+	if err != nil {
+		return input, err
+	}
+	point.Metadata[aspect.MetaApprovalRunID] = approvals[0].RunID
+	point.Metadata[aspect.MetaApprovalStatus] = string(approvals[0].Status)
+
+	var notes []string
+	for i, approval := range approvals {
+		if !delivered[i] {
+			notes = append(notes, approvalNote(approval))
+		}
+	}
+	if len(notes) == 0 {
+		point.Metadata[metaApprovalAck] = "true"
+		return input, nil
+	}
+	// 原执行已不存在，把决定交给 LLM 继续处理
+	a.log("[ApprovalAspect] Before: run %s is gone, resuming with %d decision(s)", approvals[0].RunID, len(notes))
+	input.HistoryMessages = append(input.HistoryMessages, schema.UserMessage(strings.Join(notes, "\n")))
+	return input, nil
+}
+
+// Around 决定已交付给挂起的执行时直接应答，不调用 LLM
+func (a *ApprovalAspect) Around(ctx context.Context, point *aspect.AgentPoint, input *aspect.AgentInput, next aspect.AgentExecutor) (*aspect.AgentOutput, error) {
+	if point.Metadata[metaApprovalAck] != "true" {
+		return next(ctx, input)
+	}
+	runID := point.Metadata[aspect.MetaApprovalRunID]
+	status := point.Metadata[aspect.MetaApprovalStatus]
+	return &aspect.AgentOutput{
+		Content:    fmt.Sprintf("Tool approval for run %s recorded: %s", runID, status),
+		IsSuccess:  true,
+		SkippedAI:  true,
+		SessionKey: input.SessionKey,
+		Metadata: map[string]any{
+			"_isCommandResponse":      true,
+			aspect.MetaApprovalRunID:  runID,
+			aspect.MetaApprovalStatus: status,
+		},
+	}, nil
+}
+
+// Decide 对会话中某次执行的待审批调用做出决定，approvalID 为空时处理该执行的全部待审批调用
+// 返回更新后的审批记录及每条记录是否已交付给挂起中的调用
+func (a *ApprovalAspect) Decide(ctx context.Context, sessionKey, runID, approvalID string, decision session.ApprovalDecision, editedArguments, reason string) ([]session.ToolApproval, []bool, error) {
+	if sessionKey == "" {
+		return nil, nil, aierrors.InvalidInput("approval decision requires a session")
+	}
+	if runID == "" && approvalID == "" {
+		return nil, nil, aierrors.InvalidInput("approval decision requires approvalRunId or approvalId")
+	}
+	all, err := a.sessionMgr.ListApprovals(ctx, sessionKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ids []string
+	for _, approval := range all {
+		if approval.Status != session.ApprovalPending {
+			continue
+		}
+		if (approvalID != "" && approval.ID == approvalID) || (approvalID == "" && approval.RunID == runID) {
+			ids = append(ids, approval.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil, fmt.Errorf("%w: run=%s id=%s", session.ErrApprovalNotFound, runID, approvalID)
+	}
+	if decision == session.DecisionEdit && len(ids) > 1 {
+		return nil, nil, aierrors.InvalidInput("edit decision requires approvalId when a run has several pending approvals")
+	}
+
+	approvals := make([]session.ToolApproval, 0, len(ids))
+	delivered := make([]bool, 0, len(ids))
+	for _, id := range ids {
+		updated, err := a.sessionMgr.UpdateApproval(ctx, sessionKey, id, func(approval *session.ToolApproval) error {
+			return approval.Decide(decision, editedArguments, reason)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		ok := a.broker.deliver(*updated)
+		a.log("[ApprovalAspect] Decide: approval=%s tool=%s status=%s delivered=%v", updated.ID, updated.ToolName, updated.Status, ok)
+		if emitter, found := aspect.GetEmitterWithFallback(ctx, updated.AgentID); found {
+			aspect.EmitCustom(emitter, ApprovalResolvedEvent, updated)
+		}
+		approvals = append(approvals, *updated)
+		delivered = append(delivered, ok)
+	}
+	return approvals, delivered, nil
+}
+
+// BeforeToolCall 命中审批策略的调用挂起等待决定
+func (a *ApprovalAspect) BeforeToolCall(ctx context.Context, point *aspect.AgentPoint, call *aspect.ToolCallInfo) (*aspect.ToolCallInfo, error) {
+	rule := a.match(call.Name, call.Arguments)
+	if rule == nil {
+		return call, nil
+	}
+	// 工具调用的执行点是临时创建的，会话与执行 ID 取自本次执行的执行点
+	run := aspect.GetAgentPoint(ctx)
+	if run == nil {
+		run = point
+	}
+	sessionKey := run.Metadata[aspect.MetaSessionKey]
+	if sessionKey == "" {
+		return nil, aierrors.ToolApprovalDenied(call.Name, "approval required but no session is available")
+	}
+
+	// 进程重启后恢复的执行：使用已做出的决定
+	if decided, err := a.takeDecided(ctx, sessionKey, call); err != nil {
+		return nil, err
+	} else if decided != nil {
+		a.log("[ApprovalAspect] BeforeToolCall: replay approval=%s tool=%s status=%s", decided.ID, call.Name, decided.Status)
+		return applyApproval(call, decided)
+	}
+
+	approval := session.NewToolApproval(run.Metadata[aspect.MetaRunID], run.AgentId, call.Name, call.Arguments, rule.Name)
+	// 先注册等待者再持久化，避免决定先于注册到达
+	ch := a.broker.register(approval.ID)
+	defer a.broker.unregister(approval.ID)
+	if err := a.sessionMgr.AddApproval(ctx, sessionKey, approval); err != nil {
+		return nil, err
+	}
+	a.log("[ApprovalAspect] BeforeToolCall: tool=%s suspended, approval=%s run=%s rule=%s", call.Name, approval.ID, approval.RunID, rule.Name)
+	a.emitRequest(ctx, run, approval)
+
+	timer := time.NewTimer(a.timeout)
+	defer timer.Stop()
+	var decided *session.ToolApproval
+	select {
+	case result := <-ch:
+		decided = &result
+	case <-ctx.Done():
+		// 保留待审批记录，进程重启后可继续审批
+		return nil, ctx.Err()
+	case <-timer.C:
+		updated, err := a.sessionMgr.UpdateApproval(ctx, sessionKey, approval.ID, func(approval *session.ToolApproval) error {
+			if approval.Status == session.ApprovalPending {
+				approval.Status = session.ApprovalExpired
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if updated.Status == session.ApprovalExpired {
+			return nil, aierrors.ToolApprovalDenied(call.Name, "approval timed out")
+		}
+		// 超时与决定同时发生时以决定为准
+		decided = updated
+	}
+
+	if _, err := a.sessionMgr.UpdateApproval(ctx, sessionKey, approval.ID, func(approval *session.ToolApproval) error {
+		approval.Consumed = true
+		return nil
+	}); err != nil {
+		a.log("[ApprovalAspect] BeforeToolCall: mark approval=%s consumed failed: %v", approval.ID, err)
+	}
+	return applyApproval(call, decided)
+}
+
+// takeDecided 查找同一工具、同一参数且尚未使用的已决定记录，并标记为已使用
+func (a *ApprovalAspect) takeDecided(ctx context.Context, sessionKey string, call *aspect.ToolCallInfo) (*session.ToolApproval, error) {
+	approvals, err := a.sessionMgr.ListApprovals(ctx, sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, approval := range approvals {
+		if approval.Consumed || approval.ToolName != call.Name ||
+			(approval.Status != session.ApprovalApproved && approval.Status != session.ApprovalDenied) {
+			continue
+		}
+		if approval.Arguments != call.Arguments && approval.EditedArguments != call.Arguments {
+			continue
+		}
+		if a.broker.Waiting(approval.ID) {
+			continue
+		}
+		updated, err := a.sessionMgr.UpdateApproval(ctx, sessionKey, approval.ID, func(approval *session.ToolApproval) error {
+			if approval.Consumed {
+				return fmt.Errorf("approval %s already consumed", approval.ID)
+			}
+			approval.Consumed = true
+			return nil
+		})
+		if err != nil {
+			// 并发调用已使用该决定
+			continue
+		}
+		return updated, nil
+	}
+	return nil, nil
+}
+
+// match 返回第一条命中的审批规则
+func (a *ApprovalAspect) match(toolName, arguments string) *ApprovalRule {
+	var args map[string]any
+	_ = json.Unmarshal([]byte(arguments), &args)
+	for i := range a.rules {
+		if a.rules[i].matches(toolName, args) {
+			return &a.rules[i]
+		}
+	}
+	return nil
+}
+
+// matches 检查工具调用是否命中规则
+func (r *ApprovalRule) matches(toolName string, args map[string]any) bool {
+	if len(r.Tools) > 0 && !matchAny(r.Tools, func(p string) bool {
+		ok, _ := path.Match(p, toolName)
+		return ok
+	}) {
+		return false
+	}
+	if len(r.commands) > 0 {
+		command, _ := args["command"].(string)
+		if command == "" || !matchAnyRegexp(r.commands, command) {
+			return false
+		}
+	}
+	if len(r.Paths) > 0 {
+		filePath := stringArg(args, "path", "file_path")
+		if filePath == "" || !matchAny(r.Paths, func(p string) bool { return matchApprovalPath(p, filePath) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchApprovalPath 匹配路径，不含 / 的模式同时匹配文件名
+func matchApprovalPath(pattern, filePath string) bool {
+	filePath = strings.TrimPrefix(path.Clean(strings.ReplaceAll(filePath, "\\", "/")), "./")
+	if common.MatchWithDoubleStar(pattern, filePath) {
+		return true
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(filePath))
+		return ok
+	}
+	return false
+}
+
+func matchAny(patterns []string, match func(string) bool) bool {
+	for _, p := range patterns {
+		if match(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyRegexp(patterns []*regexp.Regexp, s string) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// stringArg 按顺序返回第一个非空字符串参数
+func stringArg(args map[string]any, keys ...string) string {
+	for _, key := range keys {
+		if v, ok := args[key].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// emitRequest 通过 AG-UI 事件和规则链消息发出审批请求
+func (a *ApprovalAspect) emitRequest(ctx context.Context, run *aspect.AgentPoint, approval *session.ToolApproval) {
+	if emitter, ok := aspect.GetEmitterWithFallback(ctx, run.AgentId); ok {
+		aspect.EmitCustom(emitter, ApprovalRequestEvent, approval)
+	}
+
+	ruleCtx, ok := ctx.Value(config.ShareRuleContextKey).(types.RuleContext)
+	if !ok || ruleCtx == nil {
+		return
+	}
+	metadata := types.NewMetadata()
+	for k, v := range run.Metadata {
+		if !strings.HasPrefix(k, "_") {
+			metadata.PutValue(k, v)
+		}
+	}
+	metadata.PutValue(aspect.MetaApprovalRequired, config.ValueTrue)
+	metadata.PutValue(aspect.MetaApprovalRunID, approval.RunID)
+	metadata.PutValue(aspect.MetaApprovalID, approval.ID)
+	metadata.PutValue(aspect.MetaApprovalTool, approval.ToolName)
+	metadata.PutValue(aspect.MetaApprovalArguments, approval.Arguments)
+	metadata.PutValue(aspect.MetaApprovalRule, approval.Rule)
+	metadata.PutValue(aspect.MetaApprovalStatus, string(approval.Status))
+	data, err := json.Marshal(aspect.NewCustomEvent(ApprovalRequestEvent, approval))
+	if err != nil {
+		a.log("[ApprovalAspect] emitRequest: marshal approval failed: %v", err)
+		return
+	}
+	ruleCtx.TellNext(types.NewMsg(0, ApprovalMsgType, types.JSON, metadata, string(data)), RelationApproval)
+}
+
+// applyApproval 按决定放行（可能修改参数）或拒绝工具调用
+func applyApproval(call *aspect.ToolCallInfo, approval *session.ToolApproval) (*aspect.ToolCallInfo, error) {
+	if approval.Status != session.ApprovalApproved {
+		reason := approval.Reason
+		if reason == "" {
+			reason = "denied by user"
+		}
+		return nil, aierrors.ToolApprovalDenied(call.Name, reason)
+	}
+	call.Arguments = approval.EffectiveArguments()
+	return call, nil
+}
+
+// approvalNote 为已不存在的执行生成决定说明，注入到恢复执行的上下文
+func approvalNote(approval session.ToolApproval) string {
+	switch {
+	case approval.Status == session.ApprovalDenied:
+		note := fmt.Sprintf("[Tool approval] The pending call to tool %s with arguments %s was denied", approval.ToolName, approval.Arguments)
+		if approval.Reason != "" {
+			note += ": " + approval.Reason
+		}
+		return note + ". Do not retry it; continue without it."
+	case approval.Decision == session.DecisionEdit:
+		return fmt.Sprintf("[Tool approval] The pending call to tool %s was approved with edited arguments %s. Call it again with exactly these arguments to continue.",
+			approval.ToolName, approval.EditedArguments)
+	default:
+		return fmt.Sprintf("[Tool approval] The pending call to tool %s with arguments %s was approved. Call it again with the same arguments to continue.",
+			approval.ToolName, approval.Arguments)
+	}
+}
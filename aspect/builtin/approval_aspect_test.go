@@ -0,0 +1,264 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rulego/rulego-components-ai/aspect"
+	aierrors "github.com/rulego/rulego-components-ai/errors"
+	"github.com/rulego/rulego-components-ai/session"
+)
+
+var testApprovalRules = []ApprovalRule{
+	{Name: "dangerous-shell", Tools: []string{"bash"}, Commands: []string{`\brm\s+-rf\b`, `^git\s+push`}},
+	{Name: "protected-files", Tools: []string{"write", "edit"}, Paths: []string{"deploy/**", "*.env"}},
+	{Name: "mcp", Tools: []string{"mcp_*"}},
+}
+
+// newApprovalTestRun 创建会话和审批切面，返回带执行点的 context
+func newApprovalTestRun(t *testing.T, mgr *session.Manager, timeout time.Duration) (*ApprovalAspect, context.Context, string) {
+	t.Helper()
+	sess, err := mgr.GetOrCreate(context.Background(), session.SessionRequest{AgentID: "agent", Channel: "test", Scope: session.ScopePerPeer, ScopeID: "alice"})
+	if err != nil {
+		t.Fatalf("GetOrCreate() error = %v", err)
+	}
+	a, err := NewApprovalAspect(mgr, ApprovalConfig{Rules: testApprovalRules, Timeout: timeout, Broker: NewApprovalBroker()}, nil)
+	if err != nil {
+		t.Fatalf("NewApprovalAspect() error = %v", err)
+	}
+	run := &aspect.AgentPoint{AgentId: "agent", Metadata: map[string]string{aspect.MetaSessionKey: sess.Key, aspect.MetaRunID: "run-1"}}
+	return a, aspect.WithAgentPoint(context.Background(), run), sess.Key
+}
+
+// waitPending 等待挂起的调用写入待审批记录
+func waitPending(t *testing.T, mgr *session.Manager, sessionKey string, n int) []session.ToolApproval {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		approvals, _ := mgr.ListApprovals(context.Background(), sessionKey)
+		var pending []session.ToolApproval
+		for _, approval := range approvals {
+			if approval.Status == session.ApprovalPending {
+				pending = append(pending, approval)
+			}
+		}
+		if len(pending) >= n {
+			return pending
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d pending approvals", n)
+	return nil
+}
+
+func decisionInput(sessionKey, runID string, decision session.ApprovalDecision, args string) *aspect.AgentInput {
+	return &aspect.AgentInput{SessionKey: sessionKey, Metadata: map[string]string{
+		aspect.MetaApprovalRunID:     runID,
+		aspect.MetaApprovalDecision:  string(decision),
+		aspect.MetaApprovalArguments: args,
+	}}
+}
+
+func TestApprovalRule_Matches(t *testing.T) {
+	a, err := NewApprovalAspect(session.NewManager(session.NewMemoryStorage(), nil), ApprovalConfig{Rules: testApprovalRules}, nil)
+	if err != nil {
+		t.Fatalf("NewApprovalAspect() error = %v", err)
+	}
+	cases := []struct {
+		tool, args, want string
+	}{
+		{"bash", `{"command":"rm -rf /tmp/x"}`, "dangerous-shell"},
+		{"bash", `{"command":"git push origin main"}`, "dangerous-shell"},
+		{"bash", `{"command":"ls -la"}`, ""},
+		{"write", `{"path":"deploy/k8s/app.yaml"}`, "protected-files"},
+		{"edit", `{"file_path":"config/prod.env"}`, "protected-files"},
+		{"write", `{"path":"src/main.go"}`, ""},
+		{"read", `{"path":"deploy/app.yaml"}`, ""},
+		{"mcp_github", `{}`, "mcp"},
+	}
+	for _, tc := range cases {
+		rule := a.match(tc.tool, tc.args)
+		got := ""
+		if rule != nil {
+			got = rule.Name
+		}
+		if got != tc.want {
+			t.Errorf("match(%s, %s) = %q, want %q", tc.tool, tc.args, got, tc.want)
+		}
+	}
+
+	if _, err := NewApprovalAspect(nil, ApprovalConfig{Rules: []ApprovalRule{{Name: "bad", Commands: []string{"("}}}}, nil); err == nil {
+		t.Error("invalid command pattern should be rejected")
+	}
+}
+
+func TestApprovalAspect_SuspendAndResume(t *testing.T) {
+	mgr := session.NewManager(session.NewMemoryStorage(), nil)
+
+	cases := []struct {
+		name     string
+		decision session.ApprovalDecision
+		edited   string
+		wantArgs string
+		wantErr  bool
+	}{
+		{"approve", session.DecisionApprove, "", `{"command":"rm -rf build"}`, false},
+		{"edit", session.DecisionEdit, `{"command":"rm -rf build/tmp"}`, `{"command":"rm -rf build/tmp"}`, false},
+		{"deny", session.DecisionDeny, "", "", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, ctx, sessionKey := newApprovalTestRun(t, mgr, time.Minute)
+			type result struct {
+				call *aspect.ToolCallInfo
+				err  error
+			}
+			done := make(chan result, 1)
+			go func() {
+				call, err := a.BeforeToolCall(ctx, &aspect.AgentPoint{}, &aspect.ToolCallInfo{Name: "bash", Arguments: `{"command":"rm -rf build"}`})
+				done <- result{call, err}
+			}()
+			pending := waitPending(t, mgr, sessionKey, 1)
+			if pending[0].RunID != "run-1" || pending[0].Rule != "dangerous-shell" {
+				t.Fatalf("unexpected pending approval %+v", pending[0])
+			}
+
+			// 决定消息：记录决定并唤醒挂起的调用，不调用 LLM
+			point := &aspect.AgentPoint{Metadata: map[string]string{}}
+			input, err := a.Before(context.Background(), point, decisionInput(sessionKey, "run-1", tc.decision, tc.edited))
+			if err != nil {
+				t.Fatalf("Before() error = %v", err)
+			}
+			output, err := a.Around(context.Background(), point, input, func(ctx context.Context, input *aspect.AgentInput) (*aspect.AgentOutput, error) {
+				t.Error("decision message should not reach the LLM")
+				return nil, nil
+			})
+			if err != nil || !output.SkippedAI || output.Metadata["_isCommandResponse"] != true {
+				t.Fatalf("expected command response, got %+v err=%v", output, err)
+			}
+
+			r := <-done
+			if tc.wantErr {
+				if !aierrors.IsCode(r.err, aierrors.CodeToolApprovalDenied) {
+					t.Fatalf("expected CodeToolApprovalDenied, got %v", r.err)
+				}
+			} else if r.err != nil || r.call.Arguments != tc.wantArgs {
+				t.Fatalf("BeforeToolCall() = %+v, %v; want args %s", r.call, r.err, tc.wantArgs)
+			}
+
+			approvals, _ := mgr.ListApprovals(context.Background(), sessionKey)
+			last := approvals[len(approvals)-1]
+			if !last.Consumed || last.DecidedAt == nil {
+				t.Errorf("decided approval should be consumed, got %+v", last)
+			}
+		})
+	}
+
+	t.Run("non matching call passes", func(t *testing.T) {
+		a, ctx, _ := newApprovalTestRun(t, mgr, time.Minute)
+		call, err := a.BeforeToolCall(ctx, &aspect.AgentPoint{}, &aspect.ToolCallInfo{Name: "bash", Arguments: `{"command":"ls"}`})
+		if err != nil || call.Arguments != `{"command":"ls"}` {
+			t.Errorf("BeforeToolCall() = %+v, %v", call, err)
+		}
+	})
+
+	t.Run("unknown run", func(t *testing.T) {
+		a, _, sessionKey := newApprovalTestRun(t, mgr, time.Minute)
+		_, err := a.Before(context.Background(), &aspect.AgentPoint{}, decisionInput(sessionKey, "no-such-run", session.DecisionApprove, ""))
+		if !errors.Is(err, session.ErrApprovalNotFound) {
+			t.Errorf("expected ErrApprovalNotFound, got %v", err)
+		}
+	})
+}
+
+func TestApprovalAspect_Timeout(t *testing.T) {
+	mgr := session.NewManager(session.NewMemoryStorage(), nil)
+	a, ctx, sessionKey := newApprovalTestRun(t, mgr, 50*time.Millisecond)
+	_, err := a.BeforeToolCall(ctx, &aspect.AgentPoint{}, &aspect.ToolCallInfo{Name: "mcp_github", Arguments: `{}`})
+	if !aierrors.IsCode(err, aierrors.CodeToolApprovalDenied) {
+		t.Fatalf("expected CodeToolApprovalDenied, got %v", err)
+	}
+	approvals, _ := mgr.ListApprovals(context.Background(), sessionKey)
+	if len(approvals) != 1 || approvals[0].Status != session.ApprovalExpired {
+		t.Errorf("approval should be expired, got %+v", approvals)
+	}
+}
+
+// basicSessionManager 只实现 session.SessionManager，不支持审批记录
+type basicSessionManager struct {
+	session.SessionManager
+}
+
+func TestApprovalAspect_RequiresApprovalManager(t *testing.T) {
+	mgr := basicSessionManager{session.NewManager(session.NewMemoryStorage(), nil)}
+	a, err := NewApprovalAspect(mgr, ApprovalConfig{Rules: testApprovalRules}, nil)
+	if err != nil {
+		t.Fatalf("NewApprovalAspect() error = %v", err)
+	}
+	if a.PointCut(context.Background(), &aspect.AgentPoint{}) {
+		t.Error("aspect should not apply when the session manager does not support approvals")
+	}
+}
+
+func TestApprovalAspect_ResumeAfterRestart(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "sessions.db")
+	storage, err := session.NewSQLiteStorage(dbPath)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage() error = %v", err)
+	}
+	mgr := session.NewManager(storage, nil)
+	a, ctx, sessionKey := newApprovalTestRun(t, mgr, time.Minute)
+
+	// 执行被中断（进程退出），待审批记录保留
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		_, err := a.BeforeToolCall(runCtx, &aspect.AgentPoint{}, &aspect.ToolCallInfo{Name: "write", Arguments: `{"path":"deploy/app.yaml"}`})
+		done <- err
+	}()
+	waitPending(t, mgr, sessionKey, 1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	_ = storage.Close()
+
+	// 重启：新的存储、管理器和等待者注册表
+	storage, err = session.NewSQLiteStorage(dbPath)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage() error = %v", err)
+	}
+	defer storage.Close()
+	mgr = session.NewManager(storage, nil)
+	a, err = NewApprovalAspect(mgr, ApprovalConfig{Rules: testApprovalRules, Broker: NewApprovalBroker()}, nil)
+	if err != nil {
+		t.Fatalf("NewApprovalAspect() error = %v", err)
+	}
+
+	point := &aspect.AgentPoint{Metadata: map[string]string{}}
+	input, err := a.Before(context.Background(), point, decisionInput(sessionKey, "run-1", session.DecisionApprove, ""))
+	if err != nil {
+		t.Fatalf("Before() error = %v", err)
+	}
+	if point.Metadata[metaApprovalAck] != "" {
+		t.Fatal("decision without a live run should resume through the LLM")
+	}
+	if len(input.HistoryMessages) != 1 || !strings.Contains(input.HistoryMessages[0].Content, "was approved") {
+		t.Fatalf("expected approval note in history, got %+v", input.HistoryMessages)
+	}
+
+	// 恢复执行中 LLM 重新发起相同调用，直接使用已做出的决定
+	resumed := aspect.WithAgentPoint(context.Background(), &aspect.AgentPoint{AgentId: "agent", Metadata: map[string]string{aspect.MetaSessionKey: sessionKey, aspect.MetaRunID: "run-2"}})
+	call, err := a.BeforeToolCall(resumed, &aspect.AgentPoint{}, &aspect.ToolCallInfo{Name: "write", Arguments: `{"path":"deploy/app.yaml"}`})
+	if err != nil || call == nil {
+		t.Fatalf("replayed call should pass, got %v", err)
+	}
+	approvals, _ := mgr.ListApprovals(context.Background(), sessionKey)
+	if len(approvals) != 1 || approvals[0].Status != session.ApprovalApproved || !approvals[0].Consumed {
+		t.Errorf("unexpected approvals after resume %+v", approvals)
+	}
+}
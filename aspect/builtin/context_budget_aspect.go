@@ -0,0 +1,277 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package builtin
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/rulego/rulego-components-ai/aspect"
+	"github.com/rulego/rulego-components-ai/config"
+	aierrors "github.com/rulego/rulego-components-ai/errors"
+	"github.com/rulego/rulego-components-ai/utils/token"
+	"github.com/rulego/rulego/api/types"
+)
+
+const (
+	// DefaultContextReserveTokens 默认预留 token 数（工具定义、消息格式开销等）
+	DefaultContextReserveTokens = 2048
+	// DefaultToolResultMaxTokens 超大工具结果压缩后默认保留的 token 数
+	DefaultToolResultMaxTokens = 1024
+
+	// messageTokenOverhead 每条消息的格式开销估算
+	messageTokenOverhead = 4
+)
+
+// ContextBudgetConfig 上下文预算配置
+type ContextBudgetConfig struct {
+	// DefaultLimits 模型未在 config 模型注册表中登记时使用的限制，ContextWindow 为 0 时跳过预算
+	DefaultLimits config.ModelLimits
+	// ReserveTokens 预留 token 数，<=0 使用 DefaultContextReserveTokens
+	ReserveTokens int
+	// ToolResultMaxTokens 超大工具结果压缩后保留的 token 数，<=0 使用 DefaultToolResultMaxTokens
+	ToolResultMaxTokens int
+}
+
+// ContextBudgetAspect 上下文预算切面
+// 每次 LLM 调用前按模型的上下文窗口估算提示词大小，超出预算时按固定顺序裁剪：
+//  1. 压缩历史中的超大工具结果（从旧到新）
+//  2. 按轮次丢弃最旧的历史消息，工具调用与其结果不会被拆开
+//  3. 压缩当前轮次中的超大工具结果（从旧到新）
+//
+// 系统消息与当前轮次（最后一条用户消息及之后）的消息不会被丢弃。
+// 裁剪结果写入执行点元数据，并在 After 阶段传递到输出元数据。
+type ContextBudgetAspect struct {
+	order  int
+	config ContextBudgetConfig
+	logger types.Logger
+}
+
+// NewContextBudgetAspect 创建上下文预算切面
+func NewContextBudgetAspect(cfg ContextBudgetConfig, logger types.Logger) *ContextBudgetAspect {
+	if cfg.ReserveTokens <= 0 {
+		cfg.ReserveTokens = DefaultContextReserveTokens
+	}
+	if cfg.ToolResultMaxTokens <= 0 {
+		cfg.ToolResultMaxTokens = DefaultToolResultMaxTokens
+	}
+	return &ContextBudgetAspect{
+		order:  900,
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// Order 返回执行顺序，排在其他 MessageBefore 切面之后，按最终消息计算预算
+func (a *ContextBudgetAspect) Order() int {
+	return a.order
+}
+
+// New 创建切面的新实例
+func (a *ContextBudgetAspect) New() aspect.Aspect {
+	return &ContextBudgetAspect{
+		order:  a.order,
+		config: a.config,
+		logger: a.logger,
+	}
+}
+
+// PointCut 始终应用此切面
+func (a *ContextBudgetAspect) PointCut(ctx context.Context, point *aspect.AgentPoint) bool {
+	return true
+}
+
+// log 内部日志方法
+func (a *ContextBudgetAspect) log(format string, v ...interface{}) {
+	if a.logger != nil {
+		a.logger.Debugf(format, v...)
+	}
+}
+
+// BeforeLLM 按上下文预算裁剪消息，裁剪后仍超出预算时返回 CodeLLMContextTooLong
+func (a *ContextBudgetAspect) BeforeLLM(ctx context.Context, point *aspect.AgentPoint, messages []*schema.Message) ([]*schema.Message, error) {
+	model := aspect.GetLLMModel(ctx)
+	if model == "" {
+		model = point.Metadata[aspect.MetaLLMModel]
+	}
+	limits, ok := config.GetModelLimits(model)
+	if !ok {
+		limits = a.config.DefaultLimits
+	}
+	budget := limits.ContextWindow - limits.MaxOutputTokens - a.config.ReserveTokens
+	if limits.ContextWindow <= 0 || budget <= 0 {
+		return messages, nil
+	}
+
+	result, stats := FitContextBudget(messages, model, budget, a.config.ToolResultMaxTokens)
+
+	point.Metadata[aspect.MetaContextBudgetTokens] = strconv.Itoa(budget)
+	point.Metadata[aspect.MetaContextPromptTokens] = strconv.Itoa(stats.PromptTokens)
+	point.Metadata[aspect.MetaContextDroppedMessages] = strconv.Itoa(stats.DroppedMessages)
+	point.Metadata[aspect.MetaContextCondensedToolResults] = strconv.Itoa(stats.CondensedToolResults)
+	point.Metadata[aspect.MetaContextRemovedTokens] = strconv.Itoa(stats.RemovedTokens)
+
+	if stats.Changed() {
+		a.log("[ContextBudgetAspect] model=%s budget=%d: dropped %d messages, condensed %d tool results, removed %d tokens, prompt=%d",
+			model, budget, stats.DroppedMessages, stats.CondensedToolResults, stats.RemovedTokens, stats.PromptTokens)
+	}
+	if stats.PromptTokens > budget {
+		return nil, aierrors.Newf(aierrors.CodeLLMContextTooLong,
+			"prompt needs about %d tokens, context budget of model %s is %d", stats.PromptTokens, model, budget)
+	}
+	return result, nil
+}
+
+// After 将裁剪结果从执行点元数据传递到输出元数据
+func (a *ContextBudgetAspect) After(ctx context.Context, point *aspect.AgentPoint, output *aspect.AgentOutput) (*aspect.AgentOutput, error) {
+	if output == nil || point.Metadata[aspect.MetaContextBudgetTokens] == "" {
+		return output, nil
+	}
+	if output.Metadata == nil {
+		output.Metadata = make(map[string]any)
+	}
+	for _, key := range []string{
+		aspect.MetaContextBudgetTokens,
+		aspect.MetaContextPromptTokens,
+		aspect.MetaContextDroppedMessages,
+		aspect.MetaContextCondensedToolResults,
+		aspect.MetaContextRemovedTokens,
+	} {
+		output.Metadata[key] = point.Metadata[key]
+	}
+	return output, nil
+}
+
+// ContextBudgetStats 上下文预算裁剪统计
+type ContextBudgetStats struct {
+	PromptTokens         int // 裁剪后预估的提示词 token 数
+	DroppedMessages      int // 丢弃的历史消息数
+	CondensedToolResults int // 压缩的工具结果数
+	RemovedTokens        int // 移除的 token 数
+}
+
+// Changed 是否裁剪了消息
+func (s ContextBudgetStats) Changed() bool {
+	return s.DroppedMessages > 0 || s.CondensedToolResults > 0
+}
+
+// FitContextBudget 按预算裁剪消息，返回新的消息列表，不修改入参中的消息
+// 裁剪顺序固定，相同输入总是得到相同结果；无法满足预算时返回尽力裁剪后的结果，
+// 由调用方根据 PromptTokens 判断是否超出预算
+func FitContextBudget(messages []*schema.Message, model string, budget, toolResultMaxTokens int) ([]*schema.Message, ContextBudgetStats) {
+	var stats ContextBudgetStats
+	msgs := make([]*schema.Message, len(messages))
+	copy(msgs, messages)
+	tokens := make([]int, len(msgs))
+	for i, msg := range msgs {
+		tokens[i] = messageTokens(model, msg)
+		stats.PromptTokens += tokens[i]
+	}
+	if stats.PromptTokens <= budget {
+		return msgs, stats
+	}
+
+	// 当前轮次从最后一条用户消息开始，不参与丢弃
+	turnStart := 0
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == schema.User {
+			turnStart = i
+			break
+		}
+	}
+
+	condense := func(from, to int) {
+		for i := from; i < to && stats.PromptTokens > budget; i++ {
+			if msgs[i] == nil || msgs[i].Role != schema.Tool || tokens[i] <= toolResultMaxTokens+messageTokenOverhead {
+				continue
+			}
+			condensed := *msgs[i]
+			condensed.Content = condenseToolResult(model, msgs[i].Content, tokens[i]-messageTokenOverhead, toolResultMaxTokens)
+			n := messageTokens(model, &condensed)
+			if n >= tokens[i] {
+				continue
+			}
+			msgs[i] = &condensed
+			stats.PromptTokens -= tokens[i] - n
+			stats.RemovedTokens += tokens[i] - n
+			stats.CondensedToolResults++
+			tokens[i] = n
+		}
+	}
+
+	// 1. 压缩历史中的超大工具结果
+	condense(0, turnStart)
+
+	// 2. 从旧到新按轮次丢弃历史消息（用户消息及其后的助手回复、工具调用与结果），
+	// 工具调用与结果不会被拆开，剩余历史也总是从用户消息开始
+	for i := 0; i < turnStart && stats.PromptTokens > budget; {
+		if msgs[i].Role == schema.System {
+			i++
+			continue
+		}
+		end := i + 1
+		for end < turnStart && msgs[end].Role != schema.User && msgs[end].Role != schema.System {
+			end++
+		}
+		for j := i; j < end; j++ {
+			stats.PromptTokens -= tokens[j]
+			stats.RemovedTokens += tokens[j]
+			stats.DroppedMessages++
+			msgs[j] = nil
+		}
+		i = end
+	}
+
+	// 3. 压缩当前轮次中的超大工具结果
+	condense(turnStart, len(msgs))
+
+	result := make([]*schema.Message, 0, len(msgs)-stats.DroppedMessages)
+	for _, msg := range msgs {
+		if msg != nil {
+			result = append(result, msg)
+		}
+	}
+	return result, stats
+}
+
+// messageTokens 估算单条消息的 token 数
+func messageTokens(model string, msg *schema.Message) int {
+	n := messageTokenOverhead + token.CountTokens(model, msg.Content) + token.CountTokens(model, msg.ReasoningContent)
+	for _, tc := range msg.ToolCalls {
+		n += token.CountTokens(model, tc.Function.Name) + token.CountTokens(model, tc.Function.Arguments)
+	}
+	for _, part := range msg.UserInputMultiContent {
+		switch part.Type {
+		case schema.ChatMessagePartTypeText:
+			n += token.CountTokens(model, part.Text)
+		case schema.ChatMessagePartTypeImageURL:
+			n += imageTokenEstimate
+		}
+	}
+	return n
+}
+
+// condenseToolResult 按比例保留工具结果开头部分，并注明被移除的 token 数
+func condenseToolResult(model, content string, contentTokens, maxTokens int) string {
+	runes := []rune(content)
+	keep := len(runes) * maxTokens / contentTokens
+	head := string(runes[:keep])
+	removed := contentTokens - token.CountTokens(model, head)
+	return head + fmt.Sprintf("\n...[truncated by context budget: about %d tokens removed]", removed)
+}
@@ -0,0 +1,143 @@
+package builtin
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/rulego/rulego-components-ai/aspect"
+	"github.com/rulego/rulego-components-ai/config"
+	aierrors "github.com/rulego/rulego-components-ai/errors"
+)
+
+// budgetTestMessages 构造：系统消息 + 两轮历史（第二轮带大工具结果）+ 当前轮次
+func budgetTestMessages() []*schema.Message {
+	big := strings.Repeat("log line ", 2000)
+	return []*schema.Message{
+		schema.SystemMessage("you are a helpful assistant"),
+		schema.UserMessage("first question " + strings.Repeat("a ", 200)),
+		schema.AssistantMessage("first answer "+strings.Repeat("b ", 200), nil),
+		schema.UserMessage("check the logs"),
+		schema.AssistantMessage("", []schema.ToolCall{{ID: "c1", Function: schema.FunctionCall{Name: "bash", Arguments: `{"command":"cat app.log"}`}}}),
+		schema.ToolMessage(big, "c1"),
+		schema.AssistantMessage("logs look fine", nil),
+		schema.UserMessage("and now?"),
+	}
+}
+
+func roles(msgs []*schema.Message) string {
+	var sb strings.Builder
+	for _, m := range msgs {
+		sb.WriteString(string(m.Role)[:1])
+	}
+	return sb.String()
+}
+
+func TestFitContextBudget(t *testing.T) {
+	msgs := budgetTestMessages()
+	original := msgs[5].Content
+
+	t.Run("within budget", func(t *testing.T) {
+		got, stats := FitContextBudget(msgs, "", 1<<20, 100)
+		if stats.Changed() || len(got) != len(msgs) {
+			t.Errorf("messages within budget should not change, stats=%+v", stats)
+		}
+	})
+
+	t.Run("condense history tool result first", func(t *testing.T) {
+		_, full := FitContextBudget(msgs, "", 1<<20, 100)
+		got, stats := FitContextBudget(msgs, "", full.PromptTokens-1000, 100)
+		if stats.CondensedToolResults != 1 || stats.DroppedMessages != 0 {
+			t.Fatalf("expected only the tool result to be condensed, stats=%+v", stats)
+		}
+		if !strings.Contains(got[5].Content, "truncated by context budget") {
+			t.Errorf("condensed tool result should be marked, got %q", got[5].Content[len(got[5].Content)-80:])
+		}
+		if msgs[5].Content != original {
+			t.Error("input messages must not be modified")
+		}
+		if stats.PromptTokens != full.PromptTokens-stats.RemovedTokens {
+			t.Errorf("prompt tokens %d inconsistent with removed %d", stats.PromptTokens, stats.RemovedTokens)
+		}
+	})
+
+	t.Run("drop oldest history rounds", func(t *testing.T) {
+		got, stats := FitContextBudget(msgs, "", 120, 100)
+		// 系统消息与当前轮次保留，历史按轮次整体丢弃
+		if roles(got) != "su" {
+			t.Errorf("unexpected remaining roles %s", roles(got))
+		}
+		if stats.DroppedMessages != 6 {
+			t.Errorf("expected 6 dropped messages, got %+v", stats)
+		}
+		again, againStats := FitContextBudget(msgs, "", 120, 100)
+		if roles(again) != roles(got) || againStats != stats {
+			t.Error("budgeting must be deterministic")
+		}
+	})
+
+	t.Run("keeps tool call pairs intact", func(t *testing.T) {
+		_, full := FitContextBudget(msgs, "", 1<<20, 100)
+		first := messageTokens("", msgs[1]) + messageTokens("", msgs[2])
+		got, _ := FitContextBudget(msgs, "", full.PromptTokens-first, 1<<20)
+		if roles(got) != "suatau" {
+			t.Errorf("only the first round should be dropped, got %s", roles(got))
+		}
+		for i, m := range got {
+			if m.Role == schema.Tool && (i == 0 || len(got[i-1].ToolCalls) == 0) {
+				t.Errorf("tool result at %d lost its tool call", i)
+			}
+		}
+	})
+
+	t.Run("condense current turn tool results last", func(t *testing.T) {
+		turn := []*schema.Message{
+			schema.SystemMessage("sys"),
+			schema.UserMessage("check the logs"),
+			schema.AssistantMessage("", []schema.ToolCall{{ID: "c1", Function: schema.FunctionCall{Name: "bash", Arguments: `{}`}}}),
+			schema.ToolMessage(strings.Repeat("log line ", 2000), "c1"),
+		}
+		got, stats := FitContextBudget(turn, "", 500, 200)
+		if len(got) != 4 || stats.CondensedToolResults != 1 || stats.PromptTokens > 500 {
+			t.Errorf("expected current turn tool result to be condensed, stats=%+v", stats)
+		}
+	})
+}
+
+func TestContextBudgetAspect_BeforeLLM(t *testing.T) {
+	config.RegisterModelLimits("budget-test-model", config.ModelLimits{ContextWindow: 2000, MaxOutputTokens: 500})
+	defer config.UnregisterModelCapabilities("budget-test-model")
+
+	a := NewContextBudgetAspect(ContextBudgetConfig{ReserveTokens: 100, ToolResultMaxTokens: 100}, nil)
+	point := &aspect.AgentPoint{Metadata: map[string]string{aspect.MetaLLMModel: "budget-test-model"}}
+
+	got, err := a.BeforeLLM(context.Background(), point, budgetTestMessages())
+	if err != nil {
+		t.Fatalf("BeforeLLM() error = %v", err)
+	}
+	if got[5].Content == budgetTestMessages()[5].Content {
+		t.Error("expected oversized tool result to be condensed")
+	}
+	if point.Metadata[aspect.MetaContextBudgetTokens] != "1400" || point.Metadata[aspect.MetaContextCondensedToolResults] != "1" {
+		t.Errorf("unexpected budget metadata %v", point.Metadata)
+	}
+
+	output, _ := a.After(context.Background(), point, &aspect.AgentOutput{})
+	if output.Metadata[aspect.MetaContextDroppedMessages] != point.Metadata[aspect.MetaContextDroppedMessages] {
+		t.Errorf("budget metadata not transferred to output: %v", output.Metadata)
+	}
+
+	// 当前轮次本身超出预算
+	huge := []*schema.Message{schema.UserMessage(strings.Repeat("word ", 10000))}
+	if _, err := a.BeforeLLM(context.Background(), point, huge); !aierrors.IsCode(err, aierrors.CodeLLMContextTooLong) {
+		t.Errorf("expected CodeLLMContextTooLong, got %v", err)
+	}
+
+	// 未登记的模型且无默认限制时跳过
+	point = &aspect.AgentPoint{Metadata: map[string]string{aspect.MetaLLMModel: "unknown-model"}}
+	got, err = a.BeforeLLM(context.Background(), point, huge)
+	if err != nil || len(got) != 1 || point.Metadata[aspect.MetaContextBudgetTokens] != "" {
+		t.Errorf("unknown model should be passed through, err=%v", err)
+	}
+}
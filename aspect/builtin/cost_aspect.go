@@ -0,0 +1,197 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package builtin
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rulego/rulego-components-ai/aspect"
+	"github.com/rulego/rulego-components-ai/config"
+	aierrors "github.com/rulego/rulego-components-ai/errors"
+	"github.com/rulego/rulego-components-ai/session"
+	"github.com/rulego/rulego/api/types"
+)
+
+// CostAspect 费用记账与预算切面
+// Before 阶段检查用户/会话/智能体预算：hard 预算用尽时拒绝请求（CodeBudgetExceeded），
+// soft 预算用尽时通过 session_model 切换到降级模型（由 DynamicModelWrapper 生效）。
+// OnCompleted 阶段按模型价格为本次执行的每次 LLM 调用计费并写入用量账本，执行失败时同样记账。
+// 顺序排在 SessionAspect 之后，会话 key 与会话模型已确定。
+type CostAspect struct {
+	order   int
+	ledger  session.UsageLedger
+	budgets []session.Budget
+	logger  types.Logger
+	now     func() time.Time
+}
+
+// NewCostAspect 创建费用记账与预算切面
+// ledger 通常为 session.Manager.UsageLedger()，budgets 为空时只记账
+func NewCostAspect(ledger session.UsageLedger, budgets []session.Budget, logger types.Logger) *CostAspect {
+	return &CostAspect{
+		order:   60,
+		ledger:  ledger,
+		budgets: budgets,
+		logger:  logger,
+		now:     time.Now,
+	}
+}
+
+// Order 返回执行顺序
+func (a *CostAspect) Order() int {
+	return a.order
+}
+
+// New 创建切面的新实例
+func (a *CostAspect) New() aspect.Aspect {
+	return &CostAspect{
+		order:   a.order,
+		ledger:  a.ledger,
+		budgets: a.budgets,
+		logger:  a.logger,
+		now:     a.now,
+	}
+}
+
+// PointCut 配置了账本时应用此切面
+func (a *CostAspect) PointCut(ctx context.Context, point *aspect.AgentPoint) bool {
+	return a.ledger != nil
+}
+
+// log 内部日志方法
+func (a *CostAspect) log(format string, v ...interface{}) {
+	if a.logger != nil {
+		a.logger.Debugf(format, v...)
+	}
+}
+
+// Before 检查预算
+func (a *CostAspect) Before(ctx context.Context, point *aspect.AgentPoint, input *aspect.AgentInput) (*aspect.AgentInput, error) {
+	if point.Metadata == nil {
+		point.Metadata = make(map[string]string)
+	}
+	if input.SessionKey != "" {
+		point.Metadata[aspect.MetaSessionKey] = input.SessionKey
+	}
+	if len(a.budgets) == 0 {
+		return input, nil
+	}
+
+	subject := a.subject(point, input.SessionKey)
+	decision, err := session.CheckBudgets(ctx, a.ledger, a.budgets, subject, a.now())
+	if err != nil {
+		// 账本不可用时不阻断请求
+		a.log("[CostAspect] Before: check budgets failed: %v", err)
+		return input, nil
+	}
+	if len(decision.Exceeded) == 0 {
+		return input, nil
+	}
+
+	exceeded := make([]string, 0, len(decision.Exceeded))
+	for _, s := range decision.Exceeded {
+		exceeded = append(exceeded, string(s.Budget.Scope)+":"+string(s.Budget.Period))
+	}
+	point.Metadata[aspect.MetaBudgetExceeded] = strings.Join(exceeded, ",")
+
+	if decision.Refuse {
+		a.log("[CostAspect] Before: budget exhausted for user=%s session=%s agent=%s: %s",
+			subject.UserID, subject.SessionKey, subject.AgentID, point.Metadata[aspect.MetaBudgetExceeded])
+		return input, aierrors.BudgetExceeded(point.Metadata[aspect.MetaBudgetExceeded])
+	}
+	if decision.DowngradeModel != "" {
+		if input.Metadata == nil {
+			input.Metadata = make(map[string]string)
+		}
+		input.Metadata[aspect.MetaSessionModel] = decision.DowngradeModel
+		point.Metadata[aspect.MetaBudgetDowngradeModel] = decision.DowngradeModel
+		a.log("[CostAspect] Before: soft budget exhausted (%s), downgrade to model=%s",
+			point.Metadata[aspect.MetaBudgetExceeded], decision.DowngradeModel)
+	}
+	return input, nil
+}
+
+// OnCompleted 为本次执行的 LLM 调用计费并记账
+func (a *CostAspect) OnCompleted(ctx context.Context, point *aspect.AgentPoint, output *aspect.AgentOutput) {
+	calls := aspect.GetLLMUsagesFromContext(ctx)
+	// 模型未经包装器调用（无逐次用量）时，退化为按输出的汇总用量记账
+	if len(calls) == 0 && output != nil && output.TokenUsage.TotalTokens > 0 {
+		calls = []aspect.LLMCallUsage{{Model: point.Metadata[aspect.MetaLLMModel], Usage: output.TokenUsage}}
+	}
+	if len(calls) == 0 {
+		return
+	}
+
+	sessionKey := point.Metadata[aspect.MetaSessionKey]
+	if output != nil && output.SessionKey != "" {
+		sessionKey = output.SessionKey
+	}
+	subject := a.subject(point, sessionKey)
+	now := a.now()
+
+	var total float64
+	for _, call := range calls {
+		pricing, _ := config.GetModelPricing(call.Model)
+		cost := pricing.Cost(call.Usage.PromptTokens, call.Usage.CompletionTokens, call.Usage.CachedTokens)
+		total += cost
+		entry := &session.UsageEntry{
+			AgentID:          subject.AgentID,
+			UserID:           subject.UserID,
+			SessionKey:       subject.SessionKey,
+			Model:            call.Model,
+			PromptTokens:     int64(call.Usage.PromptTokens),
+			CompletionTokens: int64(call.Usage.CompletionTokens),
+			CachedTokens:     int64(call.Usage.CachedTokens),
+			Cost:             cost,
+			Time:             now,
+		}
+		if err := a.ledger.Record(ctx, entry); err != nil {
+			a.log("[CostAspect] OnCompleted: record usage failed: %v", err)
+		}
+	}
+
+	if output != nil {
+		if output.Metadata == nil {
+			output.Metadata = make(map[string]any)
+		}
+		output.Metadata[aspect.MetaCost] = strconv.FormatFloat(total, 'f', -1, 64)
+		for _, key := range []string{aspect.MetaBudgetExceeded, aspect.MetaBudgetDowngradeModel} {
+			if v := point.Metadata[key]; v != "" {
+				output.Metadata[key] = v
+			}
+		}
+	}
+}
+
+// subject 构建预算检查对象，用户 ID 依次取执行点用户、userId、im.userId 元数据
+func (a *CostAspect) subject(point *aspect.AgentPoint, sessionKey string) session.BudgetSubject {
+	userID := point.UserId
+	if userID == "" {
+		userID = point.Metadata[aspect.MetaUserID]
+	}
+	if userID == "" {
+		userID = point.Metadata[aspect.MetaIMUserID]
+	}
+	return session.BudgetSubject{
+		AgentID:    point.AgentId,
+		UserID:     userID,
+		SessionKey: sessionKey,
+	}
+}
@@ -0,0 +1,117 @@
+package builtin
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rulego/rulego-components-ai/aspect"
+	"github.com/rulego/rulego-components-ai/config"
+	aierrors "github.com/rulego/rulego-components-ai/errors"
+	"github.com/rulego/rulego-components-ai/session"
+)
+
+func TestCostAspect_RecordsPricedUsage(t *testing.T) {
+	config.RegisterModelPricing("cost-test-model", config.ModelPricing{Input: 2, Output: 8, CachedInput: 0.5})
+	defer config.UnregisterModelCapabilities("cost-test-model")
+
+	ledger := session.NewMemoryUsageLedger()
+	a := NewCostAspect(ledger, nil, nil)
+	point := &aspect.AgentPoint{AgentId: "agent", UserId: "alice", Metadata: map[string]string{}}
+	input := &aspect.AgentInput{SessionKey: "s1", Metadata: map[string]string{}}
+	if _, err := a.Before(context.Background(), point, input); err != nil {
+		t.Fatalf("Before() error = %v", err)
+	}
+
+	collector := aspect.NewLLMUsageCollector()
+	collector.Add(aspect.LLMCallUsage{Model: "cost-test-model", Usage: aspect.TokenUsage{PromptTokens: 1_000_000, CompletionTokens: 100_000, CachedTokens: 500_000}})
+	collector.Add(aspect.LLMCallUsage{Model: "unpriced-model", Usage: aspect.TokenUsage{PromptTokens: 10, CompletionTokens: 1}})
+	ctx := aspect.WithLLMUsageCollector(context.Background(), collector)
+
+	// 执行失败时 output 不带会话 key，使用 Before 阶段记录的会话 key
+	output := &aspect.AgentOutput{IsSuccess: false}
+	a.OnCompleted(ctx, point, output)
+
+	records, _ := ledger.Query(context.Background(), &session.UsageQuery{UserID: "alice", SessionKey: "s1", AgentID: "agent"})
+	if len(records) != 2 {
+		t.Fatalf("expected one record per model, got %d", len(records))
+	}
+	// 0.5M 未缓存输入 * 2 + 0.5M 缓存输入 * 0.5 + 0.1M 输出 * 8 = 1 + 0.25 + 0.8
+	total := session.SumUsage(records)
+	if math.Abs(total.Cost-2.05) > 1e-9 || total.Calls != 2 {
+		t.Errorf("unexpected ledger total %+v", total)
+	}
+	if output.Metadata[aspect.MetaCost] != "2.05" {
+		t.Errorf("cost metadata = %v", output.Metadata[aspect.MetaCost])
+	}
+}
+
+func TestCostAspect_FallsBackToOutputUsage(t *testing.T) {
+	ledger := session.NewMemoryUsageLedger()
+	a := NewCostAspect(ledger, nil, nil)
+	point := &aspect.AgentPoint{AgentId: "agent", Metadata: map[string]string{aspect.MetaLLMModel: "m", aspect.MetaIMUserID: "im-user"}}
+	a.OnCompleted(context.Background(), point, &aspect.AgentOutput{
+		SessionKey: "s2",
+		TokenUsage: aspect.TokenUsage{PromptTokens: 5, CompletionTokens: 5, TotalTokens: 10},
+	})
+	records, _ := ledger.Query(context.Background(), nil)
+	if len(records) != 1 || records[0].UserID != "im-user" || records[0].SessionKey != "s2" || records[0].Model != "m" {
+		t.Errorf("unexpected records %+v", records)
+	}
+}
+
+func TestCostAspect_Budgets(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 5, 20, 8, 0, 0, 0, time.UTC)
+	ledger := session.NewMemoryUsageLedger()
+	_ = ledger.Record(ctx, &session.UsageEntry{AgentID: "agent", UserID: "alice", SessionKey: "s1", Model: "m", Cost: 10, Time: now})
+
+	newAspect := func(budgets ...session.Budget) *CostAspect {
+		a := NewCostAspect(ledger, budgets, nil)
+		a.now = func() time.Time { return now }
+		return a
+	}
+	newRun := func(user string) (*aspect.AgentPoint, *aspect.AgentInput) {
+		return &aspect.AgentPoint{AgentId: "agent", UserId: user, Metadata: map[string]string{}},
+			&aspect.AgentInput{SessionKey: "s-" + user, Metadata: map[string]string{aspect.MetaSessionModel: "big-model"}}
+	}
+
+	t.Run("hard budget refuses", func(t *testing.T) {
+		a := newAspect(session.Budget{Scope: session.BudgetScopeUser, Period: session.BudgetPeriodDaily, Limit: 5, Mode: session.BudgetModeHard})
+		point, input := newRun("alice")
+		_, err := a.Before(ctx, point, input)
+		if !aierrors.IsCode(err, aierrors.CodeBudgetExceeded) {
+			t.Fatalf("expected CodeBudgetExceeded, got %v", err)
+		}
+		if point.Metadata[aspect.MetaBudgetExceeded] != "user:daily" {
+			t.Errorf("exceeded metadata = %q", point.Metadata[aspect.MetaBudgetExceeded])
+		}
+	})
+
+	t.Run("soft budget downgrades model", func(t *testing.T) {
+		a := newAspect(session.Budget{Scope: session.BudgetScopeUser, Period: session.BudgetPeriodMonthly, Limit: 5, Mode: session.BudgetModeSoft, DowngradeModel: "small-model"})
+		point, input := newRun("alice")
+		input, err := a.Before(ctx, point, input)
+		if err != nil {
+			t.Fatalf("Before() error = %v", err)
+		}
+		if input.Metadata[aspect.MetaSessionModel] != "small-model" {
+			t.Errorf("session model = %q, want small-model", input.Metadata[aspect.MetaSessionModel])
+		}
+		output := &aspect.AgentOutput{}
+		a.OnCompleted(aspect.WithLLMUsageCollector(ctx, aspect.NewLLMUsageCollector()), point, output)
+		if output.Metadata != nil {
+			t.Errorf("no metadata expected without llm calls, got %v", output.Metadata)
+		}
+	})
+
+	t.Run("other user unaffected", func(t *testing.T) {
+		a := newAspect(session.Budget{Scope: session.BudgetScopeUser, Period: session.BudgetPeriodDaily, Limit: 5, Mode: session.BudgetModeHard})
+		point, input := newRun("bob")
+		input, err := a.Before(ctx, point, input)
+		if err != nil || input.Metadata[aspect.MetaSessionModel] != "big-model" {
+			t.Errorf("bob should not be limited, err=%v", err)
+		}
+	})
+}
@@ -8,10 +8,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 	"github.com/rulego/rulego-components-ai/aspect"
 	"github.com/rulego/rulego-components-ai/session"
 	imageutil "github.com/rulego/rulego-components-ai/utils/image"
+	"github.com/rulego/rulego-components-ai/utils/token"
 	"github.com/rulego/rulego/api/types"
 )
 
@@ -21,31 +23,25 @@ var imagePathMarkerRegexp = regexp.MustCompile(`\[图片：[^\]]*\]\n?`)
 // imageTokenEstimate 每张图片估算的 token 数
 const imageTokenEstimate = 85
 
-// estimateTokenCount 估算文本的 token 数量
-// 使用简单的估算算法：区分中文和英文
-func estimateTokenCount(text string) int {
-	if len(text) == 0 {
-		return 0
-	}
+// estimateTokenCount 按模型的分词器估算文本的 token 数量
+// 模型未注册分词器时使用默认分词器，未加载分词器时退化为中英文区分的启发式估算
+func estimateTokenCount(model, text string) int {
+	return token.CountTokens(model, text)
+}
 
-	charCount := 0
-	chineseCount := 0
-	for _, r := range text {
-		charCount++
-		if r >= 0x4E00 && r <= 0x9FFF {
-			chineseCount++
-		}
+// tokenModel 返回计算 token 使用的模型：优先取本次执行最近一次 LLM 调用实际使用的模型，
+// 其次为会话级模型，最后为执行点记录的节点模型
+func tokenModel(ctx context.Context, metadata map[string]string) string {
+	if calls := aspect.GetLLMUsagesFromContext(ctx); len(calls) > 0 && calls[len(calls)-1].Model != "" {
+		return calls[len(calls)-1].Model
 	}
-
-	// 中文：约 1.5 字符/token (即 2/3 token/字符)
-	// 英文/其他：约 4 字符/token (即 1/4 token/字符)
-	nonChineseCount := charCount - chineseCount
-	estimatedTokens := chineseCount*2/3 + nonChineseCount/4
-
-	if estimatedTokens < 1 {
-		estimatedTokens = 1
+	if model := metadata[aspect.MetaSessionModel]; model != "" {
+		return model
+	}
+	if point := aspect.GetAgentPoint(ctx); point != nil {
+		return point.Metadata[aspect.MetaLLMModel]
 	}
-	return estimatedTokens
+	return ""
 }
 
 // filterImageURLs 过滤图片列表
@@ -97,6 +93,14 @@ func (a *SessionAspect) New() aspect.Aspect {
 	}
 }
 
+// SetCompactionModel 使用 chatModel 生成会话压缩摘要（需会话配置启用 CompactionConfig）
+// 会话管理器为 *session.Manager 时包装为 CompactingManager，其它管理器保持不变
+func (a *SessionAspect) SetCompactionModel(chatModel model.BaseChatModel) {
+	if mgr, ok := a.sessionMgr.(*session.Manager); ok && chatModel != nil {
+		a.sessionMgr = session.WrapCompactingManager(mgr, chatModel)
+	}
+}
+
 // PointCut 检查是否应用此切面
 func (a *SessionAspect) PointCut(ctx context.Context, point *aspect.AgentPoint) bool {
 	return a.sessionMgr != nil
@@ -158,6 +162,18 @@ func (a *SessionAspect) Before(ctx context.Context, point *aspect.AgentPoint, in
 		return input, nil
 	}
 
+	// 修剪已存储的工具结果和较早的工具调用，减少后续加载的历史
+	if pruner, ok := a.sessionMgr.(session.PruningManager); ok {
+		if pruned, err := pruner.PruneIfNeeded(ctx, sess.Key); pruned {
+			a.log("[SessionAspect] Before: pruned session=%s", sess.Key)
+			if updatedSess, err := a.sessionMgr.Get(ctx, sess.Key); err == nil {
+				sess = updatedSess
+			}
+		} else if err != nil {
+			a.log("[SessionAspect] Before: pruning failed: %v", err)
+		}
+	}
+
 	// 智能保护: 检查是否需要压缩
 	if a.shouldAutoCompact(sess) {
 		a.log("[SessionAspect] Before: auto-triggering compaction for session=%s (tokens=%d, messages=%d)",
@@ -190,10 +206,16 @@ func (a *SessionAspect) Before(ctx context.Context, point *aspect.AgentPoint, in
 		return input, nil
 	}
 
+	// 已压缩进摘要的消息不再加载，由摘要消息代替
+	history = session.ActiveMessages(history)
 	// 过滤工具调用消息：只保留最近的 N 条
 	history = filterRecentToolCalls(history, keepToolCallsCount)
 	// 转换为 schema.Message
 	input.HistoryMessages = convertSessionMessagesToSchema(history)
+	if sess.CompactedSummary != "" {
+		input.HistoryMessages = append([]*schema.Message{buildCompactedSummaryMessage(sess.CompactedSummary)}, input.HistoryMessages...)
+		a.log("[SessionAspect] Before: injected compacted summary, length=%d", len(sess.CompactedSummary))
+	}
 	// 将最近历史消息中的本地图片文件路径转为 base64，确保 LLM API 可读取
 	converted, total := convertRecentHistoryImagesToBase64(input.HistoryMessages)
 	if total > 0 {
@@ -209,8 +231,12 @@ func (a *SessionAspect) Before(ctx context.Context, point *aspect.AgentPoint, in
 }
 
 // shouldAutoCompact 检查是否应该自动压缩
-// 安全阈值策略：当消息数 >= 10 且 token 数 >= 100000 时触发
+// 启用了 CompactionConfig 时按配置阈值判断，
+// 否则使用安全阈值策略：当消息数 >= 10 且 token 数 >= 100000 时触发
 func (a *SessionAspect) shouldAutoCompact(sess *session.Session) bool {
+	if config := a.sessionMgr.GetConfig(); config != nil && config.CompactionConfig != nil && config.CompactionConfig.Enabled {
+		return sess.ShouldCompact(config.CompactionConfig)
+	}
 	if sess.Metadata.MessageCount < 10 {
 		return false
 	}
@@ -244,19 +270,20 @@ func (a *SessionAspect) After(ctx context.Context, point *aspect.AgentPoint, out
 	}
 
 	// 用户消息已在 Before() 中预存，After() 只保存助手回复和工具调用
-	userTokenCount := a.estimateUserTokenCount(output.OriginalMessages)
+	model := tokenModel(ctx, nil)
+	userTokenCount := a.estimateUserTokenCount(model, output.OriginalMessages)
 
 	messageCount := 0
 	totalEstimatedTokens := userTokenCount
 
 	// 保存工具调用和结果
-	tcCount, tcTokens := a.saveToolCallMessages(ctx, sessionKey, output.ToolCalls)
+	tcCount, tcTokens := a.saveToolCallMessages(ctx, sessionKey, model, output.ToolCalls)
 	messageCount += tcCount
 	totalEstimatedTokens += tcTokens
 
 	// 保存助手最终回复
 	if output.Content != "" {
-		assistantTokenCount := estimateTokenCount(output.Content)
+		assistantTokenCount := estimateTokenCount(model, output.Content)
 		assistantMsg := &session.SessionMessage{
 			ID:         session.GenerateMessageID(),
 			Role:       string(schema.Assistant),
@@ -280,7 +307,7 @@ func (a *SessionAspect) After(ctx context.Context, point *aspect.AgentPoint, out
 }
 
 // saveToolCallMessages 保存工具调用和结果消息，返回消息数量和估算 token 数
-func (a *SessionAspect) saveToolCallMessages(ctx context.Context, sessionKey string, toolCalls []aspect.ToolCallResult) (messageCount, totalTokens int) {
+func (a *SessionAspect) saveToolCallMessages(ctx context.Context, sessionKey, model string, toolCalls []aspect.ToolCallResult) (messageCount, totalTokens int) {
 	saveToolCalls := true
 	if config := a.sessionMgr.GetConfig(); config != nil && config.PruningConfig != nil {
 		saveToolCalls = config.PruningConfig.SaveToolCalls
@@ -298,7 +325,7 @@ func (a *SessionAspect) saveToolCallMessages(ctx context.Context, sessionKey str
 				Name:      tc.Name,
 				Arguments: processedArgs,
 			})
-			toolCallsTokenCount += estimateTokenCount(tc.Name) + estimateTokenCount(processedArgs)
+			toolCallsTokenCount += estimateTokenCount(model, tc.Name) + estimateTokenCount(model, processedArgs)
 		}
 		assistantToolCallMsg := &session.SessionMessage{
 			ID:         session.GenerateMessageID(),
@@ -323,7 +350,7 @@ func (a *SessionAspect) saveToolCallMessages(ctx context.Context, sessionKey str
 				toolResult = fmt.Sprintf("Error: %v", tc.Error)
 			}
 			toolResult = session.ProcessToolResult(toolResult)
-			toolResultTokenCount := estimateTokenCount(toolResult)
+			toolResultTokenCount := estimateTokenCount(model, toolResult)
 
 			toolMsg := &session.SessionMessage{
 				ID:         session.GenerateMessageID(),
@@ -350,13 +377,13 @@ func (a *SessionAspect) saveToolCallMessages(ctx context.Context, sessionKey str
 }
 
 // estimateUserTokenCount 从 OriginalMessages 估算用户消息的 token 数
-func (a *SessionAspect) estimateUserTokenCount(messages []*schema.Message) int {
+func (a *SessionAspect) estimateUserTokenCount(model string, messages []*schema.Message) int {
 	for i := len(messages) - 1; i >= 0; i-- {
 		msg := messages[i]
 		if msg.Role != schema.User {
 			continue
 		}
-		tokenCount := estimateTokenCount(msg.Content)
+		tokenCount := estimateTokenCount(model, msg.Content)
 		if extraImages := extractImagesFromExtra(msg.Extra); len(extraImages) > 0 {
 			tokenCount += len(extraImages) * imageTokenEstimate
 		} else if len(msg.UserInputMultiContent) > 0 {
@@ -641,6 +668,11 @@ func convertSessionMessagesToSchema(msgs []*session.SessionMessage) []*schema.Me
 	return result
 }
 
+// buildCompactedSummaryMessage 构建注入历史的会话摘要消息
+func buildCompactedSummaryMessage(summary string) *schema.Message {
+	return schema.SystemMessage(session.CompactedSummaryPrefix + summary)
+}
+
 // extractImagesFromExtra 从消息的 Extra 字段提取原始图片引用（本地路径或 URL）
 func extractImagesFromExtra(extra map[string]any) []string {
 	if extra == nil {
@@ -666,6 +698,11 @@ func (a *SessionAspect) saveUserMessageBeforeLLM(ctx context.Context, input *asp
 	if len(input.Messages) == 0 {
 		return
 	}
+	// 审批决定消息由 ApprovalAspect 记录到审批记录中，不作为用户对话保存
+	if input.Metadata[aspect.MetaApprovalDecision] != "" {
+		a.log("[SessionAspect] saveUserMessageBeforeLLM: approval decision message, skipping")
+		return
+	}
 	for i := len(input.Messages) - 1; i >= 0; i-- {
 		msg := input.Messages[i]
 		if msg.Role != schema.User {
@@ -708,7 +745,7 @@ func (a *SessionAspect) saveUserMessageBeforeLLM(ctx context.Context, input *asp
 		}
 
 		savedImages := filterImageURLs(userImages)
-		userTokenCount := estimateTokenCount(userContent) + len(savedImages)*imageTokenEstimate
+		userTokenCount := estimateTokenCount(tokenModel(ctx, input.Metadata), userContent) + len(savedImages)*imageTokenEstimate
 
 		userMsg := &session.SessionMessage{
 			ID:         session.GenerateMessageID(),
@@ -700,7 +700,7 @@ func TestEstimateTokenCount(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := estimateTokenCount(tt.text)
+			got := estimateTokenCount("", tt.text)
 			if got < tt.minToken || got > tt.maxToken {
 				t.Errorf("estimateTokenCount(%q) = %d, want between %d and %d", tt.text, got, tt.minToken, tt.maxToken)
 			}
@@ -708,6 +708,31 @@ func TestEstimateTokenCount(t *testing.T) {
 	}
 }
 
+// TestTokenModel 测试计算 token 使用的模型优先级
+func TestTokenModel(t *testing.T) {
+	ctx := context.Background()
+	if got := tokenModel(ctx, nil); got != "" {
+		t.Errorf("tokenModel() = %q, want empty", got)
+	}
+
+	ctx = aspect.WithAgentPoint(ctx, &aspect.AgentPoint{Metadata: map[string]string{aspect.MetaLLMModel: "node-model"}})
+	if got := tokenModel(ctx, nil); got != "node-model" {
+		t.Errorf("tokenModel() = %q, want node-model", got)
+	}
+	metadata := map[string]string{aspect.MetaSessionModel: "session-model"}
+	if got := tokenModel(ctx, metadata); got != "session-model" {
+		t.Errorf("tokenModel() = %q, want session-model", got)
+	}
+
+	collector := aspect.NewLLMUsageCollector()
+	collector.Add(aspect.LLMCallUsage{Model: "first-model"})
+	collector.Add(aspect.LLMCallUsage{Model: "last-model"})
+	ctx = aspect.WithLLMUsageCollector(ctx, collector)
+	if got := tokenModel(ctx, metadata); got != "last-model" {
+		t.Errorf("tokenModel() = %q, want last-model", got)
+	}
+}
+
 // TestStripImagePathMarkers 测试清理图片路径标记
 func TestStripImagePathMarkers(t *testing.T) {
 	tests := []struct {
@@ -1072,6 +1097,33 @@ func TestSaveUserMessageBeforeLLM(t *testing.T) {
 			t.Fatal("expected user message to be saved in history")
 		}
 	})
+
+	t.Run("审批决定消息", func(t *testing.T) {
+		input := &aspect.AgentInput{
+			Messages: []*schema.Message{
+				{
+					Role:    schema.User,
+					Content: "approve",
+				},
+			},
+			Metadata: map[string]string{
+				aspect.MetaApprovalDecision: string(session.DecisionApprove),
+				aspect.MetaApprovalRunID:    "run-1",
+			},
+		}
+
+		aspectInstance.saveUserMessageBeforeLLM(ctx, input, sess.Key)
+
+		history, err := manager.GetHistory(ctx, sess.Key, 10)
+		if err != nil {
+			t.Fatalf("GetHistory() error = %v", err)
+		}
+		for _, msg := range history {
+			if msg.Content == "approve" {
+				t.Fatal("approval decision must not be saved as a user turn")
+			}
+		}
+	})
 }
 
 // TestSaveUserMessageBeforeLLM_WithImages 测试用户消息带图片的保存
@@ -1408,3 +1460,52 @@ func TestFilterRecentToolCallsDropEmptyAssistant(t *testing.T) {
 		}
 	}
 }
+
+// TestSessionAspectBeforeInjectsCompactedSummary 已压缩的消息不再加载，摘要作为系统消息注入历史开头
+func TestSessionAspectBeforeInjectsCompactedSummary(t *testing.T) {
+	ctx := context.Background()
+	manager := session.NewManager(session.NewMemoryStorage(), nil)
+	req := session.SessionRequest{
+		AgentID: "agent-compact",
+		Channel: "default",
+		Scope:   session.ScopePerPeer,
+		ScopeID: "peer-compact",
+	}
+	sess, err := manager.GetOrCreate(ctx, req)
+	if err != nil {
+		t.Fatalf("GetOrCreate() error = %v", err)
+	}
+	sess.Messages = []*session.SessionMessage{
+		{ID: "m1", Role: string(schema.User), Content: "旧问题", IsCompacted: true},
+		{ID: "m2", Role: string(schema.Assistant), Content: "旧回答", IsCompacted: true},
+		{ID: "m3", Role: string(schema.User), Content: "新问题"},
+		{ID: "m4", Role: string(schema.Assistant), Content: "新回答"},
+	}
+	sess.CompactedSummary = "用户此前询问了旧问题"
+	if err := manager.Update(ctx, sess); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	aspectInstance := NewSessionAspect(manager, session.ScopePerPeer, nil)
+	point := &aspect.AgentPoint{AgentId: req.AgentID}
+	input := &aspect.AgentInput{
+		Messages: []*schema.Message{{Role: schema.User, Content: "继续"}},
+		Metadata: map[string]string{
+			aspect.MetaLoadHistory: "true",
+			aspect.MetaScopeID:     req.ScopeID,
+		},
+	}
+	out, err := aspectInstance.Before(ctx, point, input)
+	if err != nil {
+		t.Fatalf("Before() error = %v", err)
+	}
+	if len(out.HistoryMessages) != 3 {
+		t.Fatalf("expected summary + 2 active messages, got %d", len(out.HistoryMessages))
+	}
+	if out.HistoryMessages[0].Role != schema.System || out.HistoryMessages[0].Content != session.CompactedSummaryPrefix+"用户此前询问了旧问题" {
+		t.Errorf("unexpected summary message: role=%s content=%q", out.HistoryMessages[0].Role, out.HistoryMessages[0].Content)
+	}
+	if out.HistoryMessages[1].Content != "新问题" || out.HistoryMessages[2].Content != "新回答" {
+		t.Errorf("compacted messages should not be loaded: %q, %q", out.HistoryMessages[1].Content, out.HistoryMessages[2].Content)
+	}
+}
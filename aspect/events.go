@@ -60,6 +60,9 @@ const (
 	EventStateSnapshot    EventType = "STATE_SNAPSHOT"
 	EventStateDelta       EventType = "STATE_DELTA"
 	EventMessagesSnapshot EventType = "MESSAGES_SNAPSHOT"
+
+	// 自定义事件
+	EventCustom EventType = "CUSTOM"
 )
 
 // =============================================================================
@@ -237,6 +240,13 @@ type MessagesSnapshotEvent struct {
 	Messages []MessageState `json:"messages"`
 }
 
+// CustomEvent CUSTOM 事件 - 应用自定义事件（如工具调用审批请求）
+type CustomEvent struct {
+	BaseEvent
+	Name  string      `json:"name"`
+	Value interface{} `json:"value,omitempty"`
+}
+
 // =============================================================================
 // 辅助数据结构
 // =============================================================================
@@ -293,6 +303,21 @@ type EventEmitter interface {
 	EmitStateDelta(delta []JsonPatchOperation)
 }
 
+// CustomEventEmitter 支持 AG-UI CUSTOM 事件的发射器（可选接口实现）
+type CustomEventEmitter interface {
+	EmitCustom(name string, value interface{})
+}
+
+// EmitCustom 发送自定义事件
+// 发射器未实现 CustomEventEmitter 时，以 STATE_DELTA 事件将 value 写入状态路径 /<name>
+func EmitCustom(emitter EventEmitter, name string, value interface{}) {
+	if ce, ok := emitter.(CustomEventEmitter); ok {
+		ce.EmitCustom(name, value)
+		return
+	}
+	emitter.EmitStateDelta([]JsonPatchOperation{{Op: "add", Path: "/" + name, Value: value}})
+}
+
 // =============================================================================
 // Context 工具函数 - 使用泛型 Key
 // =============================================================================
@@ -476,3 +501,12 @@ func NewStateDeltaEvent(delta []JsonPatchOperation) *StateDeltaEvent {
 		Delta:     delta,
 	}
 }
+
+// NewCustomEvent 创建 CUSTOM 事件
+func NewCustomEvent(name string, value interface{}) *CustomEvent {
+	return &CustomEvent{
+		BaseEvent: NewBaseEvent(EventCustom),
+		Name:      name,
+		Value:     value,
+	}
+}
@@ -411,3 +411,12 @@ func (m *AspectManager) HasAspects() bool {
 	defer m.mu.RUnlock()
 	return len(m.aspects) > 0
 }
+
+// Aspects returns a copy of the registered aspects.
+//
+// Aspects 返回已注册切面的副本。
+func (m *AspectManager) Aspects() []Aspect {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Aspect(nil), m.aspects...)
+}
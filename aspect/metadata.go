@@ -78,4 +78,74 @@ const (
 	// MetaSessionExtraFields 会话级扩展参数覆盖（JSON 字符串）
 	// 用于传递思考强度等模型特定参数的会话级临时覆盖（如 thinking.type、reasoning_effort）
 	MetaSessionExtraFields = "session_extra_fields"
+
+	// MetaLLMModel 执行使用的模型
+	// 单次 LLM 调用实际使用的模型经 WithLLMModel 随 context 传递，未传递时切面读取该元数据
+	MetaLLMModel = "llm_model"
+
+	// ============== Context Budget ==============
+
+	// MetaContextBudgetTokens 上下文预算（上下文窗口 - 最大输出 - 预留）
+	MetaContextBudgetTokens = "contextBudgetTokens"
+
+	// MetaContextPromptTokens 预算处理后预估的提示词 token 数
+	MetaContextPromptTokens = "contextPromptTokens"
+
+	// MetaContextDroppedMessages 为满足预算丢弃的历史消息数
+	MetaContextDroppedMessages = "contextDroppedMessages"
+
+	// MetaContextCondensedToolResults 为满足预算压缩的工具结果数
+	MetaContextCondensedToolResults = "contextCondensedToolResults"
+
+	// MetaContextRemovedTokens 为满足预算移除的 token 数
+	MetaContextRemovedTokens = "contextRemovedTokens"
+
+	// ============== Cost Accounting ==============
+
+	// MetaSessionKey 当前执行的会话 key
+	// 由 CostAspect 在 Before 阶段写入 AgentPoint.Metadata，供执行失败时记账使用
+	MetaSessionKey = "sessionKey"
+
+	// MetaCost 本次执行所有 LLM 调用的费用合计（货币与模型价格一致）
+	MetaCost = "cost"
+
+	// MetaBudgetExceeded 已用尽的预算（scope:period，逗号分隔）
+	MetaBudgetExceeded = "budgetExceeded"
+
+	// MetaBudgetDowngradeModel 预算用尽后降级使用的模型
+	MetaBudgetDowngradeModel = "budgetDowngradeModel"
+
+	// ============== Tool Approval ==============
+
+	// MetaRunID 本次执行的 ID
+	// 默认取消息元数据 runId，未指定时使用消息 ID
+	MetaRunID = "runId"
+
+	// MetaApprovalRequired 审批请求消息标记 (true)
+	MetaApprovalRequired = "approvalRequired"
+
+	// MetaApprovalRunID 审批所属执行的 ID
+	// 审批请求消息中由审批切面写入；审批决定消息中用于定位等待审批的执行
+	MetaApprovalRunID = "approvalRunId"
+
+	// MetaApprovalID 审批记录 ID，审批决定消息中为空时作用于该执行所有待审批记录
+	MetaApprovalID = "approvalId"
+
+	// MetaApprovalTool 待审批的工具名称
+	MetaApprovalTool = "approvalTool"
+
+	// MetaApprovalArguments 待审批的工具参数；审批决定为 edit 时为修改后的参数
+	MetaApprovalArguments = "approvalArguments"
+
+	// MetaApprovalRule 命中的审批策略规则
+	MetaApprovalRule = "approvalRule"
+
+	// MetaApprovalDecision 审批决定 (approve/deny/edit)，携带此元数据的消息作为审批决定处理
+	MetaApprovalDecision = "approvalDecision"
+
+	// MetaApprovalReason 审批决定说明
+	MetaApprovalReason = "approvalReason"
+
+	// MetaApprovalStatus 审批决定处理结果（审批后的状态）
+	MetaApprovalStatus = "approvalStatus"
 )
@@ -20,6 +20,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/rulego/rulego-components-ai/utils/token"
 	"github.com/rulego/rulego/api/types"
 )
 
@@ -310,8 +311,56 @@ const (
 type ModelInfo struct {
 	Name         string            // 模型名称
 	Capabilities []ModelCapability // 模型能力列表
+	Tokenizer    string            // 分词编码名称，如 cl100k_base、o200k_base，为空使用内置映射
+	// ContextWindow 上下文窗口大小（输入+输出 token 总数），0 表示使用内置配置
+	ContextWindow int
+	// MaxOutputTokens 单次调用最大输出 token 数，0 表示使用内置配置
+	MaxOutputTokens int
+	// Pricing 模型价格，全部为 0 表示不计价
+	Pricing ModelPricing
 }
 
+// ModelPricing 模型价格，单位为每百万 token 的价格，货币由应用层自行约定
+type ModelPricing struct {
+	Input       float64 // 输入 token 价格
+	Output      float64 // 输出 token 价格
+	CachedInput float64 // 命中缓存的输入 token 价格，0 表示按 Input 计价
+}
+
+// IsZero 是否未配置价格
+func (p ModelPricing) IsZero() bool {
+	return p.Input == 0 && p.Output == 0 && p.CachedInput == 0
+}
+
+// Cost 计算一次调用的费用
+// promptTokens 包含 cachedTokens（与 OpenAI usage 口径一致）
+func (p ModelPricing) Cost(promptTokens, completionTokens, cachedTokens int) float64 {
+	if cachedTokens > promptTokens {
+		cachedTokens = promptTokens
+	}
+	cachedPrice := p.CachedInput
+	if cachedPrice == 0 {
+		cachedPrice = p.Input
+	}
+	return (float64(promptTokens-cachedTokens)*p.Input +
+		float64(cachedTokens)*cachedPrice +
+		float64(completionTokens)*p.Output) / 1e6
+}
+
+// modelPricingRegistry 模型价格注册表，没有内置价格，由应用层按实际采购价格注册
+// key: 模型名称（小写）, 与 modelCapabilityRegistry 共用 capabilityRegistryMutex
+var modelPricingRegistry = make(map[string]ModelPricing)
+
+// ModelLimits 模型上下文限制
+type ModelLimits struct {
+	ContextWindow   int // 上下文窗口大小（输入+输出 token 总数）
+	MaxOutputTokens int // 单次调用最大输出 token 数
+}
+
+// modelLimitsRegistry 模型上下文限制注册表（应用层可覆盖内置配置）
+// key: 模型名称（小写）, 与 modelCapabilityRegistry 共用 capabilityRegistryMutex
+var modelLimitsRegistry = make(map[string]ModelLimits)
+
 // modelCapabilityRegistry 模型能力注册表（应用层可覆盖内置配置）
 // key: 模型名称（小写）, value: 能力列表
 var modelCapabilityRegistry = make(map[string][]ModelCapability)
@@ -370,6 +419,43 @@ var defaultModelCapabilities = map[string][]ModelCapability{
 	"yi-vl":    {CapabilityVision},
 }
 
+// defaultModelLimits 内置的默认模型上下文限制
+// 匹配规则与 defaultModelCapabilities 相同（大小写不敏感，最长匹配优先）
+var defaultModelLimits = map[string]ModelLimits{
+	// === OpenAI 系列 ===
+	"gpt-5":       {ContextWindow: 400000, MaxOutputTokens: 128000},
+	"gpt-4.1":     {ContextWindow: 1047576, MaxOutputTokens: 32768},
+	"gpt-4o":      {ContextWindow: 128000, MaxOutputTokens: 16384},
+	"gpt-4-turbo": {ContextWindow: 128000, MaxOutputTokens: 4096},
+	"gpt-4-32k":   {ContextWindow: 32768, MaxOutputTokens: 4096},
+	"gpt-4":       {ContextWindow: 8192, MaxOutputTokens: 4096},
+	"gpt-3.5":     {ContextWindow: 16385, MaxOutputTokens: 4096},
+	"o1":          {ContextWindow: 200000, MaxOutputTokens: 100000},
+	"o3":          {ContextWindow: 200000, MaxOutputTokens: 100000},
+	"o4":          {ContextWindow: 200000, MaxOutputTokens: 100000},
+
+	// === Claude 系列 ===
+	"claude-3":   {ContextWindow: 200000, MaxOutputTokens: 4096},
+	"claude-3-5": {ContextWindow: 200000, MaxOutputTokens: 8192},
+	"claude-4":   {ContextWindow: 200000, MaxOutputTokens: 32000},
+
+	// === 智谱 GLM 系列 ===
+	"glm-4":   {ContextWindow: 128000, MaxOutputTokens: 4096},
+	"glm-4.6": {ContextWindow: 200000, MaxOutputTokens: 128000},
+
+	// === 阿里通义系列 ===
+	"qwen-turbo": {ContextWindow: 1000000, MaxOutputTokens: 8192},
+	"qwen-plus":  {ContextWindow: 131072, MaxOutputTokens: 8192},
+	"qwen-max":   {ContextWindow: 32768, MaxOutputTokens: 8192},
+	"qwen3":      {ContextWindow: 131072, MaxOutputTokens: 8192},
+
+	// === 其他常见模型 ===
+	"gemini":   {ContextWindow: 1048576, MaxOutputTokens: 8192},
+	"deepseek": {ContextWindow: 128000, MaxOutputTokens: 8192},
+	"mistral":  {ContextWindow: 32768, MaxOutputTokens: 4096},
+	"llama-3":  {ContextWindow: 8192, MaxOutputTokens: 2048},
+}
+
 // RegisterModelCapabilities 注册单个模型的能力
 // 应用层调用此函数注册或覆盖模型的能力列表
 func RegisterModelCapabilities(modelName string, capabilities []ModelCapability) {
@@ -385,6 +471,15 @@ func RegisterModelCapabilitiesFromConfig(models []ModelInfo) {
 	defer capabilityRegistryMutex.Unlock()
 	for _, m := range models {
 		modelCapabilityRegistry[strings.ToLower(m.Name)] = m.Capabilities
+		if m.Tokenizer != "" {
+			token.RegisterModelEncoding(m.Name, m.Tokenizer)
+		}
+		if m.ContextWindow > 0 || m.MaxOutputTokens > 0 {
+			modelLimitsRegistry[strings.ToLower(m.Name)] = ModelLimits{ContextWindow: m.ContextWindow, MaxOutputTokens: m.MaxOutputTokens}
+		}
+		if !m.Pricing.IsZero() {
+			modelPricingRegistry[strings.ToLower(m.Name)] = m.Pricing
+		}
 	}
 }
 
@@ -393,6 +488,8 @@ func UnregisterModelCapabilities(modelName string) {
 	capabilityRegistryMutex.Lock()
 	defer capabilityRegistryMutex.Unlock()
 	delete(modelCapabilityRegistry, strings.ToLower(modelName))
+	delete(modelLimitsRegistry, strings.ToLower(modelName))
+	delete(modelPricingRegistry, strings.ToLower(modelName))
 }
 
 // ClearModelCapabilitiesRegistry 清空模型能力注册表
@@ -401,6 +498,82 @@ func ClearModelCapabilitiesRegistry() {
 	capabilityRegistryMutex.Lock()
 	defer capabilityRegistryMutex.Unlock()
 	modelCapabilityRegistry = make(map[string][]ModelCapability)
+	modelLimitsRegistry = make(map[string]ModelLimits)
+	modelPricingRegistry = make(map[string]ModelPricing)
+}
+
+// RegisterModelPricing 注册模型价格
+// modelName 可以是完整模型名，也可以是模型名中包含的模式（如 "gpt-4o"），最长匹配优先
+func RegisterModelPricing(modelName string, pricing ModelPricing) {
+	capabilityRegistryMutex.Lock()
+	defer capabilityRegistryMutex.Unlock()
+	modelPricingRegistry[strings.ToLower(modelName)] = pricing
+}
+
+// GetModelPricing 获取模型价格，未注册时返回 false
+func GetModelPricing(modelName string) (ModelPricing, bool) {
+	if modelName == "" {
+		return ModelPricing{}, false
+	}
+	modelLower := strings.ToLower(modelName)
+
+	capabilityRegistryMutex.RLock()
+	defer capabilityRegistryMutex.RUnlock()
+	if pricing, ok := modelPricingRegistry[modelLower]; ok {
+		return pricing, true
+	}
+	var (
+		matched    ModelPricing
+		matchedLen = -1
+	)
+	for pattern, pricing := range modelPricingRegistry {
+		if strings.Contains(modelLower, pattern) && len(pattern) > matchedLen {
+			matched = pricing
+			matchedLen = len(pattern)
+		}
+	}
+	return matched, matchedLen >= 0
+}
+
+// RegisterModelLimits 注册单个模型的上下文限制
+func RegisterModelLimits(modelName string, limits ModelLimits) {
+	capabilityRegistryMutex.Lock()
+	defer capabilityRegistryMutex.Unlock()
+	modelLimitsRegistry[strings.ToLower(modelName)] = limits
+}
+
+// GetModelLimits 获取模型的上下文限制
+// 检测顺序：1. 应用层注册表（覆盖） -> 2. 内置默认配置；均未命中时返回 false
+// 应用层只配置了其中一项时，另一项取内置配置
+func GetModelLimits(modelName string) (ModelLimits, bool) {
+	if modelName == "" {
+		return ModelLimits{}, false
+	}
+	modelLower := strings.ToLower(modelName)
+
+	var (
+		matched    ModelLimits
+		matchedLen = -1
+	)
+	for pattern, limits := range defaultModelLimits {
+		if strings.Contains(modelLower, pattern) && len(pattern) > matchedLen {
+			matched = limits
+			matchedLen = len(pattern)
+		}
+	}
+
+	capabilityRegistryMutex.RLock()
+	registered, ok := modelLimitsRegistry[modelLower]
+	capabilityRegistryMutex.RUnlock()
+	if ok {
+		if registered.ContextWindow > 0 {
+			matched.ContextWindow = registered.ContextWindow
+		}
+		if registered.MaxOutputTokens > 0 {
+			matched.MaxOutputTokens = registered.MaxOutputTokens
+		}
+	}
+	return matched, matched.ContextWindow > 0
 }
 
 // GetModelCapabilities 获取模型的能力列表
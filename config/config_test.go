@@ -42,3 +42,57 @@ func TestGetModelCapabilities_RegistryOverridePriority(t *testing.T) {
 		t.Fatalf("expected registered override to keep streaming capability, got: %v", caps)
 	}
 }
+
+func TestGetModelLimits(t *testing.T) {
+	ClearModelCapabilitiesRegistry()
+	defer ClearModelCapabilitiesRegistry()
+
+	limits, ok := GetModelLimits("GPT-4o-mini")
+	if !ok || limits.ContextWindow != 128000 || limits.MaxOutputTokens != 16384 {
+		t.Fatalf("unexpected gpt-4o-mini limits: %+v", limits)
+	}
+	if limits, _ := GetModelLimits("gpt-4-32k-0613"); limits.ContextWindow != 32768 {
+		t.Fatalf("expected longest pattern gpt-4-32k to win, got %+v", limits)
+	}
+	if _, ok := GetModelLimits("unknown-model"); ok {
+		t.Fatal("unknown model should have no limits")
+	}
+
+	RegisterModelCapabilitiesFromConfig([]ModelInfo{
+		{Name: "gpt-4o-mini", MaxOutputTokens: 4096},
+		{Name: "my-local-model", ContextWindow: 32768},
+	})
+	if limits, _ := GetModelLimits("gpt-4o-mini"); limits.ContextWindow != 128000 || limits.MaxOutputTokens != 4096 {
+		t.Fatalf("expected partial override to keep built-in context window, got %+v", limits)
+	}
+	if limits, ok := GetModelLimits("my-local-model"); !ok || limits.ContextWindow != 32768 {
+		t.Fatalf("expected registered limits, got %+v", limits)
+	}
+}
+
+func TestModelPricing(t *testing.T) {
+	ClearModelCapabilitiesRegistry()
+	defer ClearModelCapabilitiesRegistry()
+
+	if _, ok := GetModelPricing("gpt-4o"); ok {
+		t.Fatal("pricing should not be built in")
+	}
+	RegisterModelPricing("gpt-4o", ModelPricing{Input: 2.5, Output: 10, CachedInput: 1.25})
+	RegisterModelCapabilitiesFromConfig([]ModelInfo{{Name: "gpt-4o-mini", Pricing: ModelPricing{Input: 0.15, Output: 0.6}}})
+
+	p, ok := GetModelPricing("GPT-4o-2024-08-06")
+	if !ok || p.Input != 2.5 {
+		t.Fatalf("expected gpt-4o pricing by pattern, got %+v", p)
+	}
+	if p, _ := GetModelPricing("gpt-4o-mini-2024-07-18"); p.Input != 0.15 {
+		t.Fatalf("expected longest pattern gpt-4o-mini to win, got %+v", p)
+	}
+
+	// 1M 输入（其中 400k 命中缓存）+ 100k 输出
+	if cost := p.Cost(1_000_000, 100_000, 400_000); cost != 0.6*2.5+0.4*1.25+0.1*10 {
+		t.Errorf("unexpected cost %v", cost)
+	}
+	if cost := (ModelPricing{Input: 1, Output: 2}).Cost(1_000_000, 0, 500_000); cost != 1 {
+		t.Errorf("cached tokens should use input price when CachedInput is 0, got %v", cost)
+	}
+}
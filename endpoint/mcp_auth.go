@@ -0,0 +1,513 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package endpoint
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// KeyPrincipal is the message metadata key holding the authenticated caller's subject.
+	// The subject is also written to aspect.MetaUserID so SessionAspect scopes sessions per caller.
+	KeyPrincipal = "mcp.principal"
+	// KeyPrincipalClaims is the message metadata key holding the caller's token claims as JSON
+	KeyPrincipalClaims = "mcp.claims"
+	// DefaultSubjectClaim is the claim identifying the principal
+	DefaultSubjectClaim = "sub"
+	// jwtLeeway tolerates clock skew when checking exp and nbf
+	jwtLeeway = time.Minute
+)
+
+var (
+	errMissingToken = errors.New("missing bearer token")
+	errInvalidToken = errors.New("invalid token")
+)
+
+// AuthConfig configures bearer-token authentication and per-tool, per-resource and per-prompt authorization of the HTTP transports.
+// The stdio transport is not authenticated: it trusts the local process that launched it.
+type AuthConfig struct {
+	// Tokens are static bearer tokens
+	Tokens []StaticToken `json:"tokens"`
+	// Keys is the local key set used to verify HS256/HS384/HS512 signed JWTs
+	Keys []JWK `json:"keys"`
+	// KeySetFile loads more keys from a JWK Set file ({"keys":[...]})
+	KeySetFile string `json:"keySetFile"`
+	// Issuer, if set, must equal the iss claim of JWTs
+	Issuer string `json:"issuer"`
+	// Audience, if set, must be contained in the aud claim of JWTs
+	Audience string `json:"audience"`
+	// SubjectClaim names the claim identifying the principal. Defaults to sub
+	SubjectClaim string `json:"subjectClaim"`
+	// ToolRules grant tools, resources and prompts to callers whose claims match.
+	// Without rules every authenticated caller may use every tool, resource and prompt
+	ToolRules []ToolRule `json:"toolRules"`
+}
+
+// StaticToken is a fixed bearer token with the claims of its holder
+type StaticToken struct {
+	Token string `json:"token"`
+	// Subject identifies the holder. Defaults to the subject claim in Claims
+	Subject string                 `json:"subject"`
+	Claims  map[string]interface{} `json:"claims"`
+}
+
+// JWK is a symmetric JSON Web Key (kty "oct") with a base64url encoded secret
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	K   string `json:"k"`
+	// Alg restricts the key to one algorithm, empty allows HS256, HS384 and HS512
+	Alg string `json:"alg"`
+}
+
+// ToolRule grants the tools matching Tools (globs such as "report_*"), the resources matching Resources
+// (URI globs such as "rulego://chains/*") and the prompts matching Prompts to callers whose Claim matches Value.
+// A claim matches if it equals Value, or for space-separated strings (e.g. scope) and arrays (e.g. roles)
+// if one of its items does. An empty Value or "*" matches any caller having the claim.
+// Session transcripts are further limited to the sessions created by the caller.
+type ToolRule struct {
+	Claim     string   `json:"claim"`
+	Value     string   `json:"value"`
+	Tools     []string `json:"tools"`
+	Resources []string `json:"resources"`
+	Prompts   []string `json:"prompts"`
+}
+
+// Principal is an authenticated caller
+type Principal struct {
+	Subject string
+	Claims  map[string]interface{}
+	// Tools holds the tool globs granted by the tool rules, nil means every tool
+	Tools []string
+	// Resources holds the resource URI globs granted by the tool rules, nil means every resource
+	Resources []string
+	// Prompts holds the prompt globs granted by the tool rules, nil means every prompt
+	Prompts []string
+}
+
+// CanCallTool reports whether the principal may list and call the tool
+func (p *Principal) CanCallTool(name string) bool {
+	return matchGrant(p.Tools, name)
+}
+
+// CanReadResource reports whether the principal may list, read and subscribe to the resource
+func (p *Principal) CanReadResource(uri string) bool {
+	return matchGrant(p.Resources, uri)
+}
+
+// CanGetPrompt reports whether the principal may list and get the prompt
+func (p *Principal) CanGetPrompt(name string) bool {
+	return matchGrant(p.Prompts, name)
+}
+
+// matchGrant reports whether name matches one of the granted globs (path.Match syntax).
+// nil grants everything, and "*" also matches names containing "/" such as resource URIs
+func matchGrant(patterns []string, name string) bool {
+	if patterns == nil {
+		return true
+	}
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal authenticated for the current request
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// authenticator validates bearer tokens and resolves the principal's tools
+type authenticator struct {
+	config AuthConfig
+	// tokens maps the SHA-256 of static tokens, so lookups do not leak token prefixes through timing
+	tokens map[[sha256.Size]byte]StaticToken
+	keys   []jwkKey
+}
+
+type jwkKey struct {
+	kid    string
+	alg    string
+	secret []byte
+}
+
+func newAuthenticator(config AuthConfig) (*authenticator, error) {
+	if config.SubjectClaim == "" {
+		config.SubjectClaim = DefaultSubjectClaim
+	}
+	a := &authenticator{config: config, tokens: make(map[[sha256.Size]byte]StaticToken)}
+	for i, t := range config.Tokens {
+		if t.Token == "" {
+			return nil, fmt.Errorf("auth: static token %d is empty", i)
+		}
+		if t.Subject == "" {
+			t.Subject = claimString(t.Claims[config.SubjectClaim])
+		}
+		if t.Subject == "" {
+			return nil, fmt.Errorf("auth: static token %d has no subject", i)
+		}
+		a.tokens[sha256.Sum256([]byte(t.Token))] = t
+	}
+	keys := config.Keys
+	if config.KeySetFile != "" {
+		data, err := os.ReadFile(config.KeySetFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth: read key set: %w", err)
+		}
+		var set struct {
+			Keys []JWK `json:"keys"`
+		}
+		if err := stdjson.Unmarshal(data, &set); err != nil {
+			return nil, fmt.Errorf("auth: parse key set: %w", err)
+		}
+		keys = append(append([]JWK(nil), keys...), set.Keys...)
+	}
+	for i, k := range keys {
+		if k.Kty != "" && k.Kty != "oct" {
+			return nil, fmt.Errorf("auth: key %d: unsupported kty %s, only oct keys are supported", i, k.Kty)
+		}
+		if k.Alg != "" && hashForAlg(k.Alg) == nil {
+			return nil, fmt.Errorf("auth: key %d: unsupported alg %s", i, k.Alg)
+		}
+		secret, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.K, "="))
+		if err != nil || len(secret) == 0 {
+			return nil, fmt.Errorf("auth: key %d: invalid k", i)
+		}
+		a.keys = append(a.keys, jwkKey{kid: k.Kid, alg: k.Alg, secret: secret})
+	}
+	if len(a.tokens) == 0 && len(a.keys) == 0 {
+		return nil, errors.New("auth: no tokens or keys configured")
+	}
+	return a, nil
+}
+
+// authenticate validates the bearer token of the request
+func (a *authenticator) authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, errMissingToken
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return nil, errMissingToken
+	}
+	token = strings.TrimSpace(token)
+	if t, ok := a.tokens[sha256.Sum256([]byte(token))]; ok {
+		return a.principal(t.Subject, t.Claims), nil
+	}
+	if len(a.keys) == 0 || strings.Count(token, ".") != 2 {
+		return nil, errInvalidToken
+	}
+	claims, err := a.verifyJWT(token)
+	if err != nil {
+		return nil, err
+	}
+	subject := claimString(claims[a.config.SubjectClaim])
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", errInvalidToken, a.config.SubjectClaim)
+	}
+	return a.principal(subject, claims), nil
+}
+
+// verifyJWT checks the signature and registered claims of an HMAC-signed JWT
+func (a *authenticator) verifyJWT(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errInvalidToken
+	}
+	newHash := hashForAlg(header.Alg)
+	if newHash == nil {
+		return nil, fmt.Errorf("%w: unsupported alg %s", errInvalidToken, header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, key := range a.keys {
+		if header.Kid != "" && key.kid != "" && key.kid != header.Kid || key.alg != "" && key.alg != header.Alg {
+			continue
+		}
+		mac := hmac.New(newHash, key.secret)
+		mac.Write(signed)
+		if subtle.ConstantTimeCompare(mac.Sum(nil), signature) == 1 {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("%w: signature mismatch", errInvalidToken)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, errInvalidToken
+	}
+	now := time.Now()
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(jwtLeeway)) {
+		return nil, fmt.Errorf("%w: expired", errInvalidToken)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, fmt.Errorf("%w: not valid yet", errInvalidToken)
+	}
+	if a.config.Issuer != "" && claimString(claims["iss"]) != a.config.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer", errInvalidToken)
+	}
+	if a.config.Audience != "" && !claimContains(claims["aud"], a.config.Audience) {
+		return nil, fmt.Errorf("%w: unexpected audience", errInvalidToken)
+	}
+	return claims, nil
+}
+
+// principal builds the principal and resolves the tools granted by its claims
+func (a *authenticator) principal(subject string, claims map[string]interface{}) *Principal {
+	// Copy the claims, static token claims are shared between requests
+	copied := make(map[string]interface{}, len(claims)+1)
+	for k, v := range claims {
+		copied[k] = v
+	}
+	if _, ok := copied[a.config.SubjectClaim]; !ok {
+		copied[a.config.SubjectClaim] = subject
+	}
+	claims = copied
+	p := &Principal{Subject: subject, Claims: claims}
+	if len(a.config.ToolRules) == 0 {
+		return p
+	}
+	p.Tools, p.Resources, p.Prompts = []string{}, []string{}, []string{}
+	for _, rule := range a.config.ToolRules {
+		value, ok := claims[rule.Claim]
+		if !ok {
+			continue
+		}
+		if rule.Value == "" || rule.Value == "*" || claimContains(value, rule.Value) {
+			p.Tools = append(p.Tools, rule.Tools...)
+			p.Resources = append(p.Resources, rule.Resources...)
+			p.Prompts = append(p.Prompts, rule.Prompts...)
+		}
+	}
+	return p
+}
+
+// hashForAlg returns the hash of an HMAC JWT algorithm
+func hashForAlg(alg string) func() hash.Hash {
+	switch alg {
+	case "HS256":
+		return sha256.New
+	case "HS384":
+		return sha512.New384
+	case "HS512":
+		return sha512.New
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a JWT
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return stdjson.Unmarshal(data, v)
+}
+
+// claimString converts a scalar claim to a string
+func claimString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// claimContains reports whether a claim equals want or, for arrays and space-separated strings, contains it
+func claimContains(claim interface{}, want string) bool {
+	switch value := claim.(type) {
+	case []interface{}:
+		for _, item := range value {
+			if claimString(item) == want {
+				return true
+			}
+		}
+		return false
+	case []string:
+		for _, item := range value {
+			if item == want {
+				return true
+			}
+		}
+		return false
+	case string:
+		if value == want {
+			return true
+		}
+		for _, item := range strings.Fields(value) {
+			if item == want {
+				return true
+			}
+		}
+		return false
+	default:
+		return claimString(value) == want
+	}
+}
+
+// authorize authenticates an HTTP request and attaches the principal to its context.
+// It writes a 401 response and returns false if authentication fails.
+func (s *McpServer) authorize(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if s.auth == nil {
+		return r, true
+	}
+	p, err := s.auth.authenticate(r)
+	if err != nil {
+		challenge := `Bearer realm="mcp"`
+		if !errors.Is(err, errMissingToken) {
+			challenge += `, error="invalid_token"`
+		}
+		w.Header().Set("WWW-Authenticate", challenge)
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return r, false
+	}
+	return r.WithContext(WithPrincipal(r.Context(), p)), true
+}
+
+// authServerOptions hides and guards the tools, resources and prompts a principal may not use
+func (s *McpServer) authServerOptions() []server.ServerOption {
+	hooks := &server.Hooks{}
+	hooks.AddAfterListResources(func(ctx context.Context, id any, message *mcp.ListResourcesRequest, result *mcp.ListResourcesResult) {
+		allowed := make([]mcp.Resource, 0, len(result.Resources))
+		for _, res := range result.Resources {
+			if s.canReadResource(ctx, res.URI) {
+				allowed = append(allowed, res)
+			}
+		}
+		result.Resources = allowed
+	})
+	hooks.AddAfterListPrompts(func(ctx context.Context, id any, message *mcp.ListPromptsRequest, result *mcp.ListPromptsResult) {
+		p, ok := PrincipalFromContext(ctx)
+		allowed := make([]mcp.Prompt, 0, len(result.Prompts))
+		for _, prompt := range result.Prompts {
+			if ok && p.CanGetPrompt(prompt.Name) {
+				allowed = append(allowed, prompt)
+			}
+		}
+		result.Prompts = allowed
+	})
+	// mcp-go has no prompt handler middleware, so prompts/get is checked before the request is dispatched
+	hooks.AddOnRequestInitialization(func(ctx context.Context, id any, message any) error {
+		raw, ok := message.(stdjson.RawMessage)
+		if !ok {
+			return nil
+		}
+		var req struct {
+			Method string `json:"method"`
+			Params struct {
+				Name string `json:"name"`
+			} `json:"params"`
+		}
+		if err := stdjson.Unmarshal(raw, &req); err != nil || req.Method != string(mcp.MethodPromptsGet) {
+			return nil
+		}
+		if p, ok := PrincipalFromContext(ctx); !ok || !p.CanGetPrompt(req.Params.Name) {
+			return fmt.Errorf("permission denied: prompt %s", req.Params.Name)
+		}
+		return nil
+	})
+	return []server.ServerOption{
+		server.WithHooks(hooks),
+		server.WithResourceHandlerMiddleware(func(next server.ResourceHandlerFunc) server.ResourceHandlerFunc {
+			return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+				if !s.canReadResource(ctx, request.Params.URI) {
+					return nil, fmt.Errorf("permission denied: resource %s", request.Params.URI)
+				}
+				return next(ctx, request)
+			}
+		}),
+		server.WithToolFilter(func(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+			p, ok := PrincipalFromContext(ctx)
+			if !ok {
+				return nil
+			}
+			allowed := make([]mcp.Tool, 0, len(tools))
+			for _, tool := range tools {
+				if p.CanCallTool(tool.Name) {
+					allowed = append(allowed, tool)
+				}
+			}
+			return allowed
+		}),
+		server.WithToolHandlerMiddleware(func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+			return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				p, ok := PrincipalFromContext(ctx)
+				if !ok || !p.CanCallTool(request.Params.Name) {
+					return mcp.NewToolResultError(fmt.Sprintf("permission denied: tool %s", request.Params.Name)), nil
+				}
+				return next(ctx, request)
+			}
+		}),
+	}
+}
+
+// canReadResource reports whether the principal of ctx may see the resource
+func (s *McpServer) canReadResource(ctx context.Context, uri string) bool {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok || !p.CanReadResource(uri) {
+		return false
+	}
+	return s.resources == nil || s.resources.visible(ctx, uri)
+}
@@ -0,0 +1,291 @@
+package endpoint_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego-components-ai/endpoint"
+	"github.com/rulego/rulego-components-ai/session"
+	"github.com/rulego/rulego/api/types"
+	rulegoEndpoint "github.com/rulego/rulego/endpoint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const authSecret = "0123456789abcdef0123456789abcdef"
+
+// whoamiChainJSON 返回调用方身份的规则链
+const whoamiChainJSON = `{"ruleChain":{"id":"chain_whoami","name":"Whoami"},"metadata":{"nodes":[{"id":"s1","type":"jsTransform","name":"whoami","configuration":{"jsScript":"msg.principal = metadata['mcp.principal']; msg.userId = metadata['userId']; msg.claims = metadata['mcp.claims']; return {'msg':msg,'metadata':metadata,'msgType':msgType};"}}],"connections":[]}}`
+
+// signJWT 使用 HS256 签发测试令牌
+func signJWT(t *testing.T, secret, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT", "kid": kid})
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signing := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signing))
+	return signing + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func startAuthServer(t *testing.T, port string, auth map[string]interface{}) func() {
+	t.Helper()
+	config := rulego.NewConfig(types.WithDefaultPool())
+	_, err := rulego.New("chain_whoami", []byte(whoamiChainJSON), types.WithConfig(config))
+	require.NoError(t, err)
+
+	ep, err := rulegoEndpoint.Registry.New(endpoint.Type, config, types.Configuration{
+		"server":    ":" + port,
+		"basePath":  "/mcp",
+		"transport": endpoint.TransportStreamableHTTP,
+		"auth":      auth,
+	})
+	require.NoError(t, err)
+	for _, name := range []string{"whoami", "report_daily"} {
+		_, err = ep.AddRouter(rulegoEndpoint.NewRouter().From(name).To("chain:chain_whoami").End(), name)
+		require.NoError(t, err)
+	}
+	require.NoError(t, ep.Start())
+	time.Sleep(100 * time.Millisecond)
+	return func() { ep.Destroy() }
+}
+
+func connectWithToken(t *testing.T, url, token string) (*client.Client, error) {
+	t.Helper()
+	httpTransport, err := transport.NewStreamableHTTP(url, transport.WithHTTPHeaders(map[string]string{"Authorization": "Bearer " + token}))
+	require.NoError(t, err)
+	cli := client.NewClient(httpTransport)
+	ctx := context.Background()
+	require.NoError(t, cli.Start(ctx))
+	_, err = cli.Initialize(ctx, mcp.InitializeRequest{Params: mcp.InitializeParams{ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION}})
+	if err != nil {
+		_ = cli.Close()
+		return nil, err
+	}
+	return cli, nil
+}
+
+func toolNames(t *testing.T, cli *client.Client) []string {
+	t.Helper()
+	result, err := cli.ListTools(context.Background(), mcp.ListToolsRequest{})
+	require.NoError(t, err)
+	var names []string
+	for _, tool := range result.Tools {
+		names = append(names, tool.Name)
+	}
+	return names
+}
+
+func callTool(t *testing.T, cli *client.Client, name string) *mcp.CallToolResult {
+	t.Helper()
+	result, err := cli.CallTool(context.Background(), mcp.CallToolRequest{Params: mcp.CallToolParams{
+		Name:      name,
+		Arguments: map[string]interface{}{"input": "hi"},
+	}})
+	require.NoError(t, err)
+	return result
+}
+
+func TestMcpServerEndpoint_Auth(t *testing.T) {
+	keySet := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(keySet, []byte(`{"keys":[{"kty":"oct","kid":"k2","k":"`+base64.RawURLEncoding.EncodeToString([]byte("another-secret"))+`"}]}`), 0644))
+
+	shutdown := startAuthServer(t, "19130", map[string]interface{}{
+		"tokens": []interface{}{
+			map[string]interface{}{"token": "static-admin", "subject": "alice", "claims": map[string]interface{}{"role": "admin"}},
+		},
+		"keys":       []interface{}{map[string]interface{}{"kty": "oct", "kid": "k1", "k": base64.RawURLEncoding.EncodeToString([]byte(authSecret))}},
+		"keySetFile": keySet,
+		"issuer":     "rulego-test",
+		"toolRules": []interface{}{
+			map[string]interface{}{"claim": "role", "value": "admin", "tools": []interface{}{"*"}},
+			map[string]interface{}{"claim": "scope", "value": "reports:read", "tools": []interface{}{"report_*"}},
+		},
+	})
+	defer shutdown()
+	url := "http://localhost:19130/mcp"
+
+	t.Run("missing token", func(t *testing.T) {
+		resp := postMcp(t, url, "", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "Bearer")
+	})
+
+	t.Run("rejected tokens", func(t *testing.T) {
+		now := time.Now().Unix()
+		for name, token := range map[string]string{
+			"unknown static": "static-guest",
+			"bad signature":  signJWT(t, "wrong-secret-wrong-secret-wrong!", "k1", map[string]interface{}{"sub": "bob", "iss": "rulego-test"}),
+			"expired":        signJWT(t, authSecret, "k1", map[string]interface{}{"sub": "bob", "iss": "rulego-test", "exp": now - 3600}),
+			"wrong issuer":   signJWT(t, authSecret, "k1", map[string]interface{}{"sub": "bob", "iss": "other"}),
+			"no subject":     signJWT(t, authSecret, "k1", map[string]interface{}{"iss": "rulego-test"}),
+		} {
+			_, err := connectWithToken(t, url, token)
+			assert.Error(t, err, name)
+		}
+	})
+
+	t.Run("static token", func(t *testing.T) {
+		cli, err := connectWithToken(t, url, "static-admin")
+		require.NoError(t, err)
+		defer cli.Close()
+		assert.ElementsMatch(t, []string{"whoami", "report_daily"}, toolNames(t, cli))
+
+		result := callTool(t, cli, "whoami")
+		require.False(t, result.IsError)
+		text := result.Content[0].(mcp.TextContent).Text
+		assert.Contains(t, text, `"principal":"alice"`)
+		assert.Contains(t, text, `"userId":"alice"`)
+		assert.Contains(t, text, "admin")
+	})
+
+	t.Run("jwt scoped to tools", func(t *testing.T) {
+		token := signJWT(t, authSecret, "k1", map[string]interface{}{
+			"sub": "bob", "iss": "rulego-test", "scope": "profile reports:read", "exp": time.Now().Add(time.Hour).Unix(),
+		})
+		cli, err := connectWithToken(t, url, token)
+		require.NoError(t, err)
+		defer cli.Close()
+		assert.Equal(t, []string{"report_daily"}, toolNames(t, cli))
+
+		result := callTool(t, cli, "report_daily")
+		require.False(t, result.IsError)
+		assert.Contains(t, result.Content[0].(mcp.TextContent).Text, `"principal":"bob"`)
+
+		denied := callTool(t, cli, "whoami")
+		assert.True(t, denied.IsError)
+		assert.Contains(t, denied.Content[0].(mcp.TextContent).Text, "permission denied")
+	})
+
+	t.Run("key from key set file", func(t *testing.T) {
+		token := signJWT(t, "another-secret", "k2", map[string]interface{}{"sub": "carol", "iss": "rulego-test"})
+		cli, err := connectWithToken(t, url, token)
+		require.NoError(t, err)
+		defer cli.Close()
+		// carol 没有匹配任何规则，看不到工具
+		assert.Empty(t, toolNames(t, cli))
+	})
+}
+
+func TestMcpServerEndpoint_AuthResourcesAndPrompts(t *testing.T) {
+	skillDir := t.TempDir()
+	writeTestSkill(t, skillDir, "review", "---\nname: review\ndescription: Review code\n---\nReview $ARGUMENTS carefully.")
+	sm := session.NewManager(session.NewMemoryStorage(), nil)
+	sessionURIs := map[string]string{}
+	for _, user := range []string{"alice", "bob"} {
+		sess, err := sm.GetOrCreate(context.Background(), session.SessionRequest{AgentID: "agent1", Scope: session.ScopePerPeer, ScopeID: user, UserID: user})
+		require.NoError(t, err)
+		require.NoError(t, sm.AddMessage(context.Background(), sess.Key, session.NewSessionMessage("user", "transcript of "+user)))
+		sessionURIs[user] = endpoint.SessionResourcePrefix + url.PathEscape(sess.Key)
+	}
+
+	config := rulego.NewConfig(types.WithDefaultPool())
+	_, err := rulego.New("chain_whoami", []byte(whoamiChainJSON), types.WithConfig(config))
+	require.NoError(t, err)
+	ep, err := rulegoEndpoint.Registry.New(endpoint.Type, config, types.Configuration{
+		"server":       ":19131",
+		"basePath":     "/mcp",
+		"transport":    endpoint.TransportStreamableHTTP,
+		"exposeChains": true,
+		"skillDirs":    []string{skillDir},
+		"auth": map[string]interface{}{
+			"tokens": []interface{}{
+				map[string]interface{}{"token": "token-alice", "subject": "alice", "claims": map[string]interface{}{"role": "admin"}},
+				map[string]interface{}{"token": "token-bob", "subject": "bob", "claims": map[string]interface{}{"role": "user"}},
+			},
+			"toolRules": []interface{}{
+				map[string]interface{}{"claim": "role", "value": "admin", "tools": []interface{}{"*"}, "resources": []interface{}{"*"}, "prompts": []interface{}{"*"}},
+				map[string]interface{}{"claim": "role", "value": "user", "tools": []interface{}{"whoami"}, "resources": []interface{}{endpoint.SessionResourcePrefix + "*"}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	ep.(*endpoint.McpServer).SessionManager = sm
+	_, err = ep.AddRouter(rulegoEndpoint.NewRouter().From("whoami").To("chain:chain_whoami").End(), "whoami")
+	require.NoError(t, err)
+	require.NoError(t, ep.Start())
+	defer ep.Destroy()
+	time.Sleep(100 * time.Millisecond)
+	ctx := context.Background()
+
+	listResources := func(cli *client.Client) []string {
+		result, err := cli.ListResources(ctx, mcp.ListResourcesRequest{})
+		require.NoError(t, err)
+		var uris []string
+		for _, res := range result.Resources {
+			uris = append(uris, res.URI)
+		}
+		return uris
+	}
+	readResource := func(cli *client.Client, uri string) (string, error) {
+		result, err := cli.ReadResource(ctx, mcp.ReadResourceRequest{Params: mcp.ReadResourceParams{URI: uri}})
+		if err != nil {
+			return "", err
+		}
+		return result.Contents[0].(mcp.TextResourceContents).Text, nil
+	}
+	chainURI := endpoint.ChainResourcePrefix + "chain_whoami"
+
+	alice, err := connectWithToken(t, "http://localhost:19131/mcp", "token-alice")
+	require.NoError(t, err)
+	defer alice.Close()
+	assert.ElementsMatch(t, []string{chainURI, sessionURIs["alice"]}, listResources(alice))
+	text, err := readResource(alice, sessionURIs["alice"])
+	require.NoError(t, err)
+	assert.Contains(t, text, "transcript of alice")
+	_, err = readResource(alice, sessionURIs["bob"])
+	assert.Error(t, err)
+	prompts, err := alice.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	require.NoError(t, err)
+	require.Len(t, prompts.Prompts, 1)
+	_, err = alice.GetPrompt(ctx, mcp.GetPromptRequest{Params: mcp.GetPromptParams{Name: "review", Arguments: map[string]string{"arguments": "main.go"}}})
+	assert.NoError(t, err)
+
+	bob, err := connectWithToken(t, "http://localhost:19131/mcp", "token-bob")
+	require.NoError(t, err)
+	defer bob.Close()
+	assert.Equal(t, []string{sessionURIs["bob"]}, listResources(bob))
+	_, err = readResource(bob, chainURI)
+	assert.Error(t, err)
+	_, err = readResource(bob, sessionURIs["alice"])
+	assert.Error(t, err)
+	prompts, err = bob.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, prompts.Prompts)
+	_, err = bob.GetPrompt(ctx, mcp.GetPromptRequest{Params: mcp.GetPromptParams{Name: "review", Arguments: map[string]string{"arguments": "main.go"}}})
+	assert.ErrorContains(t, err, "permission denied")
+}
+
+func TestMcpServerEndpoint_AuthConfigErrors(t *testing.T) {
+	for name, auth := range map[string]map[string]interface{}{
+		"empty":            {},
+		"token no subject": {"tokens": []interface{}{map[string]interface{}{"token": "x"}}},
+		"bad key":          {"keys": []interface{}{map[string]interface{}{"kty": "RSA", "k": "abc"}}},
+	} {
+		_, err := rulegoEndpoint.Registry.New(endpoint.Type, rulego.NewConfig(), types.Configuration{
+			"server":   ":19131",
+			"basePath": "/mcp",
+			"auth":     auth,
+		})
+		assert.Error(t, err, name)
+		if err != nil {
+			assert.True(t, strings.HasPrefix(err.Error(), "auth:"), name)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package endpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// KeyContent is the field of a rule chain output holding MCP content items
+	KeyContent = "content"
+	// KeyStructuredContent is the field of a rule chain output holding MCP structured content
+	KeyStructuredContent = "structuredContent"
+	// KeyIsError is the field of a rule chain output marking the tool result as an error
+	KeyIsError = "isError"
+	// KeyOutputSchema is the rule chain additionalInfo key declaring the tool output schema
+	KeyOutputSchema = "outputSchema"
+)
+
+// toolResultFromOutput converts the output of a rule chain into an MCP tool result.
+//
+// An output shaped like an MCP tool result, i.e. a JSON object with a "content" array of typed
+// items (text, image, audio, resource, resource_link) and/or "structuredContent", is returned as
+// such, so rule chains can produce images and embedded resources. When the tool declares an output
+// schema (structured is true) any other JSON object output becomes the structured content. All
+// other outputs are returned as text.
+func toolResultFromOutput(output string, structured bool) *mcp.CallToolResult {
+	trimmed := strings.TrimSpace(output)
+	if !strings.HasPrefix(trimmed, "{") {
+		if structured {
+			return mcp.NewToolResultError("tool declares an outputSchema but the rule chain output is not a JSON object")
+		}
+		return mcp.NewToolResultText(output)
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(trimmed), &obj); err != nil {
+		if structured {
+			return mcp.NewToolResultError(fmt.Sprintf("tool declares an outputSchema but the rule chain output is invalid JSON: %v", err))
+		}
+		return mcp.NewToolResultText(output)
+	}
+	if result, ok := parseToolResult(obj); ok {
+		if result.StructuredContent != nil && len(result.Content) == 0 {
+			// Structured content should also be serialized as text for clients that ignore it
+			if b, err := json.Marshal(result.StructuredContent); err == nil {
+				result.Content = []mcp.Content{mcp.NewTextContent(string(b))}
+			}
+		}
+		if structured && result.StructuredContent == nil && !result.IsError {
+			return mcp.NewToolResultError("tool declares an outputSchema but the rule chain output has no structuredContent")
+		}
+		return result
+	}
+	if structured {
+		return mcp.NewToolResultStructured(obj, output)
+	}
+	return mcp.NewToolResultText(output)
+}
+
+// parseToolResult parses obj as an MCP tool result. ok is false if obj does not have that shape,
+// so that ordinary JSON outputs that happen to have a "content" field stay text.
+func parseToolResult(obj map[string]interface{}) (result *mcp.CallToolResult, ok bool) {
+	rawContent, hasContent := obj[KeyContent]
+	structuredContent, hasStructured := obj[KeyStructuredContent]
+	if !hasContent && !hasStructured {
+		return nil, false
+	}
+	for key := range obj {
+		if key != KeyContent && key != KeyStructuredContent && key != KeyIsError && key != "_meta" {
+			return nil, false
+		}
+	}
+	result = &mcp.CallToolResult{}
+	if hasContent {
+		items, isArray := rawContent.([]interface{})
+		if !isArray {
+			return nil, false
+		}
+		for _, item := range items {
+			itemMap, isMap := item.(map[string]interface{})
+			if !isMap {
+				return nil, false
+			}
+			content, err := mcp.ParseContent(itemMap)
+			if err != nil {
+				return nil, false
+			}
+			result.Content = append(result.Content, content)
+		}
+	}
+	if hasStructured {
+		if _, isMap := structuredContent.(map[string]interface{}); !isMap {
+			return nil, false
+		}
+		result.StructuredContent = structuredContent
+	}
+	if isError, exists := obj[KeyIsError]; exists {
+		b, isBool := isError.(bool)
+		if !isBool {
+			return nil, false
+		}
+		result.IsError = b
+	}
+	return result, true
+}
@@ -0,0 +1,95 @@
+package endpoint_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego-components-ai/endpoint"
+	"github.com/rulego/rulego/api/types"
+	rulegoEndpoint "github.com/rulego/rulego/endpoint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chartChainJSON 按 MCP 结果格式返回文本、图片与嵌入资源
+const chartChainJSON = `{"ruleChain":{"id":"chain_chart","name":"Chart"},"metadata":{"nodes":[{"id":"s1","type":"jsTransform","name":"chart","configuration":{"jsScript":"return {'msg':{'content':[{'type':'text','text':'chart of '+msg.city},{'type':'image','data':'iVBORw0KGgo=','mimeType':'image/png'},{'type':'resource','resource':{'uri':'file:///data.csv','mimeType':'text/csv','text':'a,b'}}]},'metadata':metadata,'msgType':msgType};"}}],"connections":[]}}`
+
+// weatherChainJSON 声明 outputSchema，输出作为 structuredContent 返回
+const weatherChainJSON = `{"ruleChain":{"id":"chain_weather","name":"Weather","additionalInfo":{"outputSchema":{"type":"object","properties":{"city":{"type":"string"},"temperature":{"type":"number"}},"required":["city","temperature"]}}},"metadata":{"nodes":[{"id":"s1","type":"jsTransform","name":"weather","configuration":{"jsScript":"return {'msg':{'city':msg.city,'temperature':21},'metadata':metadata,'msgType':msgType};"}}],"connections":[]}}`
+
+// plainChainJSON 输出带 content 字段的普通 JSON，仍按文本返回
+const plainChainJSON = `{"ruleChain":{"id":"chain_plain","name":"Plain"},"metadata":{"nodes":[{"id":"s1","type":"jsTransform","name":"plain","configuration":{"jsScript":"return {'msg':{'content':'hello '+msg.city},'metadata':metadata,'msgType':msgType};"}}],"connections":[]}}`
+
+func TestMcpServerEndpoint_RichContent(t *testing.T) {
+	config := rulego.NewConfig(types.WithDefaultPool())
+	for id, def := range map[string]string{"chain_chart": chartChainJSON, "chain_weather": weatherChainJSON, "chain_plain": plainChainJSON} {
+		_, err := rulego.New(id, []byte(def), types.WithConfig(config))
+		require.NoError(t, err)
+	}
+	ep, err := rulegoEndpoint.Registry.New(endpoint.Type, config, types.Configuration{
+		"server":    ":19140",
+		"basePath":  "/mcp",
+		"transport": endpoint.TransportStreamableHTTP,
+	})
+	require.NoError(t, err)
+	for name, chainId := range map[string]string{"chart": "chain_chart", "weather": "chain_weather", "plain": "chain_plain"} {
+		_, err = ep.AddRouter(rulegoEndpoint.NewRouter().From(name).To("chain:"+chainId).End(), name)
+		require.NoError(t, err)
+	}
+	require.NoError(t, ep.Start())
+	defer ep.Destroy()
+	time.Sleep(100 * time.Millisecond)
+
+	cli := connectMcpClient(t, "http://localhost:19140/mcp")
+	defer cli.Close()
+	ctx := context.Background()
+	call := func(name string) *mcp.CallToolResult {
+		result, err := cli.CallTool(ctx, mcp.CallToolRequest{Params: mcp.CallToolParams{
+			Name:      name,
+			Arguments: map[string]interface{}{"city": "Paris"},
+		}})
+		require.NoError(t, err)
+		require.False(t, result.IsError)
+		return result
+	}
+
+	t.Run("content", func(t *testing.T) {
+		result := call("chart")
+		require.Len(t, result.Content, 3)
+		assert.Equal(t, "chart of Paris", result.Content[0].(mcp.TextContent).Text)
+		image := result.Content[1].(mcp.ImageContent)
+		assert.Equal(t, "iVBORw0KGgo=", image.Data)
+		assert.Equal(t, "image/png", image.MIMEType)
+		resource := result.Content[2].(mcp.EmbeddedResource).Resource.(mcp.TextResourceContents)
+		assert.Equal(t, "file:///data.csv", resource.URI)
+		assert.Equal(t, "a,b", resource.Text)
+	})
+
+	t.Run("output schema", func(t *testing.T) {
+		tools, err := cli.ListTools(ctx, mcp.ListToolsRequest{})
+		require.NoError(t, err)
+		for _, tool := range tools.Tools {
+			if tool.Name == "weather" {
+				assert.Equal(t, "object", tool.OutputSchema.Type)
+				assert.Contains(t, tool.OutputSchema.Properties, "temperature")
+			} else {
+				assert.Empty(t, tool.OutputSchema.Type, tool.Name)
+			}
+		}
+
+		result := call("weather")
+		assert.Equal(t, map[string]interface{}{"city": "Paris", "temperature": float64(21)}, result.StructuredContent)
+		// 同时返回 JSON 文本，兼容不读取 structuredContent 的客户端
+		assert.JSONEq(t, `{"city":"Paris","temperature":21}`, result.Content[0].(mcp.TextContent).Text)
+	})
+
+	t.Run("plain json", func(t *testing.T) {
+		result := call("plain")
+		require.Len(t, result.Content, 1)
+		assert.JSONEq(t, `{"content":"hello Paris"}`, result.Content[0].(mcp.TextContent).Text)
+		assert.Nil(t, result.StructuredContent)
+	})
+}
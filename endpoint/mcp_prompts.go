@@ -0,0 +1,125 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	einoskill "github.com/cloudwego/eino/adk/middlewares/skill"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rulego/rulego-components-ai/tool/skill"
+)
+
+// skillPrompts publishes skills as MCP prompt templates and keeps them in sync with the backend,
+// so skills added, edited or removed on disk show up via notifications/prompts/list_changed.
+type skillPrompts struct {
+	mcpServer *server.MCPServer
+	backend   einoskill.Backend
+
+	mu sync.Mutex
+	// published maps prompt name to a signature of its definition
+	published map[string]string
+}
+
+func newSkillPrompts(mcpServer *server.MCPServer, backend einoskill.Backend) *skillPrompts {
+	return &skillPrompts{
+		mcpServer: mcpServer,
+		backend:   backend,
+		published: make(map[string]string),
+	}
+}
+
+// sync registers new or changed skills and removes deleted ones
+func (p *skillPrompts) sync(ctx context.Context) {
+	list, err := p.backend.List(ctx)
+	if err != nil {
+		return
+	}
+	current := make(map[string]string, len(list))
+	var changed []server.ServerPrompt
+	p.mu.Lock()
+	for _, fm := range list {
+		s, err := p.backend.Get(ctx, fm.Name)
+		if err != nil {
+			continue
+		}
+		args, err := skill.PromptArguments(s)
+		if err != nil {
+			continue
+		}
+		prompt := newSkillPrompt(s, args)
+		signature := fmt.Sprintf("%s|%v", s.Description, args)
+		current[s.Name] = signature
+		if p.published[s.Name] != signature {
+			changed = append(changed, server.ServerPrompt{Prompt: prompt, Handler: p.handler(s.Name)})
+		}
+	}
+	var removed []string
+	for name := range p.published {
+		if _, ok := current[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	p.published = current
+	p.mu.Unlock()
+
+	if len(changed) > 0 {
+		p.mcpServer.AddPrompts(changed...)
+	}
+	if len(removed) > 0 {
+		p.mcpServer.DeletePrompts(removed...)
+	}
+}
+
+// newSkillPrompt builds the prompt definition of a skill
+func newSkillPrompt(s einoskill.Skill, args []skill.PromptArgument) mcp.Prompt {
+	opts := []mcp.PromptOption{mcp.WithPromptDescription(s.Description)}
+	for _, arg := range args {
+		argOpts := []mcp.ArgumentOption{mcp.ArgumentDescription(arg.Description)}
+		if arg.Required {
+			argOpts = append(argOpts, mcp.RequiredArgument())
+		}
+		opts = append(opts, mcp.WithArgument(arg.Name, argOpts...))
+	}
+	return mcp.NewPrompt(s.Name, opts...)
+}
+
+// handler renders the skill with the current content, so edits apply without re-registering
+func (p *skillPrompts) handler(name string) server.PromptHandlerFunc {
+	return func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		s, err := p.backend.Get(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		args, err := skill.PromptArguments(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, arg := range args {
+			if arg.Required && request.Params.Arguments[arg.Name] == "" {
+				return nil, fmt.Errorf("missing required argument: %s", arg.Name)
+			}
+		}
+		text := skill.RenderPrompt(s, args, request.Params.Arguments)
+		return mcp.NewGetPromptResult(s.Description, []mcp.PromptMessage{
+			mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(text)),
+		}), nil
+	}
+}
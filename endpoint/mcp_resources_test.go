@@ -0,0 +1,292 @@
+package endpoint_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego-components-ai/endpoint"
+	"github.com/rulego/rulego-components-ai/session"
+	"github.com/rulego/rulego/api/types"
+	rulegoEndpoint "github.com/rulego/rulego/endpoint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startResourceServer 启动发布规则链、会话、文件资源与技能提示词的 MCP Server
+func startResourceServer(t *testing.T, port string, resourceDir, skillDir string, sm session.SessionManager) (string, func()) {
+	t.Helper()
+
+	config := rulego.NewConfig(types.WithDefaultPool())
+	_, err := rulego.New("test_chain_mcp", []byte(testChainJSON), types.WithConfig(config))
+	require.NoError(t, err)
+
+	ep, err := rulegoEndpoint.Registry.New(endpoint.Type, config, types.Configuration{
+		"server":               ":" + port,
+		"basePath":             "/mcp",
+		"exposeChains":         true,
+		"resourceDir":          resourceDir,
+		"skillDirs":            []string{skillDir},
+		"resourcePollInterval": 1,
+	})
+	require.NoError(t, err)
+	ep.(*endpoint.McpServer).SessionManager = sm
+
+	router := rulegoEndpoint.NewRouter().From("test_tool").To("chain:test_chain_mcp").End()
+	_, err = ep.AddRouter(router, "测试工具")
+	require.NoError(t, err)
+	require.NoError(t, ep.Start())
+	time.Sleep(100 * time.Millisecond)
+
+	return "http://localhost:" + port + "/mcp", func() { ep.Destroy() }
+}
+
+// connectSSEClient 使用 SSE 传输连接并初始化
+func connectSSEClient(t *testing.T, addr string) *client.Client {
+	t.Helper()
+	sseTransport, err := transport.NewSSE(addr + "/sse")
+	require.NoError(t, err)
+	cli := client.NewClient(sseTransport)
+
+	// SSE 流的生命周期绑定 Start 的 ctx，不能使用会被取消的 ctx
+	require.NoError(t, cli.Start(context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = cli.Initialize(ctx, mcp.InitializeRequest{
+		Params: mcp.InitializeParams{
+			ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION,
+			ClientInfo:      mcp.Implementation{Name: "Test MCP Client", Version: "1.0.0"},
+		},
+	})
+	require.NoError(t, err)
+	return cli
+}
+
+func writeTestSkill(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, name), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name, "SKILL.md"), []byte(content), 0644))
+}
+
+func TestMcpServerEndpoint_Resources(t *testing.T) {
+	resourceDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(resourceDir, "notes.md"), []byte("# notes"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(resourceDir, ".secret"), []byte("secret"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(resourceDir, "logo.bin"), []byte{0, 1, 2}, 0644))
+
+	sm := session.NewManager(session.NewMemoryStorage(), nil)
+	sess, err := sm.GetOrCreate(context.Background(), session.SessionRequest{AgentID: "agent1", Scope: session.ScopePerPeer, UserID: "u1"})
+	require.NoError(t, err)
+	require.NoError(t, sm.AddMessage(context.Background(), sess.Key, session.NewSessionMessage("user", "hello transcript")))
+
+	addr, shutdown := startResourceServer(t, "19110", resourceDir, t.TempDir(), sm)
+	defer shutdown()
+	cli := connectSSEClient(t, addr)
+	defer cli.Close()
+	ctx := context.Background()
+
+	list, err := cli.ListResources(ctx, mcp.ListResourcesRequest{})
+	require.NoError(t, err)
+	uris := map[string]mcp.Resource{}
+	for _, r := range list.Resources {
+		uris[r.URI] = r
+	}
+	chainURI := endpoint.ChainResourcePrefix + "test_chain_mcp"
+	require.Contains(t, uris, chainURI)
+	assert.Equal(t, "Test Chain MCP", uris[chainURI].Name)
+
+	var notesURI, sessionURI string
+	for uri, r := range uris {
+		assert.NotContains(t, uri, ".secret")
+		if r.Name == "notes.md" {
+			notesURI = uri
+		}
+		if strings.HasPrefix(uri, endpoint.SessionResourcePrefix) {
+			sessionURI = uri
+		}
+	}
+	require.NotEmpty(t, notesURI)
+	require.NotEmpty(t, sessionURI)
+
+	read := func(uri string) mcp.ResourceContents {
+		res, err := cli.ReadResource(ctx, mcp.ReadResourceRequest{Params: mcp.ReadResourceParams{URI: uri}})
+		require.NoError(t, err)
+		require.Len(t, res.Contents, 1)
+		return res.Contents[0]
+	}
+	assert.Contains(t, read(chainURI).(mcp.TextResourceContents).Text, "test_chain_mcp")
+	notes := read(notesURI).(mcp.TextResourceContents)
+	assert.Equal(t, "# notes", notes.Text)
+	assert.Equal(t, "text/markdown", notes.MIMEType)
+	assert.Contains(t, read(sessionURI).(mcp.TextResourceContents).Text, "hello transcript")
+	for uri, r := range uris {
+		if r.Name == "logo.bin" {
+			assert.Equal(t, "AAEC", read(uri).(mcp.BlobResourceContents).Blob)
+		}
+	}
+}
+
+// secretChainJSON 节点配置中带有密钥的规则链
+const secretChainJSON = `{"ruleChain":{"id":"chain_secret","name":"Secret Chain","configuration":{"secrets":{"openai":"sk-chain-secret"}}},"metadata":{"nodes":[{"id":"s1","type":"jsTransform","name":"转换","configuration":{"jsScript":"return {'msg':msg,'metadata':metadata,'msgType':msgType};","apiKey":"sk-node-secret","dsn":"root:db-secret@tcp(db:3306)/app","server":"redis://:redis-secret@cache:6379/0","headers":{"Authorization":"Bearer header-secret"},"maxTokens":1024}}],"connections":[]}}`
+
+func TestMcpServerEndpoint_ChainResourceRedactsSecrets(t *testing.T) {
+	config := rulego.NewConfig(types.WithDefaultPool())
+	_, err := rulego.New("chain_secret", []byte(secretChainJSON), types.WithConfig(config))
+	require.NoError(t, err)
+	ep, err := rulegoEndpoint.Registry.New(endpoint.Type, config, types.Configuration{
+		"server":       ":19113",
+		"basePath":     "/mcp",
+		"exposeChains": true,
+	})
+	require.NoError(t, err)
+	_, err = ep.AddRouter(rulegoEndpoint.NewRouter().From("secret_tool").To("chain:chain_secret").End(), "secret tool")
+	require.NoError(t, err)
+	require.NoError(t, ep.Start())
+	defer ep.Destroy()
+	time.Sleep(100 * time.Millisecond)
+	cli := connectSSEClient(t, "http://localhost:19113/mcp")
+	defer cli.Close()
+
+	res, err := cli.ReadResource(context.Background(), mcp.ReadResourceRequest{Params: mcp.ReadResourceParams{URI: endpoint.ChainResourcePrefix + "chain_secret"}})
+	require.NoError(t, err)
+	text := res.Contents[0].(mcp.TextResourceContents).Text
+	for _, secret := range []string{"sk-chain-secret", "sk-node-secret", "db-secret", "redis-secret", "header-secret"} {
+		assert.NotContains(t, text, secret)
+	}
+	assert.Contains(t, text, "cache:6379")
+	assert.Contains(t, text, "jsScript")
+	assert.Contains(t, text, "1024")
+}
+
+func TestMcpServerEndpoint_ResourceSubscribe(t *testing.T) {
+	resourceDir := t.TempDir()
+	file := filepath.Join(resourceDir, "data.txt")
+	require.NoError(t, os.WriteFile(file, []byte("v1"), 0644))
+
+	addr, shutdown := startResourceServer(t, "19111", resourceDir, t.TempDir(), nil)
+	defer shutdown()
+	cli := connectSSEClient(t, addr)
+	defer cli.Close()
+	ctx := context.Background()
+
+	updated := make(chan string, 10)
+	listChanged := make(chan struct{}, 10)
+	cli.OnNotification(func(n mcp.JSONRPCNotification) {
+		switch n.Method {
+		case mcp.MethodNotificationResourceUpdated:
+			uri, _ := n.Params.AdditionalFields["uri"].(string)
+			updated <- uri
+		case mcp.MethodNotificationResourcesListChanged:
+			listChanged <- struct{}{}
+		}
+	})
+
+	list, err := cli.ListResources(ctx, mcp.ListResourcesRequest{})
+	require.NoError(t, err)
+	var uri string
+	for _, r := range list.Resources {
+		if r.Name == "data.txt" {
+			uri = r.URI
+		}
+	}
+	require.NotEmpty(t, uri)
+	require.NoError(t, cli.Subscribe(ctx, mcp.SubscribeRequest{Params: mcp.SubscribeParams{URI: uri}}))
+
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, os.WriteFile(file, []byte("version 2"), 0644))
+	select {
+	case got := <-updated:
+		assert.Equal(t, uri, got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected notifications/resources/updated")
+	}
+
+	// 新增文件触发 list_changed
+	require.NoError(t, os.WriteFile(filepath.Join(resourceDir, "new.txt"), []byte("new"), 0644))
+	select {
+	case <-listChanged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected notifications/resources/list_changed")
+	}
+
+	// 取消订阅后不再通知
+	require.NoError(t, cli.Unsubscribe(ctx, mcp.UnsubscribeRequest{Params: mcp.UnsubscribeParams{URI: uri}}))
+	for len(updated) > 0 {
+		<-updated
+	}
+	require.NoError(t, os.WriteFile(file, []byte("version three"), 0644))
+	select {
+	case <-updated:
+		t.Fatal("unexpected notification after unsubscribe")
+	case <-time.After(2500 * time.Millisecond):
+	}
+}
+
+func TestMcpServerEndpoint_SkillPrompts(t *testing.T) {
+	skillDir := t.TempDir()
+	writeTestSkill(t, skillDir, "pdf-extract", "---\nname: pdf-extract\ndescription: Extract text from a PDF\narguments:\n  - name: file\n    description: PDF file\n    required: true\n---\nExtract the text of ${file}.")
+	writeTestSkill(t, skillDir, "review", "---\nname: review\ndescription: Review code\n---\nReview $ARGUMENTS carefully.")
+
+	addr, shutdown := startResourceServer(t, "19112", t.TempDir(), skillDir, nil)
+	defer shutdown()
+	cli := connectSSEClient(t, addr)
+	defer cli.Close()
+	ctx := context.Background()
+
+	prompts, err := cli.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	require.NoError(t, err)
+	byName := map[string]mcp.Prompt{}
+	for _, p := range prompts.Prompts {
+		byName[p.Name] = p
+	}
+	require.Contains(t, byName, "pdf-extract")
+	require.Contains(t, byName, "review")
+	assert.Equal(t, "Extract text from a PDF", byName["pdf-extract"].Description)
+	require.Len(t, byName["pdf-extract"].Arguments, 1)
+	assert.Equal(t, "file", byName["pdf-extract"].Arguments[0].Name)
+	assert.True(t, byName["pdf-extract"].Arguments[0].Required)
+	require.Len(t, byName["review"].Arguments, 1)
+	assert.Equal(t, "arguments", byName["review"].Arguments[0].Name)
+
+	get := func(name string, args map[string]string) (string, error) {
+		res, err := cli.GetPrompt(ctx, mcp.GetPromptRequest{Params: mcp.GetPromptParams{Name: name, Arguments: args}})
+		if err != nil {
+			return "", err
+		}
+		require.Len(t, res.Messages, 1)
+		return res.Messages[0].Content.(mcp.TextContent).Text, nil
+	}
+	text, err := get("pdf-extract", map[string]string{"file": "a.pdf"})
+	require.NoError(t, err)
+	assert.Contains(t, text, "Extract the text of a.pdf.")
+	assert.Contains(t, text, "Base directory for this skill:")
+
+	text, err = get("review", map[string]string{"arguments": "main.go"})
+	require.NoError(t, err)
+	assert.Contains(t, text, "Review main.go carefully.")
+
+	_, err = get("pdf-extract", nil)
+	assert.Error(t, err)
+
+	// 新增技能后提示词列表热更新
+	writeTestSkill(t, skillDir, "deploy", "---\nname: deploy\ndescription: Deploy\n---\nDeploy.")
+	require.Eventually(t, func() bool {
+		prompts, err := cli.ListPrompts(ctx, mcp.ListPromptsRequest{})
+		if err != nil {
+			return false
+		}
+		for _, p := range prompts.Prompts {
+			if p.Name == "deploy" {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 200*time.Millisecond)
+}
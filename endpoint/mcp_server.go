@@ -18,21 +18,33 @@
 // It allows exposing rule chains as MCP tools, enabling LLMs to interact with RuleGo workflows.
 //
 // Key Features:
-// - Expose RuleChains as MCP Tools automatically or manually.
-// - Support SSE (Server-Sent Events) transport for MCP.
-// - Dynamic tool registration based on RuleChain definition.
+//   - Expose RuleChains as MCP Tools automatically or manually.
+//   - Serve MCP over Streamable HTTP (with session IDs and resumable streams), stdio,
+//     or the legacy SSE (Server-Sent Events) transport.
+//   - Dynamic tool registration based on RuleChain definition.
+//   - Publish rule-chain definitions, session transcripts and whitelisted files as MCP resources,
+//     with resources/subscribe change notifications.
+//   - Publish skills as MCP prompt templates.
+//   - Authenticate callers with bearer tokens (static or HMAC-signed JWT), restrict tools per caller
+//     and pass the caller to rule chains as message metadata.
 package endpoint
 
 import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
 
+	einoskill "github.com/cloudwego/eino/adk/middlewares/skill"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/rulego/rulego-components-ai/aspect"
+	"github.com/rulego/rulego-components-ai/session"
+	"github.com/rulego/rulego-components-ai/tool/skill"
 	"github.com/rulego/rulego/api/types"
 	"github.com/rulego/rulego/api/types/endpoint"
 	endpointImpl "github.com/rulego/rulego/endpoint"
@@ -63,6 +75,9 @@ func init() {
 
 var DefaultSSEServerPool = NewSSEServerCache()
 
+// mcpServers maps the ID of pooled SSE servers to their McpServer, used to route subscriptions
+var mcpServers sync.Map
+
 // SSEServerPool manages SSE servers, keyed by ID (e.g., RuleChain ID)
 type SSEServerPool struct {
 	sync.RWMutex
@@ -100,28 +115,65 @@ func (p *SSEServerPool) Delete(id string) {
 
 // Config defines the configuration for McpServer
 type Config struct {
-	Server     string `json:"server" label:"Server Address" desc:"Address to listen on, e.g. :8080" required:"true"`
-	CertFile   string `json:"certFile" label:"Cert File" desc:"TLS certificate file path"`
+	Server      string `json:"server" label:"Server Address" desc:"Address to listen on, e.g. :8080" required:"true"`
+	CertFile    string `json:"certFile" label:"Cert File" desc:"TLS certificate file path"`
 	CertKeyFile string `json:"certKeyFile" label:"Cert Key File" desc:"TLS private key file path"`
-	AllowCors  bool   `json:"allowCors" label:"Allow CORS" desc:"Enable Cross-Origin Resource Sharing"`
-	Name       string `json:"name" label:"Server Name" desc:"MCP server name. Defaults to RuleChain name"`
-	Version    string `json:"version" label:"Server Version" desc:"MCP server version. Defaults to RuleChain version"`
-	BasePath   string `json:"basePath" label:"Base Path" desc:"Root path for MCP endpoints. Defaults to /api/v1/rules/{ruleChain.id}/mcp"`
+	AllowCors   bool   `json:"allowCors" label:"Allow CORS" desc:"Enable Cross-Origin Resource Sharing"`
+	Name        string `json:"name" label:"Server Name" desc:"MCP server name. Defaults to RuleChain name"`
+	Version     string `json:"version" label:"Server Version" desc:"MCP server version. Defaults to RuleChain version"`
+	BasePath    string `json:"basePath" label:"Base Path" desc:"Root path for MCP endpoints. Defaults to /api/v1/rules/{ruleChain.id}/mcp"`
+	// Transport selects how clients connect: sse (default, for backward compatibility), streamableHttp or stdio
+	Transport string `json:"transport" label:"Transport" desc:"MCP transport: sse serves {basePath}/sse and {basePath}/message, streamableHttp serves {basePath}, stdio serves stdin/stdout. Defaults to sse" component:"{\"type\":\"select\",\"options\":[{\"label\":\"SSE\",\"value\":\"sse\"},{\"label\":\"Streamable HTTP\",\"value\":\"streamableHttp\"},{\"label\":\"stdio\",\"value\":\"stdio\"}]}"`
+	// EventHistorySize is the number of events kept per Streamable HTTP stream for resuming with Last-Event-ID
+	EventHistorySize int `json:"eventHistorySize" label:"Event History Size" desc:"Number of events kept per Streamable HTTP stream so clients can resume with Last-Event-ID. Defaults to 100"`
+	// Auth enables bearer-token authentication and per-tool authorization for the HTTP transports
+	Auth *AuthConfig `json:"auth" label:"Authentication" desc:"Bearer-token authentication (static tokens and HMAC-signed JWTs) and per-tool authorization. Empty accepts any caller"`
+	// ExposeChains publishes the definitions of rule chains served as tools as rulego://chains/{id} resources.
+	// Secrets in node configurations (API keys, tokens, passwords, DSNs and URL passwords) are masked
+	ExposeChains bool `json:"exposeChains" label:"Expose RuleChains" desc:"Publish the definitions of rule chains served as tools as MCP resources, with secrets in node configurations masked"`
+	// ResourceDir publishes the files under this directory as file:// resources
+	ResourceDir string `json:"resourceDir" label:"Resource Directory" desc:"Publish the files under this directory as MCP resources. Empty disables file resources"`
+	// SkillDirs publishes the skills (SKILL.md) in these directories as MCP prompts, earlier directories take precedence
+	SkillDirs []string `json:"skillDirs" label:"Skill Directories" desc:"Publish the skills in these directories as MCP prompts. Earlier directories take precedence"`
+	// ResourcePollInterval is the interval in seconds for refreshing resources and prompts and detecting changes
+	ResourcePollInterval int `json:"resourcePollInterval" label:"Resource Poll Interval" desc:"Interval in seconds for refreshing resources and prompts and notifying subscribers of changes. Defaults to 2"`
 }
 
 // McpServer implements the Model Context Protocol (MCP) server endpoint.
-// It wraps a REST server to provide SSE transport and manages MCP tools derived from RuleChains.
+// It wraps a REST server to provide the SSE or Streamable HTTP transport, or serves stdio,
+// and manages MCP tools derived from RuleChains.
 type McpServer struct {
 	*rest.Rest
 	// Config is the server configuration
 	Config Config
 	// SSEServerPool manages multiple SSE server instances
 	SSEServerPool *SSEServerPool
-
-	mcpServer *server.MCPServer
-	sseServer *server.SSEServer
-	mcpOnce   sync.Once
-	sseOnce   sync.Once
+	// SessionManager publishes session transcripts as rulego://sessions/{key} resources when set
+	// and it implements session.PortableManager.
+	// It must be set before routers are added or the server is started.
+	SessionManager session.SessionManager
+	// SkillBackend publishes skills as MCP prompts. Defaults to a skill.MultiBackend over Config.SkillDirs.
+	SkillBackend einoskill.Backend
+	// Stdin and Stdout override os.Stdin and os.Stdout for the stdio transport
+	Stdin  io.Reader
+	Stdout io.Writer
+
+	mcpServer        *server.MCPServer
+	sseServer        *server.SSEServer
+	streamableServer *server.StreamableHTTPServer
+	mcpOnce          sync.Once
+	sseOnce          sync.Once
+	streamableOnce   sync.Once
+	// auth authenticates HTTP callers, nil if authentication is disabled
+	auth *authenticator
+	// events keeps recent Streamable HTTP events for resumption
+	events *eventStore
+	// stopStdio stops the stdio transport
+	stopStdio context.CancelFunc
+	// done is closed when the endpoint stops, see Done
+	done       chan struct{}
+	doneOnce   sync.Once
+	finishOnce sync.Once
 	// ruleChain is the associated rule chain definition (if any)
 	ruleChain *types.RuleChain
 	// basePath is the effective base path for routes
@@ -131,6 +183,15 @@ type McpServer struct {
 	// defaultBasePath indicates if the path was auto-generated
 	defaultBasePath bool
 	sseServerId     string
+
+	// chains holds the rule chains published as resources
+	chains *chainResources
+	// resources keeps resources registered and tracks subscriptions
+	resources *resourceHub
+	// prompts keeps skill prompts registered
+	prompts *skillPrompts
+	// stopPoll stops the resource and prompt refresh loop
+	stopPoll context.CancelFunc
 }
 
 // Type returns the component type
@@ -191,7 +252,21 @@ func (s *McpServer) Init(ruleConfig types.Config, configuration types.Configurat
 		s.baseFullPath = base
 	}
 
-	if base == "" {
+	switch s.Config.Transport {
+	case "":
+		s.Config.Transport = TransportSSE
+	case TransportSSE, TransportStreamableHTTP, TransportStdio:
+	default:
+		return fmt.Errorf("unsupported transport: %s", s.Config.Transport)
+	}
+	s.events = newEventStore(s.Config.EventHistorySize)
+	if s.Config.Auth != nil && s.Config.Transport != TransportStdio {
+		if s.auth, err = newAuthenticator(*s.Config.Auth); err != nil {
+			return err
+		}
+	}
+
+	if base == "" && s.Config.Transport != TransportStdio {
 		return fmt.Errorf("basePath can not be empty")
 	}
 	s.basePath = base
@@ -199,6 +274,13 @@ func (s *McpServer) Init(ruleConfig types.Config, configuration types.Configurat
 	if !strings.HasPrefix(s.basePath, "/") {
 		s.basePath = "/" + s.basePath
 	}
+
+	if s.Config.ExposeChains {
+		s.chains = newChainResources()
+	}
+	if s.SkillBackend == nil && len(s.Config.SkillDirs) > 0 {
+		s.SkillBackend = skill.NewMultiBackend(s.Config.SkillDirs)
+	}
 	return err
 }
 
@@ -247,15 +329,35 @@ func (s *McpServer) Printf(format string, v ...interface{}) {
 	}
 }
 
-// Start starts the HTTP server and registers SSE endpoints
+// Start starts the HTTP server and registers the transport endpoints, or serves stdio
 func (s *McpServer) Start() error {
-	// Register SSE and Message endpoints
-	if s.defaultBasePath && !s.Rest.HasRouter(s.basePath+"/sse") {
-		s.Rest.GET(s.handlerFromPool(s.basePath + "/sse"))
-		s.Rest.POST(s.handlerFromPool(s.basePath + "/message"))
-	} else {
-		s.Rest.GET(s.handler(s.basePath + "/sse"))
-		s.Rest.POST(s.handler(s.basePath + "/message"))
+	switch s.Config.Transport {
+	case TransportStdio:
+		return s.startStdio()
+	case TransportStreamableHTTP:
+		// Register the single Streamable HTTP endpoint
+		if s.defaultBasePath && !s.Rest.HasRouter(s.basePath) {
+			s.Rest.GET(s.streamableHandlerFromPool(s.basePath))
+			s.Rest.POST(s.streamableHandlerFromPool(s.basePath))
+			s.Rest.DELETE(s.streamableHandlerFromPool(s.basePath))
+		} else {
+			s.Rest.GET(s.streamableHandler(s.basePath))
+			s.Rest.POST(s.streamableHandler(s.basePath))
+			s.Rest.DELETE(s.streamableHandler(s.basePath))
+		}
+	default:
+		// Register SSE and Message endpoints
+		if s.defaultBasePath && !s.Rest.HasRouter(s.basePath+"/sse") {
+			s.Rest.GET(s.handlerFromPool(s.basePath + "/sse"))
+			s.Rest.POST(s.handlerFromPool(s.basePath + "/message"))
+		} else {
+			s.Rest.GET(s.handler(s.basePath + "/sse"))
+			s.Rest.POST(s.handler(s.basePath + "/message"))
+		}
+	}
+
+	if err := s.startResources(); err != nil {
+		return err
 	}
 
 	if s.Rest.Started() {
@@ -272,7 +374,10 @@ func (s *McpServer) Start() error {
 			sseServerId = s.ruleChain.RuleChain.ID
 		}
 		s.sseServerId = sseServerId
-		s.GetSSEServerPool().Add(s.sseServerId, s.SSEServer())
+		if s.Config.Transport == TransportSSE {
+			s.GetSSEServerPool().Add(s.sseServerId, s.SSEServer())
+		}
+		mcpServers.Store(s.sseServerId, s)
 	}
 
 	return nil
@@ -285,24 +390,40 @@ func (s *McpServer) Destroy() {
 
 // Desc returns the component description
 func (s *McpServer) Desc() string {
-	return "Expose RuleChains as MCP tools via SSE transport. Supports dynamic tool registration, route targeting and CORS"
+	return "Expose RuleChains as MCP tools via Streamable HTTP, stdio or SSE transport, with rule-chain, session and file resources and skill prompts. Supports dynamic tool registration, route targeting and CORS"
 }
 
 // Close stops the server and cleans up
 func (s *McpServer) Close() error {
+	if s.stopPoll != nil {
+		s.stopPoll()
+	}
+	if s.stopStdio != nil {
+		s.stopStdio()
+	}
 	if s.sseServer != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 		defer cancel()
 		_ = s.sseServer.Shutdown(ctx)
 	}
 	if !s.defaultBasePath && s.Rest != nil {
-		_ = s.Rest.RemoveRouter(s.Rest.RouterKey("GET", s.basePath+"/sse"))
-		_ = s.Rest.RemoveRouter(s.Rest.RouterKey("POST", s.basePath+"/message"))
+		if s.Config.Transport == TransportStreamableHTTP {
+			_ = s.Rest.RemoveRouter(s.Rest.RouterKey("GET", s.basePath))
+			_ = s.Rest.RemoveRouter(s.Rest.RouterKey("POST", s.basePath))
+			_ = s.Rest.RemoveRouter(s.Rest.RouterKey("DELETE", s.basePath))
+		} else {
+			_ = s.Rest.RemoveRouter(s.Rest.RouterKey("GET", s.basePath+"/sse"))
+			_ = s.Rest.RemoveRouter(s.Rest.RouterKey("POST", s.basePath+"/message"))
+		}
 	}
 	if s.Rest != nil {
 		s.Rest.Destroy()
 	}
 	s.GetSSEServerPool().Delete(s.sseServerId)
+	if s.sseServerId != "" {
+		mcpServers.CompareAndDelete(s.sseServerId, s)
+	}
+	s.finish()
 
 	return nil
 }
@@ -355,7 +476,12 @@ func (s *McpServer) addRouter(router endpoint.Router, desc string, inputSchema s
 func (s *McpServer) handler(url string) endpoint.Router {
 	return endpointImpl.NewRouter().From(url).Process(func(router endpoint.Router, exchange *endpoint.Exchange) bool {
 		inMsg := exchange.In.(*rest.RequestMessage)
-		s.SSEServer().ServeHTTP(inMsg.Response(), inMsg.Request())
+		r, ok := s.authorize(inMsg.Response(), inMsg.Request())
+		if !ok {
+			return true
+		}
+		s.interceptSubscription(r)
+		s.SSEServer().ServeHTTP(inMsg.Response(), r)
 		return true
 	}).End()
 }
@@ -366,10 +492,48 @@ func (s *McpServer) handlerFromPool(url string) endpoint.Router {
 		inMsg := exchange.In.(*rest.RequestMessage)
 		id := inMsg.Metadata.GetValue(KeyId)
 		if sseServer, ok := s.GetSSEServerPool().Get(id); ok {
-			sseServer.ServeHTTP(inMsg.Response(), inMsg.Request())
+			target := s
+			if v, ok := mcpServers.Load(id); ok {
+				target = v.(*McpServer)
+			}
+			r, ok := target.authorize(inMsg.Response(), inMsg.Request())
+			if !ok {
+				return true
+			}
+			target.interceptSubscription(r)
+			sseServer.ServeHTTP(inMsg.Response(), r)
 		} else {
 			// Fallback to default server if ID not found
-			s.SSEServer().ServeHTTP(inMsg.Response(), inMsg.Request())
+			r, ok := s.authorize(inMsg.Response(), inMsg.Request())
+			if !ok {
+				return true
+			}
+			s.interceptSubscription(r)
+			s.SSEServer().ServeHTTP(inMsg.Response(), r)
+		}
+		return true
+	}).End()
+}
+
+// streamableHandler creates a router for the Streamable HTTP server
+func (s *McpServer) streamableHandler(url string) endpoint.Router {
+	return endpointImpl.NewRouter().From(url).Process(func(router endpoint.Router, exchange *endpoint.Exchange) bool {
+		inMsg := exchange.In.(*rest.RequestMessage)
+		s.serveStreamable(inMsg.Response(), inMsg.Request())
+		return true
+	}).End()
+}
+
+// streamableHandlerFromPool creates a router that selects the Streamable HTTP server based on ID
+func (s *McpServer) streamableHandlerFromPool(url string) endpoint.Router {
+	return endpointImpl.NewRouter().From(url).Process(func(router endpoint.Router, exchange *endpoint.Exchange) bool {
+		inMsg := exchange.In.(*rest.RequestMessage)
+		id := inMsg.Metadata.GetValue(KeyId)
+		if target, ok := mcpServers.Load(id); ok && target.(*McpServer).Config.Transport == TransportStreamableHTTP {
+			target.(*McpServer).serveStreamable(inMsg.Response(), inMsg.Request())
+		} else {
+			// Fallback to default server if ID not found
+			s.serveStreamable(inMsg.Response(), inMsg.Request())
 		}
 		return true
 	}).End()
@@ -391,6 +555,15 @@ func (s *McpServer) SSEServer() *server.SSEServer {
 	return s.sseServer
 }
 
+// StreamableHTTPServer returns the underlying StreamableHTTPServer instance, creating it if needed.
+// Sessions are stateful: the server issues Mcp-Session-Id on initialize and rejects unknown IDs.
+func (s *McpServer) StreamableHTTPServer() *server.StreamableHTTPServer {
+	s.streamableOnce.Do(func() {
+		s.streamableServer = server.NewStreamableHTTPServer(s.MCPServer(), server.WithStateful(true))
+	})
+	return s.streamableServer
+}
+
 // DeleteTools removes tools from the MCP server
 func (s *McpServer) DeleteTools(names ...string) {
 	if s.mcpServer != nil {
@@ -408,13 +581,86 @@ func (s *McpServer) GetSSEServerPool() *SSEServerPool {
 
 // NewMCPServer creates a configured MCPServer instance
 func (s *McpServer) NewMCPServer() *server.MCPServer {
+	// Routes are added and removed at runtime, so clients are told when the tool list changes
+	opts := []server.ServerOption{server.WithToolCapabilities(true)}
+	if s.hasResources() {
+		opts = append(opts, server.WithResourceCapabilities(true, true))
+	}
+	if s.SkillBackend != nil {
+		opts = append(opts, server.WithPromptCapabilities(true))
+	}
+	if s.auth != nil {
+		opts = append(opts, s.authServerOptions()...)
+	}
 	mcpServer := server.NewMCPServer(
 		s.Config.Name,
 		s.Config.Version,
+		opts...,
 	)
 	return mcpServer
 }
 
+// hasResources reports whether any resource family is configured
+func (s *McpServer) hasResources() bool {
+	return s.chains != nil || s.SessionManager != nil || s.Config.ResourceDir != ""
+}
+
+// startResources registers resources and prompts and starts the refresh loop
+func (s *McpServer) startResources() error {
+	if s.stopPoll != nil || (!s.hasResources() && s.SkillBackend == nil) {
+		return nil
+	}
+	mcpServer := s.MCPServer()
+	var providers []resourceProvider
+	if s.chains != nil {
+		providers = append(providers, s.chains)
+	}
+	if exporter, ok := s.SessionManager.(session.PortableManager); ok {
+		providers = append(providers, &sessionResources{manager: s.SessionManager, exporter: exporter})
+	}
+	if s.Config.ResourceDir != "" {
+		files, err := newFileResources(s.Config.ResourceDir)
+		if err != nil {
+			return err
+		}
+		providers = append(providers, files)
+	}
+	var syncPrompts func(ctx context.Context)
+	if s.SkillBackend != nil {
+		s.prompts = newSkillPrompts(mcpServer, s.SkillBackend)
+		syncPrompts = s.prompts.sync
+	}
+	s.resources = newResourceHub(mcpServer, providers...)
+	if s.auth != nil {
+		s.resources.allow = s.canReadResource
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopPoll = cancel
+	s.resources.sync(ctx)
+	if syncPrompts != nil {
+		syncPrompts(ctx)
+	}
+	interval := s.Config.ResourcePollInterval
+	if interval <= 0 {
+		interval = DefaultResourcePollInterval
+	}
+	go s.resources.run(ctx, time.Duration(interval)*time.Second, syncPrompts)
+	return nil
+}
+
+// interceptSubscription records resources/subscribe requests of an SSE or Streamable HTTP message POST
+func (s *McpServer) interceptSubscription(r *http.Request) {
+	if s.resources == nil {
+		return
+	}
+	sessionID := r.URL.Query().Get("sessionId")
+	if sessionID == "" {
+		sessionID = r.Header.Get(server.HeaderKeySessionID)
+	}
+	s.resources.interceptHTTP(r, sessionID)
+}
+
 // NewSSEServer creates a configured SSEServer instance
 func (s *McpServer) NewSSEServer(opts ...server.SSEOption) *server.SSEServer {
 	return server.NewSSEServer(s.mcpServer, opts...)
@@ -471,13 +717,25 @@ func (s *McpServer) AddToolsFromChain(chainId, startNodeId string, def types.Rul
 			}
 
 		}
+		// Declare the structured output of the tool, the rule chain output then becomes structuredContent
+		structured := false
+		if outputSchema, ok := def.RuleChain.AdditionalInfo[KeyOutputSchema]; ok && outputSchema != nil {
+			if schema, err := json.Marshal(outputSchema); err == nil {
+				tool.RawOutputSchema = schema
+				structured = true
+			}
+		}
+		if s.chains != nil {
+			s.chains.add(chainId, router.GetRuleGo(nil))
+		}
 		// Register tool handler
-		s.MCPServer().AddTool(tool, s.ruleChainToolHandler(chainId, startNodeId, router.GetRuleGo(nil)))
+		s.MCPServer().AddTool(tool, s.ruleChainToolHandler(chainId, startNodeId, router.GetRuleGo(nil), structured))
 	}
 }
 
 // ruleChainToolHandler creates the callback function for tool execution
-func (s *McpServer) ruleChainToolHandler(chainId, startNodeId string, pool types.RuleEnginePool) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+// structured is true when the tool declares an output schema
+func (s *McpServer) ruleChainToolHandler(chainId, startNodeId string, pool types.RuleEnginePool, structured bool) func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		ruleEngine, ok := pool.Get(chainId)
 		if !ok {
@@ -522,7 +780,16 @@ func (s *McpServer) ruleChainToolHandler(chainId, startNodeId string, pool types
 		// Use OnMsgAndWait if possible, but we are manually waiting with WaitGroup to capture result properly
 		// Actually, ruleEngine.OnMsgAndWait blocks until OnEnd, so we can use it directly or use OnMsg + WaitGroup.
 		// Using OnMsg + WaitGroup allows us to capture the result in the callback closure.
-		ruleEngine.OnMsg(types.NewMsgWithJsonData(msg), opts...)
+		ruleMsg := types.NewMsgWithJsonData(msg)
+		// Pass the authenticated caller so downstream nodes and SessionAspect can scope per caller
+		if p, ok := PrincipalFromContext(ctx); ok {
+			ruleMsg.Metadata.PutValue(KeyPrincipal, p.Subject)
+			ruleMsg.Metadata.PutValue(aspect.MetaUserID, p.Subject)
+			if claims, err := json.Marshal(p.Claims); err == nil {
+				ruleMsg.Metadata.PutValue(KeyPrincipalClaims, string(claims))
+			}
+		}
+		ruleEngine.OnMsg(ruleMsg, opts...)
 
 		// 使用带超时的等待，防止永久阻塞
 		done := make(chan struct{})
@@ -543,9 +810,7 @@ func (s *McpServer) ruleChainToolHandler(chainId, startNodeId string, pool types
 			return mcp.NewToolResultError(fmt.Sprintf("Execution error: %v", resultErr)), nil
 		}
 
-		// Try to parse result as MCP Content if it's a valid JSON
-		// This allows RuleChains to return rich content (images, resources) if they follow MCP schema
-		// TODO: Implement richer content parsing if needed. For now, return as Text.
-		return mcp.NewToolResultText(result), nil
+		// Rule chains can return rich content (images, resources, structuredContent) by following the MCP result schema
+		return toolResultFromOutput(result, structured), nil
 	}
 }
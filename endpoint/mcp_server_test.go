@@ -68,10 +68,11 @@ func startMcpServerEndpoint(t *testing.T, port string) (string, func()) {
 	require.NoError(t, err)
 
 	endpointConfig := types.Configuration{
-		"server":   ":" + port,
-		"basePath": "/mcp",
-		"name":     "Test MCP Server",
-		"version":  "1.0.0",
+		"server":    ":" + port,
+		"basePath":  "/mcp",
+		"name":      "Test MCP Server",
+		"version":   "1.0.0",
+		"transport": endpoint.TransportStreamableHTTP,
 	}
 
 	ep, err := rulegoEndpoint.Registry.New(endpoint.Type, config, endpointConfig)
@@ -196,10 +197,11 @@ func TestMcpServerEndpoint_MultipleTools(t *testing.T) {
 	require.NoError(t, err)
 
 	endpointConfig := types.Configuration{
-		"server":   ":19102",
-		"basePath": "/mcp",
-		"name":     "Multi Tool Server",
-		"version":  "1.0.0",
+		"server":    ":19102",
+		"basePath":  "/mcp",
+		"name":      "Multi Tool Server",
+		"version":   "1.0.0",
+		"transport": endpoint.TransportStreamableHTTP,
 	}
 
 	ep, err := rulegoEndpoint.Registry.New(endpoint.Type, config, endpointConfig)
@@ -285,7 +287,8 @@ func TestMcpServerWithDsl(t *testing.T) {
 		"name": "MCP Server",
 		"configuration": {
 			"server": ":19104",
-			"basePath": "/mcp-dsl"
+			"basePath": "/mcp-dsl",
+			"transport": "streamableHttp"
 		},
 		"routers": [
 			{
@@ -0,0 +1,321 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package endpoint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// TransportSSE serves MCP over the HTTP+SSE transport (GET {basePath}/sse, POST {basePath}/message).
+	// It is deprecated by the MCP specification and kept for backward compatibility.
+	TransportSSE = "sse"
+	// TransportStreamableHTTP serves MCP over the Streamable HTTP transport on {basePath}
+	TransportStreamableHTTP = "streamableHttp"
+	// TransportStdio serves MCP over stdin/stdout, for running the rule engine as a local MCP server binary
+	TransportStdio = "stdio"
+)
+
+const (
+	// DefaultEventHistorySize is the default number of events kept per stream for resuming
+	DefaultEventHistorySize = 100
+	// headerLastEventID is sent by clients reconnecting to a Streamable HTTP stream
+	headerLastEventID = "Last-Event-ID"
+	// maxStreamsPerSession bounds the streams remembered per session, oldest are dropped first
+	maxStreamsPerSession = 16
+	// eventRetention is how long the events of an inactive session are kept
+	eventRetention = 10 * time.Minute
+	// stdioSessionID is the fixed session ID of the single stdio client
+	stdioSessionID = "stdio"
+)
+
+// eventStore keeps the recent server-sent events of Streamable HTTP sessions so a client can
+// resume a broken stream by reconnecting with Last-Event-ID. Event IDs have the form
+// {streamId}-{seq}, which identifies the stream to continue and the position in it.
+type eventStore struct {
+	mu       sync.Mutex
+	size     int
+	nextID   uint64
+	sessions map[string]*sessionEvents
+}
+
+type sessionEvents struct {
+	streams    map[string]*streamEvents
+	order      []string
+	lastActive time.Time
+}
+
+type streamEvents struct {
+	seq    uint64
+	events []storedEvent
+}
+
+type storedEvent struct {
+	seq   uint64
+	frame []byte
+}
+
+func newEventStore(size int) *eventStore {
+	if size <= 0 {
+		size = DefaultEventHistorySize
+	}
+	return &eventStore{size: size, sessions: make(map[string]*sessionEvents)}
+}
+
+// newStream starts a new stream in the session and returns its ID
+func (e *eventStore) newStream(sessionID string) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sweep()
+	sess := e.sessions[sessionID]
+	if sess == nil {
+		sess = &sessionEvents{streams: make(map[string]*streamEvents)}
+		e.sessions[sessionID] = sess
+	}
+	sess.lastActive = time.Now()
+	e.nextID++
+	streamID := strconv.FormatUint(e.nextID, 10)
+	sess.streams[streamID] = &streamEvents{}
+	sess.order = append(sess.order, streamID)
+	if len(sess.order) > maxStreamsPerSession {
+		delete(sess.streams, sess.order[0])
+		sess.order = sess.order[1:]
+	}
+	return streamID
+}
+
+// append stores an SSE frame and returns it with its id line
+func (e *eventStore) append(sessionID, streamID string, frame []byte) []byte {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sess := e.sessions[sessionID]
+	if sess == nil || sess.streams[streamID] == nil {
+		return frame
+	}
+	stream := sess.streams[streamID]
+	stream.seq++
+	sess.lastActive = time.Now()
+	stored := make([]byte, 0, len(frame)+32)
+	stored = append(stored, fmt.Sprintf("id: %s-%d\n", streamID, stream.seq)...)
+	stored = append(stored, frame...)
+	stream.events = append(stream.events, storedEvent{seq: stream.seq, frame: stored})
+	if len(stream.events) > e.size {
+		stream.events = stream.events[len(stream.events)-e.size:]
+	}
+	return stored
+}
+
+// replay returns the stream identified by lastEventID and the frames sent after it.
+// ok is false if the stream is unknown or the events after lastEventID are no longer kept.
+func (e *eventStore) replay(sessionID, lastEventID string) (streamID string, frames [][]byte, ok bool) {
+	i := strings.LastIndex(lastEventID, "-")
+	if i <= 0 {
+		return "", nil, false
+	}
+	streamID = lastEventID[:i]
+	seq, err := strconv.ParseUint(lastEventID[i+1:], 10, 64)
+	if err != nil {
+		return "", nil, false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	sess := e.sessions[sessionID]
+	if sess == nil || sess.streams[streamID] == nil {
+		return "", nil, false
+	}
+	stream := sess.streams[streamID]
+	if seq > stream.seq || len(stream.events) > 0 && stream.events[0].seq > seq+1 {
+		return "", nil, false
+	}
+	sess.lastActive = time.Now()
+	for _, ev := range stream.events {
+		if ev.seq > seq {
+			frames = append(frames, ev.frame)
+		}
+	}
+	return streamID, frames, true
+}
+
+// remove drops the events of a terminated session
+func (e *eventStore) remove(sessionID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.sessions, sessionID)
+}
+
+// sweep drops sessions inactive for longer than eventRetention, called with mu held
+func (e *eventStore) sweep() {
+	deadline := time.Now().Add(-eventRetention)
+	for id, sess := range e.sessions {
+		if sess.lastActive.Before(deadline) {
+			delete(e.sessions, id)
+		}
+	}
+}
+
+// resumableWriter assigns event IDs to the SSE frames written by the Streamable HTTP server and
+// records them in the event store. Non-SSE responses pass through unchanged.
+type resumableWriter struct {
+	http.ResponseWriter
+	store     *eventStore
+	sessionID string
+	// streamID is set when resuming, otherwise a new stream is started once the response turns out to be SSE
+	streamID    string
+	replay      [][]byte
+	wroteHeader bool
+	sse         bool
+	pending     []byte
+}
+
+func (w *resumableWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.sse = code == http.StatusOK && strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream")
+	w.ResponseWriter.WriteHeader(code)
+	if !w.sse {
+		return
+	}
+	if w.streamID == "" {
+		w.streamID = w.store.newStream(w.sessionID)
+	}
+	for _, frame := range w.replay {
+		if _, err := w.ResponseWriter.Write(frame); err != nil {
+			break
+		}
+	}
+	w.replay = nil
+}
+
+func (w *resumableWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.sse {
+		return w.ResponseWriter.Write(p)
+	}
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.Index(w.pending, []byte("\n\n"))
+		if i < 0 {
+			break
+		}
+		frame := append([]byte(nil), w.pending[:i+2]...)
+		w.pending = append(w.pending[:0], w.pending[i+2:]...)
+		if _, err := w.ResponseWriter.Write(w.store.append(w.sessionID, w.streamID, frame)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *resumableWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// serveStreamable serves a Streamable HTTP request, making SSE streams resumable via Last-Event-ID
+func (s *McpServer) serveStreamable(w http.ResponseWriter, r *http.Request) {
+	r, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	sessionID := r.Header.Get(server.HeaderKeySessionID)
+	handler := s.StreamableHTTPServer()
+	if sessionID == "" {
+		handler.ServeHTTP(w, r)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		handler.ServeHTTP(w, r)
+		s.events.remove(sessionID)
+		return
+	}
+	if r.Method == http.MethodPost {
+		s.interceptSubscription(r)
+	}
+	rw := &resumableWriter{ResponseWriter: w, store: s.events, sessionID: sessionID}
+	if lastEventID := r.Header.Get(headerLastEventID); lastEventID != "" && r.Method == http.MethodGet {
+		if streamID, frames, ok := s.events.replay(sessionID, lastEventID); ok {
+			rw.streamID = streamID
+			rw.replay = frames
+		}
+	}
+	handler.ServeHTTP(rw, r)
+}
+
+// startStdio serves the MCP server over Stdin/Stdout until the input ends or the endpoint is closed
+func (s *McpServer) startStdio() error {
+	if s.stopStdio != nil {
+		return nil
+	}
+	if err := s.startResources(); err != nil {
+		return err
+	}
+	var in io.Reader = os.Stdin
+	var out io.Writer = os.Stdout
+	if s.Stdin != nil {
+		in = s.Stdin
+	}
+	if s.Stdout != nil {
+		out = s.Stdout
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopStdio = cancel
+	if s.resources != nil {
+		in = s.resources.interceptReader(ctx, stdioSessionID, in)
+	}
+	stdioServer := server.NewStdioServer(s.MCPServer())
+	go func() {
+		defer s.finish()
+		if err := stdioServer.Listen(ctx, in, out); err != nil && !errors.Is(err, context.Canceled) {
+			s.Printf("mcp stdio server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Done returns a channel that is closed when the endpoint is closed or, with the stdio transport,
+// when the input stream ends. A local MCP server binary can block on it after Start.
+func (s *McpServer) Done() <-chan struct{} {
+	s.doneOnce.Do(func() {
+		s.done = make(chan struct{})
+	})
+	return s.done
+}
+
+// finish closes the Done channel
+func (s *McpServer) finish() {
+	s.Done()
+	s.finishOnce.Do(func() {
+		close(s.done)
+	})
+}
@@ -0,0 +1,266 @@
+package endpoint_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego-components-ai/endpoint"
+	"github.com/rulego/rulego/api/types"
+	rulegoEndpoint "github.com/rulego/rulego/endpoint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startTransportServer 启动指定传输方式的 MCP Server
+func startTransportServer(t *testing.T, cfg types.Configuration) *endpoint.McpServer {
+	t.Helper()
+	config := rulego.NewConfig(types.WithDefaultPool())
+	_, err := rulego.New("test_chain_mcp", []byte(testChainJSON), types.WithConfig(config))
+	require.NoError(t, err)
+
+	ep, err := rulegoEndpoint.Registry.New(endpoint.Type, config, cfg)
+	require.NoError(t, err)
+	_, err = ep.AddRouter(rulegoEndpoint.NewRouter().From("test_tool").To("chain:test_chain_mcp").End(), "测试工具")
+	require.NoError(t, err)
+	require.NoError(t, ep.Start())
+	time.Sleep(100 * time.Millisecond)
+	return ep.(*endpoint.McpServer)
+}
+
+// postMcp 发送一条 JSON-RPC 消息
+func postMcp(t *testing.T, url, sessionID, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if sessionID != "" {
+		req.Header.Set(server.HeaderKeySessionID, sessionID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+// sseEvent 是解析后的 SSE 事件
+type sseEvent struct {
+	id   string
+	data string
+}
+
+// openStream 打开 GET 通知流，lastEventID 非空时从该事件之后恢复
+func openStream(t *testing.T, url, sessionID, lastEventID string) (<-chan sseEvent, func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(server.HeaderKeySessionID, sessionID)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer resp.Body.Close()
+		var ev sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				ev.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				ev.data = strings.TrimPrefix(line, "data: ")
+			case line == "":
+				if ev.data != "" {
+					events <- ev
+				}
+				ev = sseEvent{}
+			}
+		}
+	}()
+	return events, cancel
+}
+
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an SSE event")
+		return sseEvent{}
+	}
+}
+
+func TestMcpServerEndpoint_StreamableHTTPSession(t *testing.T) {
+	ep := startTransportServer(t, types.Configuration{
+		"server":    ":19120",
+		"basePath":  "/mcp",
+		"transport": endpoint.TransportStreamableHTTP,
+	})
+	defer ep.Destroy()
+	url := "http://localhost:19120/mcp"
+
+	resp := postMcp(t, url, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+mcp.LATEST_PROTOCOL_VERSION+`","capabilities":{},"clientInfo":{"name":"test","version":"1.0.0"}}}`)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	sessionID := resp.Header.Get(server.HeaderKeySessionID)
+	require.NotEmpty(t, sessionID)
+
+	// 未知会话 ID 被拒绝
+	resp = postMcp(t, url, "mcp-session-00000000-0000-0000-0000-000000000000", `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	_ = resp.Body.Close()
+	assert.GreaterOrEqual(t, resp.StatusCode, 400)
+
+	resp = postMcp(t, url, sessionID, `{"jsonrpc":"2.0","id":3,"method":"tools/list"}`)
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "test_tool")
+}
+
+func TestMcpServerEndpoint_StreamableHTTPResume(t *testing.T) {
+	ep := startTransportServer(t, types.Configuration{
+		"server":    ":19121",
+		"basePath":  "/mcp",
+		"transport": endpoint.TransportStreamableHTTP,
+	})
+	defer ep.Destroy()
+	url := "http://localhost:19121/mcp"
+
+	resp := postMcp(t, url, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"`+mcp.LATEST_PROTOCOL_VERSION+`","capabilities":{},"clientInfo":{"name":"test","version":"1.0.0"}}}`)
+	_ = resp.Body.Close()
+	sessionID := resp.Header.Get(server.HeaderKeySessionID)
+	require.NotEmpty(t, sessionID)
+	resp = postMcp(t, url, sessionID, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	_ = resp.Body.Close()
+
+	events, closeStream := openStream(t, url, sessionID, "")
+	time.Sleep(100 * time.Millisecond)
+
+	// 新增工具会向会话发送 tools/list_changed 通知
+	addTool := func(name string) {
+		_, err := ep.AddRouter(rulegoEndpoint.NewRouter().From(name).To("chain:test_chain_mcp").End(), name)
+		require.NoError(t, err)
+	}
+	addTool("tool_a")
+	first := nextEvent(t, events)
+	addTool("tool_b")
+	second := nextEvent(t, events)
+	require.NotEmpty(t, first.id)
+	require.NotEmpty(t, second.id)
+	assert.NotEqual(t, first.id, second.id)
+	assert.Contains(t, first.data, "notifications/tools/list_changed")
+	closeStream()
+
+	// 从第一个事件之后恢复，重放第二个事件
+	resumed, closeResumed := openStream(t, url, sessionID, first.id)
+	defer closeResumed()
+	replayed := nextEvent(t, resumed)
+	assert.Equal(t, second.id, replayed.id)
+	assert.Equal(t, second.data, replayed.data)
+
+	// 恢复后的新事件在同一流上继续编号
+	addTool("tool_c")
+	third := nextEvent(t, resumed)
+	assert.Equal(t, strings.SplitN(second.id, "-", 2)[0], strings.SplitN(third.id, "-", 2)[0])
+	assert.NotEqual(t, second.id, third.id)
+}
+
+func TestMcpServerEndpoint_StreamableHTTPClient(t *testing.T) {
+	ep := startTransportServer(t, types.Configuration{
+		"server":    ":19122",
+		"basePath":  "/mcp",
+		"transport": endpoint.TransportStreamableHTTP,
+	})
+	defer ep.Destroy()
+
+	httpTransport, err := transport.NewStreamableHTTP("http://localhost:19122/mcp")
+	require.NoError(t, err)
+	cli := client.NewClient(httpTransport)
+	defer cli.Close()
+	ctx := context.Background()
+	require.NoError(t, cli.Start(ctx))
+	_, err = cli.Initialize(ctx, mcp.InitializeRequest{Params: mcp.InitializeParams{ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION}})
+	require.NoError(t, err)
+
+	result, err := cli.CallTool(ctx, mcp.CallToolRequest{Params: mcp.CallToolParams{
+		Name:      "test_tool",
+		Arguments: map[string]interface{}{"input": "hello"},
+	}})
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+	assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "processed")
+}
+
+func TestMcpServerEndpoint_Stdio(t *testing.T) {
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+
+	config := rulego.NewConfig(types.WithDefaultPool())
+	_, err := rulego.New("test_chain_mcp", []byte(testChainJSON), types.WithConfig(config))
+	require.NoError(t, err)
+	ep, err := rulegoEndpoint.Registry.New(endpoint.Type, config, types.Configuration{
+		"transport": endpoint.TransportStdio,
+	})
+	require.NoError(t, err)
+	mcpEp := ep.(*endpoint.McpServer)
+	mcpEp.Stdin = serverIn
+	mcpEp.Stdout = serverOut
+	_, err = ep.AddRouter(rulegoEndpoint.NewRouter().From("test_tool").To("chain:test_chain_mcp").End(), "测试工具")
+	require.NoError(t, err)
+	require.NoError(t, ep.Start())
+	defer ep.Destroy()
+
+	cli := client.NewClient(transport.NewIO(clientIn, clientOut, io.NopCloser(strings.NewReader(""))))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, cli.Start(ctx))
+	_, err = cli.Initialize(ctx, mcp.InitializeRequest{Params: mcp.InitializeParams{ProtocolVersion: mcp.LATEST_PROTOCOL_VERSION}})
+	require.NoError(t, err)
+
+	tools, err := cli.ListTools(ctx, mcp.ListToolsRequest{})
+	require.NoError(t, err)
+	require.Len(t, tools.Tools, 1)
+	assert.Equal(t, "test_tool", tools.Tools[0].Name)
+
+	result, err := cli.CallTool(ctx, mcp.CallToolRequest{Params: mcp.CallToolParams{
+		Name:      "test_tool",
+		Arguments: map[string]interface{}{"input": "hello"},
+	}})
+	require.NoError(t, err)
+	assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "processed")
+
+	// 输入结束后 Done 关闭
+	_ = clientOut.Close()
+	select {
+	case <-mcpEp.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Done to be closed after stdin ends")
+	}
+}
+
+func TestMcpServerEndpoint_InvalidTransport(t *testing.T) {
+	_, err := rulegoEndpoint.Registry.New(endpoint.Type, rulego.NewConfig(), types.Configuration{
+		"server":    ":19123",
+		"basePath":  "/mcp",
+		"transport": "websocket",
+	})
+	assert.Error(t, err)
+}
@@ -29,6 +29,7 @@ const (
 	CodeToolTimeout       ErrorCode = 30003
 	CodeToolInvalidInput  ErrorCode = 30004
 	CodeToolNotRegistered ErrorCode = 30005
+	CodeToolApprovalDenied ErrorCode = 30006
 
 	// Storage errors (40000-40999)
 	CodeStorageConnectFail ErrorCode = 40001
@@ -41,6 +42,9 @@ const (
 	CodeNotImplemented    ErrorCode = 50002
 	CodeServiceUnavailable ErrorCode = 50003
 	CodeUnknownError      ErrorCode = 50004
+
+	// Quota errors (60000-60999)
+	CodeBudgetExceeded ErrorCode = 60001
 )
 
 // String returns the string representation of the error code.
@@ -80,6 +84,8 @@ func (c ErrorCode) String() string {
 		return "ToolInvalidInput"
 	case CodeToolNotRegistered:
 		return "ToolNotRegistered"
+	case CodeToolApprovalDenied:
+		return "ToolApprovalDenied"
 	case CodeStorageConnectFail:
 		return "StorageConnectFail"
 	case CodeStorageQueryFail:
@@ -96,6 +102,8 @@ func (c ErrorCode) String() string {
 		return "ServiceUnavailable"
 	case CodeUnknownError:
 		return "UnknownError"
+	case CodeBudgetExceeded:
+		return "BudgetExceeded"
 	default:
 		return "Unknown"
 	}
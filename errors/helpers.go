@@ -65,6 +65,10 @@ func ToolInvalidInput(toolName, reason string) *AgentError {
 	return Newf(CodeToolInvalidInput, "invalid input for tool %s: %s", toolName, reason)
 }
 
+func ToolApprovalDenied(toolName, reason string) *AgentError {
+	return Newf(CodeToolApprovalDenied, "tool call %s was not approved: %s", toolName, reason)
+}
+
 // Storage errors
 func StorageConnectFail(cause error) *AgentError {
 	return Wrap(CodeStorageConnectFail, "failed to connect to storage", cause).
@@ -93,6 +97,11 @@ func ServiceUnavailable(service string) *AgentError {
 		WithRetryable(true)
 }
 
+// Quota errors
+func BudgetExceeded(details string) *AgentError {
+	return Newf(CodeBudgetExceeded, "budget exceeded: %s", details)
+}
+
 // IsLLMError checks if the error is an LLM-related error.
 func IsLLMError(err error) bool {
 	code := GetCode(err)
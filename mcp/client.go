@@ -36,6 +36,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/mark3labs/mcp-go/client"
 	"github.com/mark3labs/mcp-go/client/transport"
@@ -61,6 +62,8 @@ const (
 	defaultClientName = "RuleGo MCP Client"
 	// defaultClientVersion 默认客户端版本
 	defaultClientVersion = "1.0.0"
+	// toolsRefreshTimeout 收到工具列表变化通知后重新发现工具的超时时间
+	toolsRefreshTimeout = 30 * time.Second
 )
 
 // ClientConfiguration MCP 客户端配置
@@ -96,6 +99,8 @@ type Client struct {
 	toolDefs     []types.MCPToolDefinition
 	toolHandlers map[string]func(ctx context.Context, args map[string]interface{}) (string, error)
 	started      bool
+	// refreshMu 串行化工具列表变化后的重新发现
+	refreshMu sync.Mutex
 
 	toolNameTpl   el.Template
 	argsTpl       el.Template
@@ -152,13 +157,25 @@ func (c *Client) OnMsg(ctx types.RuleContext, msg types.RuleMsg) {
 
 	args := c.resolveArgs(ctx, msg)
 
-	result, err := c.CallTool(ctx.GetContext(), toolName, args)
+	result, err := c.callToolResult(ctx.GetContext(), toolName, args)
 	if err != nil {
 		ctx.TellFailure(msg, err)
 		return
 	}
 
-	msg.SetData(result)
+	// 图片、资源等非文本内容或 structuredContent 以完整的 MCP 结果 JSON 输出，便于下游节点处理，
+	// 也可由 MCP Server 规则链原样返回；纯文本结果直接输出文本
+	if mcptool.HasMedia(result) || result.StructuredContent != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			ctx.TellFailure(msg, err)
+			return
+		}
+		msg.DataType = types.JSON
+		msg.SetData(string(data))
+	} else {
+		msg.SetData(mcptool.ResultText(result))
+	}
 	ctx.TellSuccess(msg)
 }
 
@@ -228,42 +245,85 @@ func (c *Client) Start() error {
 		return fmt.Errorf("MCP client connect failed: %w", err)
 	}
 
-	result, err := cli.ListTools(ctx, mcp.ListToolsRequest{})
+	toolDefs, toolHandlers, err := c.discoverTools(ctx, cli)
 	if err != nil {
 		_ = cli.Close()
-		return fmt.Errorf("MCP tool discovery failed: %w", err)
+		return err
 	}
 
 	c.cli = cli
-	c.toolDefs = make([]types.MCPToolDefinition, 0, len(result.Tools))
-	c.toolHandlers = make(map[string]func(ctx context.Context, args map[string]interface{}) (string, error))
+	c.toolDefs = toolDefs
+	c.toolHandlers = toolHandlers
+	c.started = true
+
+	if c.RuleConfig.Udf == nil {
+		c.RuleConfig.Udf = make(map[string]interface{})
+	}
+	// 使用 MCPToolProviderKey 作为默认 key 注册（保持向后兼容）
+	// 注意：如果同一 RuleConfig 下有多个 MCP Client 实例，后注册的会覆盖先注册的。
+	// 需要唯一标识时，可使用 MCPToolProviderKey + ":" + server 地址。
+	c.RuleConfig.Udf[types.MCPToolProviderKey] = c
 
+	return nil
+}
+
+// discoverTools 通过 tools/list 发现按 ToolFilter 过滤后的工具
+func (c *Client) discoverTools(ctx context.Context, cli *client.Client) ([]types.MCPToolDefinition, map[string]func(ctx context.Context, args map[string]interface{}) (string, error), error) {
+	result, err := cli.ListTools(ctx, mcp.ListToolsRequest{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("MCP tool discovery failed: %w", err)
+	}
+
+	toolDefs := make([]types.MCPToolDefinition, 0, len(result.Tools))
+	toolHandlers := make(map[string]func(ctx context.Context, args map[string]interface{}) (string, error))
 	for _, t := range result.Tools {
 		if !mcptool.MatchTool(t.Name, c.Config.ToolFilter) {
 			continue
 		}
 		schema, _ := json.Marshal(t.InputSchema)
 		toolName := t.Name
-		c.toolDefs = append(c.toolDefs, types.MCPToolDefinition{
+		toolDefs = append(toolDefs, types.MCPToolDefinition{
 			Name:        toolName,
 			Description: t.Description,
 			InputSchema: schema,
 		})
-		c.toolHandlers[toolName] = func(ctx context.Context, args map[string]interface{}) (string, error) {
-			return c.callTool(ctx, toolName, args)
+		toolHandlers[toolName] = func(ctx context.Context, args map[string]interface{}) (string, error) {
+			result, err := c.callTool(ctx, toolName, args)
+			if err != nil {
+				return "", err
+			}
+			return mcptool.ResultText(result), nil
 		}
 	}
-	c.started = true
+	return toolDefs, toolHandlers, nil
+}
 
-	if c.RuleConfig.Udf == nil {
-		c.RuleConfig.Udf = make(map[string]interface{})
+// refreshTools 服务器的工具列表变化后重新发现工具，失败时保留当前工具，等待下一次通知
+func (c *Client) refreshTools() {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	c.mu.RLock()
+	cli := c.cli
+	c.mu.RUnlock()
+	if cli == nil {
+		return
 	}
-	// 使用 MCPToolProviderKey 作为默认 key 注册（保持向后兼容）
-	// 注意：如果同一 RuleConfig 下有多个 MCP Client 实例，后注册的会覆盖先注册的。
-	// 需要唯一标识时，可使用 MCPToolProviderKey + ":" + server 地址。
-	c.RuleConfig.Udf[types.MCPToolProviderKey] = c
 
-	return nil
+	ctx, cancel := context.WithTimeout(context.Background(), toolsRefreshTimeout)
+	defer cancel()
+	toolDefs, toolHandlers, err := c.discoverTools(ctx, cli)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// 期间连接已关闭或重建时丢弃本次结果
+	if c.cli == cli {
+		c.toolDefs = toolDefs
+		c.toolHandlers = toolHandlers
+	}
 }
 
 // ListToolDefinitions 实现 types.MCPToolProvider 接口
@@ -291,7 +351,8 @@ func (c *Client) connect(ctx context.Context) (*client.Client, error) {
 	var cli *client.Client
 
 	if strings.HasPrefix(c.Config.Server, "http://") || strings.HasPrefix(c.Config.Server, "https://") {
-		httpTransport, err := transport.NewStreamableHTTP(c.Config.Server)
+		// 持续监听 GET 流，接收服务器主动发送的工具列表变化通知
+		httpTransport, err := transport.NewStreamableHTTP(c.Config.Server, transport.WithContinuousListening())
 		if err != nil {
 			return nil, fmt.Errorf("创建 HTTP 传输失败: %w", err)
 		}
@@ -305,6 +366,13 @@ func (c *Client) connect(ctx context.Context) (*client.Client, error) {
 		cli = client.NewClient(stdioTransport)
 	}
 
+	cli.OnNotification(func(notification mcp.JSONRPCNotification) {
+		if notification.Method == mcp.MethodNotificationToolsListChanged {
+			// 通知回调运行在传输层的读循环中，需在其外发起 tools/list 请求
+			go c.refreshTools()
+		}
+	})
+
 	if err := cli.Start(ctx); err != nil {
 		return nil, fmt.Errorf("启动 MCP 客户端失败: %w", err)
 	}
@@ -326,13 +394,24 @@ func (c *Client) connect(ctx context.Context) (*client.Client, error) {
 	return cli, nil
 }
 
+// callToolResult 调用已发现的远程 MCP 工具，返回完整的 MCP 结果
+func (c *Client) callToolResult(ctx context.Context, toolName string, args map[string]interface{}) (*mcp.CallToolResult, error) {
+	c.mu.RLock()
+	_, ok := c.toolHandlers[toolName]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("MCP tool not found: %s", toolName)
+	}
+	return c.callTool(ctx, toolName, args)
+}
+
 // callTool 调用远程 MCP 工具
-func (c *Client) callTool(ctx context.Context, toolName string, args map[string]interface{}) (string, error) {
+func (c *Client) callTool(ctx context.Context, toolName string, args map[string]interface{}) (*mcp.CallToolResult, error) {
 	c.mu.RLock()
 	cli := c.cli
 	if cli == nil {
 		c.mu.RUnlock()
-		return "", fmt.Errorf("MCP client not connected")
+		return nil, fmt.Errorf("MCP client not connected")
 	}
 
 	result, err := cli.CallTool(ctx, mcp.CallToolRequest{
@@ -343,28 +422,11 @@ func (c *Client) callTool(ctx context.Context, toolName string, args map[string]
 	})
 	c.mu.RUnlock()
 	if err != nil {
-		return "", fmt.Errorf("调用远程 MCP 工具失败: %w", err)
+		return nil, fmt.Errorf("调用远程 MCP 工具失败: %w", err)
 	}
 
-	if result.IsError {
-		if len(result.Content) > 0 {
-			if textContent, ok := result.Content[0].(mcp.TextContent); ok {
-				return "", fmt.Errorf("MCP 工具错误: %s", textContent.Text)
-			}
-		}
-		return "", fmt.Errorf("MCP 工具错误: 未知错误")
-	}
-
-	var contents []string
-	for _, content := range result.Content {
-		switch v := content.(type) {
-		case mcp.TextContent:
-			contents = append(contents, v.Text)
-		default:
-			if b, err := json.Marshal(v); err == nil {
-				contents = append(contents, string(b))
-			}
-		}
+	if err := mcptool.ResultError(result); err != nil {
+		return nil, err
 	}
-	return strings.Join(contents, "\n"), nil
+	return result, nil
 }
@@ -220,6 +220,45 @@ func TestClient_StartWithWildcardFilter(t *testing.T) {
 	assert.Len(t, defs, 3)
 }
 
+func TestClient_ToolsListChanged(t *testing.T) {
+	s := server.NewMCPServer("test-server", "1.0.0", server.WithToolCapabilities(true))
+	handler := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(request.Params.Name), nil
+	}
+	s.AddTool(mcp.NewTool("echo"), handler)
+	httpServer := server.NewStreamableHTTPServer(s)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = http.Serve(listener, httpServer) }()
+	defer httpServer.Shutdown(context.Background())
+
+	c := &Client{}
+	require.NoError(t, c.Init(types.NewConfig(), types.Configuration{
+		"server": fmt.Sprintf("http://%s/mcp", listener.Addr().String()),
+	}))
+	require.NoError(t, c.Start())
+	defer c.Destroy()
+
+	// 服务器新增工具后，客户端随通知重新发现工具
+	s.AddTool(mcp.NewTool("weather"), handler)
+	require.Eventually(t, func() bool {
+		defs, _ := c.ListToolDefinitions()
+		return len(defs) == 2
+	}, 5*time.Second, 20*time.Millisecond)
+	result, err := c.CallTool(context.Background(), "weather", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "weather", result)
+
+	// 删除工具
+	s.DeleteTools("echo")
+	require.Eventually(t, func() bool {
+		defs, _ := c.ListToolDefinitions()
+		return len(defs) == 1
+	}, 5*time.Second, 20*time.Millisecond)
+	_, err = c.CallTool(context.Background(), "echo", nil)
+	assert.Error(t, err)
+}
+
 func TestClient_CallTool_Echo(t *testing.T) {
 	addr, shutdown := startTestMCPServer(t)
 	defer shutdown()
@@ -523,3 +562,40 @@ func TestClient_AsMCPToolProvider(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "via provider", result)
 }
+
+func TestClient_OnMsg_RichContent(t *testing.T) {
+	s := server.NewMCPServer("test-server", "1.0.0")
+	s.AddTool(mcp.NewTool("chart"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return &mcp.CallToolResult{Content: []mcp.Content{
+			mcp.NewTextContent("chart"),
+			mcp.NewImageContent("iVBORw0KGgo=", "image/png"),
+		}}, nil
+	})
+	httpServer := server.NewStreamableHTTPServer(s)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = http.Serve(listener, httpServer) }()
+	defer httpServer.Shutdown(context.Background())
+
+	config := types.NewConfig()
+	c := &Client{}
+	require.NoError(t, c.Init(config, types.Configuration{
+		"server":   fmt.Sprintf("http://%s/mcp", listener.Addr().String()),
+		"toolName": "chart",
+	}))
+	require.NoError(t, c.Start())
+	defer c.Destroy()
+
+	// 节点输出完整的 MCP 结果 JSON，保留图片数据
+	ctx := test.NewRuleContext(config, func(msg types.RuleMsg, relationType string, err error) {
+		assert.Equal(t, types.Success, relationType)
+		assert.Equal(t, types.JSON, msg.DataType)
+		assert.JSONEq(t, `{"content":[{"type":"text","text":"chart"},{"type":"image","data":"iVBORw0KGgo=","mimeType":"image/png"}]}`, msg.GetData())
+	})
+	c.OnMsg(ctx, ctx.NewMsg("TEST_MSG", types.NewMetadata(), `{}`))
+
+	// 作为 MCPToolProvider 给 agent 使用时图片以占位文本表示
+	text, err := c.CallTool(context.Background(), "chart", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "chart\n[图片: image/png]", text)
+}
@@ -0,0 +1,212 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ApprovalStatus 工具调用审批状态
+type ApprovalStatus string
+
+const (
+	// ApprovalPending 等待审批
+	ApprovalPending ApprovalStatus = "pending"
+	// ApprovalApproved 已批准（含修改参数后批准）
+	ApprovalApproved ApprovalStatus = "approved"
+	// ApprovalDenied 已拒绝
+	ApprovalDenied ApprovalStatus = "denied"
+	// ApprovalExpired 等待超时
+	ApprovalExpired ApprovalStatus = "expired"
+)
+
+// ApprovalDecision 审批决定
+type ApprovalDecision string
+
+const (
+	// DecisionApprove 批准
+	DecisionApprove ApprovalDecision = "approve"
+	// DecisionDeny 拒绝
+	DecisionDeny ApprovalDecision = "deny"
+	// DecisionEdit 修改参数后批准
+	DecisionEdit ApprovalDecision = "edit"
+)
+
+// maxResolvedApprovals 每个会话保留的已处理审批记录数，超出后删除最旧的记录
+const maxResolvedApprovals = 50
+
+// ToolApproval 工具调用审批记录，保存在会话元数据中，随会话一起持久化
+type ToolApproval struct {
+	ID        string           `json:"id"`
+	RunID     string           `json:"runId"`
+	AgentID   string           `json:"agentId,omitempty"`
+	ToolName  string           `json:"toolName"`
+	Arguments string           `json:"arguments"`
+	Rule      string           `json:"rule,omitempty"` // 命中的审批策略规则
+	Status    ApprovalStatus   `json:"status"`
+	Decision  ApprovalDecision `json:"decision,omitempty"`
+	// EditedArguments edit 决定给出的新参数
+	EditedArguments string `json:"editedArguments,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	// Consumed 决定已被工具调用使用（进程重启后由恢复执行的调用使用）
+	Consumed  bool       `json:"consumed,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	DecidedAt *time.Time `json:"decidedAt,omitempty"`
+}
+
+// NewToolApproval 创建待审批记录
+func NewToolApproval(runID, agentID, toolName, arguments, rule string) *ToolApproval {
+	return &ToolApproval{
+		ID:        "apv_" + uuid.New().String()[:8],
+		RunID:     runID,
+		AgentID:   agentID,
+		ToolName:  toolName,
+		Arguments: arguments,
+		Rule:      rule,
+		Status:    ApprovalPending,
+		CreatedAt: time.Now(),
+	}
+}
+
+// Decide 应用审批决定
+func (a *ToolApproval) Decide(decision ApprovalDecision, editedArguments, reason string) error {
+	if a.Status != ApprovalPending {
+		return fmt.Errorf("approval %s already %s", a.ID, a.Status)
+	}
+	switch decision {
+	case DecisionApprove:
+		a.Status = ApprovalApproved
+	case DecisionEdit:
+		if editedArguments == "" {
+			return fmt.Errorf("edit decision for approval %s requires arguments", a.ID)
+		}
+		a.Status = ApprovalApproved
+		a.EditedArguments = editedArguments
+	case DecisionDeny:
+		a.Status = ApprovalDenied
+	default:
+		return fmt.Errorf("unknown approval decision %q", decision)
+	}
+	now := time.Now()
+	a.Decision = decision
+	a.Reason = reason
+	a.DecidedAt = &now
+	return nil
+}
+
+// EffectiveArguments 返回批准后实际执行使用的参数
+func (a *ToolApproval) EffectiveArguments() string {
+	if a.EditedArguments != "" {
+		return a.EditedArguments
+	}
+	return a.Arguments
+}
+
+// AddApproval 添加审批记录
+func (m *Manager) AddApproval(ctx context.Context, sessionKey string, approval *ToolApproval) error {
+	m.approvalMu.Lock()
+	defer m.approvalMu.Unlock()
+	return m.updateMetadata(ctx, sessionKey, func(metadata *SessionMetadata) error {
+		approvals := make([]ToolApproval, 0, len(metadata.Approvals)+1)
+		approvals = append(approvals, metadata.Approvals...)
+		approvals = append(approvals, *approval)
+		metadata.Approvals = trimResolvedApprovals(approvals)
+		return nil
+	})
+}
+
+// UpdateApproval 在审批锁内读取、修改并保存审批记录，update 返回错误时不保存
+func (m *Manager) UpdateApproval(ctx context.Context, sessionKey, id string, update func(*ToolApproval) error) (*ToolApproval, error) {
+	m.approvalMu.Lock()
+	defer m.approvalMu.Unlock()
+	var result *ToolApproval
+	err := m.updateMetadata(ctx, sessionKey, func(metadata *SessionMetadata) error {
+		approvals := make([]ToolApproval, len(metadata.Approvals))
+		copy(approvals, metadata.Approvals)
+		for i := range approvals {
+			if approvals[i].ID != id {
+				continue
+			}
+			if err := update(&approvals[i]); err != nil {
+				return err
+			}
+			updated := approvals[i]
+			result = &updated
+			metadata.Approvals = trimResolvedApprovals(approvals)
+			return nil
+		}
+		return ErrApprovalNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// updateMetadata 只修改会话元数据，不覆盖并发写入的消息
+// 存储未实现 MetadataStorage 时退化为读取整个会话后写回
+func (m *Manager) updateMetadata(ctx context.Context, sessionKey string, update func(*SessionMetadata) error) error {
+	if ms, ok := m.storage.(MetadataStorage); ok {
+		return ms.UpdateMetadata(ctx, sessionKey, update)
+	}
+	sess, err := m.storage.Get(ctx, sessionKey)
+	if err != nil {
+		return err
+	}
+	if err := update(&sess.Metadata); err != nil {
+		return err
+	}
+	return m.storage.Update(ctx, sess)
+}
+
+// ListApprovals 列出会话中的审批记录，按创建顺序排列
+func (m *Manager) ListApprovals(ctx context.Context, sessionKey string) ([]ToolApproval, error) {
+	sess, err := m.storage.Get(ctx, sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	approvals := make([]ToolApproval, len(sess.Metadata.Approvals))
+	copy(approvals, sess.Metadata.Approvals)
+	return approvals, nil
+}
+
+// trimResolvedApprovals 只保留最近 maxResolvedApprovals 条已处理的记录，待审批记录全部保留
+func trimResolvedApprovals(approvals []ToolApproval) []ToolApproval {
+	resolved := 0
+	for _, a := range approvals {
+		if a.Status != ApprovalPending {
+			resolved++
+		}
+	}
+	drop := resolved - maxResolvedApprovals
+	if drop <= 0 {
+		return approvals
+	}
+	result := make([]ToolApproval, 0, len(approvals)-drop)
+	for _, a := range approvals {
+		if a.Status != ApprovalPending && drop > 0 {
+			drop--
+			continue
+		}
+		result = append(result, a)
+	}
+	return result
+}
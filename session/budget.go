@@ -0,0 +1,185 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"time"
+)
+
+// BudgetScope 预算作用范围
+type BudgetScope string
+
+const (
+	// BudgetScopeUser 按用户统计（跨智能体、跨会话）
+	BudgetScopeUser BudgetScope = "user"
+	// BudgetScopeSession 按会话统计
+	BudgetScopeSession BudgetScope = "session"
+	// BudgetScopeAgent 按智能体统计
+	BudgetScopeAgent BudgetScope = "agent"
+)
+
+// BudgetPeriod 预算周期（按 UTC 自然日/自然月）
+type BudgetPeriod string
+
+const (
+	// BudgetPeriodDaily 每日预算
+	BudgetPeriodDaily BudgetPeriod = "daily"
+	// BudgetPeriodMonthly 每月预算
+	BudgetPeriodMonthly BudgetPeriod = "monthly"
+	// BudgetPeriodTotal 累计预算
+	BudgetPeriodTotal BudgetPeriod = "total"
+)
+
+// BudgetMode 预算用尽后的处理方式
+type BudgetMode string
+
+const (
+	// BudgetModeHard 用尽后拒绝请求
+	BudgetModeHard BudgetMode = "hard"
+	// BudgetModeSoft 用尽后切换到 DowngradeModel，未配置时只记录
+	BudgetModeSoft BudgetMode = "soft"
+)
+
+// Budget 费用预算
+type Budget struct {
+	Scope BudgetScope `json:"scope"`
+	// ID 预算对象（用户 ID、会话 key 或智能体 ID），为空表示作用于范围内的每个对象；
+	// 同一范围和周期下，指定 ID 的预算优先于默认预算
+	ID     string       `json:"id,omitempty"`
+	Period BudgetPeriod `json:"period"`
+	// Limit 费用上限，货币与模型价格一致
+	Limit float64    `json:"limit"`
+	Mode  BudgetMode `json:"mode"`
+	// DowngradeModel soft 模式用尽后使用的模型
+	DowngradeModel string `json:"downgradeModel,omitempty"`
+}
+
+// BudgetSubject 预算检查对象
+type BudgetSubject struct {
+	AgentID    string
+	UserID     string
+	SessionKey string
+}
+
+// id 返回预算范围对应的对象 ID
+func (s BudgetSubject) id(scope BudgetScope) string {
+	switch scope {
+	case BudgetScopeUser:
+		return s.UserID
+	case BudgetScopeSession:
+		return s.SessionKey
+	case BudgetScopeAgent:
+		return s.AgentID
+	}
+	return ""
+}
+
+// BudgetStatus 预算使用情况
+type BudgetStatus struct {
+	Budget Budget
+	Spent  float64
+}
+
+// Exhausted 预算是否用尽
+func (s BudgetStatus) Exhausted() bool {
+	return s.Spent >= s.Budget.Limit
+}
+
+// BudgetDecision 预算检查结果
+type BudgetDecision struct {
+	// Exceeded 已用尽的预算，按配置顺序
+	Exceeded []BudgetStatus
+	// Refuse 存在已用尽的 hard 预算
+	Refuse bool
+	// DowngradeModel 已用尽的 soft 预算中第一个配置的降级模型
+	DowngradeModel string
+}
+
+// CheckBudgets 检查对象适用的预算
+// 对象在预算范围内的 ID 为空时跳过该预算（例如匿名请求不受用户预算约束）
+func CheckBudgets(ctx context.Context, ledger UsageLedger, budgets []Budget, subject BudgetSubject, now time.Time) (*BudgetDecision, error) {
+	decision := &BudgetDecision{}
+	for _, b := range applicableBudgets(budgets, subject) {
+		query := budgetQuery(b, subject, now)
+		records, err := ledger.Query(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		status := BudgetStatus{Budget: b, Spent: SumUsage(records).Cost}
+		if !status.Exhausted() {
+			continue
+		}
+		decision.Exceeded = append(decision.Exceeded, status)
+		if b.Mode == BudgetModeSoft {
+			if decision.DowngradeModel == "" {
+				decision.DowngradeModel = b.DowngradeModel
+			}
+		} else {
+			decision.Refuse = true
+		}
+	}
+	return decision, nil
+}
+
+// applicableBudgets 过滤出作用于对象的预算，指定 ID 的预算覆盖同范围同周期的默认预算
+func applicableBudgets(budgets []Budget, subject BudgetSubject) []Budget {
+	type scopePeriod struct {
+		scope  BudgetScope
+		period BudgetPeriod
+	}
+	specific := make(map[scopePeriod]bool)
+	for _, b := range budgets {
+		if b.ID != "" && b.ID == subject.id(b.Scope) {
+			specific[scopePeriod{b.Scope, b.Period}] = true
+		}
+	}
+	var result []Budget
+	for _, b := range budgets {
+		id := subject.id(b.Scope)
+		if id == "" || b.Limit <= 0 {
+			continue
+		}
+		if b.ID == "" && !specific[scopePeriod{b.Scope, b.Period}] || b.ID == id {
+			result = append(result, b)
+		}
+	}
+	return result
+}
+
+// budgetQuery 生成预算周期内的账本查询条件
+func budgetQuery(b Budget, subject BudgetSubject, now time.Time) *UsageQuery {
+	query := &UsageQuery{}
+	switch b.Scope {
+	case BudgetScopeUser:
+		query.UserID = subject.UserID
+	case BudgetScopeSession:
+		query.SessionKey = subject.SessionKey
+	case BudgetScopeAgent:
+		query.AgentID = subject.AgentID
+	}
+	now = now.UTC()
+	switch b.Period {
+	case BudgetPeriodDaily:
+		query.FromDay = now.Format(UsageDayLayout)
+		query.ToDay = query.FromDay
+	case BudgetPeriodMonthly:
+		query.FromDay = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).Format(UsageDayLayout)
+		query.ToDay = now.Format(UsageDayLayout)
+	}
+	return query
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/rulego/rulego-components-ai/utils/token"
+)
+
+// DefaultCompactionPrompt 默认的会话压缩提示词
+const DefaultCompactionPrompt = `你是一个对话压缩助手。请将下面的历史对话压缩成一份简洁的摘要，供后续对话继续使用。
+
+要求：
+- 保留用户的目标、约束和偏好
+- 保留已经确认的结论、关键数据、文件路径、命令及其结果
+- 保留尚未完成的任务和待解决的问题
+- 省略寒暄、重复内容和冗长的工具输出
+- 如果提供了之前的摘要，将其与新的对话合并为一份完整摘要
+- 只输出摘要正文，不要输出任何解释`
+
+// CompactedSummaryPrefix 注入历史时摘要消息的前缀
+const CompactedSummaryPrefix = "[会话摘要] 以下是此前对话的压缩摘要，请基于此继续对话：\n"
+
+// CompactingManager 支持 LLM 摘要压缩的会话管理器
+// 达到 CompactionConfig 阈值时，将较早的消息交给 chatModel 生成摘要，
+// 摘要保存在 Session.CompactedSummary，被折叠的消息标记 IsCompacted
+type CompactingManager struct {
+	*Manager
+	chatModel model.BaseChatModel
+	// inflight 正在压缩的会话，避免同一会话并发压缩
+	inflight sync.Map
+}
+
+// NewCompactingManager 创建支持压缩的会话管理器
+// chatModel 为 nil 时 CompactIfNeeded 不执行压缩
+func NewCompactingManager(storage SessionStorage, config *SessionConfig, chatModel model.BaseChatModel) *CompactingManager {
+	return WrapCompactingManager(NewManager(storage, config), chatModel)
+}
+
+// WrapCompactingManager 为已有的会话管理器增加压缩能力，与 manager 共享存储和配置
+func WrapCompactingManager(manager *Manager, chatModel model.BaseChatModel) *CompactingManager {
+	return &CompactingManager{
+		Manager:   manager,
+		chatModel: chatModel,
+	}
+}
+
+// CompactIfNeeded 按需压缩会话
+// 会话达到压缩阈值时，将保留区之前的未压缩消息摘要合并到 CompactedSummary
+func (m *CompactingManager) CompactIfNeeded(ctx context.Context, sessionKey string) (bool, error) {
+	cfg := m.config.CompactionConfig
+	if cfg == nil || !cfg.Enabled || m.chatModel == nil {
+		return false, nil
+	}
+	if _, busy := m.inflight.LoadOrStore(sessionKey, struct{}{}); busy {
+		return false, nil
+	}
+	defer m.inflight.Delete(sessionKey)
+
+	sess, err := m.storage.Get(ctx, sessionKey)
+	if err != nil {
+		return false, err
+	}
+	if !sess.ShouldCompact(cfg) {
+		return false, nil
+	}
+
+	keepCount := cfg.KeepRecentCount
+	if keepCount <= 0 {
+		keepCount = DefaultKeepRecentCount
+	}
+	toCompact := selectMessagesToCompact(sess.Messages, keepCount)
+	if len(toCompact) == 0 {
+		return false, nil
+	}
+
+	summary, err := m.summarize(ctx, sess.CompactedSummary, toCompact)
+	if err != nil {
+		return false, fmt.Errorf("compact session %s: %w", sessionKey, err)
+	}
+
+	// 摘要期间可能有新消息写入，重新读取后按 ID 标记，避免覆盖新消息
+	latest, err := m.storage.Get(ctx, sessionKey)
+	if err != nil {
+		return false, err
+	}
+	compactedIDs := make(map[string]struct{}, len(toCompact))
+	for _, msg := range toCompact {
+		compactedIDs[msg.ID] = struct{}{}
+	}
+	for _, msg := range latest.Messages {
+		if _, ok := compactedIDs[msg.ID]; ok {
+			msg.IsCompacted = true
+		}
+	}
+	latest.CompactedSummary = summary
+	latest.Metadata.TotalTokenCount = countActiveTokens(latest)
+	latest.UpdatedAt = time.Now()
+
+	if err := m.storage.Update(ctx, latest); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// summarize 调用模型生成摘要
+func (m *CompactingManager) summarize(ctx context.Context, previousSummary string, msgs []*SessionMessage) (string, error) {
+	prompt := DefaultCompactionPrompt
+	if cfg := m.config.CompactionConfig; cfg != nil && cfg.SummaryPrompt != "" {
+		prompt = cfg.SummaryPrompt
+	}
+
+	var sb strings.Builder
+	if previousSummary != "" {
+		sb.WriteString("之前的摘要：\n")
+		sb.WriteString(previousSummary)
+		sb.WriteString("\n\n")
+	}
+	sb.WriteString("需要压缩的对话：\n")
+	sb.WriteString(formatTranscript(msgs))
+
+	resp, err := m.chatModel.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(prompt),
+		schema.UserMessage(sb.String()),
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp == nil || strings.TrimSpace(resp.Content) == "" {
+		return "", fmt.Errorf("model returned empty summary")
+	}
+	return strings.TrimSpace(resp.Content), nil
+}
+
+// selectMessagesToCompact 选出需要压缩的消息
+// 保留最近 keepCount 条未压缩消息，且保留区不能以 tool 结果开头，避免拆散工具调用组
+func selectMessagesToCompact(msgs []*SessionMessage, keepCount int) []*SessionMessage {
+	active := ActiveMessages(msgs)
+	boundary := len(active) - keepCount
+	for boundary > 0 && active[boundary].Role == string(schema.Tool) {
+		boundary--
+	}
+	if boundary <= 0 {
+		return nil
+	}
+	return active[:boundary]
+}
+
+// formatTranscript 将消息格式化为摘要模型的输入文本
+func formatTranscript(msgs []*SessionMessage) string {
+	var sb strings.Builder
+	for _, msg := range msgs {
+		switch {
+		case msg.Role == string(schema.Tool):
+			sb.WriteString(fmt.Sprintf("[tool result %s]: %s\n", msg.ToolCallID, truncateString(msg.Content, MaxToolResultSize)))
+		case len(msg.ToolCalls) > 0:
+			if msg.Content != "" {
+				sb.WriteString(fmt.Sprintf("[%s]: %s\n", msg.Role, msg.Content))
+			}
+			for _, tc := range msg.ToolCalls {
+				sb.WriteString(fmt.Sprintf("[%s tool call %s]: %s(%s)\n", msg.Role, tc.ID, tc.Name, tc.Arguments))
+			}
+		default:
+			content := msg.Content
+			if len(msg.Images) > 0 {
+				content = fmt.Sprintf("%s [图片 x%d]", content, len(msg.Images))
+			}
+			sb.WriteString(fmt.Sprintf("[%s]: %s\n", msg.Role, content))
+		}
+	}
+	return sb.String()
+}
+
+// countActiveTokens 统计摘要与未压缩消息的 token 总数
+func countActiveTokens(sess *Session) int {
+	total := 0
+	if sess.CompactedSummary != "" {
+		total = token.CountTokens(sess.Metadata.Model, sess.CompactedSummary)
+	}
+	for _, msg := range sess.Messages {
+		if !msg.IsCompacted {
+			total += msg.TokenCount
+		}
+	}
+	return total
+}
+
+// ActiveMessages 过滤掉已被压缩进摘要的消息
+func ActiveMessages(msgs []*SessionMessage) []*SessionMessage {
+	result := make([]*SessionMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		if !msg.IsCompacted {
+			result = append(result, msg)
+		}
+	}
+	return result
+}
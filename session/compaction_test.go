@@ -0,0 +1,189 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// fakeSummaryModel 返回固定摘要的模型，记录收到的输入
+type fakeSummaryModel struct {
+	summary string
+	err     error
+	calls   int
+	lastIn  []*schema.Message
+}
+
+func (f *fakeSummaryModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	f.calls++
+	f.lastIn = input
+	if f.err != nil {
+		return nil, f.err
+	}
+	return schema.AssistantMessage(f.summary, nil), nil
+}
+
+func (f *fakeSummaryModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return nil, errors.New("not implemented")
+}
+
+func newCompactionTestManager(t *testing.T, fake *fakeSummaryModel) (*CompactingManager, string) {
+	t.Helper()
+	cfg := DefaultSessionConfig()
+	cfg.CompactionConfig = &CompactionConfig{
+		Enabled:              true,
+		MaxTokenCount:        1000,
+		TriggerThreshold:     0.5,
+		KeepRecentCount:      4,
+		MinMessagesToCompact: 6,
+	}
+	mgr := NewCompactingManager(NewMemoryStorage(), cfg, fake)
+	sess, err := mgr.GetOrCreate(context.Background(), SessionRequest{
+		AgentID: "agent", Channel: "api", Scope: ScopePerPeer, ScopeID: "peer",
+	})
+	if err != nil {
+		t.Fatalf("GetOrCreate() error = %v", err)
+	}
+	return mgr, sess.Key
+}
+
+func TestCompactingManager_CompactsOlderMessages(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeSummaryModel{summary: "用户在排查部署问题"}
+	mgr, key := newCompactionTestManager(t, fake)
+
+	for i := 0; i < 10; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		if err := mgr.AddMessage(ctx, key, NewSessionMessageWithTokens(role, fmt.Sprintf("message-%d", i), 60)); err != nil {
+			t.Fatalf("AddMessage() error = %v", err)
+		}
+	}
+
+	compacted, err := mgr.CompactIfNeeded(ctx, key)
+	if err != nil {
+		t.Fatalf("CompactIfNeeded() error = %v", err)
+	}
+	if !compacted {
+		t.Fatal("expected session to be compacted")
+	}
+
+	sess, err := mgr.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if sess.CompactedSummary != "用户在排查部署问题" {
+		t.Errorf("unexpected summary %q", sess.CompactedSummary)
+	}
+	for i, msg := range sess.Messages {
+		wantCompacted := i < 6
+		if msg.IsCompacted != wantCompacted {
+			t.Errorf("message %d IsCompacted = %v, want %v", i, msg.IsCompacted, wantCompacted)
+		}
+	}
+	if len(ActiveMessages(sess.Messages)) != 4 {
+		t.Errorf("expected 4 active messages, got %d", len(ActiveMessages(sess.Messages)))
+	}
+	if sess.Metadata.TotalTokenCount >= 500 {
+		t.Errorf("expected token count to drop below threshold, got %d", sess.Metadata.TotalTokenCount)
+	}
+	if !strings.Contains(fake.lastIn[1].Content, "message-0") || strings.Contains(fake.lastIn[1].Content, "message-9") {
+		t.Errorf("summary input should contain only compacted messages: %s", fake.lastIn[1].Content)
+	}
+
+	// 未达到阈值不再压缩
+	compacted, err = mgr.CompactIfNeeded(ctx, key)
+	if err != nil || compacted {
+		t.Errorf("expected no second compaction, got compacted=%v err=%v", compacted, err)
+	}
+}
+
+func TestCompactingManager_BelowThreshold(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeSummaryModel{summary: "summary"}
+	mgr, key := newCompactionTestManager(t, fake)
+
+	for i := 0; i < 8; i++ {
+		_ = mgr.AddMessage(ctx, key, NewSessionMessageWithTokens("user", "short", 10))
+	}
+	compacted, err := mgr.CompactIfNeeded(ctx, key)
+	if err != nil || compacted {
+		t.Fatalf("expected no compaction, got compacted=%v err=%v", compacted, err)
+	}
+	if fake.calls != 0 {
+		t.Errorf("model should not be called, calls=%d", fake.calls)
+	}
+}
+
+func TestCompactingManager_ModelError(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeSummaryModel{err: errors.New("boom")}
+	mgr, key := newCompactionTestManager(t, fake)
+
+	for i := 0; i < 10; i++ {
+		_ = mgr.AddMessage(ctx, key, NewSessionMessageWithTokens("user", "text", 60))
+	}
+	compacted, err := mgr.CompactIfNeeded(ctx, key)
+	if err == nil || compacted {
+		t.Fatalf("expected error, got compacted=%v err=%v", compacted, err)
+	}
+	sess, _ := mgr.Get(ctx, key)
+	if sess.CompactedSummary != "" || len(ActiveMessages(sess.Messages)) != 10 {
+		t.Error("failed compaction must leave session untouched")
+	}
+}
+
+func TestSelectMessagesToCompact_KeepsToolCallGroup(t *testing.T) {
+	msgs := []*SessionMessage{
+		{ID: "1", Role: "user", Content: "a"},
+		{ID: "2", Role: "assistant", Content: "b"},
+		{ID: "3", Role: "user", Content: "c"},
+		{ID: "4", Role: "assistant", ToolCalls: []ToolCallInfo{{ID: "call-1", Name: "bash"}, {ID: "call-2", Name: "bash"}}},
+		{ID: "5", Role: "tool", ToolCallID: "call-1", Content: "r1"},
+		{ID: "6", Role: "tool", ToolCallID: "call-2", Content: "r2"},
+		{ID: "7", Role: "assistant", Content: "done"},
+	}
+
+	// 保留 2 条时边界落在 tool 结果上，需要回退到工具调用的 assistant 之前
+	result := selectMessagesToCompact(msgs, 2)
+	if len(result) != 3 {
+		t.Fatalf("expected 3 messages to compact, got %d", len(result))
+	}
+	if result[len(result)-1].ID != "3" {
+		t.Errorf("expected last compacted message to be 3, got %s", result[len(result)-1].ID)
+	}
+
+	// 已压缩的消息不再参与
+	msgs[0].IsCompacted = true
+	result = selectMessagesToCompact(msgs, 2)
+	if len(result) != 2 || result[0].ID != "2" {
+		t.Errorf("compacted messages should be skipped, got %d", len(result))
+	}
+
+	if result := selectMessagesToCompact(msgs, 10); result != nil {
+		t.Errorf("expected nothing to compact, got %d", len(result))
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // Session defaults
@@ -59,6 +60,15 @@ var (
 
 	// DefaultSessionIdleTimeout session idle timeout (1 hour)
 	DefaultSessionIdleTimeout = 1 * time.Hour
+
+	// DefaultSessionArchiveTimeout idle duration before a session is archived (7 days)
+	DefaultSessionArchiveTimeout = 7 * 24 * time.Hour
+
+	// DefaultJanitorInterval session janitor scan interval (1 minute)
+	DefaultJanitorInterval = 1 * time.Minute
+
+	// DefaultPromptCacheTTL provider prompt-cache window used by cache_ttl pruning (5 minutes)
+	DefaultPromptCacheTTL = 5 * time.Minute
 )
 
 // ProcessToolResult 处理工具结果
@@ -145,12 +155,24 @@ func ProcessToolCallArguments(arguments string) string {
 	return string(result)
 }
 
+// truncatedMarker 截断标记
+const truncatedMarker = "...[已截断]"
+
 // truncateString 截断字符串辅助函数
+// 结果（含截断标记）不超过 maxLen 字节，再次截断时保持不变；不会截断在多字节字符中间
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
 	}
-	return s[:maxLen] + "...[已截断]"
+	marker := truncatedMarker
+	if maxLen < len(marker) {
+		marker = ""
+	}
+	keep := maxLen - len(marker)
+	for keep > 0 && !utf8.RuneStart(s[keep]) {
+		keep--
+	}
+	return s[:keep] + marker
 }
 
 // isLargeField 判断字段是否需要截断
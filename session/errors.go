@@ -14,4 +14,10 @@ var (
 
 	// ErrStorageClosed 存储已关闭
 	ErrStorageClosed = errors.New("storage closed")
+
+	// ErrMessageNotFound 消息不存在
+	ErrMessageNotFound = errors.New("message not found")
+
+	// ErrApprovalNotFound 审批记录不存在
+	ErrApprovalNotFound = errors.New("approval not found")
 )
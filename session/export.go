@@ -0,0 +1,373 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// ExportFormatVersion 会话导出格式版本
+const ExportFormatVersion = 1
+
+// 导出记录类型
+const (
+	exportRecordSession = "session"
+	exportRecordMessage = "message"
+)
+
+// exportSessionRecord 导出文件的首行，描述会话本身
+// 字段使用显式 JSON 标签，保证格式不随内部结构调整而变化
+type exportSessionRecord struct {
+	Type             string          `json:"type"`
+	Version          int             `json:"version"`
+	Key              string          `json:"key"`
+	AgentID          string          `json:"agentId"`
+	Channel          string          `json:"channel"`
+	Scope            SessionScope    `json:"scope"`
+	ScopeID          string          `json:"scopeId"`
+	CompactedSummary string          `json:"compactedSummary,omitempty"`
+	Metadata         SessionMetadata `json:"metadata"`
+	State            SessionState    `json:"state"`
+	CreatedAt        time.Time       `json:"createdAt"`
+	UpdatedAt        time.Time       `json:"updatedAt"`
+	LastActivityAt   time.Time       `json:"lastActivityAt"`
+}
+
+// exportMessageRecord 导出文件中的一条消息
+type exportMessageRecord struct {
+	Type        string         `json:"type"`
+	ID          string         `json:"id"`
+	Role        string         `json:"role"`
+	Content     string         `json:"content"`
+	Images      []string       `json:"images,omitempty"`
+	TokenCount  int            `json:"tokenCount"`
+	IsCompacted bool           `json:"isCompacted,omitempty"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	ToolCalls   []ToolCallInfo `json:"toolCalls,omitempty"`
+	ToolCallID  string         `json:"toolCallId,omitempty"`
+}
+
+// MarshalSessionJSONL 将会话序列化为 JSON Lines
+// 第一行为会话记录（type=session），之后每行一条消息（type=message），按会话中的顺序排列
+func MarshalSessionJSONL(sess *Session) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(exportSessionRecord{
+		Type:             exportRecordSession,
+		Version:          ExportFormatVersion,
+		Key:              sess.Key,
+		AgentID:          sess.AgentID,
+		Channel:          sess.Channel,
+		Scope:            sess.Scope,
+		ScopeID:          sess.ScopeID,
+		CompactedSummary: sess.CompactedSummary,
+		Metadata:         sess.Metadata,
+		State:            sess.State,
+		CreatedAt:        sess.CreatedAt,
+		UpdatedAt:        sess.UpdatedAt,
+		LastActivityAt:   sess.LastActivityAt,
+	}); err != nil {
+		return nil, err
+	}
+	for _, msg := range sess.Messages {
+		if err := enc.Encode(exportMessageRecord{
+			Type:        exportRecordMessage,
+			ID:          msg.ID,
+			Role:        msg.Role,
+			Content:     msg.Content,
+			Images:      msg.Images,
+			TokenCount:  msg.TokenCount,
+			IsCompacted: msg.IsCompacted,
+			CreatedAt:   msg.CreatedAt,
+			ToolCalls:   msg.ToolCalls,
+			ToolCallID:  msg.ToolCallID,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalSessionJSONL 从 JSON Lines 还原会话
+func UnmarshalSessionJSONL(data []byte) (*Session, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+
+	var sess *Session
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		var head struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(raw, &head); err != nil {
+			return nil, fmt.Errorf("session export line %d: %w", line, err)
+		}
+
+		switch head.Type {
+		case exportRecordSession:
+			if sess != nil {
+				return nil, fmt.Errorf("session export line %d: duplicate session record", line)
+			}
+			var rec exportSessionRecord
+			if err := json.Unmarshal(raw, &rec); err != nil {
+				return nil, fmt.Errorf("session export line %d: %w", line, err)
+			}
+			if rec.Version > ExportFormatVersion {
+				return nil, fmt.Errorf("unsupported session export version %d", rec.Version)
+			}
+			if rec.Key == "" {
+				return nil, fmt.Errorf("session export line %d: %w", line, ErrInvalidSessionKey)
+			}
+			sess = &Session{
+				Key:              rec.Key,
+				AgentID:          rec.AgentID,
+				Channel:          rec.Channel,
+				Scope:            rec.Scope,
+				ScopeID:          rec.ScopeID,
+				CompactedSummary: rec.CompactedSummary,
+				Metadata:         rec.Metadata,
+				State:            rec.State,
+				CreatedAt:        rec.CreatedAt,
+				UpdatedAt:        rec.UpdatedAt,
+				LastActivityAt:   rec.LastActivityAt,
+				Messages:         make([]*SessionMessage, 0),
+			}
+		case exportRecordMessage:
+			if sess == nil {
+				return nil, fmt.Errorf("session export line %d: message before session record", line)
+			}
+			var rec exportMessageRecord
+			if err := json.Unmarshal(raw, &rec); err != nil {
+				return nil, fmt.Errorf("session export line %d: %w", line, err)
+			}
+			sess.Messages = append(sess.Messages, &SessionMessage{
+				ID:          rec.ID,
+				Role:        rec.Role,
+				Content:     rec.Content,
+				Images:      rec.Images,
+				TokenCount:  rec.TokenCount,
+				IsCompacted: rec.IsCompacted,
+				CreatedAt:   rec.CreatedAt,
+				ToolCalls:   rec.ToolCalls,
+				ToolCallID:  rec.ToolCallID,
+			})
+		default:
+			return nil, fmt.Errorf("session export line %d: unknown record type %q", line, head.Type)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if sess == nil {
+		return nil, fmt.Errorf("session export: missing session record")
+	}
+	return sess, nil
+}
+
+// Export 将会话（元数据、消息、工具调用和压缩摘要）导出为 JSON Lines
+func (m *Manager) Export(ctx context.Context, key string) ([]byte, error) {
+	sess, err := m.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return MarshalSessionJSONL(sess)
+}
+
+// Import 导入 Export 生成的会话数据，会话键已存在时返回 ErrSessionAlreadyExists
+func (m *Manager) Import(ctx context.Context, data []byte) (*Session, error) {
+	sess, err := UnmarshalSessionJSONL(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.createWithMessages(ctx, sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// Fork 从会话历史中的某一条消息创建分支会话，原会话保持不变
+// 新会话使用原会话的 agent/channel/scope 和 newScopeID，包含截至 uptoMessageID（含）的消息，
+// uptoMessageID 为空时复制全部消息。截断点落在工具调用组中间时，未完成的工具调用组不会被复制
+func (m *Manager) Fork(ctx context.Context, key, newScopeID, uptoMessageID string) (*Session, error) {
+	src, err := m.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	end := len(src.Messages)
+	if uptoMessageID != "" {
+		end = -1
+		for i, msg := range src.Messages {
+			if msg.ID == uptoMessageID {
+				end = i + 1
+				break
+			}
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, uptoMessageID)
+		}
+	}
+	end = trimIncompleteToolCallGroup(src.Messages, end)
+
+	now := time.Now()
+	fork := &Session{
+		Key:     GenerateSessionKey(src.AgentID, src.Channel, src.Scope, newScopeID),
+		AgentID: src.AgentID,
+		Channel: src.Channel,
+		Scope:   src.Scope,
+		ScopeID: newScopeID,
+		Metadata: SessionMetadata{
+			Title:  src.Metadata.Title,
+			UserID: src.Metadata.UserID,
+			Model:  src.Metadata.Model,
+		},
+		State:          StateActive,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		LastActivityAt: now,
+	}
+	if len(src.Metadata.ExtraFields) > 0 {
+		fork.Metadata.ExtraFields = make(map[string]any, len(src.Metadata.ExtraFields))
+		for k, v := range src.Metadata.ExtraFields {
+			fork.Metadata.ExtraFields[k] = v
+		}
+	}
+
+	// 摘要覆盖全部已压缩消息，只有分支包含所有已压缩消息时才能沿用摘要；
+	// 否则丢弃摘要，分支内的消息恢复为未压缩
+	keepSummary := true
+	for _, msg := range src.Messages[end:] {
+		if msg.IsCompacted {
+			keepSummary = false
+			break
+		}
+	}
+	fork.Messages = make([]*SessionMessage, 0, end)
+	for _, msg := range src.Messages[:end] {
+		cp := cloneMessage(msg)
+		if !keepSummary {
+			cp.IsCompacted = false
+		}
+		fork.Messages = append(fork.Messages, cp)
+	}
+	if keepSummary {
+		fork.CompactedSummary = src.CompactedSummary
+	}
+	fork.Metadata.MessageCount = len(fork.Messages)
+	fork.Metadata.TotalTokenCount = countActiveTokens(fork)
+
+	if err := m.createWithMessages(ctx, fork); err != nil {
+		return nil, err
+	}
+	return fork, nil
+}
+
+// createWithMessages 创建会话并写入消息
+// 部分存储的 Create 只保存会话本身，消息通过随后的 Update 写入
+func (m *Manager) createWithMessages(ctx context.Context, sess *Session) error {
+	if _, err := m.storage.Get(ctx, sess.Key); err == nil {
+		return ErrSessionAlreadyExists
+	} else if !errors.Is(err, ErrSessionNotFound) {
+		return err
+	}
+	if err := m.storage.Create(ctx, sess); err != nil {
+		return err
+	}
+	if len(sess.Messages) == 0 {
+		return nil
+	}
+	return m.storage.Update(ctx, sess)
+}
+
+// trimIncompleteToolCallGroup 如果 msgs[:end] 以未收齐结果的工具调用组结尾，返回该组之前的位置
+func trimIncompleteToolCallGroup(msgs []*SessionMessage, end int) int {
+	start := end
+	for start > 0 && msgs[start-1].Role == string(schema.Tool) {
+		start--
+	}
+	if start == 0 {
+		return end
+	}
+	call := msgs[start-1]
+	if call.Role != string(schema.Assistant) || len(call.ToolCalls) == 0 {
+		return end
+	}
+	results := make(map[string]struct{}, end-start)
+	for _, msg := range msgs[start:end] {
+		results[msg.ToolCallID] = struct{}{}
+	}
+	for _, tc := range call.ToolCalls {
+		if _, ok := results[tc.ID]; !ok {
+			return start - 1
+		}
+	}
+	return end
+}
+
+// JSONLArchiveSink 将归档会话以 Export 格式写入目录，每个会话一个 .jsonl 文件
+// 文件名由会话键转换而来，重复导出会覆盖同名文件
+type JSONLArchiveSink struct {
+	dir string
+}
+
+// NewJSONLArchiveSink 创建写入 dir 的归档导出目标，目录不存在时自动创建
+func NewJSONLArchiveSink(dir string) (*JSONLArchiveSink, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &JSONLArchiveSink{dir: dir}, nil
+}
+
+// Export 导出会话
+func (s *JSONLArchiveSink) Export(ctx context.Context, sess *Session) error {
+	data, err := MarshalSessionJSONL(sess)
+	if err != nil {
+		return err
+	}
+	name := strings.NewReplacer(":", "_", "/", "_", "\\", "_").Replace(sess.Key) + ".jsonl"
+	tmp, err := os.CreateTemp(s.dir, ".export-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, name))
+}
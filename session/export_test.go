@@ -0,0 +1,214 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newExportTestSession(t *testing.T, mgr *Manager) *Session {
+	t.Helper()
+	ctx := context.Background()
+	sess, err := mgr.GetOrCreate(ctx, SessionRequest{AgentID: "agent", Channel: "api", Scope: ScopePerPeer, ScopeID: "peer"})
+	if err != nil {
+		t.Fatalf("GetOrCreate() error = %v", err)
+	}
+	msgs := []*SessionMessage{
+		{ID: "m1", Role: "user", Content: "检查磁盘", TokenCount: 5, IsCompacted: true},
+		{ID: "m2", Role: "assistant", Content: "好的", TokenCount: 2, IsCompacted: true},
+		{ID: "m3", Role: "user", Content: "看看 /var", Images: []string{"https://example.com/a.png"}, TokenCount: 5},
+		{ID: "m4", Role: "assistant", ToolCalls: []ToolCallInfo{{ID: "c1", Name: "bash", Arguments: `{"command":"df -h"}`}, {ID: "c2", Name: "bash", Arguments: `{"command":"du"}`}}, TokenCount: 8},
+		{ID: "m5", Role: "tool", ToolCallID: "c1", Content: "/dev/sda1 80%", TokenCount: 4},
+		{ID: "m6", Role: "tool", ToolCallID: "c2", Content: "12G /var", TokenCount: 4},
+		{ID: "m7", Role: "assistant", Content: "磁盘使用 80%", TokenCount: 6},
+	}
+	for _, msg := range msgs {
+		if err := mgr.AddMessage(ctx, sess.Key, msg); err != nil {
+			t.Fatalf("AddMessage() error = %v", err)
+		}
+	}
+	sess, _ = mgr.Get(ctx, sess.Key)
+	sess.CompactedSummary = "用户询问磁盘情况"
+	sess.Metadata.Model = "gpt-4o"
+	sess.Metadata.ExtraFields = map[string]any{"reasoning_effort": "high"}
+	if err := mgr.Update(ctx, sess); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	return sess
+}
+
+func TestManager_ExportImport(t *testing.T) {
+	for name, newStorage := range map[string]func(t *testing.T) SessionStorage{
+		"memory": func(t *testing.T) SessionStorage { return NewMemoryStorage() },
+		"sqlite": func(t *testing.T) SessionStorage {
+			s, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "sessions.db"))
+			if err != nil {
+				t.Fatalf("NewSQLiteStorage() error = %v", err)
+			}
+			t.Cleanup(func() { _ = s.Close() })
+			return s
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			src := NewManager(newStorage(t), nil)
+			orig := newExportTestSession(t, src)
+
+			data, err := src.Export(ctx, orig.Key)
+			if err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+			if lines := bytes.Count(data, []byte("\n")); lines != 8 {
+				t.Errorf("expected 1 session line and 7 message lines, got %d", lines)
+			}
+
+			dst := NewManager(newStorage(t), nil)
+			if _, err := dst.Import(ctx, data); err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+			got, err := dst.Get(ctx, orig.Key)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got.CompactedSummary != orig.CompactedSummary || got.Metadata.Model != "gpt-4o" ||
+				got.Metadata.ExtraFields["reasoning_effort"] != "high" || got.Metadata.MessageCount != 7 {
+				t.Errorf("session fields not restored: %+v", got.Metadata)
+			}
+			if len(got.Messages) != len(orig.Messages) {
+				t.Fatalf("expected %d messages, got %d", len(orig.Messages), len(got.Messages))
+			}
+			for i := range orig.Messages {
+				want, have := orig.Messages[i], got.Messages[i]
+				if want.ID != have.ID || want.Content != have.Content || want.IsCompacted != have.IsCompacted ||
+					want.ToolCallID != have.ToolCallID || !reflect.DeepEqual(want.ToolCalls, have.ToolCalls) ||
+					!reflect.DeepEqual(want.Images, have.Images) || !want.CreatedAt.Equal(have.CreatedAt) {
+					t.Errorf("message %d mismatch: want %+v, got %+v", i, want, have)
+				}
+			}
+
+			// 再次导出结果一致
+			again, _ := dst.Export(ctx, orig.Key)
+			if !bytes.Equal(data, again) {
+				t.Errorf("export is not stable:\n%s\n%s", data, again)
+			}
+
+			if _, err := dst.Import(ctx, data); !errors.Is(err, ErrSessionAlreadyExists) {
+				t.Errorf("expected ErrSessionAlreadyExists, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalSessionJSONL_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"empty":           "",
+		"message first":   `{"type":"message","id":"m1"}`,
+		"unknown type":    `{"type":"session","version":1,"key":"k"}` + "\n" + `{"type":"event"}`,
+		"future version":  `{"type":"session","version":99,"key":"k"}`,
+		"missing key":     `{"type":"session","version":1}`,
+		"malformed json":  `{"type":`,
+		"duplicate sessn": `{"type":"session","version":1,"key":"k"}` + "\n" + `{"type":"session","version":1,"key":"k"}`,
+	}
+	for name, data := range cases {
+		if _, err := UnmarshalSessionJSONL([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestManager_Fork(t *testing.T) {
+	ctx := context.Background()
+	mgr := NewManager(NewMemoryStorage(), nil)
+	orig := newExportTestSession(t, mgr)
+
+	fork, err := mgr.Fork(ctx, orig.Key, "retry", "m3")
+	if err != nil {
+		t.Fatalf("Fork() error = %v", err)
+	}
+	if fork.Key != GenerateSessionKey("agent", "api", ScopePerPeer, "retry") {
+		t.Errorf("unexpected fork key %s", fork.Key)
+	}
+	got, _ := mgr.Get(ctx, fork.Key)
+	if messageIDs(got.Messages) != "m1,m2,m3" {
+		t.Errorf("unexpected fork messages %s", messageIDs(got.Messages))
+	}
+	if got.CompactedSummary != orig.CompactedSummary || got.Metadata.Model != "gpt-4o" {
+		t.Errorf("fork should keep summary and model, got %+v", got)
+	}
+
+	// 原会话不受影响
+	src, _ := mgr.Get(ctx, orig.Key)
+	if len(src.Messages) != 7 {
+		t.Errorf("original session changed, got %d messages", len(src.Messages))
+	}
+
+	// 截断点落在工具调用组中间时不复制未完成的组
+	fork, err = mgr.Fork(ctx, orig.Key, "mid-tool", "m5")
+	if err != nil {
+		t.Fatalf("Fork() error = %v", err)
+	}
+	if messageIDs(fork.Messages) != "m1,m2,m3" {
+		t.Errorf("incomplete tool call group should be dropped, got %s", messageIDs(fork.Messages))
+	}
+
+	// 截断点在已压缩区间内时丢弃摘要并恢复消息
+	fork, err = mgr.Fork(ctx, orig.Key, "compacted", "m1")
+	if err != nil {
+		t.Fatalf("Fork() error = %v", err)
+	}
+	if fork.CompactedSummary != "" || fork.Messages[0].IsCompacted {
+		t.Errorf("summary must be dropped when fork point is inside compacted history")
+	}
+
+	if _, err := mgr.Fork(ctx, orig.Key, "missing", "nope"); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("expected ErrMessageNotFound, got %v", err)
+	}
+	if _, err := mgr.Fork(ctx, orig.Key, "retry", ""); !errors.Is(err, ErrSessionAlreadyExists) {
+		t.Errorf("expected ErrSessionAlreadyExists, got %v", err)
+	}
+}
+
+func TestJSONLArchiveSink(t *testing.T) {
+	ctx := context.Background()
+	mgr := NewManager(NewMemoryStorage(), nil)
+	orig := newExportTestSession(t, mgr)
+
+	dir := filepath.Join(t.TempDir(), "archive")
+	sink, err := NewJSONLArchiveSink(dir)
+	if err != nil {
+		t.Fatalf("NewJSONLArchiveSink() error = %v", err)
+	}
+	sess, _ := mgr.Get(ctx, orig.Key)
+	if err := sink.Export(ctx, sess); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "agent_agent_channel_api_scope_per_peer_peer.jsonl"))
+	if err != nil {
+		entries, _ := os.ReadDir(dir)
+		t.Fatalf("archive file not found: %v (dir: %v)", err, entries)
+	}
+	restored, err := UnmarshalSessionJSONL(data)
+	if err != nil || restored.Key != orig.Key {
+		t.Errorf("archived data not importable: %v", err)
+	}
+}
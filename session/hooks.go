@@ -0,0 +1,54 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import "sync"
+
+// endHooks 会话结束回调
+var endHooks = struct {
+	sync.RWMutex
+	next  int
+	hooks map[int]func(key string)
+}{hooks: make(map[int]func(key string))}
+
+// OnSessionEnd 注册会话结束回调，返回注销函数
+// 会话被删除（Manager.Delete、过期清理）或归档时调用，供工具释放按会话持有的资源（如常驻 Shell）
+func OnSessionEnd(fn func(key string)) (unregister func()) {
+	endHooks.Lock()
+	id := endHooks.next
+	endHooks.next++
+	endHooks.hooks[id] = fn
+	endHooks.Unlock()
+	return func() {
+		endHooks.Lock()
+		delete(endHooks.hooks, id)
+		endHooks.Unlock()
+	}
+}
+
+// notifySessionEnd 调用会话结束回调
+func notifySessionEnd(key string) {
+	endHooks.RLock()
+	hooks := make([]func(key string), 0, len(endHooks.hooks))
+	for _, fn := range endHooks.hooks {
+		hooks = append(hooks, fn)
+	}
+	endHooks.RUnlock()
+	for _, fn := range hooks {
+		fn(key)
+	}
+}
@@ -0,0 +1,364 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rulego/rulego/api/types"
+)
+
+// janitorPageSize 每次从存储读取的会话数
+const janitorPageSize = 100
+
+// ArchiveSink 归档会话的导出目标
+// 同一会话可能被重复导出（例如进程重启后），实现应按 Session.Key 幂等处理
+type ArchiveSink interface {
+	Export(ctx context.Context, session *Session) error
+}
+
+// ArchiveSinkFunc 函数形式的 ArchiveSink
+type ArchiveSinkFunc func(ctx context.Context, session *Session) error
+
+// Export 导出会话
+func (f ArchiveSinkFunc) Export(ctx context.Context, session *Session) error {
+	return f(ctx, session)
+}
+
+// JanitorStats 一次清理的统计
+type JanitorStats struct {
+	Scanned  int
+	Idled    int
+	Archived int
+	Exported int
+	Deleted  int
+}
+
+// Janitor 会话生命周期清理器，由 Manager 持有
+// 定期扫描存储：按 IdleTimeout/ArchiveTimeout 转换会话状态，归档时导出到 ArchiveSink，
+// 超过 TTL 的会话被删除。可通过 JanitorAspect 随规则引擎创建和销毁自动启停
+type Janitor struct {
+	storage SessionStorage
+	config  *SessionConfig
+
+	mu       sync.Mutex
+	sink     ArchiveSink
+	logger   types.Logger
+	refs     int
+	cancel   context.CancelFunc
+	done     chan struct{}
+	lastScan time.Time
+
+	// now 当前时间，测试中可替换
+	now func() time.Time
+}
+
+// newJanitor 创建清理器
+func newJanitor(storage SessionStorage, config *SessionConfig) *Janitor {
+	return &Janitor{
+		storage: storage,
+		config:  config,
+		now:     time.Now,
+	}
+}
+
+// SetSink 设置归档导出目标，nil 表示不导出
+func (j *Janitor) SetSink(sink ArchiveSink) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.sink = sink
+}
+
+// SetLogger 设置日志记录器
+func (j *Janitor) SetLogger(logger types.Logger) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.logger = logger
+}
+
+// Start 启动后台清理
+// 可被多次调用（例如多个规则引擎共享同一个 Manager），需要与 Stop 成对调用
+func (j *Janitor) Start() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.refs++
+	if j.refs > 1 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+	j.done = make(chan struct{})
+	go j.loop(ctx, j.done)
+}
+
+// Stop 停止后台清理，所有 Start 都被 Stop 后才真正停止，并等待进行中的清理结束
+func (j *Janitor) Stop() {
+	j.mu.Lock()
+	if j.refs == 0 {
+		j.mu.Unlock()
+		return
+	}
+	j.refs--
+	if j.refs > 0 {
+		j.mu.Unlock()
+		return
+	}
+	cancel, done := j.cancel, j.done
+	j.cancel, j.done = nil, nil
+	j.mu.Unlock()
+
+	cancel()
+	<-done
+}
+
+// Running 是否正在后台运行
+func (j *Janitor) Running() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.refs > 0
+}
+
+func (j *Janitor) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	interval := j.config.JanitorInterval
+	if interval <= 0 {
+		interval = DefaultJanitorInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := j.RunOnce(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				j.log("[SessionJanitor] run failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce 执行一次清理
+// 单个会话处理失败不会中断扫描，所有错误合并返回
+func (j *Janitor) RunOnce(ctx context.Context) (JanitorStats, error) {
+	var stats JanitorStats
+	now := j.now()
+
+	j.mu.Lock()
+	sink := j.sink
+	lastScan := j.lastScan
+	j.mu.Unlock()
+
+	// 先收集全部会话再处理，避免删除导致分页偏移
+	// 判断过期只需要元数据，消息仅在导出时按需加载
+	var sessions []*Session
+	for offset := 0; ; offset += janitorPageSize {
+		page, err := j.storage.List(ctx, &SessionQuery{Limit: janitorPageSize, Offset: offset, SkipMessages: true})
+		if err != nil {
+			return stats, err
+		}
+		sessions = append(sessions, page...)
+		if len(page) < janitorPageSize {
+			break
+		}
+	}
+	stats.Scanned = len(sessions)
+
+	var errs []error
+	for _, sess := range sessions {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		if err := j.process(ctx, sess, sink, lastScan, now, &stats); err != nil {
+			errs = append(errs, fmt.Errorf("session %s: %w", sess.Key, err))
+		}
+	}
+
+	j.mu.Lock()
+	j.lastScan = now
+	j.mu.Unlock()
+	return stats, errors.Join(errs...)
+}
+
+// process 处理单个会话
+func (j *Janitor) process(ctx context.Context, sess *Session, sink ArchiveSink, lastScan, now time.Time, stats *JanitorStats) error {
+	ttl, archiveTimeout := j.config.TTL, j.config.ArchiveTimeout
+
+	if sess.IsExpired(ttl, now) {
+		// 未启用归档、归档晚于过期或本轮才跨过归档阈值时会话尚未导出，删除前导出
+		neverExported := archiveTimeout <= 0 || archiveTimeout >= ttl ||
+			crossed(sess.LastActivityAt, lastScan, now, archiveTimeout)
+		if sink != nil && neverExported {
+			if err := j.export(ctx, sink, sess.Key, stats); err != nil {
+				return err
+			}
+		}
+		if err := j.storage.Delete(ctx, sess.Key); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return err
+		}
+		stats.Deleted++
+		notifySessionEnd(sess.Key)
+		return nil
+	}
+
+	// 存储可能已惰性更新状态，按空闲时长是否在本轮跨过归档阈值判断是否需要导出
+	if sink != nil && archiveTimeout > 0 && crossed(sess.LastActivityAt, lastScan, now, archiveTimeout) {
+		if err := j.export(ctx, sink, sess.Key, stats); err != nil {
+			return err
+		}
+	}
+
+	state := sess.ResolveState(j.config.IdleTimeout, archiveTimeout, now)
+	if state == sess.State {
+		return nil
+	}
+	if err := j.updateState(ctx, sess, state); err != nil {
+		return err
+	}
+	switch state {
+	case StateIdle:
+		stats.Idled++
+	case StateArchived:
+		stats.Archived++
+		notifySessionEnd(sess.Key)
+	}
+	return nil
+}
+
+// export 加载含消息的完整会话并导出，会话已被并发删除时跳过
+func (j *Janitor) export(ctx context.Context, sink ArchiveSink, key string, stats *JanitorStats) error {
+	sess, err := j.storage.Get(ctx, key)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if err := sink.Export(ctx, sess); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	stats.Exported++
+	return nil
+}
+
+// updateState 持久化状态，优先使用只更新状态的接口，避免覆盖并发写入的消息
+func (j *Janitor) updateState(ctx context.Context, sess *Session, state SessionState) error {
+	if ss, ok := j.storage.(StateStorage); ok {
+		return ss.UpdateState(ctx, sess.Key, state)
+	}
+	// 扫描得到的会话不含消息，需读取完整会话再整体更新
+	full, err := j.storage.Get(ctx, sess.Key)
+	if err != nil {
+		return err
+	}
+	full.State = state
+	return j.storage.Update(ctx, full)
+}
+
+// crossed 判断自 lastActivityAt 起的空闲时长是否在 (lastScan, now] 区间内达到 threshold
+// lastScan 为零值（首次扫描）时只要已达到阈值即视为跨过
+func crossed(lastActivityAt, lastScan, now time.Time, threshold time.Duration) bool {
+	if lastActivityAt.IsZero() {
+		return false
+	}
+	at := lastActivityAt.Add(threshold)
+	return !at.After(now) && (lastScan.IsZero() || at.After(lastScan))
+}
+
+func (j *Janitor) log(format string, args ...interface{}) {
+	j.mu.Lock()
+	logger := j.logger
+	j.mu.Unlock()
+	if logger != nil {
+		logger.Printf(format, args...)
+	}
+}
+
+// JanitorAspect 规则引擎切面，随规则引擎创建/重载启动清理器，随销毁停止
+// 通过 types.WithAspects(session.NewJanitorAspect(manager.Janitor())) 注册
+type JanitorAspect struct {
+	janitor *Janitor
+
+	mu      sync.Mutex
+	started bool
+}
+
+var (
+	_ types.OnCreatedAspect = (*JanitorAspect)(nil)
+	_ types.OnReloadAspect  = (*JanitorAspect)(nil)
+	_ types.OnDestroyAspect = (*JanitorAspect)(nil)
+)
+
+// NewJanitorAspect 创建清理器生命周期切面
+func NewJanitorAspect(janitor *Janitor) *JanitorAspect {
+	return &JanitorAspect{janitor: janitor}
+}
+
+// Order 返回执行顺序
+func (a *JanitorAspect) Order() int {
+	return 900
+}
+
+// New 为每个规则引擎创建独立实例，共享同一个清理器
+func (a *JanitorAspect) New() types.Aspect {
+	return &JanitorAspect{janitor: a.janitor}
+}
+
+// OnCreated 规则引擎创建后启动清理器
+func (a *JanitorAspect) OnCreated(chainCtx types.NodeCtx) error {
+	a.start(chainCtx)
+	return nil
+}
+
+// OnReload 规则引擎重载后确保清理器运行
+func (a *JanitorAspect) OnReload(chainCtx types.NodeCtx, ctx types.NodeCtx) error {
+	a.start(chainCtx)
+	return nil
+}
+
+// OnDestroy 规则引擎销毁时停止清理器
+func (a *JanitorAspect) OnDestroy(chainCtx types.NodeCtx) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.started {
+		return
+	}
+	a.started = false
+	a.janitor.Stop()
+}
+
+// start 每个规则引擎只持有一次引用，重复的 OnReload 不会重复启动
+func (a *JanitorAspect) start(chainCtx types.NodeCtx) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.started || a.janitor == nil {
+		return
+	}
+	if chainCtx != nil && chainCtx.Config().Logger != nil {
+		a.janitor.mu.Lock()
+		if a.janitor.logger == nil {
+			a.janitor.logger = chainCtx.Config().Logger
+		}
+		a.janitor.mu.Unlock()
+	}
+	a.started = true
+	a.janitor.Start()
+}
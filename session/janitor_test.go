@@ -0,0 +1,254 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rulego/rulego"
+	"github.com/rulego/rulego/api/types"
+)
+
+func newJanitorTestManager(t *testing.T, sessions map[string]time.Duration, now time.Time) (*Manager, SessionStorage) {
+	t.Helper()
+	storage := NewMemoryStorage()
+	cfg := DefaultSessionConfig()
+	cfg.TTL = 30 * 24 * time.Hour
+	cfg.IdleTimeout = time.Hour
+	cfg.ArchiveTimeout = 7 * 24 * time.Hour
+	mgr := NewManager(storage, cfg)
+	mgr.Janitor().now = func() time.Time { return now }
+
+	for scopeID, idle := range sessions {
+		sess := newSuiteSession("agent", "api", ScopePerPeer, scopeID)
+		sess.LastActivityAt = now.Add(-idle)
+		if err := storage.Create(context.Background(), sess); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	return mgr, storage
+}
+
+func TestJanitor_RunOnce(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	mgr, storage := newJanitorTestManager(t, map[string]time.Duration{
+		"active":   time.Minute,
+		"idle":     2 * time.Hour,
+		"archived": 8 * 24 * time.Hour,
+		"expired":  31 * 24 * time.Hour,
+	}, now)
+
+	var exported []string
+	mgr.Janitor().SetSink(ArchiveSinkFunc(func(ctx context.Context, sess *Session) error {
+		exported = append(exported, sess.ScopeID)
+		return nil
+	}))
+
+	stats, err := mgr.Janitor().RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	want := JanitorStats{Scanned: 4, Idled: 1, Archived: 1, Exported: 2, Deleted: 1}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+	if len(exported) != 2 {
+		t.Errorf("expected archived and expired sessions to be exported, got %v", exported)
+	}
+
+	for scopeID, state := range map[string]SessionState{"active": StateActive, "idle": StateIdle, "archived": StateArchived} {
+		sess, err := storage.Get(ctx, GenerateSessionKey("agent", "api", ScopePerPeer, scopeID))
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", scopeID, err)
+		}
+		if sess.State != state {
+			t.Errorf("session %s state = %s, want %s", scopeID, sess.State, state)
+		}
+	}
+	if _, err := storage.Get(ctx, GenerateSessionKey("agent", "api", ScopePerPeer, "expired")); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expired session should be deleted, got %v", err)
+	}
+
+	// 再次扫描不重复导出
+	exported = nil
+	if _, err := mgr.Janitor().RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if len(exported) != 0 {
+		t.Errorf("sessions should not be exported twice, got %v", exported)
+	}
+}
+
+// metadataOnlyStorage 记录 List 查询，且不实现 StateStorage，迫使清理器整体更新会话
+type metadataOnlyStorage struct {
+	SessionStorage
+	queries []SessionQuery
+}
+
+func (s *metadataOnlyStorage) List(ctx context.Context, query *SessionQuery) ([]*Session, error) {
+	s.queries = append(s.queries, *query)
+	return s.SessionStorage.List(ctx, query)
+}
+
+func TestJanitor_ListsMetadataOnly(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	storage := &metadataOnlyStorage{SessionStorage: NewMemoryStorage()}
+	cfg := DefaultSessionConfig()
+	cfg.TTL = 30 * 24 * time.Hour
+	cfg.IdleTimeout = time.Hour
+	cfg.ArchiveTimeout = 7 * 24 * time.Hour
+	mgr := NewManager(storage, cfg)
+	mgr.Janitor().now = func() time.Time { return now }
+
+	for scopeID, idle := range map[string]time.Duration{"idle": 2 * time.Hour, "archived": 8 * 24 * time.Hour} {
+		sess := newSuiteSession("agent", "api", ScopePerPeer, scopeID)
+		sess.LastActivityAt = now.Add(-idle)
+		if err := storage.Create(ctx, sess); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		sess.Messages = []*SessionMessage{NewSessionMessage("user", "hello "+scopeID)}
+		if err := storage.Update(ctx, sess); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+	}
+
+	var exported []*Session
+	mgr.Janitor().SetSink(ArchiveSinkFunc(func(ctx context.Context, sess *Session) error {
+		exported = append(exported, sess)
+		return nil
+	}))
+	if _, err := mgr.Janitor().RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+
+	for _, q := range storage.queries {
+		if !q.SkipMessages {
+			t.Errorf("janitor should list sessions without messages: %+v", q)
+		}
+	}
+	// 导出时按需加载消息
+	if len(exported) != 1 || len(exported[0].Messages) != 1 || exported[0].Messages[0].Content != "hello archived" {
+		t.Errorf("exported session should include messages: %+v", exported)
+	}
+	// 整体更新状态时不丢失消息
+	for _, scopeID := range []string{"idle", "archived"} {
+		sess, err := storage.Get(ctx, GenerateSessionKey("agent", "api", ScopePerPeer, scopeID))
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", scopeID, err)
+		}
+		if sess.State == StateActive || len(sess.Messages) != 1 {
+			t.Errorf("session %s: state = %s, messages = %d", scopeID, sess.State, len(sess.Messages))
+		}
+	}
+}
+
+func TestJanitor_ReactivatedByNewMessage(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	mgr, storage := newJanitorTestManager(t, map[string]time.Duration{"peer": 2 * time.Hour}, now)
+	key := GenerateSessionKey("agent", "api", ScopePerPeer, "peer")
+
+	if _, err := mgr.Janitor().RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if err := mgr.AddMessage(ctx, key, NewSessionMessage("user", "hello again")); err != nil {
+		t.Fatalf("AddMessage() error = %v", err)
+	}
+	sess, _ := storage.Get(ctx, key)
+	if sess.State != StateActive {
+		t.Errorf("expected session to be reactivated, got %s", sess.State)
+	}
+}
+
+func TestJanitor_SinkErrorKeepsSession(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	mgr, storage := newJanitorTestManager(t, map[string]time.Duration{"expired": 31 * 24 * time.Hour}, now)
+	mgr.Janitor().SetSink(ArchiveSinkFunc(func(ctx context.Context, sess *Session) error {
+		return errors.New("sink down")
+	}))
+
+	if _, err := mgr.Janitor().RunOnce(ctx); err == nil {
+		t.Fatal("expected sink error")
+	}
+	if _, err := storage.Get(ctx, GenerateSessionKey("agent", "api", ScopePerPeer, "expired")); err != nil {
+		t.Errorf("session must be kept when export fails, got %v", err)
+	}
+}
+
+func TestJanitor_StartStop(t *testing.T) {
+	cfg := DefaultSessionConfig()
+	cfg.TTL = time.Millisecond
+	cfg.JanitorInterval = 10 * time.Millisecond
+	storage := NewMemoryStorage()
+	mgr := NewManager(storage, cfg)
+
+	sess := newSuiteSession("agent", "api", ScopePerPeer, "peer")
+	sess.LastActivityAt = time.Now().Add(-time.Hour)
+	_ = storage.Create(context.Background(), sess)
+
+	j := mgr.Janitor()
+	j.Start()
+	j.Start()
+	j.Stop()
+	if !j.Running() {
+		t.Fatal("janitor should keep running until every Start is stopped")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := storage.Get(context.Background(), sess.Key); errors.Is(err, ErrSessionNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background janitor did not delete expired session")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	j.Stop()
+	if j.Running() {
+		t.Error("janitor should be stopped")
+	}
+}
+
+func TestJanitorAspect_RuleEngineLifecycle(t *testing.T) {
+	mgr := NewManager(NewMemoryStorage(), nil)
+	dsl := `{"ruleChain":{"id":"janitor_aspect_test","name":"janitor"},"metadata":{"nodes":[]}}`
+
+	engine, err := rulego.New("janitor_aspect_test", []byte(dsl), types.WithAspects(NewJanitorAspect(mgr.Janitor())))
+	if err != nil {
+		t.Fatalf("rulego.New() error = %v", err)
+	}
+	if !mgr.Janitor().Running() {
+		t.Fatal("janitor should start with the rule engine")
+	}
+	if err := engine.ReloadSelf([]byte(dsl)); err != nil {
+		t.Fatalf("ReloadSelf() error = %v", err)
+	}
+	if !mgr.Janitor().Running() {
+		t.Fatal("janitor should keep running after reload")
+	}
+	rulego.Del("janitor_aspect_test")
+	if mgr.Janitor().Running() {
+		t.Error("janitor should stop when the rule engine is destroyed")
+	}
+}
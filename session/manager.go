@@ -3,6 +3,7 @@ package session
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -38,6 +39,37 @@ type SessionManager interface {
 	GetConfig() *SessionConfig
 }
 
+// PruningManager 支持修剪已存储消息的会话管理器（可选接口）
+type PruningManager interface {
+	// PruneIfNeeded 按修剪配置修剪会话中已存储的消息
+	// 返回值: 是否修改了会话, 错误
+	PruneIfNeeded(ctx context.Context, sessionKey string) (bool, error)
+}
+
+// PortableManager 支持导出、导入和分支会话的会话管理器（可选接口）
+type PortableManager interface {
+	// Export 将会话导出为 JSON Lines
+	Export(ctx context.Context, key string) ([]byte, error)
+
+	// Import 导入 Export 生成的会话数据
+	Import(ctx context.Context, data []byte) (*Session, error)
+
+	// Fork 从会话历史中的某一条消息创建分支会话
+	Fork(ctx context.Context, key, newScopeID, uptoMessageID string) (*Session, error)
+}
+
+// ApprovalManager 支持工具调用审批记录的会话管理器（可选接口）
+type ApprovalManager interface {
+	// AddApproval 添加工具调用审批记录
+	AddApproval(ctx context.Context, sessionKey string, approval *ToolApproval) error
+
+	// UpdateApproval 修改审批记录（读改写在锁内完成）
+	UpdateApproval(ctx context.Context, sessionKey, id string, update func(*ToolApproval) error) (*ToolApproval, error)
+
+	// ListApprovals 列出会话中的审批记录
+	ListApprovals(ctx context.Context, sessionKey string) ([]ToolApproval, error)
+}
+
 // SessionRequest 会话请求
 type SessionRequest struct {
 	AgentID  string
@@ -61,6 +93,12 @@ type SessionConfig struct {
 	// IdleTimeout 空闲超时
 	IdleTimeout time.Duration
 
+	// ArchiveTimeout 空闲超过该时间后归档，0 表示不自动归档
+	ArchiveTimeout time.Duration
+
+	// JanitorInterval 后台清理器扫描间隔，0 使用 DefaultJanitorInterval
+	JanitorInterval time.Duration
+
 	// PruningConfig 修剪配置
 	PruningConfig *PruningConfig
 
@@ -85,6 +123,9 @@ type PruningConfig struct {
 	// 0 表示不限制（加载所有）
 	// N 表示只保留最近 N 组工具调用
 	KeepToolCallsCount int
+
+	// CacheTTL 模型提示词缓存的有效期，仅 cache_ttl 模式使用，0 使用 DefaultPromptCacheTTL
+	CacheTTL time.Duration
 }
 
 // PruneMode 修剪模式
@@ -103,6 +144,9 @@ type CompactionConfig struct {
 	TriggerThreshold   float64
 	KeepRecentCount    int
 	MinMessagesToCompact int
+
+	// SummaryPrompt 摘要提示词，为空使用 DefaultCompactionPrompt
+	SummaryPrompt string
 }
 
 // DefaultSessionConfig 默认会话配置
@@ -112,6 +156,7 @@ func DefaultSessionConfig() *SessionConfig {
 		MaxTokenCount: 128000,
 		TTL:          24 * time.Hour * 30, // 30天
 		IdleTimeout:  1 * time.Hour,
+		ArchiveTimeout: DefaultSessionArchiveTimeout,
 		PruningConfig: &PruningConfig{
 			Enabled:            false,
 			Mode:               PruneModeSoft,
@@ -134,6 +179,10 @@ func DefaultSessionConfig() *SessionConfig {
 type Manager struct {
 	storage SessionStorage
 	config  SessionConfig
+	janitor *Janitor
+	ledger  UsageLedger
+	// approvalMu 串行化审批记录的读改写
+	approvalMu sync.Mutex
 }
 
 // NewManager 创建新的会话管理器
@@ -141,10 +190,37 @@ func NewManager(storage SessionStorage, config *SessionConfig) *Manager {
 	if config == nil {
 		config = DefaultSessionConfig()
 	}
-	return &Manager{
+	if es, ok := storage.(ExpirableStorage); ok {
+		es.SetExpiration(config.TTL, config.IdleTimeout, config.ArchiveTimeout)
+	}
+	m := &Manager{
 		storage: storage,
 		config:  *config,
 	}
+	m.janitor = newJanitor(storage, &m.config)
+	if ls, ok := storage.(UsageLedgerStorage); ok {
+		m.ledger = ls.UsageLedger()
+	} else {
+		m.ledger = NewMemoryUsageLedger()
+	}
+	return m
+}
+
+// Janitor 返回会话生命周期清理器，需要调用 Start 或注册 JanitorAspect 后才会后台运行
+func (m *Manager) Janitor() *Janitor {
+	return m.janitor
+}
+
+// UsageLedger 返回用量账本，存储实现了 UsageLedgerStorage 时与会话持久化在一起
+func (m *Manager) UsageLedger() UsageLedger {
+	return m.ledger
+}
+
+// SetUsageLedger 替换用量账本，需在开始处理请求前调用
+func (m *Manager) SetUsageLedger(ledger UsageLedger) {
+	if ledger != nil {
+		m.ledger = ledger
+	}
 }
 
 // GetOrCreate 获取或创建会话
@@ -186,9 +262,13 @@ func (m *Manager) Update(ctx context.Context, session *Session) error {
 	return m.storage.Update(ctx, session)
 }
 
-// Delete 删除会话
+// Delete 删除会话，成功后触发会话结束回调
 func (m *Manager) Delete(ctx context.Context, key string) error {
-	return m.storage.Delete(ctx, key)
+	if err := m.storage.Delete(ctx, key); err != nil {
+		return err
+	}
+	notifySessionEnd(key)
+	return nil
 }
 
 // List 列出会话
@@ -198,8 +278,8 @@ func (m *Manager) List(ctx context.Context, query *SessionQuery) ([]*Session, er
 
 // CompactIfNeeded 按需压缩会话（默认实现不支持压缩）
 func (m *Manager) CompactIfNeeded(ctx context.Context, sessionKey string) (bool, error) {
-	// 默认 Manager 不支持压缩功能
-	// 实际的压缩由 SessionManagerAdapter 实现
+	// 默认 Manager 不持有模型，不执行压缩
+	// 需要压缩时使用 NewCompactingManager
 	return false, nil
 }
 
@@ -221,6 +301,7 @@ func (m *Manager) createSession(ctx context.Context, req SessionRequest) (*Sessi
 		Messages:   make([]*SessionMessage, 0),
 		Metadata: SessionMetadata{
 			Title:        fmt.Sprintf("Session %s", req.ScopeID),
+			UserID:       req.UserID,
 			MessageCount: 0,
 		},
 		State:          StateActive,
@@ -257,3 +338,28 @@ func (s *Session) ShouldPrune(config *PruningConfig) bool {
 
 	return len(s.Messages) > config.KeepRecentCount
 }
+
+// ResolveState 根据最近活动时间计算会话当前应处的状态
+// active/idle 会话空闲超过 IdleTimeout 转为 idle，超过 ArchiveTimeout 转为 archived；
+// 超时配置 <= 0 表示不启用对应转换
+func (s *Session) ResolveState(idleTimeout, archiveTimeout time.Duration, now time.Time) SessionState {
+	if s.State == StateArchived || s.LastActivityAt.IsZero() {
+		return s.State
+	}
+	idle := now.Sub(s.LastActivityAt)
+	if archiveTimeout > 0 && idle >= archiveTimeout {
+		return StateArchived
+	}
+	if idleTimeout > 0 && idle >= idleTimeout {
+		return StateIdle
+	}
+	return s.State
+}
+
+// IsExpired 检查会话是否已超过 TTL（自最近一次活动起计算），ttl <= 0 表示永不过期
+func (s *Session) IsExpired(ttl time.Duration, now time.Time) bool {
+	if ttl <= 0 || s.LastActivityAt.IsZero() {
+		return false
+	}
+	return now.Sub(s.LastActivityAt) >= ttl
+}
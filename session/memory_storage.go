@@ -2,6 +2,7 @@ package session
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 )
@@ -149,12 +150,44 @@ func (m *MemoryStorage) AddMessage(ctx context.Context, sessionKey string, msg *
 	}
 	session.Metadata.MessageCount++
 	session.Metadata.TotalTokenCount += msg.TokenCount - droppedTokens
+	session.State = StateActive
 	session.UpdatedAt = time.Now()
 	session.LastActivityAt = time.Now()
 
 	return nil
 }
 
+// UpdateState 只更新会话状态
+func (m *MemoryStorage) UpdateState(ctx context.Context, key string, state SessionState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, exists := m.sessions[key]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	session.State = state
+	return nil
+}
+
+// UpdateMetadata 只更新会话元数据
+func (m *MemoryStorage) UpdateMetadata(ctx context.Context, key string, update func(*SessionMetadata) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, exists := m.sessions[key]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	metadata := session.Metadata
+	metadata.Approvals = append([]ToolApproval(nil), session.Metadata.Approvals...)
+	if err := update(&metadata); err != nil {
+		return err
+	}
+	session.Metadata = metadata
+	return nil
+}
+
 // GetHistory 获取会话历史消息
 func (m *MemoryStorage) GetHistory(ctx context.Context, sessionKey string, limit int) ([]*SessionMessage, error) {
 	m.mu.RLock()
@@ -210,14 +243,25 @@ func (m *MemoryStorage) List(ctx context.Context, query *SessionQuery) ([]*Sessi
 		}
 
 		sessionCopy := *session
-		sessionCopy.Messages = make([]*SessionMessage, 0, len(m.messages[session.Key]))
-		for _, msg := range m.messages[session.Key] {
-			sessionCopy.Messages = append(sessionCopy.Messages, cloneMessage(msg))
+		sessionCopy.Messages = nil
+		if query == nil || !query.SkipMessages {
+			sessionCopy.Messages = make([]*SessionMessage, 0, len(m.messages[session.Key]))
+			for _, msg := range m.messages[session.Key] {
+				sessionCopy.Messages = append(sessionCopy.Messages, cloneMessage(msg))
+			}
 		}
 
 		result = append(result, &sessionCopy)
 	}
 
+	// 按创建时间排序，保证分页结果稳定
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.Before(result[j].CreatedAt)
+		}
+		return result[i].Key < result[j].Key
+	})
+
 	// 应用limit和offset
 	if query != nil {
 		if query.Offset > 0 {
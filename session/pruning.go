@@ -0,0 +1,206 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/rulego/rulego-components-ai/utils/token"
+)
+
+// PruneResult 一次修剪的统计
+type PruneResult struct {
+	// TruncatedToolResults 被截断的工具结果数
+	TruncatedToolResults int
+	// DroppedMessages 被删除的消息数
+	DroppedMessages int
+}
+
+// Changed 是否修改了消息
+func (r PruneResult) Changed() bool {
+	return r.TruncatedToolResults > 0 || r.DroppedMessages > 0
+}
+
+// PruneIfNeeded 按 PruningConfig 修剪会话中已存储的消息
+// 最近 KeepRecentCount 条未压缩消息不受影响，不同模式的处理：
+//   - soft: 截断超过 MaxToolResultSize 的工具结果
+//   - hard: 在 soft 基础上删除较早的工具调用组（assistant 工具调用及其结果成对删除）
+//   - cache_ttl: 仅当提示词缓存已过期时按 hard 处理，且只处理早于缓存窗口的消息，避免缓存前缀失效
+//
+// 返回值: 是否修改了会话, 错误
+func (m *Manager) PruneIfNeeded(ctx context.Context, sessionKey string) (bool, error) {
+	cfg := m.config.PruningConfig
+	if cfg == nil || !cfg.Enabled {
+		return false, nil
+	}
+	sess, err := m.storage.Get(ctx, sessionKey)
+	if err != nil {
+		return false, err
+	}
+	if !sess.ShouldPrune(cfg) {
+		return false, nil
+	}
+
+	pruned, result := PruneMessages(sess.Messages, cfg, sess.Metadata.Model, sess.LastActivityAt, time.Now())
+	if !result.Changed() {
+		return false, nil
+	}
+
+	// 修剪期间可能有新消息写入，重新读取后按 ID 应用修剪结果，避免覆盖新消息
+	latest, err := m.storage.Get(ctx, sessionKey)
+	if err != nil {
+		return false, err
+	}
+	latest.Messages = mergePrunedMessages(latest.Messages, sess.Messages, pruned)
+	latest.Metadata.MessageCount = len(latest.Messages)
+	latest.Metadata.TotalTokenCount = countActiveTokens(latest)
+	latest.UpdatedAt = time.Now()
+	if err := m.storage.Update(ctx, latest); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// mergePrunedMessages 把基于 original 得到的修剪结果按消息 ID 应用到最新的消息列表
+// original 中有而 pruned 中没有的消息被删除，被修改的消息替换为修剪后的版本，其余消息（包括新写入的）保持不变
+func mergePrunedMessages(latest, original, pruned []*SessionMessage) []*SessionMessage {
+	prunedByID := make(map[string]*SessionMessage, len(pruned))
+	for _, msg := range pruned {
+		prunedByID[msg.ID] = msg
+	}
+	originalIDs := make(map[string]struct{}, len(original))
+	for _, msg := range original {
+		originalIDs[msg.ID] = struct{}{}
+	}
+	merged := make([]*SessionMessage, 0, len(latest))
+	for _, msg := range latest {
+		if _, ok := originalIDs[msg.ID]; !ok {
+			merged = append(merged, msg)
+			continue
+		}
+		if replaced, ok := prunedByID[msg.ID]; ok {
+			merged = append(merged, replaced)
+		}
+	}
+	return merged
+}
+
+// PruneMessages 按修剪配置处理消息，返回新的消息列表，不修改入参中的消息
+// lastActivityAt 为会话最近一次活动时间，用于 cache_ttl 模式判断提示词缓存是否仍然有效
+// model 为会话使用的模型，用于重新计算被修改消息的 token 数
+func PruneMessages(msgs []*SessionMessage, config *PruningConfig, model string, lastActivityAt, now time.Time) ([]*SessionMessage, PruneResult) {
+	var result PruneResult
+	if config == nil || len(msgs) == 0 {
+		return msgs, result
+	}
+
+	boundary := pruneBoundary(msgs, config.KeepRecentCount)
+	if config.Mode == PruneModeCacheTTL {
+		cacheTTL := config.CacheTTL
+		if cacheTTL <= 0 {
+			cacheTTL = DefaultPromptCacheTTL
+		}
+		cutoff := now.Add(-cacheTTL)
+		// 缓存仍然有效时修改任何前缀都会使缓存失效
+		if lastActivityAt.After(cutoff) {
+			return msgs, result
+		}
+		for i := 0; i < boundary; i++ {
+			if msgs[i].CreatedAt.After(cutoff) {
+				boundary = i
+				break
+			}
+		}
+	}
+	// 区间末尾不能停在工具调用组中间
+	for boundary > 0 && boundary < len(msgs) && msgs[boundary].Role == string(schema.Tool) {
+		boundary--
+	}
+	if boundary <= 0 {
+		return msgs, result
+	}
+
+	maxSize := config.MaxToolResultSize
+	if maxSize <= 0 {
+		maxSize = MaxToolResultSize
+	}
+	dropToolCalls := config.Mode == PruneModeHard || config.Mode == PruneModeCacheTTL
+
+	pruned := make([]*SessionMessage, 0, len(msgs))
+	for i := 0; i < boundary; i++ {
+		msg := msgs[i]
+		if msg.IsCompacted {
+			pruned = append(pruned, msg)
+			continue
+		}
+		if dropToolCalls {
+			if msg.Role == string(schema.Tool) {
+				// 所属的工具调用已在下面一并处理，这里只会遇到孤立的工具结果
+				result.DroppedMessages++
+				continue
+			}
+			if msg.Role == string(schema.Assistant) && len(msg.ToolCalls) > 0 {
+				groupEnd := i + 1
+				for groupEnd < boundary && msgs[groupEnd].Role == string(schema.Tool) {
+					groupEnd++
+				}
+				result.DroppedMessages += groupEnd - i - 1
+				if msg.Content != "" {
+					// 保留 assistant 的文字回复，去掉工具调用，保持调用与结果成对
+					stripped := *msg
+					stripped.ToolCalls = nil
+					stripped.TokenCount = token.CountTokens(model, stripped.Content)
+					pruned = append(pruned, &stripped)
+				} else {
+					result.DroppedMessages++
+				}
+				i = groupEnd - 1
+				continue
+			}
+		}
+		if msg.Role == string(schema.Tool) && len(msg.Content) > maxSize {
+			truncated := *msg
+			truncated.Content = truncateString(msg.Content, maxSize)
+			truncated.TokenCount = token.CountTokens(model, truncated.Content)
+			pruned = append(pruned, &truncated)
+			result.TruncatedToolResults++
+			continue
+		}
+		pruned = append(pruned, msg)
+	}
+	if !result.Changed() {
+		return msgs, result
+	}
+	return append(pruned, msgs[boundary:]...), result
+}
+
+// pruneBoundary 返回可修剪区间的结束下标，之后的最近 keepCount 条未压缩消息受保护
+func pruneBoundary(msgs []*SessionMessage, keepCount int) int {
+	if keepCount <= 0 {
+		keepCount = DefaultKeepRecentCount
+	}
+	boundary := len(msgs)
+	for kept := 0; boundary > 0 && kept < keepCount; {
+		boundary--
+		if !msgs[boundary].IsCompacted {
+			kept++
+		}
+	}
+	return boundary
+}
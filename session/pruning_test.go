@@ -0,0 +1,227 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rulego/rulego-components-ai/utils/token"
+)
+
+// pruneTestMessages 两组工具调用 + 最近的普通对话
+func pruneTestMessages(createdAt time.Time) []*SessionMessage {
+	big := strings.Repeat("x", 100)
+	msgs := []*SessionMessage{
+		{ID: "1", Role: "user", Content: "list files"},
+		{ID: "2", Role: "assistant", ToolCalls: []ToolCallInfo{{ID: "c1", Name: "bash"}, {ID: "c2", Name: "bash"}}},
+		{ID: "3", Role: "tool", ToolCallID: "c1", Content: big},
+		{ID: "4", Role: "tool", ToolCallID: "c2", Content: "ok"},
+		{ID: "5", Role: "assistant", Content: "checking", ToolCalls: []ToolCallInfo{{ID: "c3", Name: "read"}}},
+		{ID: "6", Role: "tool", ToolCallID: "c3", Content: big},
+		{ID: "7", Role: "assistant", Content: "done"},
+		{ID: "8", Role: "user", Content: "thanks"},
+		{ID: "9", Role: "assistant", Content: "welcome"},
+	}
+	for _, msg := range msgs {
+		msg.CreatedAt = createdAt
+	}
+	return msgs
+}
+
+func messageIDs(msgs []*SessionMessage) string {
+	ids := make([]string, len(msgs))
+	for i, msg := range msgs {
+		ids[i] = msg.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestPruneMessages_Soft(t *testing.T) {
+	now := time.Now()
+	msgs := pruneTestMessages(now)
+	cfg := &PruningConfig{Enabled: true, Mode: PruneModeSoft, KeepRecentCount: 2, MaxToolResultSize: 24}
+
+	pruned, result := PruneMessages(msgs, cfg, "", now, now)
+	if result.TruncatedToolResults != 2 || result.DroppedMessages != 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if messageIDs(pruned) != messageIDs(msgs) {
+		t.Errorf("soft mode must keep all messages, got %s", messageIDs(pruned))
+	}
+	if pruned[2].Content != "xxxxxxxxxx"+truncatedMarker {
+		t.Errorf("tool result not truncated within the limit: %q", pruned[2].Content)
+	}
+	if len(msgs[2].Content) != 100 {
+		t.Error("input messages must not be modified")
+	}
+
+	// 截断后的结果不超过上限，再次修剪不再修改
+	if _, result := PruneMessages(pruned, cfg, "", now, now); result.Changed() {
+		t.Errorf("second prune pass should not change messages, got %+v", result)
+	}
+}
+
+func TestPruneMessages_SoftKeepsSavedTruncation(t *testing.T) {
+	now := time.Now()
+	msgs := pruneTestMessages(now)
+	// 保存时已截断的工具结果带有截断标记，长度超过上限
+	msgs[2].Content = ProcessToolResult(strings.Repeat("x", MaxToolResultSize*2))
+	msgs[5].Content = strings.Repeat("中", MaxToolResultSize)
+	cfg := &PruningConfig{Enabled: true, Mode: PruneModeSoft, KeepRecentCount: 2}
+
+	pruned, result := PruneMessages(msgs, cfg, "", now, now)
+	if result.TruncatedToolResults != 2 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	for _, i := range []int{2, 5} {
+		if len(pruned[i].Content) > MaxToolResultSize || !utf8.ValidString(pruned[i].Content) {
+			t.Errorf("message %d truncated to %d bytes, valid utf8=%v", i, len(pruned[i].Content), utf8.ValidString(pruned[i].Content))
+		}
+	}
+	if _, result := PruneMessages(pruned, cfg, "", now, now); result.Changed() {
+		t.Errorf("second prune pass should not change messages, got %+v", result)
+	}
+}
+
+func TestPruneMessages_Hard(t *testing.T) {
+	now := time.Now()
+	msgs := pruneTestMessages(now)
+	cfg := &PruningConfig{Enabled: true, Mode: PruneModeHard, KeepRecentCount: 2}
+
+	pruned, result := PruneMessages(msgs, cfg, "", now, now)
+	if got := messageIDs(pruned); got != "1,5,7,8,9" {
+		t.Fatalf("unexpected messages after hard prune: %s", got)
+	}
+	if result.DroppedMessages != 4 {
+		t.Errorf("expected 4 dropped messages, got %d", result.DroppedMessages)
+	}
+	if len(pruned[1].ToolCalls) != 0 || pruned[1].Content != "checking" {
+		t.Errorf("assistant text should be kept without tool calls: %+v", pruned[1])
+	}
+	if len(msgs[4].ToolCalls) != 1 {
+		t.Error("input messages must not be modified")
+	}
+}
+
+func TestPruneMessages_KeepsToolCallGroupInRecentWindow(t *testing.T) {
+	now := time.Now()
+	msgs := pruneTestMessages(now)[:6]
+	cfg := &PruningConfig{Enabled: true, Mode: PruneModeHard, KeepRecentCount: 1}
+
+	// 保留区落在最后一个工具结果上，整组工具调用都应保留
+	pruned, _ := PruneMessages(msgs, cfg, "", now, now)
+	if got := messageIDs(pruned); got != "1,5,6" {
+		t.Fatalf("unexpected messages: %s", got)
+	}
+	if len(pruned[1].ToolCalls) != 1 {
+		t.Error("tool call paired with a kept result must not be stripped")
+	}
+}
+
+func TestPruneMessages_CacheTTL(t *testing.T) {
+	now := time.Now()
+	cfg := &PruningConfig{Enabled: true, Mode: PruneModeCacheTTL, KeepRecentCount: 2, CacheTTL: 5 * time.Minute}
+
+	// 缓存仍有效时不修剪
+	msgs := pruneTestMessages(now.Add(-10 * time.Minute))
+	if _, result := PruneMessages(msgs, cfg, "", now.Add(-time.Minute), now); result.Changed() {
+		t.Fatalf("warm cache must not be pruned, got %+v", result)
+	}
+
+	// 缓存过期后只修剪早于缓存窗口的消息
+	for _, msg := range msgs[4:] {
+		msg.CreatedAt = now.Add(-time.Minute)
+	}
+	pruned, _ := PruneMessages(msgs, cfg, "", now.Add(-10*time.Minute), now)
+	if got := messageIDs(pruned); got != "1,5,6,7,8,9" {
+		t.Fatalf("unexpected messages after cache_ttl prune: %s", got)
+	}
+}
+
+// fixedTokenizer 每段文本固定计为 n 个 token
+type fixedTokenizer struct{ n int }
+
+func (fixedTokenizer) Name() string        { return "prune-test" }
+func (fixedTokenizer) Encode(string) []int { return nil }
+func (f fixedTokenizer) Count(string) int  { return f.n }
+
+func TestPruneMessages_CountsTokensWithModel(t *testing.T) {
+	token.RegisterEncoding(fixedTokenizer{n: 7})
+	token.RegisterModelEncoding("prune-test-model", "prune-test")
+	defer func() {
+		token.UnregisterModelEncoding("prune-test-model")
+		token.UnregisterEncoding("prune-test")
+	}()
+
+	now := time.Now()
+	cfg := &PruningConfig{Enabled: true, Mode: PruneModeSoft, KeepRecentCount: 2, MaxToolResultSize: 10}
+	pruned, _ := PruneMessages(pruneTestMessages(now), cfg, "prune-test-model", now, now)
+	if pruned[2].TokenCount != 7 || pruned[5].TokenCount != 7 {
+		t.Errorf("truncated tool results should be counted with the model tokenizer, got %d and %d",
+			pruned[2].TokenCount, pruned[5].TokenCount)
+	}
+}
+
+func TestManager_PruneIfNeeded(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultSessionConfig()
+	cfg.PruningConfig = &PruningConfig{Enabled: true, Mode: PruneModeHard, KeepRecentCount: 2}
+	mgr := NewManager(NewMemoryStorage(), cfg)
+	sess, err := mgr.GetOrCreate(ctx, SessionRequest{AgentID: "agent", Channel: "api", Scope: ScopePerPeer, ScopeID: "peer"})
+	if err != nil {
+		t.Fatalf("GetOrCreate() error = %v", err)
+	}
+	for _, msg := range pruneTestMessages(time.Now()) {
+		msg.TokenCount = 10
+		if err := mgr.AddMessage(ctx, sess.Key, msg); err != nil {
+			t.Fatalf("AddMessage() error = %v", err)
+		}
+	}
+
+	pruned, err := mgr.PruneIfNeeded(ctx, sess.Key)
+	if err != nil || !pruned {
+		t.Fatalf("expected session to be pruned, got pruned=%v err=%v", pruned, err)
+	}
+	got, _ := mgr.Get(ctx, sess.Key)
+	if got.Metadata.MessageCount != 5 || len(got.Messages) != 5 {
+		t.Errorf("expected 5 messages, got count=%d len=%d", got.Metadata.MessageCount, len(got.Messages))
+	}
+	if got.Metadata.TotalTokenCount >= 90 {
+		t.Errorf("token count should drop after pruning, got %d", got.Metadata.TotalTokenCount)
+	}
+
+	if pruned, _ := mgr.PruneIfNeeded(ctx, sess.Key); pruned {
+		t.Error("second prune should be a no-op")
+	}
+}
+
+func TestMergePrunedMessages(t *testing.T) {
+	original := pruneTestMessages(time.Now())
+	cfg := &PruningConfig{Enabled: true, Mode: PruneModeHard, KeepRecentCount: 2, MaxToolResultSize: 24}
+	pruned, _ := PruneMessages(original, cfg, "", time.Now(), time.Now())
+
+	// 修剪期间写入了新消息
+	latest := append(append([]*SessionMessage{}, original...), &SessionMessage{ID: "10", Role: "user", Content: "more"})
+	merged := mergePrunedMessages(latest, original, pruned)
+	if got, want := messageIDs(merged), messageIDs(pruned)+",10"; got != want {
+		t.Errorf("merged messages = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,637 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rulego/rulego-components-ai/constants"
+)
+
+// Redis 哈希字段
+const (
+	redisFieldAgentID          = "agentId"
+	redisFieldChannel          = "channel"
+	redisFieldScope            = "scope"
+	redisFieldScopeID          = "scopeId"
+	redisFieldCompactedSummary = "compactedSummary"
+	redisFieldMetadata         = "metadata"
+	redisFieldMessageCount     = "messageCount"
+	redisFieldTotalTokenCount  = "totalTokenCount"
+	redisFieldState            = "state"
+	redisFieldCreatedAt        = "createdAt"
+	redisFieldUpdatedAt        = "updatedAt"
+	redisFieldLastActivityAt   = "lastActivityAt"
+)
+
+// redisTxMaxRetries WATCH 事务冲突时的最大重试次数
+const redisTxMaxRetries = 10
+
+// redisSetStateScript 仅在会话仍存在时更新状态，避免给已过期的会话重新写入不带 TTL 的哈希
+var redisSetStateScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('HSET', KEYS[1], 'state', ARGV[1])
+end
+return 0
+`)
+
+// RedisStorage Redis 协议存储实现
+// 多个 Agent 副本共享同一 Redis 时可以在任意副本上继续同一会话。
+//
+// 键布局（均带 Prefix）：
+//   - {key}            会话哈希
+//   - {key}:messages   消息列表，每个元素为 JSON
+//   - idx:all          按创建时间排序的会话集合
+//   - idx:agent:{id} / idx:channel:{c} / idx:scope:{s}  List 使用的二级索引
+//
+// 会话哈希和消息列表按 TTL 自最近一次活动起滑动过期，索引中的过期成员在 List 时惰性清理。
+// Redis Cluster 下需要在 Prefix 中使用 hash tag（如 "{session}:"），保证事务内的键位于同一 slot。
+type RedisStorage struct {
+	client     redis.UniversalClient
+	prefix     string
+	ownsClient bool
+	closed     atomic.Bool
+
+	ttl            atomic.Int64
+	idleTimeout    atomic.Int64
+	archiveTimeout atomic.Int64
+
+	// now 当前时间，测试中可替换
+	now func() time.Time
+}
+
+// NewRedisStorage 根据存储配置连接 Redis 并创建会话存储
+func NewRedisStorage(config *StorageConfig) (*RedisStorage, error) {
+	if config == nil || config.RedisAddr == "" {
+		return nil, fmt.Errorf("redis address is required")
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:         config.RedisAddr,
+		DB:           config.RedisDB,
+		Password:     config.RedisPassword,
+		DialTimeout:  constants.DefaultRedisDialTimeout,
+		ReadTimeout:  constants.DefaultRedisReadTimeout,
+		WriteTimeout: constants.DefaultRedisWriteTimeout,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), constants.DefaultRedisDialTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("connect redis %s: %w", config.RedisAddr, err)
+	}
+	s := NewRedisStorageWithClient(client, config.Prefix)
+	s.ownsClient = true
+	return s, nil
+}
+
+// NewRedisStorageWithClient 使用已有的 Redis 客户端创建会话存储
+// 客户端由调用方管理，Close 不会关闭它
+func NewRedisStorageWithClient(client redis.UniversalClient, prefix string) *RedisStorage {
+	if prefix == "" {
+		prefix = DefaultStorageConfig().Prefix
+	}
+	return &RedisStorage{
+		client: client,
+		prefix: prefix,
+		now:    time.Now,
+	}
+}
+
+// SetExpiration 设置会话过期、空闲和归档时间，<= 0 表示不启用
+func (s *RedisStorage) SetExpiration(ttl, idleTimeout, archiveTimeout time.Duration) {
+	s.ttl.Store(int64(ttl))
+	s.idleTimeout.Store(int64(idleTimeout))
+	s.archiveTimeout.Store(int64(archiveTimeout))
+}
+
+// Close 关闭存储，仅关闭由存储自身创建的客户端
+func (s *RedisStorage) Close() error {
+	if !s.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	if s.ownsClient {
+		return s.client.Close()
+	}
+	return nil
+}
+
+// Create 创建会话
+func (s *RedisStorage) Create(ctx context.Context, session *Session) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+	fields, err := sessionToRedisFields(session)
+	if err != nil {
+		return err
+	}
+	messages, err := encodeRedisMessages(session.Messages)
+	if err != nil {
+		return err
+	}
+	hashKey := s.sessionKey(session.Key)
+	return s.watch(ctx, func(tx *redis.Tx) error {
+		exists, err := tx.Exists(ctx, hashKey).Result()
+		if err != nil {
+			return err
+		}
+		if exists > 0 {
+			return ErrSessionAlreadyExists
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, hashKey, fields)
+			if len(messages) > 0 {
+				pipe.RPush(ctx, s.messagesKey(session.Key), messages...)
+			}
+			s.addToIndexes(ctx, pipe, session)
+			s.expire(ctx, pipe, session.Key)
+			return nil
+		})
+		return err
+	}, hashKey)
+}
+
+// Get 获取会话，按空闲时间惰性更新会话状态
+func (s *RedisStorage) Get(ctx context.Context, key string) (*Session, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+	pipe := s.client.Pipeline()
+	hashCmd := pipe.HGetAll(ctx, s.sessionKey(key))
+	msgCmd := pipe.LRange(ctx, s.messagesKey(key), 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	if len(hashCmd.Val()) == 0 {
+		return nil, ErrSessionNotFound
+	}
+	session, err := sessionFromRedisFields(key, hashCmd.Val())
+	if err != nil {
+		return nil, err
+	}
+	if session.Messages, err = decodeRedisMessages(msgCmd.Val()); err != nil {
+		return nil, err
+	}
+	if err := s.refreshState(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Update 更新会话，消息列表整体替换为 session.Messages
+func (s *RedisStorage) Update(ctx context.Context, session *Session) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+	fields, err := sessionToRedisFields(session)
+	if err != nil {
+		return err
+	}
+	messages, err := encodeRedisMessages(session.Messages)
+	if err != nil {
+		return err
+	}
+	hashKey := s.sessionKey(session.Key)
+	msgKey := s.messagesKey(session.Key)
+	return s.watch(ctx, func(tx *redis.Tx) error {
+		old, err := tx.HMGet(ctx, hashKey, redisFieldAgentID, redisFieldChannel, redisFieldScope).Result()
+		if err != nil {
+			return err
+		}
+		if old[0] == nil {
+			return ErrSessionNotFound
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			s.removeFromIndexes(ctx, pipe, session.Key, redisString(old[0]), redisString(old[1]), SessionScope(redisString(old[2])))
+			pipe.HSet(ctx, hashKey, fields)
+			pipe.Del(ctx, msgKey)
+			if len(messages) > 0 {
+				pipe.RPush(ctx, msgKey, messages...)
+			}
+			s.addToIndexes(ctx, pipe, session)
+			s.expire(ctx, pipe, session.Key)
+			return nil
+		})
+		return err
+	}, hashKey)
+}
+
+// Delete 删除会话、消息及索引
+func (s *RedisStorage) Delete(ctx context.Context, key string) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+	hashKey := s.sessionKey(key)
+	return s.watch(ctx, func(tx *redis.Tx) error {
+		old, err := tx.HMGet(ctx, hashKey, redisFieldAgentID, redisFieldChannel, redisFieldScope).Result()
+		if err != nil {
+			return err
+		}
+		if old[0] == nil {
+			return ErrSessionNotFound
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, hashKey, s.messagesKey(key))
+			s.removeFromIndexes(ctx, pipe, key, redisString(old[0]), redisString(old[1]), SessionScope(redisString(old[2])))
+			return nil
+		})
+		return err
+	}, hashKey)
+}
+
+// AddMessage 添加消息到会话，更新统计和活动时间，并将空闲或归档的会话重新激活
+func (s *RedisStorage) AddMessage(ctx context.Context, sessionKey string, msg *SessionMessage) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	hashKey := s.sessionKey(sessionKey)
+	return s.watch(ctx, func(tx *redis.Tx) error {
+		exists, err := tx.Exists(ctx, hashKey).Result()
+		if err != nil {
+			return err
+		}
+		if exists == 0 {
+			return ErrSessionNotFound
+		}
+		now := strconv.FormatInt(toUnixNano(s.now()), 10)
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.RPush(ctx, s.messagesKey(sessionKey), data)
+			pipe.HIncrBy(ctx, hashKey, redisFieldMessageCount, 1)
+			pipe.HIncrBy(ctx, hashKey, redisFieldTotalTokenCount, int64(msg.TokenCount))
+			pipe.HSet(ctx, hashKey,
+				redisFieldUpdatedAt, now,
+				redisFieldLastActivityAt, now,
+				redisFieldState, string(StateActive))
+			s.expire(ctx, pipe, sessionKey)
+			return nil
+		})
+		return err
+	}, hashKey)
+}
+
+// GetHistory 获取会话最近的 limit 条消息，limit <= 0 返回全部
+func (s *RedisStorage) GetHistory(ctx context.Context, sessionKey string, limit int) ([]*SessionMessage, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+	start := int64(0)
+	if limit > 0 {
+		start = -int64(limit)
+	}
+	pipe := s.client.Pipeline()
+	existsCmd := pipe.Exists(ctx, s.sessionKey(sessionKey))
+	msgCmd := pipe.LRange(ctx, s.messagesKey(sessionKey), start, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	if existsCmd.Val() == 0 {
+		return nil, ErrSessionNotFound
+	}
+	return decodeRedisMessages(msgCmd.Val())
+}
+
+// List 列出会话，按创建时间升序
+// AgentID/Channel/Scope 通过二级索引求交集，ScopeID/State 在读取后过滤
+func (s *RedisStorage) List(ctx context.Context, query *SessionQuery) ([]*Session, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+	if query == nil {
+		query = &SessionQuery{}
+	}
+
+	var indexKeys []string
+	if query.AgentID != "" {
+		indexKeys = append(indexKeys, s.indexKey("agent", query.AgentID))
+	}
+	if query.Channel != "" {
+		indexKeys = append(indexKeys, s.indexKey("channel", query.Channel))
+	}
+	if query.Scope != "" {
+		indexKeys = append(indexKeys, s.indexKey("scope", string(query.Scope)))
+	}
+
+	var (
+		keys []string
+		err  error
+	)
+	if len(indexKeys) > 0 {
+		keys, err = s.client.SInter(ctx, indexKeys...).Result()
+	} else {
+		keys, err = s.client.ZRange(ctx, s.allIndexKey(), 0, -1).Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return []*Session{}, nil
+	}
+
+	pipe := s.client.Pipeline()
+	hashCmds := make([]*redis.MapStringStringCmd, len(keys))
+	for i, key := range keys {
+		hashCmds[i] = pipe.HGetAll(ctx, s.sessionKey(key))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	var (
+		sessions []*Session
+		stale    []string
+	)
+	for i, key := range keys {
+		if len(hashCmds[i].Val()) == 0 {
+			stale = append(stale, key)
+			continue
+		}
+		session, err := sessionFromRedisFields(key, hashCmds[i].Val())
+		if err != nil {
+			return nil, err
+		}
+		if err := s.refreshState(ctx, session); err != nil {
+			return nil, err
+		}
+		if query.ScopeID != "" && session.ScopeID != query.ScopeID {
+			continue
+		}
+		if query.State != "" && session.State != query.State {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	if len(stale) > 0 {
+		s.pruneIndexes(ctx, stale, indexKeys)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].CreatedAt.Equal(sessions[j].CreatedAt) {
+			return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+		}
+		return sessions[i].Key < sessions[j].Key
+	})
+	if query.Offset > 0 {
+		if query.Offset >= len(sessions) {
+			return []*Session{}, nil
+		}
+		sessions = sessions[query.Offset:]
+	}
+	if query.Limit > 0 && len(sessions) > query.Limit {
+		sessions = sessions[:query.Limit]
+	}
+	if len(sessions) == 0 {
+		return []*Session{}, nil
+	}
+	if query.SkipMessages {
+		return sessions, nil
+	}
+
+	pipe = s.client.Pipeline()
+	msgCmds := make([]*redis.StringSliceCmd, len(sessions))
+	for i, session := range sessions {
+		msgCmds[i] = pipe.LRange(ctx, s.messagesKey(session.Key), 0, -1)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	for i, session := range sessions {
+		if session.Messages, err = decodeRedisMessages(msgCmds[i].Val()); err != nil {
+			return nil, err
+		}
+	}
+	return sessions, nil
+}
+
+// UpdateState 只更新会话状态
+func (s *RedisStorage) UpdateState(ctx context.Context, key string, state SessionState) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+	n, err := redisSetStateScript.Run(ctx, s.client, []string{s.sessionKey(key)}, string(state)).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// UpdateMetadata 只更新会话元数据，消息数和 token 数仍以独立计数器为准
+func (s *RedisStorage) UpdateMetadata(ctx context.Context, key string, update func(*SessionMetadata) error) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+	hashKey := s.sessionKey(key)
+	return s.watch(ctx, func(tx *redis.Tx) error {
+		raw, err := tx.HGet(ctx, hashKey, redisFieldMetadata).Result()
+		if errors.Is(err, redis.Nil) {
+			return ErrSessionNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var metadata SessionMetadata
+		if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+			return fmt.Errorf("decode session metadata: %w", err)
+		}
+		if err := update(&metadata); err != nil {
+			return err
+		}
+		updated, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, hashKey, redisFieldMetadata, string(updated))
+			return nil
+		})
+		return err
+	}, hashKey)
+}
+
+// refreshState 按空闲时间计算会话状态，发生变化时写回 Redis
+func (s *RedisStorage) refreshState(ctx context.Context, session *Session) error {
+	state := session.ResolveState(time.Duration(s.idleTimeout.Load()), time.Duration(s.archiveTimeout.Load()), s.now())
+	if state == session.State {
+		return nil
+	}
+	session.State = state
+	return redisSetStateScript.Run(ctx, s.client, []string{s.sessionKey(session.Key)}, string(state)).Err()
+}
+
+// watch 在 WATCH 事务中执行 fn，乐观锁冲突时重试
+func (s *RedisStorage) watch(ctx context.Context, fn func(tx *redis.Tx) error, keys ...string) error {
+	for i := 0; i < redisTxMaxRetries; i++ {
+		err := s.client.Watch(ctx, fn, keys...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("redis transaction on %v: too many conflicts", keys)
+}
+
+// expire 按 TTL 刷新会话哈希和消息列表的过期时间
+func (s *RedisStorage) expire(ctx context.Context, pipe redis.Pipeliner, key string) {
+	ttl := time.Duration(s.ttl.Load())
+	if ttl <= 0 {
+		return
+	}
+	pipe.PExpire(ctx, s.sessionKey(key), ttl)
+	pipe.PExpire(ctx, s.messagesKey(key), ttl)
+}
+
+// addToIndexes 将会话写入二级索引
+func (s *RedisStorage) addToIndexes(ctx context.Context, pipe redis.Pipeliner, session *Session) {
+	pipe.ZAdd(ctx, s.allIndexKey(), redis.Z{Score: float64(toUnixNano(session.CreatedAt)), Member: session.Key})
+	pipe.SAdd(ctx, s.indexKey("agent", session.AgentID), session.Key)
+	pipe.SAdd(ctx, s.indexKey("channel", session.Channel), session.Key)
+	pipe.SAdd(ctx, s.indexKey("scope", string(session.Scope)), session.Key)
+}
+
+// removeFromIndexes 将会话从二级索引移除
+func (s *RedisStorage) removeFromIndexes(ctx context.Context, pipe redis.Pipeliner, key, agentID, channel string, scope SessionScope) {
+	pipe.ZRem(ctx, s.allIndexKey(), key)
+	pipe.SRem(ctx, s.indexKey("agent", agentID), key)
+	pipe.SRem(ctx, s.indexKey("channel", channel), key)
+	pipe.SRem(ctx, s.indexKey("scope", string(scope)), key)
+}
+
+// pruneIndexes 清理已过期会话在索引中的残留成员
+// 过期会话的哈希已不存在，只能从本次查询涉及的索引中移除，其余索引在各自被查询时清理
+func (s *RedisStorage) pruneIndexes(ctx context.Context, keys []string, indexKeys []string) {
+	members := make([]any, len(keys))
+	for i, key := range keys {
+		members[i] = key
+	}
+	pipe := s.client.Pipeline()
+	pipe.ZRem(ctx, s.allIndexKey(), members...)
+	for _, indexKey := range indexKeys {
+		pipe.SRem(ctx, indexKey, members...)
+	}
+	// 清理失败不影响查询结果，下次 List 会再次尝试
+	_, _ = pipe.Exec(ctx)
+}
+
+func (s *RedisStorage) sessionKey(key string) string {
+	return s.prefix + key
+}
+
+func (s *RedisStorage) messagesKey(key string) string {
+	return s.prefix + key + ":messages"
+}
+
+func (s *RedisStorage) allIndexKey() string {
+	return s.prefix + "idx:all"
+}
+
+func (s *RedisStorage) indexKey(kind, value string) string {
+	return s.prefix + "idx:" + kind + ":" + value
+}
+
+// sessionToRedisFields 将会话转换为哈希字段，消息单独存储
+func sessionToRedisFields(session *Session) (map[string]any, error) {
+	metadata, err := json.Marshal(session.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		redisFieldAgentID:          session.AgentID,
+		redisFieldChannel:          session.Channel,
+		redisFieldScope:            string(session.Scope),
+		redisFieldScopeID:          session.ScopeID,
+		redisFieldCompactedSummary: session.CompactedSummary,
+		redisFieldMetadata:         string(metadata),
+		redisFieldMessageCount:     session.Metadata.MessageCount,
+		redisFieldTotalTokenCount:  session.Metadata.TotalTokenCount,
+		redisFieldState:            string(session.State),
+		redisFieldCreatedAt:        toUnixNano(session.CreatedAt),
+		redisFieldUpdatedAt:        toUnixNano(session.UpdatedAt),
+		redisFieldLastActivityAt:   toUnixNano(session.LastActivityAt),
+	}, nil
+}
+
+// sessionFromRedisFields 从哈希字段还原会话，统计字段以独立计数器为准
+func sessionFromRedisFields(key string, fields map[string]string) (*Session, error) {
+	session := &Session{
+		Key:              key,
+		AgentID:          fields[redisFieldAgentID],
+		Channel:          fields[redisFieldChannel],
+		Scope:            SessionScope(fields[redisFieldScope]),
+		ScopeID:          fields[redisFieldScopeID],
+		CompactedSummary: fields[redisFieldCompactedSummary],
+		State:            SessionState(fields[redisFieldState]),
+	}
+	if raw := fields[redisFieldMetadata]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &session.Metadata); err != nil {
+			return nil, fmt.Errorf("decode session metadata: %w", err)
+		}
+	}
+	session.Metadata.MessageCount = parseRedisInt(fields[redisFieldMessageCount])
+	session.Metadata.TotalTokenCount = parseRedisInt(fields[redisFieldTotalTokenCount])
+	session.CreatedAt = fromUnixNano(int64(parseRedisInt(fields[redisFieldCreatedAt])))
+	session.UpdatedAt = fromUnixNano(int64(parseRedisInt(fields[redisFieldUpdatedAt])))
+	session.LastActivityAt = fromUnixNano(int64(parseRedisInt(fields[redisFieldLastActivityAt])))
+	return session, nil
+}
+
+func encodeRedisMessages(msgs []*SessionMessage) ([]any, error) {
+	result := make([]any, 0, len(msgs))
+	for _, msg := range msgs {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, data)
+	}
+	return result, nil
+}
+
+func decodeRedisMessages(values []string) ([]*SessionMessage, error) {
+	messages := make([]*SessionMessage, 0, len(values))
+	for _, value := range values {
+		var msg SessionMessage
+		if err := json.Unmarshal([]byte(value), &msg); err != nil {
+			return nil, fmt.Errorf("decode session message: %w", err)
+		}
+		messages = append(messages, &msg)
+	}
+	return messages, nil
+}
+
+func parseRedisInt(value string) int {
+	n, _ := strconv.ParseInt(value, 10, 64)
+	return int(n)
+}
+
+func redisString(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return ""
+}
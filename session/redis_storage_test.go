@@ -0,0 +1,155 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedisStorage(t *testing.T) (*RedisStorage, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	storage, err := NewRedisStorage(&StorageConfig{Type: StorageTypeRedis, RedisAddr: mr.Addr(), Prefix: "test:"})
+	if err != nil {
+		t.Fatalf("NewRedisStorage() error = %v", err)
+	}
+	t.Cleanup(func() { _ = storage.Close() })
+	return storage, mr
+}
+
+func TestRedisStorage(t *testing.T) {
+	runStorageSuite(t, func(t *testing.T) SessionStorage {
+		storage, _ := newTestRedisStorage(t)
+		return storage
+	})
+}
+
+func TestRedisStorage_TTLExpiry(t *testing.T) {
+	ctx := context.Background()
+	storage, mr := newTestRedisStorage(t)
+	storage.SetExpiration(time.Hour, 0, 0)
+
+	sess := newSuiteSession("agent", "api", ScopePerPeer, "peer")
+	if err := storage.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	// 活动会刷新过期时间
+	mr.FastForward(40 * time.Minute)
+	if err := storage.AddMessage(ctx, sess.Key, NewSessionMessageWithTokens("user", "hi", 1)); err != nil {
+		t.Fatalf("AddMessage() error = %v", err)
+	}
+	mr.FastForward(40 * time.Minute)
+	if _, err := storage.Get(ctx, sess.Key); err != nil {
+		t.Fatalf("session should still exist after activity, got %v", err)
+	}
+	if ttl := mr.TTL("test:" + sess.Key + ":messages"); ttl <= 0 {
+		t.Errorf("message list should expire with the session, ttl=%v", ttl)
+	}
+
+	mr.FastForward(time.Hour)
+	if _, err := storage.Get(ctx, sess.Key); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound after TTL, got %v", err)
+	}
+
+	// 过期会话从索引中惰性清理
+	list, err := storage.List(ctx, &SessionQuery{AgentID: "agent"})
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("expected no sessions, got %d", len(list))
+	}
+	if members, _ := mr.ZMembers("test:idx:all"); len(members) != 0 {
+		t.Errorf("expired session should be pruned from index, got %v", members)
+	}
+}
+
+func TestRedisStorage_IdleAndArchive(t *testing.T) {
+	ctx := context.Background()
+	storage, _ := newTestRedisStorage(t)
+	storage.SetExpiration(0, time.Hour, 24*time.Hour)
+	now := time.Now()
+	storage.now = func() time.Time { return now }
+
+	sess := newSuiteSession("agent", "api", ScopePerPeer, "peer")
+	sess.LastActivityAt = now
+	if err := storage.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	got, err := storage.Get(ctx, sess.Key)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.State != StateIdle {
+		t.Errorf("expected idle state, got %s", got.State)
+	}
+	idle, err := storage.List(ctx, &SessionQuery{State: StateIdle})
+	if err != nil || len(idle) != 1 {
+		t.Fatalf("expected 1 idle session, got %d err=%v", len(idle), err)
+	}
+
+	now = now.Add(24 * time.Hour)
+	archived, err := storage.List(ctx, &SessionQuery{AgentID: "agent", State: StateArchived})
+	if err != nil || len(archived) != 1 {
+		t.Fatalf("expected 1 archived session, got %d err=%v", len(archived), err)
+	}
+
+	// 新消息重新激活会话
+	if err := storage.AddMessage(ctx, sess.Key, NewSessionMessage("user", "back")); err != nil {
+		t.Fatalf("AddMessage() error = %v", err)
+	}
+	got, _ = storage.Get(ctx, sess.Key)
+	if got.State != StateActive {
+		t.Errorf("expected active state after new message, got %s", got.State)
+	}
+}
+
+func TestRedisStorage_ManagerAppliesExpiration(t *testing.T) {
+	storage, _ := newTestRedisStorage(t)
+	cfg := DefaultSessionConfig()
+	cfg.TTL = 2 * time.Hour
+	NewManager(storage, cfg)
+	if got := time.Duration(storage.ttl.Load()); got != 2*time.Hour {
+		t.Errorf("expected manager to apply TTL, got %v", got)
+	}
+	if got := time.Duration(storage.archiveTimeout.Load()); got != DefaultSessionArchiveTimeout {
+		t.Errorf("expected default archive timeout, got %v", got)
+	}
+}
+
+func TestRedisStorage_ClientOwnership(t *testing.T) {
+	storage, _ := newTestRedisStorage(t)
+	shared := NewRedisStorageWithClient(storage.client, "")
+	if shared.prefix != "session:" {
+		t.Errorf("expected default prefix, got %q", shared.prefix)
+	}
+	_ = shared.Close()
+	if err := storage.client.Ping(context.Background()).Err(); err != nil {
+		t.Errorf("closing a storage must not close a shared client: %v", err)
+	}
+	if _, err := shared.Get(context.Background(), "x"); !errors.Is(err, ErrStorageClosed) {
+		t.Errorf("expected ErrStorageClosed, got %v", err)
+	}
+}
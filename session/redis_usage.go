@@ -0,0 +1,140 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 账本哈希字段后缀，字段名为 <聚合键 JSON>|<指标>
+const (
+	usageFieldCalls      = "calls"
+	usageFieldPrompt     = "prompt"
+	usageFieldCompletion = "completion"
+	usageFieldCached     = "cached"
+	usageFieldCost       = "cost"
+)
+
+// redisUsageLedger 与会话共用 Redis 的用量账本
+// 每天一个哈希保存当天所有汇总记录，按日期排序的 ZSET 记录有数据的日期
+type redisUsageLedger struct {
+	s *RedisStorage
+}
+
+// UsageLedger 返回持久化在同一 Redis 中的用量账本
+func (s *RedisStorage) UsageLedger() UsageLedger {
+	return &redisUsageLedger{s: s}
+}
+
+func (l *redisUsageLedger) daysKey() string {
+	return l.s.prefix + "usage:days"
+}
+
+func (l *redisUsageLedger) dayKey(day string) string {
+	return l.s.prefix + "usage:day:" + day
+}
+
+// Record 记录一次调用的用量
+func (l *redisUsageLedger) Record(ctx context.Context, entry *UsageEntry) error {
+	if l.s.closed.Load() {
+		return ErrStorageClosed
+	}
+	r := newUsageRecord(entry)
+	member, err := json.Marshal([]string{r.AgentID, r.UserID, r.SessionKey, r.Model})
+	if err != nil {
+		return err
+	}
+	prefix := string(member) + "|"
+	dayKey := l.dayKey(r.Day)
+	_, err = l.s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(ctx, dayKey, prefix+usageFieldCalls, r.Calls)
+		pipe.HIncrBy(ctx, dayKey, prefix+usageFieldPrompt, r.PromptTokens)
+		pipe.HIncrBy(ctx, dayKey, prefix+usageFieldCompletion, r.CompletionTokens)
+		pipe.HIncrBy(ctx, dayKey, prefix+usageFieldCached, r.CachedTokens)
+		pipe.HIncrByFloat(ctx, dayKey, prefix+usageFieldCost, r.Cost)
+		pipe.ZAdd(ctx, l.daysKey(), redis.Z{Member: r.Day})
+		return nil
+	})
+	return err
+}
+
+// Query 查询汇总记录
+func (l *redisUsageLedger) Query(ctx context.Context, query *UsageQuery) ([]*UsageRecord, error) {
+	if l.s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+	rng := &redis.ZRangeBy{Min: "-", Max: "+"}
+	if query != nil && query.FromDay != "" {
+		rng.Min = "[" + query.FromDay
+	}
+	if query != nil && query.ToDay != "" {
+		rng.Max = "[" + query.ToDay
+	}
+	days, err := l.s.client.ZRangeByLex(ctx, l.daysKey(), rng).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*UsageRecord
+	for _, day := range days {
+		fields, err := l.s.client.HGetAll(ctx, l.dayKey(day)).Result()
+		if err != nil {
+			return nil, err
+		}
+		records := make(map[string]*UsageRecord)
+		for field, value := range fields {
+			idx := strings.LastIndexByte(field, '|')
+			if idx < 0 {
+				continue
+			}
+			member, metric := field[:idx], field[idx+1:]
+			r, ok := records[member]
+			if !ok {
+				var parts []string
+				if err := json.Unmarshal([]byte(member), &parts); err != nil || len(parts) != 4 {
+					continue
+				}
+				r = &UsageRecord{Day: day, AgentID: parts[0], UserID: parts[1], SessionKey: parts[2], Model: parts[3]}
+				records[member] = r
+			}
+			switch metric {
+			case usageFieldCalls:
+				r.Calls, _ = strconv.ParseInt(value, 10, 64)
+			case usageFieldPrompt:
+				r.PromptTokens, _ = strconv.ParseInt(value, 10, 64)
+			case usageFieldCompletion:
+				r.CompletionTokens, _ = strconv.ParseInt(value, 10, 64)
+			case usageFieldCached:
+				r.CachedTokens, _ = strconv.ParseInt(value, 10, 64)
+			case usageFieldCost:
+				r.Cost, _ = strconv.ParseFloat(value, 64)
+			}
+		}
+		for _, r := range records {
+			if query.match(r) {
+				result = append(result, r)
+			}
+		}
+	}
+	sortUsageRecords(result)
+	return result, nil
+}
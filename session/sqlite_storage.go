@@ -0,0 +1,549 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema 会话表和消息表结构
+// 时间统一存储为 UnixNano，切片和结构体字段存储为 JSON
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	key               TEXT PRIMARY KEY,
+	agent_id          TEXT NOT NULL DEFAULT '',
+	channel           TEXT NOT NULL DEFAULT '',
+	scope             TEXT NOT NULL DEFAULT '',
+	scope_id          TEXT NOT NULL DEFAULT '',
+	compacted_summary TEXT NOT NULL DEFAULT '',
+	metadata          TEXT NOT NULL DEFAULT '{}',
+	state             TEXT NOT NULL DEFAULT '',
+	created_at        INTEGER NOT NULL DEFAULT 0,
+	updated_at        INTEGER NOT NULL DEFAULT 0,
+	last_activity_at  INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_agent ON sessions(agent_id, channel, scope, scope_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_state ON sessions(state);
+
+CREATE TABLE IF NOT EXISTS session_messages (
+	seq          INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_key  TEXT NOT NULL,
+	id           TEXT NOT NULL DEFAULT '',
+	role         TEXT NOT NULL DEFAULT '',
+	content      TEXT NOT NULL DEFAULT '',
+	images       TEXT NOT NULL DEFAULT '',
+	token_count  INTEGER NOT NULL DEFAULT 0,
+	is_compacted INTEGER NOT NULL DEFAULT 0,
+	created_at   INTEGER NOT NULL DEFAULT 0,
+	tool_calls   TEXT NOT NULL DEFAULT '',
+	tool_call_id TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_session_messages_key ON session_messages(session_key, seq);
+
+CREATE TABLE IF NOT EXISTS usage_ledger (
+	day               TEXT NOT NULL,
+	agent_id          TEXT NOT NULL DEFAULT '',
+	user_id           TEXT NOT NULL DEFAULT '',
+	session_key       TEXT NOT NULL DEFAULT '',
+	model             TEXT NOT NULL DEFAULT '',
+	calls             INTEGER NOT NULL DEFAULT 0,
+	prompt_tokens     INTEGER NOT NULL DEFAULT 0,
+	completion_tokens INTEGER NOT NULL DEFAULT 0,
+	cached_tokens     INTEGER NOT NULL DEFAULT 0,
+	cost              REAL NOT NULL DEFAULT 0,
+	PRIMARY KEY (day, agent_id, user_id, session_key, model)
+);
+CREATE INDEX IF NOT EXISTS idx_usage_ledger_user ON usage_ledger(user_id, day);
+`
+
+const sessionColumns = `key, agent_id, channel, scope, scope_id, compacted_summary, metadata, state, created_at, updated_at, last_activity_at`
+
+const messageColumns = `id, role, content, images, token_count, is_compacted, created_at, tool_calls, tool_call_id`
+
+// SQLiteStorage SQLite 存储实现
+// 会话和消息持久化到单个数据库文件，进程重启后会话不丢失
+type SQLiteStorage struct {
+	db     *sql.DB
+	closed atomic.Bool
+}
+
+// NewSQLiteStorage 打开（不存在则创建）SQLite 数据库文件并初始化表结构
+// path 为 ":memory:" 时使用内存数据库，主要用于测试
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("sqlite path is required")
+	}
+	dsn := path
+	if path != ":memory:" && !strings.HasPrefix(path, "file:") {
+		dsn = "file:" + path
+	}
+	if strings.Contains(dsn, "?") {
+		dsn += "&_pragma=busy_timeout(5000)"
+	} else {
+		dsn += "?_pragma=busy_timeout(5000)"
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite %s: %w", path, err)
+	}
+	// SQLite 单写者，串行化连接避免 database is locked；":memory:" 也要求共享同一连接
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("init sqlite schema: %w", err)
+	}
+	return &SQLiteStorage{db: db}, nil
+}
+
+// Close 关闭数据库连接
+func (s *SQLiteStorage) Close() error {
+	if !s.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Create 创建会话
+func (s *SQLiteStorage) Create(ctx context.Context, session *Session) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+	metadata, err := json.Marshal(session.Metadata)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO sessions (`+sessionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(key) DO NOTHING`,
+		session.Key, session.AgentID, session.Channel, string(session.Scope), session.ScopeID,
+		session.CompactedSummary, string(metadata), string(session.State),
+		toUnixNano(session.CreatedAt), toUnixNano(session.UpdatedAt), toUnixNano(session.LastActivityAt))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSessionAlreadyExists
+	}
+	return nil
+}
+
+// Get 获取会话
+func (s *SQLiteStorage) Get(ctx context.Context, key string) (*Session, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+	row := s.db.QueryRowContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE key = ?`, key)
+	session, err := scanSession(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	session.Messages, err = s.queryMessages(ctx, key, 0)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Update 更新会话，消息列表整体替换为 session.Messages
+func (s *SQLiteStorage) Update(ctx context.Context, session *Session) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+	metadata, err := json.Marshal(session.Metadata)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE sessions SET agent_id = ?, channel = ?, scope = ?, scope_id = ?, compacted_summary = ?,
+			metadata = ?, state = ?, created_at = ?, updated_at = ?, last_activity_at = ? WHERE key = ?`,
+		session.AgentID, session.Channel, string(session.Scope), session.ScopeID, session.CompactedSummary,
+		string(metadata), string(session.State),
+		toUnixNano(session.CreatedAt), toUnixNano(session.UpdatedAt), toUnixNano(session.LastActivityAt),
+		session.Key)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM session_messages WHERE session_key = ?`, session.Key); err != nil {
+		return err
+	}
+	for _, msg := range session.Messages {
+		if err := insertMessage(ctx, tx, session.Key, msg); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Delete 删除会话及其消息
+func (s *SQLiteStorage) Delete(ctx context.Context, key string) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE key = ?`, key)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM session_messages WHERE session_key = ?`, key); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddMessage 添加消息到会话，同时更新会话统计和活动时间
+func (s *SQLiteStorage) AddMessage(ctx context.Context, sessionKey string, msg *SessionMessage) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var metadataRaw string
+	err = tx.QueryRowContext(ctx, `SELECT metadata FROM sessions WHERE key = ?`, sessionKey).Scan(&metadataRaw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	var metadata SessionMetadata
+	if err := json.Unmarshal([]byte(metadataRaw), &metadata); err != nil {
+		return fmt.Errorf("decode session metadata: %w", err)
+	}
+	metadata.MessageCount++
+	metadata.TotalTokenCount += msg.TokenCount
+	updated, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	if err := insertMessage(ctx, tx, sessionKey, msg); err != nil {
+		return err
+	}
+	now := toUnixNano(time.Now())
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE sessions SET metadata = ?, state = ?, updated_at = ?, last_activity_at = ? WHERE key = ?`,
+		string(updated), string(StateActive), now, now, sessionKey); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateMetadata 只更新会话元数据
+func (s *SQLiteStorage) UpdateMetadata(ctx context.Context, key string, update func(*SessionMetadata) error) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var metadataRaw string
+	err = tx.QueryRowContext(ctx, `SELECT metadata FROM sessions WHERE key = ?`, key).Scan(&metadataRaw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	var metadata SessionMetadata
+	if err := json.Unmarshal([]byte(metadataRaw), &metadata); err != nil {
+		return fmt.Errorf("decode session metadata: %w", err)
+	}
+	if err := update(&metadata); err != nil {
+		return err
+	}
+	updated, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE sessions SET metadata = ? WHERE key = ?`, string(updated), key); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateState 只更新会话状态
+func (s *SQLiteStorage) UpdateState(ctx context.Context, key string, state SessionState) error {
+	if s.closed.Load() {
+		return ErrStorageClosed
+	}
+	res, err := s.db.ExecContext(ctx, `UPDATE sessions SET state = ? WHERE key = ?`, string(state), key)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// GetHistory 获取会话最近的 limit 条消息，limit <= 0 返回全部
+func (s *SQLiteStorage) GetHistory(ctx context.Context, sessionKey string, limit int) ([]*SessionMessage, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+	var exists int
+	err := s.db.QueryRowContext(ctx, `SELECT 1 FROM sessions WHERE key = ?`, sessionKey).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.queryMessages(ctx, sessionKey, limit)
+}
+
+// List 列出会话，按创建时间升序
+func (s *SQLiteStorage) List(ctx context.Context, query *SessionQuery) ([]*Session, error) {
+	if s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+	var (
+		conds []string
+		args  []any
+	)
+	if query != nil {
+		if query.AgentID != "" {
+			conds = append(conds, "agent_id = ?")
+			args = append(args, query.AgentID)
+		}
+		if query.Channel != "" {
+			conds = append(conds, "channel = ?")
+			args = append(args, query.Channel)
+		}
+		if query.Scope != "" {
+			conds = append(conds, "scope = ?")
+			args = append(args, string(query.Scope))
+		}
+		if query.ScopeID != "" {
+			conds = append(conds, "scope_id = ?")
+			args = append(args, query.ScopeID)
+		}
+		if query.State != "" {
+			conds = append(conds, "state = ?")
+			args = append(args, string(query.State))
+		}
+	}
+
+	stmt := `SELECT ` + sessionColumns + ` FROM sessions`
+	if len(conds) > 0 {
+		stmt += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	stmt += ` ORDER BY created_at, key`
+	if query != nil && (query.Limit > 0 || query.Offset > 0) {
+		limit := -1
+		if query.Limit > 0 {
+			limit = query.Limit
+		}
+		stmt += ` LIMIT ? OFFSET ?`
+		args = append(args, limit, max(query.Offset, 0))
+	}
+
+	rows, err := s.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	var result []*Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result = append(result, session)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if query != nil && query.SkipMessages {
+		if result == nil {
+			result = []*Session{}
+		}
+		return result, nil
+	}
+
+	// 连接池只有一个连接，必须在关闭会话游标后再查询消息
+	for _, session := range result {
+		if session.Messages, err = s.queryMessages(ctx, session.Key, 0); err != nil {
+			return nil, err
+		}
+	}
+	if result == nil {
+		result = []*Session{}
+	}
+	return result, nil
+}
+
+// queryMessages 按写入顺序查询会话消息，limit > 0 时只返回最近的 limit 条
+func (s *SQLiteStorage) queryMessages(ctx context.Context, sessionKey string, limit int) ([]*SessionMessage, error) {
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	if limit > 0 {
+		rows, err = s.db.QueryContext(ctx,
+			`SELECT `+messageColumns+` FROM (SELECT seq, `+messageColumns+` FROM session_messages
+				WHERE session_key = ? ORDER BY seq DESC LIMIT ?) ORDER BY seq`, sessionKey, limit)
+	} else {
+		rows, err = s.db.QueryContext(ctx,
+			`SELECT `+messageColumns+` FROM session_messages WHERE session_key = ? ORDER BY seq`, sessionKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]*SessionMessage, 0)
+	for rows.Next() {
+		var (
+			msg               SessionMessage
+			images, toolCalls string
+			isCompacted       int
+			createdAt         int64
+		)
+		if err := rows.Scan(&msg.ID, &msg.Role, &msg.Content, &images, &msg.TokenCount, &isCompacted,
+			&createdAt, &toolCalls, &msg.ToolCallID); err != nil {
+			return nil, err
+		}
+		msg.IsCompacted = isCompacted != 0
+		msg.CreatedAt = fromUnixNano(createdAt)
+		if images != "" {
+			if err := json.Unmarshal([]byte(images), &msg.Images); err != nil {
+				return nil, fmt.Errorf("decode message images: %w", err)
+			}
+		}
+		if toolCalls != "" {
+			if err := json.Unmarshal([]byte(toolCalls), &msg.ToolCalls); err != nil {
+				return nil, fmt.Errorf("decode message tool calls: %w", err)
+			}
+		}
+		messages = append(messages, &msg)
+	}
+	return messages, rows.Err()
+}
+
+// insertMessage 在事务中写入一条消息
+func insertMessage(ctx context.Context, tx *sql.Tx, sessionKey string, msg *SessionMessage) error {
+	var images, toolCalls string
+	if msg.Images != nil {
+		raw, err := json.Marshal(msg.Images)
+		if err != nil {
+			return err
+		}
+		images = string(raw)
+	}
+	if msg.ToolCalls != nil {
+		raw, err := json.Marshal(msg.ToolCalls)
+		if err != nil {
+			return err
+		}
+		toolCalls = string(raw)
+	}
+	isCompacted := 0
+	if msg.IsCompacted {
+		isCompacted = 1
+	}
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO session_messages (session_key, `+messageColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sessionKey, msg.ID, msg.Role, msg.Content, images, msg.TokenCount, isCompacted,
+		toUnixNano(msg.CreatedAt), toolCalls, msg.ToolCallID)
+	return err
+}
+
+// rowScanner 兼容 *sql.Row 和 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSession 扫描会话行（不含消息）
+func scanSession(row rowScanner) (*Session, error) {
+	var (
+		session                              Session
+		scope, state, metadata               string
+		createdAt, updatedAt, lastActivityAt int64
+	)
+	if err := row.Scan(&session.Key, &session.AgentID, &session.Channel, &scope, &session.ScopeID,
+		&session.CompactedSummary, &metadata, &state, &createdAt, &updatedAt, &lastActivityAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(metadata), &session.Metadata); err != nil {
+		return nil, fmt.Errorf("decode session metadata: %w", err)
+	}
+	session.Scope = SessionScope(scope)
+	session.State = SessionState(state)
+	session.CreatedAt = fromUnixNano(createdAt)
+	session.UpdatedAt = fromUnixNano(updatedAt)
+	session.LastActivityAt = fromUnixNano(lastActivityAt)
+	session.Messages = make([]*SessionMessage, 0)
+	return &session, nil
+}
+
+// toUnixNano 零值时间存为 0
+func toUnixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// fromUnixNano 0 还原为零值时间
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteStorage(t *testing.T) {
+	runStorageSuite(t, func(t *testing.T) SessionStorage {
+		storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "sessions.db"))
+		if err != nil {
+			t.Fatalf("NewSQLiteStorage() error = %v", err)
+		}
+		t.Cleanup(func() { _ = storage.Close() })
+		return storage
+	})
+}
+
+func TestSQLiteStorage_PersistsAcrossReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.db")
+
+	storage, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage() error = %v", err)
+	}
+	mgr := NewManager(storage, nil)
+	sess, err := mgr.GetOrCreate(ctx, SessionRequest{AgentID: "agent", Channel: "api", Scope: ScopePerPeer, ScopeID: "peer"})
+	if err != nil {
+		t.Fatalf("GetOrCreate() error = %v", err)
+	}
+	if err := mgr.AddMessage(ctx, sess.Key, NewSessionMessageWithTokens("user", "记住我", 5)); err != nil {
+		t.Fatalf("AddMessage() error = %v", err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := storage.Get(ctx, sess.Key); !errors.Is(err, ErrStorageClosed) {
+		t.Errorf("Get() after close error = %v, want ErrStorageClosed", err)
+	}
+
+	reopened, err := NewSQLiteStorage(path)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	defer reopened.Close()
+	got, err := NewManager(reopened, nil).Get(ctx, sess.Key)
+	if err != nil {
+		t.Fatalf("Get() after reopen error = %v", err)
+	}
+	if len(got.Messages) != 1 || got.Messages[0].Content != "记住我" || got.Metadata.TotalTokenCount != 5 {
+		t.Errorf("session not persisted: %+v", got.Messages)
+	}
+}
+
+func TestNewStorage(t *testing.T) {
+	storage, err := NewStorage(nil)
+	if err != nil {
+		t.Fatalf("NewStorage(nil) error = %v", err)
+	}
+	if _, ok := storage.(*MemoryStorage); !ok {
+		t.Errorf("expected memory storage by default, got %T", storage)
+	}
+
+	storage, err = NewStorage(&StorageConfig{Type: StorageTypeSQLite, SQLitePath: filepath.Join(t.TempDir(), "s.db")})
+	if err != nil {
+		t.Fatalf("NewStorage(sqlite) error = %v", err)
+	}
+	sqliteStorage, ok := storage.(*SQLiteStorage)
+	if !ok {
+		t.Fatalf("expected sqlite storage, got %T", storage)
+	}
+	_ = sqliteStorage.Close()
+
+	if _, err := NewStorage(&StorageConfig{Type: StorageTypeSQLite}); err == nil {
+		t.Error("expected error for empty sqlite path")
+	}
+	if _, err := NewStorage(&StorageConfig{Type: "unknown"}); err == nil {
+		t.Error("expected error for unknown storage type")
+	}
+}
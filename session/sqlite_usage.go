@@ -0,0 +1,103 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"strings"
+)
+
+const usageColumns = `day, agent_id, user_id, session_key, model, calls, prompt_tokens, completion_tokens, cached_tokens, cost`
+
+// sqliteUsageLedger 与会话共用数据库文件的用量账本
+type sqliteUsageLedger struct {
+	s *SQLiteStorage
+}
+
+// UsageLedger 返回持久化在同一数据库中的用量账本
+func (s *SQLiteStorage) UsageLedger() UsageLedger {
+	return &sqliteUsageLedger{s: s}
+}
+
+// Record 记录一次调用的用量
+func (l *sqliteUsageLedger) Record(ctx context.Context, entry *UsageEntry) error {
+	if l.s.closed.Load() {
+		return ErrStorageClosed
+	}
+	r := newUsageRecord(entry)
+	_, err := l.s.db.ExecContext(ctx,
+		`INSERT INTO usage_ledger (`+usageColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(day, agent_id, user_id, session_key, model) DO UPDATE SET
+			calls = calls + excluded.calls,
+			prompt_tokens = prompt_tokens + excluded.prompt_tokens,
+			completion_tokens = completion_tokens + excluded.completion_tokens,
+			cached_tokens = cached_tokens + excluded.cached_tokens,
+			cost = cost + excluded.cost`,
+		r.Day, r.AgentID, r.UserID, r.SessionKey, r.Model,
+		r.Calls, r.PromptTokens, r.CompletionTokens, r.CachedTokens, r.Cost)
+	return err
+}
+
+// Query 查询汇总记录
+func (l *sqliteUsageLedger) Query(ctx context.Context, query *UsageQuery) ([]*UsageRecord, error) {
+	if l.s.closed.Load() {
+		return nil, ErrStorageClosed
+	}
+	var (
+		conds []string
+		args  []any
+	)
+	if query != nil {
+		for _, c := range []struct {
+			expr  string
+			value string
+		}{
+			{"agent_id = ?", query.AgentID},
+			{"user_id = ?", query.UserID},
+			{"session_key = ?", query.SessionKey},
+			{"model = ?", query.Model},
+			{"day >= ?", query.FromDay},
+			{"day <= ?", query.ToDay},
+		} {
+			if c.value != "" {
+				conds = append(conds, c.expr)
+				args = append(args, c.value)
+			}
+		}
+	}
+	stmt := `SELECT ` + usageColumns + ` FROM usage_ledger`
+	if len(conds) > 0 {
+		stmt += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	stmt += ` ORDER BY day, agent_id, user_id, session_key, model`
+
+	rows, err := l.s.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []*UsageRecord
+	for rows.Next() {
+		r := &UsageRecord{}
+		if err := rows.Scan(&r.Day, &r.AgentID, &r.UserID, &r.SessionKey, &r.Model,
+			&r.Calls, &r.PromptTokens, &r.CompletionTokens, &r.CachedTokens, &r.Cost); err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
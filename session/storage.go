@@ -1,6 +1,10 @@
 package session
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"time"
+)
 
 // SessionStorage 会话存储接口
 type SessionStorage interface {
@@ -26,6 +30,24 @@ type SessionStorage interface {
 	List(ctx context.Context, query *SessionQuery) ([]*Session, error)
 }
 
+// ExpirableStorage 支持按会话配置自动过期的存储（可选接口实现）
+// NewManager 会用 SessionConfig 的 TTL、IdleTimeout 和 ArchiveTimeout 配置实现了该接口的存储
+type ExpirableStorage interface {
+	SetExpiration(ttl, idleTimeout, archiveTimeout time.Duration)
+}
+
+// StateStorage 支持单独更新会话状态的存储（可选接口实现）
+// 只修改状态字段，不会覆盖并发写入的消息
+type StateStorage interface {
+	UpdateState(ctx context.Context, key string, state SessionState) error
+}
+
+// MetadataStorage 支持单独更新会话元数据的存储（可选接口实现）
+// 在存储内原子地读取、修改并保存元数据，不会覆盖并发写入的消息；update 返回错误时不保存
+type MetadataStorage interface {
+	UpdateMetadata(ctx context.Context, key string, update func(*SessionMetadata) error) error
+}
+
 // SessionQuery 会话查询条件
 type SessionQuery struct {
 	AgentID string
@@ -35,8 +57,20 @@ type SessionQuery struct {
 	State   SessionState
 	Limit   int
 	Offset  int
+	// SkipMessages 为 true 时只返回会话元数据，不加载消息
+	SkipMessages bool
 }
 
+// 存储类型（StorageConfig.Type 取值）
+const (
+	// StorageTypeMemory 内存存储，进程重启后会话丢失
+	StorageTypeMemory = "memory"
+	// StorageTypeSQLite SQLite 文件存储
+	StorageTypeSQLite = "sqlite"
+	// StorageTypeRedis Redis 协议存储，多副本共享会话
+	StorageTypeRedis = "redis"
+)
+
 // StorageConfig 存储配置
 type StorageConfig struct {
 	Type string
@@ -54,7 +88,25 @@ type StorageConfig struct {
 // DefaultStorageConfig 默认存储配置
 func DefaultStorageConfig() *StorageConfig {
 	return &StorageConfig{
-		Type:   "memory",
+		Type:   StorageTypeMemory,
 		Prefix: "session:",
 	}
 }
+
+// NewStorage 根据存储配置创建会话存储
+// Type 为空时使用内存存储
+func NewStorage(config *StorageConfig) (SessionStorage, error) {
+	if config == nil {
+		config = DefaultStorageConfig()
+	}
+	switch config.Type {
+	case "", StorageTypeMemory:
+		return NewMemoryStorage(), nil
+	case StorageTypeSQLite:
+		return NewSQLiteStorage(config.SQLitePath)
+	case StorageTypeRedis:
+		return NewRedisStorage(config)
+	default:
+		return nil, fmt.Errorf("unsupported session storage type: %s", config.Type)
+	}
+}
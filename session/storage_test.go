@@ -0,0 +1,380 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// runStorageSuite 所有 SessionStorage 实现共用的行为测试
+func runStorageSuite(t *testing.T, newStorage func(t *testing.T) SessionStorage) {
+	t.Run("CreateAndGet", func(t *testing.T) {
+		testStorageCreateAndGet(t, newStorage(t))
+	})
+	t.Run("Update", func(t *testing.T) {
+		testStorageUpdate(t, newStorage(t))
+	})
+	t.Run("Delete", func(t *testing.T) {
+		testStorageDelete(t, newStorage(t))
+	})
+	t.Run("AddMessageAndHistory", func(t *testing.T) {
+		testStorageAddMessageAndHistory(t, newStorage(t))
+	})
+	t.Run("ToolCallsAndImages", func(t *testing.T) {
+		testStorageToolCallsAndImages(t, newStorage(t))
+	})
+	t.Run("List", func(t *testing.T) {
+		testStorageList(t, newStorage(t))
+	})
+	t.Run("UpdateMetadata", func(t *testing.T) {
+		testStorageUpdateMetadata(t, newStorage(t))
+	})
+}
+
+func newSuiteSession(agentID, channel string, scope SessionScope, scopeID string) *Session {
+	now := time.Now()
+	return &Session{
+		Key:            GenerateSessionKey(agentID, channel, scope, scopeID),
+		AgentID:        agentID,
+		Channel:        channel,
+		Scope:          scope,
+		ScopeID:        scopeID,
+		Metadata:       SessionMetadata{Title: "Session " + scopeID},
+		State:          StateActive,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		LastActivityAt: now,
+	}
+}
+
+func testStorageCreateAndGet(t *testing.T, storage SessionStorage) {
+	ctx := context.Background()
+	sess := newSuiteSession("agent", "api", ScopePerPeer, "peer-1")
+	sess.Metadata.Model = "gpt-4o"
+	sess.Metadata.ExtraFields = map[string]any{"reasoning_effort": "high"}
+
+	if err := storage.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := storage.Create(ctx, sess); !errors.Is(err, ErrSessionAlreadyExists) {
+		t.Fatalf("Create() duplicate error = %v, want ErrSessionAlreadyExists", err)
+	}
+
+	got, err := storage.Get(ctx, sess.Key)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.AgentID != "agent" || got.Channel != "api" || got.Scope != ScopePerPeer || got.ScopeID != "peer-1" {
+		t.Errorf("unexpected session identity: %+v", got)
+	}
+	if got.State != StateActive || got.Metadata.Title != "Session peer-1" || got.Metadata.Model != "gpt-4o" {
+		t.Errorf("unexpected session fields: state=%s metadata=%+v", got.State, got.Metadata)
+	}
+	if got.Metadata.ExtraFields["reasoning_effort"] != "high" {
+		t.Errorf("extra fields not preserved: %+v", got.Metadata.ExtraFields)
+	}
+	if !got.CreatedAt.Equal(sess.CreatedAt) || !got.LastActivityAt.Equal(sess.LastActivityAt) {
+		t.Errorf("timestamps not preserved: %v vs %v", got.CreatedAt, sess.CreatedAt)
+	}
+	if len(got.Messages) != 0 {
+		t.Errorf("expected no messages, got %d", len(got.Messages))
+	}
+
+	if _, err := storage.Get(ctx, "missing"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Get() missing error = %v, want ErrSessionNotFound", err)
+	}
+}
+
+func testStorageUpdate(t *testing.T, storage SessionStorage) {
+	ctx := context.Background()
+	sess := newSuiteSession("agent", "api", ScopePerPeer, "peer-1")
+	if err := storage.Update(ctx, sess); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("Update() missing error = %v, want ErrSessionNotFound", err)
+	}
+	if err := storage.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	_ = storage.AddMessage(ctx, sess.Key, NewSessionMessage("user", "old"))
+
+	sess.State = StateIdle
+	sess.CompactedSummary = "summary"
+	sess.Metadata.TotalTokenCount = 42
+	sess.Messages = []*SessionMessage{
+		{ID: "m1", Role: "user", Content: "hello", IsCompacted: true, CreatedAt: time.Now()},
+		{ID: "m2", Role: "assistant", Content: "hi", TokenCount: 3, CreatedAt: time.Now()},
+	}
+	if err := storage.Update(ctx, sess); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	got, err := storage.Get(ctx, sess.Key)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.State != StateIdle || got.CompactedSummary != "summary" || got.Metadata.TotalTokenCount != 42 {
+		t.Errorf("update not applied: state=%s summary=%q tokens=%d", got.State, got.CompactedSummary, got.Metadata.TotalTokenCount)
+	}
+	if len(got.Messages) != 2 || got.Messages[0].ID != "m1" || !got.Messages[0].IsCompacted || got.Messages[1].TokenCount != 3 {
+		t.Errorf("messages not replaced: %+v", got.Messages)
+	}
+
+	// 返回值与存储隔离
+	got.Messages[0].Content = "mutated"
+	again, _ := storage.Get(ctx, sess.Key)
+	if again.Messages[0].Content != "hello" {
+		t.Error("mutating returned message must not affect storage")
+	}
+}
+
+func testStorageUpdateMetadata(t *testing.T, storage SessionStorage) {
+	ctx := context.Background()
+	ms, ok := storage.(MetadataStorage)
+	if !ok {
+		t.Skip("storage does not implement MetadataStorage")
+	}
+	sess := newSuiteSession("agent", "api", ScopePerPeer, "peer-meta")
+	noop := func(*SessionMetadata) error { return nil }
+	if err := ms.UpdateMetadata(ctx, sess.Key, noop); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("UpdateMetadata() missing error = %v, want ErrSessionNotFound", err)
+	}
+	if err := storage.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	msg := NewSessionMessage("user", "hello")
+	msg.TokenCount = 5
+	_ = storage.AddMessage(ctx, sess.Key, msg)
+
+	err := ms.UpdateMetadata(ctx, sess.Key, func(metadata *SessionMetadata) error {
+		metadata.Approvals = append(metadata.Approvals, ToolApproval{ID: "apv_1", Status: ApprovalPending})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateMetadata() error = %v", err)
+	}
+	// 元数据更新之后写入的消息与统计不受影响
+	_ = storage.AddMessage(ctx, sess.Key, NewSessionMessage("assistant", "hi"))
+	failed := errors.New("rejected")
+	if err := ms.UpdateMetadata(ctx, sess.Key, func(metadata *SessionMetadata) error {
+		metadata.Approvals = nil
+		return failed
+	}); !errors.Is(err, failed) {
+		t.Fatalf("UpdateMetadata() error = %v, want %v", err, failed)
+	}
+
+	got, err := storage.Get(ctx, sess.Key)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if len(got.Metadata.Approvals) != 1 || got.Metadata.Approvals[0].ID != "apv_1" {
+		t.Errorf("approvals = %+v, want apv_1", got.Metadata.Approvals)
+	}
+	if got.Metadata.Title != "Session peer-meta" {
+		t.Errorf("title = %q, other metadata must be kept", got.Metadata.Title)
+	}
+	if len(got.Messages) != 2 || got.Metadata.MessageCount != 2 || got.Metadata.TotalTokenCount != 5 {
+		t.Errorf("messages not kept: len=%d count=%d tokens=%d", len(got.Messages), got.Metadata.MessageCount, got.Metadata.TotalTokenCount)
+	}
+}
+
+func testStorageDelete(t *testing.T, storage SessionStorage) {
+	ctx := context.Background()
+	sess := newSuiteSession("agent", "api", ScopePerPeer, "peer-1")
+	if err := storage.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	_ = storage.AddMessage(ctx, sess.Key, NewSessionMessage("user", "hello"))
+
+	if err := storage.Delete(ctx, sess.Key); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := storage.Delete(ctx, sess.Key); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Delete() twice error = %v, want ErrSessionNotFound", err)
+	}
+	if _, err := storage.GetHistory(ctx, sess.Key, 0); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("GetHistory() after delete error = %v, want ErrSessionNotFound", err)
+	}
+
+	// 同键重建后不残留旧消息
+	if err := storage.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() after delete error = %v", err)
+	}
+	history, _ := storage.GetHistory(ctx, sess.Key, 0)
+	if len(history) != 0 {
+		t.Errorf("expected empty history after recreate, got %d", len(history))
+	}
+}
+
+func testStorageAddMessageAndHistory(t *testing.T, storage SessionStorage) {
+	ctx := context.Background()
+	sess := newSuiteSession("agent", "api", ScopePerPeer, "peer-1")
+	if err := storage.AddMessage(ctx, sess.Key, NewSessionMessage("user", "x")); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("AddMessage() missing error = %v, want ErrSessionNotFound", err)
+	}
+	if err := storage.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := storage.AddMessage(ctx, sess.Key, NewSessionMessageWithTokens("user", fmt.Sprintf("msg-%d", i), 10)); err != nil {
+			t.Fatalf("AddMessage() error = %v", err)
+		}
+	}
+
+	all, err := storage.GetHistory(ctx, sess.Key, 0)
+	if err != nil {
+		t.Fatalf("GetHistory() error = %v", err)
+	}
+	if len(all) != 5 || all[0].Content != "msg-0" || all[4].Content != "msg-4" {
+		t.Fatalf("unexpected full history: %d messages", len(all))
+	}
+
+	recent, err := storage.GetHistory(ctx, sess.Key, 2)
+	if err != nil {
+		t.Fatalf("GetHistory() error = %v", err)
+	}
+	if len(recent) != 2 || recent[0].Content != "msg-3" || recent[1].Content != "msg-4" {
+		t.Fatalf("expected last 2 messages in order, got %+v", recent)
+	}
+
+	got, _ := storage.Get(ctx, sess.Key)
+	if got.Metadata.MessageCount != 5 || got.Metadata.TotalTokenCount != 50 {
+		t.Errorf("stats not updated: count=%d tokens=%d", got.Metadata.MessageCount, got.Metadata.TotalTokenCount)
+	}
+	if !got.LastActivityAt.After(sess.LastActivityAt) && !got.LastActivityAt.Equal(sess.LastActivityAt) {
+		t.Errorf("last activity not updated")
+	}
+}
+
+func testStorageToolCallsAndImages(t *testing.T, storage SessionStorage) {
+	ctx := context.Background()
+	sess := newSuiteSession("agent", "api", ScopePerPeer, "peer-1")
+	if err := storage.Create(ctx, sess); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	userMsg := NewSessionMessage("user", "看图")
+	userMsg.Images = []string{"https://example.com/a.png", "/tmp/b.jpg"}
+	callMsg := &SessionMessage{
+		ID:   GenerateMessageID(),
+		Role: "assistant",
+		ToolCalls: []ToolCallInfo{
+			{ID: "call-1", Name: "bash", Arguments: `{"command":"ls"}`},
+			{ID: "call-2", Name: "read", Arguments: `{"path":"a.txt"}`},
+		},
+		CreatedAt: time.Now(),
+	}
+	resultMsg := &SessionMessage{ID: GenerateMessageID(), Role: "tool", Content: "a.txt", ToolCallID: "call-1", CreatedAt: time.Now()}
+	for _, msg := range []*SessionMessage{userMsg, callMsg, resultMsg} {
+		if err := storage.AddMessage(ctx, sess.Key, msg); err != nil {
+			t.Fatalf("AddMessage() error = %v", err)
+		}
+	}
+
+	history, err := storage.GetHistory(ctx, sess.Key, 0)
+	if err != nil {
+		t.Fatalf("GetHistory() error = %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(history))
+	}
+	if len(history[0].Images) != 2 || history[0].Images[1] != "/tmp/b.jpg" {
+		t.Errorf("images not preserved: %v", history[0].Images)
+	}
+	if len(history[1].ToolCalls) != 2 || history[1].ToolCalls[1].Name != "read" || history[1].ToolCalls[0].Arguments != `{"command":"ls"}` {
+		t.Errorf("tool calls not preserved: %+v", history[1].ToolCalls)
+	}
+	if history[2].ToolCallID != "call-1" || history[2].Role != "tool" {
+		t.Errorf("tool result not preserved: %+v", history[2])
+	}
+	if history[0].ID != userMsg.ID || !history[0].CreatedAt.Equal(userMsg.CreatedAt) {
+		t.Errorf("message identity not preserved")
+	}
+}
+
+func testStorageList(t *testing.T, storage SessionStorage) {
+	ctx := context.Background()
+	sessions := []*Session{
+		newSuiteSession("agent-a", "api", ScopePerPeer, "p1"),
+		newSuiteSession("agent-a", "feishu", ScopePerPeer, "p2"),
+		newSuiteSession("agent-a", "api", ScopeThread, "t1"),
+		newSuiteSession("agent-b", "api", ScopePerPeer, "p1"),
+	}
+	sessions[2].State = StateArchived
+	for _, sess := range sessions {
+		if err := storage.Create(ctx, sess); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+	_ = storage.AddMessage(ctx, sessions[0].Key, NewSessionMessage("user", "hello"))
+
+	cases := []struct {
+		name  string
+		query *SessionQuery
+		want  int
+	}{
+		{"all", nil, 4},
+		{"agent", &SessionQuery{AgentID: "agent-a"}, 3},
+		{"channel", &SessionQuery{AgentID: "agent-a", Channel: "api"}, 2},
+		{"scope", &SessionQuery{Scope: ScopePerPeer}, 3},
+		{"scopeId", &SessionQuery{ScopeID: "p1"}, 2},
+		{"state", &SessionQuery{State: StateArchived}, 1},
+		{"limit", &SessionQuery{Limit: 3}, 3},
+		{"offset", &SessionQuery{Offset: 3}, 1},
+		{"limitOffset", &SessionQuery{AgentID: "agent-a", Limit: 2, Offset: 2}, 1},
+		{"offsetBeyond", &SessionQuery{Offset: 10}, 0},
+	}
+	for _, tc := range cases {
+		got, err := storage.List(ctx, tc.query)
+		if err != nil {
+			t.Fatalf("%s: List() error = %v", tc.name, err)
+		}
+		if len(got) != tc.want {
+			t.Errorf("%s: expected %d sessions, got %d", tc.name, tc.want, len(got))
+		}
+	}
+
+	got, _ := storage.List(ctx, &SessionQuery{AgentID: "agent-a", Channel: "api", Scope: ScopePerPeer})
+	if len(got) != 1 || len(got[0].Messages) != 1 || got[0].Messages[0].Content != "hello" {
+		t.Errorf("listed session should include messages: %+v", got)
+	}
+	got, _ = storage.List(ctx, &SessionQuery{AgentID: "agent-a", Channel: "api", Scope: ScopePerPeer, SkipMessages: true})
+	if len(got) != 1 || got[0].Key != sessions[0].Key || len(got[0].Messages) != 0 {
+		t.Errorf("SkipMessages should list metadata only: %+v", got)
+	}
+
+	// 分页结果不重复
+	seen := make(map[string]bool)
+	for offset := 0; offset < 4; offset += 2 {
+		page, _ := storage.List(ctx, &SessionQuery{Limit: 2, Offset: offset})
+		for _, sess := range page {
+			if seen[sess.Key] {
+				t.Errorf("session %s returned twice while paging", sess.Key)
+			}
+			seen[sess.Key] = true
+		}
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	runStorageSuite(t, func(t *testing.T) SessionStorage {
+		return NewMemoryStorage()
+	})
+}
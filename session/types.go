@@ -73,8 +73,11 @@ type ToolCall struct {
 // SessionMetadata 会话元数据
 type SessionMetadata struct {
 	Title           string         `json:"title"`
+	UserID          string         `json:"userId,omitempty"`      // 创建会话的用户 ID
 	Model           string         `json:"model,omitempty"`       // 当前使用的模型
 	ExtraFields     map[string]any `json:"extraFields,omitempty"` // 会话级扩展参数覆盖（思考强度等，如 thinking.type/reasoning_effort）
 	TotalTokenCount int            `json:"totalTokenCount"`
 	MessageCount    int            `json:"messageCount"`
+	// Approvals 工具调用审批记录（待审批与最近处理的记录）
+	Approvals []ToolApproval `json:"approvals,omitempty"`
 }
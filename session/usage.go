@@ -0,0 +1,197 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// UsageDayLayout 账本按天汇总使用的日期格式（UTC）
+const UsageDayLayout = "2006-01-02"
+
+// UsageEntry 单次 LLM 调用的用量
+type UsageEntry struct {
+	AgentID          string
+	UserID           string
+	SessionKey       string
+	Model            string
+	PromptTokens     int64 // 输入 token 数（包含 CachedTokens）
+	CompletionTokens int64
+	CachedTokens     int64
+	Cost             float64
+	// Time 调用时间，为零值时使用当前时间
+	Time time.Time
+}
+
+// UsageRecord 账本汇总记录，按 天/智能体/用户/会话/模型 聚合
+type UsageRecord struct {
+	Day              string  `json:"day"`
+	AgentID          string  `json:"agentId"`
+	UserID           string  `json:"userId"`
+	SessionKey       string  `json:"sessionKey"`
+	Model            string  `json:"model"`
+	Calls            int64   `json:"calls"`
+	PromptTokens     int64   `json:"promptTokens"`
+	CompletionTokens int64   `json:"completionTokens"`
+	CachedTokens     int64   `json:"cachedTokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// add 累加另一条记录的用量
+func (r *UsageRecord) add(o *UsageRecord) {
+	r.Calls += o.Calls
+	r.PromptTokens += o.PromptTokens
+	r.CompletionTokens += o.CompletionTokens
+	r.CachedTokens += o.CachedTokens
+	r.Cost += o.Cost
+}
+
+// UsageQuery 账本查询条件，空字段表示不过滤
+type UsageQuery struct {
+	AgentID    string
+	UserID     string
+	SessionKey string
+	Model      string
+	// FromDay、ToDay 日期范围（含），格式为 UsageDayLayout
+	FromDay string
+	ToDay   string
+}
+
+// match 记录是否满足查询条件
+func (q *UsageQuery) match(r *UsageRecord) bool {
+	if q == nil {
+		return true
+	}
+	return (q.AgentID == "" || q.AgentID == r.AgentID) &&
+		(q.UserID == "" || q.UserID == r.UserID) &&
+		(q.SessionKey == "" || q.SessionKey == r.SessionKey) &&
+		(q.Model == "" || q.Model == r.Model) &&
+		(q.FromDay == "" || r.Day >= q.FromDay) &&
+		(q.ToDay == "" || r.Day <= q.ToDay)
+}
+
+// UsageLedger 用量账本
+type UsageLedger interface {
+	// Record 记录一次调用的用量
+	Record(ctx context.Context, entry *UsageEntry) error
+
+	// Query 查询汇总记录，按 天/智能体/用户/会话/模型 排序
+	Query(ctx context.Context, query *UsageQuery) ([]*UsageRecord, error)
+}
+
+// UsageLedgerStorage 自带持久化账本的存储（可选接口实现）
+// NewManager 优先使用存储提供的账本，否则使用内存账本
+type UsageLedgerStorage interface {
+	UsageLedger() UsageLedger
+}
+
+// SumUsage 汇总多条记录，返回结果只保留用量字段
+func SumUsage(records []*UsageRecord) UsageRecord {
+	var total UsageRecord
+	for _, r := range records {
+		total.add(r)
+	}
+	return total
+}
+
+// newUsageRecord 由单次用量生成汇总记录
+func newUsageRecord(entry *UsageEntry) *UsageRecord {
+	t := entry.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return &UsageRecord{
+		Day:              t.UTC().Format(UsageDayLayout),
+		AgentID:          entry.AgentID,
+		UserID:           entry.UserID,
+		SessionKey:       entry.SessionKey,
+		Model:            entry.Model,
+		Calls:            1,
+		PromptTokens:     entry.PromptTokens,
+		CompletionTokens: entry.CompletionTokens,
+		CachedTokens:     entry.CachedTokens,
+		Cost:             entry.Cost,
+	}
+}
+
+// sortUsageRecords 按 天/智能体/用户/会话/模型 排序
+func sortUsageRecords(records []*UsageRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.AgentID != b.AgentID {
+			return a.AgentID < b.AgentID
+		}
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		if a.SessionKey != b.SessionKey {
+			return a.SessionKey < b.SessionKey
+		}
+		return a.Model < b.Model
+	})
+}
+
+// usageRecordKey 汇总记录的聚合键
+type usageRecordKey struct {
+	day, agentID, userID, sessionKey, model string
+}
+
+// MemoryUsageLedger 内存账本，进程重启后数据丢失
+type MemoryUsageLedger struct {
+	mu      sync.RWMutex
+	records map[usageRecordKey]*UsageRecord
+}
+
+// NewMemoryUsageLedger 创建内存账本
+func NewMemoryUsageLedger() *MemoryUsageLedger {
+	return &MemoryUsageLedger{records: make(map[usageRecordKey]*UsageRecord)}
+}
+
+// Record 记录一次调用的用量
+func (l *MemoryUsageLedger) Record(ctx context.Context, entry *UsageEntry) error {
+	r := newUsageRecord(entry)
+	key := usageRecordKey{r.Day, r.AgentID, r.UserID, r.SessionKey, r.Model}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if existing, ok := l.records[key]; ok {
+		existing.add(r)
+	} else {
+		l.records[key] = r
+	}
+	return nil
+}
+
+// Query 查询汇总记录
+func (l *MemoryUsageLedger) Query(ctx context.Context, query *UsageQuery) ([]*UsageRecord, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var result []*UsageRecord
+	for _, r := range l.records {
+		if query.match(r) {
+			cp := *r
+			result = append(result, &cp)
+		}
+	}
+	sortUsageRecords(result)
+	return result, nil
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright 2023 The RuleGo Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package session
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// runUsageLedgerSuite 所有账本实现共用的测试用例
+func runUsageLedgerSuite(t *testing.T, newLedger func(t *testing.T) UsageLedger) {
+	ctx := context.Background()
+	day1 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	day2 := time.Date(2026, 3, 2, 23, 30, 0, 0, time.UTC)
+
+	ledger := newLedger(t)
+	for _, e := range []*UsageEntry{
+		{AgentID: "a1", UserID: "u1", SessionKey: "s1", Model: "m1", PromptTokens: 100, CompletionTokens: 10, CachedTokens: 50, Cost: 0.5, Time: day1},
+		{AgentID: "a1", UserID: "u1", SessionKey: "s1", Model: "m1", PromptTokens: 200, CompletionTokens: 20, Cost: 0.25, Time: day1},
+		{AgentID: "a1", UserID: "u2", SessionKey: "s2", Model: "m2", PromptTokens: 10, CompletionTokens: 1, Cost: 1, Time: day1},
+		{AgentID: "a2", UserID: "u1", SessionKey: "s3", Model: "m1", PromptTokens: 1, CompletionTokens: 1, Cost: 2, Time: day2},
+	} {
+		if err := ledger.Record(ctx, e); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	t.Run("aggregates by key", func(t *testing.T) {
+		records, err := ledger.Query(ctx, nil)
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		if len(records) != 3 {
+			t.Fatalf("expected 3 records, got %d", len(records))
+		}
+		r := records[0]
+		if r.Day != "2026-03-01" || r.UserID != "u1" || r.Calls != 2 || r.PromptTokens != 300 ||
+			r.CompletionTokens != 30 || r.CachedTokens != 50 || math.Abs(r.Cost-0.75) > 1e-9 {
+			t.Errorf("unexpected aggregated record %+v", r)
+		}
+		if records[2].Day != "2026-03-02" {
+			t.Errorf("records should be sorted by day, got %+v", records[2])
+		}
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		cases := []struct {
+			name  string
+			query *UsageQuery
+			calls int64
+			cost  float64
+		}{
+			{"user", &UsageQuery{UserID: "u1"}, 3, 2.75},
+			{"session", &UsageQuery{SessionKey: "s2"}, 1, 1},
+			{"agent and model", &UsageQuery{AgentID: "a1", Model: "m1"}, 2, 0.75},
+			{"day range", &UsageQuery{FromDay: "2026-03-02", ToDay: "2026-03-31"}, 1, 2},
+			{"single day", &UsageQuery{UserID: "u1", FromDay: "2026-03-01", ToDay: "2026-03-01"}, 2, 0.75},
+			{"no match", &UsageQuery{UserID: "nobody"}, 0, 0},
+		}
+		for _, tc := range cases {
+			records, err := ledger.Query(ctx, tc.query)
+			if err != nil {
+				t.Fatalf("%s: Query() error = %v", tc.name, err)
+			}
+			total := SumUsage(records)
+			if total.Calls != tc.calls || math.Abs(total.Cost-tc.cost) > 1e-9 {
+				t.Errorf("%s: got calls=%d cost=%v, want calls=%d cost=%v", tc.name, total.Calls, total.Cost, tc.calls, tc.cost)
+			}
+		}
+	})
+}
+
+func TestMemoryUsageLedger(t *testing.T) {
+	runUsageLedgerSuite(t, func(t *testing.T) UsageLedger {
+		return NewMemoryUsageLedger()
+	})
+}
+
+func TestSQLiteUsageLedger(t *testing.T) {
+	runUsageLedgerSuite(t, func(t *testing.T) UsageLedger {
+		storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "sessions.db"))
+		if err != nil {
+			t.Fatalf("NewSQLiteStorage() error = %v", err)
+		}
+		t.Cleanup(func() { _ = storage.Close() })
+		return storage.UsageLedger()
+	})
+}
+
+func TestRedisUsageLedger(t *testing.T) {
+	runUsageLedgerSuite(t, func(t *testing.T) UsageLedger {
+		storage, _ := newTestRedisStorage(t)
+		return storage.UsageLedger()
+	})
+}
+
+func TestManager_UsageLedger(t *testing.T) {
+	if _, ok := NewManager(NewMemoryStorage(), nil).UsageLedger().(*MemoryUsageLedger); !ok {
+		t.Error("memory storage should use a memory ledger")
+	}
+	storage, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage() error = %v", err)
+	}
+	defer storage.Close()
+	mgr := NewManager(storage, nil)
+	if _, ok := mgr.UsageLedger().(*sqliteUsageLedger); !ok {
+		t.Errorf("sqlite storage should provide its own ledger, got %T", mgr.UsageLedger())
+	}
+	custom := NewMemoryUsageLedger()
+	mgr.SetUsageLedger(custom)
+	if mgr.UsageLedger() != custom {
+		t.Error("SetUsageLedger() should replace the ledger")
+	}
+}
+
+func TestCheckBudgets(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	ledger := NewMemoryUsageLedger()
+	record := func(user, sess string, cost float64, at time.Time) {
+		_ = ledger.Record(ctx, &UsageEntry{AgentID: "agent", UserID: user, SessionKey: sess, Model: "m", Cost: cost, Time: at})
+	}
+	record("alice", "s1", 3, now)
+	record("alice", "s2", 4, now.AddDate(0, 0, -3))
+	record("alice", "s0", 100, now.AddDate(0, -1, 0))
+	record("bob", "s3", 1, now)
+
+	alice := BudgetSubject{AgentID: "agent", UserID: "alice", SessionKey: "s1"}
+
+	t.Run("daily within limit", func(t *testing.T) {
+		d, err := CheckBudgets(ctx, ledger, []Budget{{Scope: BudgetScopeUser, Period: BudgetPeriodDaily, Limit: 5, Mode: BudgetModeHard}}, alice, now)
+		if err != nil || len(d.Exceeded) != 0 || d.Refuse {
+			t.Errorf("daily budget should not be exhausted, got %+v err=%v", d, err)
+		}
+	})
+
+	t.Run("monthly hard refuses", func(t *testing.T) {
+		d, _ := CheckBudgets(ctx, ledger, []Budget{{Scope: BudgetScopeUser, Period: BudgetPeriodMonthly, Limit: 7, Mode: BudgetModeHard}}, alice, now)
+		if !d.Refuse || len(d.Exceeded) != 1 || d.Exceeded[0].Spent != 7 {
+			t.Errorf("monthly budget should be exhausted, got %+v", d)
+		}
+	})
+
+	t.Run("soft downgrades", func(t *testing.T) {
+		budgets := []Budget{
+			{Scope: BudgetScopeSession, Period: BudgetPeriodTotal, Limit: 1, Mode: BudgetModeSoft, DowngradeModel: "small"},
+			{Scope: BudgetScopeUser, Period: BudgetPeriodTotal, Limit: 50, Mode: BudgetModeSoft, DowngradeModel: "tiny"},
+		}
+		d, _ := CheckBudgets(ctx, ledger, budgets, alice, now)
+		if d.Refuse || d.DowngradeModel != "small" || len(d.Exceeded) != 2 {
+			t.Errorf("expected downgrade to first configured model, got %+v", d)
+		}
+	})
+
+	t.Run("specific id overrides default", func(t *testing.T) {
+		budgets := []Budget{
+			{Scope: BudgetScopeUser, Period: BudgetPeriodDaily, Limit: 0.5, Mode: BudgetModeHard},
+			{Scope: BudgetScopeUser, ID: "alice", Period: BudgetPeriodDaily, Limit: 10, Mode: BudgetModeHard},
+		}
+		if d, _ := CheckBudgets(ctx, ledger, budgets, alice, now); d.Refuse {
+			t.Errorf("alice has a larger dedicated budget, got %+v", d)
+		}
+		bob := BudgetSubject{AgentID: "agent", UserID: "bob", SessionKey: "s3"}
+		if d, _ := CheckBudgets(ctx, ledger, budgets, bob, now); !d.Refuse {
+			t.Errorf("bob should fall back to the default budget, got %+v", d)
+		}
+	})
+
+	t.Run("anonymous subject skips user budgets", func(t *testing.T) {
+		budgets := []Budget{{Scope: BudgetScopeUser, Period: BudgetPeriodTotal, Limit: 0.1, Mode: BudgetModeHard}}
+		if d, _ := CheckBudgets(ctx, ledger, budgets, BudgetSubject{AgentID: "agent", SessionKey: "s9"}, now); d.Refuse {
+			t.Errorf("user budget should not apply without a user id, got %+v", d)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package bash
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rulego/rulego-components-ai/session"
+	"github.com/rulego/rulego-components-ai/tool/common"
+)
+
+// beginCheckpoint 开启检查点且 ctx 注入了 runId 时，在执行前对工作目录做快照，
+// 返回执行后记录改动的函数（其返回值附加到执行结果）；不需要记录时返回 nil
+func (t *bashTool) beginCheckpoint(ctx context.Context) func() string {
+	runId := common.RunIDFromCtx(ctx)
+	if !t.config.Checkpoint || runId == "" {
+		return nil
+	}
+	// 检查点根目录与文件工具一致：ctx 注入的 workDir 优先，否则使用配置
+	root := common.WorkDirFromCtx(ctx)
+	if root == "" {
+		root = t.config.WorkDir
+	}
+	if root == "" {
+		root, _ = os.Getwd()
+	}
+	root, _ = filepath.Abs(root)
+
+	limits := common.DefaultSnapshotLimits()
+	limits.SkipDirs = append(limits.SkipDirs, ".tool-output")
+	before, err := common.SnapshotWorkspace(root, limits)
+	if err != nil {
+		return func() string { return "\n\nCheckpoint Warning: workspace snapshot failed: " + err.Error() }
+	}
+	sessionKey, _ := session.SessionKeyFromContext(ctx)
+	return func() string {
+		checkpoints := common.NewCheckpointManager(root)
+		_, skipped, err := checkpoints.RecordSnapshotChanges(sessionKey, runId, ToolName, before)
+		var warnings []string
+		if err != nil {
+			warnings = append(warnings, "recording file changes failed: "+err.Error())
+		}
+		if len(skipped) > 0 {
+			names := make([]string, 0, len(skipped))
+			for _, p := range skipped {
+				names = append(names, checkpoints.Rel(p))
+			}
+			warnings = append(warnings, fmt.Sprintf("%d changed file(s) too large to checkpoint: %s", len(skipped), strings.Join(names, ", ")))
+		}
+		if before.Truncated() {
+			warnings = append(warnings, "workspace has too many files, only the first part is checkpointed")
+		}
+		if len(warnings) == 0 {
+			return ""
+		}
+		return "\n\nCheckpoint Warning: " + strings.Join(warnings, "; ")
+	}
+}
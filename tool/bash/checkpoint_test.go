@@ -0,0 +1,50 @@
+package bash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rulego/rulego-components-ai/session"
+	"github.com/rulego/rulego-components-ai/tool/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoint_RecordsCommandChanges(t *testing.T) {
+	bt := newTestTool(t, func(c *Config) {
+		c.Mode = ModeDeny
+		c.WorkDir = t.TempDir()
+		c.Checkpoint = true
+	})
+
+	workDir := bt.config.WorkDir
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "keep.txt"), []byte("keep\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "gone.txt"), []byte("gone\n"), 0644))
+
+	ctx := common.WithRunID(session.WithSessionKey(context.Background(), "sess"), "run1")
+	result := invoke(t, bt, ctx, map[string]interface{}{"command": "echo more >> keep.txt && rm gone.txt && mkdir -p out && echo new > out/new.txt"})
+	assert.Contains(t, result, "Exit Code: 0")
+	assert.NotContains(t, result, "Checkpoint Warning")
+
+	checkpoints := common.NewCheckpointManager(workDir)
+	list, err := checkpoints.List("sess")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Len(t, list[0].Files, 3)
+
+	_, err = checkpoints.Restore("sess", "run1")
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(workDir, "keep.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "keep\n", string(data))
+	assert.FileExists(t, filepath.Join(workDir, "gone.txt"))
+	assert.NoFileExists(t, filepath.Join(workDir, "out", "new.txt"))
+
+	// 未注入 runId 时不记录
+	invoke(t, bt, context.Background(), map[string]interface{}{"command": "echo x > other.txt"})
+	list, err = common.NewCheckpointManager(workDir).List("")
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
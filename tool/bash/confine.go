@@ -0,0 +1,94 @@
+package bash
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// 执行隔离（仅 Linux，需显式开启）：命令经由当前可执行文件的跳板进程启动，
+// 跳板设置 rlimit、Landlock 文件系统规则后 exec 目标命令；禁止网络时在新的 user+net namespace 中运行。
+// 内核不支持的项会跳过，并在执行结果中给出警告。
+
+const (
+	// confineEnv 传递隔离参数给跳板进程的环境变量
+	confineEnv = "RULEGO_BASH_CONFINE"
+	// confineArg0 跳板进程的 argv[0]，与 confineEnv 同时出现才进入跳板逻辑
+	confineArg0 = "rulego-bash-confine"
+)
+
+// defaultReadOnlyDirs 默认只读可访问的系统目录（Landlock）
+var defaultReadOnlyDirs = []string{
+	"/bin", "/sbin", "/usr", "/lib", "/lib32", "/lib64", "/libx32",
+	"/etc", "/opt", "/proc", "/sys", "/run", "/nix",
+}
+
+// ConfinementConfig 执行隔离配置
+type ConfinementConfig struct {
+	// Enabled 开启执行隔离
+	Enabled bool `json:"enabled"`
+	// CPUSeconds 每个进程的 CPU 时间上限（秒，RLIMIT_CPU），0 表示不限制
+	CPUSeconds int `json:"cpuSeconds"`
+	// MemoryMB 每个进程的虚拟内存上限（MB，RLIMIT_AS），0 表示不限制
+	MemoryMB int `json:"memoryMB"`
+	// MaxProcesses 运行用户的进程数上限（RLIMIT_NPROC，按用户统计，root 不受限），0 表示不限制
+	MaxProcesses int `json:"maxProcesses"`
+	// MaxFileSizeMB 单个写入文件的大小上限（MB，RLIMIT_FSIZE），0 表示不限制
+	MaxFileSizeMB int `json:"maxFileSizeMB"`
+	// Landlock 启用 Landlock 文件系统隔离：工作目录与 PathSecurity.AllowDirs 可读写，系统目录只读，其他路径不可访问
+	Landlock bool `json:"landlock"`
+	// ReadOnlyDirs 额外的只读目录
+	ReadOnlyDirs []string `json:"readOnlyDirs"`
+	// ReadWriteDirs 额外的读写目录（如系统临时目录）
+	ReadWriteDirs []string `json:"readWriteDirs"`
+	// DenyNetwork 在新的网络命名空间中运行（只有回环网卡），需要内核允许非特权 user namespace
+	DenyNetwork bool `json:"denyNetwork"`
+}
+
+// confineSpec 传给跳板进程的隔离参数
+type confineSpec struct {
+	Path      string            `json:"path"`
+	Args      []string          `json:"args"`
+	Rlimits   map[string]uint64 `json:"rlimits,omitempty"`
+	Landlock  bool              `json:"landlock,omitempty"`
+	ReadOnly  []string          `json:"readOnly,omitempty"`
+	ReadWrite []string          `json:"readWrite,omitempty"`
+}
+
+// confine 按配置为命令设置执行隔离，返回需要告知调用方的警告（如内核不支持 Landlock）
+// 必须在 setSysProcAttr 之后、Start 之前调用
+func (t *bashTool) confine(cmd *exec.Cmd, workDir string) []string {
+	c := t.config.Confinement
+	if !c.Enabled || cmd.Err != nil {
+		return nil
+	}
+	spec := confineSpec{Path: cmd.Path, Args: cmd.Args, Rlimits: make(map[string]uint64)}
+	const mb = 1024 * 1024
+	if c.CPUSeconds > 0 {
+		spec.Rlimits["cpu"] = uint64(c.CPUSeconds)
+	}
+	if c.MemoryMB > 0 {
+		spec.Rlimits["as"] = uint64(c.MemoryMB) * mb
+	}
+	if c.MaxProcesses > 0 {
+		spec.Rlimits["nproc"] = uint64(c.MaxProcesses)
+	}
+	if c.MaxFileSizeMB > 0 {
+		spec.Rlimits["fsize"] = uint64(c.MaxFileSizeMB) * mb
+	}
+	if c.Landlock {
+		spec.Landlock = true
+		spec.ReadOnly = append(append([]string{}, defaultReadOnlyDirs...), c.ReadOnlyDirs...)
+		if workDir == "" {
+			workDir, _ = os.Getwd()
+		}
+		spec.ReadWrite = append(spec.ReadWrite, workDir)
+		if t.config.PathSecurity != nil {
+			spec.ReadWrite = append(spec.ReadWrite, t.config.PathSecurity.AllowDirs...)
+		}
+		spec.ReadWrite = append(spec.ReadWrite, c.ReadWriteDirs...)
+		// Shell 解释器可能不在默认系统目录中（如自定义 ShellPath）
+		spec.ReadOnly = append(spec.ReadOnly, filepath.Dir(cmd.Path))
+	}
+	return applyConfinement(cmd, spec, c.DenyNetwork)
+}
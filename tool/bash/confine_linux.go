@@ -0,0 +1,225 @@
+//go:build linux
+
+package bash
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// 跳板进程入口：带 confineEnv 且 argv[0] 为 confineArg0 时应用隔离并 exec 目标命令，不返回
+func init() {
+	raw, ok := os.LookupEnv(confineEnv)
+	if !ok || len(os.Args) == 0 || os.Args[0] != confineArg0 {
+		return
+	}
+	if err := runConfined(raw); err != nil {
+		fmt.Fprintf(os.Stderr, "confinement: %v\n", err)
+		os.Exit(126)
+	}
+	os.Exit(0)
+}
+
+// rlimitResources 配置名到 rlimit 资源的映射
+var rlimitResources = map[string]int{
+	"cpu":   unix.RLIMIT_CPU,
+	"as":    unix.RLIMIT_AS,
+	"nproc": unix.RLIMIT_NPROC,
+	"fsize": unix.RLIMIT_FSIZE,
+}
+
+// runConfined 在跳板进程中应用隔离并 exec 目标命令；没有目标命令时直接返回（用于探测）
+func runConfined(raw string) error {
+	var spec confineSpec
+	if err := json.Unmarshal([]byte(raw), &spec); err != nil {
+		return err
+	}
+	if spec.Path == "" {
+		return nil
+	}
+	// Landlock 与 no_new_privs 作用于当前线程，exec 前不能切换线程
+	runtime.LockOSThread()
+	if spec.Landlock {
+		if err := restrictLandlock(spec.ReadOnly, spec.ReadWrite); err != nil {
+			return fmt.Errorf("landlock: %w", err)
+		}
+	}
+	env := make([]string, 0, len(os.Environ()))
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, confineEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	// rlimit 最后设置，避免跳板自身受内存、文件大小限制影响
+	for name, value := range spec.Rlimits {
+		resource, ok := rlimitResources[name]
+		if !ok {
+			continue
+		}
+		if err := unix.Setrlimit(resource, &unix.Rlimit{Cur: value, Max: value}); err != nil {
+			return fmt.Errorf("setrlimit %s: %w", name, err)
+		}
+	}
+	return syscall.Exec(spec.Path, spec.Args, env)
+}
+
+// landlock 文件访问权限
+const (
+	landlockFileAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE | unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	landlockReadAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_READ_DIR
+)
+
+// landlockHandledAccess 返回内核 ABI 版本支持的全部文件系统访问权限
+func landlockHandledAccess(abi int) uint64 {
+	access := uint64(unix.LANDLOCK_ACCESS_FS_MAKE_SYM<<1) - 1 // ABI 1：EXECUTE 到 MAKE_SYM
+	if abi >= 2 {
+		access |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		access |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	if abi >= 5 {
+		access |= unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	}
+	return access
+}
+
+// landlockABI 返回内核 Landlock ABI 版本，不支持时返回 0
+func landlockABI() int {
+	v, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0
+	}
+	return int(v)
+}
+
+// restrictLandlock 限制当前线程只能访问给定目录：readOnly 只读，readWrite 读写；不存在的目录跳过
+func restrictLandlock(readOnly, readWrite []string) error {
+	abi := landlockABI()
+	if abi == 0 {
+		return fmt.Errorf("not supported by the kernel")
+	}
+	handled := landlockHandledAccess(abi)
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("create ruleset: %w", errno)
+	}
+	rulesetFd := int(fd)
+	defer unix.Close(rulesetFd)
+
+	addRule := func(path string, access uint64) error {
+		pathFd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return nil
+		}
+		defer unix.Close(pathFd)
+		var st unix.Stat_t
+		if err := unix.Fstat(pathFd, &st); err != nil {
+			return nil
+		}
+		if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+			access &= landlockFileAccess
+		}
+		rule := unix.LandlockPathBeneathAttr{Allowed_access: access & handled, Parent_fd: int32(pathFd)}
+		if _, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(rulesetFd), unix.LANDLOCK_RULE_PATH_BENEATH,
+			uintptr(unsafe.Pointer(&rule)), 0, 0, 0); errno != 0 {
+			return fmt.Errorf("add rule for %s: %w", path, errno)
+		}
+		return nil
+	}
+	for _, dir := range readOnly {
+		if err := addRule(dir, landlockReadAccess); err != nil {
+			return err
+		}
+	}
+	// /dev 下的设备文件（/dev/null、/dev/tty 等）允许读写
+	if err := addRule("/dev", landlockReadAccess|landlockFileAccess); err != nil {
+		return err
+	}
+	for _, dir := range readWrite {
+		if dir == "" {
+			continue
+		}
+		if err := addRule(dir, handled); err != nil {
+			return err
+		}
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("set no_new_privs: %w", err)
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(rulesetFd), 0, 0); errno != 0 {
+		return fmt.Errorf("restrict self: %w", errno)
+	}
+	return nil
+}
+
+var (
+	netnsOnce      sync.Once
+	netnsSupported bool
+)
+
+// netnsAvailable 探测能否以非特权方式创建 user+net namespace（结果缓存）
+func netnsAvailable() bool {
+	netnsOnce.Do(func() {
+		cmd := exec.Command("/proc/self/exe")
+		cmd.Args = []string{confineArg0}
+		cmd.Env = append(os.Environ(), confineEnv+"={}")
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+		setNetns(cmd.SysProcAttr)
+		netnsSupported = cmd.Run() == nil
+	})
+	return netnsSupported
+}
+
+// setNetns 在新的 user+net namespace 中启动进程，当前用户映射为命名空间内的同一 uid/gid
+func setNetns(attr *syscall.SysProcAttr) {
+	attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+}
+
+// applyConfinement 把命令改为经由跳板进程启动，返回不支持项的警告
+func applyConfinement(cmd *exec.Cmd, spec confineSpec, denyNetwork bool) []string {
+	var warnings []string
+	if spec.Landlock && landlockABI() == 0 {
+		spec.Landlock = false
+		warnings = append(warnings, "Confinement Warning: Landlock is not supported by the kernel, filesystem confinement disabled")
+	}
+	if denyNetwork {
+		if netnsAvailable() {
+			if cmd.SysProcAttr == nil {
+				cmd.SysProcAttr = &syscall.SysProcAttr{}
+			}
+			setNetns(cmd.SysProcAttr)
+		} else {
+			warnings = append(warnings, "Confinement Warning: unprivileged network namespaces are not available, network access not restricted")
+		}
+	}
+	if !spec.Landlock && len(spec.Rlimits) == 0 {
+		return warnings
+	}
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return append(warnings, "Confinement Warning: "+err.Error())
+	}
+	cmd.Path = "/proc/self/exe"
+	cmd.Args = []string{confineArg0}
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(env[:len(env):len(env)], confineEnv+"="+string(raw))
+	return warnings
+}
@@ -0,0 +1,86 @@
+//go:build linux
+
+package bash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rulego/rulego-components-ai/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfinement_Rlimits(t *testing.T) {
+	bt := newTestTool(t, func(c *Config) {
+		c.Mode = ModeDeny
+		c.WorkDir = t.TempDir()
+		c.Confinement = ConfinementConfig{Enabled: true, CPUSeconds: 7, MaxFileSizeMB: 1, MemoryMB: 4096}
+	})
+
+	result := invoke(t, bt, context.Background(), map[string]interface{}{"command": "ulimit -t; ulimit -v"})
+	assert.Contains(t, result, "Exit Code: 0")
+	assert.Contains(t, result, "7\n4194304")
+
+	result = invoke(t, bt, context.Background(), map[string]interface{}{"command": "head -c 2000000 /dev/zero > big.bin"})
+	assert.NotContains(t, result, "Exit Code: 0")
+	info, err := os.Stat(filepath.Join(bt.config.WorkDir, "big.bin"))
+	require.NoError(t, err)
+	assert.LessOrEqual(t, info.Size(), int64(1024*1024))
+}
+
+func TestConfinement_Landlock(t *testing.T) {
+	secretDir := t.TempDir()
+	secret := filepath.Join(secretDir, "id_rsa")
+	require.NoError(t, os.WriteFile(secret, []byte("top-secret"), 0600))
+	bt := newTestTool(t, func(c *Config) {
+		c.Mode = ModeDeny
+		c.WorkDir = t.TempDir()
+		c.Confinement = ConfinementConfig{Enabled: true, Landlock: true}
+	})
+
+	result := invoke(t, bt, context.Background(), map[string]interface{}{"command": "echo ok > inside.txt && cat inside.txt; cat " + secret})
+	if landlockABI() == 0 {
+		assert.Contains(t, result, "Landlock is not supported")
+		assert.Contains(t, result, "top-secret")
+		return
+	}
+	assert.NotContains(t, result, "Confinement Warning")
+	assert.Contains(t, result, "ok")
+	assert.NotContains(t, result, "top-secret")
+	assert.Contains(t, result, "Permission denied")
+
+	// 会话 Shell 与后台任务同样受限
+	key := "test-confine-" + t.Name()
+	defer CloseSession(key)
+	bt.config.SessionMode = true
+	ctx := session.WithSessionKey(context.Background(), key)
+	result = invoke(t, bt, ctx, map[string]interface{}{"command": "cat " + secret})
+	assert.Contains(t, result, "Shell Session: started")
+	assert.NotContains(t, result, "top-secret")
+}
+
+func TestConfinement_DenyNetwork(t *testing.T) {
+	bt := newTestTool(t, func(c *Config) {
+		c.Mode = ModeDeny
+		c.WorkDir = t.TempDir()
+		c.Confinement = ConfinementConfig{Enabled: true, DenyNetwork: true}
+	})
+	result := invoke(t, bt, context.Background(), map[string]interface{}{"command": "cat /proc/net/dev"})
+	if !netnsAvailable() {
+		assert.Contains(t, result, "network namespaces are not available")
+		return
+	}
+	assert.Contains(t, result, "Exit Code: 0")
+	var ifaces []string
+	for _, line := range strings.Split(result, "\n") {
+		if name, _, ok := strings.Cut(line, ":"); ok && !strings.Contains(name, " |") && strings.TrimSpace(name) != "" {
+			ifaces = append(ifaces, strings.TrimSpace(name))
+		}
+	}
+	assert.Contains(t, ifaces, "lo")
+	assert.NotContains(t, ifaces, "eth0")
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package bash
+
+import "os/exec"
+
+// applyConfinement 非 Linux 平台不支持执行隔离，只返回警告
+func applyConfinement(cmd *exec.Cmd, spec confineSpec, denyNetwork bool) []string {
+	return []string{"Confinement Warning: resource and filesystem confinement is only supported on Linux"}
+}
@@ -0,0 +1,483 @@
+package bash
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rulego/rulego-components-ai/session"
+	"github.com/rulego/rulego-components-ai/tool/common"
+)
+
+// 后台任务：run_in_background 启动的命令脱离本次工具调用运行，输出写入落盘目录
+// （workDir/.tool-output，无工作目录时使用系统临时目录），通过 jobs/output/wait/signal 操作查询和控制。
+// 任务归属调用时的会话，只能被同一会话访问；会话结束时终止该会话的所有后台任务。
+// 未绑定会话的任务没有结束回调，启动 BackgroundJobTTL 后终止并删除其输出文件。
+
+const (
+	// OpRun 执行命令（默认）
+	OpRun = "run"
+	// OpJobs 列出当前会话的后台任务
+	OpJobs = "jobs"
+	// OpOutput 读取后台任务游标之后的增量输出
+	OpOutput = "output"
+	// OpWait 等待后台任务结束
+	OpWait = "wait"
+	// OpSignal 向后台任务发送信号
+	OpSignal = "signal"
+
+	// DefaultMaxBackgroundJobs 每个会话默认最多同时运行的后台任务数
+	DefaultMaxBackgroundJobs = 10
+	// DefaultBackgroundJobTTL 未绑定会话的后台任务默认存活时间（秒）
+	DefaultBackgroundJobTTL = 3600
+	// maxFinishedJobs 每个会话保留的已结束任务数，超出后移除最早结束的任务
+	maxFinishedJobs = 20
+)
+
+// errTooManyJobs 会话运行中的后台任务达到上限
+var errTooManyJobs = errors.New("too many background jobs running")
+
+// backgroundJob 后台任务
+type backgroundJob struct {
+	id         string
+	sessionKey string
+	command    string
+	dir        string
+	stdoutPath string
+	stderrPath string
+	startedAt  time.Time
+	cmd        *exec.Cmd
+	done       chan struct{}
+
+	mu      sync.Mutex
+	endedAt time.Time
+	state   string // 结束状态，如 "exit status 1"、"signal: killed"
+	code    int
+}
+
+// finished 返回任务是否已结束
+func (j *backgroundJob) finished() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// status 返回任务状态描述
+func (j *backgroundJob) status() string {
+	if !j.finished() {
+		return fmt.Sprintf("running (pid %d, %s)", j.cmd.Process.Pid, time.Since(j.startedAt).Round(time.Second))
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	elapsed := j.endedAt.Sub(j.startedAt).Round(time.Millisecond)
+	if j.code >= 0 {
+		return fmt.Sprintf("exited (code %d, %s)", j.code, elapsed)
+	}
+	return fmt.Sprintf("terminated (%s, %s)", j.state, elapsed)
+}
+
+// jobManager 后台任务注册表
+type jobManager struct {
+	mu   sync.Mutex
+	seq  int
+	jobs map[string]*backgroundJob
+}
+
+// defaultJobs 进程内共享的后台任务注册表
+var defaultJobs = &jobManager{jobs: make(map[string]*backgroundJob)}
+
+// get 按 ID 获取会话的任务
+func (m *jobManager) get(sessionKey, id string) (*backgroundJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok || j.sessionKey != sessionKey {
+		return nil, false
+	}
+	return j, true
+}
+
+// list 列出会话的任务，按启动顺序排列
+func (m *jobManager) list(sessionKey string) []*backgroundJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var jobs []*backgroundJob
+	for _, j := range m.jobs {
+		if j.sessionKey == sessionKey {
+			jobs = append(jobs, j)
+		}
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].startedAt.Before(jobs[b].startedAt) })
+	return jobs
+}
+
+// add 登记新任务，超过并发上限时返回错误；同时清理过多的已结束任务
+func (m *jobManager) add(sessionKey string, maxRunning int, create func(id string) (*backgroundJob, error)) (*backgroundJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	running := 0
+	var finished []*backgroundJob
+	for _, j := range m.jobs {
+		if j.sessionKey != sessionKey {
+			continue
+		}
+		if j.finished() {
+			finished = append(finished, j)
+		} else {
+			running++
+		}
+	}
+	if running >= maxRunning {
+		return nil, fmt.Errorf("%w (%d), wait for or kill one first", errTooManyJobs, running)
+	}
+	if drop := len(finished) - maxFinishedJobs + 1; drop > 0 {
+		sort.Slice(finished, func(a, b int) bool { return finished[a].endedAt.Before(finished[b].endedAt) })
+		for _, j := range finished[:drop] {
+			delete(m.jobs, j.id)
+		}
+	}
+	m.seq++
+	j, err := create("job-" + strconv.Itoa(m.seq))
+	if err != nil {
+		return nil, err
+	}
+	m.jobs[j.id] = j
+	return j, nil
+}
+
+// closeSession 终止并移除会话的所有任务
+func (m *jobManager) closeSession(sessionKey string) {
+	m.mu.Lock()
+	var closing []*backgroundJob
+	for id, j := range m.jobs {
+		if j.sessionKey == sessionKey {
+			closing = append(closing, j)
+			delete(m.jobs, id)
+		}
+	}
+	m.mu.Unlock()
+	for _, j := range closing {
+		if !j.finished() {
+			killProcessGroup(j.cmd.Process.Pid)
+			<-j.done
+		}
+	}
+}
+
+// expireAfter ttl 后终止并移除任务，同时删除其输出文件
+func (m *jobManager) expireAfter(j *backgroundJob, ttl time.Duration) {
+	time.AfterFunc(ttl, func() {
+		m.mu.Lock()
+		if m.jobs[j.id] == j {
+			delete(m.jobs, j.id)
+		}
+		m.mu.Unlock()
+		if !j.finished() {
+			killProcessGroup(j.cmd.Process.Pid)
+			<-j.done
+		}
+		_ = os.Remove(j.stdoutPath)
+		_ = os.Remove(j.stderrPath)
+	})
+}
+
+// startBackground 启动后台任务，立即返回任务 ID
+func (t *bashTool) startBackground(ctx context.Context, params OperationParams, fullCommand string) (string, error) {
+	workDir, errMsg := t.resolveWorkDir(ctx, params.WorkDir)
+	if errMsg != "" {
+		return errMsg, nil
+	}
+	var cmdPath string
+	var cmdArgs []string
+	if len(params.Args) > 0 {
+		path, err := exec.LookPath(params.Command)
+		if err != nil {
+			return common.NewErrorf(common.ErrCodeFileNotFound, "command '%s' not found: %v", params.Command, err).Error(), nil
+		}
+		cmdPath, cmdArgs = path, params.Args
+	} else {
+		cmdPath = t.platform.ShellCommand
+		cmdArgs = append([]string{}, t.platform.ShellArgs...)
+		if t.platform.ShellType == ShellTypePowerShell {
+			// 与前台执行一致：PowerShell 5.1 不支持 &&，拼接到 [Console]:: 前缀之后
+			cmdArgs[len(cmdArgs)-1] += " " + strings.ReplaceAll(fullCommand, " && ", "; ")
+		} else {
+			cmdArgs = append(cmdArgs, fullCommand)
+		}
+	}
+
+	outDir := filepath.Join(os.TempDir(), "rulego-bash-jobs")
+	if workDir != "" {
+		outDir = filepath.Join(workDir, ".tool-output")
+	} else if t.config.WorkDir != "" {
+		outDir = filepath.Join(t.config.WorkDir, ".tool-output")
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return common.NewErrorf(common.ErrCodeDirCreateFailed, "create job output dir: %v", err).Error(), nil
+	}
+
+	sessionKey, _ := session.SessionKeyFromContext(ctx)
+	maxRunning := t.config.MaxBackgroundJobs
+	var warnings []string
+	job, err := defaultJobs.add(sessionKey, maxRunning, func(id string) (*backgroundJob, error) {
+		prefix := filepath.Join(outDir, fmt.Sprintf("%s-%s-%s", id, time.Now().Format("20060102-150405"), randomHex(4)))
+		stdout, err := os.Create(prefix + ".stdout")
+		if err != nil {
+			return nil, err
+		}
+		stderr, err := os.Create(prefix + ".stderr")
+		if err != nil {
+			_ = stdout.Close()
+			return nil, err
+		}
+		cmd := exec.Command(cmdPath, cmdArgs...)
+		cmd.Dir = workDir
+		cmd.Env = t.shellEnv()
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		// 独立进程组：signal 与会话结束时作用于任务的所有子进程
+		setSysProcAttr(cmd)
+		warnings = t.confine(cmd, workDir)
+		if err := cmd.Start(); err != nil {
+			_ = stdout.Close()
+			_ = stderr.Close()
+			return nil, fmt.Errorf("启动后台任务失败: %w", err)
+		}
+		j := &backgroundJob{
+			id:         id,
+			sessionKey: sessionKey,
+			command:    fullCommand,
+			dir:        workDir,
+			stdoutPath: stdout.Name(),
+			stderrPath: stderr.Name(),
+			startedAt:  time.Now(),
+			cmd:        cmd,
+			done:       make(chan struct{}),
+		}
+		go func() {
+			err := cmd.Wait()
+			_ = stdout.Close()
+			_ = stderr.Close()
+			j.mu.Lock()
+			j.endedAt = time.Now()
+			j.code = cmd.ProcessState.ExitCode()
+			if err != nil {
+				j.state = err.Error()
+			}
+			j.mu.Unlock()
+			close(j.done)
+		}()
+		return j, nil
+	})
+	if errors.Is(err, errTooManyJobs) {
+		return common.NewError(common.ErrCodeInvalidParams, err.Error()).Error(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if sessionKey == "" {
+		// 没有会话结束回调，到期回收，避免进程与输出文件泄漏
+		defaultJobs.expireAfter(job, time.Duration(t.config.BackgroundJobTTL)*time.Second)
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Job ID: %s\n", job.id))
+	if job.dir != "" {
+		result.WriteString(fmt.Sprintf("Work Dir: %s\n", job.dir))
+	}
+	result.WriteString(fmt.Sprintf("Status: %s\n", job.status()))
+	for _, w := range warnings {
+		result.WriteString(w + "\n")
+	}
+	result.WriteString(fmt.Sprintf("Stdout File: %s\nStderr File: %s\n", job.stdoutPath, job.stderrPath))
+	result.WriteString(fmt.Sprintf("Use operation=output (job_id=%s) to read new output, operation=wait to wait for it, operation=signal to stop it.\n", job.id))
+	return result.String(), nil
+}
+
+// listJobs 列出当前会话的后台任务
+func (t *bashTool) listJobs(ctx context.Context) string {
+	sessionKey, _ := session.SessionKeyFromContext(ctx)
+	jobs := defaultJobs.list(sessionKey)
+	if len(jobs) == 0 {
+		return "No background jobs.\n"
+	}
+	var result strings.Builder
+	for _, j := range jobs {
+		result.WriteString(fmt.Sprintf("%s\t%s\t%s\n", j.id, j.status(), j.command))
+	}
+	return result.String()
+}
+
+// jobOutput 读取任务游标之后的增量输出；tail>0 时改为读取最后 tail 行
+func (t *bashTool) jobOutput(ctx context.Context, params OperationParams) string {
+	job, errMsg := lookupJob(ctx, params.JobID)
+	if errMsg != "" {
+		return errMsg
+	}
+	return t.formatJobOutput(job, params)
+}
+
+// waitJob 等待任务结束（最长 timeout 秒），然后返回增量输出
+func (t *bashTool) waitJob(ctx context.Context, params OperationParams) string {
+	job, errMsg := lookupJob(ctx, params.JobID)
+	if errMsg != "" {
+		return errMsg
+	}
+	timeout := t.effectiveTimeout(params.Timeout)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	note := ""
+	select {
+	case <-job.done:
+	case <-timer.C:
+		note = fmt.Sprintf("Job still running after waiting %v.\n", timeout)
+	case <-ctx.Done():
+		note = "Wait interrupted.\n"
+	}
+	return note + t.formatJobOutput(job, params)
+}
+
+// signalJob 向任务进程组发送信号，默认 TERM
+func (t *bashTool) signalJob(ctx context.Context, params OperationParams) string {
+	job, errMsg := lookupJob(ctx, params.JobID)
+	if errMsg != "" {
+		return errMsg
+	}
+	sig := params.Signal
+	if sig == "" {
+		sig = "TERM"
+	}
+	if job.finished() {
+		return fmt.Sprintf("Job %s already finished: %s\n", job.id, job.status())
+	}
+	if err := signalProcessGroup(job.cmd.Process.Pid, sig); err != nil {
+		return common.NewErrorf(common.ErrCodeInvalidParams, "signal %s: %v", sig, err).Error()
+	}
+	// 等待片刻以便返回信号生效后的状态
+	select {
+	case <-job.done:
+	case <-time.After(500 * time.Millisecond):
+	}
+	return fmt.Sprintf("Sent SIG%s to %s\nStatus: %s\n", strings.TrimPrefix(strings.ToUpper(sig), "SIG"), job.id, job.status())
+}
+
+// lookupJob 按 ID 查找当前会话的任务
+func lookupJob(ctx context.Context, id string) (*backgroundJob, string) {
+	if id == "" {
+		return nil, common.NewError(common.ErrCodeInvalidParams, "job_id is required").Error()
+	}
+	sessionKey, _ := session.SessionKeyFromContext(ctx)
+	job, ok := defaultJobs.get(sessionKey, id)
+	if !ok {
+		return nil, common.NewErrorf(common.ErrCodeInvalidParams, "background job '%s' not found", id).Error()
+	}
+	return job, ""
+}
+
+// formatJobOutput 格式化任务状态与增量输出
+func (t *bashTool) formatJobOutput(job *backgroundJob, params OperationParams) string {
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Job: %s\n", job.id))
+	result.WriteString(fmt.Sprintf("Command: %s\n", job.command))
+	result.WriteString(fmt.Sprintf("Status: %s\n", job.status()))
+
+	stdout, stdoutNext, stdoutMore := readJobFile(job.stdoutPath, params.StdoutCursor, params.Tail, t.config.MaxOutputSize)
+	stderr, stderrNext, stderrMore := readJobFile(job.stderrPath, params.StderrCursor, params.Tail, t.config.MaxOutputSize)
+	result.WriteString(fmt.Sprintf("Next Cursor: stdout_cursor=%d stderr_cursor=%d\n", stdoutNext, stderrNext))
+	if stdoutMore || stderrMore {
+		result.WriteString("More output available: call again with the next cursor.\n")
+	}
+	result.WriteString("---\n")
+	for _, part := range []struct {
+		name, text string
+	}{{"stdout", stdout}, {"stderr", stderr}} {
+		if part.text == "" {
+			continue
+		}
+		result.WriteString(part.name + ":\n")
+		result.WriteString(part.text)
+		if !strings.HasSuffix(part.text, "\n") {
+			result.WriteString("\n")
+		}
+	}
+	return result.String()
+}
+
+// readJobFile 从 cursor 处读取最多 maxBytes 字节（tail>0 时读取最后 tail 行）
+// 返回内容、下一个游标及是否还有未读内容
+func readJobFile(path string, cursor int64, tail, maxBytes int) (string, int64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", cursor, false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", cursor, false
+	}
+	size := info.Size()
+	if tail > 0 {
+		start := size - int64(maxBytes)
+		if start < 0 {
+			start = 0
+		}
+		data := make([]byte, size-start)
+		n, _ := f.ReadAt(data, start)
+		lines := strings.SplitAfter(string(data[:n]), "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		if len(lines) > tail {
+			lines = lines[len(lines)-tail:]
+		}
+		return convertToUTF8([]byte(strings.Join(lines, ""))), size, false
+	}
+	if cursor < 0 || cursor > size {
+		cursor = 0
+	}
+	n := size - cursor
+	if n > int64(maxBytes) {
+		n = int64(maxBytes)
+	}
+	data := make([]byte, n)
+	read, err := f.ReadAt(data, cursor)
+	if err != nil && err != io.EOF {
+		return "", cursor, false
+	}
+	data = data[:read]
+	// 不截断多字节字符，未读完的字符留到下次
+	if cursor+int64(read) < size {
+		for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(data[i]) {
+				if !utf8.FullRune(data[i:]) {
+					data = data[:i]
+				}
+				break
+			}
+		}
+	}
+	next := cursor + int64(len(data))
+	return convertToUTF8(data), next, next < size
+}
+
+// randomHex 返回 n 字节随机数的十六进制表示
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,148 @@
+package bash
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rulego/rulego-components-ai/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	jobIDPattern  = regexp.MustCompile(`Job ID: (job-\d+)`)
+	cursorPattern = regexp.MustCompile(`stdout_cursor=(\d+) stderr_cursor=(\d+)`)
+)
+
+func startJob(t *testing.T, bt *bashTool, ctx context.Context, command string) string {
+	t.Helper()
+	result := invoke(t, bt, ctx, map[string]interface{}{"command": command, "run_in_background": true})
+	m := jobIDPattern.FindStringSubmatch(result)
+	require.NotNil(t, m, result)
+	return m[1]
+}
+
+func nextCursor(t *testing.T, result string) (int, int) {
+	t.Helper()
+	m := cursorPattern.FindStringSubmatch(result)
+	require.NotNil(t, m, result)
+	stdout, _ := strconv.Atoi(m[1])
+	stderr, _ := strconv.Atoi(m[2])
+	return stdout, stderr
+}
+
+func TestBashJobs_OutputCursorAndWait(t *testing.T) {
+	bt := newTestTool(t, func(c *Config) {
+		c.Mode = ModeDeny
+		c.WorkDir = t.TempDir()
+		c.MaxBackgroundJobs = 2
+	})
+	key := "test-jobs-" + t.Name()
+	defer CloseSession(key)
+	ctx := session.WithSessionKey(context.Background(), key)
+
+	id := startJob(t, bt, ctx, "echo first; echo warn >&2; sleep 0.5; echo second; exit 7")
+	entries, err := os.ReadDir(bt.config.WorkDir + "/.tool-output")
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	var first string
+	require.Eventually(t, func() bool {
+		first = invoke(t, bt, ctx, map[string]interface{}{"operation": OpOutput, "job_id": id})
+		return strings.Contains(first, "stdout:\nfirst")
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Contains(t, first, "Status: running")
+	stdoutCursor, stderrCursor := nextCursor(t, first)
+	assert.Equal(t, len("first\n"), stdoutCursor)
+
+	waited := invoke(t, bt, ctx, map[string]interface{}{
+		"operation": OpWait, "job_id": id, "stdout_cursor": stdoutCursor, "stderr_cursor": stderrCursor,
+	})
+	assert.Contains(t, waited, "exited (code 7")
+	assert.Contains(t, waited, "second")
+	assert.NotContains(t, waited, "stdout:\nfirst")
+
+	tail := invoke(t, bt, ctx, map[string]interface{}{"operation": OpOutput, "job_id": id, "tail": 1})
+	assert.Contains(t, tail, "stdout:\nsecond\n")
+	assert.Contains(t, tail, "stderr:\nwarn\n")
+
+	list := invoke(t, bt, ctx, map[string]interface{}{"operation": OpJobs})
+	assert.Contains(t, list, id)
+}
+
+func TestBashJobs_SignalAndLimit(t *testing.T) {
+	bt := newTestTool(t, func(c *Config) {
+		c.Mode = ModeDeny
+		c.WorkDir = t.TempDir()
+		c.MaxBackgroundJobs = 1
+	})
+	key := "test-signal-" + t.Name()
+	defer CloseSession(key)
+	ctx := session.WithSessionKey(context.Background(), key)
+
+	id := startJob(t, bt, ctx, "sleep 30 & sleep 30")
+	limited := invoke(t, bt, ctx, map[string]interface{}{"command": "sleep 1", "run_in_background": true})
+	assert.Contains(t, limited, "too many background jobs")
+
+	// 其他会话不可见
+	other := session.WithSessionKey(context.Background(), key+"-other")
+	assert.Contains(t, invoke(t, bt, other, map[string]interface{}{"operation": OpOutput, "job_id": id}), "not found")
+
+	assert.Contains(t, invoke(t, bt, ctx, map[string]interface{}{"operation": OpSignal, "job_id": id, "signal": "BOGUS"}), "unsupported signal")
+	start := time.Now()
+	signaled := invoke(t, bt, ctx, map[string]interface{}{"operation": OpSignal, "job_id": id})
+	assert.Contains(t, signaled, "Sent SIGTERM")
+	waited := invoke(t, bt, ctx, map[string]interface{}{"operation": OpWait, "job_id": id, "timeout": 5})
+	assert.Contains(t, waited, "terminated")
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// 任务结束后可以启动新任务
+	startJob(t, bt, ctx, "true")
+}
+
+func TestBashJobs_CloseSessionKillsJobs(t *testing.T) {
+	bt := newTestTool(t, func(c *Config) {
+		c.Mode = ModeDeny
+		c.WorkDir = t.TempDir()
+		c.MaxBackgroundJobs = 2
+	})
+	key := "test-close-" + t.Name()
+	ctx := session.WithSessionKey(context.Background(), key)
+
+	id := startJob(t, bt, ctx, "sleep 30")
+	job, ok := defaultJobs.get(key, id)
+	require.True(t, ok)
+
+	CloseSession(key)
+	assert.True(t, job.finished())
+	_, ok = defaultJobs.get(key, id)
+	assert.False(t, ok)
+}
+
+func TestBashJobs_SessionlessJobExpires(t *testing.T) {
+	bt := newTestTool(t, func(c *Config) {
+		c.Mode = ModeDeny
+		c.WorkDir = t.TempDir()
+		c.MaxBackgroundJobs = 2
+	})
+	bt.config.BackgroundJobTTL = 1
+	ctx := context.Background()
+
+	id := startJob(t, bt, ctx, "sleep 30")
+	job, ok := defaultJobs.get("", id)
+	require.True(t, ok)
+
+	require.Eventually(t, job.finished, 5*time.Second, 50*time.Millisecond)
+	require.Eventually(t, func() bool {
+		_, ok := defaultJobs.get("", id)
+		_, err := os.Stat(job.stdoutPath)
+		return !ok && os.IsNotExist(err)
+	}, 5*time.Second, 50*time.Millisecond)
+	_, err := os.Stat(job.stderrPath)
+	assert.True(t, os.IsNotExist(err))
+}
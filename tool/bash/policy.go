@@ -0,0 +1,760 @@
+package bash
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/rulego/rulego-components-ai/tool/common"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// 命令策略：sh/bash 命令先解析为 POSIX Shell AST，再逐条简单命令评估策略。
+// 子 Shell、$(...)、反引号、进程替换、here-doc、函数体中的命令都会被检查；
+// sudo/env/xargs/timeout 等包装命令、find -exec、eval 与 sh -c 的内层命令同样展开检查。
+// PowerShell/CMD 不是 POSIX Shell，仍使用 extractAllCommands 等字符串扫描。
+
+// PolicyAction 策略规则动作
+type PolicyAction string
+
+const (
+	// PolicyAllow 允许匹配的命令（allow 模式下等同于加入允许列表）
+	PolicyAllow PolicyAction = "allow"
+	// PolicyDeny 拒绝匹配的命令
+	PolicyDeny PolicyAction = "deny"
+
+	// maxPolicyDepth eval、sh -c 嵌套解析的最大深度
+	maxPolicyDepth = 8
+	// maxPolicyDirs 检查重定向时最多考虑的当前目录数，超过后按当前目录未知处理
+	maxPolicyDirs = 32
+)
+
+// DefaultNetworkTools 默认视为网络工具的命令
+var DefaultNetworkTools = []string{
+	"curl", "wget", "nc", "ncat", "netcat", "socat", "telnet", "ftp", "sftp",
+	"ssh", "scp", "rsync", "httpie", "http", "aria2c",
+}
+
+// PolicyRule 声明式命令策略规则，所有非空条件同时满足才匹配
+type PolicyRule struct {
+	// Name 规则名称，拒绝时在错误信息中说明
+	Name   string       `json:"name"`
+	Action PolicyAction `json:"action"`
+	// Commands 命令名，支持 path.Match 通配（如 "python*"）
+	Commands []string `json:"commands"`
+	// Subcommands 子命令，匹配命令的前几个非选项参数（如 "push"、"remote add"），任一匹配即可
+	Subcommands []string `json:"subcommands"`
+	// Args 参数正则，匹配以空格连接的参数，任一匹配即可
+	Args []string `json:"args"`
+	// Reason 拒绝原因，附在错误信息中
+	Reason string `json:"reason"`
+}
+
+// PolicyConfig 命令策略配置
+type PolicyConfig struct {
+	// Rules 按顺序匹配，第一条匹配的规则生效；deny 列表与 denyArgs 始终生效
+	Rules []PolicyRule `json:"rules"`
+	// DenyNetwork 禁止网络工具及 /dev/tcp、/dev/udp 重定向
+	DenyNetwork bool `json:"denyNetwork"`
+	// NetworkTools 网络工具列表，为空时使用 DefaultNetworkTools
+	NetworkTools []string `json:"networkTools"`
+}
+
+// compiledRule 预编译的策略规则
+type compiledRule struct {
+	PolicyRule
+	subcommands [][]string
+	args        []*regexp.Regexp
+}
+
+// compilePolicy 校验并预编译策略规则
+func compilePolicy(cfg PolicyConfig) ([]compiledRule, error) {
+	rules := make([]compiledRule, 0, len(cfg.Rules))
+	for i, r := range cfg.Rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rules[%d]", i)
+		}
+		if r.Action != PolicyAllow && r.Action != PolicyDeny {
+			return nil, fmt.Errorf("policy rule '%s': invalid action '%s'", r.Name, r.Action)
+		}
+		if len(r.Commands) == 0 {
+			return nil, fmt.Errorf("policy rule '%s': commands is required", r.Name)
+		}
+		for _, c := range r.Commands {
+			if _, err := path.Match(strings.ToLower(c), ""); err != nil {
+				return nil, fmt.Errorf("policy rule '%s': invalid command pattern '%s': %w", r.Name, c, err)
+			}
+		}
+		cr := compiledRule{PolicyRule: r}
+		for _, s := range r.Subcommands {
+			cr.subcommands = append(cr.subcommands, strings.Fields(s))
+		}
+		for _, a := range r.Args {
+			re, err := regexp.Compile(a)
+			if err != nil {
+				return nil, fmt.Errorf("policy rule '%s': invalid args pattern '%s': %w", r.Name, a, err)
+			}
+			cr.args = append(cr.args, re)
+		}
+		rules = append(rules, cr)
+	}
+	return rules, nil
+}
+
+// matches 判断规则是否匹配命令
+func (r *compiledRule) matches(c shellCommand) bool {
+	matched := false
+	for _, p := range r.Commands {
+		if ok, _ := path.Match(strings.ToLower(p), c.name); ok {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+	if len(r.subcommands) > 0 {
+		positional := c.positional()
+		matched = false
+		for _, sub := range r.subcommands {
+			if containsSubcommand(positional, sub, r.Action == PolicyDeny) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(r.args) > 0 {
+		joined := strings.Join(c.args, " ")
+		for _, re := range r.args {
+			if re.MatchString(joined) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// containsSubcommand 判断非选项参数是否包含子命令。
+// 选项的值（如 git -c k=v push 中的 k=v）无法与子命令区分：allow 规则只匹配开头，
+// deny 规则匹配任意连续位置，宁可多拒绝也不漏拒
+func containsSubcommand(positional, sub []string, anywhere bool) bool {
+	for i := 0; i+len(sub) <= len(positional); i++ {
+		if equalFold(positional[i:i+len(sub)], sub) {
+			return true
+		}
+		if !anywhere {
+			break
+		}
+	}
+	return false
+}
+
+// policyViolation 策略拒绝结果，说明命中的规则
+type policyViolation struct {
+	rule   string
+	detail string
+}
+
+func (v *policyViolation) Error() string {
+	return fmt.Sprintf("%s [rule: %s]", v.detail, v.rule)
+}
+
+// shellWord 命令中的一个单词；无法静态求值的单词保留源码
+type shellWord struct {
+	value   string
+	literal bool
+}
+
+// shellRedirect 重定向
+type shellRedirect struct {
+	op     string
+	target shellWord
+}
+
+// shellCommand AST 中提取的一条简单命令
+type shellCommand struct {
+	// name 规范化的命令名（小写、去路径与 .exe），仅有重定向的语句为空
+	name string
+	// raw 命令名原文
+	raw string
+	// dynamic 命令名无法静态确定（如 $cmd、$(echo rm)）
+	dynamic   bool
+	args      []string
+	redirects []shellRedirect
+	// via 命令的来源，如 "sudo"、"find -exec"、"eval"
+	via string
+	// chdir cd/pushd/popd 切换到的目录，无法静态确定（如 cd -、popd）时 literal 为 false
+	chdir *shellWord
+}
+
+// positional 返回非选项参数
+func (c shellCommand) positional() []string {
+	var out []string
+	for _, a := range c.args {
+		if !strings.HasPrefix(a, "-") {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// words 返回用于 denyArgs 匹配的单词序列（命令、参数与重定向）
+func (c shellCommand) words() []string {
+	words := append([]string{c.raw}, c.args...)
+	for _, r := range c.redirects {
+		words = append(words, r.op, r.target.value)
+	}
+	return words
+}
+
+// matchDeniedArgs 按单词匹配禁止模式：带空格的模式（如 "-rf /"）须与连续单词完全相同，
+// Windows 风格短参数（如 /s）须为独立单词，其他模式（如 /dev/sd）匹配单词的子串。
+// 引号内的文本是一个单词，echo 'rm -rf /' 不会误判
+func matchDeniedArgs(words []string, pattern string) bool {
+	tokens := strings.Fields(pattern)
+	if len(tokens) == 0 {
+		return false
+	}
+	if len(tokens) == 1 && !(strings.HasPrefix(pattern, "/") && len(pattern) <= 3) {
+		for _, w := range words {
+			if strings.Contains(w, pattern) {
+				return true
+			}
+		}
+		return false
+	}
+	return containsSubcommand(words, tokens, true)
+}
+
+// describe 返回用于错误信息的命令描述
+func (c shellCommand) describe() string {
+	if c.via != "" {
+		return fmt.Sprintf("'%s' (via %s)", c.name, c.via)
+	}
+	return fmt.Sprintf("'%s'", c.name)
+}
+
+// commandAnalyzer 把命令字符串解析为简单命令列表
+type commandAnalyzer struct {
+	commands []shellCommand
+	funcs    map[string]bool
+}
+
+// analyzeCommand 解析 sh/bash 命令，返回其中所有会执行的简单命令与声明的函数名
+func analyzeCommand(cmd string) ([]shellCommand, map[string]bool, error) {
+	a := &commandAnalyzer{funcs: make(map[string]bool)}
+	if err := a.parse(cmd, "", 0); err != nil {
+		return nil, nil, err
+	}
+	return a.commands, a.funcs, nil
+}
+
+// analyzeArgv 分析直接执行（不经过 Shell）的命令参数列表
+func analyzeArgv(argv []string) ([]shellCommand, map[string]bool, error) {
+	a := &commandAnalyzer{funcs: make(map[string]bool)}
+	words := make([]shellWord, len(argv))
+	for i, v := range argv {
+		words[i] = shellWord{value: v, literal: true}
+	}
+	if err := a.addCall(words, nil, "", 0); err != nil {
+		return nil, nil, err
+	}
+	return a.commands, a.funcs, nil
+}
+
+// parse 解析脚本并收集命令，via 标记内层脚本的来源（eval、sh -c）
+func (a *commandAnalyzer) parse(script, via string, depth int) error {
+	if depth > maxPolicyDepth {
+		return fmt.Errorf("command nesting too deep")
+	}
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(script), "")
+	if err != nil {
+		return err
+	}
+	var walkErr error
+	syntax.Walk(file, func(node syntax.Node) bool {
+		if walkErr != nil {
+			return false
+		}
+		switch n := node.(type) {
+		case *syntax.FuncDecl:
+			a.funcs[strings.ToLower(n.Name.Value)] = true
+		case *syntax.Stmt:
+			redirects := convertRedirects(n.Redirs)
+			switch c := n.Cmd.(type) {
+			case *syntax.CallExpr:
+				if len(c.Args) > 0 {
+					words := make([]shellWord, len(c.Args))
+					for i, w := range c.Args {
+						words[i] = wordValue(w)
+					}
+					walkErr = a.addCall(words, redirects, via, depth)
+					return walkErr == nil
+				}
+			case *syntax.DeclClause:
+				a.commands = append(a.commands, shellCommand{name: c.Variant.Value, raw: c.Variant.Value, redirects: redirects, via: via})
+				return true
+			}
+			if len(redirects) > 0 {
+				a.commands = append(a.commands, shellCommand{redirects: redirects, via: via})
+			}
+		}
+		return true
+	})
+	return walkErr
+}
+
+// addCall 登记一条简单命令，并展开包装命令、find -exec、eval 与 sh -c 的内层命令
+func (a *commandAnalyzer) addCall(words []shellWord, redirects []shellRedirect, via string, depth int) error {
+	c := shellCommand{raw: words[0].value, redirects: redirects, via: via}
+	if words[0].literal {
+		c.name = normalizeCommandName(words[0].value)
+	} else {
+		c.name = words[0].value
+		c.dynamic = true
+	}
+	for _, w := range words[1:] {
+		c.args = append(c.args, w.value)
+	}
+	switch c.name {
+	case "cd", "pushd", "popd":
+		c.chdir = chdirTarget(c.name, words[1:])
+	}
+	a.commands = append(a.commands, c)
+	if c.dynamic {
+		return nil
+	}
+
+	rest := words[1:]
+	switch c.name {
+	case "eval":
+		script, ok := joinLiteral(rest)
+		if !ok {
+			a.commands = append(a.commands, dynamicCommand(rest, "eval"))
+			return nil
+		}
+		return a.parse(script, "eval", depth+1)
+	case "sh", "bash", "zsh", "dash", "ksh":
+		if i := shellScriptArg(rest); i >= 0 {
+			if !rest[i].literal {
+				a.commands = append(a.commands, dynamicCommand(rest[i:i+1], c.name+" -c"))
+				return nil
+			}
+			return a.parse(rest[i].value, c.name+" -c", depth+1)
+		}
+	case "find":
+		for i := 0; i < len(rest); i++ {
+			switch rest[i].value {
+			case "-exec", "-execdir", "-ok", "-okdir":
+				end := i + 1
+				for end < len(rest) && rest[end].value != ";" && rest[end].value != "+" {
+					end++
+				}
+				if end > i+1 {
+					if err := a.addCall(rest[i+1:end], nil, "find "+rest[i].value, depth+1); err != nil {
+						return err
+					}
+				}
+				i = end
+			}
+		}
+	default:
+		if skip, ok := commandWrappers[c.name]; ok {
+			if inner := skip(rest); len(inner) > 0 {
+				return a.addCall(inner, nil, c.name, depth+1)
+			}
+		}
+	}
+	return nil
+}
+
+// chdirTarget 返回 cd/pushd/popd 切换到的目录；无参数（$HOME）、cd -、pushd +N 与 popd 无法静态确定
+func chdirTarget(name string, args []shellWord) *shellWord {
+	for len(args) > 0 && strings.HasPrefix(args[0].value, "-") && args[0].value != "-" {
+		if args[0].value == "--" {
+			args = args[1:]
+			break
+		}
+		args = args[1:]
+	}
+	if name == "popd" || len(args) == 0 {
+		return &shellWord{value: name}
+	}
+	target := args[0]
+	if target.value == "-" || strings.HasPrefix(target.value, "~") || (name == "pushd" && strings.HasPrefix(target.value, "+")) {
+		target.literal = false
+	}
+	return &target
+}
+
+// dynamicCommand 构造无法静态解析的内层命令
+func dynamicCommand(words []shellWord, via string) shellCommand {
+	values := make([]string, len(words))
+	for i, w := range words {
+		values[i] = w.value
+	}
+	text := strings.Join(values, " ")
+	return shellCommand{name: text, raw: text, dynamic: true, via: via}
+}
+
+// commandWrappers 包装命令：返回被包装的内层命令（跳过包装命令自身的选项）
+var commandWrappers = map[string]func(args []shellWord) []shellWord{
+	"sudo":    skipOptions("u", "g", "h", "p", "C", "D", "r", "t", "U", "T"),
+	"doas":    skipOptions("u", "C"),
+	"nohup":   skipOptions(),
+	"nice":    skipOptions("n"),
+	"ionice":  skipOptions("c", "n", "p"),
+	"stdbuf":  skipOptions("i", "o", "e"),
+	"command": skipCommandBuiltin,
+	"builtin": skipOptions(),
+	"exec":    skipOptions("a"),
+	"xargs":   skipOptions("a", "d", "E", "e", "I", "i", "L", "l", "n", "P", "s", "-arg-file", "-delimiter", "-max-args", "-max-procs", "-replace"),
+	"watch":   skipOptions("n", "d", "-interval"),
+	"strace":  skipOptions("e", "o", "p", "s", "u"),
+	"env": func(args []shellWord) []shellWord {
+		args = skipOptions("u", "C", "S", "-unset", "-chdir")(args)
+		for len(args) > 0 && args[0].literal && strings.Contains(args[0].value, "=") {
+			args = args[1:]
+		}
+		return args
+	},
+	"timeout": func(args []shellWord) []shellWord {
+		args = skipOptions("s", "k", "-signal", "-kill-after")(args)
+		if len(args) > 0 {
+			args = args[1:] // 时长
+		}
+		return args
+	},
+}
+
+// skipOptions 返回跳过选项的函数，withValue 为带值的短选项（如 "u"）或长选项（如 "-user"）
+func skipOptions(withValue ...string) func(args []shellWord) []shellWord {
+	return func(args []shellWord) []shellWord {
+		for len(args) > 0 {
+			v := args[0].value
+			if v == "--" {
+				return args[1:]
+			}
+			if !strings.HasPrefix(v, "-") || v == "-" {
+				return args
+			}
+			args = args[1:]
+			opt := strings.TrimPrefix(v, "-")
+			if strings.Contains(opt, "=") {
+				continue
+			}
+			for _, w := range withValue {
+				// 短选项值可以紧跟在后面（如 -n10、-oL），只有单独出现时才消耗下一个参数
+				if opt == w && len(args) > 0 {
+					args = args[1:]
+					break
+				}
+			}
+		}
+		return args
+	}
+}
+
+// skipCommandBuiltin command 内建：-v/-V 只查询不执行
+func skipCommandBuiltin(args []shellWord) []shellWord {
+	for _, a := range args {
+		if a.value == "-v" || a.value == "-V" {
+			return nil
+		}
+	}
+	return skipOptions()(args)
+}
+
+// shellScriptArg 返回 sh -c 脚本参数的位置，不是 -c 调用时返回 -1
+func shellScriptArg(args []shellWord) int {
+	for i, a := range args {
+		v := a.value
+		if !strings.HasPrefix(v, "-") || strings.HasPrefix(v, "--") {
+			return -1
+		}
+		if strings.Contains(v, "c") && i+1 < len(args) {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// joinLiteral 以空格连接单词，任一单词不是字面值时返回 false
+func joinLiteral(words []shellWord) (string, bool) {
+	values := make([]string, len(words))
+	for i, w := range words {
+		if !w.literal {
+			return "", false
+		}
+		values[i] = w.value
+	}
+	return strings.Join(values, " "), true
+}
+
+// convertRedirects 转换 AST 重定向，here-doc 正文由 Walk 单独检查
+func convertRedirects(redirs []*syntax.Redirect) []shellRedirect {
+	var out []shellRedirect
+	for _, r := range redirs {
+		switch r.Op {
+		case syntax.Hdoc, syntax.DashHdoc, syntax.WordHdoc, syntax.DplIn, syntax.DplOut:
+			continue
+		}
+		out = append(out, shellRedirect{op: r.Op.String(), target: wordValue(r.Word)})
+	}
+	return out
+}
+
+// wordValue 求单词的字面值（去引号与转义）；含展开或替换时返回源码并标记为非字面值
+func wordValue(w *syntax.Word) shellWord {
+	var sb strings.Builder
+	if literalParts(&sb, w.Parts, false) {
+		return shellWord{value: sb.String(), literal: true}
+	}
+	sb.Reset()
+	_ = syntax.NewPrinter().Print(&sb, w)
+	return shellWord{value: sb.String()}
+}
+
+// literalParts 拼接字面值片段，遇到展开、替换等返回 false
+func literalParts(sb *strings.Builder, parts []syntax.WordPart, quoted bool) bool {
+	for _, p := range parts {
+		switch p := p.(type) {
+		case *syntax.Lit:
+			sb.WriteString(unescapeLit(p.Value, quoted))
+		case *syntax.SglQuoted:
+			if p.Dollar {
+				return false
+			}
+			sb.WriteString(p.Value)
+		case *syntax.DblQuoted:
+			if p.Dollar || !literalParts(sb, p.Parts, true) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// unescapeLit 去掉字面值中的反斜杠转义；双引号内只转义 $ ` " \ 和换行
+func unescapeLit(s string, quoted bool) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			next := s[i+1]
+			if next == '\n' {
+				i++
+				continue
+			}
+			if !quoted || strings.IndexByte("$`\"\\", next) >= 0 {
+				sb.WriteByte(next)
+				i++
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// normalizeCommandName 规范化命令名：去路径、Windows 下去 .exe 后缀、小写
+func normalizeCommandName(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 && i < len(name)-1 {
+		name = name[i+1:]
+	}
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(strings.ToLower(name), ".exe")
+	}
+	return strings.ToLower(name)
+}
+
+func equalFold(a, b []string) bool {
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPolicy 按 Shell AST 评估命令策略，通过时返回 nil；argv 非空时命令不经过 Shell 直接执行。
+// 先对所有命令评估拒绝条件（声明式 deny 规则、deny 列表、网络工具、denyArgs、重定向路径），
+// 再检查 allow 模式的允许列表，使被包装的危险命令报告真实的拒绝原因。
+// cwd 为命令开始执行时的当前目录（如会话 Shell 保留的目录），为空时即 workDir
+func (t *bashTool) checkPolicy(fullCommand string, argv []string, workDir, cwd string) *policyViolation {
+	var commands []shellCommand
+	var funcs map[string]bool
+	var err error
+	if len(argv) > 0 {
+		commands, funcs, err = analyzeArgv(argv)
+	} else {
+		commands, funcs, err = analyzeCommand(fullCommand)
+	}
+	if err != nil {
+		return &policyViolation{rule: "parse", detail: fmt.Sprintf("cannot parse command: %v", err)}
+	}
+	if cwd == "" {
+		cwd = workDir
+	}
+	dirs := commandDirs(commands, cwd)
+	allowed := make([]bool, len(commands))
+	for i, c := range commands {
+		v, explicitAllow := t.checkDenied(c, workDir, dirs)
+		if v != nil {
+			return v
+		}
+		allowed[i] = explicitAllow
+	}
+	if t.config.Mode != ModeAllow {
+		return nil
+	}
+	for i, c := range commands {
+		if c.name == "" || allowed[i] || funcs[c.name] {
+			continue
+		}
+		if c.dynamic {
+			return &policyViolation{rule: "allow", detail: fmt.Sprintf("command %s cannot be resolved statically", c.describe())}
+		}
+		if len(t.config.Allow) > 0 && !common.ContainsIgnoreCase(t.config.Allow, c.name) {
+			return &policyViolation{rule: "allow", detail: fmt.Sprintf("command %s not in allow list. Allowed: %v", c.describe(), t.config.Allow)}
+		}
+	}
+	return nil
+}
+
+// commandDirs 返回命令执行期间可能的当前目录：起始目录与 cd/pushd 切换到的目录。
+// 条件执行与循环使切换顺序无法静态确定，相对目录按之前所有可能的目录解析；
+// 存在无法静态确定的切换时返回 nil
+func commandDirs(commands []shellCommand, cwd string) []string {
+	dirs := []string{cwd}
+	for _, c := range commands {
+		if c.chdir == nil {
+			continue
+		}
+		if !c.chdir.literal {
+			return nil
+		}
+		target := filepath.FromSlash(c.chdir.value)
+		if filepath.IsAbs(target) {
+			dirs = appendDir(dirs, filepath.Clean(target))
+		} else {
+			for _, dir := range dirs {
+				dirs = appendDir(dirs, filepath.Join(dir, target))
+			}
+		}
+		if len(dirs) > maxPolicyDirs {
+			return nil
+		}
+	}
+	return dirs
+}
+
+// appendDir 追加不重复的目录
+func appendDir(dirs []string, dir string) []string {
+	for _, d := range dirs {
+		if d == dir {
+			return dirs
+		}
+	}
+	return append(dirs, dir)
+}
+
+// checkDenied 评估单条命令的拒绝条件，并返回是否被声明式 allow 规则显式放行
+// dirs 为 commandDirs 返回的可能的当前目录，用于解析相对重定向目标
+func (t *bashTool) checkDenied(c shellCommand, workDir string, dirs []string) (*policyViolation, bool) {
+	explicitAllow := false
+	if c.name != "" && !c.dynamic {
+		for i := range t.rules {
+			r := &t.rules[i]
+			if !r.matches(c) {
+				continue
+			}
+			if r.Action == PolicyDeny {
+				detail := fmt.Sprintf("command %s denied by policy", c.describe())
+				if r.Reason != "" {
+					detail += ": " + r.Reason
+				}
+				return &policyViolation{rule: r.Name, detail: detail}, false
+			}
+			explicitAllow = true
+			break
+		}
+		if common.ContainsIgnoreCase(t.config.Deny, c.name) {
+			return &policyViolation{rule: "deny", detail: fmt.Sprintf("command %s is denied", c.describe())}, false
+		}
+		if t.config.Policy.DenyNetwork && common.ContainsIgnoreCase(t.networkTools(), c.name) {
+			return &policyViolation{rule: "network", detail: fmt.Sprintf("command %s accesses the network", c.describe())}, false
+		}
+	}
+	words := c.words()
+	for _, denied := range t.config.DenyArgs {
+		if matchDeniedArgs(words, denied) {
+			return &policyViolation{rule: "denyArgs", detail: fmt.Sprintf("command contains denied pattern '%s'", denied)}, false
+		}
+	}
+	for _, r := range c.redirects {
+		if v := t.checkRedirect(r, workDir, dirs); v != nil {
+			return v, false
+		}
+	}
+	return nil, explicitAllow
+}
+
+// checkRedirect 检查重定向目标：网络设备与路径安全策略
+// 相对目标按每个可能的当前目录解析，均须通过路径安全策略
+func (t *bashTool) checkRedirect(r shellRedirect, workDir string, dirs []string) *policyViolation {
+	target := r.target.value
+	if t.config.Policy.DenyNetwork && (strings.HasPrefix(target, "/dev/tcp/") || strings.HasPrefix(target, "/dev/udp/")) {
+		return &policyViolation{rule: "network", detail: fmt.Sprintf("redirection '%s %s' accesses the network", r.op, target)}
+	}
+	if t.config.PathSecurity == nil {
+		return nil
+	}
+	switch target {
+	case "/dev/null", "/dev/stdin", "/dev/stdout", "/dev/stderr", "/dev/tty":
+		return nil
+	}
+	if !r.target.literal {
+		return &policyViolation{rule: "pathSecurity", detail: fmt.Sprintf("redirection target '%s' cannot be resolved statically", target)}
+	}
+	resolver, err := common.NewSecurePathResolver(workDir, *t.config.PathSecurity)
+	if err != nil {
+		return &policyViolation{rule: "pathSecurity", detail: fmt.Sprintf("redirection target '%s': %v", target, err)}
+	}
+	path := filepath.FromSlash(target)
+	paths := []string{path}
+	if !filepath.IsAbs(path) {
+		if dirs == nil {
+			return &policyViolation{rule: "pathSecurity", detail: fmt.Sprintf("redirection target '%s' cannot be resolved: the working directory is changed dynamically", target)}
+		}
+		paths = paths[:0]
+		for _, dir := range dirs {
+			paths = append(paths, filepath.Join(dir, path))
+		}
+	}
+	for _, p := range paths {
+		if _, err := resolver.Resolve(p); err != nil {
+			return &policyViolation{rule: "pathSecurity", detail: fmt.Sprintf("redirection '%s %s' not allowed: %v", r.op, target, err)}
+		}
+	}
+	return nil
+}
+
+// networkTools 返回网络工具列表
+func (t *bashTool) networkTools() []string {
+	if len(t.config.Policy.NetworkTools) > 0 {
+		return t.config.Policy.NetworkTools
+	}
+	return DefaultNetworkTools
+}
@@ -0,0 +1,199 @@
+package bash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rulego/rulego-components-ai/tool/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func commandNames(t *testing.T, command string) []string {
+	t.Helper()
+	commands, _, err := analyzeCommand(command)
+	require.NoError(t, err, command)
+	var names []string
+	for _, c := range commands {
+		if c.name != "" {
+			names = append(names, c.name)
+		}
+	}
+	return names
+}
+
+func TestAnalyzeCommand_NestedCommands(t *testing.T) {
+	testCases := []struct {
+		name     string
+		command  string
+		expected []string
+	}{
+		{"pipeline and lists", "cat a | grep x && echo ok || true; ls &", []string{"cat", "grep", "echo", "true", "ls"}},
+		{"subshell", "(cd /tmp && rm x)", []string{"cd", "rm"}},
+		{"command substitution", "echo $(rm -rf x)", []string{"echo", "rm"}},
+		{"backtick", "echo `whoami`", []string{"echo", "whoami"}},
+		{"assignment only", "X=$(curl example.com)", []string{"curl"}},
+		{"process substitution", "diff <(sort a) <(sort b)", []string{"diff", "sort", "sort"}},
+		{"here-doc substitution", "cat <<EOF\n$(reboot)\nEOF", []string{"cat", "reboot"}},
+		{"env prefix", "FOO=1 BAR=2 go test", []string{"go"}},
+		{"env wrapper", "env -u HOME FOO=1 rm x", []string{"env", "rm"}},
+		{"sudo", "sudo -u root chmod 777 x", []string{"sudo", "chmod"}},
+		{"timeout", "timeout -s KILL 5 dd if=/dev/zero", []string{"timeout", "dd"}},
+		{"xargs", "ls | xargs -n 1 -I {} chown root {}", []string{"ls", "xargs", "chown"}},
+		{"find exec", "find . -name '*.go' -exec chmod 600 {} \\; -exec rm {} +", []string{"find", "chmod", "rm"}},
+		{"eval", "eval 'shutdown now'", []string{"eval", "shutdown"}},
+		{"sh -c", "bash -lc \"mkfs /dev/sda; echo done\"", []string{"bash", "mkfs", "echo"}},
+		{"nested sh -c", "sh -c 'sh -c \"su root\"'", []string{"sh", "sh", "su"}},
+		{"quoted command path", "'/usr/bin/RM' x", []string{"rm"}},
+		{"function body", "f() { reboot; }; f", []string{"reboot", "f"}},
+		{"declaration", "export A=1", []string{"export"}},
+		{"command -v", "command -v rm", []string{"command"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, commandNames(t, tc.command))
+		})
+	}
+}
+
+func TestAnalyzeCommand_Words(t *testing.T) {
+	commands, _, err := analyzeCommand(`rm -rf "/" $HOME 'a b' c\ d`)
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	assert.Equal(t, []string{"-rf", "/", "$HOME", "a b", "c d"}, commands[0].args)
+
+	commands, _, err = analyzeCommand("$CMD -rf /")
+	require.NoError(t, err)
+	assert.True(t, commands[0].dynamic)
+
+	_, _, err = analyzeCommand("echo 'unterminated")
+	assert.Error(t, err)
+}
+
+func checkCommand(bt *bashTool, command string) string {
+	return bt.checkSecurity(context.Background(), OperationParams{Command: command}, command)
+}
+
+func TestPolicy_BypassesAreDenied(t *testing.T) {
+	bt := newTestTool(t, func(c *Config) { c.ShellPath = "/bin/sh" })
+	testCases := []struct {
+		command string
+		message string
+	}{
+		{"echo $(chmod 777 x)", "command 'chmod' is denied [rule: deny]"},
+		{"(su root)", "command 'su' is denied"},
+		{"find . -exec chown root {} \\;", "'chown' (via find -exec) is denied"},
+		{"ls | xargs -n 1 dd", "command 'dd' (via xargs) is denied"},
+		{"eval 'reboot'", "command 'reboot' (via eval) is denied"},
+		{"sh -c 'ls'", "command 'sh' not in allow list"},
+		{"cat <<EOF\n$(shutdown)\nEOF", "command 'shutdown' is denied"},
+		{"rm -rf \"/\"", "command contains denied pattern '-rf /' [rule: denyArgs]"},
+		{"$CMD x", "cannot be resolved statically"},
+		{"echo 'unterminated", "cannot parse command"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.command, func(t *testing.T) {
+			result := checkCommand(bt, tc.command)
+			assert.Contains(t, result, common.ErrorMessages[common.ErrCodePermissionDenied])
+			assert.Contains(t, result, tc.message)
+		})
+	}
+
+	// 字符串中的危险字样、函数调用、echo 参数不应误判
+	for _, command := range []string{
+		"echo 'rm -rf / is dangerous'",
+		"grep -rn 'chmod' .",
+		"greet() { echo hi; }; greet",
+		"cat a 2>&1 | head -1 > out.txt",
+		"FOO=1 go version",
+	} {
+		assert.Empty(t, checkCommand(bt, command), command)
+	}
+}
+
+func TestPolicy_Rules(t *testing.T) {
+	bt := newTestTool(t, func(c *Config) {
+		c.ShellPath = "/bin/sh"
+		c.Policy.Rules = []PolicyRule{
+			{Name: "no-push", Action: PolicyDeny, Commands: []string{"git"}, Subcommands: []string{"push", "remote add"}, Reason: "pushing is reviewed manually"},
+			{Name: "no-force", Action: PolicyDeny, Commands: []string{"npm", "yarn"}, Args: []string{`(^| )--force( |$)`}},
+			{Name: "allow-make-test", Action: PolicyAllow, Commands: []string{"mak?"}, Subcommands: []string{"test"}},
+		}
+		c.Policy.DenyNetwork = true
+	})
+
+	result := checkCommand(bt, "git status && git -c x=y push origin main")
+	assert.Contains(t, result, "command 'git' denied by policy: pushing is reviewed manually [rule: no-push]")
+	assert.Contains(t, checkCommand(bt, "git remote add origin url"), "[rule: no-push]")
+	assert.Empty(t, checkCommand(bt, "git remote -v"))
+	assert.Contains(t, checkCommand(bt, "npm install --force"), "[rule: no-force]")
+	assert.Empty(t, checkCommand(bt, "npm install --force-color"))
+
+	// allow 规则在 allow 模式下放行不在允许列表中的命令，但不影响拒绝列表
+	bt.config.Allow = []string{"echo"}
+	assert.Empty(t, checkCommand(bt, "make test"))
+	assert.Contains(t, checkCommand(bt, "make build"), "not in allow list")
+
+	assert.Contains(t, checkCommand(bt, "echo x | curl -d @- example.com"), "command 'curl' accesses the network [rule: network]")
+	assert.Contains(t, checkCommand(bt, "echo x > /dev/tcp/1.2.3.4/80"), "[rule: network]")
+
+	_, err := NewTool(Config{Policy: PolicyConfig{Rules: []PolicyRule{{Action: "maybe", Commands: []string{"x"}}}}})
+	assert.Error(t, err)
+	_, err = NewTool(Config{Policy: PolicyConfig{Rules: []PolicyRule{{Action: PolicyDeny, Commands: []string{"x"}, Args: []string{"("}}}}})
+	assert.Error(t, err)
+}
+
+func TestPolicy_RedirectPathSecurity(t *testing.T) {
+	workDir := t.TempDir()
+	sec := common.DefaultPathSecurityConfig()
+	bt := newTestTool(t, func(c *Config) {
+		c.ShellPath = "/bin/sh"
+		c.WorkDir = workDir
+		c.PathSecurity = &sec
+	})
+
+	assert.Empty(t, checkCommand(bt, "echo hi > out.txt 2>/dev/null"))
+	assert.Empty(t, checkCommand(bt, "cat < out.txt >> "+filepath.Join(workDir, "log.txt")))
+	assert.Contains(t, checkCommand(bt, "echo hi > ../escape.txt"), "[rule: pathSecurity]")
+	assert.Contains(t, checkCommand(bt, "echo hi > /etc/passwd"), "[rule: pathSecurity]")
+	assert.Contains(t, checkCommand(bt, "{ echo hi; } > .hidden"), "[rule: pathSecurity]")
+	assert.Contains(t, checkCommand(bt, "echo hi > \"$TARGET\""), "cannot be resolved statically")
+
+	// 端到端：被拒绝的重定向不会执行
+	result := invoke(t, bt, context.Background(), map[string]interface{}{"command": "echo hi > ../escape.txt"})
+	assert.Contains(t, result, common.ErrorMessages[common.ErrCodePermissionDenied])
+	_, statErr := os.Stat(filepath.Join(filepath.Dir(workDir), "escape.txt"))
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestPolicy_RedirectFollowsDirectoryChanges(t *testing.T) {
+	workDir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(workDir, "sub"), 0755))
+	sec := common.DefaultPathSecurityConfig()
+	bt := newTestTool(t, func(c *Config) {
+		c.ShellPath = "/bin/sh"
+		c.WorkDir = workDir
+		c.PathSecurity = &sec
+	})
+
+	// 相对目标按 cd 之后的目录解析
+	assert.Contains(t, checkCommand(bt, "cd /etc && echo x > passwd"), "[rule: pathSecurity]")
+	assert.Contains(t, checkCommand(bt, "false && cd /etc; echo x > passwd"), "[rule: pathSecurity]")
+	assert.Contains(t, checkCommand(bt, "(cd /etc; echo x > passwd)"), "[rule: pathSecurity]")
+	assert.Contains(t, checkCommand(bt, "cd sub && cd ../.. && echo x > escape.txt"), "[rule: pathSecurity]")
+	assert.Empty(t, checkCommand(bt, "cd sub && echo x > out.txt"))
+	assert.Empty(t, checkCommand(bt, "cd /etc && echo x > "+filepath.Join(workDir, "out.txt")))
+
+	// 无法静态确定的目录切换之后，相对目标一律拒绝
+	assert.Contains(t, checkCommand(bt, "cd \"$DIR\" && echo x > out.txt"), "working directory is changed dynamically")
+	assert.Contains(t, checkCommand(bt, "cd - && echo x > out.txt"), "working directory is changed dynamically")
+	assert.Contains(t, checkCommand(bt, "pushd sub && popd && echo x > out.txt"), "working directory is changed dynamically")
+
+	// 起始目录为会话 Shell 保留的当前目录
+	v := bt.checkPolicy("echo x > passwd", nil, workDir, "/etc")
+	require.NotNil(t, v)
+	assert.Equal(t, "pathSecurity", v.rule)
+	assert.Nil(t, bt.checkPolicy("echo x > out.txt", nil, workDir, filepath.Join(workDir, "sub")))
+}
@@ -0,0 +1,492 @@
+package bash
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rulego/rulego-components-ai/session"
+)
+
+// 会话模式：同一会话的命令在同一个常驻 Shell 中执行。
+// 命令通过 stdin 以 eval 方式送入 Shell，执行后分别向 stdout/stderr 打印带随机数的结束标记，
+// 读取端按标记切分出本次命令的输出、退出码和当前目录。超时只杀 Shell 的子进程（正在执行的命令），
+// Shell 本身及其状态保留；命令不响应中断或 Shell 退出时，下次调用自动重启 Shell。
+
+const (
+	// sessionInterruptGrace 中断命令后等待结束标记的时间，超过则重启 Shell
+	sessionInterruptGrace = 2 * time.Second
+	// sessionExitDrain Shell 退出后等待剩余输出的时间
+	sessionExitDrain = 200 * time.Millisecond
+)
+
+// errShellExited 常驻 Shell 已退出
+var errShellExited = errors.New("shell session exited")
+
+// shellSessionState 本次调用使用的 Shell 状态，写入结果头部
+type shellSessionState string
+
+const (
+	shellStarted   shellSessionState = "started"
+	shellReused    shellSessionState = "reused"
+	shellRestarted shellSessionState = "restarted (previous cwd/env state was lost)"
+)
+
+// shellPool 按会话管理常驻 Shell
+type shellPool struct {
+	mu       sync.Mutex
+	sessions map[string]*shellSession
+}
+
+// defaultShellPool 进程内共享的常驻 Shell 池
+var defaultShellPool = &shellPool{sessions: make(map[string]*shellSession)}
+
+// CloseSession 关闭会话的常驻 Shell（含其启动的所有子进程）并终止会话的后台任务
+// 会话结束时由 session.OnSessionEnd 回调自动调用
+func CloseSession(sessionKey string) {
+	defaultShellPool.closeSession(sessionKey)
+	defaultJobs.closeSession(sessionKey)
+}
+
+// acquire 获取可用的常驻 Shell，不存在或已退出时通过 start 启动新的 Shell
+func (p *shellPool) acquire(key string, start func() (*shellSession, error)) (*shellSession, shellSessionState, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	existing, ok := p.sessions[key]
+	if ok && existing.alive() {
+		return existing, shellReused, nil
+	}
+	s, err := start()
+	if err != nil {
+		return nil, "", err
+	}
+	p.sessions[key] = s
+	if ok {
+		return s, shellRestarted, nil
+	}
+	return s, shellStarted, nil
+}
+
+// cwd 返回常驻 Shell 的当前目录，Shell 不存在或已退出时返回空字符串
+func (p *shellPool) cwd(key string) string {
+	p.mu.Lock()
+	s, ok := p.sessions[key]
+	p.mu.Unlock()
+	if !ok || !s.alive() {
+		return ""
+	}
+	return s.cwd()
+}
+
+// closeSession 关闭并移除会话的所有常驻 Shell
+func (p *shellPool) closeSession(sessionKey string) {
+	p.mu.Lock()
+	var closing []*shellSession
+	for key, s := range p.sessions {
+		if s.sessionKey == sessionKey {
+			closing = append(closing, s)
+			delete(p.sessions, key)
+		}
+	}
+	p.mu.Unlock()
+	for _, s := range closing {
+		s.close()
+	}
+}
+
+// outputCapture 持续读取 Shell 输出管道并缓存
+type outputCapture struct {
+	mu     sync.Mutex
+	buf    []byte
+	notify chan struct{}
+	eof    chan struct{}
+}
+
+func newOutputCapture() *outputCapture {
+	return &outputCapture{notify: make(chan struct{}, 1), eof: make(chan struct{})}
+}
+
+// read 读取管道直到 EOF（Shell 及其所有子进程关闭管道）
+func (c *outputCapture) read(r io.ReadCloser) {
+	defer close(c.eof)
+	defer r.Close()
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(chunk)
+		if n > 0 {
+			c.mu.Lock()
+			c.buf = append(c.buf, chunk[:n]...)
+			c.mu.Unlock()
+			select {
+			case c.notify <- struct{}{}:
+			default:
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// cut 查找结束标记，找到完整标记行时返回标记前的输出和标记后到行尾的状态，并丢弃已消费的部分
+func (c *outputCapture) cut(marker string) ([]byte, string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := bytes.Index(c.buf, []byte(marker))
+	if i < 0 {
+		return nil, "", false
+	}
+	rest := c.buf[i+len(marker):]
+	nl := bytes.IndexByte(rest, '\n')
+	if nl < 0 {
+		return nil, "", false
+	}
+	out := append([]byte(nil), c.buf[:i]...)
+	status := string(rest[:nl])
+	c.buf = append([]byte(nil), rest[nl+1:]...)
+	return out, status, true
+}
+
+// drain 取出全部已缓存的输出
+func (c *outputCapture) drain() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := c.buf
+	c.buf = nil
+	return out
+}
+
+// shellSession 常驻 Shell
+type shellSession struct {
+	sessionKey string
+	mu         sync.Mutex // 串行执行命令
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	stdout     *outputCapture
+	stderr     *outputCapture
+	done       chan struct{} // Shell 进程退出
+	nonce      string
+	seq        int
+	idle       time.Duration
+	idleTimer  *time.Timer
+	// warnings 启动时的执行隔离警告
+	warnings []string
+	// dirMu 保护 dir；run 执行期间持有 mu，读取当前目录不应等待命令结束
+	dirMu sync.Mutex
+	// dir 最近一次命令结束后 Shell 的当前目录
+	dir string
+}
+
+// startShellSession 启动常驻 Shell，从 stdin 读取命令
+// prepare 在启动前调整命令（如执行隔离），返回的警告记录在会话上
+func startShellSession(sessionKey, shell string, args []string, dir string, env []string, idle time.Duration, prepare func(cmd *exec.Cmd) []string) (*shellSession, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(shell, args...)
+	cmd.Dir = dir
+	cmd.Env = env
+	// 独立进程组：关闭会话时连同后台子进程一起结束
+	setSysProcAttr(cmd)
+	var warnings []string
+	if prepare != nil {
+		warnings = prepare(cmd)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	// 使用 os.Pipe 而非 StdoutPipe：Wait 不会关闭读端，Shell 退出后仍可读完剩余输出
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		_ = stdoutR.Close()
+		_ = stdoutW.Close()
+		return nil, err
+	}
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+	err = cmd.Start()
+	_ = stdoutW.Close()
+	_ = stderrW.Close()
+	if err != nil {
+		_ = stdoutR.Close()
+		_ = stderrR.Close()
+		return nil, fmt.Errorf("启动会话 Shell 失败: %w", err)
+	}
+
+	s := &shellSession{
+		sessionKey: sessionKey,
+		cmd:        cmd,
+		stdin:      stdin,
+		stdout:     newOutputCapture(),
+		stderr:     newOutputCapture(),
+		done:       make(chan struct{}),
+		nonce:      hex.EncodeToString(nonce),
+		idle:       idle,
+		warnings:   warnings,
+		dir:        dir,
+	}
+	go s.stdout.read(stdoutR)
+	go s.stderr.read(stderrR)
+	go func() {
+		_ = cmd.Wait()
+		close(s.done)
+	}()
+	s.idleTimer = time.AfterFunc(idle, s.close)
+	return s, nil
+}
+
+// alive 返回 Shell 是否仍在运行
+func (s *shellSession) alive() bool {
+	select {
+	case <-s.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// close 结束 Shell 及其进程组，可与 run 并发调用
+func (s *shellSession) close() {
+	if !s.alive() {
+		return
+	}
+	killProcessGroup(s.cmd.Process.Pid)
+	_ = s.stdin.Close()
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+	}
+}
+
+// markers 生成本次命令的 stdout/stderr 结束标记
+func (s *shellSession) markers() (string, string) {
+	prefix := "__RULEGO_SHELL_" + s.nonce + "_" + strconv.Itoa(s.seq)
+	return prefix + "_OUT__", prefix + "_ERR__"
+}
+
+// script 生成送入 Shell 的脚本
+// 命令经 command eval 执行：语法错误只影响本次命令，不会吞掉结束标记
+// （command 前缀使 sh 不因特殊内建命令出错而退出）；stdin 重定向到 /dev/null，避免命令读取后续脚本。
+// 标记拆成两段打印，set -x 等回显不会出现完整标记。
+func (s *shellSession) script(command, cdDir string) string {
+	outMarker, errMarker := s.markers()
+	var b strings.Builder
+	if cdDir != "" {
+		b.WriteString("cd -- " + shellQuote(cdDir) + " && ")
+	}
+	b.WriteString("command eval " + shellQuote(command) + " </dev/null\n")
+	fmt.Fprintf(&b, "printf '%%s%%s%%d %%s\\n' %s %s \"$?\" \"$PWD\"\n",
+		shellQuote(outMarker[:len(outMarker)/2]), shellQuote(outMarker[len(outMarker)/2:]))
+	fmt.Fprintf(&b, "printf '%%s%%s\\n' %s %s >&2\n",
+		shellQuote(errMarker[:len(errMarker)/2]), shellQuote(errMarker[len(errMarker)/2:]))
+	return b.String()
+}
+
+// run 在常驻 Shell 中执行命令
+// 超时或 ctx 取消时只中断正在执行的命令；命令不响应中断时重启 Shell
+func (s *shellSession) run(ctx context.Context, command, cdDir string, timeout time.Duration) (execResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 执行期间暂停空闲计时；计时器已触发说明 Shell 正在因空闲关闭
+	if !s.idleTimer.Stop() || !s.alive() {
+		s.close()
+		return execResult{}, errShellExited
+	}
+	defer s.idleTimer.Reset(s.idle)
+
+	s.seq++
+	outMarker, errMarker := s.markers()
+	// 丢弃上次命令之后（如后台任务）产生的游离输出
+	s.stdout.drain()
+	s.stderr.drain()
+
+	result := execResult{command: command}
+	start := time.Now()
+	if _, err := io.WriteString(s.stdin, s.script(command, cdDir)); err != nil {
+		return execResult{}, errShellExited
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ctxDone := ctx.Done()
+	var graceC <-chan time.Time
+	var stdout, stderr []byte
+	var status string
+	outDone, errDone := false, false
+	for {
+		if !outDone {
+			stdout, status, outDone = s.stdout.cut(outMarker)
+		}
+		if !errDone {
+			stderr, _, errDone = s.stderr.cut(errMarker)
+		}
+		if outDone && errDone {
+			break
+		}
+		select {
+		case <-s.stdout.notify:
+		case <-s.stderr.notify:
+		case <-s.done:
+			// 命令中执行了 exit 或 Shell 崩溃，收集剩余输出
+			select {
+			case <-s.stdout.eof:
+			case <-time.After(sessionExitDrain):
+			}
+			result.duration = time.Since(start)
+			result.stdout = s.stdout.drain()
+			result.stderr = s.stderr.drain()
+			result.exitCode = s.cmd.ProcessState.ExitCode()
+			result.errorMsg = joinErrorMsg(result.errorMsg, "会话 Shell 已退出，下次调用将启动新的 Shell")
+			return result, nil
+		case <-timer.C:
+			result.errorMsg = fmt.Sprintf("命令执行超时（%v）", timeout)
+			killDescendants(s.cmd.Process.Pid)
+			graceC = time.After(sessionInterruptGrace)
+		case <-ctxDone:
+			ctxDone = nil
+			if graceC == nil {
+				result.errorMsg = "命令执行被中断"
+				killDescendants(s.cmd.Process.Pid)
+				graceC = time.After(sessionInterruptGrace)
+			}
+		case <-graceC:
+			// 命令未响应中断（如 Shell 内建的死循环），结束 Shell，下次调用重启
+			s.close()
+			result.duration = time.Since(start)
+			result.stdout = s.stdout.drain()
+			result.stderr = s.stderr.drain()
+			result.exitCode = -1
+			result.errorMsg = joinErrorMsg(result.errorMsg, "命令未响应中断，会话 Shell 已结束，下次调用将启动新的 Shell")
+			return result, nil
+		}
+	}
+
+	result.duration = time.Since(start)
+	result.stdout = stdout
+	result.stderr = stderr
+	code, dir, _ := strings.Cut(status, " ")
+	result.exitCode, _ = strconv.Atoi(code)
+	result.dir = dir
+	if dir != "" {
+		s.dirMu.Lock()
+		s.dir = dir
+		s.dirMu.Unlock()
+	}
+	return result, nil
+}
+
+// cwd 返回 Shell 的当前目录
+func (s *shellSession) cwd() string {
+	s.dirMu.Lock()
+	defer s.dirMu.Unlock()
+	return s.dir
+}
+
+// joinErrorMsg 拼接错误信息
+func joinErrorMsg(msgs ...string) string {
+	var parts []string
+	for _, m := range msgs {
+		if m != "" {
+			parts = append(parts, m)
+		}
+	}
+	return strings.Join(parts, "；")
+}
+
+// shellQuote 用单引号转义字符串
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// supportsSession 会话模式只支持 sh/bash 风格 Shell
+func (t *bashTool) supportsSession() bool {
+	return t.platform.ShellType == ShellTypeBash || t.platform.ShellType == ShellTypeSh
+}
+
+// sessionShellArgs 常驻 Shell 的启动参数：不加载用户配置，从 stdin 读取命令
+func (t *bashTool) sessionShellArgs() []string {
+	if t.platform.ShellType == ShellTypeBash {
+		return []string{"--noprofile", "--norc"}
+	}
+	return nil
+}
+
+// sessionPoolKey 常驻 Shell 池的 key：同一会话、Shell 与起始目录共用一个 Shell
+func (t *bashTool) sessionPoolKey(sessionKey, startDir string) string {
+	return sessionKey + "\x00" + t.platform.ShellCommand + "\x00" + startDir
+}
+
+// sessionCwd 返回命令将在会话 Shell 中执行时该 Shell 的当前目录，用于策略检查解析相对路径
+// 不使用会话 Shell、指定了 work_dir 或 Shell 尚未启动时返回空字符串
+func (t *bashTool) sessionCwd(ctx context.Context, params OperationParams) string {
+	if !t.config.SessionMode || params.RunInBackground || len(params.Args) > 0 || params.WorkDir != "" || !t.supportsSession() {
+		return ""
+	}
+	sessionKey, _ := session.SessionKeyFromContext(ctx)
+	if sessionKey == "" {
+		return ""
+	}
+	startDir, errMsg := t.resolveWorkDir(ctx, "")
+	if errMsg != "" {
+		return ""
+	}
+	return defaultShellPool.cwd(t.sessionPoolKey(sessionKey, startDir))
+}
+
+// executeInSession 在会话的常驻 Shell 中执行命令
+// 新 Shell 在 ctx/配置的工作目录启动；work_dir 参数会 cd 到该目录，且该目录在之后的调用中保留
+func (t *bashTool) executeInSession(ctx context.Context, sessionKey, fullCommand, paramDir string, timeout time.Duration) (string, error) {
+	startDir, errMsg := t.resolveWorkDir(ctx, "")
+	if errMsg != "" {
+		return errMsg, nil
+	}
+	cdDir := ""
+	if paramDir != "" {
+		if cdDir, errMsg = t.resolveWorkDir(ctx, paramDir); errMsg != "" {
+			return errMsg, nil
+		}
+	}
+	key := t.sessionPoolKey(sessionKey, startDir)
+	idle := time.Duration(t.config.SessionIdleTimeout) * time.Second
+
+	// Shell 可能恰好在获取后空闲超时退出，重试一次
+	for attempt := 0; ; attempt++ {
+		s, state, err := defaultShellPool.acquire(key, func() (*shellSession, error) {
+			return startShellSession(sessionKey, t.platform.ShellCommand, t.sessionShellArgs(), startDir, t.shellEnv(), idle, func(cmd *exec.Cmd) []string {
+				return t.confine(cmd, startDir)
+			})
+		})
+		if err != nil {
+			return "", err
+		}
+		result, err := s.run(ctx, fullCommand, cdDir, timeout)
+		if errors.Is(err, errShellExited) && attempt == 0 {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		result.notes = append(result.notes, "Shell Session: "+string(state))
+		if state != shellReused {
+			result.notes = append(result.notes, s.warnings...)
+		}
+		return t.formatResult(result), nil
+	}
+}
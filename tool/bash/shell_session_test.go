@@ -0,0 +1,185 @@
+package bash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rulego/rulego-components-ai/session"
+	"github.com/rulego/rulego-components-ai/tool/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBashSession_PersistsState(t *testing.T) {
+	bt := newTestTool(t, func(c *Config) {
+		c.Mode = ModeDeny
+		c.SessionMode = true
+		c.SessionIdleTimeout = 60
+	})
+	key := "test-persist-" + t.Name()
+	defer CloseSession(key)
+	ctx := session.WithSessionKey(context.Background(), key)
+	dir := t.TempDir()
+
+	first := invoke(t, bt, ctx, map[string]interface{}{"command": "cd " + dir + " && export GREETING=hello && greet() { echo \"$GREETING $1\"; }"})
+	assert.Contains(t, first, "Shell Session: started")
+	assert.Contains(t, first, "Exit Code: 0")
+
+	second := invoke(t, bt, ctx, map[string]interface{}{"command": "pwd; greet world; printf no-newline"})
+	assert.Contains(t, second, "Shell Session: reused")
+	assert.Contains(t, second, "Work Dir: "+dir)
+	assert.Contains(t, second, "hello world")
+	assert.Contains(t, second, "no-newline")
+	assert.NotContains(t, second, "__RULEGO_SHELL_")
+
+	// 退出码、stderr 与语法错误只影响本次命令
+	failed := invoke(t, bt, ctx, map[string]interface{}{"command": "echo oops >&2; false"})
+	assert.Contains(t, failed, "Exit Code: 1")
+	assert.Contains(t, failed, "stderr:\noops")
+	syntax := invoke(t, bt, ctx, map[string]interface{}{"command": "echo 'unterminated"})
+	assert.NotContains(t, syntax, "Exit Code: 0")
+	assert.Contains(t, invoke(t, bt, ctx, map[string]interface{}{"command": "echo $GREETING"}), "hello")
+
+	// 其他会话互不影响
+	other := session.WithSessionKey(context.Background(), key+"-other")
+	defer CloseSession(key + "-other")
+	assert.NotContains(t, invoke(t, bt, other, map[string]interface{}{"command": "echo \"[$GREETING]\""}), "hello")
+}
+
+func TestBashSession_TimeoutInterruptsOnlyCommand(t *testing.T) {
+	bt := newTestTool(t, func(c *Config) {
+		c.Mode = ModeDeny
+		c.SessionMode = true
+		c.SessionIdleTimeout = 60
+	})
+	key := "test-timeout-" + t.Name()
+	defer CloseSession(key)
+	ctx := session.WithSessionKey(context.Background(), key)
+
+	pid := strings.TrimSpace(lastLine(invoke(t, bt, ctx, map[string]interface{}{"command": "export KEEP=1; echo $$"})))
+	start := time.Now()
+	result := invoke(t, bt, ctx, map[string]interface{}{"command": "sleep 30", "timeout": 1})
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Contains(t, result, "命令执行超时")
+
+	after := invoke(t, bt, ctx, map[string]interface{}{"command": "echo $KEEP $$"})
+	assert.Contains(t, after, "Shell Session: reused")
+	assert.Contains(t, after, "1 "+pid)
+}
+
+func TestBashSession_RestartsAfterExit(t *testing.T) {
+	bt := newTestTool(t, func(c *Config) {
+		c.Mode = ModeDeny
+		c.SessionMode = true
+		c.SessionIdleTimeout = 60
+	})
+	key := "test-exit-" + t.Name()
+	defer CloseSession(key)
+	ctx := session.WithSessionKey(context.Background(), key)
+
+	invoke(t, bt, ctx, map[string]interface{}{"command": "export LOST=1"})
+	exited := invoke(t, bt, ctx, map[string]interface{}{"command": "echo bye; exit 3"})
+	assert.Contains(t, exited, "Exit Code: 3")
+	assert.Contains(t, exited, "bye")
+	assert.Contains(t, exited, "会话 Shell 已退出")
+
+	restarted := invoke(t, bt, ctx, map[string]interface{}{"command": "echo \"[$LOST]\""})
+	assert.Contains(t, restarted, "Shell Session: restarted")
+	assert.Contains(t, restarted, "[]")
+}
+
+func TestBashSession_IdleAndSessionEnd(t *testing.T) {
+	bt := newTestTool(t, func(c *Config) {
+		c.Mode = ModeDeny
+		c.SessionMode = true
+		c.SessionIdleTimeout = 1
+	})
+	key := "test-idle-" + t.Name()
+	defer CloseSession(key)
+	ctx := session.WithSessionKey(context.Background(), key)
+
+	invoke(t, bt, ctx, map[string]interface{}{"command": "true"})
+	s := sessionShell(key)
+	require.NotNil(t, s)
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle shell should be closed")
+	}
+	assert.Contains(t, invoke(t, bt, ctx, map[string]interface{}{"command": "true"}), "Shell Session: restarted")
+
+	// 会话删除时关闭常驻 Shell
+	mgr := session.NewManager(session.NewMemoryStorage(), nil)
+	sess, err := mgr.GetOrCreate(context.Background(), session.SessionRequest{AgentID: "agent", Channel: "test", Scope: session.ScopeMain})
+	require.NoError(t, err)
+	ctx = session.WithSessionKey(context.Background(), sess.Key)
+	invoke(t, bt, ctx, map[string]interface{}{"command": "true"})
+	s = sessionShell(sess.Key)
+	require.NotNil(t, s)
+	require.NoError(t, mgr.Delete(context.Background(), sess.Key))
+	assert.False(t, s.alive())
+	assert.Nil(t, sessionShell(sess.Key))
+}
+
+func TestBashSession_WithoutSessionKey(t *testing.T) {
+	bt := newTestTool(t, func(c *Config) {
+		c.Mode = ModeDeny
+		c.SessionMode = true
+		c.SessionIdleTimeout = 60
+	})
+	invoke(t, bt, context.Background(), map[string]interface{}{"command": "export ONESHOT=1"})
+	result := invoke(t, bt, context.Background(), map[string]interface{}{"command": "echo \"[$ONESHOT]\""})
+	assert.Contains(t, result, "[]")
+	assert.NotContains(t, result, "Shell Session:")
+}
+
+// sessionShell 返回会话当前的常驻 Shell
+func sessionShell(sessionKey string) *shellSession {
+	defaultShellPool.mu.Lock()
+	defer defaultShellPool.mu.Unlock()
+	for _, s := range defaultShellPool.sessions {
+		if s.sessionKey == sessionKey {
+			return s
+		}
+	}
+	return nil
+}
+
+func lastLine(result string) string {
+	lines := strings.Split(strings.TrimRight(result, "\n"), "\n")
+	return lines[len(lines)-1]
+}
+
+func TestBashSession_RedirectUsesShellCwd(t *testing.T) {
+	bt := newTestTool(t, func(c *Config) {
+		c.Mode = ModeDeny
+		c.SessionMode = true
+		c.SessionIdleTimeout = 60
+	})
+	workDir := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(workDir, "sub"), 0755))
+	sec := common.DefaultPathSecurityConfig()
+	bt.config.WorkDir = workDir
+	bt.config.PathSecurity = &sec
+	key := "test-cwd-" + t.Name()
+	defer CloseSession(key)
+	ctx := session.WithSessionKey(context.Background(), key)
+
+	// 上一次调用 cd 到工作目录之外，本次的相对重定向按 Shell 的当前目录检查
+	assert.Contains(t, invoke(t, bt, ctx, map[string]interface{}{"command": "cd " + outside}), "Exit Code: 0")
+	denied := invoke(t, bt, ctx, map[string]interface{}{"command": "echo x > escape.txt"})
+	assert.Contains(t, denied, common.ErrorMessages[common.ErrCodePermissionDenied])
+	_, err := os.Stat(filepath.Join(outside, "escape.txt"))
+	assert.True(t, os.IsNotExist(err))
+
+	// 回到工作目录内的子目录后允许写入
+	assert.Contains(t, invoke(t, bt, ctx, map[string]interface{}{"command": "cd " + filepath.Join(workDir, "sub")}), "Exit Code: 0")
+	assert.Contains(t, invoke(t, bt, ctx, map[string]interface{}{"command": "echo x > out.txt"}), "Exit Code: 0")
+	_, err = os.Stat(filepath.Join(workDir, "sub", "out.txt"))
+	assert.NoError(t, err)
+}
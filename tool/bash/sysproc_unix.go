@@ -3,7 +3,11 @@
 package bash
 
 import (
+	"fmt"
+	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 	"syscall"
 )
 
@@ -17,3 +21,94 @@ func killProcessGroup(pid int) {
 	// pid 为负数表示进程组
 	_ = syscall.Kill(-pid, syscall.SIGKILL)
 }
+
+// killDescendants kill 进程的所有后代进程，进程本身保留（用于中断常驻 Shell 中正在执行的命令）
+func killDescendants(pid int) {
+	for _, child := range descendantPids(pid) {
+		_ = syscall.Kill(child, syscall.SIGKILL)
+	}
+}
+
+// descendantPids 返回进程的所有后代进程 ID
+func descendantPids(pid int) []int {
+	children := make(map[int][]int)
+	for p, ppid := range processParents() {
+		children[ppid] = append(children[ppid], p)
+	}
+	var result []int
+	queue := []int{pid}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, c := range children[cur] {
+			result = append(result, c)
+			queue = append(queue, c)
+		}
+	}
+	return result
+}
+
+// processParents 返回 pid -> ppid 映射，优先读取 /proc（Linux），否则使用 ps
+func processParents() map[int]int {
+	parents := make(map[int]int)
+	if entries, err := os.ReadDir("/proc"); err == nil {
+		for _, e := range entries {
+			pid, err := strconv.Atoi(e.Name())
+			if err != nil {
+				continue
+			}
+			data, err := os.ReadFile("/proc/" + e.Name() + "/stat")
+			if err != nil {
+				continue
+			}
+			// 格式：pid (comm) state ppid ...，comm 可能包含空格和括号
+			stat := string(data)
+			if i := strings.LastIndexByte(stat, ')'); i >= 0 {
+				if fields := strings.Fields(stat[i+1:]); len(fields) >= 2 {
+					if ppid, err := strconv.Atoi(fields[1]); err == nil {
+						parents[pid] = ppid
+					}
+				}
+			}
+		}
+		if len(parents) > 0 {
+			return parents
+		}
+	}
+	out, err := exec.Command("ps", "-A", "-o", "pid=", "-o", "ppid=").Output()
+	if err != nil {
+		return parents
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		if err1 == nil && err2 == nil {
+			parents[pid] = ppid
+		}
+	}
+	return parents
+}
+
+// jobSignals 后台任务支持的信号
+var jobSignals = map[string]syscall.Signal{
+	"TERM": syscall.SIGTERM,
+	"KILL": syscall.SIGKILL,
+	"INT":  syscall.SIGINT,
+	"HUP":  syscall.SIGHUP,
+	"QUIT": syscall.SIGQUIT,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// signalProcessGroup 向整个进程组发送信号，name 如 TERM、SIGINT
+func signalProcessGroup(pid int, name string) error {
+	sig, ok := jobSignals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return fmt.Errorf("unsupported signal %q", name)
+	}
+	return syscall.Kill(-pid, sig)
+}
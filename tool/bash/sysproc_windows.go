@@ -3,8 +3,10 @@
 package bash
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 )
 
 // setSysProcAttr 在 Windows 上不做额外设置
@@ -21,3 +23,18 @@ func killProcessGroup(pid int) {
 		_ = p.Kill()
 	}
 }
+
+// killDescendants 在 Windows 上不支持只中断子进程，常驻 Shell 超时后由调用方整体重启
+func killDescendants(pid int) {
+}
+
+// signalProcessGroup 在 Windows 上只支持 TERM/KILL（均为终止进程）
+func signalProcessGroup(pid int, name string) error {
+	switch strings.TrimPrefix(strings.ToUpper(name), "SIG") {
+	case "TERM", "KILL":
+		killProcessGroup(pid)
+		return nil
+	default:
+		return fmt.Errorf("signal %q is not supported on windows", name)
+	}
+}
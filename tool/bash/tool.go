@@ -18,6 +18,7 @@ import (
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 	"github.com/eino-contrib/jsonschema"
+	"github.com/rulego/rulego-components-ai/session"
 	aitool "github.com/rulego/rulego-components-ai/tool"
 	"github.com/rulego/rulego-components-ai/tool/common"
 	orderedmap "github.com/wk8/go-ordered-map/v2"
@@ -31,6 +32,9 @@ const (
 
 	// DefaultMaxOutputSize 默认最大输出大小 (64KB)
 	DefaultMaxOutputSize = 64 * 1024
+
+	// DefaultSessionIdleTimeout 会话 Shell 默认空闲超时（秒）
+	DefaultSessionIdleTimeout = 600
 )
 
 // SecurityMode defines the security mode for command execution.
@@ -53,6 +57,19 @@ type Config struct {
 	Deny          []string     `json:"deny" label:"拒绝列表" desc:"拒绝执行的命令列表"`
 	DenyArgs      []string     `json:"denyArgs" label:"拒绝参数" desc:"拒绝的参数模式"`
 	ShellPath     string       `json:"shellPath" label:"Shell路径" desc:"指定使用的 Shell 路径（例如 bash.exe 的绝对路径）。如果为空，则自动检测。"`
+	// SessionMode 会话模式：按会话 key 复用常驻 Shell，仅支持 sh/bash 风格 Shell
+	SessionMode        bool `json:"sessionMode" label:"会话模式" desc:"同一会话的命令在同一个常驻 Shell 中执行，保留 cd、环境变量、函数等状态（仅 sh/bash）"`
+	SessionIdleTimeout int  `json:"sessionIdleTimeout" label:"会话空闲超时" desc:"会话 Shell 空闲多久后关闭（秒，默认600）"`
+	MaxBackgroundJobs  int  `json:"maxBackgroundJobs" label:"后台任务上限" desc:"每个会话最多同时运行的后台任务数（默认10）"`
+	BackgroundJobTTL   int  `json:"backgroundJobTTL" label:"后台任务存活时间" desc:"未绑定会话的后台任务启动多久后终止并删除输出文件（秒，默认3600）"`
+	// Policy 声明式命令策略，按 Shell AST 逐条命令评估（仅 sh/bash）
+	Policy PolicyConfig `json:"policy" label:"命令策略" desc:"按命令、子命令（如 git push）、参数正则匹配的 allow/deny 规则，以及网络工具限制"`
+	// PathSecurity 重定向目标的路径安全策略，为空时不检查（仅 sh/bash）
+	PathSecurity *common.PathSecurityConfig `json:"pathSecurity" label:"重定向路径安全" desc:"重定向读写的文件必须通过该路径安全策略，为空时不检查"`
+	// Confinement 执行隔离（仅 Linux）：rlimit、Landlock 文件系统规则、网络命名空间
+	Confinement ConfinementConfig `json:"confinement" label:"执行隔离" desc:"Linux 下限制命令的 CPU、内存、进程数、文件大小，并用 Landlock 限制只能访问工作目录与允许目录，可选禁止网络"`
+	// Checkpoint 前台命令执行前后对工作目录做快照，把改动的文件记入本次执行的检查点（需 ctx 注入 runId）
+	Checkpoint bool `json:"checkpoint" label:"记录检查点" desc:"前台命令执行前后对工作目录做快照，命令改动的文件可通过 edit 工具的 restore_checkpoint 撤销（后台任务不记录）"`
 }
 
 // DefaultConfig returns the default configuration based on the current platform.
@@ -68,9 +85,29 @@ func DefaultConfig() Config {
 	}
 }
 
+// ResolveShellType 返回该配置实际使用的 Shell 类型：未指定 ShellPath 时取平台默认，
+// 否则按路径名识别（powershell/pwsh、bash/sh、cmd），无法识别时当作 sh 处理
+func (c Config) ResolveShellType() ShellType {
+	if c.ShellPath == "" {
+		return GetPlatformConfig().ShellType
+	}
+	lowerPath := strings.ToLower(c.ShellPath)
+	// 先识别 powershell/pwsh，它们的名称本身包含 "sh"
+	if strings.Contains(lowerPath, "powershell") || strings.Contains(lowerPath, "pwsh") {
+		return ShellTypePowerShell
+	} else if strings.Contains(lowerPath, "bash") || strings.Contains(lowerPath, "sh") {
+		return ShellTypeBash
+	} else if strings.Contains(lowerPath, "cmd") {
+		return ShellTypeCMD
+	}
+	// 默认当作 sh 处理
+	return ShellTypeSh
+}
+
 type bashTool struct {
 	config   Config
 	platform PlatformConfig
+	rules    []compiledRule
 }
 
 // NewTool creates a new bash tool.
@@ -79,12 +116,9 @@ func NewTool(config Config) (tool.BaseTool, error) {
 
 	if config.ShellPath != "" {
 		platform.ShellCommand = config.ShellPath
-		lowerPath := strings.ToLower(config.ShellPath)
-		if strings.Contains(lowerPath, "bash") || strings.Contains(lowerPath, "sh") {
-			platform.ShellType = ShellTypeBash
-			platform.ShellArgs = []string{"-c"}
-		} else if strings.Contains(lowerPath, "powershell") || strings.Contains(lowerPath, "pwsh") {
-			platform.ShellType = ShellTypePowerShell
+		platform.ShellType = config.ResolveShellType()
+		switch platform.ShellType {
+		case ShellTypePowerShell:
 			platform.ShellArgs = []string{
 				"-NoProfile",
 				"-NonInteractive",
@@ -92,12 +126,9 @@ func NewTool(config Config) (tool.BaseTool, error) {
 				"-Command",
 				"[Console]::OutputEncoding=[System.Text.Encoding]::UTF8;",
 			}
-		} else if strings.Contains(lowerPath, "cmd") {
-			platform.ShellType = ShellTypeCMD
+		case ShellTypeCMD:
 			platform.ShellArgs = []string{"/c"}
-		} else {
-			// 默认当作 sh 处理
-			platform.ShellType = ShellTypeSh
+		default:
 			platform.ShellArgs = []string{"-c"}
 		}
 	}
@@ -108,6 +139,15 @@ func NewTool(config Config) (tool.BaseTool, error) {
 	if config.MaxOutputSize <= 0 {
 		config.MaxOutputSize = DefaultMaxOutputSize
 	}
+	if config.SessionIdleTimeout <= 0 {
+		config.SessionIdleTimeout = DefaultSessionIdleTimeout
+	}
+	if config.MaxBackgroundJobs <= 0 {
+		config.MaxBackgroundJobs = DefaultMaxBackgroundJobs
+	}
+	if config.BackgroundJobTTL <= 0 {
+		config.BackgroundJobTTL = DefaultBackgroundJobTTL
+	}
 	if config.Mode == "" {
 		config.Mode = ModeAllow
 	}
@@ -121,9 +161,15 @@ func NewTool(config Config) (tool.BaseTool, error) {
 		config.DenyArgs = platform.DefaultDenyArgs
 	}
 
+	rules, err := compilePolicy(config.Policy)
+	if err != nil {
+		return nil, err
+	}
+
 	return &bashTool{
 		config:   config,
 		platform: platform,
+		rules:    rules,
 	}, nil
 }
 
@@ -131,9 +177,15 @@ func NewTool(config Config) (tool.BaseTool, error) {
 func (t *bashTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
 	props := orderedmap.New[string, *jsonschema.Schema]()
 
+	props.Set("operation", &jsonschema.Schema{
+		Type:        "string",
+		Enum:        []any{OpRun, OpJobs, OpOutput, OpWait, OpSignal},
+		Description: "操作类型（可选，默认 run）。run: 执行命令；jobs: 列出后台任务；output: 读取后台任务输出；wait: 等待后台任务结束；signal: 向后台任务发送信号。",
+	})
+
 	props.Set("command", &jsonschema.Schema{
 		Type:        "string",
-		Description: "要执行的 shell 命令。支持管道(|)、链式(&&, ||)、重定向(>)等 shell 语法。例如: 'ls -la', 'find . -name \"*.go\" | head -20'",
+		Description: "要执行的 shell 命令（run 操作必填）。支持管道(|)、链式(&&, ||)、重定向(>)等 shell 语法。例如: 'ls -la', 'find . -name \"*.go\" | head -20'",
 	})
 
 	props.Set("args", &jsonschema.Schema{
@@ -154,6 +206,36 @@ func (t *bashTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
 		Description: fmt.Sprintf("超时时间（秒，可选）。默认使用配置值 %d 秒。对于长时间运行的命令可设置更大的值。", t.config.Timeout),
 	})
 
+	props.Set("run_in_background", &jsonschema.Schema{
+		Type:        "boolean",
+		Description: "后台运行（可选）。为 true 时立即返回任务 ID，适用于开发服务器、长时间构建等，之后用 output/wait/signal 操作查看或结束。",
+	})
+
+	props.Set("job_id", &jsonschema.Schema{
+		Type:        "string",
+		Description: "后台任务 ID（output、wait、signal 操作必填）。",
+	})
+
+	props.Set("stdout_cursor", &jsonschema.Schema{
+		Type:        "integer",
+		Description: "stdout 读取游标（可选）。传入上次 output/wait 返回的 Next Cursor，只读取新增输出。",
+	})
+
+	props.Set("stderr_cursor", &jsonschema.Schema{
+		Type:        "integer",
+		Description: "stderr 读取游标（可选）。传入上次 output/wait 返回的 Next Cursor，只读取新增输出。",
+	})
+
+	props.Set("tail", &jsonschema.Schema{
+		Type:        "integer",
+		Description: "只读取最后 N 行输出（可选），设置后忽略游标。",
+	})
+
+	props.Set("signal", &jsonschema.Schema{
+		Type:        "string",
+		Description: "signal 操作发送的信号（可选），默认 TERM。支持 TERM、KILL、INT、HUP、QUIT、USR1、USR2。",
+	})
+
 	// 根据平台和 shell 类型生成描述
 	platformDesc := fmt.Sprintf("当前平台: %s, Shell类型: %s。", runtime.GOOS, t.platform.ShellType)
 	switch t.platform.ShellType {
@@ -166,6 +248,10 @@ func (t *bashTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
 	case ShellTypeSh:
 		platformDesc += "使用 Unix/Shell 风格命令: ls, cat, grep, find, cp, mv, rm, mkdir。"
 	}
+	if t.config.SessionMode && t.supportsSession() {
+		platformDesc += "会话模式：同一会话的命令在同一个常驻 Shell 中执行，cd、export、source、函数定义等状态在调用之间保留。"
+	}
+	platformDesc += fmt.Sprintf("长时间运行的命令可设置 run_in_background 后台运行（每个会话最多 %d 个），再通过 output 增量读取输出、wait 等待结束、signal 终止。", t.config.MaxBackgroundJobs)
 
 	return &schema.ToolInfo{
 		Name: ToolName,
@@ -174,17 +260,25 @@ func (t *bashTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
 		ParamsOneOf: schema.NewParamsOneOfByJSONSchema(&jsonschema.Schema{
 			Type:       "object",
 			Properties: props,
-			Required:   []string{"command"},
 		}),
 	}, nil
 }
 
 // OperationParams holds operation parameters.
 type OperationParams struct {
-	Command string   `json:"command"`
-	Args    []string `json:"args"`
-	WorkDir string   `json:"work_dir"`
-	Timeout int      `json:"timeout"` // 超时时间（秒），0 表示使用配置默认值
+	Operation string   `json:"operation"` // 操作类型，空表示 run
+	Command   string   `json:"command"`
+	Args      []string `json:"args"`
+	WorkDir   string   `json:"work_dir"`
+	Timeout   int      `json:"timeout"` // 超时时间（秒），0 表示使用配置默认值
+	// RunInBackground 后台运行，立即返回任务 ID
+	RunInBackground bool   `json:"run_in_background"`
+	JobID           string `json:"job_id"`
+	// StdoutCursor/StderrCursor 增量读取的字节游标，取自上次 output/wait 返回的 Next Cursor
+	StdoutCursor int64  `json:"stdout_cursor"`
+	StderrCursor int64  `json:"stderr_cursor"`
+	Tail         int    `json:"tail"`   // 读取最后 N 行，忽略游标
+	Signal       string `json:"signal"` // TERM（默认）、KILL、INT、HUP 等
 }
 
 // InvokableRun executes the operation.
@@ -194,7 +288,20 @@ func (t *bashTool) InvokableRun(ctx context.Context, arguments string, opts ...t
 		return "", fmt.Errorf("解析参数失败: %w", err)
 	}
 
-	return t.executeShell(ctx, params)
+	switch params.Operation {
+	case "", OpRun:
+		return t.executeShell(ctx, params)
+	case OpJobs:
+		return t.listJobs(ctx), nil
+	case OpOutput:
+		return t.jobOutput(ctx, params), nil
+	case OpWait:
+		return t.waitJob(ctx, params), nil
+	case OpSignal:
+		return t.signalJob(ctx, params), nil
+	default:
+		return common.NewErrorf(common.ErrCodeInvalidParams, "unknown operation '%s'", params.Operation).Error(), nil
+	}
 }
 
 // executeShell executes a shell command.
@@ -211,52 +318,36 @@ func (t *bashTool) executeShell(ctx context.Context, params OperationParams) (st
 		fullCommand = params.Command
 	}
 
-	// 提取命令链中的所有命令进行安全检查
-	commands := extractAllCommands(fullCommand)
+	if errMsg := t.checkSecurity(ctx, params, fullCommand); errMsg != "" {
+		return errMsg, nil
+	}
 
-	// Security check: deny commands (always apply)
-	for _, cmd := range commands {
-		if common.ContainsIgnoreCase(t.config.Deny, cmd) {
-			return common.NewErrorf(common.ErrCodePermissionDenied, "command '%s' is denied", cmd).Error(), nil
-		}
+	if params.RunInBackground {
+		return t.startBackground(ctx, params, fullCommand)
 	}
 
-	// Security check: deny args/patterns in full command (always apply)
-	for _, denied := range t.config.DenyArgs {
-		if isBlockedPatternMatch(fullCommand, denied) {
-			return common.NewErrorf(common.ErrCodePermissionDenied, "command contains denied pattern '%s'", denied).Error(), nil
+	// 检查点：执行前后对工作目录做快照，把命令改动的文件记入本次执行的检查点
+	if finish := t.beginCheckpoint(ctx); finish != nil {
+		result, err := t.runForeground(ctx, params, fullCommand)
+		if err == nil {
+			result += finish()
 		}
+		return result, err
 	}
+	return t.runForeground(ctx, params, fullCommand)
+}
 
-	// Security check: based on mode
-	switch t.config.Mode {
-	case ModeAllow:
-		// Allow mode: all commands must be in allow list
-		for _, cmd := range commands {
-			if len(t.config.Allow) > 0 && !common.ContainsIgnoreCase(t.config.Allow, cmd) {
-				return common.NewErrorf(common.ErrCodePermissionDenied, "command '%s' not in allow list. Allowed: %v", cmd, t.config.Allow).Error(), nil
-			}
+// runForeground 前台执行命令（会话 Shell 或一次性进程）并等待结果
+func (t *bashTool) runForeground(ctx context.Context, params OperationParams, fullCommand string) (string, error) {
+	timeout := t.effectiveTimeout(params.Timeout)
+
+	// 会话模式：同一会话复用常驻 Shell，保留 cd、环境变量、函数等状态（args 形式仍单独执行）
+	if t.config.SessionMode && len(params.Args) == 0 && t.supportsSession() {
+		if sessionKey, _ := session.SessionKeyFromContext(ctx); sessionKey != "" {
+			return t.executeInSession(ctx, sessionKey, fullCommand, params.WorkDir, timeout)
 		}
-	case ModeDeny:
-		// Deny mode: allow all commands except denied ones (already checked above)
-		// No additional check needed
 	}
 
-	// Set timeout (use params timeout if specified, otherwise use config default)
-	timeoutSeconds := t.config.Timeout
-	if params.Timeout > 0 {
-		timeoutSeconds = params.Timeout
-	}
-	// 超时上限：不超过全局 MaxTimeout，默认不超过 300 秒
-	maxAllowed := common.GetTimeoutConfig().MaxTimeout
-	if maxAllowed <= 0 {
-		maxAllowed = 300 * time.Second
-	}
-	timeoutDuration := time.Duration(timeoutSeconds) * time.Second
-	if timeoutDuration > maxAllowed {
-		timeoutDuration = maxAllowed
-	}
-	timeout := timeoutDuration
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
@@ -289,27 +380,16 @@ func (t *bashTool) executeShell(ctx context.Context, params OperationParams) (st
 		cmd = exec.CommandContext(ctx, t.platform.ShellCommand, shellArgs...)
 	}
 
-	// Set working directory（解析顺序：LLM 参数 → ctx 注入 → 配置写死）
-	workDir := params.WorkDir
-	if workDir == "" {
-		// 通用 workDir 注入：调用方（如主 agent 派子 agent）通过 ctx 指定工作目录，
-		// 不依赖配置写死（业务层把 projectId 等转成路径后注入，基础库不认业务字段）
-		workDir = common.WorkDirFromCtx(ctx)
-	}
-	if workDir == "" {
-		workDir = t.config.WorkDir
-	}
-	if workDir != "" {
-		// 安全校验：清理路径并拒绝包含 .. 的路径
-		workDir = filepath.Clean(workDir)
-		if strings.Contains(workDir, "..") {
-			return common.NewErrorf(common.ErrCodePathEscape, "work_dir contains path traversal: %s", params.WorkDir).Error(), nil
-		}
-		cmd.Dir = workDir
+	workDir, errMsg := t.resolveWorkDir(ctx, params.WorkDir)
+	if errMsg != "" {
+		return errMsg, nil
 	}
+	cmd.Dir = workDir
 
 	// 设置进程组属性（Linux/macOS 下设置 Setpgid，用于超时时 kill 整个进程组）
 	setSysProcAttr(cmd)
+	cmd.Env = t.shellEnv()
+	notes := t.confine(cmd, workDir)
 
 	// ctx 超时/取消时触发 Cancel 杀进程组，WaitDelay 兜底回收残留 IO
 	cmd.Cancel = func() error {
@@ -320,21 +400,6 @@ func (t *bashTool) executeShell(ctx context.Context, params OperationParams) (st
 	}
 	cmd.WaitDelay = 5 * time.Second
 
-	// Set environment variables
-	cmd.Env = os.Environ()
-	// Windows: 设置 UTF-8 编码
-	if runtime.GOOS == "windows" {
-		cmd.Env = append(cmd.Env,
-			"PYTHONIOENCODING=utf-8",
-			"LANG=en_US.UTF-8",
-			"LC_ALL=en_US.UTF-8",
-		)
-		// 如果使用 Git Bash，还需要设置 CHCP
-		if t.platform.ShellType == ShellTypeBash {
-			cmd.Env = append(cmd.Env, "MSYSTEM=UCRT64")
-		}
-	}
-
 	// Capture output with size limit
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -348,27 +413,173 @@ func (t *bashTool) executeShell(ctx context.Context, params OperationParams) (st
 
 	// exec.CommandContext 在 ctx 超时/取消时自动触发上面的 Cancel 杀进程
 	err := cmd.Wait()
-	executionTime := time.Since(startTime).Milliseconds()
 
+	result := execResult{
+		command:  fullCommand,
+		dir:      cmd.Dir,
+		duration: time.Since(startTime),
+		stdout:   stdout.Bytes(),
+		stderr:   stderr.Bytes(),
+		notes:    notes,
+	}
+	if err != nil {
+		exitErr, isExitErr := err.(*exec.ExitError)
+		result.exitCode = -1
+		if isExitErr {
+			result.exitCode = exitErr.ExitCode()
+		}
+		// 检查上下文错误（超时或取消）
+		if ctx.Err() == context.DeadlineExceeded {
+			result.errorMsg = fmt.Sprintf("命令执行超时（%v）", timeout)
+		} else if ctx.Err() == context.Canceled {
+			result.errorMsg = "命令执行被中断"
+		} else if !isExitErr {
+			// ExitError 会通过 Exit Code 显示，这里不需要额外信息
+			result.errorMsg = err.Error()
+		}
+	}
+	return t.formatResult(result), nil
+}
+
+// checkSecurity 安全检查，拒绝时返回说明命中规则的错误提示
+// sh/bash 解析为 Shell AST 后评估命令策略；PowerShell/CMD 使用字符串扫描
+func (t *bashTool) checkSecurity(ctx context.Context, params OperationParams, fullCommand string) string {
+	if t.platform.ShellType == ShellTypeBash || t.platform.ShellType == ShellTypeSh {
+		workDir, errMsg := t.resolveWorkDir(ctx, params.WorkDir)
+		if errMsg != "" {
+			return errMsg
+		}
+		var argv []string
+		if len(params.Args) > 0 {
+			argv = append([]string{params.Command}, params.Args...)
+		}
+		if v := t.checkPolicy(fullCommand, argv, workDir, t.sessionCwd(ctx, params)); v != nil {
+			return common.NewError(common.ErrCodePermissionDenied, v.Error()).Error()
+		}
+		return ""
+	}
+
+	// 提取命令链中的所有命令进行安全检查
+	commands := extractAllCommands(fullCommand)
+
+	// Security check: deny commands (always apply)
+	for _, cmd := range commands {
+		if common.ContainsIgnoreCase(t.config.Deny, cmd) {
+			return common.NewErrorf(common.ErrCodePermissionDenied, "command '%s' is denied", cmd).Error()
+		}
+	}
+
+	// Security check: deny args/patterns in full command (always apply)
+	for _, denied := range t.config.DenyArgs {
+		if isBlockedPatternMatch(fullCommand, denied) {
+			return common.NewErrorf(common.ErrCodePermissionDenied, "command contains denied pattern '%s'", denied).Error()
+		}
+	}
+
+	// Allow mode: all commands must be in allow list
+	if t.config.Mode == ModeAllow {
+		for _, cmd := range commands {
+			if len(t.config.Allow) > 0 && !common.ContainsIgnoreCase(t.config.Allow, cmd) {
+				return common.NewErrorf(common.ErrCodePermissionDenied, "command '%s' not in allow list. Allowed: %v", cmd, t.config.Allow).Error()
+			}
+		}
+	}
+	return ""
+}
+
+// effectiveTimeout 计算超时时间（参数优先，否则使用配置默认值）
+// 上限不超过全局 MaxTimeout，默认不超过 300 秒
+func (t *bashTool) effectiveTimeout(paramTimeout int) time.Duration {
+	timeoutSeconds := t.config.Timeout
+	if paramTimeout > 0 {
+		timeoutSeconds = paramTimeout
+	}
+	maxAllowed := common.GetTimeoutConfig().MaxTimeout
+	if maxAllowed <= 0 {
+		maxAllowed = 300 * time.Second
+	}
+	timeout := time.Duration(timeoutSeconds) * time.Second
+	if timeout > maxAllowed {
+		timeout = maxAllowed
+	}
+	return timeout
+}
+
+// resolveWorkDir 解析工作目录（解析顺序：LLM 参数 → ctx 注入 → 配置写死）
+// 返回工作目录，路径非法时返回错误提示
+func (t *bashTool) resolveWorkDir(ctx context.Context, paramDir string) (string, string) {
+	workDir := paramDir
+	if workDir == "" {
+		// 通用 workDir 注入：调用方（如主 agent 派子 agent）通过 ctx 指定工作目录，
+		// 不依赖配置写死（业务层把 projectId 等转成路径后注入，基础库不认业务字段）
+		workDir = common.WorkDirFromCtx(ctx)
+	}
+	if workDir == "" {
+		workDir = t.config.WorkDir
+	}
+	if workDir == "" {
+		return "", ""
+	}
+	// 安全校验：清理路径并拒绝包含 .. 的路径
+	workDir = filepath.Clean(workDir)
+	if strings.Contains(workDir, "..") {
+		return "", common.NewErrorf(common.ErrCodePathEscape, "work_dir contains path traversal: %s", paramDir).Error()
+	}
+	return workDir, ""
+}
+
+// shellEnv 返回命令执行的环境变量
+func (t *bashTool) shellEnv() []string {
+	env := os.Environ()
+	// Windows: 设置 UTF-8 编码
+	if runtime.GOOS == "windows" {
+		env = append(env,
+			"PYTHONIOENCODING=utf-8",
+			"LANG=en_US.UTF-8",
+			"LC_ALL=en_US.UTF-8",
+		)
+		// 如果使用 Git Bash，还需要设置 CHCP
+		if t.platform.ShellType == ShellTypeBash {
+			env = append(env, "MSYSTEM=UCRT64")
+		}
+	}
+	return env
+}
+
+// execResult 命令执行结果
+type execResult struct {
+	command  string
+	dir      string
+	duration time.Duration
+	exitCode int
+	errorMsg string
+	stdout   []byte
+	stderr   []byte
+	// notes 附加在头部的说明（如会话 Shell 状态）
+	notes []string
+}
+
+// formatResult 将执行结果格式化为供 LLM 阅读的文本
+func (t *bashTool) formatResult(r execResult) string {
 	// 输出处理三步：
 	//   1) UTF-8 转换（已有逻辑）
 	//   2) L1 token 清洗链（progress/ansi/redact/longline + never-worse 守卫，opt-out via # nofilter/# raw）
 	//   3) 错误感知 head+tail 截断（超限时落盘到 workDir/.tool-output，output 给路径）
-	rawStdout := convertToUTF8(stdout.Bytes())
-	rawStderr := convertToUTF8(stderr.Bytes())
+	rawStdout := convertToUTF8(r.stdout)
+	rawStderr := convertToUTF8(r.stderr)
 
 	filteredStdout := rawStdout
 	filteredStderr := rawStderr
-	if shouldFilter(fullCommand) {
+	if shouldFilter(r.command) {
 		pipe := newTokenPipeline()
 		filteredStdout = pipe.run(rawStdout)
 		filteredStderr = pipe.run(rawStderr)
 	}
 
-	// 落盘目录：workDir/.tool-output（workDir 优先 cmd.Dir，回退 config.WorkDir）
+	// 落盘目录：workDir/.tool-output（workDir 优先实际执行目录，回退 config.WorkDir）
 	outDir := ""
-	if cmd.Dir != "" {
-		outDir = cmd.Dir
+	if r.dir != "" {
+		outDir = r.dir
 	} else if t.config.WorkDir != "" {
 		outDir = t.config.WorkDir
 	}
@@ -381,44 +592,24 @@ func (t *bashTool) executeShell(ctx context.Context, params OperationParams) (st
 	stdoutRawSize := len(filteredStdout)
 	stderrRawSize := len(filteredStderr)
 
-	// Determine error message
-	errorMsg := ""
-	if err != nil {
-		// 检查上下文错误（超时或取消）
-		if ctx.Err() == context.DeadlineExceeded {
-			errorMsg = fmt.Sprintf("命令执行超时（%v）", timeout)
-		} else if ctx.Err() == context.Canceled {
-			errorMsg = "命令执行被中断"
-		} else if _, ok := err.(*exec.ExitError); ok {
-			// ExitError 会在后面通过 Exit Code 显示，这里不需要额外信息
-		} else {
-			errorMsg = err.Error()
-		}
-	}
-
 	// Build result as text format for LLM consumption
 	var result strings.Builder
 
 	// Header: execution info
-	if cmd.Dir != "" {
-		result.WriteString(fmt.Sprintf("Work Dir: %s\n", cmd.Dir))
+	if r.dir != "" {
+		result.WriteString(fmt.Sprintf("Work Dir: %s\n", r.dir))
 	}
-	result.WriteString(fmt.Sprintf("Duration: %dms\n", executionTime))
+	for _, note := range r.notes {
+		result.WriteString(note + "\n")
+	}
+	result.WriteString(fmt.Sprintf("Duration: %dms\n", r.duration.Milliseconds()))
 
 	// Exit status
-	if err == nil {
-		result.WriteString("Exit Code: 0\n")
-	} else {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			result.WriteString(fmt.Sprintf("Exit Code: %d\n", exitErr.ExitCode()))
-		} else {
-			result.WriteString("Exit Code: -1\n")
-		}
-	}
+	result.WriteString(fmt.Sprintf("Exit Code: %d\n", r.exitCode))
 
 	// Error info (if any)
-	if errorMsg != "" {
-		result.WriteString(fmt.Sprintf("Error: %s\n", errorMsg))
+	if r.errorMsg != "" {
+		result.WriteString(fmt.Sprintf("Error: %s\n", r.errorMsg))
 	}
 
 	// Truncation warning：基于清洗后的实际字节判断
@@ -452,7 +643,7 @@ func (t *bashTool) executeShell(ctx context.Context, params OperationParams) (st
 		}
 	}
 
-	return result.String(), nil
+	return result.String()
 }
 
 // truncateWithDump 用统一截断服务（错误感知 head+tail）截断输出；
@@ -948,4 +1139,6 @@ func RegisterDefault() error {
 
 func init() {
 	_ = RegisterDefault()
+	// 会话结束（删除、过期、归档）时关闭对应的常驻 Shell
+	session.OnSessionEnd(CloseSession)
 }
@@ -10,8 +10,34 @@ import (
 
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// newTestTool 创建测试用的 bash 工具：默认配置经 mutate 修改后创建，需要 POSIX Shell
+func newTestTool(t *testing.T, mutate func(*Config)) *bashTool {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	config := DefaultConfig()
+	if mutate != nil {
+		mutate(&config)
+	}
+	bTool, err := NewTool(config)
+	require.NoError(t, err)
+	return bTool.(*bashTool)
+}
+
+// invoke 以 params 调用工具并返回结果
+func invoke(t *testing.T, bt *bashTool, ctx context.Context, params map[string]interface{}) string {
+	t.Helper()
+	args, err := json.Marshal(params)
+	require.NoError(t, err)
+	result, err := bt.InvokableRun(ctx, string(args))
+	require.NoError(t, err)
+	return result
+}
+
 func TestBashTool_Cancel(t *testing.T) {
 	config := DefaultConfig()
 	config.Timeout = 5 // 设置足够长的超时时间
@@ -355,3 +381,13 @@ func TestExtractAllCommands_Redirection(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_ResolveShellType(t *testing.T) {
+	assert.Equal(t, GetPlatformConfig().ShellType, Config{}.ResolveShellType())
+	assert.Equal(t, ShellTypeBash, Config{ShellPath: "/bin/bash"}.ResolveShellType())
+	assert.Equal(t, ShellTypeBash, Config{ShellPath: "/bin/sh"}.ResolveShellType())
+	assert.Equal(t, ShellTypePowerShell, Config{ShellPath: "powershell.exe"}.ResolveShellType())
+	assert.Equal(t, ShellTypePowerShell, Config{ShellPath: "/usr/bin/pwsh"}.ResolveShellType())
+	assert.Equal(t, ShellTypeCMD, Config{ShellPath: `C:\Windows\System32\cmd.exe`}.ResolveShellType())
+	assert.Equal(t, ShellTypeSh, Config{ShellPath: "/usr/bin/zzz"}.ResolveShellType())
+}
@@ -0,0 +1,451 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// CheckpointDir is the directory (under BackupDir) holding run-level checkpoints.
+	CheckpointDir = "checkpoints"
+
+	// DefaultMaxCheckpoints is the default number of checkpoints kept per session.
+	DefaultMaxCheckpoints = 20
+
+	checkpointManifest = "checkpoint.json"
+	defaultSessionDir  = "_default"
+)
+
+// runIDCtxKey 专用 context key 类型，存本次 agent 执行的 runId。
+type runIDCtxKey struct{}
+
+// WithRunID 把本次执行的 runId 注入 ctx，文件工具据此把改动记入同一个检查点。
+func WithRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDCtxKey{}, runID)
+}
+
+// RunIDFromCtx 从 ctx 取 runId（空=未注入，不记录检查点）。
+func RunIDFromCtx(ctx context.Context) string {
+	if v, ok := ctx.Value(runIDCtxKey{}).(string); ok {
+		return v
+	}
+	return ""
+}
+
+// checkpointMu serializes manifest updates; tools build managers per call.
+var checkpointMu sync.Mutex
+
+// Checkpoint records the state of every file a run touched, as it was before the run first changed it.
+type Checkpoint struct {
+	RunID     string           `json:"runId"`
+	SessionID string           `json:"sessionId"`
+	CreatedAt time.Time        `json:"createdAt"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+	Files     []CheckpointFile `json:"files"`
+
+	dir string
+}
+
+// CheckpointFile is the original state of one file.
+type CheckpointFile struct {
+	Path    string      `json:"path"`           // Absolute path
+	Existed bool        `json:"existed"`        // false: file was created by the run, restore deletes it
+	Blob    string      `json:"blob,omitempty"` // Original content, relative to the checkpoint directory
+	Mode    fs.FileMode `json:"mode,omitempty"` // Original permission bits
+	Source  string      `json:"source"`         // Tool that changed the file (edit, write, bash)
+}
+
+// CheckpointRestore describes the result of restoring a checkpoint.
+type CheckpointRestore struct {
+	Runs     []string // Run IDs that were reverted, newest first
+	Restored []string // Files written back with their original content
+	Deleted  []string // Files created by the reverted runs and removed
+}
+
+// CheckpointManager stores run-level checkpoints under <workspace>/.history/checkpoints/<session>/<run>.
+// Restoring a checkpoint reverts that run and every later run of the same session.
+type CheckpointManager struct {
+	workspace      string
+	dir            string
+	maxCheckpoints int
+}
+
+// NewCheckpointManager creates a new CheckpointManager.
+func NewCheckpointManager(workspace string) *CheckpointManager {
+	return &CheckpointManager{
+		workspace:      workspace,
+		dir:            filepath.Join(workspace, BackupDir, CheckpointDir),
+		maxCheckpoints: DefaultMaxCheckpoints,
+	}
+}
+
+// SetMaxCheckpoints sets the number of checkpoints kept per session (<= 0 keeps all).
+func (m *CheckpointManager) SetMaxCheckpoints(n int) {
+	m.maxCheckpoints = n
+}
+
+// Record saves the current state of filePath into the run's checkpoint before the file is changed.
+// Only the first change of a file within a run is recorded. A missing file is recorded as created by the run.
+func (m *CheckpointManager) Record(sessionId, runId, source, filePath string) error {
+	if runId == "" {
+		return nil
+	}
+	var content []byte
+	var mode fs.FileMode
+	info, err := os.Stat(filePath)
+	existed := err == nil
+	if existed {
+		if info.IsDir() {
+			return nil
+		}
+		if content, err = os.ReadFile(filePath); err != nil {
+			return fmt.Errorf("failed to read file for checkpoint: %w", err)
+		}
+		mode = info.Mode().Perm()
+	}
+	return m.recordContent(sessionId, runId, source, filePath, existed, content, mode)
+}
+
+func (m *CheckpointManager) recordContent(sessionId, runId, source, filePath string, existed bool, content []byte, mode fs.FileMode) error {
+	checkpointMu.Lock()
+	defer checkpointMu.Unlock()
+
+	cp, err := m.load(sessionId, runId)
+	if err != nil {
+		return err
+	}
+	created := cp == nil
+	if created {
+		now := time.Now()
+		cp = &Checkpoint{RunID: runId, SessionID: sessionId, CreatedAt: now, dir: m.runDir(sessionId, runId)}
+	}
+	filePath = filepath.Clean(filePath)
+	for _, f := range cp.Files {
+		if f.Path == filePath {
+			return nil
+		}
+	}
+	if err := EnsureDir(cp.dir); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	entry := CheckpointFile{Path: filePath, Existed: existed, Mode: mode, Source: source}
+	if existed {
+		entry.Blob = fmt.Sprintf("%d.orig", len(cp.Files)+1)
+		if err := os.WriteFile(filepath.Join(cp.dir, entry.Blob), content, 0644); err != nil {
+			return fmt.Errorf("failed to save checkpoint content: %w", err)
+		}
+	}
+	cp.Files = append(cp.Files, entry)
+	cp.UpdatedAt = time.Now()
+	if err := m.save(cp); err != nil {
+		return err
+	}
+	if created {
+		m.prune(sessionId)
+	}
+	return nil
+}
+
+// List returns the checkpoints of a session, newest first.
+func (m *CheckpointManager) List(sessionId string) ([]Checkpoint, error) {
+	checkpointMu.Lock()
+	defer checkpointMu.Unlock()
+	return m.list(sessionId)
+}
+
+func (m *CheckpointManager) list(sessionId string) ([]Checkpoint, error) {
+	sessionDir := filepath.Join(m.dir, checkpointDirName(sessionId))
+	entries, err := os.ReadDir(sessionDir)
+	if err != nil {
+		return nil, nil // No checkpoints yet
+	}
+	var checkpoints []Checkpoint
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		cp, err := readCheckpoint(filepath.Join(sessionDir, entry.Name()))
+		if err != nil || cp == nil {
+			continue
+		}
+		checkpoints = append(checkpoints, *cp)
+	}
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].CreatedAt.After(checkpoints[j].CreatedAt)
+	})
+	return checkpoints, nil
+}
+
+// Restore returns the workspace to the state before the given run: the run and every later run of
+// the session are reverted (files restored or deleted) and their checkpoints removed.
+func (m *CheckpointManager) Restore(sessionId, runId string) (*CheckpointRestore, error) {
+	checkpointMu.Lock()
+	defer checkpointMu.Unlock()
+
+	checkpoints, err := m.list(sessionId)
+	if err != nil {
+		return nil, err
+	}
+	idx := -1
+	for i, cp := range checkpoints {
+		if cp.RunID == runId {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, fmt.Errorf("checkpoint for run '%s' not found", runId)
+	}
+	reverted := checkpoints[:idx+1]
+
+	// 同一文件取最早一次执行记录的原始状态
+	type original struct {
+		file CheckpointFile
+		dir  string
+	}
+	originals := make(map[string]original)
+	var order []string
+	for i := len(reverted) - 1; i >= 0; i-- {
+		for _, f := range reverted[i].Files {
+			if _, ok := originals[f.Path]; !ok {
+				originals[f.Path] = original{file: f, dir: reverted[i].dir}
+				order = append(order, f.Path)
+			}
+		}
+	}
+
+	result := &CheckpointRestore{}
+	for _, p := range order {
+		o := originals[p]
+		if !o.file.Existed {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return result, fmt.Errorf("failed to remove %s: %w", p, err)
+			}
+			result.Deleted = append(result.Deleted, p)
+			continue
+		}
+		if err := EnsureParentDir(p); err != nil {
+			return result, fmt.Errorf("failed to restore %s: %w", p, err)
+		}
+		if err := atomicCopyFile(filepath.Join(o.dir, o.file.Blob), p); err != nil {
+			return result, fmt.Errorf("failed to restore %s: %w", p, err)
+		}
+		if o.file.Mode != 0 {
+			_ = os.Chmod(p, o.file.Mode)
+		}
+		result.Restored = append(result.Restored, p)
+	}
+	for _, cp := range reverted {
+		os.RemoveAll(cp.dir)
+		result.Runs = append(result.Runs, cp.RunID)
+	}
+	return result, nil
+}
+
+// Rel returns path relative to the workspace when it is inside it.
+func (m *CheckpointManager) Rel(path string) string {
+	if rel, err := filepath.Rel(m.workspace, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+func (m *CheckpointManager) runDir(sessionId, runId string) string {
+	return filepath.Join(m.dir, checkpointDirName(sessionId), checkpointDirName(runId))
+}
+
+func (m *CheckpointManager) load(sessionId, runId string) (*Checkpoint, error) {
+	return readCheckpoint(m.runDir(sessionId, runId))
+}
+
+func (m *CheckpointManager) save(cp *Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	manifest := filepath.Join(cp.dir, checkpointManifest)
+	if err := os.WriteFile(manifest+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return os.Rename(manifest+".tmp", manifest)
+}
+
+// prune removes the oldest checkpoints exceeding maxCheckpoints.
+func (m *CheckpointManager) prune(sessionId string) {
+	if m.maxCheckpoints <= 0 {
+		return
+	}
+	checkpoints, _ := m.list(sessionId)
+	for i := m.maxCheckpoints; i < len(checkpoints); i++ {
+		os.RemoveAll(checkpoints[i].dir)
+	}
+}
+
+// readCheckpoint reads a checkpoint manifest; returns nil when the checkpoint does not exist.
+func readCheckpoint(dir string) (*Checkpoint, error) {
+	data, err := os.ReadFile(filepath.Join(dir, checkpointManifest))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	cp.dir = dir
+	return &cp, nil
+}
+
+// checkpointDirName converts a session or run ID into a safe directory name.
+func checkpointDirName(id string) string {
+	if id == "" {
+		return defaultSessionDir
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, id)
+}
+
+// SnapshotLimits bounds the cost of a workspace snapshot.
+type SnapshotLimits struct {
+	MaxFiles     int      // Maximum number of files tracked
+	MaxFileSize  int64    // Files larger than this are tracked without content
+	MaxTotalSize int64    // Total content kept in memory
+	SkipDirs     []string // Directory names not walked (BackupDir and .git are always skipped)
+}
+
+// DefaultSnapshotLimits returns default snapshot limits.
+func DefaultSnapshotLimits() SnapshotLimits {
+	return SnapshotLimits{
+		MaxFiles:     5000,
+		MaxFileSize:  1024 * 1024,
+		MaxTotalSize: 64 * 1024 * 1024,
+		SkipDirs:     []string{"node_modules"},
+	}
+}
+
+// WorkspaceSnapshot is the state of the files under a directory at one point in time,
+// used to find files changed by commands that bypass the file tools (e.g. bash).
+type WorkspaceSnapshot struct {
+	root      string
+	limits    SnapshotLimits
+	files     map[string]snapshotFile
+	truncated bool
+}
+
+type snapshotFile struct {
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+	content []byte // nil when over the size limits
+}
+
+// SnapshotWorkspace walks root and records size, modification time and (within limits) content of regular files.
+func SnapshotWorkspace(root string, limits SnapshotLimits) (*WorkspaceSnapshot, error) {
+	return snapshotWorkspace(root, limits, true)
+}
+
+// Truncated reports whether the file limit was reached, so changes to untracked files are not recorded.
+func (s *WorkspaceSnapshot) Truncated() bool {
+	return s.truncated
+}
+
+func snapshotWorkspace(root string, limits SnapshotLimits, withContent bool) (*WorkspaceSnapshot, error) {
+	skip := map[string]bool{BackupDir: true, ".git": true}
+	for _, d := range limits.SkipDirs {
+		skip[d] = true
+	}
+	s := &WorkspaceSnapshot{root: root, limits: limits, files: make(map[string]snapshotFile)}
+	var total int64
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && skip[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if limits.MaxFiles > 0 && len(s.files) >= limits.MaxFiles {
+			s.truncated = true
+			return filepath.SkipAll
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		f := snapshotFile{size: info.Size(), modTime: info.ModTime(), mode: info.Mode().Perm()}
+		if withContent && (limits.MaxFileSize <= 0 || f.size <= limits.MaxFileSize) && (limits.MaxTotalSize <= 0 || total+f.size <= limits.MaxTotalSize) {
+			if content, err := os.ReadFile(path); err == nil {
+				f.content = content
+				total += int64(len(content))
+			}
+		}
+		s.files[path] = f
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// RecordSnapshotChanges compares before with the current state of its root and records every created,
+// modified or deleted file into the run's checkpoint. Files whose original content was not captured
+// (over the snapshot limits) are returned as skipped.
+func (m *CheckpointManager) RecordSnapshotChanges(sessionId, runId, source string, before *WorkspaceSnapshot) (recorded int, skipped []string, err error) {
+	if runId == "" || before == nil {
+		return 0, nil, nil
+	}
+	after, err := snapshotWorkspace(before.root, before.limits, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	var paths []string
+	for p := range before.files {
+		paths = append(paths, p)
+	}
+	for p := range after.files {
+		// 快照不完整时无法区分新建文件与超出上限未跟踪的文件，只比较已跟踪的文件
+		if _, ok := before.files[p]; !ok && !before.truncated {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		old, existed := before.files[p]
+		cur, exists := after.files[p]
+		if existed && exists && old.size == cur.size && old.modTime.Equal(cur.modTime) {
+			continue
+		}
+		if existed && !exists && after.truncated {
+			continue // 超出上限未遍历到，不视为删除
+		}
+		if existed && old.content == nil {
+			skipped = append(skipped, p)
+			continue
+		}
+		if err := m.recordContent(sessionId, runId, source, p, existed, old.content, old.mode); err != nil {
+			return recorded, skipped, err
+		}
+		recorded++
+	}
+	return recorded, skipped, nil
+}
@@ -0,0 +1,167 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointManager_RecordAndRestore(t *testing.T) {
+	workspace := t.TempDir()
+	a := filepath.Join(workspace, "a.txt")
+	b := filepath.Join(workspace, "sub", "b.txt")
+	require.NoError(t, os.WriteFile(a, []byte("a0"), 0644))
+	m := NewCheckpointManager(workspace)
+
+	// run1：修改 a，新建 b
+	require.NoError(t, m.Record("s1", "run1", "edit", a))
+	require.NoError(t, os.WriteFile(a, []byte("a1"), 0644))
+	require.NoError(t, m.Record("s1", "run1", "edit", a)) // 同一执行只记录第一次
+	require.NoError(t, m.Record("s1", "run1", "write", b))
+	require.NoError(t, os.MkdirAll(filepath.Dir(b), 0755))
+	require.NoError(t, os.WriteFile(b, []byte("b1"), 0644))
+	time.Sleep(10 * time.Millisecond)
+
+	// run2：再次修改 a、b
+	require.NoError(t, m.Record("s1", "run2", "edit", a))
+	require.NoError(t, os.WriteFile(a, []byte("a2"), 0644))
+	require.NoError(t, m.Record("s1", "run2", "edit", b))
+	require.NoError(t, os.WriteFile(b, []byte("b2"), 0644))
+
+	// 其他会话与未注入 runId 的记录互不影响
+	require.NoError(t, m.Record("s2", "run3", "edit", a))
+	require.NoError(t, m.Record("s1", "", "edit", a))
+
+	list, err := m.List("s1")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "run2", list[0].RunID)
+	assert.Equal(t, "run1", list[1].RunID)
+	require.Len(t, list[1].Files, 2)
+	assert.True(t, list[1].Files[0].Existed)
+	assert.False(t, list[1].Files[1].Existed)
+	assert.Equal(t, "write", list[1].Files[1].Source)
+
+	// 恢复 run2：只撤销 run2
+	result, err := m.Restore("s1", "run2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"run2"}, result.Runs)
+	assertFileContent(t, a, "a1")
+	assertFileContent(t, b, "b1")
+
+	// 恢复 run1：a 回到初始内容，run1 新建的 b 被删除
+	result, err = m.Restore("s1", "run1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{a}, result.Restored)
+	assert.Equal(t, []string{b}, result.Deleted)
+	assertFileContent(t, a, "a0")
+	assert.NoFileExists(t, b)
+
+	list, err = m.List("s1")
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	list, err = m.List("s2")
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	_, err = m.Restore("s1", "run1")
+	assert.Error(t, err)
+}
+
+func TestCheckpointManager_RestoreRevertsLaterRuns(t *testing.T) {
+	workspace := t.TempDir()
+	f := filepath.Join(workspace, "f.txt")
+	require.NoError(t, os.WriteFile(f, []byte("v0"), 0600))
+	m := NewCheckpointManager(workspace)
+
+	for i, run := range []string{"r1", "r2", "r3"} {
+		require.NoError(t, m.Record("", run, "edit", f))
+		require.NoError(t, os.WriteFile(f, []byte{'v', byte('1' + i)}, 0644))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	result, err := m.Restore("", "r2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"r3", "r2"}, result.Runs)
+	assertFileContent(t, f, "v1")
+
+	list, err := m.List("")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "r1", list[0].RunID)
+
+	_, err = m.Restore("", "r1")
+	require.NoError(t, err)
+	assertFileContent(t, f, "v0")
+	info, err := os.Stat(f)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestCheckpointManager_Prune(t *testing.T) {
+	workspace := t.TempDir()
+	f := filepath.Join(workspace, "f.txt")
+	require.NoError(t, os.WriteFile(f, []byte("x"), 0644))
+	m := NewCheckpointManager(workspace)
+	m.SetMaxCheckpoints(2)
+
+	for _, run := range []string{"r1", "r2", "r3"} {
+		require.NoError(t, m.Record("s", run, "edit", f))
+		time.Sleep(10 * time.Millisecond)
+	}
+	list, err := m.List("s")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "r3", list[0].RunID)
+	assert.Equal(t, "r2", list[1].RunID)
+}
+
+func TestCheckpointManager_RecordSnapshotChanges(t *testing.T) {
+	workspace := t.TempDir()
+	modified := filepath.Join(workspace, "modified.txt")
+	deleted := filepath.Join(workspace, "deleted.txt")
+	untouched := filepath.Join(workspace, "untouched.txt")
+	large := filepath.Join(workspace, "large.bin")
+	for _, p := range []string{modified, deleted, untouched} {
+		require.NoError(t, os.WriteFile(p, []byte("orig "+filepath.Base(p)), 0644))
+	}
+	require.NoError(t, os.WriteFile(large, make([]byte, 64), 0644))
+
+	limits := DefaultSnapshotLimits()
+	limits.MaxFileSize = 32
+	before, err := SnapshotWorkspace(workspace, limits)
+	require.NoError(t, err)
+
+	// 模拟 bash 命令的改动
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, os.WriteFile(modified, []byte("changed"), 0644))
+	require.NoError(t, os.Remove(deleted))
+	created := filepath.Join(workspace, "dir", "created.txt")
+	require.NoError(t, os.MkdirAll(filepath.Dir(created), 0755))
+	require.NoError(t, os.WriteFile(created, []byte("new"), 0644))
+	require.NoError(t, os.WriteFile(large, make([]byte, 128), 0644))
+
+	m := NewCheckpointManager(workspace)
+	recorded, skipped, err := m.RecordSnapshotChanges("s", "run1", "bash", before)
+	require.NoError(t, err)
+	assert.Equal(t, 3, recorded)
+	assert.Equal(t, []string{large}, skipped)
+
+	_, err = m.Restore("s", "run1")
+	require.NoError(t, err)
+	assertFileContent(t, modified, "orig modified.txt")
+	assertFileContent(t, deleted, "orig deleted.txt")
+	assertFileContent(t, untouched, "orig untouched.txt")
+	assert.NoFileExists(t, created)
+}
+
+func assertFileContent(t *testing.T, path, expected string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(data))
+}
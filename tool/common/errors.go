@@ -50,6 +50,10 @@ const (
 	ErrCodeBackupFailed       ErrorCode = "BACKUP_FAILED"
 	ErrCodeRestoreFailed      ErrorCode = "RESTORE_FAILED"
 	ErrCodeVersionInvalid     ErrorCode = "VERSION_INVALID"
+	ErrCodePatchInvalid       ErrorCode = "PATCH_INVALID"
+	ErrCodePatchFailed        ErrorCode = "PATCH_FAILED"
+	ErrCodeCheckpointFailed   ErrorCode = "CHECKPOINT_FAILED"
+	ErrCodeCheckpointInvalid  ErrorCode = "CHECKPOINT_INVALID"
 
 	// Search errors
 	ErrCodeQueryEmpty    ErrorCode = "QUERY_EMPTY"
@@ -104,6 +108,10 @@ var ErrorMessages = map[ErrorCode]string{
 	ErrCodeBackupFailed:       "Failed to create backup",
 	ErrCodeRestoreFailed:      "Failed to restore backup",
 	ErrCodeVersionInvalid:     "Invalid version number",
+	ErrCodePatchInvalid:       "Invalid patch",
+	ErrCodePatchFailed:        "Failed to apply patch",
+	ErrCodeCheckpointFailed:   "Failed to record checkpoint",
+	ErrCodeCheckpointInvalid:  "Failed to restore checkpoint",
 
 	// Search
 	ErrCodeQueryEmpty:     "Search query cannot be empty",
@@ -208,6 +216,14 @@ func ErrBackupFailed(path string) *ToolError      { return NewErrorf(ErrCodeBack
 func ErrBackupNotFound(path string) *ToolError    { return NewErrorf(ErrCodeBackupNotFound, "%s", path) }
 func ErrRestoreFailed(detail string) *ToolError   { return NewErrorf(ErrCodeRestoreFailed, "%s", detail) }
 func ErrVersionInvalid() *ToolError               { return NewError(ErrCodeVersionInvalid, "version must be > 0") }
+func ErrPatchInvalid(detail string) *ToolError    { return NewErrorf(ErrCodePatchInvalid, "%s", detail) }
+func ErrPatchFailed(detail string) *ToolError     { return NewErrorf(ErrCodePatchFailed, "%s", detail) }
+func ErrCheckpointFailed(detail string) *ToolError {
+	return NewErrorf(ErrCodeCheckpointFailed, "%s", detail)
+}
+func ErrCheckpointInvalid(detail string) *ToolError {
+	return NewErrorf(ErrCodeCheckpointInvalid, "%s", detail)
+}
 
 // Search errors
 func ErrQueryEmpty() *ToolError  { return NewError(ErrCodeQueryEmpty, "") }
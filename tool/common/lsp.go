@@ -0,0 +1,570 @@
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LSPProviderConfig 基于 Language Server Protocol 的诊断 provider 配置。
+// 语言服务器经 stdio 通信，首次诊断时启动并常驻复用；写入/编辑后以 didOpen/didChange 同步全文，
+// 收集 textDocument/publishDiagnostics（去抖后返回）。注册示例：
+//
+//	common.RegisterLSPDiagnosticProvider(common.LSPProviderConfig{
+//	    Exts: []string{".go"}, Command: "gopls", RootDir: "/path/to/project",
+//	})
+type LSPProviderConfig struct {
+	// Exts 关联扩展名（含点，如 ".ts"），一个语言服务器可处理多个扩展名。
+	Exts []string
+	// Command 语言服务器命令（如 "gopls"、"typescript-language-server"）。PATH 中不可用时静默跳过。
+	Command string
+	// Args 命令参数（如 ["--stdio"]）。
+	Args []string
+	// Env 额外环境变量（KEY=VALUE），追加到当前进程环境。
+	Env []string
+	// RootDir 工作区根目录（rootUri），空则取首个诊断文件所在目录。
+	RootDir string
+	// LanguageID 文档语言 ID，空则按扩展名推断（.go→go、.ts→typescript 等）。
+	LanguageID string
+	// InitializationOptions 传给 initialize 的服务器专属选项。
+	InitializationOptions any
+	// Debounce 收到诊断后等待后续更新的静默时间，<=0 取默认 300ms（服务器常先发语法诊断、再发语义诊断）。
+	Debounce time.Duration
+	// Timeout 单次诊断（含启动与初始化）的最长等待，<=0 取默认 10s；超时返回已收到的诊断。
+	Timeout time.Duration
+}
+
+// lspLanguageIDs 扩展名到 LSP languageId 的默认映射。
+var lspLanguageIDs = map[string]string{
+	".go": "go", ".py": "python", ".rs": "rust", ".java": "java", ".c": "c", ".h": "c",
+	".cpp": "cpp", ".cc": "cpp", ".hpp": "cpp", ".cs": "csharp", ".rb": "ruby", ".php": "php",
+	".js": "javascript", ".jsx": "javascriptreact", ".ts": "typescript", ".tsx": "typescriptreact",
+	".sh": "shellscript", ".json": "json", ".yaml": "yaml", ".yml": "yaml", ".lua": "lua",
+}
+
+// LSPDiagnosticProvider 经 LSP 获取诊断的 provider，语言服务器进程跨调用常驻；进程退出后下次诊断自动重启。
+type LSPDiagnosticProvider struct {
+	cfg LSPProviderConfig
+
+	// mu 串行化诊断请求（同步文档 + 等待诊断）
+	mu     sync.Mutex
+	client *lspClient
+}
+
+// NewLSPDiagnosticProvider 构造 LSP provider，补默认值、规范化扩展名。进程在首次 Report 时启动。
+func NewLSPDiagnosticProvider(cfg LSPProviderConfig) *LSPDiagnosticProvider {
+	if cfg.Debounce <= 0 {
+		cfg.Debounce = 300 * time.Millisecond
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	// 复制后再规范化，避免修改调用方的切片
+	exts := make([]string, len(cfg.Exts))
+	for i, ext := range cfg.Exts {
+		exts[i] = strings.ToLower(ext)
+	}
+	cfg.Exts = exts
+	return &LSPDiagnosticProvider{cfg: cfg}
+}
+
+// RegisterLSPDiagnosticProvider 构造 LSP provider 并为其所有扩展名注册，返回 provider 供应用退出时 Close。
+func RegisterLSPDiagnosticProvider(cfg LSPProviderConfig) *LSPDiagnosticProvider {
+	p := NewLSPDiagnosticProvider(cfg)
+	for _, ext := range p.cfg.Exts {
+		RegisterDiagnosticProvider(ext, p)
+	}
+	return p
+}
+
+// Supports 按扩展名匹配（大小写不敏感）。
+func (p *LSPDiagnosticProvider) Supports(filePath string) bool {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	for _, e := range p.cfg.Exts {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// Report 同步文件内容到语言服务器并返回其发布的诊断。命令不可用时返回 nil（不阻塞主流程）。
+func (p *LSPDiagnosticProvider) Report(filePath string) ([]Diagnostic, error) {
+	if !p.Supports(filePath) {
+		return nil, nil
+	}
+	if _, err := exec.LookPath(p.cfg.Command); err != nil {
+		return nil, nil
+	}
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	deadline := time.Now().Add(p.cfg.Timeout)
+
+	if p.client == nil || p.client.isClosed() {
+		root := p.cfg.RootDir
+		if root == "" {
+			root = filepath.Dir(absPath)
+		}
+		client, err := startLSPClient(p.cfg, root, deadline)
+		if err != nil {
+			return nil, fmt.Errorf("start language server: %w", err)
+		}
+		p.client = client
+	}
+	return p.client.diagnose(absPath, p.languageID(absPath), string(content), p.cfg.Debounce, deadline)
+}
+
+// Close 关闭语言服务器（shutdown + exit），之后的 Report 会重新启动。
+func (p *LSPDiagnosticProvider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.client == nil {
+		return nil
+	}
+	err := p.client.close()
+	p.client = nil
+	return err
+}
+
+func (p *LSPDiagnosticProvider) languageID(filePath string) string {
+	if p.cfg.LanguageID != "" {
+		return p.cfg.LanguageID
+	}
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if id, ok := lspLanguageIDs[ext]; ok {
+		return id
+	}
+	return strings.TrimPrefix(ext, ".")
+}
+
+// lspMessage JSON-RPC 2.0 消息（请求、响应、通知共用）。
+type lspMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *lspError        `json:"error,omitempty"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// lspDocument 已同步给服务器的文档状态与最近一次发布的诊断。
+type lspDocument struct {
+	version int
+	text    string
+	// published 最近一次收到诊断的时间与对应文档版本（服务器未携带版本时为 0）
+	published    time.Time
+	pubVersion   int
+	diagnostics  []Diagnostic
+	hasPublished bool
+}
+
+// lspClient 一个语言服务器进程的连接。
+type lspClient struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	writeM sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[string]chan *lspMessage
+	docs    map[string]*lspDocument
+	// updated 每次收到诊断时关闭并替换，用于唤醒等待方
+	updated chan struct{}
+	closed  bool
+	done    chan struct{}
+}
+
+// startLSPClient 启动语言服务器并完成 initialize/initialized 握手。
+func startLSPClient(cfg LSPProviderConfig, root string, deadline time.Time) (*lspClient, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Dir = root
+	if len(cfg.Env) > 0 {
+		cmd.Env = append(os.Environ(), cfg.Env...)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = io.Discard
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c := &lspClient{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[string]chan *lspMessage),
+		docs:    make(map[string]*lspDocument),
+		updated: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(bufio.NewReader(stdout))
+
+	rootURI := pathToURI(root)
+	params := map[string]any{
+		"processId": os.Getpid(),
+		"rootUri":   rootURI,
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{
+				"synchronization":    map[string]any{"didSave": true},
+				"publishDiagnostics": map[string]any{"versionSupport": true},
+			},
+			"workspace": map[string]any{"configuration": true, "workspaceFolders": true},
+		},
+		"workspaceFolders": []map[string]string{{"uri": rootURI, "name": filepath.Base(root)}},
+	}
+	if cfg.InitializationOptions != nil {
+		params["initializationOptions"] = cfg.InitializationOptions
+	}
+	if _, err := c.request("initialize", params, deadline); err != nil {
+		c.kill()
+		return nil, err
+	}
+	if err := c.notify("initialized", map[string]any{}); err != nil {
+		c.kill()
+		return nil, err
+	}
+	return c, nil
+}
+
+// diagnose 以 didOpen（首次）或 didChange（全文）同步文档，等待该版本的诊断并去抖。
+// 内容与上次同步相同且已有诊断时直接返回缓存结果。
+func (c *lspClient) diagnose(path, languageID, text string, debounce time.Duration, deadline time.Time) ([]Diagnostic, error) {
+	uri := pathToURI(path)
+	c.mu.Lock()
+	doc, opened := c.docs[path]
+	if opened && doc.text == text && doc.hasPublished {
+		diags := doc.diagnostics
+		c.mu.Unlock()
+		return diags, nil
+	}
+	if !opened {
+		doc = &lspDocument{}
+		c.docs[path] = doc
+	}
+	doc.version++
+	doc.text = text
+	doc.hasPublished = false
+	version := doc.version
+	sent := time.Now()
+	c.mu.Unlock()
+
+	var err error
+	if !opened {
+		err = c.notify("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "languageId": languageID, "version": version, "text": text},
+		})
+	} else {
+		err = c.notify("textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": uri, "version": version},
+			"contentChanges": []map[string]any{{"text": text}},
+		})
+	}
+	if err == nil {
+		err = c.notify("textDocument/didSave", map[string]any{"textDocument": map[string]any{"uri": uri}})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 等待本版本的首次诊断，之后在 debounce 内没有新诊断即返回
+	for {
+		c.mu.Lock()
+		fresh := doc.hasPublished && (doc.pubVersion == version || (doc.pubVersion == 0 && doc.published.After(sent)))
+		diags, last, updated, closed := doc.diagnostics, doc.published, c.updated, c.closed
+		c.mu.Unlock()
+
+		wait := time.Until(deadline)
+		if fresh {
+			if quiet := debounce - time.Since(last); quiet < wait {
+				wait = quiet
+			}
+			if wait <= 0 {
+				return diags, nil
+			}
+		} else if closed {
+			return nil, errors.New("language server exited")
+		} else if wait <= 0 {
+			return nil, nil // 超时未收到诊断，不阻塞主流程
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-updated:
+		case <-timer.C:
+			if !fresh {
+				return nil, nil
+			}
+		}
+		timer.Stop()
+	}
+}
+
+// request 发送请求并等待响应。
+func (c *lspClient) request(method string, params any, deadline time.Time) (json.RawMessage, error) {
+	c.mu.Lock()
+	c.nextID++
+	id := strconv.Itoa(c.nextID)
+	ch := make(chan *lspMessage, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	raw := json.RawMessage(id)
+	if err := c.send(&lspMessage{ID: &raw, Method: method}, params); err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return nil, fmt.Errorf("%s: %s (code %d)", method, resp.Error.Message, resp.Error.Code)
+		}
+		return resp.Result, nil
+	case <-c.done:
+		return nil, fmt.Errorf("%s: language server exited", method)
+	case <-timer.C:
+		return nil, fmt.Errorf("%s: timed out", method)
+	}
+}
+
+func (c *lspClient) notify(method string, params any) error {
+	return c.send(&lspMessage{Method: method}, params)
+}
+
+// send 以 Content-Length 帧写出消息。
+func (c *lspClient) send(msg *lspMessage, params any) error {
+	msg.JSONRPC = "2.0"
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		msg.Params = raw
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeM.Lock()
+	defer c.writeM.Unlock()
+	if _, err := fmt.Fprintf(c.stdin, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.stdin.Write(body)
+	return err
+}
+
+// readLoop 读取服务器消息：响应交给等待的请求，诊断写入文档状态，服务器请求回复空结果。
+func (c *lspClient) readLoop(r *bufio.Reader) {
+	defer func() {
+		c.mu.Lock()
+		c.closed = true
+		close(c.updated)
+		c.mu.Unlock()
+		close(c.done)
+		_ = c.cmd.Wait()
+	}()
+	for {
+		body, err := readLSPFrame(r)
+		if err != nil {
+			return
+		}
+		var msg lspMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			continue
+		}
+		switch {
+		case msg.Method == "" && msg.ID != nil:
+			c.mu.Lock()
+			ch := c.pending[string(*msg.ID)]
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- &msg
+			}
+		case msg.Method == "textDocument/publishDiagnostics":
+			c.handleDiagnostics(msg.Params)
+		case msg.ID != nil:
+			c.replyServerRequest(&msg)
+		}
+	}
+}
+
+// replyServerRequest 回复服务器发起的请求（workspace/configuration 等），客户端不提供额外能力。
+func (c *lspClient) replyServerRequest(msg *lspMessage) {
+	var result any
+	if msg.Method == "workspace/configuration" {
+		var params struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		_ = json.Unmarshal(msg.Params, &params)
+		result = make([]any, len(params.Items))
+	}
+	raw, _ := json.Marshal(result)
+	resp := &lspMessage{JSONRPC: "2.0", ID: msg.ID, Result: raw}
+	body, _ := json.Marshal(resp)
+	c.writeM.Lock()
+	defer c.writeM.Unlock()
+	fmt.Fprintf(c.stdin, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// lspDiagnostic LSP 诊断结构（只取需要的字段）。
+type lspDiagnostic struct {
+	Range struct {
+		Start struct {
+			Line      int `json:"line"`
+			Character int `json:"character"`
+		} `json:"start"`
+	} `json:"range"`
+	Severity int    `json:"severity"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+// handleDiagnostics 记录诊断并唤醒等待方。LSP 严重度与 Severity* 常量取值一致，缺省按 Error；行列转为 1 起始。
+func (c *lspClient) handleDiagnostics(raw json.RawMessage) {
+	var params struct {
+		URI         string          `json:"uri"`
+		Version     int             `json:"version"`
+		Diagnostics []lspDiagnostic `json:"diagnostics"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return
+	}
+	diags := make([]Diagnostic, 0, len(params.Diagnostics))
+	for _, d := range params.Diagnostics {
+		sev := d.Severity
+		if sev < SeverityError || sev > SeverityHint {
+			sev = SeverityError
+		}
+		msg := d.Message
+		if d.Source != "" {
+			msg = d.Source + ": " + msg
+		}
+		diags = append(diags, Diagnostic{Severity: sev, Line: d.Range.Start.Line + 1, Col: d.Range.Start.Character + 1, Message: msg})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, ok := c.docs[uriToPath(params.URI)]
+	if !ok || c.closed {
+		return
+	}
+	if params.Version != 0 && params.Version < doc.version {
+		return // 旧版本的诊断
+	}
+	doc.diagnostics = diags
+	doc.published = time.Now()
+	doc.pubVersion = params.Version
+	doc.hasPublished = true
+	close(c.updated)
+	c.updated = make(chan struct{})
+}
+
+func (c *lspClient) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// close 按协议 shutdown + exit，服务器未及时退出时强制结束。
+func (c *lspClient) close() error {
+	if c.isClosed() {
+		return nil
+	}
+	_, err := c.request("shutdown", nil, time.Now().Add(2*time.Second))
+	_ = c.notify("exit", nil)
+	select {
+	case <-c.done:
+	case <-time.After(2 * time.Second):
+		c.kill()
+		<-c.done
+	}
+	return err
+}
+
+func (c *lspClient) kill() {
+	if c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+}
+
+// readLSPFrame 读取一个 Content-Length 帧的消息体。
+func readLSPFrame(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length: %w", err)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("missing Content-Length header")
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(r, body)
+	return body, err
+}
+
+// pathToURI 绝对路径转 file:// URI（Windows 盘符前补 /）。
+func pathToURI(path string) string {
+	p := filepath.ToSlash(path)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+// uriToPath file:// URI 转本地路径。
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	p := u.Path
+	if len(p) >= 3 && p[0] == '/' && p[2] == ':' {
+		p = p[1:] // /C:/x → C:/x
+	}
+	return filepath.FromSlash(p)
+}
@@ -0,0 +1,293 @@
+package common
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeLSPServer 测试用语言服务器：每次 didOpen/didChange 先发布空诊断，50ms 后再发布真实诊断（验证去抖）。
+// 含 ERROR/WARN/HINT 的行分别产生对应严重度的诊断，含 NOSEV 的行不带严重度；消息附带 open/change 计数。
+const fakeLSPServer = `package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var mu sync.Mutex
+
+func write(v any) {
+	body, _ := json.Marshal(v)
+	mu.Lock()
+	defer mu.Unlock()
+	fmt.Fprintf(os.Stdout, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func publish(uri string, version int, text string, opens, changes int) {
+	write(map[string]any{"jsonrpc": "2.0", "method": "textDocument/publishDiagnostics",
+		"params": map[string]any{"uri": uri, "version": version, "diagnostics": []any{}}})
+	time.Sleep(50 * time.Millisecond)
+	var diags []any
+	for i, line := range strings.Split(text, "\n") {
+		for kw, sev := range map[string]int{"ERROR": 1, "WARN": 2, "HINT": 4, "NOSEV": 0} {
+			col := strings.Index(line, kw)
+			if col < 0 {
+				continue
+			}
+			d := map[string]any{
+				"range":   map[string]any{"start": map[string]any{"line": i, "character": col}, "end": map[string]any{"line": i, "character": col + len(kw)}},
+				"source":  "fake",
+				"message": fmt.Sprintf("%s found (opens=%d changes=%d)", kw, opens, changes),
+			}
+			if sev > 0 {
+				d["severity"] = sev
+			}
+			diags = append(diags, d)
+		}
+	}
+	write(map[string]any{"jsonrpc": "2.0", "method": "textDocument/publishDiagnostics",
+		"params": map[string]any{"uri": uri, "version": version, "diagnostics": diags}})
+}
+
+func main() {
+	r := bufio.NewReader(os.Stdin)
+	opens, changes := 0, 0
+	for {
+		length := 0
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				break
+			}
+			if v, ok := strings.CutPrefix(line, "Content-Length: "); ok {
+				length, _ = strconv.Atoi(v)
+			}
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+		var msg struct {
+			ID     json.RawMessage ` + "`json:\"id\"`" + `
+			Method string          ` + "`json:\"method\"`" + `
+			Params struct {
+				TextDocument struct {
+					URI     string ` + "`json:\"uri\"`" + `
+					Version int    ` + "`json:\"version\"`" + `
+					Text    string ` + "`json:\"text\"`" + `
+				} ` + "`json:\"textDocument\"`" + `
+				ContentChanges []struct {
+					Text string ` + "`json:\"text\"`" + `
+				} ` + "`json:\"contentChanges\"`" + `
+			} ` + "`json:\"params\"`" + `
+		}
+		json.Unmarshal(body, &msg)
+		switch msg.Method {
+		case "initialize":
+			write(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": map[string]any{"capabilities": map[string]any{"textDocumentSync": 1}}})
+			// 服务器发起的请求，客户端应回复
+			write(map[string]any{"jsonrpc": "2.0", "id": 100, "method": "workspace/configuration", "params": map[string]any{"items": []any{map[string]any{}}}})
+		case "textDocument/didOpen":
+			opens++
+			doc := msg.Params.TextDocument
+			go publish(doc.URI, doc.Version, doc.Text, opens, changes)
+		case "textDocument/didChange":
+			changes++
+			doc := msg.Params.TextDocument
+			go publish(doc.URI, doc.Version, msg.Params.ContentChanges[0].Text, opens, changes)
+		case "shutdown":
+			write(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": nil})
+		case "exit":
+			return
+		}
+	}
+}
+`
+
+// buildFakeLSPServer 编译假语言服务器，返回可执行文件路径
+func buildFakeLSPServer(t *testing.T) string {
+	t.Helper()
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(fakeLSPServer), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module fakelsp\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(dir, "fakelsp")
+	cmd := exec.Command(goBin, "build", "-o", bin, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=", "GOWORK=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("build fake lsp server: %v\n%s", err, out)
+	}
+	return bin
+}
+
+func newFakeLSPProvider(t *testing.T) (*LSPDiagnosticProvider, string) {
+	t.Helper()
+	bin := buildFakeLSPServer(t)
+	root := t.TempDir()
+	p := NewLSPDiagnosticProvider(LSPProviderConfig{
+		Exts:     []string{".FAKE"},
+		Command:  bin,
+		RootDir:  root,
+		Debounce: 150 * time.Millisecond,
+		Timeout:  5 * time.Second,
+	})
+	t.Cleanup(func() { p.Close() })
+	return p, root
+}
+
+func TestLSPProvider_Supports(t *testing.T) {
+	exts := []string{".TS", ".tsx"}
+	p := NewLSPDiagnosticProvider(LSPProviderConfig{Exts: exts, Command: "x"})
+	if !p.Supports("/a/b.ts") || !p.Supports("/a/b.TSX") {
+		t.Fatal("should support .ts and .tsx")
+	}
+	if p.Supports("/a/b.go") {
+		t.Fatal("should not support .go")
+	}
+	if exts[0] != ".TS" {
+		t.Fatalf("caller's Exts should not be modified, got %v", exts)
+	}
+}
+
+func TestLSPProvider_CommandMissingSkips(t *testing.T) {
+	p := NewLSPDiagnosticProvider(LSPProviderConfig{Exts: []string{".x"}, Command: "no-such-language-server-xyz"})
+	f := filepath.Join(t.TempDir(), "a.x")
+	os.WriteFile(f, []byte("ERROR"), 0644)
+	diags, err := p.Report(f)
+	if err != nil || diags != nil {
+		t.Fatalf("missing command should skip silently, got %v, %v", diags, err)
+	}
+}
+
+func TestLSPProvider_DiagnosticsAndSeverities(t *testing.T) {
+	p, root := newFakeLSPProvider(t)
+	f := filepath.Join(root, "a.fake")
+	os.WriteFile(f, []byte("ok\n  ERROR here\nWARN\nHINT NOSEV\n"), 0644)
+
+	diags, err := p.Report(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 先发布的空诊断应被去抖覆盖
+	if len(diags) != 4 {
+		t.Fatalf("want 4 diagnostics, got %+v", diags)
+	}
+	got := map[string]Diagnostic{}
+	for _, d := range diags {
+		got[strings.Fields(strings.TrimPrefix(d.Message, "fake: "))[0]] = d
+	}
+	if d := got["ERROR"]; d.Severity != SeverityError || d.Line != 2 || d.Col != 3 || !strings.HasPrefix(d.Message, "fake: ERROR found") {
+		t.Fatalf("unexpected ERROR diagnostic: %+v", d)
+	}
+	if d := got["WARN"]; d.Severity != SeverityWarning || d.Line != 3 || d.Col != 1 {
+		t.Fatalf("unexpected WARN diagnostic: %+v", d)
+	}
+	if d := got["HINT"]; d.Severity != SeverityHint || d.Line != 4 {
+		t.Fatalf("unexpected HINT diagnostic: %+v", d)
+	}
+	if d := got["NOSEV"]; d.Severity != SeverityError || d.Col != 6 {
+		t.Fatalf("missing severity should map to error: %+v", d)
+	}
+	if report := DiagnosticReport(f, diags, 10); !strings.Contains(report, "ERROR found") || strings.Contains(report, "WARN found") {
+		t.Fatalf("report should contain only errors: %s", report)
+	}
+}
+
+func TestLSPProvider_WarmServerUsesDidChange(t *testing.T) {
+	p, root := newFakeLSPProvider(t)
+	f := filepath.Join(root, "a.fake")
+	os.WriteFile(f, []byte("ERROR\n"), 0644)
+	diags, err := p.Report(f)
+	if err != nil || len(diags) != 1 || !strings.Contains(diags[0].Message, "opens=1 changes=0") {
+		t.Fatalf("first report: %+v, %v", diags, err)
+	}
+
+	// 编辑后复用同一进程，以 didChange 同步
+	os.WriteFile(f, []byte("fine\nERROR\n"), 0644)
+	diags, err = p.Report(f)
+	if err != nil || len(diags) != 1 || diags[0].Line != 2 || !strings.Contains(diags[0].Message, "opens=1 changes=1") {
+		t.Fatalf("second report: %+v, %v", diags, err)
+	}
+
+	// 内容未变直接返回缓存
+	diags, err = p.Report(f)
+	if err != nil || len(diags) != 1 || !strings.Contains(diags[0].Message, "changes=1") {
+		t.Fatalf("unchanged report: %+v, %v", diags, err)
+	}
+
+	// 修复后诊断清空
+	os.WriteFile(f, []byte("fine\n"), 0644)
+	diags, err = p.Report(f)
+	if err != nil || len(diags) != 0 {
+		t.Fatalf("fixed report: %+v, %v", diags, err)
+	}
+
+	// 另一个文件走 didOpen，仍是同一进程
+	g := filepath.Join(root, "b.fake")
+	os.WriteFile(g, []byte("ERROR\n"), 0644)
+	diags, err = p.Report(g)
+	if err != nil || len(diags) != 1 || !strings.Contains(diags[0].Message, "opens=2 changes=2") {
+		t.Fatalf("second file: %+v, %v", diags, err)
+	}
+}
+
+func TestLSPProvider_RestartAfterClose(t *testing.T) {
+	p, root := newFakeLSPProvider(t)
+	f := filepath.Join(root, "a.fake")
+	os.WriteFile(f, []byte("ERROR\n"), 0644)
+	if _, err := p.Report(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	diags, err := p.Report(f)
+	if err != nil || len(diags) != 1 || !strings.Contains(diags[0].Message, "opens=1 changes=0") {
+		t.Fatalf("report after restart: %+v, %v", diags, err)
+	}
+}
+
+func TestLSPProvider_RegisterForAllExts(t *testing.T) {
+	p := RegisterLSPDiagnosticProvider(LSPProviderConfig{Exts: []string{".lspa", ".lspb"}, Command: "x"})
+	defer func() {
+		diagnosticProviders.Delete(".lspa")
+		diagnosticProviders.Delete(".lspb")
+	}()
+	if LookupDiagnosticProvider("/x/y.lspa") != p || LookupDiagnosticProvider("/x/y.lspb") != p {
+		t.Fatal("provider should be registered for every extension")
+	}
+}
+
+func TestPathURIRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir with space", "a#b.go")
+	uri := pathToURI(path)
+	if !strings.HasPrefix(uri, "file:///") || strings.Contains(uri, " ") {
+		t.Fatalf("unexpected uri %q", uri)
+	}
+	if got := uriToPath(uri); got != path {
+		t.Fatalf("round trip: got %q want %q", got, path)
+	}
+}
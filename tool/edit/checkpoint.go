@@ -0,0 +1,73 @@
+package edit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rulego/rulego-components-ai/tool/common"
+)
+
+// recordCheckpoint 把文件改动前的状态记入本次执行（ctx 中的 runId）的检查点，未注入 runId 时跳过。
+// 返回非空字符串表示记录失败，应放弃本次编辑，避免出现无法撤销的改动。
+func recordCheckpoint(ctx context.Context, checkpoints *common.CheckpointManager, sessionId string, paths ...string) string {
+	runId := common.RunIDFromCtx(ctx)
+	if runId == "" {
+		return ""
+	}
+	for _, p := range paths {
+		if err := checkpoints.Record(sessionId, runId, ToolName, p); err != nil {
+			return common.ErrCheckpointFailed(fmt.Sprintf("%s: %v", checkpoints.Rel(p), err)).Error()
+		}
+	}
+	return ""
+}
+
+// listCheckpoints 列出当前会话的检查点（新的在前）
+func (t *editTool) listCheckpoints(sessionId string, checkpoints *common.CheckpointManager) (string, error) {
+	list, err := checkpoints.List(sessionId)
+	if err != nil {
+		return "", fmt.Errorf("list checkpoints: %w", err)
+	}
+	if len(list) == 0 {
+		return "No checkpoints found for this session", nil
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Checkpoints (%d, newest first; restoring one reverts it and every later run):\n", len(list)))
+	for _, cp := range list {
+		var files []string
+		for i, f := range cp.Files {
+			if i == 5 {
+				files = append(files, fmt.Sprintf("... +%d more", len(cp.Files)-5))
+				break
+			}
+			files = append(files, checkpoints.Rel(f.Path))
+		}
+		result.WriteString(fmt.Sprintf("  %s  %s  %d file(s): %s\n",
+			cp.RunID, cp.CreatedAt.Format("2006-01-02 15:04:05"), len(cp.Files), strings.Join(files, ", ")))
+	}
+	return strings.TrimRight(result.String(), "\n"), nil
+}
+
+// restoreCheckpoint 把工作目录恢复到指定执行开始前的状态
+func (t *editTool) restoreCheckpoint(params OperationParams, sessionId string, checkpoints *common.CheckpointManager) (string, error) {
+	if params.CheckpointID == "" {
+		return common.ErrCheckpointInvalid("checkpoint_id is required; use list_checkpoints to find it").Error(), nil
+	}
+	restored, err := checkpoints.Restore(sessionId, params.CheckpointID)
+	if err != nil {
+		return common.ErrCheckpointInvalid(err.Error()).Error(), nil
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Success: Restored workspace to before run %s (reverted %d run(s): %s)",
+		params.CheckpointID, len(restored.Runs), strings.Join(restored.Runs, ", ")))
+	for _, p := range restored.Restored {
+		result.WriteString("\n  Restored: " + checkpoints.Rel(p))
+	}
+	for _, p := range restored.Deleted {
+		result.WriteString("\n  Deleted: " + checkpoints.Rel(p))
+	}
+	return result.String(), nil
+}
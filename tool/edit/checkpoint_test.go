@@ -0,0 +1,68 @@
+package edit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/rulego/rulego-components-ai/session"
+	"github.com/rulego/rulego-components-ai/tool/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointUndo(t *testing.T) {
+	invokable, workDir := newPatchTool(t)
+	writeFiles(t, workDir, map[string]string{
+		"a.txt": "a0\n",
+		"b.txt": "b0\n",
+	})
+	base := session.WithSessionKey(context.Background(), "sess-1")
+	run := func(runId string, params map[string]interface{}) string {
+		result, err := invokable.InvokableRun(common.WithRunID(base, runId), buildParams(params))
+		require.NoError(t, err)
+		t.Logf("Result: %s", result)
+		return result
+	}
+
+	// run1：patch 修改 a、新建 c
+	result := run("run1", map[string]interface{}{
+		"operation": "patch",
+		"patch":     "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-a0\n+a1\n--- /dev/null\n+++ b/c.txt\n@@ -0,0 +1 @@\n+c1\n",
+	})
+	require.Contains(t, result, "Success")
+
+	// run2：search 修改 a、b
+	for _, p := range [][2]string{{"a.txt", "a1"}, {"b.txt", "b0"}} {
+		result = run("run2", map[string]interface{}{"operation": "search", "path": p[0], "search": p[1], "replace": p[1] + "-run2"})
+		require.Contains(t, result, "Success")
+	}
+
+	result = run("run3", map[string]interface{}{"operation": "list_checkpoints"})
+	assert.Contains(t, result, "Checkpoints (2")
+	assert.Contains(t, result, "run2")
+	assert.Contains(t, result, "2 file(s): a.txt, b.txt")
+	assert.Contains(t, result, "2 file(s): a.txt, c.txt")
+
+	// 撤销 run2
+	result = run("run3", map[string]interface{}{"operation": "restore_checkpoint", "checkpoint_id": "run2"})
+	assert.Contains(t, result, "Success: Restored workspace to before run run2")
+	assert.Equal(t, "a1\n", readFile(t, workDir, "a.txt"))
+	assert.Equal(t, "b0\n", readFile(t, workDir, "b.txt"))
+
+	// 撤销 run1：新建的 c 被删除
+	result = run("run3", map[string]interface{}{"operation": "restore_checkpoint", "checkpoint_id": "run1"})
+	assert.Contains(t, result, "Deleted: c.txt")
+	assert.Equal(t, "a0\n", readFile(t, workDir, "a.txt"))
+	assert.NoFileExists(t, filepath.Join(workDir, "c.txt"))
+
+	result = run("run3", map[string]interface{}{"operation": "restore_checkpoint", "checkpoint_id": "run1"})
+	assert.Contains(t, result, "not found")
+	result = run("run3", map[string]interface{}{"operation": "restore_checkpoint"})
+	assert.Contains(t, result, "checkpoint_id is required")
+
+	// 其他会话看不到本会话的检查点
+	result, err := invokable.InvokableRun(session.WithSessionKey(context.Background(), "sess-2"), buildParams(map[string]interface{}{"operation": "list_checkpoints"}))
+	require.NoError(t, err)
+	assert.Contains(t, result, "No checkpoints found")
+}
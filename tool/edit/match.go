@@ -0,0 +1,289 @@
+package edit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rulego/rulego-components-ai/tool/common"
+)
+
+// search 操作的匹配级联：精确匹配 → 忽略行尾空白 → 忽略缩进 → 首尾行锚定的相似块。
+// 前一级没有候选时才进入下一级；任一级出现多个候选时拒绝编辑并返回候选行范围，由模型补充上下文后重试。
+// 非精确匹配按整行替换，replace 会按目标位置的缩进重新缩进。
+
+// matchStrategy 匹配级别
+type matchStrategy string
+
+const (
+	matchExact       matchStrategy = "exact"
+	matchLineTrimmed matchStrategy = "line-trimmed"
+	matchIndentation matchStrategy = "indentation-normalized"
+	matchBlockAnchor matchStrategy = "block-anchor"
+)
+
+const (
+	// blockAnchorMinLines 首尾锚定匹配要求的最少搜索行数
+	blockAnchorMinLines = 3
+	// blockAnchorThreshold 首尾锚定时中间行的最低相似度
+	blockAnchorThreshold = 0.6
+	// maxMatchCandidates 歧义时最多列出的候选数
+	maxMatchCandidates = 5
+)
+
+// lineMatch 一个候选位置，start/end 为 0 起始的行区间 [start, end)；精确匹配时 offset 为字节偏移
+type lineMatch struct {
+	start, end int
+	offset     int
+}
+
+// matchResult 级联匹配结果
+type matchResult struct {
+	strategy   matchStrategy
+	candidates []lineMatch
+}
+
+// findSearchMatches 按级联在 content（LF 行尾）中查找 search，返回首个有候选的级别
+func findSearchMatches(content, search string) matchResult {
+	if idx := strings.Index(content, search); idx >= 0 {
+		var candidates []lineMatch
+		for off := idx; off >= 0 && off < len(content); {
+			start := strings.Count(content[:off], "\n")
+			end := start + strings.Count(strings.TrimSuffix(search, "\n"), "\n") + 1
+			candidates = append(candidates, lineMatch{start: start, end: end, offset: off})
+			next := strings.Index(content[off+len(search):], search)
+			if next < 0 {
+				break
+			}
+			off += len(search) + next
+		}
+		return matchResult{strategy: matchExact, candidates: candidates}
+	}
+
+	lines := strings.Split(content, "\n")
+	searchLines := splitSearchLines(search)
+	if len(searchLines) == 0 {
+		return matchResult{}
+	}
+	for _, s := range []struct {
+		strategy matchStrategy
+		equal    func(a, b []string) bool
+	}{
+		{matchLineTrimmed, linesEqualTrimRight},
+		{matchIndentation, linesEqualIndentation},
+	} {
+		var candidates []lineMatch
+		for i := 0; i+len(searchLines) <= len(lines); i++ {
+			if s.equal(lines[i:i+len(searchLines)], searchLines) {
+				candidates = append(candidates, lineMatch{start: i, end: i + len(searchLines)})
+			}
+		}
+		if len(candidates) > 0 {
+			return matchResult{strategy: s.strategy, candidates: candidates}
+		}
+	}
+	if candidates := blockAnchorMatches(lines, searchLines); len(candidates) > 0 {
+		return matchResult{strategy: matchBlockAnchor, candidates: candidates}
+	}
+	return matchResult{}
+}
+
+// splitSearchLines 把搜索串拆成行，去掉首尾空行
+func splitSearchLines(search string) []string {
+	lines := strings.Split(search, "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// linesEqualTrimRight 忽略行尾空白逐行比较
+func linesEqualTrimRight(a, b []string) bool {
+	for i := range a {
+		if strings.TrimRight(a[i], " \t\r") != strings.TrimRight(b[i], " \t\r") {
+			return false
+		}
+	}
+	return true
+}
+
+// linesEqualIndentation 忽略行首行尾空白逐行比较（缩进漂移、tab/空格混用）
+func linesEqualIndentation(a, b []string) bool {
+	for i := range a {
+		if strings.TrimSpace(a[i]) != strings.TrimSpace(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// blockAnchorMatches 首尾行（忽略空白）相同、行数相同且中间行足够相似的块
+func blockAnchorMatches(lines, searchLines []string) []lineMatch {
+	n := len(searchLines)
+	if n < blockAnchorMinLines {
+		return nil
+	}
+	first, last := strings.TrimSpace(searchLines[0]), strings.TrimSpace(searchLines[n-1])
+	if first == "" || last == "" {
+		return nil
+	}
+	var candidates []lineMatch
+	for i := 0; i+n <= len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != first || strings.TrimSpace(lines[i+n-1]) != last {
+			continue
+		}
+		total := 0.0
+		for j := 1; j < n-1; j++ {
+			total += similarity(strings.TrimSpace(lines[i+j]), strings.TrimSpace(searchLines[j]))
+		}
+		if total/float64(n-2) >= blockAnchorThreshold {
+			candidates = append(candidates, lineMatch{start: i, end: i + n})
+		}
+	}
+	return candidates
+}
+
+// similarity 基于编辑距离的相似度，1 表示相同
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
+
+// applyLineMatch 用 replace 替换 m 覆盖的整行；目标缩进与搜索串不同时按目标缩进重新缩进 replace
+func applyLineMatch(content string, m lineMatch, search, replace string) string {
+	lines := strings.Split(content, "\n")
+	searchLines := splitSearchLines(search)
+	replacement := splitReplaceLines(replace)
+	target := lines[m.start:m.end]
+	if !sameIndentation(searchLines, target) {
+		replacement = reindent(replacement, searchLines, target, fileIndentUnit(lines))
+	}
+	return strings.Join(append(lines[:m.start:m.start], append(replacement, lines[m.end:]...)...), "\n")
+}
+
+// splitReplaceLines 拆分替换内容；去掉一个末尾换行，空内容表示删除匹配行
+func splitReplaceLines(replace string) []string {
+	replace = strings.TrimSuffix(replace, "\n")
+	if replace == "" {
+		return nil
+	}
+	return strings.Split(replace, "\n")
+}
+
+// sameIndentation 搜索串与目标逐行缩进是否一致
+func sameIndentation(searchLines, target []string) bool {
+	for i := range searchLines {
+		if leadingIndent(searchLines[i]) != leadingIndent(target[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// reindent 把 replace 从搜索串的缩进风格转换为目标位置的缩进：
+// 相对搜索串首行的缩进按层级换算（层宽取搜索串与 replace 中最小的缩进增量），再以目标首行缩进为基准
+func reindent(lines, searchLines, target []string, unit string) []string {
+	base := indentWidth(leadingIndent(firstNonBlank(searchLines)))
+	to := leadingIndent(firstNonBlank(target))
+	step := 0
+	for _, l := range append(append([]string{}, searchLines...), lines...) {
+		if d := indentWidth(leadingIndent(l)) - base; strings.TrimSpace(l) != "" && d > 0 && (step == 0 || d < step) {
+			step = d
+		}
+	}
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		body := strings.TrimLeft(l, " \t")
+		if body == "" {
+			out[i] = l
+			continue
+		}
+		rel := max(indentWidth(leadingIndent(l))-base, 0)
+		if step > 0 && unit != "" {
+			out[i] = to + strings.Repeat(unit, rel/step) + strings.Repeat(" ", rel%step) + body
+		} else {
+			out[i] = to + strings.Repeat(" ", rel) + body
+		}
+	}
+	return out
+}
+
+// fileIndentUnit 推断文件的缩进单位：有 tab 缩进用 tab，否则取最小的空格缩进
+func fileIndentUnit(lines []string) string {
+	spaces := 0
+	for _, l := range lines {
+		indent := leadingIndent(l)
+		if strings.TrimSpace(l) == "" || indent == "" {
+			continue
+		}
+		if indent[0] == '\t' {
+			return "\t"
+		}
+		if n := len(indent) - len(strings.TrimLeft(indent, " ")); n > 0 && (spaces == 0 || n < spaces) {
+			spaces = n
+		}
+	}
+	return strings.Repeat(" ", spaces)
+}
+
+// indentWidth 缩进的列宽，tab 按 4 列计
+func indentWidth(indent string) int {
+	return len(indent) + 3*strings.Count(indent, "\t")
+}
+
+func firstNonBlank(lines []string) string {
+	for _, l := range lines {
+		if strings.TrimSpace(l) != "" {
+			return l
+		}
+	}
+	return ""
+}
+
+func leadingIndent(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// formatCandidates 列出候选行范围与首行预览
+func formatCandidates(content string, candidates []lineMatch) string {
+	lines := strings.Split(content, "\n")
+	var b strings.Builder
+	for i, c := range candidates {
+		if i >= maxMatchCandidates {
+			b.WriteString(fmt.Sprintf("  ... and %d more\n", len(candidates)-maxMatchCandidates))
+			break
+		}
+		preview := common.TruncateString(strings.TrimSpace(firstNonBlank(lines[c.start:c.end])), 100)
+		if c.end-c.start == 1 {
+			b.WriteString(fmt.Sprintf("  line %d: %s\n", c.start+1, preview))
+		} else {
+			b.WriteString(fmt.Sprintf("  lines %d-%d: %s\n", c.start+1, c.end, preview))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
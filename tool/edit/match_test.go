@@ -0,0 +1,97 @@
+package edit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindSearchMatches(t *testing.T) {
+	content := "func a() {\n\tif x {\n\t\treturn 1\n\t}\n}\n\nfunc b() {\n\tif x {\n\t\treturn 2\n\t}\n}\n"
+
+	tests := []struct {
+		name     string
+		search   string
+		strategy matchStrategy
+		ranges   [][2]int
+	}{
+		{"精确匹配", "return 1", matchExact, [][2]int{{2, 3}}},
+		{"精确匹配多处", "\tif x {\n", matchExact, [][2]int{{1, 2}, {7, 8}}},
+		{"忽略行尾空白", "\t\treturn 2  \n\t}", matchLineTrimmed, [][2]int{{8, 10}}},
+		{"忽略缩进", "  if x {\n    return 1\n  }", matchIndentation, [][2]int{{1, 4}}},
+		{"忽略缩进多处", "  }\n}", matchIndentation, [][2]int{{3, 5}, {9, 11}}},
+		{"首尾锚定", "func b() {\n\tif x {\n\t\treturn 3\n\t}\n}", matchBlockAnchor, [][2]int{{6, 11}}},
+		{"首尾锚定多处", "\tif x {\n\t\treturn 0\n\t}", matchBlockAnchor, [][2]int{{1, 4}, {7, 10}}},
+		{"无匹配", "func c() {}", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := findSearchMatches(content, tt.search)
+			assert.Equal(t, tt.strategy, result.strategy)
+			var ranges [][2]int
+			for _, c := range result.candidates {
+				ranges = append(ranges, [2]int{c.start, c.end})
+			}
+			assert.Equal(t, tt.ranges, ranges)
+		})
+	}
+}
+
+func TestEditSearchCascade(t *testing.T) {
+	invokable, workDir := newPatchTool(t)
+	ctx := context.Background()
+	search := func(path, s, r string) string {
+		return runSearch(t, invokable, ctx, path, s, r)
+	}
+
+	// 缩进漂移：replace 按目标缩进重新缩进
+	writeFiles(t, workDir, map[string]string{"a.go": "func a() {\n\t\tif x {\n\t\t\tcall()\n\t\t}\n}\n"})
+	result := search("a.go", "if x {\n\tcall()\n}", "if y {\n\tcall()\n\tdone()\n}")
+	assert.Contains(t, result, "Success")
+	assert.Contains(t, result, "Match: indentation-normalized (lines 2-4)")
+	assert.Equal(t, "func a() {\n\t\tif y {\n\t\t\tcall()\n\t\t\tdone()\n\t\t}\n}\n", readFile(t, workDir, "a.go"))
+
+	// CRLF 文件 + LF 搜索串，写回保持 CRLF
+	writeFiles(t, workDir, map[string]string{"crlf.txt": "one\r\ntwo\r\nthree\r\n"})
+	result = search("crlf.txt", "one\ntwo", "one\n2")
+	assert.Contains(t, result, "Success")
+	assert.Equal(t, "one\r\n2\r\nthree\r\n", readFile(t, workDir, "crlf.txt"))
+
+	// 行尾空白
+	writeFiles(t, workDir, map[string]string{"trail.txt": "a = 1   \nb = 2\n"})
+	result = search("trail.txt", "a = 1\nb = 2", "a = 10\nb = 20")
+	assert.Contains(t, result, "Match: line-trimmed (lines 1-2)")
+	assert.Equal(t, "a = 10\nb = 20\n", readFile(t, workDir, "trail.txt"))
+
+	// 非精确多候选：拒绝编辑并列出行范围
+	original := "x {\n  y()\n}\n\n    x {\n      y()\n    }\n"
+	writeFiles(t, workDir, map[string]string{"amb.txt": original})
+	result = search("amb.txt", "\tx {\n\t  y()\n\t}", "z")
+	assert.Contains(t, result, "SEARCH_NOT_UNIQUE")
+	assert.Contains(t, result, "2 indentation-normalized matches")
+	assert.Contains(t, result, "lines 1-3: x {")
+	assert.Contains(t, result, "lines 5-7: x {")
+	assert.Equal(t, original, readFile(t, workDir, "amb.txt"))
+
+	// 精确多候选同样给出行范围
+	result = search("amb.txt", "y()", "z()")
+	assert.Contains(t, result, "found 2 matches")
+	assert.Contains(t, result, "line 2: y()")
+	assert.Contains(t, result, "line 6: y()")
+}
+
+func runSearch(t *testing.T, invokable tool.InvokableTool, ctx context.Context, path, search, replace string) string {
+	t.Helper()
+	result, err := invokable.InvokableRun(ctx, buildParams(map[string]interface{}{
+		"operation": "search",
+		"path":      path,
+		"search":    search,
+		"replace":   replace,
+	}))
+	require.NoError(t, err)
+	t.Logf("Result: %s", result)
+	return result
+}
@@ -0,0 +1,588 @@
+package edit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rulego/rulego-components-ai/tool/common"
+)
+
+// patchLine 补丁 hunk 中的一行，op 为 ' '（上下文）、'-'（删除）或 '+'（新增）
+type patchLine struct {
+	op   byte
+	text string
+}
+
+// patchHunk 一个 hunk
+type patchHunk struct {
+	header   string
+	oldStart int
+	oldCount int
+	lines    []patchLine
+	// noEOLOld/noEOLNew 旧/新内容末尾没有换行（\ No newline at end of file）
+	noEOLOld bool
+	noEOLNew bool
+}
+
+// patchFile 补丁中的一个文件，oldPath/newPath 为空表示 /dev/null（新增/删除）
+type patchFile struct {
+	oldPath string
+	newPath string
+	hunks   []*patchHunk
+	// gitHeader 来自 diff --git 头，--- / +++ 行只补全路径
+	gitHeader bool
+}
+
+// parseUnifiedDiff 解析 unified diff（支持多文件、git 风格 add/delete/rename）。
+// 没有文件头的裸 hunk 应用到 defaultPath。行数统计不可靠（模型常算错），hunk 以下一个 @@ 或文件头结束
+func parseUnifiedDiff(text, defaultPath string) ([]*patchFile, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var files []*patchFile
+	var cur *patchFile
+	var hunk *patchHunk
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			hunk = nil
+			cur = &patchFile{gitHeader: true}
+			if oldPath, newPath, ok := splitGitPaths(strings.TrimPrefix(line, "diff --git ")); ok {
+				cur.oldPath, cur.newPath = oldPath, newPath
+			}
+			files = append(files, cur)
+			continue
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			hunk = nil
+			oldPath := cleanPatchPath(strings.TrimPrefix(line, "--- "), "a/")
+			newPath := cleanPatchPath(strings.TrimPrefix(lines[i+1], "+++ "), "b/")
+			i++
+			if cur == nil || !cur.gitHeader || len(cur.hunks) > 0 {
+				cur = &patchFile{}
+				files = append(files, cur)
+			}
+			cur.oldPath, cur.newPath = oldPath, newPath
+			cur.gitHeader = false
+			continue
+		case strings.HasPrefix(line, "@@"):
+			h, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			if cur == nil {
+				if defaultPath == "" {
+					return nil, fmt.Errorf("hunk '%s' has no file header (--- a/path, +++ b/path) and no path was given", line)
+				}
+				cur = &patchFile{oldPath: defaultPath, newPath: defaultPath}
+				files = append(files, cur)
+			}
+			hunk = h
+			cur.hunks = append(cur.hunks, hunk)
+			continue
+		}
+
+		if hunk == nil {
+			// git 扩展头：new/deleted file mode、rename from/to 等
+			if cur != nil && cur.gitHeader {
+				switch {
+				case strings.HasPrefix(line, "new file mode"):
+					cur.oldPath = ""
+				case strings.HasPrefix(line, "deleted file mode"):
+					cur.newPath = ""
+				case strings.HasPrefix(line, "rename from "):
+					cur.oldPath = cleanPatchPath(strings.TrimPrefix(line, "rename from "), "")
+				case strings.HasPrefix(line, "rename to "):
+					cur.newPath = cleanPatchPath(strings.TrimPrefix(line, "rename to "), "")
+				}
+			}
+			continue
+		}
+
+		switch {
+		case line == "":
+			// 模型常把空上下文行的前导空格丢掉
+			hunk.lines = append(hunk.lines, patchLine{op: ' '})
+		case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+			hunk.lines = append(hunk.lines, patchLine{op: line[0], text: line[1:]})
+		case line[0] == '\\':
+			if n := len(hunk.lines); n > 0 {
+				switch hunk.lines[n-1].op {
+				case '-':
+					hunk.noEOLOld = true
+				case '+':
+					hunk.noEOLNew = true
+				default:
+					hunk.noEOLOld, hunk.noEOLNew = true, true
+				}
+			}
+		default:
+			// 非 hunk 内容（如说明文字），结束当前 hunk
+			hunk = nil
+		}
+	}
+
+	for _, f := range files {
+		for _, h := range f.hunks {
+			// 去掉 hunk 末尾由空行产生的多余上下文
+			for len(h.lines) > 0 && h.lines[len(h.lines)-1] == (patchLine{op: ' '}) {
+				h.lines = h.lines[:len(h.lines)-1]
+			}
+		}
+		if f.oldPath == "" && f.newPath == "" {
+			return nil, fmt.Errorf("patch section has no file path")
+		}
+		if f.oldPath != "" && f.newPath != "" && f.oldPath == f.newPath && len(f.hunks) == 0 {
+			return nil, fmt.Errorf("patch for '%s' has no hunks", f.oldPath)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file changes found in patch")
+	}
+	return files, nil
+}
+
+// parseHunkHeader 解析 @@ -l,s +l,s @@ 头
+func parseHunkHeader(line string) (*patchHunk, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") {
+		// 没有行号的 @@（apply_patch 风格），按文件开头向后搜索
+		return &patchHunk{header: line, oldStart: 1, oldCount: -1}, nil
+	}
+	start, count, err := parseHunkRange(strings.TrimPrefix(fields[1], "-"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hunk header '%s': %w", line, err)
+	}
+	return &patchHunk{header: line, oldStart: start, oldCount: count}, nil
+}
+
+// parseHunkRange 解析 "l,s" 或 "l"
+func parseHunkRange(s string) (int, int, error) {
+	startStr, countStr, hasCount := strings.Cut(s, ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, err
+	}
+	count := 1
+	if hasCount {
+		if count, err = strconv.Atoi(countStr); err != nil {
+			return 0, 0, err
+		}
+	}
+	return start, count, nil
+}
+
+// splitGitPaths 解析 diff --git a/x b/x 中的两个路径
+func splitGitPaths(s string) (string, string, bool) {
+	if i := strings.Index(s, " b/"); strings.HasPrefix(s, "a/") && i > 0 {
+		return s[2:i], s[i+3:], true
+	}
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return "", "", false
+	}
+	return fields[0], fields[1], true
+}
+
+// cleanPatchPath 清理文件头中的路径：去掉时间戳、引号与 a/ b/ 前缀，/dev/null 返回空
+func cleanPatchPath(p, prefix string) string {
+	if i := strings.IndexByte(p, '\t'); i >= 0 {
+		p = p[:i]
+	}
+	p = strings.TrimSpace(p)
+	if unquoted, err := strconv.Unquote(p); err == nil && strings.HasPrefix(p, `"`) {
+		p = unquoted
+	}
+	if p == "/dev/null" {
+		return ""
+	}
+	if prefix != "" {
+		p = strings.TrimPrefix(p, prefix)
+	}
+	return p
+}
+
+// hunkError 应用 hunk 失败
+type hunkError struct {
+	index  int
+	header string
+	reason string
+}
+
+func (e *hunkError) Error() string {
+	return fmt.Sprintf("hunk #%d (%s): %s", e.index, e.header, e.reason)
+}
+
+// applyHunks 把 hunks 依次应用到 content（LF 行尾），上下文按期望行号就近搜索（容忍行号偏移），
+// 精确匹配失败时忽略行尾空白再试。返回新内容与偏移说明
+func applyHunks(content string, hunks []*patchHunk) (string, []string, error) {
+	hasFinalNL := content == "" || strings.HasSuffix(content, "\n")
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+	var notes []string
+	shift, offset, minPos := 0, 0, 0
+
+	for i, h := range hunks {
+		var old []string
+		for _, l := range h.lines {
+			if l.op != '+' {
+				old = append(old, l.text)
+			}
+		}
+		base := h.oldStart - 1
+		if h.oldCount == 0 {
+			base = h.oldStart // 纯新增 hunk：在第 oldStart 行之后插入
+		}
+		expected := base + shift + offset
+		if h.oldCount < 0 {
+			expected = minPos // 没有行号的 hunk 紧接上一个 hunk 向后搜索
+		}
+		var pos int
+		fuzzy := false
+		if len(old) == 0 {
+			pos = min(max(expected, minPos), len(lines))
+		} else {
+			pos = findLines(lines, old, expected, minPos, false)
+			if pos < 0 {
+				pos = findLines(lines, old, expected, minPos, true)
+				fuzzy = pos >= 0
+			}
+			if pos < 0 {
+				reason := "context not found"
+				if p := findLines(lines, old, expected, 0, true); p >= 0 {
+					reason = fmt.Sprintf("context only found at line %d, before the previous hunk", p+1)
+				}
+				return "", nil, &hunkError{index: i + 1, header: h.header, reason: reason + closestMatchHint(strings.Join(lines, "\n"), strings.Join(old, "\n"))}
+			}
+		}
+
+		// 上下文行保留文件原文（忽略空白匹配时不改动原有空白）
+		var replacement []string
+		j := pos
+		for _, l := range h.lines {
+			switch l.op {
+			case ' ':
+				replacement = append(replacement, lines[j])
+				j++
+			case '-':
+				j++
+			case '+':
+				replacement = append(replacement, l.text)
+			}
+		}
+		atEOF := pos+len(old) == len(lines)
+		lines = append(lines[:pos], append(replacement, lines[pos+len(old):]...)...)
+		if atEOF {
+			if h.noEOLNew {
+				hasFinalNL = false
+			} else if h.noEOLOld {
+				hasFinalNL = true
+			}
+		}
+
+		if h.oldCount >= 0 {
+			offset = pos - (base + shift)
+			if offset != 0 {
+				notes = append(notes, fmt.Sprintf("hunk #%d applied at offset %+d lines", i+1, offset))
+			}
+		}
+		if fuzzy {
+			notes = append(notes, fmt.Sprintf("hunk #%d matched ignoring whitespace differences", i+1))
+		}
+		shift += len(replacement) - len(old)
+		minPos = pos + len(replacement)
+	}
+
+	result := strings.Join(lines, "\n")
+	if hasFinalNL && len(lines) > 0 {
+		result += "\n"
+	}
+	return result, notes, nil
+}
+
+// findLines 从 expected 开始向两侧搜索 old 在 lines 中的位置（不早于 minPos），找不到返回 -1
+func findLines(lines, old []string, expected, minPos int, ignoreSpace bool) int {
+	last := len(lines) - len(old)
+	if last < minPos {
+		return -1
+	}
+	expected = min(max(expected, minPos), last)
+	for d := 0; expected-d >= minPos || expected+d <= last; d++ {
+		if p := expected - d; p >= minPos && linesEqual(lines[p:p+len(old)], old, ignoreSpace) {
+			return p
+		}
+		if p := expected + d; d > 0 && p <= last && linesEqual(lines[p:p+len(old)], old, ignoreSpace) {
+			return p
+		}
+	}
+	return -1
+}
+
+// linesEqual 比较行，ignoreSpace 时忽略首尾空白
+func linesEqual(a, b []string, ignoreSpace bool) bool {
+	for i := range b {
+		if ignoreSpace {
+			if strings.TrimSpace(a[i]) != strings.TrimSpace(b[i]) {
+				return false
+			}
+		} else if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// fileChange 补丁对单个文件的改动
+type fileChange struct {
+	op       byte // 'A' 新增、'M' 修改、'D' 删除、'R' 重命名
+	display  string
+	oldAbs   string
+	newAbs   string
+	original []byte      // 改动前内容，新增文件为 nil
+	mode     os.FileMode // 改动前的权限位，写入与回滚时恢复
+	content  string
+	hunks    int
+	added    int
+	removed  int
+	notes    []string
+}
+
+// editPatch 应用 unified diff：先在内存中计算所有文件的新内容（任一 hunk 失败则不写任何文件），
+// 再逐个备份并写入，写入失败时回滚已写入的文件
+func (t *editTool) editPatch(ctx context.Context, r *common.SecurePathResolver, params OperationParams, sessionId string, backup *common.BackupManager, checkpoints *common.CheckpointManager) (string, error) {
+	if strings.TrimSpace(params.Patch) == "" {
+		return common.ErrPatchInvalid("patch cannot be empty").Error(), nil
+	}
+	files, err := parseUnifiedDiff(params.Patch, params.Path)
+	if err != nil {
+		return common.ErrPatchInvalid(err.Error()).Error(), nil
+	}
+
+	// 解析所有路径并按路径排序加锁（避免与其他编辑并发时死锁），之后再读取文件内容
+	type resolvedFile struct {
+		*patchFile
+		oldAbs, newAbs string
+	}
+	resolved := make([]resolvedFile, len(files))
+	lockSet := make(map[string]bool)
+	for i, f := range files {
+		resolved[i].patchFile = f
+		for _, side := range []struct {
+			rel string
+			abs *string
+		}{{f.oldPath, &resolved[i].oldAbs}, {f.newPath, &resolved[i].newAbs}} {
+			if side.rel == "" {
+				continue
+			}
+			abs, err := r.Resolve(side.rel)
+			if err != nil {
+				return common.ErrPatchFailed(fmt.Sprintf("%s: %v. No files were modified.", side.rel, err)).Error(), nil
+			}
+			*side.abs = abs
+			lockSet[abs] = true
+		}
+	}
+	locked := make([]string, 0, len(lockSet))
+	for p := range lockSet {
+		locked = append(locked, p)
+	}
+	sort.Strings(locked)
+	for _, p := range locked {
+		defer lockFile(p)()
+	}
+
+	var changes []*fileChange
+	byPath := make(map[string]*fileChange)
+	for _, f := range resolved {
+		display := f.newPath
+		if display == "" {
+			display = f.oldPath
+		}
+		c := &fileChange{display: display, oldAbs: f.oldAbs, newAbs: f.newAbs, hunks: len(f.hunks)}
+		for _, h := range f.hunks {
+			for _, l := range h.lines {
+				switch l.op {
+				case '+':
+					c.added++
+				case '-':
+					c.removed++
+				}
+			}
+		}
+
+		// 同一文件出现在多个段落：在前一段的结果上继续应用
+		if prev, ok := byPath[f.oldAbs]; ok && f.oldAbs == f.newAbs && (prev.op == 'A' || prev.op == 'M') {
+			content, notes, err := applyHunks(prev.content, f.hunks)
+			if err != nil {
+				return patchFailure(display, err), nil
+			}
+			prev.content = content
+			prev.notes = append(prev.notes, notes...)
+			prev.hunks += c.hunks
+			prev.added += c.added
+			prev.removed += c.removed
+			continue
+		}
+
+		var base string
+		switch {
+		case f.oldAbs == "":
+			c.op = 'A'
+		case f.newAbs == "":
+			c.op = 'D'
+		case f.newAbs != f.oldAbs:
+			c.op = 'R'
+		default:
+			c.op = 'M'
+		}
+		if c.op == 'A' || c.op == 'R' {
+			if _, err := os.Stat(f.newAbs); err == nil {
+				return common.ErrPatchFailed(fmt.Sprintf("cannot create '%s': file already exists. No files were modified.", f.newPath)).Error(), nil
+			}
+		}
+		if c.op != 'A' {
+			info, err := os.Stat(f.oldAbs)
+			if err != nil {
+				return common.ErrPatchFailed(fmt.Sprintf("%s: file not found. No files were modified.", f.oldPath)).Error(), nil
+			}
+			if info.IsDir() {
+				return common.ErrPatchFailed(fmt.Sprintf("%s: path is a directory. No files were modified.", f.oldPath)).Error(), nil
+			}
+			c.mode = info.Mode().Perm()
+			if msg := checkReadBeforeEdit(ctx, f.oldPath); msg != "" {
+				return msg, nil
+			}
+			if c.original, err = os.ReadFile(f.oldAbs); err != nil {
+				return "", fmt.Errorf("read file: %w", err)
+			}
+			base = strings.ReplaceAll(string(c.original), "\r\n", "\n")
+		}
+
+		content, notes, err := applyHunks(base, f.hunks)
+		if err != nil {
+			return patchFailure(display, err), nil
+		}
+		c.content = content
+		c.notes = notes
+		changes = append(changes, c)
+		for _, p := range []string{c.oldAbs, c.newAbs} {
+			if p != "" {
+				byPath[p] = c
+			}
+		}
+	}
+
+	for _, c := range changes {
+		for _, p := range []string{c.oldAbs, c.newAbs} {
+			if p == "" {
+				continue
+			}
+			if msg := recordCheckpoint(ctx, checkpoints, sessionId, p); msg != "" {
+				return msg + " No files were modified.", nil
+			}
+		}
+	}
+
+	backups := make(map[*fileChange]string)
+	for _, c := range changes {
+		if c.original == nil {
+			continue
+		}
+		backupPath, version, err := backup.Backup(c.oldAbs, sessionId)
+		if err != nil {
+			return common.ErrBackupFailed(fmt.Sprintf("%s: %v. No files were modified.", c.display, err)).Error(), nil
+		}
+		backups[c] = fmt.Sprintf("%s (v%d)", backupPath, version)
+	}
+
+	for i, c := range changes {
+		if err := writeFileChange(c); err != nil {
+			for j := i; j >= 0; j-- {
+				rollbackFileChange(changes[j])
+			}
+			return common.ErrPatchFailed(fmt.Sprintf("writing %s: %v. All changes were rolled back.", c.display, err)).Error(), nil
+		}
+	}
+
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("Success: Applied patch to %d file(s)\n", len(changes)))
+	for _, c := range changes {
+		if c.op == 'R' {
+			oldRel, _ := filepath.Rel(r.Workspace(), c.oldAbs)
+			result.WriteString(fmt.Sprintf("  R %s -> %s", filepath.ToSlash(oldRel), c.display))
+		} else {
+			result.WriteString(fmt.Sprintf("  %c %s", c.op, c.display))
+		}
+		result.WriteString(fmt.Sprintf(" (%d hunk(s), +%d -%d)\n", c.hunks, c.added, c.removed))
+		for _, n := range c.notes {
+			result.WriteString("    Note: " + n + "\n")
+		}
+		if b, ok := backups[c]; ok {
+			result.WriteString("    Backup: " + b + "\n")
+		}
+	}
+	out := strings.TrimRight(result.String(), "\n")
+	for _, c := range changes {
+		if c.op != 'D' {
+			out += t.reportDiagnostics(c.newAbs)
+		}
+	}
+	return out, nil
+}
+
+// patchFailure 格式化 hunk 应用失败
+func patchFailure(path string, err error) string {
+	return common.ErrPatchFailed(fmt.Sprintf("%s: %v. No files were modified; re-read the file and regenerate the patch.", path, err)).Error()
+}
+
+// writeFileChange 写入单个文件改动
+func writeFileChange(c *fileChange) error {
+	switch c.op {
+	case 'D':
+		return os.Remove(c.oldAbs)
+	case 'A', 'R':
+		if err := os.MkdirAll(filepath.Dir(c.newAbs), 0755); err != nil {
+			return err
+		}
+	}
+	content := c.content
+	if c.op == 'R' {
+		// 新路径不存在，atomicWriteFile 无法嗅探原文件行尾
+		content = normalizeLineEndings(content, detectLineEnding(c.original))
+	}
+	if err := atomicWriteFile(c.newAbs, []byte(content)); err != nil {
+		return err
+	}
+	if c.mode != 0 {
+		if err := os.Chmod(c.newAbs, c.mode); err != nil {
+			return err
+		}
+	}
+	if c.op == 'R' {
+		return os.Remove(c.oldAbs)
+	}
+	return nil
+}
+
+// rollbackFileChange 撤销单个文件改动，恢复原始内容与权限
+func rollbackFileChange(c *fileChange) {
+	switch c.op {
+	case 'A':
+		_ = os.Remove(c.newAbs)
+		return
+	case 'R':
+		_ = os.Remove(c.newAbs)
+	}
+	// 文件仍存在时 WriteFile 不修改权限，需再 Chmod
+	_ = os.WriteFile(c.oldAbs, c.original, c.mode)
+	_ = os.Chmod(c.oldAbs, c.mode)
+}
@@ -0,0 +1,267 @@
+package edit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/rulego/rulego-components-ai/session"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPatchTool(t *testing.T) (tool.InvokableTool, string) {
+	t.Helper()
+	workDir := t.TempDir()
+	tTool, err := NewTool(Config{WorkDir: workDir})
+	require.NoError(t, err)
+	return tTool.(tool.InvokableTool), workDir
+}
+
+func writeFiles(t *testing.T, workDir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(workDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	}
+}
+
+func readFile(t *testing.T, workDir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(workDir, name))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func runPatch(t *testing.T, invokable tool.InvokableTool, ctx context.Context, params map[string]interface{}) string {
+	t.Helper()
+	params["operation"] = "patch"
+	result, err := invokable.InvokableRun(ctx, buildParams(params))
+	require.NoError(t, err)
+	t.Logf("Result: %s", result)
+	return result
+}
+
+func TestPatchMultiFile(t *testing.T) {
+	invokable, workDir := newPatchTool(t)
+	writeFiles(t, workDir, map[string]string{
+		"a.go":       "package a\n\nfunc A() int {\n\treturn 1\n}\n",
+		"old.txt":    "keep me\n",
+		"remove.txt": "bye\n",
+	})
+
+	patch := `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -3,3 +3,3 @@
+ func A() int {
+-	return 1
++	return 2
+ }
+diff --git a/new/b.go b/new/b.go
+new file mode 100644
+--- /dev/null
++++ b/new/b.go
+@@ -0,0 +1,3 @@
++package b
++
++func B() {}
+diff --git a/remove.txt b/remove.txt
+deleted file mode 100644
+--- a/remove.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/old.txt b/renamed.txt
+similarity index 100%
+rename from old.txt
+rename to renamed.txt
+`
+	result := runPatch(t, invokable, context.Background(), map[string]interface{}{"patch": patch})
+	assert.Contains(t, result, "Success: Applied patch to 4 file(s)")
+	assert.Contains(t, result, "M a.go (1 hunk(s), +1 -1)")
+	assert.Contains(t, result, "A new/b.go")
+	assert.Contains(t, result, "D remove.txt")
+	assert.Contains(t, result, "R old.txt -> renamed.txt")
+
+	assert.Equal(t, "package a\n\nfunc A() int {\n\treturn 2\n}\n", readFile(t, workDir, "a.go"))
+	assert.Equal(t, "package b\n\nfunc B() {}\n", readFile(t, workDir, "new/b.go"))
+	assert.Equal(t, "keep me\n", readFile(t, workDir, "renamed.txt"))
+	assert.NoFileExists(t, filepath.Join(workDir, "remove.txt"))
+	assert.NoFileExists(t, filepath.Join(workDir, "old.txt"))
+}
+
+func TestPatchFuzz(t *testing.T) {
+	invokable, workDir := newPatchTool(t)
+	writeFiles(t, workDir, map[string]string{
+		"f.txt": "header1\nheader2\nalpha\n    beta\ngamma\n",
+	})
+
+	// 行号偏移 2 行、上下文缩进与文件不一致，无 --- +++ 头时应用到 path
+	patch := "@@ -1,3 +1,3 @@\n alpha\n-  beta\n+    BETA\n gamma\n"
+	result := runPatch(t, invokable, context.Background(), map[string]interface{}{"path": "f.txt", "patch": patch})
+	assert.Contains(t, result, "Success")
+	assert.Contains(t, result, "hunk #1 applied at offset +2 lines")
+	assert.Contains(t, result, "hunk #1 matched ignoring whitespace differences")
+	assert.Equal(t, "header1\nheader2\nalpha\n    BETA\ngamma\n", readFile(t, workDir, "f.txt"))
+}
+
+func TestPatchFailedHunkIsAtomic(t *testing.T) {
+	invokable, workDir := newPatchTool(t)
+	writeFiles(t, workDir, map[string]string{
+		"one.txt": "1\n2\n3\n",
+		"two.txt": "a\nb\nc\n",
+	})
+
+	patch := `--- a/one.txt
++++ b/one.txt
+@@ -1,2 +1,2 @@
+ 1
+-2
++two
+--- a/two.txt
++++ b/two.txt
+@@ -1,2 +1,2 @@
+ a
+-b
++B
+@@ -3,1 +3,1 @@
+-missing
++x
+`
+	result := runPatch(t, invokable, context.Background(), map[string]interface{}{"patch": patch})
+	assert.Contains(t, result, "Failed to apply patch")
+	assert.Contains(t, result, "two.txt")
+	assert.Contains(t, result, "hunk #2")
+	assert.Contains(t, result, "No files were modified")
+	assert.Equal(t, "1\n2\n3\n", readFile(t, workDir, "one.txt"))
+	assert.Equal(t, "a\nb\nc\n", readFile(t, workDir, "two.txt"))
+}
+
+func TestPatchLineEndings(t *testing.T) {
+	invokable, workDir := newPatchTool(t)
+	writeFiles(t, workDir, map[string]string{
+		"crlf.txt":  "one\r\ntwo\r\nthree\r\n",
+		"no_nl.txt": "x\ny",
+	})
+
+	patch := `--- a/crlf.txt
++++ b/crlf.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+--- a/no_nl.txt
++++ b/no_nl.txt
+@@ -1,2 +1,2 @@
+ x
+-y
+\ No newline at end of file
++z
+`
+	result := runPatch(t, invokable, context.Background(), map[string]interface{}{"patch": patch})
+	assert.Contains(t, result, "Success")
+	assert.Equal(t, "one\r\nTWO\r\nthree\r\n", readFile(t, workDir, "crlf.txt"))
+	assert.Equal(t, "x\nz\n", readFile(t, workDir, "no_nl.txt"))
+}
+
+func TestPatchPreservesFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not supported on Windows")
+	}
+	invokable, workDir := newPatchTool(t)
+	writeFiles(t, workDir, map[string]string{"run.sh": "echo a\n"})
+	script := filepath.Join(workDir, "run.sh")
+	require.NoError(t, os.Chmod(script, 0750))
+
+	patch := `--- a/run.sh
++++ b/run.sh
+@@ -1 +1 @@
+-echo a
++echo b
+`
+	result := runPatch(t, invokable, context.Background(), map[string]interface{}{"patch": patch})
+	assert.Contains(t, result, "Success")
+	info, err := os.Stat(script)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), info.Mode().Perm())
+
+	// 回滚恢复原始内容与权限
+	require.NoError(t, os.Chmod(script, 0644))
+	rollbackFileChange(&fileChange{op: 'M', oldAbs: script, original: []byte("echo a\n"), mode: 0750})
+	assert.Equal(t, "echo a\n", readFile(t, workDir, "run.sh"))
+	info, err = os.Stat(script)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), info.Mode().Perm())
+
+	renamed := filepath.Join(workDir, "renamed.sh")
+	require.NoError(t, os.Rename(script, renamed))
+	rollbackFileChange(&fileChange{op: 'R', oldAbs: script, newAbs: renamed, original: []byte("echo a\n"), mode: 0750})
+	assert.NoFileExists(t, renamed)
+	info, err = os.Stat(script)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), info.Mode().Perm())
+}
+
+func TestPatchBackupAndRestore(t *testing.T) {
+	invokable, workDir := newPatchTool(t)
+	writeFiles(t, workDir, map[string]string{"f.txt": "old\n"})
+
+	result := runPatch(t, invokable, context.Background(), map[string]interface{}{
+		"patch": "--- a/f.txt\n+++ b/f.txt\n@@ -1 +1 @@\n-old\n+new\n",
+	})
+	assert.Contains(t, result, "Backup:")
+	assert.Equal(t, "new\n", readFile(t, workDir, "f.txt"))
+
+	result, err := invokable.InvokableRun(context.Background(), buildParams(map[string]interface{}{
+		"operation": "restore",
+		"path":      "f.txt",
+		"version":   1,
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, result, "Success")
+	assert.Equal(t, "old\n", readFile(t, workDir, "f.txt"))
+}
+
+func TestPatchInvalid(t *testing.T) {
+	invokable, workDir := newPatchTool(t)
+	writeFiles(t, workDir, map[string]string{"exists.txt": "x\n"})
+
+	result := runPatch(t, invokable, context.Background(), map[string]interface{}{"patch": ""})
+	assert.Contains(t, result, "Invalid patch")
+
+	result = runPatch(t, invokable, context.Background(), map[string]interface{}{"patch": "@@ -1 +1 @@\n-a\n+b\n"})
+	assert.Contains(t, result, "Invalid patch")
+	assert.Contains(t, result, "no file header")
+
+	result = runPatch(t, invokable, context.Background(), map[string]interface{}{
+		"patch": "--- /dev/null\n+++ b/exists.txt\n@@ -0,0 +1 @@\n+y\n",
+	})
+	assert.Contains(t, result, "already exists")
+	assert.Equal(t, "x\n", readFile(t, workDir, "exists.txt"))
+}
+
+func TestPatchReadBeforeEdit(t *testing.T) {
+	invokable, workDir := newPatchTool(t)
+	writeFiles(t, workDir, map[string]string{"f.txt": "old\n"})
+	patch := "--- a/f.txt\n+++ b/f.txt\n@@ -1 +1 @@\n-old\n+new\n"
+
+	sess := &session.Session{Messages: []*session.SessionMessage{{Role: "user", Content: "edit f.txt"}}}
+	ctx := session.WithSession(context.Background(), sess)
+	result := runPatch(t, invokable, ctx, map[string]interface{}{"patch": patch})
+	assert.Contains(t, result, "READ_BEFORE_EDIT")
+	assert.Equal(t, "old\n", readFile(t, workDir, "f.txt"))
+
+	sess.Messages = append(sess.Messages, &session.SessionMessage{
+		Role:      "assistant",
+		ToolCalls: []session.ToolCallInfo{{Name: "read", Arguments: `{"path":"f.txt"}`}},
+	})
+	result = runPatch(t, invokable, ctx, map[string]interface{}{"patch": patch})
+	assert.Contains(t, result, "Success")
+	assert.Equal(t, "new\n", readFile(t, workDir, "f.txt"))
+}
@@ -53,6 +53,15 @@ func isWriteOp(op string) bool {
 	return false
 }
 
+// isWorkspaceOp 判断是否为不需要 path 的操作（patch 路径来自补丁头，检查点作用于整个工作目录）。
+func isWorkspaceOp(op string) bool {
+	switch op {
+	case "patch", "list_checkpoints", "restore_checkpoint":
+		return true
+	}
+	return false
+}
+
 // readBeforeEditScanWindow 扫描最近 N 条 session 消息用于 read-before-edit 检查。
 const readBeforeEditScanWindow = 20
 
@@ -189,13 +198,13 @@ func (t *editTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
 
 	props.Set("operation", &jsonschema.Schema{
 		Type:        "string",
-		Description: "Operation type: line (edit line), search (search-replace), insert (insert content), delete (delete lines), restore (restore backup), list_backups (list backups)",
-		Enum:        []any{"line", "search", "insert", "delete", "restore", "list_backups"},
+		Description: "Operation type: line (edit line), search (search-replace), insert (insert content), delete (delete lines), patch (apply unified diff), restore (restore backup), list_backups (list backups), list_checkpoints (list run checkpoints of this session), restore_checkpoint (undo all file changes made since a run started)",
+		Enum:        []any{"line", "search", "insert", "delete", "patch", "restore", "list_backups", "list_checkpoints", "restore_checkpoint"},
 	})
 
 	props.Set("path", &jsonschema.Schema{
 		Type:        "string",
-		Description: "File path",
+		Description: "File path (not used by checkpoint operations; optional for patch, where it is the target of hunks without file headers)",
 	})
 
 	props.Set("line_number", &jsonschema.Schema{
@@ -210,7 +219,7 @@ func (t *editTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
 
 	props.Set("search", &jsonschema.Schema{
 		Type:        "string",
-		Description: "Search content (required for search operation)",
+		Description: "Search content (required for search operation). Without use_regex/global, falls back to whitespace- and indentation-insensitive line matching when there is no exact match; edits are refused with candidate line ranges when more than one location matches",
 	})
 
 	props.Set("replace", &jsonschema.Schema{
@@ -251,13 +260,23 @@ func (t *editTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
 		Description: "Backup version number (required for restore operation)",
 	})
 
+	props.Set("patch", &jsonschema.Schema{
+		Type:        "string",
+		Description: "Unified diff (required for patch operation). Supports multiple files and git-style add/delete/rename; all hunks must apply or no file is changed",
+	})
+
+	props.Set("checkpoint_id", &jsonschema.Schema{
+		Type:        "string",
+		Description: "Run ID of the checkpoint (required for restore_checkpoint operation, see list_checkpoints)",
+	})
+
 	return &schema.ToolInfo{
 		Name: ToolName,
-		Desc: "Edit files with line-level editing, search-replace, insert, and delete operations. Supports backup and restore.",
+		Desc: "Edit files with line-level editing, search-replace, insert, delete, and unified-diff patch operations. Supports backup and restore, and run-level checkpoints to undo every file change of a run.",
 		ParamsOneOf: schema.NewParamsOneOfByJSONSchema(&jsonschema.Schema{
 			Type:       "object",
 			Properties: props,
-			Required:   []string{"operation", "path"},
+			Required:   []string{"operation"},
 		}),
 	}, nil
 }
@@ -276,6 +295,8 @@ type OperationParams struct {
 	InsertBefore string `json:"insert_before"`
 	DeleteLines  []int  `json:"delete_lines"`
 	Version      int    `json:"version"`
+	Patch        string `json:"patch"`
+	CheckpointID string `json:"checkpoint_id"`
 }
 
 // InvokableRun executes the operation.
@@ -285,7 +306,8 @@ func (t *editTool) InvokableRun(ctx context.Context, arguments string, opts ...t
 		return "", fmt.Errorf("parse params: %w", err)
 	}
 
-	if params.Path == "" {
+	// patch 的文件路径来自补丁头，path 仅作为裸 hunk 的目标文件；检查点操作作用于整个工作目录
+	if params.Path == "" && !isWorkspaceOp(params.Operation) {
 		return common.ErrPathEmpty().Error(), nil
 	}
 
@@ -298,14 +320,27 @@ func (t *editTool) InvokableRun(ctx context.Context, arguments string, opts ...t
 	// backup 按 effWd 构造（NewBackupManager 无后台协程，per-call 构造廉价）。
 	backup := common.NewBackupManager(effWd, t.config.MaxHistory)
 
+	// Get session key from context for session-isolated backups
+	sessionKey, _ := session.SessionKeyFromContext(ctx)
+
+	// 检查点按 effWd 构造，记录本次执行（runId）改动的所有文件
+	checkpoints := common.NewCheckpointManager(effWd)
+
+	switch params.Operation {
+	case "patch":
+		// patch 可能涉及多个文件，路径解析、加锁与 read-before-edit 检查在 editPatch 内按文件进行
+		return t.editPatch(ctx, r, params, sessionKey, backup, checkpoints)
+	case "list_checkpoints":
+		return t.listCheckpoints(sessionKey, checkpoints)
+	case "restore_checkpoint":
+		return t.restoreCheckpoint(params, sessionKey, checkpoints)
+	}
+
 	path, err := r.Resolve(params.Path)
 	if err != nil {
 		return "", err
 	}
 
-	// Get session key from context for session-isolated backups
-	sessionKey, _ := session.SessionKeyFromContext(ctx)
-
 	// 修改类操作按文件加锁，防止并发编辑同一文件相互覆盖（list_backups 只读不需锁）
 	if params.Operation != "list_backups" {
 		defer lockFile(path)()
@@ -327,6 +362,9 @@ func (t *editTool) InvokableRun(ctx context.Context, arguments string, opts ...t
 	case "list_backups":
 		return t.listBackups(path, params, sessionKey, backup)
 	case "restore":
+		if msg := recordCheckpoint(ctx, checkpoints, sessionKey, path); msg != "" {
+			return msg, nil
+		}
 		return t.editRestore(path, params, sessionKey, backup)
 	}
 
@@ -344,6 +382,12 @@ func (t *editTool) InvokableRun(ctx context.Context, arguments string, opts ...t
 		return common.ErrPathIsDirectory(params.Path).Error(), nil
 	}
 
+	if isWriteOp(params.Operation) {
+		if msg := recordCheckpoint(ctx, checkpoints, sessionKey, path); msg != "" {
+			return msg, nil
+		}
+	}
+
 	switch params.Operation {
 	case "line":
 		rr, e := t.editLine(path, params, sessionKey, backup)
@@ -611,6 +655,8 @@ func (t *editTool) editSearch(path string, params OperationParams, sessionId str
 
 	contentStr := string(content)
 	replaceCount := 0
+	// matchNote 非精确匹配时说明匹配级别与行范围
+	matchNote := ""
 
 	if params.UseRegex {
 		// Use safe regex for search and replace
@@ -637,20 +683,32 @@ func (t *editTool) editSearch(path string, params OperationParams, sessionId str
 			}
 		}
 	} else {
-		// Use literal string replacement
+		// 字面量匹配统一按 LF 进行（写回时 atomicWriteFile 恢复文件原生行尾），避免 CRLF/LF 不一致导致匹配失败
+		contentStr = strings.ReplaceAll(contentStr, "\r\n", "\n")
+		search := strings.ReplaceAll(params.Search, "\r\n", "\n")
+		replace := strings.ReplaceAll(params.Replace, "\r\n", "\n")
 		if params.Global {
-			replaceCount = strings.Count(contentStr, params.Search)
-			contentStr = strings.ReplaceAll(contentStr, params.Search, params.Replace)
+			replaceCount = strings.Count(contentStr, search)
+			contentStr = strings.ReplaceAll(contentStr, search, replace)
 		} else {
-			// 唯一性校验：非全局替换要求搜索串唯一定位，多处匹配则拒绝
-			count := strings.Count(contentStr, params.Search)
-			if count == 0 {
-				// no match, replaceCount stays 0
-			} else if count > 1 {
-				return fmt.Sprintf("Error: SEARCH_NOT_UNIQUE - found %d matches. Provide a longer search string to uniquely locate, or set global=true to replace all. First matches at:\n%s", count, locateMatches(contentStr, params.Search, 3)), nil
-			} else {
-				contentStr = strings.Replace(contentStr, params.Search, params.Replace, 1)
+			// 级联匹配：精确 → 忽略行尾空白 → 忽略缩进 → 首尾锚定；多个候选时拒绝并列出行范围
+			match := findSearchMatches(contentStr, search)
+			switch {
+			case len(match.candidates) > 1 && match.strategy == matchExact:
+				return fmt.Sprintf("Error: SEARCH_NOT_UNIQUE - found %d matches. Provide a longer search string to uniquely locate, or set global=true to replace all. Candidates:\n%s",
+					len(match.candidates), formatCandidates(contentStr, match.candidates)), nil
+			case len(match.candidates) > 1:
+				return fmt.Sprintf("Error: SEARCH_NOT_UNIQUE - no exact match; found %d %s matches. Include more surrounding lines to pick one. Candidates:\n%s",
+					len(match.candidates), match.strategy, formatCandidates(contentStr, match.candidates)), nil
+			case len(match.candidates) == 1 && match.strategy == matchExact:
+				off := match.candidates[0].offset
+				contentStr = contentStr[:off] + replace + contentStr[off+len(search):]
+				replaceCount = 1
+			case len(match.candidates) == 1:
+				m := match.candidates[0]
+				contentStr = applyLineMatch(contentStr, m, search, replace)
 				replaceCount = 1
+				matchNote = fmt.Sprintf("\n  Match: %s (lines %d-%d)", match.strategy, m.start+1, m.end)
 			}
 		}
 	}
@@ -671,8 +729,8 @@ func (t *editTool) editSearch(path string, params OperationParams, sessionId str
 		return "", fmt.Errorf("write file: %w", err)
 	}
 
-	return fmt.Sprintf("Success: Replaced %d occurrence(s) in %s\n  Search: %s\n  Replace: %s\n  Backup: %s (v%d)",
-		replaceCount, params.Path,
+	return fmt.Sprintf("Success: Replaced %d occurrence(s) in %s%s\n  Search: %s\n  Replace: %s\n  Backup: %s (v%d)",
+		replaceCount, params.Path, matchNote,
 		common.TruncateString(params.Search, 30),
 		common.TruncateString(params.Replace, 30),
 		backupPath, version), nil
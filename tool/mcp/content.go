@@ -0,0 +1,135 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ResultError 返回 MCP 工具结果中的错误，结果不是错误时返回 nil。
+func ResultError(result *mcp.CallToolResult) error {
+	if !result.IsError {
+		return nil
+	}
+	if len(result.Content) > 0 {
+		if textContent, ok := result.Content[0].(mcp.TextContent); ok {
+			return fmt.Errorf("MCP 工具错误: %s", textContent.Text)
+		}
+	}
+	return errors.New("MCP 工具错误: 未知错误")
+}
+
+// ResultText 将 MCP 工具结果转换为文本：文本内容原样保留，嵌入的文本资源展开为其内容，
+// 图片、音频与二进制资源以占位文本表示。结果只有 structuredContent 时返回其 JSON。
+func ResultText(result *mcp.CallToolResult) string {
+	var contents []string
+	for _, content := range result.Content {
+		contents = append(contents, contentText(content))
+	}
+	if len(contents) == 0 && result.StructuredContent != nil {
+		if b, err := json.Marshal(result.StructuredContent); err == nil {
+			contents = append(contents, string(b))
+		}
+	}
+	return strings.Join(contents, "\n")
+}
+
+// ToolResultFromMCP 将 MCP 工具结果转换为 eino 多模态工具结果。
+// vision 为 true 时，图片内容与图片类型的嵌入资源转换为图片 part，交给支持视觉的模型；
+// 其余内容按 ResultText 的规则转换为文本 part，相邻文本合并为一个 part。
+func ToolResultFromMCP(result *mcp.CallToolResult, vision bool) *schema.ToolResult {
+	var parts []schema.ToolOutputPart
+	var texts []string
+	flush := func() {
+		if len(texts) > 0 {
+			parts = append(parts, schema.ToolOutputPart{Type: schema.ToolPartTypeText, Text: strings.Join(texts, "\n")})
+			texts = nil
+		}
+	}
+	for _, content := range result.Content {
+		if vision {
+			if image := contentImage(content); image != nil {
+				flush()
+				parts = append(parts, schema.ToolOutputPart{Type: schema.ToolPartTypeImage, Image: image})
+				continue
+			}
+		}
+		texts = append(texts, contentText(content))
+	}
+	if len(result.Content) == 0 && result.StructuredContent != nil {
+		texts = append(texts, ResultText(result))
+	}
+	flush()
+	if len(parts) == 0 {
+		parts = append(parts, schema.ToolOutputPart{Type: schema.ToolPartTypeText})
+	}
+	return &schema.ToolResult{Parts: parts}
+}
+
+// HasMedia 判断 MCP 工具结果中是否包含图片、音频或二进制资源等非文本内容
+func HasMedia(result *mcp.CallToolResult) bool {
+	for _, content := range result.Content {
+		switch v := content.(type) {
+		case mcp.ImageContent, mcp.AudioContent:
+			return true
+		case mcp.EmbeddedResource:
+			if _, ok := v.Resource.(mcp.BlobResourceContents); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// contentText 返回单个 MCP 内容的文本表示
+func contentText(content mcp.Content) string {
+	switch v := content.(type) {
+	case mcp.TextContent:
+		return v.Text
+	case mcp.ImageContent:
+		return fmt.Sprintf("[图片: %s]", v.MIMEType)
+	case mcp.AudioContent:
+		return fmt.Sprintf("[音频: %s]", v.MIMEType)
+	case mcp.ResourceLink:
+		return fmt.Sprintf("[资源: %s %s]", v.Name, v.URI)
+	case mcp.EmbeddedResource:
+		switch r := v.Resource.(type) {
+		case mcp.TextResourceContents:
+			return r.Text
+		case mcp.BlobResourceContents:
+			return fmt.Sprintf("[资源: %s %s]", r.URI, r.MIMEType)
+		}
+	}
+	if b, err := json.Marshal(content); err == nil {
+		return string(b)
+	}
+	return ""
+}
+
+// contentImage 将图片内容或图片类型的二进制资源转换为图片 part，其他内容返回 nil
+func contentImage(content mcp.Content) *schema.ToolOutputImage {
+	var data, mimeType string
+	switch v := content.(type) {
+	case mcp.ImageContent:
+		data, mimeType = v.Data, v.MIMEType
+	case mcp.EmbeddedResource:
+		blob, ok := v.Resource.(mcp.BlobResourceContents)
+		if !ok || !strings.HasPrefix(blob.MIMEType, "image/") {
+			return nil
+		}
+		data, mimeType = blob.Blob, blob.MIMEType
+	default:
+		return nil
+	}
+	if data == "" {
+		return nil
+	}
+	return &schema.ToolOutputImage{MessagePartCommon: schema.MessagePartCommon{
+		Base64Data: &data,
+		MIMEType:   mimeType,
+	}}
+}
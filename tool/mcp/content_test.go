@@ -0,0 +1,124 @@
+package mcp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testImageData = "iVBORw0KGgo="
+
+// richResult 包含文本、图片、文本资源、二进制资源与资源链接的结果
+func richResult() *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.NewTextContent("chart ready"),
+			mcp.NewImageContent(testImageData, "image/png"),
+			mcp.NewEmbeddedResource(mcp.TextResourceContents{URI: "file:///notes.txt", MIMEType: "text/plain", Text: "notes"}),
+			mcp.NewEmbeddedResource(mcp.BlobResourceContents{URI: "file:///chart.jpg", MIMEType: "image/jpeg", Blob: testImageData}),
+			mcp.NewEmbeddedResource(mcp.BlobResourceContents{URI: "file:///data.bin", MIMEType: "application/octet-stream", Blob: "AAEC"}),
+			mcp.NewResourceLink("file:///report.pdf", "report", "", "application/pdf"),
+		},
+	}
+}
+
+func TestResultText(t *testing.T) {
+	assert.Equal(t, "chart ready\n[图片: image/png]\nnotes\n[资源: file:///chart.jpg image/jpeg]\n[资源: file:///data.bin application/octet-stream]\n[资源: report file:///report.pdf]",
+		ResultText(richResult()))
+
+	// 只有 structuredContent 时返回其 JSON
+	structured := &mcp.CallToolResult{StructuredContent: map[string]interface{}{"temperature": 21}}
+	assert.Equal(t, `{"temperature":21}`, ResultText(structured))
+}
+
+func TestResultError(t *testing.T) {
+	assert.NoError(t, ResultError(richResult()))
+	assert.EqualError(t, ResultError(mcp.NewToolResultError("boom")), "MCP 工具错误: boom")
+	assert.EqualError(t, ResultError(&mcp.CallToolResult{IsError: true}), "MCP 工具错误: 未知错误")
+}
+
+func TestToolResultFromMCP(t *testing.T) {
+	t.Run("vision", func(t *testing.T) {
+		result := ToolResultFromMCP(richResult(), true)
+		require.Len(t, result.Parts, 5)
+		assert.Equal(t, schema.ToolOutputPart{Type: schema.ToolPartTypeText, Text: "chart ready"}, result.Parts[0])
+		assert.Equal(t, schema.ToolPartTypeImage, result.Parts[1].Type)
+		assert.Equal(t, testImageData, *result.Parts[1].Image.Base64Data)
+		assert.Equal(t, "image/png", result.Parts[1].Image.MIMEType)
+		assert.Equal(t, "notes", result.Parts[2].Text)
+		// 图片类型的二进制资源同样作为图片交给模型
+		assert.Equal(t, "image/jpeg", result.Parts[3].Image.MIMEType)
+		assert.Equal(t, "[资源: file:///data.bin application/octet-stream]\n[资源: report file:///report.pdf]", result.Parts[4].Text)
+	})
+
+	t.Run("no vision", func(t *testing.T) {
+		result := ToolResultFromMCP(richResult(), false)
+		require.Len(t, result.Parts, 1)
+		assert.Equal(t, ResultText(richResult()), result.Parts[0].Text)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		result := ToolResultFromMCP(&mcp.CallToolResult{}, true)
+		require.Len(t, result.Parts, 1)
+		assert.Equal(t, schema.ToolPartTypeText, result.Parts[0].Type)
+	})
+}
+
+func TestHasMedia(t *testing.T) {
+	assert.True(t, HasMedia(richResult()))
+	assert.False(t, HasMedia(mcp.NewToolResultText("hello")))
+	assert.False(t, HasMedia(&mcp.CallToolResult{Content: []mcp.Content{
+		mcp.NewEmbeddedResource(mcp.TextResourceContents{URI: "file:///a.txt", Text: "a"}),
+	}}))
+}
+
+func TestCreateToolsFromRemote_Vision(t *testing.T) {
+	s := server.NewMCPServer("test-server", "1.0.0")
+	s.AddTool(mcp.NewTool("screenshot", mcp.WithDescription("Takes a screenshot")),
+		func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return &mcp.CallToolResult{Content: []mcp.Content{
+				mcp.NewTextContent("captured"),
+				mcp.NewImageContent(testImageData, "image/png"),
+			}}, nil
+		})
+	httpServer := server.NewStreamableHTTPServer(s)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = http.Serve(listener, httpServer)
+	}()
+	defer httpServer.Shutdown(context.Background())
+	addr := "http://" + listener.Addr().String() + "/mcp"
+
+	// 支持视觉：多模态适配器返回图片 part
+	tools, err := CreateToolsFromRemoteWithOptions(addr, nil, RemoteOptions{Vision: true})
+	require.NoError(t, err)
+	require.Len(t, tools, 1)
+	_, isInvokable := tools[0].(tool.InvokableTool)
+	assert.False(t, isInvokable)
+	enhanced, ok := tools[0].(tool.EnhancedInvokableTool)
+	require.True(t, ok)
+	info, err := enhanced.Info(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "screenshot", info.Name)
+	result, err := enhanced.InvokableRun(context.Background(), &schema.ToolArgument{Text: "{}"})
+	require.NoError(t, err)
+	require.Len(t, result.Parts, 2)
+	assert.Equal(t, "captured", result.Parts[0].Text)
+	assert.Equal(t, testImageData, *result.Parts[1].Image.Base64Data)
+
+	// 不支持视觉：图片以占位文本表示
+	tools, err = CreateToolsFromRemote(addr, nil)
+	require.NoError(t, err)
+	text, err := tools[0].(tool.InvokableTool).InvokableRun(context.Background(), "{}")
+	require.NoError(t, err)
+	assert.Equal(t, "captured\n[图片: image/png]", text)
+}
@@ -22,6 +22,8 @@ type remoteMCPClient struct {
 	server string
 	client *client.Client
 	mu     sync.Mutex
+	// onToolsChanged 非空时订阅服务器的 notifications/tools/list_changed 通知
+	onToolsChanged func()
 }
 
 func newRemoteMCPClient(server string) *remoteMCPClient {
@@ -39,7 +41,12 @@ func (r *remoteMCPClient) getClient(ctx context.Context) (*client.Client, error)
 	var c *client.Client
 
 	if strings.HasPrefix(r.server, "http://") || strings.HasPrefix(r.server, "https://") {
-		httpTransport, err := transport.NewStreamableHTTP(r.server)
+		var httpOpts []transport.StreamableHTTPCOption
+		if r.onToolsChanged != nil {
+			// 服务器主动发送的通知经 GET 流下发
+			httpOpts = append(httpOpts, transport.WithContinuousListening())
+		}
+		httpTransport, err := transport.NewStreamableHTTP(r.server, httpOpts...)
 		if err != nil {
 			return nil, fmt.Errorf("创建 HTTP 传输失败: %w", err)
 		}
@@ -53,7 +60,17 @@ func (r *remoteMCPClient) getClient(ctx context.Context) (*client.Client, error)
 		c = client.NewClient(stdioTransport)
 	}
 
-	if err := c.Start(ctx); err != nil {
+	if r.onToolsChanged != nil {
+		onToolsChanged := r.onToolsChanged
+		c.OnNotification(func(notification mcp.JSONRPCNotification) {
+			if notification.Method == mcp.MethodNotificationToolsListChanged {
+				onToolsChanged()
+			}
+		})
+	}
+
+	// 连接的生命周期与共享它的工具一致，不受本次调用 ctx 的影响
+	if err := c.Start(context.Background()); err != nil {
 		return nil, fmt.Errorf("启动 MCP 客户端失败: %w", err)
 	}
 
@@ -110,10 +127,19 @@ func (a *RemoteMCPToolAdapter) Info(ctx context.Context) (*schema.ToolInfo, erro
 
 // InvokableRun 调用远程 MCP 工具。
 func (a *RemoteMCPToolAdapter) InvokableRun(ctx context.Context, arguments string, opts ...tool.Option) (string, error) {
+	result, err := a.call(ctx, arguments)
+	if err != nil {
+		return "", err
+	}
+	return ResultText(result), nil
+}
+
+// call 调用远程 MCP 工具，工具返回错误结果时转换为 error。
+func (a *RemoteMCPToolAdapter) call(ctx context.Context, arguments string) (*mcp.CallToolResult, error) {
 	var args map[string]interface{}
 	if arguments != "" {
 		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
-			return "", fmt.Errorf("解析参数失败: %w", err)
+			return nil, fmt.Errorf("解析参数失败: %w", err)
 		}
 	}
 	if args == nil {
@@ -122,7 +148,7 @@ func (a *RemoteMCPToolAdapter) InvokableRun(ctx context.Context, arguments strin
 
 	cli, err := a.rc.getClient(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	result, err := cli.CallTool(ctx, mcp.CallToolRequest{
@@ -132,65 +158,88 @@ func (a *RemoteMCPToolAdapter) InvokableRun(ctx context.Context, arguments strin
 		},
 	})
 	if err != nil {
-		return "", fmt.Errorf("调用远程 MCP 工具失败: %w", err)
+		return nil, fmt.Errorf("调用远程 MCP 工具失败: %w", err)
+	}
+	if err := ResultError(result); err != nil {
+		return nil, err
 	}
+	return result, nil
+}
 
-	if result.IsError {
-		if len(result.Content) > 0 {
-			if textContent, ok := result.Content[0].(mcp.TextContent); ok {
-				return "", fmt.Errorf("MCP 工具错误: %s", textContent.Text)
-			}
-		}
-		return "", fmt.Errorf("MCP 工具错误: 未知错误")
-	}
-
-	var contents []string
-	for _, content := range result.Content {
-		switch v := content.(type) {
-		case mcp.TextContent:
-			contents = append(contents, v.Text)
-		case mcp.ImageContent:
-			contents = append(contents, fmt.Sprintf("[图片: %s]", v.MIMEType))
-		default:
-			if b, err := json.Marshal(v); err == nil {
-				contents = append(contents, string(b))
-			}
-		}
+// RemoteMCPMultimodalToolAdapter 将远程 MCP 工具适配为 eino tool.EnhancedInvokableTool，
+// 工具返回的图片与图片资源以多模态 part 交给支持视觉的模型，而不是占位文本。
+type RemoteMCPMultimodalToolAdapter struct {
+	*RemoteMCPToolAdapter
+}
+
+// InvokableRun 调用远程 MCP 工具，返回多模态结果。
+func (a *RemoteMCPMultimodalToolAdapter) InvokableRun(ctx context.Context, toolArgument *schema.ToolArgument, opts ...tool.Option) (*schema.ToolResult, error) {
+	var arguments string
+	if toolArgument != nil {
+		arguments = toolArgument.Text
 	}
-	return strings.Join(contents, "\n"), nil
+	result, err := a.call(ctx, arguments)
+	if err != nil {
+		return nil, err
+	}
+	return ToolResultFromMCP(result, true), nil
+}
+
+// RemoteOptions 远程 MCP 工具的创建选项
+type RemoteOptions struct {
+	// Vision 为 true 时创建 RemoteMCPMultimodalToolAdapter，工具结果中的图片直接交给模型，
+	// 通常由 config.SupportsVision(模型名) 决定
+	Vision bool
 }
 
 // CreateToolsFromRemote 连接远程 MCP 服务器，通过 tools/list 自动发现工具并创建适配器。
 // toolNames 为过滤器：nil 或空切片表示加载全部，["*"] 也表示全部。
 func CreateToolsFromRemote(server string, toolNames []string) ([]tool.BaseTool, error) {
+	return CreateToolsFromRemoteWithOptions(server, toolNames, RemoteOptions{})
+}
+
+// CreateToolsFromRemoteWithOptions 同 CreateToolsFromRemote，按 opts 创建适配器。
+// 返回的工具不随服务器的工具列表变化更新，需要更新时使用 RemoteToolSet。
+func CreateToolsFromRemoteWithOptions(server string, toolNames []string, opts RemoteOptions) ([]tool.BaseTool, error) {
 	rc := newRemoteMCPClient(server)
+	tools, _, err := listRemoteTools(context.Background(), rc, toolNames, opts)
+	return tools, err
+}
 
-	ctx := context.Background()
+// listRemoteTools 通过 tools/list 发现工具并创建适配器，同时返回工具定义的签名，用于判断工具列表是否变化。
+func listRemoteTools(ctx context.Context, rc *remoteMCPClient, toolNames []string, opts RemoteOptions) ([]tool.BaseTool, string, error) {
 	cli, err := rc.getClient(ctx)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	result, err := cli.ListTools(ctx, mcp.ListToolsRequest{})
 	if err != nil {
-		return nil, fmt.Errorf("获取远程 MCP 工具列表失败: %w", err)
+		return nil, "", fmt.Errorf("获取远程 MCP 工具列表失败: %w", err)
 	}
 
 	var tools []tool.BaseTool
+	var signature strings.Builder
 	for _, t := range result.Tools {
 		if !MatchTool(t.Name, toolNames) {
 			continue
 		}
 
 		inputSchema, _ := json.Marshal(t.InputSchema)
+		signature.WriteString(t.Name + "\x00" + t.Description + "\x00" + string(inputSchema) + "\n")
 
-		tools = append(tools, &RemoteMCPToolAdapter{
+		adapter := &RemoteMCPToolAdapter{
 			name:        t.Name,
 			description: t.Description,
 			inputSchema: inputSchema,
 			rc:          rc,
-		})
+		}
+		if opts.Vision {
+			tools = append(tools, &RemoteMCPMultimodalToolAdapter{RemoteMCPToolAdapter: adapter})
+		} else {
+			tools = append(tools, adapter)
+		}
 	}
 
-	return tools, nil
+	return tools, signature.String(), nil
 }
@@ -0,0 +1,118 @@
+package mcp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// refreshTimeout 收到工具列表变化通知后重新获取工具列表的超时时间
+const refreshTimeout = 30 * time.Second
+
+// RemoteToolSet 远程 MCP 服务器上按过滤器选出的一组工具。
+// 订阅服务器的 notifications/tools/list_changed 通知，收到后重新执行 tools/list，
+// 工具有增删或定义变化时更新 Tools 并调用 OnChange 注册的回调，使用方据此换用新的工具。
+type RemoteToolSet struct {
+	rc        *remoteMCPClient
+	toolNames []string
+	opts      RemoteOptions
+
+	// refreshMu 串行化 Refresh，避免并发通知交错更新
+	refreshMu sync.Mutex
+
+	mu        sync.RWMutex
+	tools     []tool.BaseTool
+	signature string
+	listeners []func()
+}
+
+// NewRemoteToolSet 连接远程 MCP 服务器并发现工具。
+// toolNames 为过滤器：nil 或空切片表示加载全部，["*"] 也表示全部。
+func NewRemoteToolSet(server string, toolNames []string, opts RemoteOptions) (*RemoteToolSet, error) {
+	s := &RemoteToolSet{
+		rc:        newRemoteMCPClient(server),
+		toolNames: toolNames,
+		opts:      opts,
+	}
+	s.rc.onToolsChanged = func() {
+		// 通知回调运行在传输层的读循环中，需在其外发起 tools/list 请求
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+			defer cancel()
+			// 刷新失败时保留当前工具，并通知使用方在下次使用前重新获取
+			if _, err := s.Refresh(ctx); err != nil {
+				s.notify()
+			}
+		}()
+	}
+
+	tools, signature, err := listRemoteTools(context.Background(), s.rc, toolNames, opts)
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	s.tools = tools
+	s.signature = signature
+	return s, nil
+}
+
+// Tools 返回当前的工具
+func (s *RemoteToolSet) Tools() []tool.BaseTool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]tool.BaseTool(nil), s.tools...)
+}
+
+// OnChange 注册工具列表变化（或收到变化通知后重新获取失败）时的回调
+func (s *RemoteToolSet) OnChange(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Refresh 重新获取工具列表，工具有变化时更新 Tools、调用 OnChange 回调并返回 true。
+func (s *RemoteToolSet) Refresh(ctx context.Context) (bool, error) {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	tools, signature, err := listRemoteTools(ctx, s.rc, s.toolNames, s.opts)
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	if signature == s.signature {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.tools = tools
+	s.signature = signature
+	s.mu.Unlock()
+
+	s.notify()
+	return true, nil
+}
+
+// notify 调用 OnChange 回调
+func (s *RemoteToolSet) notify() {
+	s.mu.RLock()
+	listeners := append([]func(){}, s.listeners...)
+	s.mu.RUnlock()
+	for _, fn := range listeners {
+		fn()
+	}
+}
+
+// Close 关闭到远程 MCP 服务器的连接
+func (s *RemoteToolSet) Close() error {
+	s.rc.mu.Lock()
+	defer s.rc.mu.Unlock()
+	if s.rc.client == nil {
+		return nil
+	}
+	err := s.rc.client.Close()
+	s.rc.client = nil
+	return err
+}
@@ -0,0 +1,98 @@
+package mcp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func echoHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return mcp.NewToolResultText("ok"), nil
+}
+
+func remoteToolNames(t *testing.T, s *RemoteToolSet) []string {
+	var names []string
+	for _, tl := range s.Tools() {
+		info, err := tl.Info(context.Background())
+		require.NoError(t, err)
+		names = append(names, info.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRemoteToolSet_ToolsListChanged(t *testing.T) {
+	s := server.NewMCPServer("test-server", "1.0.0", server.WithToolCapabilities(true))
+	s.AddTool(mcp.NewTool("search", mcp.WithDescription("Searches")), echoHandler)
+	httpServer := server.NewStreamableHTTPServer(s)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = http.Serve(listener, httpServer)
+	}()
+	defer httpServer.Shutdown(context.Background())
+
+	set, err := NewRemoteToolSet("http://"+listener.Addr().String()+"/mcp", nil, RemoteOptions{})
+	require.NoError(t, err)
+	defer set.Close()
+	assert.Equal(t, []string{"search"}, remoteToolNames(t, set))
+
+	changed := make(chan struct{}, 10)
+	set.OnChange(func() {
+		changed <- struct{}{}
+	})
+	waitChanged := func() {
+		select {
+		case <-changed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("未收到工具列表变化通知")
+		}
+	}
+
+	// 服务器新增工具后，工具集随通知更新
+	s.AddTool(mcp.NewTool("fetch", mcp.WithDescription("Fetches")), echoHandler)
+	waitChanged()
+	assert.Equal(t, []string{"fetch", "search"}, remoteToolNames(t, set))
+
+	// 删除工具
+	s.DeleteTools("search")
+	waitChanged()
+	assert.Equal(t, []string{"fetch"}, remoteToolNames(t, set))
+
+	// 工具定义未变化时不触发回调
+	updated, err := set.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.False(t, updated)
+}
+
+func TestRemoteToolSet_Filter(t *testing.T) {
+	s := server.NewMCPServer("test-server", "1.0.0", server.WithToolCapabilities(true))
+	s.AddTool(mcp.NewTool("search"), echoHandler)
+	httpServer := server.NewStreamableHTTPServer(s)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = http.Serve(listener, httpServer)
+	}()
+	defer httpServer.Shutdown(context.Background())
+
+	set, err := NewRemoteToolSet("http://"+listener.Addr().String()+"/mcp", []string{"search"}, RemoteOptions{})
+	require.NoError(t, err)
+	defer set.Close()
+
+	// 过滤器之外的工具变化不影响工具集
+	s.AddTool(mcp.NewTool("fetch"), echoHandler)
+	time.Sleep(200 * time.Millisecond)
+	updated, err := set.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.False(t, updated)
+	assert.Equal(t, []string{"search"}, remoteToolNames(t, set))
+}
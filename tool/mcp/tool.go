@@ -136,30 +136,11 @@ func (t *mcpTool) InvokableRun(ctx context.Context, arguments string, opts ...to
 		return "", fmt.Errorf("调用 MCP 工具失败: %w", err)
 	}
 
-	if result.IsError {
-		if len(result.Content) > 0 {
-			if textContent, ok := result.Content[0].(mcp.TextContent); ok {
-				return "", fmt.Errorf("MCP 工具错误: %s", textContent.Text)
-			}
-		}
-		return "", fmt.Errorf("MCP 工具错误: 未知错误")
-	}
-
-	var contents []string
-	for _, content := range result.Content {
-		switch c := content.(type) {
-		case mcp.TextContent:
-			contents = append(contents, c.Text)
-		case mcp.ImageContent:
-			contents = append(contents, fmt.Sprintf("[图片: %s]", c.MIMEType))
-		default:
-			if b, err := json.Marshal(c); err == nil {
-				contents = append(contents, string(b))
-			}
-		}
+	if err := ResultError(result); err != nil {
+		return "", err
 	}
 
-	return strings.Join(contents, "\n"), nil
+	return ResultText(result), nil
 }
 
 // getClient 获取或初始化 MCP 客户端
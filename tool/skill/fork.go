@@ -0,0 +1,175 @@
+package skill
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	einoskill "github.com/cloudwego/eino/adk/middlewares/skill"
+	"github.com/cloudwego/eino/schema"
+	aitool "github.com/rulego/rulego-components-ai/tool"
+	"gopkg.in/yaml.v3"
+)
+
+// 技能执行模式，在 SKILL.md frontmatter 中以 mode 声明（兼容 eino 的 context 字段）：
+//
+//	---
+//	name: review
+//	description: Review the current diff
+//	mode: fork
+//	allowed-tools: read, grep, glob
+//	---
+const (
+	// ModeInline 在当前智能体中执行：技能内容作为工具结果返回（默认）
+	ModeInline = "inline"
+	// ModeFork 派生独立子智能体执行，不携带父对话
+	ModeFork = "fork"
+	// ModeForkWithContext 派生子智能体执行，并携带父智能体最近的对话
+	ModeForkWithContext = "fork_with_context"
+)
+
+// DefaultForkContextMessages fork_with_context 默认携带的父对话消息数
+const DefaultForkContextMessages = 20
+
+// skillOptions eino FrontMatter 未覆盖的扩展字段
+type skillOptions struct {
+	mode string
+	// allowedTools 子智能体可用的工具，nil 表示未声明（继承父智能体全部工具）
+	allowedTools []string
+	// scripts 声明的脚本，nil 表示未声明（scripts/ 目录下的文件均为脚本）
+	scripts []skillScript
+}
+
+// parseSkillOptions 读取技能的执行模式、allowed-tools 与 scripts。
+// 非文件系统 backend（无 SKILL.md）时只使用 eino 解析的 context 字段。
+func parseSkillOptions(skill einoskill.Skill) (skillOptions, error) {
+	opts := skillOptions{mode: string(skill.Context)}
+	if skill.BaseDirectory != "" {
+		data, err := os.ReadFile(filepath.Join(skill.BaseDirectory, "SKILL.md"))
+		if err == nil {
+			var fm struct {
+				Mode         string    `yaml:"mode"`
+				AllowedTools yaml.Node `yaml:"allowed-tools"`
+				Scripts      yaml.Node `yaml:"scripts"`
+			}
+			if err := yaml.Unmarshal([]byte(extractFrontMatter(string(data))), &fm); err != nil {
+				return opts, fmt.Errorf("parse frontmatter of skill %s: %w", skill.Name, err)
+			}
+			if fm.Mode != "" {
+				opts.mode = fm.Mode
+			}
+			if opts.allowedTools, err = parseAllowedTools(&fm.AllowedTools); err != nil {
+				return opts, fmt.Errorf("skill %s: %w", skill.Name, err)
+			}
+			if opts.scripts, err = parseScripts(&fm.Scripts); err != nil {
+				return opts, fmt.Errorf("skill %s: %w", skill.Name, err)
+			}
+		}
+	}
+	switch opts.mode {
+	case "":
+		opts.mode = ModeInline
+	case ModeInline, ModeFork, ModeForkWithContext:
+	default:
+		return opts, fmt.Errorf("skill %s: unknown mode %q (expected %s, %s or %s)", skill.Name, opts.mode, ModeInline, ModeFork, ModeForkWithContext)
+	}
+	return opts, nil
+}
+
+// extractFrontMatter 提取 --- 包围的 frontmatter，没有时返回空串
+func extractFrontMatter(content string) string {
+	content = strings.TrimPrefix(strings.ReplaceAll(content, "\r\n", "\n"), "\ufeff")
+	if !strings.HasPrefix(content, "---\n") {
+		return ""
+	}
+	rest := content[len("---\n"):]
+	if end := strings.Index(rest, "\n---"); end >= 0 {
+		return rest[:end]
+	}
+	return ""
+}
+
+// parseAllowedTools 支持 YAML 列表或逗号/空白分隔的字符串；声明为空时返回空切片（不提供工具）
+func parseAllowedTools(node *yaml.Node) ([]string, error) {
+	var items []string
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.ScalarNode:
+		items = strings.FieldsFunc(node.Value, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+	case yaml.SequenceNode:
+		if err := node.Decode(&items); err != nil {
+			return nil, fmt.Errorf("invalid allowed-tools: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid allowed-tools: expected a list or a comma-separated string")
+	}
+	tools := make([]string, 0, len(items))
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			tools = append(tools, item)
+		}
+	}
+	return tools, nil
+}
+
+// inlineBackend 交给 eino skill 工具的 backend：清除 context 字段，使 eino 始终按 inline 执行，
+// fork 模式由 dynamicSkillTool 自行派生子智能体（eino 的 fork 依赖 adk ChatModelAgent 状态，ReAct 智能体不可用）。
+type inlineBackend struct {
+	einoskill.Backend
+}
+
+func (b inlineBackend) Get(ctx context.Context, name string) (einoskill.Skill, error) {
+	skill, err := b.Backend.Get(ctx, name)
+	skill.Context = ""
+	return skill, err
+}
+
+const forkSystemPrompt = `You are a sub-agent running the skill "%s" on behalf of a parent agent.
+Follow the skill instructions in the user message to complete the task, using only the tools available to you.
+Only your final reply is returned to the parent agent, so make it a complete, self-contained answer.`
+
+const forkSystemPromptChinese = `你是代表父智能体执行 Skill "%s" 的子智能体。
+按照用户消息中的 Skill 说明完成任务，只能使用提供给你的工具。
+只有你的最终回复会返回给父智能体，请给出完整、独立的答案。`
+
+// runForked 在子智能体中执行技能，只返回子智能体的最终回复。
+// 未注入运行器（不在 ReAct 智能体中运行）时返回空串与 false，由调用方回退到 inline。
+func (d *dynamicSkillTool) runForked(ctx context.Context, skill einoskill.Skill, opts skillOptions, task string) (string, bool, error) {
+	runner := aitool.SubAgentRunnerFromCtx(ctx)
+	if runner == nil {
+		return "", false, nil
+	}
+
+	launch, base, taskLabel, systemPrompt := "Launching skill: %s\n", "Base directory for this skill: %s\n\n%s", "Task: ", forkSystemPrompt
+	if d.useChinese {
+		launch, base, taskLabel, systemPrompt = "正在启动 Skill：%s\n", "此 Skill 的目录：%s\n\n%s", "任务：", forkSystemPromptChinese
+	}
+	content := fmt.Sprintf(launch, skill.Name) + fmt.Sprintf(base, skill.BaseDirectory, skill.Content)
+	if task = strings.TrimSpace(task); task != "" {
+		content += "\n\n" + taskLabel + task
+	}
+
+	var messages []*schema.Message
+	if opts.mode == ModeForkWithContext {
+		messages = runner.RecentMessages(d.forkContextMessages)
+	}
+	messages = append(messages, schema.UserMessage(content))
+
+	answer, err := runner.RunSubAgent(ctx, aitool.SubAgentRequest{
+		Name:         skill.Name,
+		SystemPrompt: fmt.Sprintf(systemPrompt, skill.Name),
+		Messages:     messages,
+		AllowedTools: opts.allowedTools,
+		ExcludeTools: []string{d.toolName},
+	})
+	if err != nil {
+		return "", true, fmt.Errorf("skill %s: sub-agent failed: %w", skill.Name, err)
+	}
+	if strings.TrimSpace(answer) == "" {
+		answer = fmt.Sprintf("Skill %s finished without a final answer.", skill.Name)
+	}
+	return answer, true, nil
+}
@@ -0,0 +1,182 @@
+package skill
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	aitool "github.com/rulego/rulego-components-ai/tool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRunner 记录派生请求并返回固定回复
+type fakeRunner struct {
+	requests []aitool.SubAgentRequest
+	history  []*schema.Message
+	limit    int
+	answer   string
+	err      error
+}
+
+func (r *fakeRunner) RunSubAgent(_ context.Context, req aitool.SubAgentRequest) (string, error) {
+	r.requests = append(r.requests, req)
+	return r.answer, r.err
+}
+
+func (r *fakeRunner) RecentMessages(limit int) []*schema.Message {
+	r.limit = limit
+	return r.history
+}
+
+func writeSkill(t *testing.T, dir, name, frontMatter, body string) {
+	t.Helper()
+	skillDir := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(skillDir, 0755))
+	content := "---\nname: " + name + "\ndescription: " + name + " skill\n" + frontMatter + "---\n" + body
+	require.NoError(t, os.WriteFile(filepath.Join(skillDir, "SKILL.md"), []byte(content), 0644))
+}
+
+func newForkTestTool(t *testing.T, config Config) (tool.InvokableTool, string) {
+	t.Helper()
+	dir := t.TempDir()
+	config.LocalDirs = []string{dir}
+	tTool, err := NewTool(config)
+	require.NoError(t, err)
+	return tTool.(tool.InvokableTool), dir
+}
+
+func TestParseSkillOptions(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name        string
+		frontMatter string
+		mode        string
+		tools       []string
+		wantErr     bool
+	}{
+		{"默认 inline", "", ModeInline, nil, false},
+		{"mode 字段", "mode: fork\n", ModeFork, nil, false},
+		{"兼容 eino context 字段", "context: fork_with_context\n", ModeForkWithContext, nil, false},
+		{"mode 优先于 context", "context: fork\nmode: inline\n", ModeInline, nil, false},
+		{"逗号分隔的工具", "mode: fork\nallowed-tools: read, grep,glob\n", ModeFork, []string{"read", "grep", "glob"}, false},
+		{"列表形式的工具", "mode: fork\nallowed-tools:\n  - read\n  - bash\n", ModeFork, []string{"read", "bash"}, false},
+		{"空工具列表", "mode: fork\nallowed-tools: []\n", ModeFork, []string{}, false},
+		{"未知模式", "mode: background\n", "", nil, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := "s" + string(rune('a'+i))
+			writeSkill(t, dir, name, tt.frontMatter, "body")
+			backend := NewMultiBackend([]string{dir})
+			skill, err := backend.Get(context.Background(), name)
+			require.NoError(t, err)
+
+			opts, err := parseSkillOptions(skill)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.mode, opts.mode)
+			assert.Equal(t, tt.tools, opts.allowedTools)
+		})
+	}
+}
+
+func TestForkSkillRunsSubAgent(t *testing.T) {
+	invokable, dir := newForkTestTool(t, Config{})
+	writeSkill(t, dir, "review", "mode: fork\nallowed-tools: read, grep\n", "Review the code carefully.")
+
+	runner := &fakeRunner{answer: "LGTM, 2 nits"}
+	ctx := aitool.WithSubAgentRunner(context.Background(), runner)
+	output, err := invokable.InvokableRun(ctx, `{"skill": "review", "task": "review main.go"}`)
+	require.NoError(t, err)
+
+	// 只返回子智能体的最终回复
+	assert.Equal(t, "LGTM, 2 nits", output)
+	require.Len(t, runner.requests, 1)
+	req := runner.requests[0]
+	assert.Equal(t, "review", req.Name)
+	assert.Contains(t, req.SystemPrompt, `skill "review"`)
+	assert.Equal(t, []string{"read", "grep"}, req.AllowedTools)
+	assert.Equal(t, []string{"skill"}, req.ExcludeTools)
+	// fork 不携带父对话
+	require.Len(t, req.Messages, 1)
+	assert.Equal(t, schema.User, req.Messages[0].Role)
+	assert.Contains(t, req.Messages[0].Content, "Review the code carefully.")
+	assert.Contains(t, req.Messages[0].Content, "Base directory for this skill: ")
+	assert.Contains(t, req.Messages[0].Content, "Task: review main.go")
+}
+
+func TestForkWithContextSeedsParentMessages(t *testing.T) {
+	invokable, dir := newForkTestTool(t, Config{ForkContextMessages: 5})
+	writeSkill(t, dir, "summarize", "context: fork_with_context\n", "Summarize the conversation.")
+
+	runner := &fakeRunner{
+		answer:  "summary",
+		history: []*schema.Message{schema.UserMessage("hi"), schema.AssistantMessage("hello", nil)},
+	}
+	ctx := aitool.WithSubAgentRunner(context.Background(), runner)
+	output, err := invokable.InvokableRun(ctx, `{"skill": "summarize"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "summary", output)
+
+	assert.Equal(t, 5, runner.limit)
+	req := runner.requests[0]
+	// 未声明 allowed-tools：继承父智能体全部工具
+	assert.Nil(t, req.AllowedTools)
+	require.Len(t, req.Messages, 3)
+	assert.Equal(t, "hi", req.Messages[0].Content)
+	assert.Equal(t, "hello", req.Messages[1].Content)
+	assert.Contains(t, req.Messages[2].Content, "Summarize the conversation.")
+}
+
+func TestForkSkillErrorsAndEmptyAnswer(t *testing.T) {
+	invokable, dir := newForkTestTool(t, Config{})
+	writeSkill(t, dir, "broken", "mode: fork\n", "x")
+
+	runner := &fakeRunner{err: errors.New("model unavailable")}
+	ctx := aitool.WithSubAgentRunner(context.Background(), runner)
+	_, err := invokable.InvokableRun(ctx, `{"skill": "broken"}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "model unavailable")
+
+	runner.err = nil
+	output, err := invokable.InvokableRun(ctx, `{"skill": "broken"}`)
+	require.NoError(t, err)
+	assert.Contains(t, output, "finished without a final answer")
+}
+
+func TestInlineSkillDoesNotFork(t *testing.T) {
+	invokable, dir := newForkTestTool(t, Config{})
+	writeSkill(t, dir, "hello", "", "Hello inline!")
+	writeSkill(t, dir, "forked", "mode: fork\n", "Forked body")
+
+	runner := &fakeRunner{answer: "should not be used"}
+	ctx := aitool.WithSubAgentRunner(context.Background(), runner)
+	output, err := invokable.InvokableRun(ctx, `{"skill": "hello"}`)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Hello inline!")
+	assert.Empty(t, runner.requests)
+
+	// 未注入运行器（不在 ReAct 智能体中）时 fork 技能回退为 inline
+	output, err = invokable.InvokableRun(context.Background(), `{"skill": "forked"}`)
+	require.NoError(t, err)
+	assert.Contains(t, output, "Forked body")
+}
+
+func TestInfoHasOptionalTaskParam(t *testing.T) {
+	invokable, _ := newForkTestTool(t, Config{})
+	info, err := invokable.Info(context.Background())
+	require.NoError(t, err)
+	params, err := info.ParamsOneOf.ToJSONSchema()
+	require.NoError(t, err)
+	_, ok := params.Properties.Get("task")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"skill"}, params.Required)
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -15,14 +16,15 @@ import (
 //
 // 背景：eino v0.9+ 起 einoskill.NewLocalBackend 被替换为 NewBackendFromFilesystem，
 // 后者要求传入一个 filesystem.Backend；而 adk/filesystem 仅提供 InMemoryBackend，
-// 没有读真实磁盘的实现。skill middleware 只用到 GlobInfo（查找 */SKILL.md）与
-// Read（读取文件内容），因此这里仅正确实现这两个方法，其余返回 errOSBackendNotSupported。
+// 没有读真实磁盘的实现。skill middleware 用到 GlobInfo（查找 */SKILL.md）与
+// Read（读取文件内容），LsInfo/GrepRaw 供浏览技能目录下的附带文件；osBackend 只读，
+// Write/Edit 返回 errOSBackendNotSupported。
 type osBackend struct{}
 
 // newOSBackend 创建基于本地文件系统的 backend。
 func newOSBackend() *osBackend { return &osBackend{} }
 
-// errOSBackendNotSupported 表示该方法在 osBackend 中未实现（skill 不会调用）。
+// errOSBackendNotSupported 表示该方法在 osBackend 中未实现（skill 只读，不会调用）。
 var errOSBackendNotSupported = errors.New("osBackend: operation not supported")
 
 // GlobInfo 按通配符匹配文件，返回匹配项信息。pattern 相对 base 解析（如 base=dir, pattern=*/SKILL.md）。
@@ -93,14 +95,101 @@ func (b *osBackend) Read(_ context.Context, req *filesystem.ReadRequest) (*files
 	return &filesystem.FileContent{Content: content}, nil
 }
 
-// LsInfo 未实现（skill 不使用）。
-func (b *osBackend) LsInfo(_ context.Context, _ *filesystem.LsInfoRequest) ([]filesystem.FileInfo, error) {
-	return nil, errOSBackendNotSupported
+// LsInfo 列出目录的直接子项（不含隐藏文件），返回绝对路径，与 GlobInfo 保持一致。
+func (b *osBackend) LsInfo(_ context.Context, req *filesystem.LsInfoRequest) ([]filesystem.FileInfo, error) {
+	if req == nil {
+		return nil, errors.New("osBackend.LsInfo: nil request")
+	}
+	dir := req.Path
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]filesystem.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, infoErr := e.Info()
+		if infoErr != nil {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if abs, absErr := filepath.Abs(path); absErr == nil {
+			path = abs
+		}
+		result = append(result, filesystem.FileInfo{
+			Path:       path,
+			IsDir:      e.IsDir(),
+			Size:       info.Size(),
+			ModifiedAt: info.ModTime().UTC().Format(time.RFC3339),
+		})
+	}
+	return result, nil
 }
 
-// GrepRaw 未实现（skill 不使用）。
-func (b *osBackend) GrepRaw(_ context.Context, _ *filesystem.GrepRequest) ([]filesystem.GrepMatch, error) {
-	return nil, errOSBackendNotSupported
+// GrepRaw 在目录下递归按正则逐行搜索（跳过隐藏文件与目录），Glob 按文件名过滤。
+// 不支持 EnableMultiline 与上下文行，技能目录通常很小，无需 ripgrep。
+func (b *osBackend) GrepRaw(ctx context.Context, req *filesystem.GrepRequest) ([]filesystem.GrepMatch, error) {
+	if req == nil {
+		return nil, errors.New("osBackend.GrepRaw: nil request")
+	}
+	if req.Pattern == "" {
+		return nil, errors.New("osBackend.GrepRaw: empty pattern")
+	}
+	pattern := req.Pattern
+	if req.CaseInsensitive {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	root := req.Path
+	if root == "" {
+		root = "."
+	}
+	var matches []filesystem.GrepMatch
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != root {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if req.Glob != "" {
+			if ok, _ := filepath.Match(req.Glob, d.Name()); !ok {
+				return nil
+			}
+		}
+		data, readErr := os.ReadFile(path)
+		if readErr != nil {
+			return nil
+		}
+		for i, line := range strings.Split(string(data), "\n") {
+			if re.MatchString(line) {
+				matches = append(matches, filesystem.GrepMatch{
+					Content: strings.TrimSuffix(line, "\r"),
+					Path:    path,
+					Line:    i + 1,
+				})
+			}
+		}
+		return nil
+	})
+	return matches, err
 }
 
 // Write 未实现（skill 只读）。
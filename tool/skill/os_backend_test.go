@@ -142,17 +142,67 @@ func TestOSBackend_GlobInfo_RelativeBase(t *testing.T) {
 	}
 }
 
-// TestOSBackend_UnsupportedMethods 验证 skill 不用的方法返回 not-supported。
-func TestOSBackend_UnsupportedMethods(t *testing.T) {
+// TestOSBackend_LsInfo 验证列出直接子项并跳过隐藏文件。
+func TestOSBackend_LsInfo(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "scripts"), 0755)
+	os.WriteFile(filepath.Join(dir, "scripts", "run.sh"), []byte("echo hi"), 0644)
+	os.WriteFile(filepath.Join(dir, "SKILL.md"), []byte("# s"), 0644)
+	os.WriteFile(filepath.Join(dir, ".hidden"), []byte("x"), 0644)
+
+	infos, err := newOSBackend().LsInfo(context.Background(), &filesystem.LsInfoRequest{Path: dir})
+	if err != nil {
+		t.Fatalf("LsInfo 失败: %v", err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("期望 2 个子项，got %d", len(infos))
+	}
+	for _, info := range infos {
+		if !filepath.IsAbs(info.Path) {
+			t.Errorf("Path 必须为绝对路径，got %s", info.Path)
+		}
+		if filepath.Base(info.Path) == "scripts" && !info.IsDir {
+			t.Errorf("scripts 应为目录")
+		}
+	}
+}
+
+// TestOSBackend_GrepRaw 验证递归正则搜索、Glob 过滤与忽略大小写。
+func TestOSBackend_GrepRaw(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "references"), 0755)
+	os.WriteFile(filepath.Join(dir, "SKILL.md"), []byte("# PDF\nuse extract.py\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "references", "api.md"), []byte("intro\npdf pages\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "references", "notes.txt"), []byte("pdf notes\n"), 0644)
+
 	b := newOSBackend()
 	ctx := context.Background()
+	matches, err := b.GrepRaw(ctx, &filesystem.GrepRequest{Pattern: "pdf", Path: dir, Glob: "*.md", CaseInsensitive: true})
+	if err != nil {
+		t.Fatalf("GrepRaw 失败: %v", err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("期望 2 个匹配，got %d: %+v", len(matches), matches)
+	}
+	for _, m := range matches {
+		if filepath.Base(m.Path) == "api.md" && (m.Line != 2 || m.Content != "pdf pages") {
+			t.Errorf("api.md 匹配不正确: %+v", m)
+		}
+	}
 
-	if _, err := b.LsInfo(ctx, &filesystem.LsInfoRequest{}); err == nil {
-		t.Error("LsInfo 应返回 not-supported")
+	if _, err := b.GrepRaw(ctx, &filesystem.GrepRequest{Path: dir}); err == nil {
+		t.Error("空 pattern 应报错")
 	}
-	if _, err := b.GrepRaw(ctx, &filesystem.GrepRequest{}); err == nil {
-		t.Error("GrepRaw 应返回 not-supported")
+	if _, err := b.GrepRaw(ctx, &filesystem.GrepRequest{Pattern: "(", Path: dir}); err == nil {
+		t.Error("非法正则应报错")
 	}
+}
+
+// TestOSBackend_UnsupportedMethods 验证只读 backend 的写方法返回 not-supported。
+func TestOSBackend_UnsupportedMethods(t *testing.T) {
+	b := newOSBackend()
+	ctx := context.Background()
+
 	if err := b.Write(ctx, &filesystem.WriteRequest{}); err == nil {
 		t.Error("Write 应返回 not-supported")
 	}
@@ -0,0 +1,97 @@
+package skill
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	einoskill "github.com/cloudwego/eino/adk/middlewares/skill"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPromptArgument 技能未声明 arguments 时提供的唯一参数，替换内容中的 $ARGUMENTS
+const DefaultPromptArgument = "arguments"
+
+// PromptArgument 技能渲染为提示词模板（如 MCP prompts）时的参数，在 SKILL.md frontmatter 中声明：
+//
+//	---
+//	name: pdf-extract
+//	description: Extract text from a PDF
+//	arguments:
+//	  - name: file
+//	    description: PDF file to extract
+//	    required: true
+//	---
+//	Extract the text of ${file}.
+//
+// 也支持字符串列表形式（arguments: [file, pages]），此时参数均为可选。
+type PromptArgument struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Required    bool   `yaml:"required"`
+}
+
+// PromptArguments 读取技能声明的参数，未声明时返回一个可选的 DefaultPromptArgument。
+func PromptArguments(skill einoskill.Skill) ([]PromptArgument, error) {
+	defaultArgs := []PromptArgument{{Name: DefaultPromptArgument, Description: "Arguments passed to the skill"}}
+	if skill.BaseDirectory == "" {
+		return defaultArgs, nil
+	}
+	data, err := os.ReadFile(filepath.Join(skill.BaseDirectory, "SKILL.md"))
+	if err != nil {
+		return defaultArgs, nil
+	}
+	var fm struct {
+		Arguments yaml.Node `yaml:"arguments"`
+	}
+	if err := yaml.Unmarshal([]byte(extractFrontMatter(string(data))), &fm); err != nil {
+		return nil, fmt.Errorf("parse frontmatter of skill %s: %w", skill.Name, err)
+	}
+	if fm.Arguments.Kind == 0 {
+		return defaultArgs, nil
+	}
+	if fm.Arguments.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("skill %s: invalid arguments: expected a list", skill.Name)
+	}
+	args := make([]PromptArgument, 0, len(fm.Arguments.Content))
+	for _, item := range fm.Arguments.Content {
+		var arg PromptArgument
+		if item.Kind == yaml.ScalarNode {
+			arg.Name = item.Value
+		} else if err := item.Decode(&arg); err != nil {
+			return nil, fmt.Errorf("skill %s: invalid arguments: %w", skill.Name, err)
+		}
+		if arg.Name = strings.TrimSpace(arg.Name); arg.Name == "" {
+			return nil, fmt.Errorf("skill %s: invalid arguments: missing name", skill.Name)
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// RenderPrompt 渲染技能内容：替换 ${name} 与 $ARGUMENTS 占位符，内容中没有占位符的参数值追加在末尾。
+// 技能目录非空时在开头注明，便于把内容中的相对路径转换为绝对路径。
+func RenderPrompt(skill einoskill.Skill, args []PromptArgument, values map[string]string) string {
+	content := skill.Content
+	var unused []string
+	for _, arg := range args {
+		value := values[arg.Name]
+		placeholder := "${" + arg.Name + "}"
+		if arg.Name == DefaultPromptArgument && !strings.Contains(content, placeholder) {
+			placeholder = "$ARGUMENTS"
+		}
+		if strings.Contains(content, placeholder) {
+			content = strings.ReplaceAll(content, placeholder, value)
+		} else if value != "" {
+			unused = append(unused, arg.Name+": "+value)
+		}
+	}
+	if len(unused) > 0 {
+		content += "\n\nARGUMENTS:\n" + strings.Join(unused, "\n")
+	}
+	if skill.BaseDirectory != "" {
+		content = fmt.Sprintf("Base directory for this skill: %s\n\n%s", skill.BaseDirectory, content)
+	}
+	return content
+}
@@ -0,0 +1,56 @@
+package skill
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	einoskill "github.com/cloudwego/eino/adk/middlewares/skill"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePromptSkill(t *testing.T, frontMatter, content string) einoskill.Skill {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "SKILL.md"), []byte("---\n"+frontMatter+"---\n"+content), 0644))
+	return einoskill.Skill{
+		FrontMatter:   einoskill.FrontMatter{Name: "demo", Description: "demo skill"},
+		Content:       content,
+		BaseDirectory: dir,
+	}
+}
+
+func TestPromptArguments(t *testing.T) {
+	s := writePromptSkill(t, "name: demo\narguments:\n  - name: file\n    description: input file\n    required: true\n  - pages\n", "Read ${file}")
+	args, err := PromptArguments(s)
+	require.NoError(t, err)
+	assert.Equal(t, []PromptArgument{
+		{Name: "file", Description: "input file", Required: true},
+		{Name: "pages"},
+	}, args)
+
+	s = writePromptSkill(t, "name: demo\n", "Review $ARGUMENTS")
+	args, err = PromptArguments(s)
+	require.NoError(t, err)
+	require.Len(t, args, 1)
+	assert.Equal(t, DefaultPromptArgument, args[0].Name)
+	assert.False(t, args[0].Required)
+
+	_, err = PromptArguments(writePromptSkill(t, "name: demo\narguments: file\n", ""))
+	assert.Error(t, err)
+	_, err = PromptArguments(writePromptSkill(t, "name: demo\narguments:\n  - description: no name\n", ""))
+	assert.Error(t, err)
+}
+
+func TestRenderPrompt(t *testing.T) {
+	s := writePromptSkill(t, "name: demo\n", "Read ${file}.")
+	args := []PromptArgument{{Name: "file"}, {Name: "pages"}}
+	text := RenderPrompt(s, args, map[string]string{"file": "a.pdf", "pages": "1-3"})
+	assert.Equal(t, "Base directory for this skill: "+s.BaseDirectory+"\n\nRead a.pdf.\n\nARGUMENTS:\npages: 1-3", text)
+
+	s.BaseDirectory = ""
+	s.Content = "Review $ARGUMENTS now."
+	text = RenderPrompt(s, []PromptArgument{{Name: DefaultPromptArgument}}, map[string]string{DefaultPromptArgument: "main.go"})
+	assert.Equal(t, "Review main.go now.", text)
+}
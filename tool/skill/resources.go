@@ -0,0 +1,412 @@
+package skill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/eino/adk/filesystem"
+	einoskill "github.com/cloudwego/eino/adk/middlewares/skill"
+	"github.com/rulego/rulego-components-ai/tool/bash"
+	"github.com/rulego/rulego-components-ai/tool/common"
+	"gopkg.in/yaml.v3"
+)
+
+// 技能工具的操作类型，通过 operation 参数指定，为空时等同 invoke。
+// 技能目录（SKILL.md 所在目录）常附带 scripts/、references/、模板等文件：
+//
+//	pdf-extract/
+//	├── SKILL.md
+//	├── scripts/extract.py
+//	└── references/format.md
+const (
+	// OpInvoke 执行技能（默认）
+	OpInvoke = "invoke"
+	// OpListFiles 列出技能目录下的附带文件
+	OpListFiles = "list_files"
+	// OpReadFile 读取技能目录下的附带文件
+	OpReadFile = "read_file"
+	// OpRunScript 通过 bash 工具的安全策略执行技能声明的脚本
+	OpRunScript = "run_script"
+)
+
+const (
+	// maxSkillFiles list_files 最多列出的文件数
+	maxSkillFiles = 500
+	// maxSkillFileSize read_file 单个文件的最大字节数 (256KB)，更大的文件需用 offset/limit 分段读取
+	maxSkillFileSize = 256 * 1024
+	// skillScriptsDir 未在 frontmatter 声明 scripts 时，该目录下的文件视为脚本
+	skillScriptsDir = "scripts"
+)
+
+// skillArgs 技能工具的调用参数
+type skillArgs struct {
+	Operation string   `json:"operation"`
+	Skill     string   `json:"skill"`
+	Task      string   `json:"task"`
+	Path      string   `json:"path"`
+	Offset    int      `json:"offset"`
+	Limit     int      `json:"limit"`
+	Script    string   `json:"script"`
+	Args      []string `json:"args"`
+	WorkDir   string   `json:"work_dir"`
+	Timeout   int      `json:"timeout"`
+}
+
+// skillScript 技能声明的脚本
+type skillScript struct {
+	// name 脚本名：frontmatter map 形式的键，否则为不含扩展名的文件名
+	name string
+	// path 相对技能目录的路径（使用 / 分隔）
+	path string
+}
+
+// parseScripts 解析 frontmatter 的 scripts 字段，支持两种形式：
+//
+//	scripts:
+//	  extract: scripts/extract.py
+//
+//	scripts:
+//	  - scripts/extract.py
+//
+// 未声明时返回 nil，由调用方回退到 scripts/ 目录。
+func parseScripts(node *yaml.Node) ([]skillScript, error) {
+	var scripts []skillScript
+	switch node.Kind {
+	case 0:
+		return nil, nil
+	case yaml.MappingNode:
+		var m map[string]string
+		if err := node.Decode(&m); err != nil {
+			return nil, fmt.Errorf("invalid scripts: %w", err)
+		}
+		for name, path := range m {
+			scripts = append(scripts, skillScript{name: name, path: filepath.ToSlash(filepath.Clean(path))})
+		}
+	case yaml.SequenceNode:
+		var paths []string
+		if err := node.Decode(&paths); err != nil {
+			return nil, fmt.Errorf("invalid scripts: %w", err)
+		}
+		for _, path := range paths {
+			scripts = append(scripts, skillScript{name: scriptName(path), path: filepath.ToSlash(filepath.Clean(path))})
+		}
+	default:
+		return nil, fmt.Errorf("invalid scripts: expected a list of paths or a name-to-path map")
+	}
+	sort.Slice(scripts, func(i, j int) bool { return scripts[i].name < scripts[j].name })
+	return scripts, nil
+}
+
+// scriptName 不含扩展名的文件名
+func scriptName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// skillScripts 返回技能的脚本：frontmatter 声明优先，否则为 scripts/ 目录下的全部文件
+func skillScripts(baseDir string, opts skillOptions) []skillScript {
+	if opts.scripts != nil {
+		return opts.scripts
+	}
+	var scripts []skillScript
+	root := filepath.Join(baseDir, skillScriptsDir)
+	_ = walkSkillDir(root, func(rel string, _ fs.FileInfo) {
+		scripts = append(scripts, skillScript{
+			name: scriptName(rel),
+			path: skillScriptsDir + "/" + rel,
+		})
+	})
+	return scripts
+}
+
+// findScript 按脚本名或相对路径查找
+func findScript(scripts []skillScript, name string) (skillScript, bool) {
+	clean := filepath.ToSlash(filepath.Clean(name))
+	for _, s := range scripts {
+		if s.name == name || s.path == clean {
+			return s, true
+		}
+	}
+	return skillScript{}, false
+}
+
+// walkSkillDir 遍历目录下的文件（跳过隐藏文件与目录），fn 收到使用 / 分隔的相对路径
+func walkSkillDir(root string, fn func(rel string, info fs.FileInfo)) error {
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if path == root {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		fn(filepath.ToSlash(rel), info)
+		return nil
+	})
+}
+
+// resolveSkillPath 把相对技能目录的路径（或技能目录内的绝对路径）解析为真实路径，
+// 解析符号链接后仍必须位于技能目录内，防止通过 ../ 或链接读取技能目录外的文件。
+func resolveSkillPath(baseDir, path string) (string, error) {
+	if strings.TrimSpace(path) == "" {
+		return "", common.ErrPathEmpty()
+	}
+	base, err := filepath.EvalSymlinks(baseDir)
+	if err != nil {
+		return "", common.ErrFileNotFound(baseDir)
+	}
+	target := path
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(baseDir, target)
+	}
+	target = filepath.Clean(target)
+	if !isInside(target, filepath.Clean(baseDir)) && !isInside(target, base) {
+		return "", common.ErrPathEscape(path)
+	}
+	real, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", common.ErrFileNotFound(path)
+		}
+		return "", common.ErrPathInvalid(path)
+	}
+	if !isInside(real, base) {
+		return "", common.ErrPathEscape(path)
+	}
+	return real, nil
+}
+
+// isInside path 是否为 base 本身或位于 base 之下
+func isInside(path, base string) bool {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// resolveSkill 获取技能及其扩展选项，技能必须来自文件系统（有 base directory）
+func (d *dynamicSkillTool) resolveSkill(ctx context.Context, name string) (einoskill.Skill, skillOptions, error) {
+	if name == "" {
+		return einoskill.Skill{}, skillOptions{}, common.ErrInvalidParams("skill is required")
+	}
+	skill, err := d.backend.Get(ctx, name)
+	if err != nil {
+		return skill, skillOptions{}, err
+	}
+	if skill.BaseDirectory == "" {
+		return skill, skillOptions{}, common.NewErrorf(common.ErrCodeOperationNotSupported, "skill %s has no base directory", name)
+	}
+	opts, err := parseSkillOptions(skill)
+	return skill, opts, err
+}
+
+// listFiles 列出技能目录下的文件，并标记可通过 run_script 执行的脚本
+func (d *dynamicSkillTool) listFiles(ctx context.Context, args skillArgs) (string, error) {
+	skill, opts, err := d.resolveSkill(ctx, args.Skill)
+	if err != nil {
+		return "", err
+	}
+	scripts := make(map[string]string)
+	for _, s := range skillScripts(skill.BaseDirectory, opts) {
+		scripts[s.path] = s.name
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Base directory for this skill: %s\n", skill.BaseDirectory)
+	count := 0
+	err = walkSkillDir(skill.BaseDirectory, func(rel string, info fs.FileInfo) {
+		count++
+		if count > maxSkillFiles {
+			return
+		}
+		fmt.Fprintf(&sb, "- %s (%d bytes)", rel, info.Size())
+		if name, ok := scripts[rel]; ok {
+			fmt.Fprintf(&sb, " [script: %s]", name)
+		}
+		sb.WriteString("\n")
+	})
+	if err != nil {
+		return "", err
+	}
+	if count > maxSkillFiles {
+		fmt.Fprintf(&sb, "... %d more files not shown\n", count-maxSkillFiles)
+	}
+	return sb.String(), nil
+}
+
+// readFile 读取技能目录下的文件，路径限制在技能目录内
+func (d *dynamicSkillTool) readFile(ctx context.Context, args skillArgs) (string, error) {
+	skill, _, err := d.resolveSkill(ctx, args.Skill)
+	if err != nil {
+		return "", err
+	}
+	path, err := resolveSkillPath(skill.BaseDirectory, args.Path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", common.ErrPathIsDirectory(args.Path)
+	}
+	if args.Offset <= 1 && args.Limit <= 0 && info.Size() > maxSkillFileSize {
+		return "", common.ErrFileTooLarge(info.Size(), maxSkillFileSize)
+	}
+	content, err := newOSBackend().Read(ctx, &filesystem.ReadRequest{FilePath: path, Offset: args.Offset, Limit: args.Limit})
+	if err != nil {
+		return "", err
+	}
+	return content.Content, nil
+}
+
+// runScript 执行技能声明的脚本：按扩展名选择解释器，命令交给 bash 工具执行，
+// 因此与直接调用 bash 工具一样受 allow/deny 列表、命令策略、执行隔离与超时约束。
+func (d *dynamicSkillTool) runScript(ctx context.Context, args skillArgs) (string, error) {
+	skill, opts, err := d.resolveSkill(ctx, args.Skill)
+	if err != nil {
+		return "", err
+	}
+	if args.Script == "" {
+		return "", common.ErrInvalidParams("script is required")
+	}
+	script, ok := findScript(skillScripts(skill.BaseDirectory, opts), args.Script)
+	if !ok {
+		return "", common.NewErrorf(common.ErrCodeInvalidParams, "skill %s does not declare script %s", skill.Name, args.Script)
+	}
+	path, err := resolveSkillPath(skill.BaseDirectory, script.path)
+	if err != nil {
+		return "", err
+	}
+	if d.scriptRunner == nil {
+		return "", common.ErrOperationNotSupported(OpRunScript)
+	}
+
+	command := scriptCommand(path, d.scriptShell)
+	for _, arg := range args.Args {
+		command += " " + shellQuote(arg, d.scriptShell)
+	}
+	params, err := json.Marshal(map[string]any{
+		"command":  command,
+		"work_dir": args.WorkDir,
+		"timeout":  args.Timeout,
+	})
+	if err != nil {
+		return "", err
+	}
+	return d.scriptRunner.InvokableRun(ctx, string(params))
+}
+
+// scriptInterpreters 按扩展名选择的解释器，其它脚本直接执行（需可执行权限）
+var scriptInterpreters = map[string]string{
+	".py":  "python3",
+	".sh":  "bash",
+	".js":  "node",
+	".mjs": "node",
+	".cjs": "node",
+	".rb":  "ruby",
+	".pl":  "perl",
+}
+
+// withScriptInterpreters 返回补充了当前平台脚本解释器的允许列表（不修改原切片），
+// 默认脚本执行器以 allow 模式运行，缺少解释器时 run_script 会被拒绝
+func withScriptInterpreters(allow []string) []string {
+	result := append([]string(nil), allow...)
+	var missing []string
+	for _, interpreter := range scriptInterpreters {
+		interpreter = platformInterpreter(interpreter)
+		if !common.ContainsIgnoreCase(result, interpreter) && !common.ContainsIgnoreCase(missing, interpreter) {
+			missing = append(missing, interpreter)
+		}
+	}
+	sort.Strings(missing)
+	return append(result, missing...)
+}
+
+// platformInterpreter Windows 下 python3 通常以 python 安装
+func platformInterpreter(interpreter string) string {
+	if interpreter == "python3" && runtime.GOOS == "windows" {
+		return "python"
+	}
+	return interpreter
+}
+
+// scriptCommand 构建执行脚本的命令，路径按执行器的 Shell 类型引用
+func scriptCommand(path string, shell bash.ShellType) string {
+	interpreter := platformInterpreter(scriptInterpreters[strings.ToLower(filepath.Ext(path))])
+	if interpreter == "" {
+		quoted := shellQuote(path, shell)
+		// PowerShell 中以引号开头的是字符串表达式，需要调用运算符 & 才会执行
+		if shell == bash.ShellTypePowerShell && quoted != path {
+			return "& " + quoted
+		}
+		return quoted
+	}
+	return interpreter + " " + shellQuote(path, shell)
+}
+
+// shellQuote 按 Shell 类型引用参数，仅由安全字符组成的参数原样返回：
+//   - sh/bash：单引号包裹，内部单引号先闭合引号再转义输出
+//   - PowerShell：单引号包裹（不做变量展开），内部单引号写两次
+//   - cmd：双引号包裹，内部双引号写作 ""，% 置于引号外并用 ^ 转义以避免变量展开
+func shellQuote(s string, shell bash.ShellType) string {
+	switch shell {
+	case bash.ShellTypePowerShell:
+		if s != "" && isSafeArg(s, `_-./:\`) {
+			return s
+		}
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	case bash.ShellTypeCMD:
+		if s != "" && isSafeArg(s, `_-./:\`) {
+			return s
+		}
+		s = strings.ReplaceAll(s, `"`, `""`)
+		return `"` + strings.ReplaceAll(s, "%", `"^%"`) + `"`
+	default:
+		if s == "" {
+			return "''"
+		}
+		if isSafeArg(s, "_-./:=@%+,") {
+			return s
+		}
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+}
+
+// isSafeArg 参数是否只由字母数字与给定的安全字符组成
+func isSafeArg(s, safeChars string) bool {
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune(safeChars, r)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,248 @@
+package skill
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/rulego/rulego-components-ai/tool/bash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeScriptRunner 记录交给 bash 工具的参数
+type fakeScriptRunner struct {
+	calls []map[string]any
+}
+
+func (r *fakeScriptRunner) Info(context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "bash"}, nil
+}
+
+func (r *fakeScriptRunner) InvokableRun(_ context.Context, arguments string, _ ...tool.Option) (string, error) {
+	var params map[string]any
+	if err := json.Unmarshal([]byte(arguments), &params); err != nil {
+		return "", err
+	}
+	r.calls = append(r.calls, params)
+	return "ok", nil
+}
+
+// writePdfSkill 创建带 scripts/ 与 references/ 的技能
+func writePdfSkill(t *testing.T, dir, frontMatter string) string {
+	t.Helper()
+	writeSkill(t, dir, "pdf-extract", frontMatter, "Run scripts/extract.py to extract text.")
+	skillDir := filepath.Join(dir, "pdf-extract")
+	require.NoError(t, os.MkdirAll(filepath.Join(skillDir, "scripts"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(skillDir, "references"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(skillDir, "scripts", "extract.py"), []byte("print('x')\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(skillDir, "references", "format.md"), []byte("line1\nline2\nline3\n"), 0644))
+	return skillDir
+}
+
+func runSkillOp(t *testing.T, tl tool.InvokableTool, args map[string]any) string {
+	t.Helper()
+	b, err := json.Marshal(args)
+	require.NoError(t, err)
+	result, err := tl.InvokableRun(context.Background(), string(b))
+	require.NoError(t, err)
+	return result
+}
+
+func TestSkillTool_ListFiles(t *testing.T) {
+	tl, dir := newForkTestTool(t, Config{ScriptRunner: &fakeScriptRunner{}})
+	skillDir := writePdfSkill(t, dir, "")
+	require.NoError(t, os.WriteFile(filepath.Join(skillDir, ".secret"), []byte("x"), 0644))
+
+	result := runSkillOp(t, tl, map[string]any{"operation": OpListFiles, "skill": "pdf-extract"})
+	assert.Contains(t, result, skillDir)
+	assert.Contains(t, result, "- SKILL.md")
+	assert.Contains(t, result, "- references/format.md (18 bytes)")
+	assert.Contains(t, result, "- scripts/extract.py (11 bytes) [script: extract]")
+	assert.NotContains(t, result, ".secret")
+}
+
+func TestSkillTool_ReadFile(t *testing.T) {
+	tl, dir := newForkTestTool(t, Config{ScriptRunner: &fakeScriptRunner{}})
+	skillDir := writePdfSkill(t, dir, "")
+	outside := filepath.Join(dir, "outside.txt")
+	require.NoError(t, os.WriteFile(outside, []byte("secret"), 0644))
+
+	result := runSkillOp(t, tl, map[string]any{"operation": OpReadFile, "skill": "pdf-extract", "path": "references/format.md"})
+	assert.Equal(t, "line1\nline2\nline3\n", result)
+
+	result = runSkillOp(t, tl, map[string]any{"operation": OpReadFile, "skill": "pdf-extract", "path": "references/format.md", "offset": 2, "limit": 1})
+	assert.Equal(t, "line2", result)
+
+	// 技能目录内的绝对路径允许
+	result = runSkillOp(t, tl, map[string]any{"operation": OpReadFile, "skill": "pdf-extract", "path": filepath.Join(skillDir, "scripts", "extract.py")})
+	assert.Equal(t, "print('x')\n", result)
+
+	for _, path := range []string{"../outside.txt", outside, "", "missing.md", "scripts"} {
+		result = runSkillOp(t, tl, map[string]any{"operation": OpReadFile, "skill": "pdf-extract", "path": path})
+		assert.Contains(t, result, "Error:", "path %q 应返回错误", path)
+		assert.NotContains(t, result, "secret")
+	}
+
+	if runtime.GOOS != "windows" {
+		require.NoError(t, os.Symlink(outside, filepath.Join(skillDir, "link.txt")))
+		result = runSkillOp(t, tl, map[string]any{"operation": OpReadFile, "skill": "pdf-extract", "path": "link.txt"})
+		assert.Contains(t, result, "Path escapes allowed directory")
+	}
+}
+
+func TestSkillTool_RunScript(t *testing.T) {
+	runner := &fakeScriptRunner{}
+	tl, dir := newForkTestTool(t, Config{ScriptRunner: runner})
+	skillDir := writePdfSkill(t, dir, "")
+	realDir, err := filepath.EvalSymlinks(skillDir)
+	require.NoError(t, err)
+
+	result := runSkillOp(t, tl, map[string]any{
+		"operation": OpRunScript,
+		"skill":     "pdf-extract",
+		"script":    "extract",
+		"args":      []string{"in.pdf", "out dir"},
+		"work_dir":  "/tmp/work",
+		"timeout":   30,
+	})
+	assert.Equal(t, "ok", result)
+	require.Len(t, runner.calls, 1)
+	interpreter := "python3"
+	if runtime.GOOS == "windows" {
+		interpreter = "python"
+	}
+	assert.Equal(t, interpreter+" "+shellQuote(filepath.Join(realDir, "scripts", "extract.py"), bash.Config{}.ResolveShellType())+" in.pdf 'out dir'", runner.calls[0]["command"])
+	assert.Equal(t, "/tmp/work", runner.calls[0]["work_dir"])
+	assert.EqualValues(t, 30, runner.calls[0]["timeout"])
+
+	// 按路径引用同样可以
+	runSkillOp(t, tl, map[string]any{"operation": OpRunScript, "skill": "pdf-extract", "script": "scripts/extract.py"})
+	require.Len(t, runner.calls, 2)
+
+	// 未声明的文件不能作为脚本执行
+	result = runSkillOp(t, tl, map[string]any{"operation": OpRunScript, "skill": "pdf-extract", "script": "references/format.md"})
+	assert.Contains(t, result, "does not declare script")
+	assert.Len(t, runner.calls, 2)
+}
+
+func TestSkillTool_RunScriptDeclared(t *testing.T) {
+	runner := &fakeScriptRunner{}
+	tl, dir := newForkTestTool(t, Config{ScriptRunner: runner})
+	writePdfSkill(t, dir, "scripts:\n  convert: references/format.md\n")
+
+	// 声明 scripts 后 scripts/ 目录不再默认可执行
+	result := runSkillOp(t, tl, map[string]any{"operation": OpRunScript, "skill": "pdf-extract", "script": "extract"})
+	assert.Contains(t, result, "does not declare script")
+
+	runSkillOp(t, tl, map[string]any{"operation": OpRunScript, "skill": "pdf-extract", "script": "convert"})
+	require.Len(t, runner.calls, 1)
+	assert.Contains(t, runner.calls[0]["command"], "format.md")
+
+	list := runSkillOp(t, tl, map[string]any{"operation": OpListFiles, "skill": "pdf-extract"})
+	assert.Contains(t, list, "references/format.md (18 bytes) [script: convert]")
+}
+
+// TestSkillTool_RunScriptBashPolicy 脚本经 bash 工具执行，受其安全策略约束
+func TestSkillTool_RunScriptBashPolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	cfg := bash.DefaultConfig()
+	cfg.ShellPath = "/bin/sh"
+	cfg.Allow = []string{"echo"}
+	tl, dir := newForkTestTool(t, Config{Bash: &cfg})
+	skillDir := filepath.Join(dir, "hello")
+	writeSkill(t, dir, "hello", "", "say hello")
+	require.NoError(t, os.MkdirAll(filepath.Join(skillDir, "scripts"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(skillDir, "scripts", "hello.sh"), []byte("echo hello $1\n"), 0755))
+
+	// allow 列表不含 bash，脚本被拒绝
+	result := runSkillOp(t, tl, map[string]any{"operation": OpRunScript, "skill": "hello", "script": "hello", "args": []string{"world"}})
+	assert.NotContains(t, result, "hello world")
+
+	cfg.Allow = []string{"echo", "bash"}
+	tl, dir = newForkTestTool(t, Config{Bash: &cfg})
+	skillDir = filepath.Join(dir, "hello")
+	writeSkill(t, dir, "hello", "", "say hello")
+	require.NoError(t, os.MkdirAll(filepath.Join(skillDir, "scripts"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(skillDir, "scripts", "hello.sh"), []byte("echo hello $1\n"), 0755))
+	if _, err := os.Stat("/bin/bash"); err != nil {
+		t.Skip("bash not installed")
+	}
+	result = runSkillOp(t, tl, map[string]any{"operation": OpRunScript, "skill": "hello", "script": "hello", "args": []string{"world"}})
+	assert.Contains(t, result, "hello world")
+}
+
+// TestSkillTool_RunScriptDefaultRunner 默认执行器的允许列表包含脚本解释器
+func TestSkillTool_RunScriptDefaultRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	if _, err := os.Stat("/bin/bash"); err != nil {
+		t.Skip("bash not installed")
+	}
+	tl, dir := newForkTestTool(t, Config{})
+	skillDir := filepath.Join(dir, "hello")
+	writeSkill(t, dir, "hello", "", "say hello")
+	require.NoError(t, os.MkdirAll(filepath.Join(skillDir, "scripts"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(skillDir, "scripts", "hello.sh"), []byte("echo hello $1\n"), 0755))
+
+	result := runSkillOp(t, tl, map[string]any{"operation": OpRunScript, "skill": "hello", "script": "hello", "args": []string{"world"}})
+	assert.Contains(t, result, "hello world")
+}
+
+func TestWithScriptInterpreters(t *testing.T) {
+	allow := []string{"echo", "node"}
+	result := withScriptInterpreters(allow)
+	assert.Equal(t, []string{"echo", "node"}, allow)
+	for _, interpreter := range []string{"bash", "node", "ruby", "perl"} {
+		assert.Contains(t, result, interpreter)
+	}
+	if runtime.GOOS == "windows" {
+		assert.Contains(t, result, "python")
+	} else {
+		assert.Contains(t, result, "python3")
+	}
+	assert.Len(t, result, 6)
+}
+
+func TestSkillTool_UnknownOperation(t *testing.T) {
+	tl, dir := newForkTestTool(t, Config{ScriptRunner: &fakeScriptRunner{}})
+	writePdfSkill(t, dir, "")
+	result := runSkillOp(t, tl, map[string]any{"operation": "delete", "skill": "pdf-extract"})
+	assert.Contains(t, result, "unknown operation")
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, "in.pdf", shellQuote("in.pdf", bash.ShellTypeSh))
+	assert.Equal(t, "''", shellQuote("", bash.ShellTypeBash))
+	assert.Equal(t, "'a b'", shellQuote("a b", bash.ShellTypeSh))
+	assert.Equal(t, `'it'\''s'`, shellQuote("it's", bash.ShellTypeSh))
+	assert.Equal(t, "'$(rm -rf /)'", shellQuote("$(rm -rf /)", bash.ShellTypeBash))
+
+	// PowerShell：单引号内不展开变量与子表达式
+	assert.Equal(t, `C:\skills\run.py`, shellQuote(`C:\skills\run.py`, bash.ShellTypePowerShell))
+	assert.Equal(t, "''", shellQuote("", bash.ShellTypePowerShell))
+	assert.Equal(t, "'a b'", shellQuote("a b", bash.ShellTypePowerShell))
+	assert.Equal(t, "'it''s'", shellQuote("it's", bash.ShellTypePowerShell))
+	assert.Equal(t, "'$(Remove-Item x); @a'", shellQuote("$(Remove-Item x); @a", bash.ShellTypePowerShell))
+
+	// cmd：双引号包裹，% 置于引号外转义
+	assert.Equal(t, `""`, shellQuote("", bash.ShellTypeCMD))
+	assert.Equal(t, `"a b"`, shellQuote("a b", bash.ShellTypeCMD))
+	assert.Equal(t, `"say ""hi"" & exit"`, shellQuote(`say "hi" & exit`, bash.ShellTypeCMD))
+	assert.Equal(t, `""^%"PATH"^%""`, shellQuote("%PATH%", bash.ShellTypeCMD))
+}
+
+func TestScriptCommand(t *testing.T) {
+	assert.Equal(t, "bash '/skills/my dir/run.sh'", scriptCommand("/skills/my dir/run.sh", bash.ShellTypeSh))
+	assert.Equal(t, "/skills/run", scriptCommand("/skills/run", bash.ShellTypeSh))
+	assert.Equal(t, `& 'C:\my skills\run.exe'`, scriptCommand(`C:\my skills\run.exe`, bash.ShellTypePowerShell))
+	assert.Equal(t, `"C:\my skills\run.bat"`, scriptCommand(`C:\my skills\run.bat`, bash.ShellTypeCMD))
+}
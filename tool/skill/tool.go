@@ -13,6 +13,8 @@ import (
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 	aitool "github.com/rulego/rulego-components-ai/tool"
+	"github.com/rulego/rulego-components-ai/tool/bash"
+	"github.com/rulego/rulego-components-ai/tool/common"
 )
 
 const (
@@ -82,6 +84,23 @@ const toolDescBaseChinese = `在主对话中执行 Skill（技能）
 
 `
 
+// toolDescResources 附带文件与脚本的说明，追加在 eino 工具描述之后
+const toolDescResources = `<skill_resources>
+A skill directory may bundle scripts, references and templates next to SKILL.md:
+- operation "list_files" lists the bundled files; scripts are marked with [script: name]
+- operation "read_file" with "path" reads a bundled file (paths are confined to the skill directory)
+- operation "run_script" with "script" and "args" runs a script declared by the skill under the bash tool's security policy
+</skill_resources>
+`
+
+const toolDescResourcesChinese = `<skill_resources>
+Skill 目录中可能在 SKILL.md 旁附带脚本、参考资料和模板：
+- operation "list_files" 列出附带文件，脚本以 [script: 名称] 标出
+- operation "read_file" 配合 "path" 读取附带文件（路径限制在 Skill 目录内）
+- operation "run_script" 配合 "script" 与 "args" 执行 Skill 声明的脚本，受 bash 工具安全策略约束
+</skill_resources>
+`
+
 // skillListTmpl 技能列表模板，与 eino prompt.go 中 toolDescriptionTemplate 格式一致
 var skillListTmpl = template.Must(template.New("skills").Parse(`
 <available_skills>
@@ -107,6 +126,15 @@ type Config struct {
 	DisabledSkills []string `json:"disabledSkills" label:"禁用的技能" desc:"该智能体禁用的技能名称列表，仅对当前智能体生效"`
 	// UseChinese controls prompt language
 	UseChinese bool `json:"useChinese" label:"使用中文" desc:"是否使用中文提示"`
+	// ForkContextMessages fork_with_context 模式携带的父对话消息数，<=0 取默认 20
+	ForkContextMessages int `json:"forkContextMessages" label:"携带上下文消息数" desc:"fork_with_context 模式的技能携带给子智能体的父对话消息数，默认 20"`
+	// Bash run_script 执行技能脚本使用的 bash 工具配置，为空时使用 bash 默认配置（allow 模式），
+	// 并在允许列表中补充 python3、bash、node 等脚本解释器；自定义 allow 模式配置需自行加入所需解释器
+	Bash *bash.Config `json:"bash" label:"脚本执行配置" desc:"执行技能脚本使用的 bash 工具配置（安全模式、允许列表、命令策略、执行隔离等），为空时使用 bash 默认配置并允许脚本解释器；自定义 allow 模式配置需在允许列表中加入所需解释器"`
+	// ScriptRunner allows providing the tool that runs skill scripts (e.g. the agent's own bash tool).
+	// If nil, a bash tool is created from Bash. Script arguments are quoted for the shell
+	// resolved from Bash.ShellPath (platform default when empty), so set it to match the runner.
+	ScriptRunner tool.InvokableTool `json:"-"`
 	// Backend allows providing a custom backend implementation.
 	// If nil, a default backend using directories will be created.
 	Backend einoskill.Backend `json:"-"`
@@ -116,32 +144,73 @@ type Config struct {
 // 通过覆写 Info() 返回稳定的 tool description（不含具体技能列表），
 // 技能列表改由 MessageModifier 在每次请求时动态注入 system prompt。
 type dynamicSkillTool struct {
-	tool.BaseTool                   // 嵌入 eino skillTool（通过 InvokableRun 委托执行）
-	backend     einoskill.Backend   // MultiBackend 引用，ListSkills 时调用以触发指纹检查
-	toolName    string              // 工具名称
-	useChinese  bool                // 是否使用中文
-	instruction string              // eino middleware 的 AdditionalInstruction（技能系统使用说明）
+	tool.BaseTool                          // 嵌入 eino skillTool（通过 InvokableRun 委托执行）
+	backend             einoskill.Backend  // MultiBackend 引用，ListSkills 时调用以触发指纹检查
+	toolName            string             // 工具名称
+	useChinese          bool               // 是否使用中文
+	instruction         string             // eino middleware 的 AdditionalInstruction（技能系统使用说明）
+	forkContextMessages int                // fork_with_context 携带的父对话消息数
+	scriptRunner        tool.InvokableTool // 执行技能脚本的 bash 工具
+	scriptShell         bash.ShellType     // 脚本执行器的 Shell 类型，决定参数引用方式
 }
 
 // Info 覆写：返回稳定描述，不含具体技能列表。
 // 原始 skillTool.Info() 会在 Desc 中嵌入初始化时的技能列表快照，
 // 如果不覆写，genToolInfos() 会把快照绑定到 chat model，导致两份列表不一致。
 func (d *dynamicSkillTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
-	descBase := toolDescBase
+	descBase := toolDescBase + toolDescResources
 	paramDesc := "The skill name (no arguments). E.g., \"pdf\" or \"xlsx\""
+	taskDesc := "Optional. The concrete task to hand over when the skill runs in a sub-agent (fork modes); ignored by inline skills"
+	opDesc := "Optional, defaults to invoke. invoke: run the skill; list_files: list files bundled in the skill directory; read_file: read a bundled file; run_script: run a script declared by the skill"
+	pathDesc := "File path relative to the skill directory (read_file)"
+	offsetDesc := "1-based line to start reading from (read_file, optional)"
+	limitDesc := "Maximum number of lines to read (read_file, optional)"
+	scriptDesc := "Script name or path as shown by list_files (run_script)"
+	argsDesc := "Arguments passed to the script (run_script, optional)"
+	workDirDesc := "Working directory for the script (run_script, optional), defaults to the bash tool's working directory"
+	timeoutDesc := "Script timeout in seconds (run_script, optional)"
 	if d.useChinese {
-		descBase = toolDescBaseChinese
+		descBase = toolDescBaseChinese + toolDescResourcesChinese
 		paramDesc = "技能名称（无需其他参数）。例如：\"pdf\" 或 \"xlsx\""
+		taskDesc = "可选。技能在子智能体中执行（fork 模式）时交给子智能体的具体任务；inline 技能忽略此参数"
+		opDesc = "可选，默认 invoke。invoke：执行技能；list_files：列出技能目录附带的文件；read_file：读取附带文件；run_script：执行技能声明的脚本"
+		pathDesc = "相对技能目录的文件路径（read_file）"
+		offsetDesc = "起始行号，从 1 开始（read_file，可选）"
+		limitDesc = "最多读取的行数（read_file，可选）"
+		scriptDesc = "脚本名或 list_files 列出的脚本路径（run_script）"
+		argsDesc = "传给脚本的参数（run_script，可选）"
+		workDirDesc = "脚本的工作目录（run_script，可选），默认使用 bash 工具的工作目录"
+		timeoutDesc = "脚本超时时间，单位秒（run_script，可选）"
 	}
 	return &schema.ToolInfo{
 		Name: d.toolName,
 		Desc: descBase,
 		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"operation": {
+				Type: schema.String,
+				Desc: opDesc,
+				Enum: []string{OpInvoke, OpListFiles, OpReadFile, OpRunScript},
+			},
 			"skill": {
 				Type:     schema.String,
 				Desc:     paramDesc,
 				Required: true,
 			},
+			"task": {
+				Type: schema.String,
+				Desc: taskDesc,
+			},
+			"path":   {Type: schema.String, Desc: pathDesc},
+			"offset": {Type: schema.Integer, Desc: offsetDesc},
+			"limit":  {Type: schema.Integer, Desc: limitDesc},
+			"script": {Type: schema.String, Desc: scriptDesc},
+			"args": {
+				Type:     schema.Array,
+				Desc:     argsDesc,
+				ElemInfo: &schema.ParameterInfo{Type: schema.String},
+			},
+			"work_dir": {Type: schema.String, Desc: workDirDesc},
+			"timeout":  {Type: schema.Integer, Desc: timeoutDesc},
 		}),
 	}, nil
 }
@@ -161,9 +230,43 @@ func (d *dynamicSkillTool) GetSkillInstruction() string {
 	return d.instruction
 }
 
-// InvokableRun 委托给嵌入的 eino skillTool 执行技能。
+// InvokableRun 按 operation 分发：list_files/read_file/run_script 访问技能目录附带的文件，
+// 错误以文本返回给模型；invoke 时 fork/fork_with_context 模式派生子智能体并只返回其最终回复，
+// inline 模式（或未注入子智能体运行器时）委托给嵌入的 eino skillTool。
 // 嵌入 tool.BaseTool 接口不会提升 InvokableTool 的方法，需要显式委托。
 func (d *dynamicSkillTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var args skillArgs
+	if err := json.Unmarshal([]byte(argumentsInJSON), &args); err == nil && args.Operation != "" && args.Operation != OpInvoke {
+		var result string
+		switch args.Operation {
+		case OpListFiles:
+			result, err = d.listFiles(ctx, args)
+		case OpReadFile:
+			result, err = d.readFile(ctx, args)
+		case OpRunScript:
+			result, err = d.runScript(ctx, args)
+		default:
+			err = common.NewErrorf(common.ErrCodeInvalidParams, "unknown operation '%s'", args.Operation)
+		}
+		if err != nil {
+			return err.Error(), nil
+		}
+		return result, nil
+	}
+	if args.Skill != "" {
+		if skill, err := d.backend.Get(ctx, args.Skill); err == nil {
+			skillOpts, err := parseSkillOptions(skill)
+			if err != nil {
+				return "", err
+			}
+			if skillOpts.mode != ModeInline {
+				if result, forked, err := d.runForked(ctx, skill, skillOpts, args.Task); forked {
+					return result, err
+				}
+			}
+		}
+	}
+
 	invokable, ok := d.BaseTool.(tool.InvokableTool)
 	if !ok {
 		return "", fmt.Errorf("underlying skill tool does not support InvokableRun")
@@ -212,8 +315,9 @@ func NewTool(config Config) (tool.BaseTool, error) {
 	}
 
 	// Create Eino skill middleware to get the tool
+	// fork 模式由 dynamicSkillTool 处理，eino 只负责 inline
 	einoConfig := &einoskill.Config{
-		Backend:    backend,
+		Backend:    inlineBackend{backend},
 		UseChinese: config.UseChinese,
 	}
 
@@ -227,12 +331,35 @@ func NewTool(config Config) (tool.BaseTool, error) {
 		return nil, fmt.Errorf("failed to create skill tool: no tool returned from middleware")
 	}
 
+	bashConfig := bash.DefaultConfig()
+	if config.Bash != nil {
+		bashConfig = *config.Bash
+	} else {
+		bashConfig.Allow = withScriptInterpreters(bashConfig.Allow)
+	}
+	scriptRunner := config.ScriptRunner
+	if scriptRunner == nil {
+		bashTool, err := bash.NewTool(bashConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create script runner: %w", err)
+		}
+		scriptRunner, _ = bashTool.(tool.InvokableTool)
+	}
+
+	forkContextMessages := config.ForkContextMessages
+	if forkContextMessages <= 0 {
+		forkContextMessages = DefaultForkContextMessages
+	}
+
 	return &dynamicSkillTool{
-		BaseTool:    middleware.AdditionalTools[0],
-		backend:     backend,
-		toolName:    "skill",
-		useChinese:  config.UseChinese,
-		instruction: middleware.AdditionalInstruction,
+		BaseTool:            middleware.AdditionalTools[0],
+		backend:             backend,
+		toolName:            "skill",
+		useChinese:          config.UseChinese,
+		instruction:         middleware.AdditionalInstruction,
+		forkContextMessages: forkContextMessages,
+		scriptRunner:        scriptRunner,
+		scriptShell:         bashConfig.ResolveShellType(),
 	}, nil
 }
 
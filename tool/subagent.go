@@ -0,0 +1,45 @@
+package tool
+
+import (
+	"context"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// SubAgentRequest 派生子智能体的请求
+type SubAgentRequest struct {
+	// Name 子智能体名称（如技能名），用于日志与事件
+	Name string
+	// SystemPrompt 子智能体的系统提示词
+	SystemPrompt string
+	// Messages 子智能体的初始消息（不含系统提示词）
+	Messages []*schema.Message
+	// AllowedTools 子智能体可用的工具名；nil 表示继承父智能体全部工具，空切片表示不提供工具
+	AllowedTools []string
+	// ExcludeTools 始终排除的工具名（如发起派生的 skill 工具自身，避免递归派生）
+	ExcludeTools []string
+}
+
+// SubAgentRunner 由宿主智能体在每次执行时注入 ctx，供工具派生使用父智能体模型的子智能体。
+type SubAgentRunner interface {
+	// RunSubAgent 运行子智能体直到给出最终回复，只返回最终回复内容
+	RunSubAgent(ctx context.Context, req SubAgentRequest) (string, error)
+	// RecentMessages 返回父智能体当前对话中最近的至多 limit 条消息（不含系统消息），limit<=0 表示全部
+	RecentMessages(limit int) []*schema.Message
+}
+
+type subAgentRunnerKey struct{}
+
+// WithSubAgentRunner 注入子智能体运行器
+func WithSubAgentRunner(ctx context.Context, runner SubAgentRunner) context.Context {
+	return context.WithValue(ctx, subAgentRunnerKey{}, runner)
+}
+
+// SubAgentRunnerFromCtx 读取子智能体运行器，未注入时返回 nil
+func SubAgentRunnerFromCtx(ctx context.Context) SubAgentRunner {
+	if ctx == nil {
+		return nil
+	}
+	runner, _ := ctx.Value(subAgentRunnerKey{}).(SubAgentRunner)
+	return runner
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 	"github.com/eino-contrib/jsonschema"
+	"github.com/rulego/rulego-components-ai/session"
 	aitool "github.com/rulego/rulego-components-ai/tool"
 	"github.com/rulego/rulego-components-ai/tool/common"
 	"github.com/rulego/rulego/utils/maps"
@@ -141,11 +142,11 @@ func (t *writeTool) InvokableRun(ctx context.Context, arguments string, opts ...
 		return "", fmt.Errorf("create workspace: %w", err)
 	}
 
-	return t.writeFile(params, r)
+	return t.writeFile(ctx, params, r)
 }
 
 // writeFile writes to a file.
-func (t *writeTool) writeFile(params OperationParams, r *common.SecurePathResolver) (string, error) {
+func (t *writeTool) writeFile(ctx context.Context, params OperationParams, r *common.SecurePathResolver) (string, error) {
 	path, err := r.Resolve(params.Path)
 	if err != nil {
 		return "", err
@@ -198,6 +199,14 @@ func (t *writeTool) writeFile(params OperationParams, r *common.SecurePathResolv
 		message = fmt.Sprintf("Created %s (%d bytes)", params.Path, len(content))
 	}
 
+	// 写入前把文件原状态记入本次执行（runId）的检查点，供 edit 工具 restore_checkpoint 撤销
+	if runId := common.RunIDFromCtx(ctx); runId != "" {
+		sessionKey, _ := session.SessionKeyFromContext(ctx)
+		if err := common.NewCheckpointManager(r.Workspace()).Record(sessionKey, runId, ToolName, path); err != nil {
+			return common.ErrCheckpointFailed(err.Error()).Error(), nil
+		}
+	}
+
 	// Use atomic write: write to temp file first, then rename
 	if err := atomicWriteFile(path, content); err != nil {
 		return "", fmt.Errorf("write file: %w", err)
@@ -0,0 +1,198 @@
+package token
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dlclark/regexp2"
+)
+
+// Pre-tokenization patterns of the tiktoken encodings.
+const (
+	PatternR50k   = `'s|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+(?!\S)|\s+`
+	PatternCl100k = `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`
+	PatternO200k  = `[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+`
+)
+
+// encodingPatterns maps well-known encodings to their pre-tokenization pattern.
+var encodingPatterns = map[string]string{
+	EncodingR50kBase:   PatternR50k,
+	EncodingP50kBase:   PatternR50k,
+	EncodingCl100kBase: PatternCl100k,
+	EncodingO200kBase:  PatternO200k,
+}
+
+// BPETokenizer is a byte-level BPE tokenizer compatible with tiktoken rank files.
+type BPETokenizer struct {
+	name    string
+	ranks   map[string]int
+	reverse map[int]string
+	pattern *regexp2.Regexp
+}
+
+// NewBPETokenizer creates a tokenizer from mergeable ranks (token bytes -> rank) and a pre-tokenization pattern.
+func NewBPETokenizer(name string, ranks map[string]int, pattern string) (*BPETokenizer, error) {
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("bpe %s: empty ranks", name)
+	}
+	re, err := regexp2.Compile(pattern, regexp2.None)
+	if err != nil {
+		return nil, fmt.Errorf("bpe %s: compile pattern: %w", name, err)
+	}
+	reverse := make(map[int]string, len(ranks))
+	for tok, rank := range ranks {
+		reverse[rank] = tok
+	}
+	return &BPETokenizer{name: name, ranks: ranks, reverse: reverse, pattern: re}, nil
+}
+
+// LoadTiktokenFile loads a tiktoken rank file ("<base64 token> <rank>" per line).
+// pattern may be empty for well-known encodings, in which case it is derived from name.
+func LoadTiktokenFile(name, path, pattern string) (*BPETokenizer, error) {
+	if pattern == "" {
+		pattern = encodingPatterns[name]
+		if pattern == "" {
+			return nil, fmt.Errorf("bpe %s: pattern is required for unknown encoding", name)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("bpe %s: %s:%d: malformed line", name, path, line)
+		}
+		tok, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("bpe %s: %s:%d: %w", name, path, line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("bpe %s: %s:%d: %w", name, path, line, err)
+		}
+		ranks[string(tok)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewBPETokenizer(name, ranks, pattern)
+}
+
+// LoadEncoding loads a tiktoken rank file for a well-known encoding and registers it,
+// so ForModel returns it for every model mapped to that encoding.
+func LoadEncoding(name, path string) (*BPETokenizer, error) {
+	t, err := LoadTiktokenFile(name, path, "")
+	if err != nil {
+		return nil, err
+	}
+	RegisterEncoding(t)
+	return t, nil
+}
+
+// Name returns the encoding name.
+func (t *BPETokenizer) Name() string {
+	return t.name
+}
+
+// Encode returns the token ids of text.
+func (t *BPETokenizer) Encode(text string) []int {
+	var ids []int
+	t.eachPiece(text, func(piece []byte) {
+		if rank, ok := t.ranks[string(piece)]; ok {
+			ids = append(ids, rank)
+			return
+		}
+		ids = t.bytePairEncode(piece, ids)
+	})
+	return ids
+}
+
+// Count returns the number of tokens in text.
+func (t *BPETokenizer) Count(text string) int {
+	count := 0
+	t.eachPiece(text, func(piece []byte) {
+		if _, ok := t.ranks[string(piece)]; ok {
+			count++
+			return
+		}
+		count += len(t.mergeBoundaries(piece)) - 1
+	})
+	return count
+}
+
+// eachPiece splits text with the pre-tokenization pattern.
+func (t *BPETokenizer) eachPiece(text string, fn func(piece []byte)) {
+	if text == "" {
+		return
+	}
+	m, err := t.pattern.FindStringMatch(text)
+	for err == nil && m != nil {
+		fn([]byte(m.String()))
+		m, err = t.pattern.FindNextMatch(m)
+	}
+}
+
+// bytePairEncode appends the ranks of piece after merging to ids.
+// Bytes without a rank (incomplete rank files) are skipped.
+func (t *BPETokenizer) bytePairEncode(piece []byte, ids []int) []int {
+	bounds := t.mergeBoundaries(piece)
+	for i := 0; i < len(bounds)-1; i++ {
+		if rank, ok := t.ranks[string(piece[bounds[i]:bounds[i+1]])]; ok {
+			ids = append(ids, rank)
+		}
+	}
+	return ids
+}
+
+// mergeBoundaries repeatedly merges the adjacent pair with the lowest rank
+// and returns the boundaries of the resulting parts.
+func (t *BPETokenizer) mergeBoundaries(piece []byte) []int {
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		minRank, minIdx := math.MaxInt, -1
+		for i := 0; i < len(bounds)-2; i++ {
+			if rank, ok := t.ranks[string(piece[bounds[i]:bounds[i+2]])]; ok && rank < minRank {
+				minRank, minIdx = rank, i
+			}
+		}
+		if minIdx < 0 {
+			break
+		}
+		bounds = append(bounds[:minIdx+1], bounds[minIdx+2:]...)
+	}
+	return bounds
+}
+
+// Decode converts token ids back to text. Unknown ids are skipped.
+func (t *BPETokenizer) Decode(ids []int) string {
+	var sb strings.Builder
+	for _, id := range ids {
+		if tok, ok := t.reverse[id]; ok {
+			sb.WriteString(tok)
+		}
+	}
+	return sb.String()
+}
@@ -175,27 +175,14 @@ func (mc *MetricsCollector) ToJSON() string {
 	return string(b)
 }
 
-// EstimateTokens provides a rough token count estimation.
-// English: ~4 chars per token, Chinese: ~2 chars per token.
-// This is a rough estimate; actual tokenization depends on the model.
+// EstimateTokens counts tokens with the default tokenizer.
+// Without a configured tokenizer this is the Heuristic estimate; use CountTokens
+// when the model is known.
 func EstimateTokens(text string) int {
 	if len(text) == 0 {
 		return 0
 	}
-
-	// Count Chinese characters
-	chineseCount := 0
-	for _, r := range text {
-		if r >= 0x4E00 && r <= 0x9FFF {
-			chineseCount++
-		}
-	}
-
-	// Non-Chinese characters
-	otherCount := len(text) - chineseCount
-
-	// Chinese: ~2 chars per token, English: ~4 chars per token
-	return (chineseCount / 2) + (otherCount / 4) + 1
+	return Default().Count(text)
 }
 
 // EstimateMessagesTokens estimates tokens for a list of messages.
@@ -206,4 +193,4 @@ func EstimateMessagesTokens(messages []string) int {
 	}
 	// Add overhead for message formatting
 	return total + len(messages)*4
-}
\ No newline at end of file
+}
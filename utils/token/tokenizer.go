@@ -0,0 +1,206 @@
+package token
+
+import (
+	"strings"
+	"sync"
+)
+
+// Tokenizer converts text to model tokens.
+// Implementations must be safe for concurrent use.
+type Tokenizer interface {
+	// Name returns the encoding name, e.g. "cl100k_base".
+	Name() string
+	// Encode returns the token ids of text. Tokenizers that only estimate return nil.
+	Encode(text string) []int
+	// Count returns the number of tokens in text.
+	Count(text string) int
+}
+
+// Heuristic is the fallback tokenizer used when no encoding is registered for a model.
+// English: ~4 chars per token, Chinese: ~2 chars per token.
+// This is a rough estimate; actual tokenization depends on the model.
+var Heuristic Tokenizer = heuristicTokenizer{}
+
+type heuristicTokenizer struct{}
+
+func (heuristicTokenizer) Name() string { return "heuristic" }
+
+func (heuristicTokenizer) Encode(string) []int { return nil }
+
+func (heuristicTokenizer) Count(text string) int {
+	if len(text) == 0 {
+		return 0
+	}
+
+	// Count Chinese characters
+	chineseCount := 0
+	for _, r := range text {
+		if r >= 0x4E00 && r <= 0x9FFF {
+			chineseCount++
+		}
+	}
+
+	// Non-Chinese characters
+	otherCount := len(text) - chineseCount
+
+	// Chinese: ~2 chars per token, English: ~4 chars per token
+	return (chineseCount / 2) + (otherCount / 4) + 1
+}
+
+// Well-known encoding names.
+const (
+	EncodingCl100kBase = "cl100k_base"
+	EncodingO200kBase  = "o200k_base"
+	EncodingP50kBase   = "p50k_base"
+	EncodingR50kBase   = "r50k_base"
+)
+
+var (
+	registryMu sync.RWMutex
+	// encodings holds loaded tokenizers by encoding name.
+	encodings = make(map[string]Tokenizer)
+	// modelEncodings holds application registered model patterns (lowercase) -> encoding name.
+	modelEncodings = make(map[string]string)
+	// defaultTokenizer is used by EstimateTokens and for models without an encoding.
+	defaultTokenizer = Heuristic
+)
+
+// defaultModelEncodings maps built-in model name patterns to their encoding.
+// Matching is case-insensitive and the longest contained pattern wins,
+// the same rule used by the config model capability registry.
+var defaultModelEncodings = map[string]string{
+	"gpt-4o":                 EncodingO200kBase,
+	"gpt-4.1":                EncodingO200kBase,
+	"gpt-4.5":                EncodingO200kBase,
+	"gpt-5":                  EncodingO200kBase,
+	"o1":                     EncodingO200kBase,
+	"o3":                     EncodingO200kBase,
+	"o4":                     EncodingO200kBase,
+	"gpt-4":                  EncodingCl100kBase,
+	"gpt-3.5":                EncodingCl100kBase,
+	"text-embedding-3":       EncodingCl100kBase,
+	"text-embedding-ada-002": EncodingCl100kBase,
+	"text-davinci":           EncodingP50kBase,
+	"davinci":                EncodingR50kBase,
+}
+
+// RegisterEncoding registers a tokenizer under its encoding name.
+func RegisterEncoding(t Tokenizer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	encodings[t.Name()] = t
+}
+
+// UnregisterEncoding removes a registered encoding.
+func UnregisterEncoding(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(encodings, name)
+}
+
+// RegisterModelEncoding maps a model name (or a pattern contained in model names) to an encoding.
+// Application registrations take precedence over the built-in table.
+func RegisterModelEncoding(modelPattern, encoding string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	modelEncodings[strings.ToLower(modelPattern)] = encoding
+}
+
+// UnregisterModelEncoding removes an application registered model mapping.
+func UnregisterModelEncoding(modelPattern string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(modelEncodings, strings.ToLower(modelPattern))
+}
+
+// SetDefault sets the tokenizer used by EstimateTokens and for models without
+// a loaded encoding. nil restores Heuristic.
+func SetDefault(t Tokenizer) {
+	if t == nil {
+		t = Heuristic
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	defaultTokenizer = t
+}
+
+// Default returns the default tokenizer.
+func Default() Tokenizer {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return defaultTokenizer
+}
+
+// EncodingForModel returns the encoding name for a model, or "" if unknown.
+func EncodingForModel(model string) string {
+	if model == "" {
+		return ""
+	}
+	modelLower := strings.ToLower(model)
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if enc := matchLongest(modelLower, modelEncodings); enc != "" {
+		return enc
+	}
+	return matchLongest(modelLower, defaultModelEncodings)
+}
+
+// ForModel returns the tokenizer for a model.
+// Falls back to the default tokenizer when the model's encoding is unknown or not loaded.
+func ForModel(model string) Tokenizer {
+	enc := EncodingForModel(model)
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if t, ok := encodings[enc]; ok && enc != "" {
+		return t
+	}
+	return defaultTokenizer
+}
+
+// CountTokens counts the tokens of text for a model, see ForModel.
+func CountTokens(model, text string) int {
+	if len(text) == 0 {
+		return 0
+	}
+	return ForModel(model).Count(text)
+}
+
+// wordPatterns are short patterns that only match as a whole word of the model name
+// ("o1-mini", "azure/o3"), not inside other names such as "turbo1" or "gpt-4o3x".
+var wordPatterns = map[string]bool{"o1": true, "o3": true, "o4": true}
+
+// matchLongest returns the value of the longest key contained in s.
+func matchLongest(s string, table map[string]string) string {
+	var (
+		matched    string
+		matchedLen = -1
+	)
+	for pattern, value := range table {
+		if len(pattern) > matchedLen && containsPattern(s, pattern) {
+			matched = value
+			matchedLen = len(pattern)
+		}
+	}
+	return matched
+}
+
+// containsPattern reports whether s contains pattern, as a whole word for wordPatterns.
+func containsPattern(s, pattern string) bool {
+	if !wordPatterns[pattern] {
+		return strings.Contains(s, pattern)
+	}
+	for i := 0; i+len(pattern) <= len(s); i++ {
+		if s[i:i+len(pattern)] != pattern {
+			continue
+		}
+		end := i + len(pattern)
+		if (i == 0 || !isAlnum(s[i-1])) && (end == len(s) || !isAlnum(s[end])) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,136 @@
+package token
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeRankFile writes a tiny tiktoken rank file: every single byte plus a few merges.
+func writeRankFile(t *testing.T) string {
+	t.Helper()
+	var sb strings.Builder
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), i)
+	}
+	for i, merge := range []string{"he", "ll", "hell", "hello", " w"} {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(merge)), 256+i)
+	}
+	path := filepath.Join(t.TempDir(), "test.tiktoken")
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBPETokenizer(t *testing.T) {
+	tk, err := LoadTiktokenFile(EncodingCl100kBase, writeRankFile(t), "")
+	if err != nil {
+		t.Fatalf("LoadTiktokenFile() error = %v", err)
+	}
+
+	ids := tk.Encode("hello world")
+	want := []int{259, 260, 'o', 'r', 'l', 'd'}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("Encode() = %v, want %v", ids, want)
+	}
+	if n := tk.Count("hello world"); n != len(want) {
+		t.Errorf("Count() = %d, want %d", n, len(want))
+	}
+	if s := tk.Decode(ids); s != "hello world" {
+		t.Errorf("Decode() = %q", s)
+	}
+	if s := "你好 hello"; tk.Decode(tk.Encode(s)) != s {
+		t.Errorf("multi-byte text does not round trip")
+	}
+	if tk.Count("") != 0 {
+		t.Error("empty text should have no tokens")
+	}
+}
+
+func TestLoadTiktokenFile_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.tiktoken")
+	_ = os.WriteFile(bad, []byte("aGVsbG8=\n"), 0o644)
+
+	if _, err := LoadTiktokenFile(EncodingCl100kBase, bad, ""); err == nil {
+		t.Error("expected error for malformed line")
+	}
+	if _, err := LoadTiktokenFile("custom", bad, ""); err == nil {
+		t.Error("expected error for unknown encoding without pattern")
+	}
+	if _, err := LoadTiktokenFile(EncodingCl100kBase, filepath.Join(dir, "missing"), ""); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestForModel(t *testing.T) {
+	if ForModel("gpt-4o-mini") != Heuristic {
+		t.Fatal("expected heuristic fallback when no encoding is loaded")
+	}
+
+	tk, err := LoadEncoding(EncodingO200kBase, writeRankFile(t))
+	if err != nil {
+		t.Fatalf("LoadEncoding() error = %v", err)
+	}
+	defer UnregisterEncoding(EncodingO200kBase)
+
+	if got := EncodingForModel("GPT-4o-mini"); got != EncodingO200kBase {
+		t.Errorf("EncodingForModel() = %s", got)
+	}
+	if EncodingForModel("gpt-4-turbo") != EncodingCl100kBase {
+		t.Error("gpt-4-turbo should map to cl100k_base")
+	}
+	if ForModel("gpt-4o-mini") != Tokenizer(tk) {
+		t.Error("expected loaded o200k tokenizer for gpt-4o")
+	}
+	if ForModel("gpt-4") != Heuristic {
+		t.Error("expected heuristic for model whose encoding is not loaded")
+	}
+
+	for model, want := range map[string]string{
+		"o1":              EncodingO200kBase,
+		"o3-mini":         EncodingO200kBase,
+		"azure/o4-mini":   EncodingO200kBase,
+		"qwen-turbo1":     "",
+		"llama-3-70b-o1x": "",
+		"deepseek-v3":     "",
+	} {
+		if got := EncodingForModel(model); got != want {
+			t.Errorf("EncodingForModel(%q) = %q, want %q", model, got, want)
+		}
+	}
+
+	RegisterModelEncoding("qwen", EncodingO200kBase)
+	defer UnregisterModelEncoding("qwen")
+	if CountTokens("Qwen-Max", "hello world") != 6 {
+		t.Error("registered model should use its encoding")
+	}
+}
+
+func TestEstimateTokens_Default(t *testing.T) {
+	if n := EstimateTokens("hello world, this is a test"); n != Heuristic.Count("hello world, this is a test") {
+		t.Errorf("EstimateTokens() = %d, want heuristic count", n)
+	}
+	if EstimateTokens("") != 0 || EstimateTokens("a") != 1 {
+		t.Error("unexpected heuristic result for short text")
+	}
+	// 中文约 2 字符一个 token，其余按字节约 4 个一个 token
+	if n := Heuristic.Count("你好世界 hello"); n != 4/2+(len("你好世界 hello")-4)/4+1 {
+		t.Errorf("Heuristic.Count() = %d", n)
+	}
+
+	tk, err := LoadTiktokenFile(EncodingCl100kBase, writeRankFile(t), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetDefault(tk)
+	defer SetDefault(nil)
+	if EstimateTokens("hello world") != 6 {
+		t.Error("EstimateTokens should use the default tokenizer")
+	}
+}